@@ -17,6 +17,7 @@
 protoc_go("github.com/google/gapid/core/data/pack" "core/data/pack" "pack.proto")
 protoc_go("github.com/google/gapid/core/data/record" "core/data/record" "record.proto")
 protoc_go("github.com/google/gapid/core/data/search" "core/data/search" "search.proto")
+protoc_java("core/data/search" "search.proto" "com/google/gapid/proto/search/Search")
 protoc_go("github.com/google/gapid/core/data/stash/grpc" "core/data/stash/grpc" "stash.proto")
 protoc_go("github.com/google/gapid/core/data/stash" "core/data/stash" "stash.proto")
 protoc_go("github.com/google/gapid/gapil/snippets" "gapil/snippets" "snippets.proto")
@@ -21,6 +21,7 @@ if(NOT DISABLED_CXX)
         target_include_directories(gapir PRIVATE "${glue}")
     else()
         add_executable(gapir ${sources})
+        target_link_libraries(gapir deviceinfo)
         install(TARGETS gapir DESTINATION ${TARGET_INSTALL_PATH})
     endif()
     target_link_libraries(gapir gapir_static)
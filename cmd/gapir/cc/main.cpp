@@ -31,9 +31,12 @@
 #include <memory>
 #include <stdlib.h>
 #include <string.h>
+#include <string>
 
 #if TARGET_OS == GAPID_OS_ANDROID
 #include <android_native_app_glue.h>
+#else
+#include "core/os/device/deviceinfo/cc/instance.h"
 #endif  // TARGET_OS == GAPID_OS_ANDROID
 
 using namespace core;
@@ -71,8 +74,9 @@ void listenConnections(std::unique_ptr<Connection> conn,
                        const char* authToken,
                        const char* cachePath,
                        int idleTimeoutMs,
-                       MemoryManager* memoryManager) {
-    ServerListener listener(std::move(conn), memoryManager->getSize());
+                       MemoryManager* memoryManager,
+                       ServerListener::DeviceInstanceProvider deviceInstance) {
+    ServerListener listener(std::move(conn), memoryManager->getSize(), deviceInstance);
 
     std::unique_ptr<ResourceInMemoryCache> resourceProvider(
             createResourceProvider(cachePath, memoryManager));
@@ -137,10 +141,20 @@ void android_main(struct android_app* app) {
 
     // Note if you want to create a disk cache create it under:
     // app->activity->internalDataPath
-    listenConnections(std::move(conn), nullptr, nullptr, Connection::NO_TIMEOUT, &memoryManager);
+    listenConnections(std::move(conn), nullptr, nullptr, Connection::NO_TIMEOUT, &memoryManager, nullptr);
 }
 
 #else  // TARGET_OS == GAPID_OS_ANDROID
+
+// hostDeviceInstance returns the serialized device.Instance proto describing
+// this machine.
+std::string hostDeviceInstance() {
+    device_instance instance = get_device_instance(nullptr);
+    std::string out(reinterpret_cast<const char*>(instance.data), instance.size);
+    free_device_instance(instance);
+    return out;
+}
+
 // Main function for PC
 int main(int argc, const char* argv[]) {
     int logLevel = LOG_LEVEL;
@@ -148,6 +162,7 @@ int main(int argc, const char* argv[]) {
 
     const char* cachePath = nullptr;
     const char* portStr = "0";
+    const char* listenAddress = "127.0.0.1";
     const char* authToken = nullptr;
     int idleTimeoutMs = Connection::NO_TIMEOUT;
 
@@ -167,6 +182,11 @@ int main(int argc, const char* argv[]) {
                 GAPID_FATAL("Usage: --port <port_num>");
             }
             portStr = argv[++i];
+        } else if (strcmp(argv[i], "--listen") == 0) {
+            if (i + 1 >= argc) {
+                GAPID_FATAL("Usage: --listen <address>");
+            }
+            listenAddress = argv[++i];
         } else if (strcmp(argv[i], "--log-level") == 0) {
             if (i + 1 >= argc) {
                 GAPID_FATAL("Usage: --log-level <F|E|W|I|D|V>");
@@ -199,12 +219,16 @@ int main(int argc, const char* argv[]) {
     GAPID_LOGGER_INIT(logLevel, "gapir", logPath);
 
     MemoryManager memoryManager(memorySizes);
-    GAPID_INFO("gapir listening on port %s", portStr);
-    auto conn = SocketConnection::createSocket("127.0.0.1", portStr);
+    GAPID_INFO("gapir listening on %s port %s", listenAddress, portStr);
+    if (authToken == nullptr && strcmp(listenAddress, "127.0.0.1") != 0) {
+        GAPID_WARNING("Listening on %s without an --auth-token", listenAddress);
+    }
+    auto conn = SocketConnection::createSocket(listenAddress, portStr);
     if (conn == nullptr) {
-        GAPID_FATAL("Failed to create listening socket on port: %s", portStr);
+        GAPID_FATAL("Failed to create listening socket on %s port: %s", listenAddress, portStr);
     }
-    listenConnections(std::move(conn), authToken, cachePath, idleTimeoutMs, &memoryManager);
+    listenConnections(std::move(conn), authToken, cachePath, idleTimeoutMs, &memoryManager,
+                      hostDeviceInstance);
     return EXIT_SUCCESS;
 }
 
@@ -17,6 +17,7 @@ package main
 import (
 	"flag"
 	"path/filepath"
+	"strings"
 	"time"
 
 	"github.com/google/gapid/core/app"
@@ -27,6 +28,7 @@ import (
 	"github.com/google/gapid/core/os/android/adb"
 	"github.com/google/gapid/core/os/device"
 	"github.com/google/gapid/core/os/device/bind"
+	gapir "github.com/google/gapid/gapir/client"
 	"github.com/google/gapid/gapis/database"
 	"github.com/google/gapid/gapis/replay"
 	"github.com/google/gapid/gapis/server"
@@ -43,6 +45,8 @@ var (
 	gapirArgStr     = flag.String("gapir-args", "", `"<The arguments to be passed to gapir>"`)
 	scanAndroidDevs = flag.Bool("monitor-android-devices", true, "Server will scan for locally connected Android devices")
 	addLocalDevice  = flag.Bool("add-local-device", true, "Server will create a new local replay device")
+	gapirInstances  = flag.Int("gapir-host-instances", 1, "Number of gapir instances to replay with in parallel on the local device")
+	gapirRemotes    = flag.String("gapir-remote", "", "Comma-separated list of host:port addresses of remote gapir instances to replay on")
 )
 
 func main() {
@@ -59,6 +63,7 @@ var features = []string{}
 
 func run(ctx log.Context) error {
 	m, r := replay.New(ctx), bind.NewRegistry()
+	m.HostInstances = *gapirInstances
 	ctx = replay.PutManager(ctx, m)
 	ctx = bind.PutRegistry(ctx, r)
 	ctx = database.Put(ctx, database.NewInMemory(ctx))
@@ -74,6 +79,13 @@ func run(ctx log.Context) error {
 		r.AddDevice(ctx, bind.Host(ctx))
 	}
 
+	if *gapirRemotes != "" {
+		for _, address := range strings.Split(*gapirRemotes, ",") {
+			address = strings.TrimSpace(address)
+			go gapir.MonitorRemote(ctx, r, address, auth.Token(*gapirAuthToken), time.Second*3)
+		}
+	}
+
 	return server.Listen(ctx, *rpc, server.Config{
 		Info: &service.ServerInfo{
 			Name:         device.Host(ctx).Name,
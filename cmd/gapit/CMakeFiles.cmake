@@ -21,12 +21,18 @@ set(files
     common.go
     devices.go
     dump.go
+    find.go
     flags.go
     info.go
     inputs.go
     main.go
+    mesh.go
     packages.go
     report.go
+    screenshot.go
+    stats.go
+    stats_test.go
+    status.go
     sxs_video.go
     trace.go
     video.go
@@ -0,0 +1,83 @@
+// Copyright (C) 2017 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/google/gapid/core/app"
+	"github.com/google/gapid/core/data/search/script"
+	"github.com/google/gapid/core/log"
+	"github.com/google/gapid/gapis/atom"
+	"github.com/google/gapid/gapis/service/path"
+)
+
+type findVerb struct{ FindFlags }
+
+func init() {
+	verb := &findVerb{}
+	app.AddVerb(&app.Verb{
+		Name:      "find",
+		ShortHelp: "Prints the commands of a .gfxtrace file that match a search query",
+		Auto:      verb,
+	})
+}
+
+func (verb *findVerb) Run(ctx log.Context, flags flag.FlagSet) error {
+	if flags.NArg() < 2 {
+		app.Usage(ctx, "A gfx trace file and a search query expected, got %d arguments", flags.NArg())
+		return nil
+	}
+
+	filepath, err := filepath.Abs(flags.Arg(0))
+	if err != nil {
+		return fmt.Errorf("Could not find capture file '%s': %v", flags.Arg(0), err)
+	}
+
+	expression := strings.Join(flags.Args()[1:], " ")
+	expr, err := script.Parse(ctx, expression)
+	if err != nil {
+		return fmt.Errorf("Malformed search query '%s': %v", expression, err)
+	}
+
+	client, err := getGapis(ctx, verb.Gapis, GapirFlags{})
+	if err != nil {
+		return fmt.Errorf("Failed to connect to the GAPIS server: %v", err)
+	}
+	defer client.Close()
+
+	capture, err := client.LoadCapture(ctx, filepath)
+	if err != nil {
+		return fmt.Errorf("Failed to load the capture file '%v': %v", filepath, err)
+	}
+
+	boxedAtoms, err := client.Get(ctx, capture.Commands().Path())
+	if err != nil {
+		return fmt.Errorf("Failed to acquire the capture's atoms: %v", err)
+	}
+	atoms := boxedAtoms.(*atom.List).Atoms
+
+	search := capture.Commands().Search(expr.Query())
+	search.First, search.Count = verb.First, verb.Count
+
+	stdout := ctx.Raw("").Writer()
+	return client.FindCommands(ctx, search, verb.Max, func(p *path.Command) error {
+		fmt.Fprintf(stdout, "%.6d %v\n", p.Index, atoms[p.Index])
+		return nil
+	})
+}
@@ -34,6 +34,23 @@ const (
 	SimpleList
 )
 
+const (
+	CsvStats StatsOutput = iota
+	JsonStats
+)
+
+const (
+	Color0Attachment AttachmentType = iota
+	Color1Attachment
+	Color2Attachment
+	Color3Attachment
+	Color4Attachment
+	Color5Attachment
+	Color6Attachment
+	Color7Attachment
+	DepthAttachment
+)
+
 type VideoType uint8
 
 var videoTypeNames = map[VideoType]string{
@@ -66,6 +83,41 @@ func (v PackagesOutput) String() string {
 	return packagesOutputNames[v]
 }
 
+type StatsOutput uint8
+
+var statsOutputNames = map[StatsOutput]string{
+	CsvStats:  "csv",
+	JsonStats: "json",
+}
+
+func (v *StatsOutput) Choose(c interface{}) {
+	*v = c.(StatsOutput)
+}
+func (v StatsOutput) String() string {
+	return statsOutputNames[v]
+}
+
+type AttachmentType uint8
+
+var attachmentTypeNames = map[AttachmentType]string{
+	Color0Attachment: "color0",
+	Color1Attachment: "color1",
+	Color2Attachment: "color2",
+	Color3Attachment: "color3",
+	Color4Attachment: "color4",
+	Color5Attachment: "color5",
+	Color6Attachment: "color6",
+	Color7Attachment: "color7",
+	DepthAttachment:  "depth",
+}
+
+func (v *AttachmentType) Choose(c interface{}) {
+	*v = c.(AttachmentType)
+}
+func (v AttachmentType) String() string {
+	return attachmentTypeNames[v]
+}
+
 type (
 	DeviceFlags struct {
 		Device string `help:"Device to spawn on. One of: 'host', 'android' or <device-serial>"`
@@ -109,6 +161,30 @@ type (
 			End   int `help:"frame to end capture on: -1 for last frame"`
 		}
 	}
+	ScreenshotFlags struct {
+		Gapis      GapisFlags
+		Gapir      GapirFlags
+		At         int            `help:"command index to take the screenshot after: -1 for the last command"`
+		Attachment AttachmentType `help:"framebuffer attachment to export"`
+		Layer      int            `help:"layer of the attached image to export: -1 for the layer bound to the framebuffer"`
+		Level      int            `help:"mip level of the attached image to export, used when layer is not -1"`
+		Out        string         `help:"output image path: the extension selects .png, .exr, .hdr or .raw output"`
+		Max        struct {
+			Width  int `help:"maximum image width"`
+			Height int `help:"maximum image height"`
+		}
+		Depth struct {
+			Near float64 `help:"depth mapped to black in .png output"`
+			Far  float64 `help:"depth mapped to white in .png output"`
+		}
+	}
+	MeshFlags struct {
+		Gapis   GapisFlags
+		Gapir   GapirFlags
+		At      int    `help:"index of the draw command to export the mesh of: -1 for the last command"`
+		Faceted bool   `help:"if true then normals are calculated from each face"`
+		Out     string `help:"output mesh path: the extension selects .obj or .glb output"`
+	}
 	DumpFlags struct {
 		Gapis          GapisFlags
 		Gapir          GapirFlags
@@ -117,6 +193,21 @@ type (
 		Raw            bool `help:"if true then the value of constants, instead of their names, will be dumped."`
 		ShowDeviceInfo bool `help:"if true then show originating device information."`
 	}
+	FindFlags struct {
+		Gapis GapisFlags
+		First uint64 `help:"index of the first command to search"`
+		Count uint64 `help:"number of commands to search: 0 for all"`
+		Max   int    `help:"maximum number of commands to print: 0 for all"`
+	}
+	StatsFlags struct {
+		Gapis  GapisFlags
+		Format StatsOutput `help:"output format"`
+		Out    string      `help:"output file, standard output if none"`
+	}
+	StatusFlags struct {
+		Gapis    GapisFlags
+		Interval time.Duration `help:"interval between status updates"`
+	}
 	TraceFlags struct {
 		Gapii GapiiFlags
 		For   time.Duration `help:"duration to trace for"`
@@ -0,0 +1,100 @@
+// Copyright (C) 2017 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"flag"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+
+	"github.com/google/gapid/core/app"
+	"github.com/google/gapid/core/fault/cause"
+	"github.com/google/gapid/core/log"
+	"github.com/google/gapid/gapis/atom"
+	"github.com/google/gapid/gapis/service/path"
+)
+
+type meshVerb struct{ MeshFlags }
+
+func init() {
+	verb := &meshVerb{}
+	verb.Gapir.Device = "host"
+	verb.At = allTheWay
+	verb.Out = "mesh.obj"
+	app.AddVerb(&app.Verb{
+		Name:      "mesh",
+		ShortHelp: "Export the mesh of a draw command of a .gfxtrace file",
+		Auto:      verb,
+	})
+}
+
+var meshFormats = map[string]path.MeshFormat{
+	".obj": path.MeshFormat_OBJ,
+	".glb": path.MeshFormat_GLTF,
+}
+
+func (verb *meshVerb) Run(ctx log.Context, flags flag.FlagSet) error {
+	if flags.NArg() != 1 {
+		app.Usage(ctx, "Exactly one gfx trace file expected, got %d", flags.NArg())
+		return nil
+	}
+
+	format, ok := meshFormats[strings.ToLower(filepath.Ext(verb.Out))]
+	if !ok {
+		app.Usage(ctx, "Unsupported output file extension %q", filepath.Ext(verb.Out))
+		return nil
+	}
+
+	traceFile, err := filepath.Abs(flags.Arg(0))
+	if err != nil {
+		return cause.Explain(ctx, err, "Finding file").With("File", flags.Arg(0))
+	}
+
+	client, err := getGapis(ctx, verb.Gapis, verb.Gapir)
+	if err != nil {
+		return cause.Explain(ctx, err, "Failed to connect to the GAPIS server")
+	}
+	defer client.Close()
+
+	capture, err := client.LoadCapture(ctx, traceFile)
+	if err != nil {
+		return cause.Explain(ctx, err, "LoadCapture").With("capture", traceFile)
+	}
+
+	at := verb.At
+	if at == allTheWay {
+		boxedAtoms, err := client.Get(ctx, capture.Commands().Path())
+		if err != nil {
+			return cause.Explain(ctx, err, "Acquiring the capture's atoms")
+		}
+		at = len(boxedAtoms.(*atom.List).Atoms) - 1
+	}
+	if at < 0 {
+		return cause.Explain(ctx, nil, "Capture has no commands")
+	}
+	ctx = ctx.I("cmd", at)
+
+	mesh := capture.Commands().Index(uint64(at)).Mesh(verb.Faceted)
+	boxedData, err := client.Get(ctx, mesh.As(format).Path())
+	if err != nil {
+		return cause.Explain(ctx, err, "Failed to export the mesh")
+	}
+
+	if err := ioutil.WriteFile(verb.Out, boxedData.([]byte), 0666); err != nil {
+		return cause.Explain(ctx, err, "Failed to write the mesh").With("out", verb.Out)
+	}
+	return nil
+}
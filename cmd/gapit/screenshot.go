@@ -0,0 +1,157 @@
+// Copyright (C) 2017 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"flag"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+
+	"github.com/google/gapid/core/app"
+	"github.com/google/gapid/core/fault/cause"
+	"github.com/google/gapid/core/log"
+	"github.com/google/gapid/gapis/atom"
+	"github.com/google/gapid/gapis/gfxapi"
+	"github.com/google/gapid/gapis/service"
+	"github.com/google/gapid/gapis/service/path"
+
+	img "github.com/google/gapid/core/image"
+)
+
+type screenshotVerb struct{ ScreenshotFlags }
+
+func init() {
+	verb := &screenshotVerb{}
+	verb.Gapir.Device = "host"
+	verb.At = allTheWay
+	verb.Out = "screenshot.png"
+	verb.Max.Width = 0x10000
+	verb.Max.Height = 0x10000
+	verb.Depth.Far = 1
+	verb.Layer = -1
+	app.AddVerb(&app.Verb{
+		Name:      "screenshot",
+		ShortHelp: "Export a framebuffer attachment of a .gfxtrace file as an image",
+		Auto:      verb,
+	})
+}
+
+var screenshotAttachments = map[AttachmentType]gfxapi.FramebufferAttachment{
+	Color0Attachment: gfxapi.FramebufferAttachment_Color0,
+	Color1Attachment: gfxapi.FramebufferAttachment_Color1,
+	Color2Attachment: gfxapi.FramebufferAttachment_Color2,
+	Color3Attachment: gfxapi.FramebufferAttachment_Color3,
+	Color4Attachment: gfxapi.FramebufferAttachment_Color4,
+	Color5Attachment: gfxapi.FramebufferAttachment_Color5,
+	Color6Attachment: gfxapi.FramebufferAttachment_Color6,
+	Color7Attachment: gfxapi.FramebufferAttachment_Color7,
+	DepthAttachment:  gfxapi.FramebufferAttachment_Depth,
+}
+
+func (verb *screenshotVerb) Run(ctx log.Context, flags flag.FlagSet) error {
+	if flags.NArg() != 1 {
+		app.Usage(ctx, "Exactly one gfx trace file expected, got %d", flags.NArg())
+		return nil
+	}
+
+	traceFile, err := filepath.Abs(flags.Arg(0))
+	if err != nil {
+		return cause.Explain(ctx, err, "Finding file").With("File", flags.Arg(0))
+	}
+
+	client, err := getGapis(ctx, verb.Gapis, verb.Gapir)
+	if err != nil {
+		return cause.Explain(ctx, err, "Failed to connect to the GAPIS server")
+	}
+	defer client.Close()
+
+	capture, err := client.LoadCapture(ctx, traceFile)
+	if err != nil {
+		return cause.Explain(ctx, err, "LoadCapture").With("capture", traceFile)
+	}
+
+	device, err := getDevice(ctx, client, capture, verb.Gapir)
+	if err != nil {
+		return err
+	}
+
+	at := verb.At
+	if at == allTheWay {
+		boxedAtoms, err := client.Get(ctx, capture.Commands().Path())
+		if err != nil {
+			return cause.Explain(ctx, err, "Acquiring the capture's atoms")
+		}
+		at = len(boxedAtoms.(*atom.List).Atoms) - 1
+	}
+	if at < 0 {
+		return cause.Explain(ctx, nil, "Capture has no commands")
+	}
+	ctx = ctx.I("cmd", at).V("attachment", verb.Attachment)
+
+	settings := &service.RenderSettings{MaxWidth: uint32(verb.Max.Width), MaxHeight: uint32(verb.Max.Height)}
+	var layer *gfxapi.FramebufferLayer
+	if verb.Layer >= 0 {
+		layer = &gfxapi.FramebufferLayer{Layer: uint32(verb.Layer), Level: uint32(verb.Level)}
+	}
+	iip, err := client.GetFramebufferAttachment(ctx, device, capture.Commands().Index(uint64(at)), screenshotAttachments[verb.Attachment], layer, settings)
+	if err != nil {
+		return cause.Explain(ctx, err, "GetFramebufferAttachment failed")
+	}
+	iio, err := client.Get(ctx, iip.Path())
+	if err != nil {
+		return cause.Explain(ctx, err, "Failed to get the framebuffer image info")
+	}
+	ii := iio.(*img.Info2D)
+	dataO, err := client.Get(ctx, path.NewBlob(ii.Data.ID()).Path())
+	if err != nil {
+		return cause.Explain(ctx, err, "Failed to get the framebuffer image data")
+	}
+	w, h, data := int(ii.Width), int(ii.Height), dataO.([]byte)
+	ctx = ctx.I("width", w).I("height", h).V("format", ii.Format)
+	if w == 0 || h == 0 {
+		return cause.Explain(ctx, nil, "Framebuffer has zero dimensions")
+	}
+
+	switch strings.ToLower(filepath.Ext(verb.Out)) {
+	case ".exr":
+		data, err = img.Convert(data, w, h, ii.Format, img.EXR_ZIP)
+	case ".hdr":
+		data, err = img.Convert(data, w, h, ii.Format, img.HDR)
+	case ".raw":
+		// Written in the attachment's native format.
+		ctx.Info().Logf("Raw framebuffer format: %v", ii.Format)
+	case ".png":
+		if verb.Attachment == DepthAttachment {
+			data, err = img.VisualizeDepth(data, w, h, ii.Format, float32(verb.Depth.Near), float32(verb.Depth.Far))
+		} else {
+			data, err = img.Convert(data, w, h, ii.Format, img.RGBA_U8_NORM)
+		}
+		if err == nil {
+			data, err = img.Convert(data, w, h, img.RGBA_U8_NORM, img.PNG)
+		}
+	default:
+		app.Usage(ctx, "Unsupported output file extension %q", filepath.Ext(verb.Out))
+		return nil
+	}
+	if err != nil {
+		return cause.Explain(ctx, err, "Failed to convert the framebuffer").With("out", verb.Out)
+	}
+
+	if err := ioutil.WriteFile(verb.Out, data, 0666); err != nil {
+		return cause.Explain(ctx, err, "Failed to write the image").With("out", verb.Out)
+	}
+	return nil
+}
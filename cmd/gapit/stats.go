@@ -0,0 +1,148 @@
+// Copyright (C) 2017 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/google/gapid/core/app"
+	"github.com/google/gapid/core/fault/cause"
+	"github.com/google/gapid/core/log"
+	"github.com/google/gapid/gapis/service"
+)
+
+type statsVerb struct{ StatsFlags }
+
+func init() {
+	verb := &statsVerb{}
+	app.AddVerb(&app.Verb{
+		Name:      "stats",
+		ShortHelp: "Prints the per-frame and total statistics of a .gfxtrace file",
+		Auto:      verb,
+	})
+}
+
+func (verb *statsVerb) Run(ctx log.Context, flags flag.FlagSet) error {
+	if flags.NArg() != 1 {
+		app.Usage(ctx, "Exactly one gfx trace file expected, got %d", flags.NArg())
+		return nil
+	}
+
+	filepath, err := filepath.Abs(flags.Arg(0))
+	if err != nil {
+		return fmt.Errorf("Could not find capture file '%s': %v", flags.Arg(0), err)
+	}
+
+	client, err := getGapis(ctx, verb.Gapis, GapirFlags{})
+	if err != nil {
+		return fmt.Errorf("Failed to connect to the GAPIS server: %v", err)
+	}
+	defer client.Close()
+
+	capture, err := client.LoadCapture(ctx, filepath)
+	if err != nil {
+		return fmt.Errorf("Failed to load the capture file '%v': %v", filepath, err)
+	}
+
+	boxedStats, err := client.Get(ctx, capture.Stats().Path())
+	if err != nil {
+		return fmt.Errorf("Failed to acquire the capture's statistics: %v", err)
+	}
+	stats := boxedStats.(*service.Stats)
+
+	w := os.Stdout
+	if verb.Out != "" {
+		f, err := os.OpenFile(verb.Out, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0644)
+		if err != nil {
+			return cause.Explain(ctx, err, "Failed to open statistics output file")
+		}
+		w = f
+		defer w.Close()
+	}
+
+	switch verb.Format {
+	case JsonStats:
+		if err := writeStatsJSON(w, stats); err != nil {
+			return cause.Explain(ctx, err, "marshal json")
+		}
+
+	case CsvStats:
+		if err := writeStatsCSV(w, stats); err != nil {
+			return cause.Explain(ctx, err, "write csv")
+		}
+	}
+
+	return nil
+}
+
+// writeStatsJSON writes the stats as indented JSON.
+func writeStatsJSON(w io.Writer, stats *service.Stats) error {
+	e := json.NewEncoder(w)
+	e.SetIndent("", "  ")
+	return e.Encode(stats)
+}
+
+// writeStatsCSV writes a row for each frame of stats followed by a row for
+// the whole capture. Each category of state change has its own column.
+func writeStatsCSV(w io.Writer, stats *service.Stats) error {
+	categories := []string{}
+	for category := range stats.Total.StateChanges {
+		categories = append(categories, category)
+	}
+	sort.Strings(categories)
+
+	header := []string{
+		"frame", "first command", "command count", "draw calls", "primitives",
+		"vertices", "texture upload bytes", "buffer upload bytes",
+		"shader compiles", "context switches",
+	}
+	for _, category := range categories {
+		header = append(header, category+" changes")
+	}
+
+	out := csv.NewWriter(w)
+	out.Write(header)
+	row := func(name string, f *service.FrameStats) {
+		r := []string{
+			name,
+			fmt.Sprint(f.Range.First),
+			fmt.Sprint(f.Range.Count),
+			fmt.Sprint(f.DrawCalls),
+			fmt.Sprint(f.Primitives),
+			fmt.Sprint(f.Vertices),
+			fmt.Sprint(f.TextureUploadBytes),
+			fmt.Sprint(f.BufferUploadBytes),
+			fmt.Sprint(f.ShaderCompiles),
+			fmt.Sprint(f.ContextSwitches),
+		}
+		for _, category := range categories {
+			r = append(r, fmt.Sprint(f.StateChanges[category]))
+		}
+		out.Write(r)
+	}
+	for i, f := range stats.Frames {
+		row(fmt.Sprint(i), f)
+	}
+	row("total", stats.Total)
+	out.Flush()
+	return out.Error()
+}
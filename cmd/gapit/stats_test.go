@@ -0,0 +1,94 @@
+// Copyright (C) 2017 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	"github.com/google/gapid/core/assert"
+	"github.com/google/gapid/core/log"
+	"github.com/google/gapid/gapis/service"
+)
+
+func testStats() *service.Stats {
+	return &service.Stats{
+		Total: &service.FrameStats{
+			Range:              &service.CommandRange{First: 0, Count: 10},
+			DrawCalls:          3,
+			Primitives:         5,
+			Vertices:           15,
+			StateChanges:       map[string]uint64{"program": 2, "buffer": 1},
+			TextureUploadBytes: 64,
+			BufferUploadBytes:  20,
+			ShaderCompiles:     1,
+			ContextSwitches:    2,
+		},
+		Frames: []*service.FrameStats{
+			{
+				Range:              &service.CommandRange{First: 0, Count: 6},
+				DrawCalls:          2,
+				Primitives:         2,
+				Vertices:           6,
+				StateChanges:       map[string]uint64{"program": 2, "buffer": 1},
+				TextureUploadBytes: 64,
+				BufferUploadBytes:  20,
+				ShaderCompiles:     1,
+				ContextSwitches:    1,
+			},
+			{
+				Range:           &service.CommandRange{First: 6, Count: 4},
+				DrawCalls:       1,
+				Primitives:      3,
+				Vertices:        9,
+				StateChanges:    map[string]uint64{},
+				ContextSwitches: 1,
+			},
+		},
+	}
+}
+
+func TestWriteStatsCSV(t *testing.T) {
+	ctx := log.Testing(t)
+	buf := &bytes.Buffer{}
+	err := writeStatsCSV(buf, testStats())
+	if !assert.With(ctx).ThatError(err).Succeeded() {
+		return
+	}
+	assert.With(ctx).ThatString(buf.String()).Equals(
+		"frame,first command,command count,draw calls,primitives,vertices," +
+			"texture upload bytes,buffer upload bytes,shader compiles,context switches," +
+			"buffer changes,program changes\n" +
+			"0,0,6,2,2,6,64,20,1,1,1,2\n" +
+			"1,6,4,1,3,9,0,0,0,1,0,0\n" +
+			"total,0,10,3,5,15,64,20,1,2,1,2\n")
+}
+
+func TestWriteStatsJSON(t *testing.T) {
+	ctx := log.Testing(t)
+	buf := &bytes.Buffer{}
+	err := writeStatsJSON(buf, testStats())
+	if !assert.With(ctx).ThatError(err).Succeeded() {
+		return
+	}
+	assert.For(ctx, "indented").ThatString(buf.String()).Contains("\n  \"total\": {\n")
+	got := &service.Stats{}
+	err = json.Unmarshal(buf.Bytes(), got)
+	if assert.For(ctx, "unmarshal").ThatError(err).Succeeded() {
+		assert.For(ctx, "stats").That(got.Total).DeepEquals(testStats().Total)
+		assert.For(ctx, "frames").ThatSlice(got.Frames).IsLength(2)
+	}
+}
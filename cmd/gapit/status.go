@@ -0,0 +1,69 @@
+// Copyright (C) 2017 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"time"
+
+	"github.com/google/gapid/core/app"
+	"github.com/google/gapid/core/fault/cause"
+	"github.com/google/gapid/core/log"
+	"github.com/google/gapid/gapis/service"
+)
+
+type statusVerb struct{ StatusFlags }
+
+func init() {
+	verb := &statusVerb{}
+	verb.Interval = time.Second
+	app.AddVerb(&app.Verb{
+		Name:      "status",
+		ShortHelp: "Prints the tasks in flight on a running GAPIS server",
+		Auto:      verb,
+	})
+}
+
+func (verb *statusVerb) Run(ctx log.Context, flags flag.FlagSet) error {
+	if verb.Gapis.Port == 0 {
+		app.Usage(ctx, "A running server must be selected with -gapis-port")
+		return nil
+	}
+	client, err := getGapis(ctx, verb.Gapis, GapirFlags{})
+	if err != nil {
+		return cause.Explain(ctx, err, "Failed to connect to the GAPIS server")
+	}
+	defer client.Close()
+
+	stdout := ctx.Raw("").Writer()
+	return client.Status(ctx, verb.Interval, func(tasks *service.Tasks) error {
+		fmt.Fprintf(stdout, "-- %d tasks --\n", len(tasks.List))
+		depths := map[uint64]int{}
+		for _, t := range tasks.List {
+			depth := 0
+			if d, ok := depths[t.Parent]; ok {
+				depth = d + 1
+			}
+			depths[t.Id] = depth
+			progress := ""
+			if t.Total > 0 {
+				progress = fmt.Sprintf(" %d/%d (%.1f%%)", t.Completed, t.Total, 100*float64(t.Completed)/float64(t.Total))
+			}
+			fmt.Fprintf(stdout, "%*s%v [%v]%v\n", depth*2, "", t.Name, time.Duration(t.ElapsedMs)*time.Millisecond, progress)
+		}
+		return nil
+	})
+}
@@ -350,7 +350,7 @@ func (verb *videoVerb) encodeVideo(ctx log.Context, filepath string, vidFun vide
 func getFrame(ctx log.Context, flags VideoFlags, cmd *path.Command, device *path.Device, client service.Service) (*image.NRGBA, error) {
 	ctx = ctx.I("cmd", int(cmd.Index))
 	settings := &service.RenderSettings{MaxWidth: uint32(flags.Max.Width), MaxHeight: uint32(flags.Max.Height)}
-	iip, err := client.GetFramebufferAttachment(ctx, device, cmd, gfxapi.FramebufferAttachment_Color0, settings)
+	iip, err := client.GetFramebufferAttachment(ctx, device, cmd, gfxapi.FramebufferAttachment_Color0, nil, settings)
 	if err != nil {
 		return nil, err
 	}
@@ -22,6 +22,7 @@ import (
 	"time"
 
 	"github.com/google/gapid/core/app"
+	"github.com/google/gapid/core/data/pack"
 	"github.com/google/gapid/core/fault/cause"
 	"github.com/google/gapid/core/log"
 	"github.com/google/gapid/core/os/file"
@@ -35,6 +36,8 @@ var (
 	packFile   file.Path
 	legacyFile file.Path
 	timing     bool
+	compress   bool
+	blockSize  int
 )
 
 func main() {
@@ -43,6 +46,8 @@ func main() {
 	flag.Var(&packFile, "pack", "the pack file to generate")
 	flag.Var(&legacyFile, "legacy", "the legacy file to generate")
 	flag.BoolVar(&timing, "time", false, "time the encode and decode performanc3")
+	flag.BoolVar(&compress, "compress", false, "compress the pack file in blocks")
+	flag.IntVar(&blockSize, "blocksize", pack.DefaultBlockSize, "the uncompressed size of each compressed pack block")
 	app.Run(run)
 }
 
@@ -54,6 +59,13 @@ func run(ctx log.Context) error {
 		size                          int64
 		err                           error
 	)
+	opts := pack.Options{BlockSize: blockSize}
+	if compress {
+		opts.Compression = pack.Compression_Flate
+	}
+	writePack := func(ctx log.Context, atoms *atom.List, w io.Writer) error {
+		return capture.WritePack(ctx, atoms, w, opts)
+	}
 	args := flag.Args()
 	if len(args) != 1 {
 		return cause.Explainf(ctx, nil, "Expected 1 argument, got %d", len(args))
@@ -73,7 +85,7 @@ func run(ctx log.Context) error {
 		ctx.Notice().Logf("Wrote %v atoms to legacy file in %v", len(atoms.Atoms), d)
 	}
 	if !packFile.IsEmpty() {
-		d := delta(func() { err = writeAtoms(ctx, packFile.System(), atoms, capture.WritePack) })
+		d := delta(func() { err = writeAtoms(ctx, packFile.System(), atoms, writePack) })
 		if err != nil {
 			return cause.Explain(ctx, err, "Unable write pack")
 		}
@@ -120,7 +132,7 @@ func run(ctx log.Context) error {
 	ctx.Notice().Logf("Live[%d]->Legacy[%d] in %v", len(atoms.Atoms), legacyData.Len(), toLegacyTime)
 
 	// Time writing in pack format
-	toPackTime := delta(func() { err = capture.WritePack(ctx, atoms, packData) })
+	toPackTime := delta(func() { err = writePack(ctx, atoms, packData) })
 	if err != nil {
 		return cause.Explain(ctx, err, "Unable write pack")
 	}
@@ -20,6 +20,9 @@
 set(files
     bench.go
     cat.go
+    history.go
+    history_test.go
+    history_verbs.go
     indices.go
     list.go
     main.go
@@ -32,7 +35,10 @@ set(files
     run.go
     samples.go
     samples_test.go
+    stats.go
+    stats_test.go
     summary.go
+    trend.go
 )
 set(dirs
     
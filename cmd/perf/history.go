@@ -0,0 +1,212 @@
+// Copyright (C) 2017 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"time"
+	"unicode"
+
+	"github.com/golang/protobuf/ptypes"
+	"github.com/google/gapid/core/data/search"
+	"github.com/google/gapid/core/data/stash"
+	"github.com/google/gapid/core/log"
+	"github.com/google/gapid/core/os/file"
+)
+
+const (
+	historyIndex   = "history.json"
+	perfzStashType = "application/x-perfz"
+)
+
+var unsafeBuildChars = regexp.MustCompile(`[^A-Za-z0-9._-]+`)
+
+// HistoryEntry is a single .perfz run recorded in a History.
+type HistoryEntry struct {
+	Build  string    // Build or CL identifier the run was made against.
+	Date   time.Time // Time the run was added to the history.
+	Source string    // File name (directory histories) or stash id (stash histories).
+}
+
+// History is a store of .perfz runs indexed by build, used to track
+// performance over time.
+type History interface {
+	// Entries returns all the recorded runs, oldest first.
+	Entries(ctx log.Context) ([]HistoryEntry, error)
+	// Add records the .perfz file as a run of the given build.
+	Add(ctx log.Context, build string, perfzFile string) (HistoryEntry, error)
+	// Load loads the .perfz of a recorded run.
+	Load(ctx log.Context, entry HistoryEntry) (*Perfz, error)
+	// Close releases any resources held by the history.
+	Close()
+}
+
+// OpenHistory opens the history at location. File paths are treated as
+// directory histories, anything with a scheme or host is dialed as a stash.
+func OpenHistory(ctx log.Context, location string) (History, error) {
+	if isFilePath(location) {
+		return &dirHistory{dir: location}, nil
+	}
+	u, err := url.Parse(location)
+	if err != nil || (u.Scheme == "" && u.Host == "") {
+		return &dirHistory{dir: location}, nil
+	}
+	client, err := stash.Dial(ctx, location)
+	if err != nil {
+		return nil, err
+	}
+	return &stashHistory{client: client}, nil
+}
+
+// isFilePath returns true if location is an absolute or existing file path.
+// Windows paths such as C:\perf would otherwise parse as URLs with the scheme
+// "c", so a single letter followed by a colon is taken to be a drive letter.
+func isFilePath(location string) bool {
+	if filepath.IsAbs(location) || filepath.VolumeName(location) != "" {
+		return true
+	}
+	if len(location) >= 2 && location[1] == ':' && unicode.IsLetter(rune(location[0])) {
+		return true
+	}
+	_, err := os.Stat(location)
+	return err == nil
+}
+
+// dirHistory is a History backed by a directory of .perfz files and a
+// JSON index.
+type dirHistory struct {
+	dir string
+}
+
+func (h *dirHistory) Entries(ctx log.Context) ([]HistoryEntry, error) {
+	data, err := ioutil.ReadFile(filepath.Join(h.dir, historyIndex))
+	if os.IsNotExist(err) {
+		return []HistoryEntry{}, nil
+	} else if err != nil {
+		return nil, err
+	}
+	entries := []HistoryEntry{}
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
+
+func (h *dirHistory) Add(ctx log.Context, build string, perfzFile string) (HistoryEntry, error) {
+	entries, err := h.Entries(ctx)
+	if err != nil {
+		return HistoryEntry{}, err
+	}
+	entry := HistoryEntry{
+		Build:  build,
+		Date:   time.Now(),
+		Source: fmt.Sprintf("%04d-%s.perfz", len(entries), unsafeBuildChars.ReplaceAllString(build, "_")),
+	}
+	if err := os.MkdirAll(h.dir, 0755); err != nil {
+		return HistoryEntry{}, err
+	}
+	if err := file.Copy(ctx, file.Abs(filepath.Join(h.dir, entry.Source)), file.Abs(perfzFile)); err != nil {
+		return HistoryEntry{}, err
+	}
+	entries = append(entries, entry)
+	data, err := json.MarshalIndent(entries, "", "  ")
+	if err != nil {
+		return HistoryEntry{}, err
+	}
+	return entry, ioutil.WriteFile(filepath.Join(h.dir, historyIndex), data, 0644)
+}
+
+func (h *dirHistory) Load(ctx log.Context, entry HistoryEntry) (*Perfz, error) {
+	return LoadPerfz(ctx, filepath.Join(h.dir, entry.Source), flagVerifyHashes)
+}
+
+func (h *dirHistory) Close() {}
+
+// stashHistory is a History backed by a stash. Runs are stored as entities
+// of type perfzStashType named after their build.
+type stashHistory struct {
+	client *stash.Client
+}
+
+func (h *stashHistory) Entries(ctx log.Context) ([]HistoryEntry, error) {
+	entries := []HistoryEntry{}
+	err := h.client.Search(ctx, &search.Query{}, func(ctx log.Context, e *stash.Entity) error {
+		if !isPerfzEntity(e) || len(e.Upload.Name) == 0 {
+			return nil
+		}
+		date, err := ptypes.Timestamp(e.Timestamp)
+		if err != nil {
+			return err
+		}
+		entries = append(entries, HistoryEntry{Build: e.Upload.Name[0], Date: date, Source: e.Upload.Id})
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.SliceStable(entries, func(i, j int) bool { return entries[i].Date.Before(entries[j].Date) })
+	return entries, nil
+}
+
+func isPerfzEntity(e *stash.Entity) bool {
+	if e.Upload == nil || e.Status != stash.Present {
+		return false
+	}
+	for _, t := range e.Upload.Type {
+		if t == perfzStashType {
+			return true
+		}
+	}
+	return false
+}
+
+func (h *stashHistory) Add(ctx log.Context, build string, perfzFile string) (HistoryEntry, error) {
+	data, err := ioutil.ReadFile(perfzFile)
+	if err != nil {
+		return HistoryEntry{}, err
+	}
+	info := stash.Upload{Name: []string{build}, Type: []string{perfzStashType}}
+	id, err := h.client.UploadBytes(ctx, info, data)
+	if err != nil {
+		return HistoryEntry{}, err
+	}
+	return HistoryEntry{Build: build, Date: time.Now(), Source: id}, nil
+}
+
+// Load downloads the run into a cache directory keyed by stash id, so
+// that the bundled entries of the returned Perfz remain readable.
+func (h *stashHistory) Load(ctx log.Context, entry HistoryEntry) (*Perfz, error) {
+	cached := file.Abs(filepath.Join(os.TempDir(), "perfz-history", entry.Source+".perfz"))
+	if !cached.Exists() {
+		if err := h.client.GetFile(ctx, entry.Source, cached); err != nil {
+			return nil, err
+		}
+		if !cached.Exists() {
+			return nil, fmt.Errorf("Run of build %s not found in stash: %s", entry.Build, entry.Source)
+		}
+	}
+	return LoadPerfz(ctx, cached.System(), flagVerifyHashes)
+}
+
+func (h *stashHistory) Close() {
+	h.client.Close()
+}
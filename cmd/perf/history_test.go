@@ -0,0 +1,40 @@
+// Copyright (C) 2017 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"os"
+	"testing"
+
+	"github.com/google/gapid/core/assert"
+)
+
+func TestIsFilePath(t *testing.T) {
+	ctx := assert.Context(t)
+	for _, test := range []struct {
+		location string
+		expected bool
+	}{
+		{`C:\perf\history`, true},
+		{`d:/perf`, true},
+		{os.TempDir(), true},
+		{".", true},
+		{"grpc://localhost:8080", false},
+		{"localhost:8080", false},
+		{"missing/history", false},
+	} {
+		assert.For(ctx, test.location).That(isFilePath(test.location)).Equals(test.expected)
+	}
+}
@@ -0,0 +1,92 @@
+// Copyright (C) 2017 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"time"
+
+	"github.com/google/gapid/core/app"
+	"github.com/google/gapid/core/fault/cause"
+	"github.com/google/gapid/core/log"
+)
+
+func init() {
+	historyVerb := &app.Verb{
+		Name:      "history",
+		ShortHelp: "Manages a history of perfz runs indexed by build",
+	}
+	historyVerb.Add(&app.Verb{
+		Name:       "add",
+		ShortHelp:  "Records a perfz file as a run of the given build",
+		Run:        historyAddVerb,
+		ShortUsage: "<history> <build> <perfz>",
+	})
+	historyVerb.Add(&app.Verb{
+		Name:       "list",
+		ShortHelp:  "Lists the runs recorded in a history",
+		Run:        historyListVerb,
+		ShortUsage: "<history>",
+	})
+	app.AddVerb(historyVerb)
+}
+
+func historyAddVerb(ctx log.Context, flags flag.FlagSet) error {
+	if flags.NArg() != 3 {
+		app.Usage(ctx, "Three arguments expected, got %d", flags.NArg())
+		return nil
+	}
+
+	h, err := OpenHistory(ctx, flags.Arg(0))
+	if err != nil {
+		return err
+	}
+	defer h.Close()
+
+	// Make sure we only record loadable runs.
+	if _, err := LoadPerfz(ctx, flags.Arg(2), flagVerifyHashes); err != nil {
+		return err
+	}
+
+	entry, err := h.Add(ctx, flags.Arg(1), flags.Arg(2))
+	if err != nil {
+		return cause.Explain(ctx, err, "History.Add")
+	}
+	ctx.Info().Logf("Recorded run of build %s as %s", entry.Build, entry.Source)
+	return nil
+}
+
+func historyListVerb(ctx log.Context, flags flag.FlagSet) error {
+	if flags.NArg() != 1 {
+		app.Usage(ctx, "One argument expected, got %d", flags.NArg())
+		return nil
+	}
+
+	h, err := OpenHistory(ctx, flags.Arg(0))
+	if err != nil {
+		return err
+	}
+	defer h.Close()
+
+	entries, err := h.Entries(ctx)
+	if err != nil {
+		return err
+	}
+	for _, e := range entries {
+		fmt.Printf("%s  %-20s %s\n", e.Date.Format(time.RFC3339), e.Build, e.Source)
+	}
+	return nil
+}
@@ -82,6 +82,18 @@ func (m KeyedSamples) IndexedMultisamples() IndexedMultisamples {
 	return result
 }
 
+// Compare tests each index present in both m and other for a significant
+// change in duration, returning the comparisons keyed by index.
+func (m KeyedSamples) Compare(other KeyedSamples, alpha float64) map[string]Comparison {
+	res := map[string]Comparison{}
+	for key, before := range m {
+		if after, found := other[key]; found {
+			res[key] = Compare(before.Seconds(), after.Seconds(), alpha)
+		}
+	}
+	return res
+}
+
 func (s IndexedMultisamples) Len() int { return len(s) }
 func (s IndexedMultisamples) Less(i, j int) bool {
 	return s[i].Index < s[j].Index
@@ -137,6 +149,15 @@ func (s *Multisample) Average() time.Duration {
 	return time.Duration(sum / int64(len(*s)))
 }
 
+// Seconds returns the samples as a slice of seconds.
+func (s *Multisample) Seconds() []float64 {
+	res := make([]float64, len(*s))
+	for i, value := range *s {
+		res[i] = value.Duration().Seconds()
+	}
+	return res
+}
+
 func (s *Multisample) Min() time.Duration {
 	min := time.Duration(1<<63 - 1)
 	for _, value := range *s {
@@ -0,0 +1,242 @@
+// Copyright (C) 2017 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"fmt"
+	"math"
+	"sort"
+)
+
+// Summary holds descriptive statistics over a set of values.
+type Summary struct {
+	N      int
+	Mean   float64
+	StdDev float64
+}
+
+// Comparison is the result of testing two sets of values for a
+// significant difference of their means using Welch's t-test.
+type Comparison struct {
+	Before Summary
+	After  Summary
+	Delta  float64 // After.Mean - Before.Mean
+	Lower  float64 // Lower bound of the confidence interval for Delta.
+	Upper  float64 // Upper bound of the confidence interval for Delta.
+	P      float64 // Two-sided p-value of the difference.
+}
+
+// ChangePoint is a position in a series where the values after the point
+// differ significantly from the values before it.
+type ChangePoint struct {
+	Index int // Index of the first series element after the change.
+	Comparison
+}
+
+// Summarize returns the descriptive statistics of values.
+func Summarize(values []float64) Summary {
+	s := Summary{N: len(values)}
+	if s.N == 0 {
+		return s
+	}
+	for _, v := range values {
+		s.Mean += v
+	}
+	s.Mean /= float64(s.N)
+	if s.N > 1 {
+		sum := 0.0
+		for _, v := range values {
+			sum += (v - s.Mean) * (v - s.Mean)
+		}
+		s.StdDev = math.Sqrt(sum / float64(s.N-1))
+	}
+	return s
+}
+
+// Compare tests whether the means of before and after differ, returning
+// the difference together with its (1 - alpha) confidence interval.
+func Compare(before, after []float64, alpha float64) Comparison {
+	c := Comparison{Before: Summarize(before), After: Summarize(after), P: 1}
+	c.Delta = c.After.Mean - c.Before.Mean
+	c.Lower, c.Upper = c.Delta, c.Delta
+	if c.Before.N < 2 || c.After.N < 2 {
+		return c
+	}
+	vb := c.Before.StdDev * c.Before.StdDev / float64(c.Before.N)
+	va := c.After.StdDev * c.After.StdDev / float64(c.After.N)
+	se := math.Sqrt(vb + va)
+	if se == 0 {
+		if c.Delta != 0 {
+			c.P = 0
+		}
+		return c
+	}
+	df := (vb + va) * (vb + va) / (vb*vb/float64(c.Before.N-1) + va*va/float64(c.After.N-1))
+	c.P = studentTwoTailed(c.Delta/se, df)
+	margin := studentQuantile(alpha, df) * se
+	c.Lower, c.Upper = c.Delta-margin, c.Delta+margin
+	return c
+}
+
+// Significant returns true if the difference is significant at level alpha.
+func (c Comparison) Significant(alpha float64) bool {
+	return c.P < alpha
+}
+
+// Relative returns the difference as a fraction of the mean before.
+func (c Comparison) Relative() float64 {
+	if c.Before.Mean == 0 {
+		return 0
+	}
+	return c.Delta / c.Before.Mean
+}
+
+func (c Comparison) String() string {
+	return fmt.Sprintf("%+.4g (%+.2f%%, CI [%+.4g, %+.4g], p=%.4f)",
+		c.Delta, 100*c.Relative(), c.Lower, c.Upper, c.P)
+}
+
+// ChangePoints finds the points in series where the values change
+// significantly at level alpha, using binary segmentation: the split with
+// the smallest p-value is tested (Bonferroni-corrected for the number of
+// candidate splits) and, if significant, both halves are searched again.
+// The returned change points are ordered by index.
+func ChangePoints(series [][]float64, alpha float64) []ChangePoint {
+	res := []ChangePoint{}
+	var segment func(start, end int)
+	segment = func(start, end int) {
+		// Each side of a split needs at least two values to estimate variance.
+		candidates := []int{}
+		for i := start + 1; i < end; i++ {
+			if countValues(series[start:i]) >= 2 && countValues(series[i:end]) >= 2 {
+				candidates = append(candidates, i)
+			}
+		}
+		if len(candidates) == 0 {
+			return
+		}
+		best := ChangePoint{Comparison: Comparison{P: math.Inf(1)}}
+		for _, i := range candidates {
+			c := Compare(flatten(series[start:i]), flatten(series[i:end]), alpha)
+			if c.P < best.P {
+				best = ChangePoint{Index: i, Comparison: c}
+			}
+		}
+		if !best.Significant(alpha / float64(len(candidates))) {
+			return
+		}
+		res = append(res, best)
+		segment(start, best.Index)
+		segment(best.Index, end)
+	}
+	segment(0, len(series))
+	sort.Slice(res, func(i, j int) bool { return res[i].Index < res[j].Index })
+	return res
+}
+
+func countValues(series [][]float64) int {
+	n := 0
+	for _, s := range series {
+		n += len(s)
+	}
+	return n
+}
+
+func flatten(series [][]float64) []float64 {
+	res := make([]float64, 0, countValues(series))
+	for _, s := range series {
+		res = append(res, s...)
+	}
+	return res
+}
+
+// studentTwoTailed returns P(|T| > |t|) for Student's t-distribution with
+// df degrees of freedom.
+func studentTwoTailed(t, df float64) float64 {
+	return incompleteBeta(df/2, 0.5, df/(df+t*t))
+}
+
+// studentQuantile returns the critical value t such that
+// P(|T| > t) = alpha for Student's t-distribution with df degrees of freedom.
+func studentQuantile(alpha, df float64) float64 {
+	lo, hi := 0.0, 1.0
+	for studentTwoTailed(hi, df) > alpha && hi < 1e6 {
+		hi *= 2
+	}
+	for i := 0; i < 100; i++ {
+		mid := (lo + hi) / 2
+		if studentTwoTailed(mid, df) > alpha {
+			lo = mid
+		} else {
+			hi = mid
+		}
+	}
+	return (lo + hi) / 2
+}
+
+// incompleteBeta returns the regularized incomplete beta function I_x(a, b).
+func incompleteBeta(a, b, x float64) float64 {
+	switch {
+	case x <= 0:
+		return 0
+	case x >= 1:
+		return 1
+	}
+	la, _ := math.Lgamma(a)
+	lb, _ := math.Lgamma(b)
+	lab, _ := math.Lgamma(a + b)
+	front := math.Exp(lab - la - lb + a*math.Log(x) + b*math.Log(1-x))
+	if x < (a+1)/(a+b+2) {
+		return front * betaContinuedFraction(a, b, x) / a
+	}
+	return 1 - front*betaContinuedFraction(b, a, 1-x)/b
+}
+
+// betaContinuedFraction evaluates the continued fraction for the incomplete
+// beta function using the modified Lentz's method.
+func betaContinuedFraction(a, b, x float64) float64 {
+	const (
+		maxIterations = 300
+		epsilon       = 1e-14
+		tiny          = 1e-300
+	)
+	clamp := func(v float64) float64 {
+		if math.Abs(v) < tiny {
+			return tiny
+		}
+		return v
+	}
+	c := 1.0
+	d := 1 / clamp(1-(a+b)*x/(a+1))
+	h := d
+	for m := 1; m <= maxIterations; m++ {
+		fm := float64(m)
+		// Even step.
+		num := fm * (b - fm) * x / ((a + 2*fm - 1) * (a + 2*fm))
+		d = 1 / clamp(1+num*d)
+		c = clamp(1 + num/c)
+		h *= d * c
+		// Odd step.
+		num = -(a + fm) * (a + b + fm) * x / ((a + 2*fm) * (a + 2*fm + 1))
+		d = 1 / clamp(1+num*d)
+		c = clamp(1 + num/c)
+		delta := d * c
+		h *= delta
+		if math.Abs(delta-1) < epsilon {
+			break
+		}
+	}
+	return h
+}
@@ -0,0 +1,85 @@
+// Copyright (C) 2017 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"math/rand"
+	"testing"
+	"time"
+
+	"github.com/google/gapid/core/assert"
+)
+
+func TestStudentQuantile(t *testing.T) {
+	ctx := assert.Context(t)
+	assert.With(ctx).ThatFloat(studentQuantile(0.05, 10)).Equals(2.228, 0.001)
+	assert.With(ctx).ThatFloat(studentQuantile(0.01, 5)).Equals(4.032, 0.001)
+	assert.With(ctx).ThatFloat(studentQuantile(0.05, 1e6)).Equals(1.960, 0.001)
+	assert.With(ctx).ThatFloat(studentTwoTailed(2.228, 10)).Equals(0.05, 0.0001)
+}
+
+func TestCompare(t *testing.T) {
+	ctx := assert.Context(t)
+	c := Compare([]float64{1, 2, 3, 4, 5}, []float64{3, 4, 5, 6, 7}, 0.05)
+	assert.With(ctx).ThatFloat(c.Delta).Equals(2, 1e-9)
+	assert.With(ctx).ThatFloat(c.P).Equals(0.0805, 0.0001)
+	assert.With(ctx).ThatFloat(c.Lower).Equals(-0.306, 0.001)
+	assert.With(ctx).ThatFloat(c.Upper).Equals(4.306, 0.001)
+	assert.With(ctx).That(c.Significant(0.05)).Equals(false)
+
+	c = Compare([]float64{1, 1, 1}, []float64{2, 2, 2}, 0.05)
+	assert.With(ctx).That(c.Significant(0.05)).Equals(true)
+}
+
+func TestChangePoints(t *testing.T) {
+	ctx := assert.Context(t)
+	r := rand.New(rand.NewSource(1))
+	series := make([][]float64, 20)
+	for i := range series {
+		mean := 10.0
+		if i >= 12 {
+			mean = 11.0
+		}
+		for j := 0; j < 5; j++ {
+			series[i] = append(series[i], mean+r.NormFloat64()*0.5)
+		}
+	}
+	changes := ChangePoints(series, 0.05)
+	assert.With(ctx).ThatInteger(len(changes)).Equals(1)
+	assert.With(ctx).ThatInteger(changes[0].Index).Equals(12)
+	assert.With(ctx).ThatFloat(changes[0].Lower).IsAtLeast(0)
+
+	flat := make([][]float64, 10)
+	for i := range flat {
+		flat[i] = []float64{1, 2, 3}
+	}
+	assert.With(ctx).ThatInteger(len(ChangePoints(flat, 0.05))).Equals(0)
+}
+
+func TestKeyedSamplesCompare(t *testing.T) {
+	ctx := assert.Context(t)
+	before, after := NewKeyedSamples(), NewKeyedSamples()
+	for i := 0; i < 5; i++ {
+		before.Add(1, time.Duration(10+i)*time.Millisecond)
+		after.Add(1, time.Duration(10+i)*time.Millisecond)
+		before.Add(2, time.Duration(10+i)*time.Millisecond)
+		after.Add(2, time.Duration(20+i)*time.Millisecond)
+		before.Add(3, time.Millisecond)
+	}
+	res := before.Compare(after, 0.05)
+	assert.With(ctx).ThatInteger(len(res)).Equals(2)
+	assert.With(ctx).That(res["0000000001"].Significant(0.05)).Equals(false)
+	assert.With(ctx).That(res["0000000002"].Significant(0.05)).Equals(true)
+}
@@ -0,0 +1,342 @@
+// Copyright (C) 2017 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"regexp"
+	"sort"
+	"text/template"
+
+	"github.com/google/gapid/core/app"
+	"github.com/google/gapid/core/app/benchmark"
+	"github.com/google/gapid/core/log"
+)
+
+const trendTemplate = `#!/usr/bin/env gnuplot
+{{range $i, $t := .Trends}}$trend{{$i}} << EOD
+#build median min max
+{{range $j, $p := $t.Points}}{{$j}} {{$p.Median}} {{$p.Min}} {{$p.Max}}
+{{end}}EOD
+{{end}}
+reset
+set terminal svg size 1600, {{.Height}}
+{{.Extra}}
+set multiplot layout {{len .Trends}}, 1 title "{/=20 {{.BenchName}}}"
+set style fill transparent solid 0.2 noborder
+{{range $i, $t := .Trends}}
+set title "{{$t.Name}}"
+set ylabel "{{$t.Unit}}"
+set xtics ({{range $j, $p := $t.Points}}{{if $j}}, {{end}}"{{$p.Build}}" {{$j}}{{end}}) rotate by -45
+unset arrow
+{{range $t.Changes}}set arrow from {{.Index}}-0.5, graph 0 to {{.Index}}-0.5, graph 1 nohead lc rgb "{{if gt .Delta 0.0}}red{{else}}green{{end}}"
+{{end}}plot '$trend{{$i}}' using 1:3:4 with filledcurves title 'min..max', \
+     '$trend{{$i}}' using 1:2 with lp lt 1 pt 7 ps 0.5 lw 1 title 'median'
+{{end}}unset multiplot
+`
+
+var (
+	flagTrendBenchmark  string
+	flagTrendMetrics    string
+	flagTrendPlot       string
+	flagTrendRunGnuplot bool
+	flagAlpha           float64
+)
+
+func init() {
+	verb := &app.Verb{
+		Name:       "trend",
+		ShortHelp:  "Reports and plots metric trends and regressions across a perfz history",
+		Run:        trendVerb,
+		ShortUsage: "<history>",
+	}
+	verb.Flags.Raw.StringVar(&flagTrendBenchmark, "b", "", "benchmark name")
+	verb.Flags.Raw.StringVar(&flagTrendMetrics, "m", "", "regular expression selecting the metrics to analyse")
+	verb.Flags.Raw.Float64Var(&flagAlpha, "alpha", 0.05, "significance level of the regression tests")
+	verb.Flags.Raw.StringVar(&flagTrendPlot, "o", "", "output file for the plot, no plot if empty")
+	verb.Flags.Raw.BoolVar(&flagTrendRunGnuplot, "run-gnuplot", true, "run gnuplot")
+	app.AddVerb(verb)
+}
+
+// TrendPoint holds the values of a metric for a single build.
+type TrendPoint struct {
+	Build  string
+	Values []float64
+}
+
+// Trend is the history of a single metric across builds.
+type Trend struct {
+	Name    string
+	Unit    string
+	Points  []TrendPoint
+	Changes []ChangePoint
+}
+
+// Median returns the median of the point's values.
+func (p TrendPoint) Median() float64 {
+	s := append([]float64{}, p.Values...)
+	sort.Float64s(s)
+	if len(s)%2 != 0 {
+		return s[len(s)/2]
+	}
+	return (s[len(s)/2-1] + s[len(s)/2]) / 2
+}
+
+// Min returns the smallest of the point's values.
+func (p TrendPoint) Min() float64 {
+	min := p.Values[0]
+	for _, v := range p.Values {
+		if v < min {
+			min = v
+		}
+	}
+	return min
+}
+
+// Max returns the largest of the point's values.
+func (p TrendPoint) Max() float64 {
+	max := p.Values[0]
+	for _, v := range p.Values {
+		if v > max {
+			max = v
+		}
+	}
+	return max
+}
+
+// Analyse finds the significant changes in the trend at level alpha.
+func (t *Trend) Analyse(alpha float64) {
+	series := make([][]float64, len(t.Points))
+	for i, p := range t.Points {
+		series[i] = p.Values
+	}
+	t.Changes = ChangePoints(series, alpha)
+}
+
+// trends accumulates Trends by name, in the order they were first seen.
+type trends struct {
+	byName map[string]*Trend
+	order  []*Trend
+}
+
+func (t *trends) add(name, unit, build string, values []float64) {
+	if len(values) == 0 {
+		return
+	}
+	trend, found := t.byName[name]
+	if !found {
+		trend = &Trend{Name: name, Unit: unit}
+		t.byName[name] = trend
+		t.order = append(t.order, trend)
+	}
+	trend.Points = append(trend.Points, TrendPoint{Build: build, Values: values})
+}
+
+func (t *trends) addBenchmark(build string, b *Benchmark) {
+	t.add("total", "s", build, []float64{b.TotalTimeTaken.Duration().Seconds()})
+
+	samples := []float64{}
+	for _, s := range b.Samples.IndexedMultisamples() {
+		samples = append(samples, s.Values.Seconds()...)
+	}
+	t.add("samples", "s", build, samples)
+
+	for _, name := range sortedKeys(b.Metrics) {
+		t.add("metric/"+name, "s", build, b.Metrics[name].Seconds())
+	}
+
+	if b.Counters == nil {
+		return
+	}
+	counters := b.Counters.AllCounters()
+	names := make([]string, 0, len(counters))
+	for name := range counters {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		switch c := counters[name].(type) {
+		case *benchmark.IntegerCounter:
+			t.add("counter/"+name, "", build, []float64{float64(c.GetInt64())})
+		case *benchmark.DurationCounter:
+			t.add("counter/"+name, "s", build, []float64{c.GetDuration().Seconds()})
+		}
+	}
+}
+
+func sortedKeys(m map[string]*Multisample) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func trendVerb(ctx log.Context, flags flag.FlagSet) error {
+	if flags.NArg() != 1 {
+		app.Usage(ctx, "One argument expected, got %d", flags.NArg())
+		return nil
+	}
+
+	pattern, err := regexp.Compile(flagTrendMetrics)
+	if err != nil {
+		return err
+	}
+
+	h, err := OpenHistory(ctx, flags.Arg(0))
+	if err != nil {
+		return err
+	}
+	defer h.Close()
+
+	entries, err := h.Entries(ctx)
+	if err != nil {
+		return err
+	}
+	if len(entries) == 0 {
+		return fmt.Errorf("No runs recorded in history %s", flags.Arg(0))
+	}
+
+	all := &trends{byName: map[string]*Trend{}}
+	benchName := flagTrendBenchmark
+	var previous, last *Benchmark
+	var previousBuild, lastBuild string
+	for _, e := range entries {
+		perfz, err := h.Load(ctx, e)
+		if err != nil {
+			return err
+		}
+		bench, err := selectBenchmark(perfz, benchName)
+		if err != nil {
+			ctx.Warning().Logf("Skipping build %s: %v", e.Build, err)
+			continue
+		}
+		benchName = bench.Input.Name
+		all.addBenchmark(e.Build, bench)
+		previous, previousBuild = last, lastBuild
+		last, lastBuild = bench, e.Build
+	}
+
+	selected := []*Trend{}
+	for _, t := range all.order {
+		if pattern.MatchString(t.Name) {
+			t.Analyse(flagAlpha)
+			selected = append(selected, t)
+		}
+	}
+
+	printTrendReport(selected)
+	if previous != nil && pattern.MatchString("samples") {
+		printSampleComparison(previousBuild, lastBuild, previous.Samples.Compare(last.Samples, flagAlpha))
+	}
+
+	if flagTrendPlot == "" || len(selected) == 0 {
+		return nil
+	}
+	return plotTrends(benchName, selected)
+}
+
+func printTrendReport(trends []*Trend) {
+	for _, t := range trends {
+		unit := ""
+		if t.Unit != "" {
+			unit = fmt.Sprintf(" (%s)", t.Unit)
+		}
+		fmt.Printf("%s%s: %d builds\n", t.Name, unit, len(t.Points))
+		for _, c := range t.Changes {
+			fmt.Printf("  %s at %s (after %s): %v\n",
+				changeKind(t, c.Comparison), t.Points[c.Index].Build, t.Points[c.Index-1].Build, c.Comparison)
+		}
+	}
+}
+
+// changeKind describes the direction of a change. For durations lower is
+// better, for other counters the direction is reported as is.
+func changeKind(t *Trend, c Comparison) string {
+	switch {
+	case t.Unit == "s" && c.Delta > 0:
+		return "REGRESSION"
+	case t.Unit == "s":
+		return "improvement"
+	case c.Delta > 0:
+		return "increase"
+	default:
+		return "decrease"
+	}
+}
+
+func printSampleComparison(before, after string, comparisons map[string]Comparison) {
+	keys := []string{}
+	for k, c := range comparisons {
+		if c.Significant(flagAlpha) {
+			keys = append(keys, k)
+		}
+	}
+	if len(keys) == 0 {
+		return
+	}
+	sort.Strings(keys)
+	fmt.Printf("samples changed between %s and %s:\n", before, after)
+	for _, k := range keys {
+		c := comparisons[k]
+		fmt.Printf("  %s %s: %v\n", k, changeKind(&Trend{Unit: "s"}, c), c)
+	}
+}
+
+func plotTrends(benchName string, trends []*Trend) error {
+	args := struct {
+		Trends    []*Trend
+		BenchName string
+		Height    int
+		Extra     string
+	}{
+		Trends:    trends,
+		BenchName: benchName,
+		Height:    300 * len(trends),
+		Extra: func() string {
+			if flagTrendRunGnuplot && flagTrendPlot != "-" {
+				return fmt.Sprintf(`set output "%s"`, flagTrendPlot)
+			}
+			return ""
+		}(),
+	}
+
+	tmpl, err := template.New("trend").Parse(trendTemplate)
+	if err != nil {
+		return err
+	}
+
+	writeScript := func(w io.Writer) error {
+		return tmpl.Execute(w, args)
+	}
+
+	if !flagTrendRunGnuplot {
+		return writeAllFn(flagTrendPlot, writeScript)
+	}
+	fn, _, err := FuncDataSource(writeScript).DiskFile()
+	if err != nil {
+		return err
+	}
+	defer os.Remove(fn)
+	cmd := exec.Command("gnuplot", fn)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	return cmd.Run()
+}
@@ -67,33 +67,63 @@ func GenToken() Token {
 // RPC calls for the given auth token.
 func ServerInterceptor(token Token) grpc.UnaryServerInterceptor {
 	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
-		if token != NoAuth {
-			md, ok := metadata.FromContext(ctx)
-			if !ok {
-				return nil, ErrInvalidToken
-			}
+		if err := check(ctx, token); err != nil {
+			return nil, err
+		}
+		return handler(ctx, req)
+	}
+}
 
-			got, ok := md[rpcHeader]
-			if !ok || len(got) != 1 || Token(got[0]) != token {
-				return nil, ErrInvalidToken
-			}
+// StreamServerInterceptor returns a grpc.StreamServerInterceptor that checks
+// incoming streaming RPC calls for the given auth token.
+func StreamServerInterceptor(token Token) grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		if err := check(ss.Context(), token); err != nil {
+			return err
 		}
+		return handler(srv, ss)
+	}
+}
 
-		return handler(ctx, req)
+// check returns ErrInvalidToken if the metadata of ctx does not hold token.
+func check(ctx context.Context, token Token) error {
+	if token == NoAuth {
+		return nil
+	}
+	md, ok := metadata.FromContext(ctx)
+	if !ok {
+		return ErrInvalidToken
+	}
+	got, ok := md[rpcHeader]
+	if !ok || len(got) != 1 || Token(got[0]) != token {
+		return ErrInvalidToken
 	}
+	return nil
 }
 
 // ClientInterceptor returns a grpc.UnaryClientInterceptor that adds the given
 // auth token to outgoing RPC calls.
 func ClientInterceptor(token Token) grpc.UnaryClientInterceptor {
 	return func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
-		if token != NoAuth {
-			if md, ok := metadata.FromContext(ctx); ok {
-				ctx = metadata.NewContext(ctx, metadata.Join(md, metadata.Pairs(rpcHeader, string(token))))
-			} else {
-				ctx = metadata.NewContext(ctx, metadata.Pairs(rpcHeader, string(token)))
-			}
-		}
-		return invoker(ctx, method, req, reply, cc, opts...)
+		return invoker(addToken(ctx, token), method, req, reply, cc, opts...)
+	}
+}
+
+// StreamClientInterceptor returns a grpc.StreamClientInterceptor that adds the
+// given auth token to outgoing streaming RPC calls.
+func StreamClientInterceptor(token Token) grpc.StreamClientInterceptor {
+	return func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, streamer grpc.Streamer, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+		return streamer(addToken(ctx, token), desc, cc, method, opts...)
+	}
+}
+
+// addToken returns ctx with token added to its outgoing metadata.
+func addToken(ctx context.Context, token Token) context.Context {
+	if token == NoAuth {
+		return ctx
+	}
+	if md, ok := metadata.FromContext(ctx); ok {
+		return metadata.NewContext(ctx, metadata.Join(md, metadata.Pairs(rpcHeader, string(token))))
 	}
+	return metadata.NewContext(ctx, metadata.Pairs(rpcHeader, string(token)))
 }
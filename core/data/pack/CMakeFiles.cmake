@@ -18,10 +18,13 @@
 # build and the file will be recreated, check in the new version.
 
 set(files
+    block.go
     doc.go
+    index.go
     pack.go
     pack.pb.go
     pack.proto
+    pack_test.go
     reader.go
     types.go
     writer.go
@@ -0,0 +1,158 @@
+// Copyright (C) 2017 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pack
+
+import (
+	"bufio"
+	"bytes"
+	"compress/flate"
+	"encoding/binary"
+	"io"
+	"io/ioutil"
+
+	"github.com/golang/protobuf/proto"
+)
+
+// blockWriter is an io.Writer that gathers sections into blocks, and writes
+// each block compressed once it reaches the target size.
+type blockWriter struct {
+	to         io.Writer
+	size       int
+	data       bytes.Buffer
+	compressed bytes.Buffer
+	deflate    *flate.Writer
+	sizebuf    *proto.Buffer
+}
+
+func newBlockWriter(to io.Writer, size int) (*blockWriter, error) {
+	b := &blockWriter{
+		to:      to,
+		size:    size,
+		sizebuf: proto.NewBuffer(make([]byte, 0, maxVarintSize*2)),
+	}
+	deflate, err := flate.NewWriter(&b.compressed, flate.DefaultCompression)
+	if err != nil {
+		return nil, err
+	}
+	b.deflate = deflate
+	b.data.Grow(size)
+	return b, nil
+}
+
+// empty returns true if nothing has been written to the current block.
+func (b *blockWriter) empty() bool {
+	return b.data.Len() == 0
+}
+
+// Write appends p to the current block.
+func (b *blockWriter) Write(p []byte) (int, error) {
+	return b.data.Write(p)
+}
+
+// endSection is called after each complete section has been written, and
+// flushes the current block if it has reached the target size.
+func (b *blockWriter) endSection() error {
+	if b.data.Len() < b.size {
+		return nil
+	}
+	return b.flush()
+}
+
+// flush compresses and writes out the current block, if it is not empty.
+func (b *blockWriter) flush() error {
+	if b.data.Len() == 0 {
+		return nil
+	}
+	b.compressed.Reset()
+	b.deflate.Reset(&b.compressed)
+	if _, err := b.deflate.Write(b.data.Bytes()); err != nil {
+		return err
+	}
+	if err := b.deflate.Close(); err != nil {
+		return err
+	}
+	if err := b.sizebuf.EncodeVarint(uint64(b.data.Len())); err != nil {
+		return err
+	}
+	if err := b.sizebuf.EncodeVarint(uint64(b.compressed.Len())); err != nil {
+		return err
+	}
+	_, err := b.to.Write(b.sizebuf.Bytes())
+	b.sizebuf.Reset()
+	b.data.Reset()
+	if err != nil {
+		return err
+	}
+	_, err = b.to.Write(b.compressed.Bytes())
+	return err
+}
+
+// blockReader is an io.Reader that returns the decompressed data of the
+// blocks read from an underlying reader.
+type blockReader struct {
+	from    *bufio.Reader
+	data    bytes.Buffer
+	inflate io.ReadCloser
+}
+
+func newBlockReader(from io.Reader) *blockReader {
+	return &blockReader{
+		from:    bufio.NewReader(from),
+		inflate: flate.NewReader(nil),
+	}
+}
+
+// Read reads decompressed data, decompressing the next block if the current
+// one has been consumed.
+func (b *blockReader) Read(p []byte) (int, error) {
+	for b.data.Len() == 0 {
+		if err := b.next(); err != nil {
+			return 0, err
+		}
+	}
+	return b.data.Read(p)
+}
+
+func (b *blockReader) next() error {
+	size, err := binary.ReadUvarint(b.from)
+	if err != nil {
+		// An EOF here is the clean end of the file.
+		return err
+	}
+	compressed, err := binary.ReadUvarint(b.from)
+	if err != nil {
+		return unexpectedEOF(err)
+	}
+	block := io.LimitReader(b.from, int64(compressed))
+	if err := b.inflate.(flate.Resetter).Reset(block, nil); err != nil {
+		return err
+	}
+	b.data.Reset()
+	b.data.Grow(int(size))
+	if _, err := io.CopyN(&b.data, b.inflate, int64(size)); err != nil {
+		return unexpectedEOF(err)
+	}
+	// Skip anything the decompressor did not need to consume, so that the
+	// reader is positioned at the start of the next block.
+	_, err = io.Copy(ioutil.Discard, block)
+	return err
+}
+
+func unexpectedEOF(err error) error {
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
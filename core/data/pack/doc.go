@@ -18,4 +18,12 @@
 // After that is a repeated sequence of uvarint length, tag and matching encoded message pair.
 // Some section tags will also be followed by a string.
 // The tag 0 is special, and marks a type entry, the body will be a descriptor.DescriptorProto.
+//
+// If the Header declares a compression scheme, the sections following the Header
+// are grouped into blocks. Each block is stored as the uvarint size of the
+// uncompressed data, the uvarint size of the compressed data and then the
+// compressed data. Sections never span blocks, and each block starts with the
+// type entries of all the types registered before the block, so every block
+// can be decoded without the blocks that precede it. ReadIndex lists the
+// blocks of a file, and NewReaderAt starts decoding a file at any block.
 package pack
@@ -0,0 +1,127 @@
+// Copyright (C) 2017 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pack
+
+import (
+	"encoding/binary"
+	"io"
+
+	"github.com/golang/protobuf/proto"
+)
+
+// Block describes a single block of a compressed pack file.
+type Block struct {
+	// Offset is the offset of the block from the start of the file.
+	Offset int64
+	// Size is the size of the uncompressed data of the block.
+	Size uint64
+}
+
+// ReadIndex returns the list of blocks of the compressed pack file from.
+// Only the block headers are read, the blocks are not decompressed.
+// ReadIndex returns ErrNotCompressed if the file is not compressed.
+func ReadIndex(from io.ReadSeeker) ([]Block, error) {
+	offset, err := seekBlocks(from)
+	if err != nil {
+		return nil, err
+	}
+	out := []Block{}
+	for {
+		r := &countingByteReader{from: from}
+		size, err := binary.ReadUvarint(r)
+		if err == io.EOF {
+			return out, nil // Clean end of the file.
+		}
+		if err != nil {
+			return nil, err
+		}
+		compressed, err := binary.ReadUvarint(r)
+		if err != nil {
+			return nil, unexpectedEOF(err)
+		}
+		out = append(out, Block{Offset: offset, Size: size})
+		offset += int64(r.count) + int64(compressed)
+		if _, err := from.Seek(offset, io.SeekStart); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// NewReaderAt builds a pack file reader that decodes the compressed pack file
+// from starting at the block b, as returned by ReadIndex.
+// NewReaderAt returns ErrNotCompressed if the file is not compressed.
+func NewReaderAt(from io.ReadSeeker, b Block) (*Reader, error) {
+	if _, err := seekBlocks(from); err != nil {
+		return nil, err
+	}
+	if _, err := from.Seek(b.Offset, io.SeekStart); err != nil {
+		return nil, err
+	}
+	return newReader(newBlockReader(from)), nil
+}
+
+// seekBlocks reads and validates the magic and header of the compressed pack
+// file from, returning the offset of the first block.
+func seekBlocks(from io.ReadSeeker) (int64, error) {
+	if _, err := from.Seek(0, io.SeekStart); err != nil {
+		return 0, err
+	}
+	magic := make([]byte, len(magicBytes))
+	if _, err := io.ReadFull(from, magic); err != nil {
+		return 0, err
+	}
+	if string(magic) != Magic {
+		return 0, ErrIncorrectMagic
+	}
+	r := &countingByteReader{from: from}
+	size, err := binary.ReadUvarint(r)
+	if err != nil {
+		return 0, unexpectedEOF(err)
+	}
+	data := make([]byte, size)
+	if _, err := io.ReadFull(from, data); err != nil {
+		return 0, unexpectedEOF(err)
+	}
+	header := &Header{}
+	if err := proto.Unmarshal(data, header); err != nil {
+		return 0, err
+	}
+	switch {
+	case header.GetVersion().GetMajor() != compressedVersion.GetMajor():
+		if header.GetVersion().GetMajor() == version.GetMajor() {
+			return 0, ErrNotCompressed
+		}
+		return 0, ErrUnknownVersion
+	case header.Compression != Compression_Flate:
+		return 0, ErrUnknownCompression
+	}
+	return int64(len(magic)) + int64(r.count) + int64(size), nil
+}
+
+// countingByteReader is an io.ByteReader that reads a byte at a time from an
+// io.Reader, counting the bytes read.
+type countingByteReader struct {
+	from  io.Reader
+	buf   [1]byte
+	count int
+}
+
+func (r *countingByteReader) ReadByte() (byte, error) {
+	if _, err := io.ReadFull(r.from, r.buf[:]); err != nil {
+		return 0, err
+	}
+	r.count++
+	return r.buf[0], nil
+}
@@ -25,11 +25,24 @@ const (
 	// ErrUnknownVersion is the error returned when the header version is one this
 	// package cannot handle.
 	ErrUnknownVersion = fault.Const("Unknown pack file version")
+	// ErrUnknownCompression is the error returned when the header compression
+	// is one this package cannot handle.
+	ErrUnknownCompression = fault.Const("Unknown pack file compression")
+	// ErrNotCompressed is the error returned when a block operation is
+	// attempted on an uncompressed file.
+	ErrNotCompressed = fault.Const("Pack file is not compressed")
 
 	// VersionMajor is the curent major version the package writes.
 	VersionMajor = 1
 	// VersionMinor is the current minor version the package writes.
 	VersionMinor = 0
+	// CompressedVersionMajor is the major version the package writes for
+	// compressed files, which older readers cannot decode.
+	CompressedVersionMajor = 2
+
+	// DefaultBlockSize is the block size used for compressed files if none is
+	// specified.
+	DefaultBlockSize = 1 << 20
 
 	initalBufferSize = 4096
 	maxVarintSize    = 10
@@ -42,4 +55,17 @@ var (
 		Major: VersionMajor,
 		Minor: VersionMinor,
 	}
+	compressedVersion = Version{
+		Major: CompressedVersionMajor,
+		Minor: VersionMinor,
+	}
 )
+
+// Options controls the encoding of a pack file.
+type Options struct {
+	// Compression is the compression scheme applied to the file.
+	Compression Compression
+	// BlockSize is the target size of the uncompressed data of each block.
+	// If zero, DefaultBlockSize is used.
+	BlockSize int
+}
@@ -0,0 +1,135 @@
+// Copyright (C) 2017 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pack_test
+
+import (
+	"bytes"
+	"io"
+	"testing"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/google/gapid/core/assert"
+	"github.com/google/gapid/core/data/pack"
+	"github.com/google/gapid/core/log"
+)
+
+func TestRoundTrip(t *testing.T) {
+	ctx := log.Testing(t)
+	messages := []proto.Message{}
+	for i := uint32(0); i < 1000; i++ {
+		messages = append(messages, &pack.Version{Major: i, Minor: i * 3})
+	}
+	for _, test := range []struct {
+		name string
+		opts pack.Options
+	}{
+		{"none", pack.Options{}},
+		{"flate", pack.Options{Compression: pack.Compression_Flate}},
+		{"flate small blocks", pack.Options{Compression: pack.Compression_Flate, BlockSize: 64}},
+	} {
+		ctx := ctx.S("test", test.name)
+		buf := &bytes.Buffer{}
+		w, err := pack.NewWriterWithOptions(buf, test.opts)
+		if !assert.With(ctx).ThatError(err).Succeeded() {
+			continue
+		}
+		for _, msg := range messages {
+			assert.With(ctx).ThatError(w.Marshal(msg)).Succeeded()
+		}
+		assert.With(ctx).ThatError(w.Flush()).Succeeded()
+
+		r, err := pack.NewReader(buf)
+		if !assert.With(ctx).ThatError(err).Succeeded() {
+			continue
+		}
+		got := []proto.Message{}
+		for {
+			msg, err := r.Unmarshal()
+			if err == io.EOF {
+				break
+			}
+			if !assert.With(ctx).ThatError(err).Succeeded() {
+				break
+			}
+			got = append(got, msg)
+		}
+		assert.With(ctx).ThatSlice(got).DeepEquals(messages)
+	}
+}
+
+func TestUnknownCompression(t *testing.T) {
+	ctx := log.Testing(t)
+	_, err := pack.NewWriterWithOptions(&bytes.Buffer{}, pack.Options{Compression: pack.Compression(99)})
+	assert.With(ctx).ThatError(err).Equals(pack.ErrUnknownCompression)
+}
+
+func TestSeek(t *testing.T) {
+	ctx := log.Testing(t)
+	messages := []proto.Message{}
+	for i := uint32(0); i < 1000; i++ {
+		messages = append(messages, &pack.Version{Major: i, Minor: i * 3})
+		messages = append(messages, &pack.Header{BlockSize: i})
+	}
+	buf := &bytes.Buffer{}
+	w, err := pack.NewWriterWithOptions(buf, pack.Options{Compression: pack.Compression_Flate, BlockSize: 256})
+	assert.With(ctx).ThatError(err).Succeeded()
+	for _, msg := range messages {
+		assert.With(ctx).ThatError(w.Marshal(msg)).Succeeded()
+	}
+	assert.With(ctx).ThatError(w.Flush()).Succeeded()
+
+	file := bytes.NewReader(buf.Bytes())
+	blocks, err := pack.ReadIndex(file)
+	assert.With(ctx).ThatError(err).Succeeded()
+	if !assert.For(ctx, "blocks").That(len(blocks) > 2).Equals(true) {
+		return
+	}
+
+	// Decoding from any block gives the messages that follow the block.
+	for _, i := range []int{0, 1, len(blocks) / 2, len(blocks) - 1} {
+		ctx := ctx.I("block", i)
+		r, err := pack.NewReaderAt(file, blocks[i])
+		if !assert.With(ctx).ThatError(err).Succeeded() {
+			continue
+		}
+		got := []proto.Message{}
+		for {
+			msg, err := r.Unmarshal()
+			if err == io.EOF {
+				break
+			}
+			if !assert.With(ctx).ThatError(err).Succeeded() {
+				break
+			}
+			got = append(got, msg)
+		}
+		if i == 0 {
+			assert.With(ctx).ThatSlice(got).DeepEquals(messages)
+		} else {
+			assert.For(ctx, "count").That(len(got) < len(messages)).Equals(true)
+			assert.With(ctx).ThatSlice(got).DeepEquals(messages[len(messages)-len(got):])
+		}
+	}
+}
+
+func TestIndexUncompressed(t *testing.T) {
+	ctx := log.Testing(t)
+	buf := &bytes.Buffer{}
+	w, err := pack.NewWriter(buf)
+	assert.With(ctx).ThatError(err).Succeeded()
+	assert.With(ctx).ThatError(w.Marshal(&pack.Version{Major: 1})).Succeeded()
+	_, err = pack.ReadIndex(bytes.NewReader(buf.Bytes()))
+	assert.With(ctx).ThatError(err).Equals(pack.ErrNotCompressed)
+}
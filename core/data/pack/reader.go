@@ -15,6 +15,7 @@
 package pack
 
 import (
+	"bytes"
 	"io"
 	"reflect"
 
@@ -45,9 +46,20 @@ func NewReader(from io.Reader) (*Reader, error) {
 	if err := r.readMagic(); err != nil {
 		return nil, err
 	}
-	if _, err := r.readHeader(); err != nil {
+	header, err := r.readHeader()
+	if err != nil {
 		return nil, err
 	}
+	switch header.Compression {
+	case Compression_None:
+	case Compression_Flate:
+		// Anything already buffered beyond the header belongs to the first block.
+		buffered := append([]byte{}, r.buf[r.next:]...)
+		r.from = newBlockReader(io.MultiReader(bytes.NewReader(buffered), r.from))
+		r.buf, r.next = r.buf[:0], 0
+	default:
+		return nil, ErrUnknownCompression
+	}
 	return r, nil
 }
 
@@ -119,7 +131,13 @@ func (r *Reader) readHeader() (*Header, error) {
 	if err := r.pb.Unmarshal(header); err != nil {
 		return nil, err
 	}
-	if header.GetVersion().GetMajor() != version.GetMajor() {
+	switch header.GetVersion().GetMajor() {
+	case version.GetMajor():
+		if header.Compression != Compression_None {
+			return header, ErrUnknownVersion
+		}
+	case compressedVersion.GetMajor():
+	default:
 		return header, ErrUnknownVersion
 	}
 	return header, nil
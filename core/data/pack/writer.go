@@ -29,14 +29,23 @@ type (
 		buf     *proto.Buffer
 		sizebuf *proto.Buffer
 		to      io.Writer
+		block   *blockWriter
 	}
 )
 
 // NewWriter constructs and returns a new Writer that writes to the supplied
-// output stream.
+// output stream without compression.
 // This method will write the packfile magic and header to the underlying
 // stream.
 func NewWriter(to io.Writer) (*Writer, error) {
+	return NewWriterWithOptions(to, Options{})
+}
+
+// NewWriterWithOptions constructs and returns a new Writer that writes to the
+// supplied output stream, encoded with opts.
+// This method will write the packfile magic and header to the underlying
+// stream.
+func NewWriterWithOptions(to io.Writer, opts Options) (*Writer, error) {
 	w := &Writer{
 		Types:   NewTypes(),
 		buf:     proto.NewBuffer(make([]byte, 0, initalBufferSize)),
@@ -47,22 +56,64 @@ func NewWriter(to io.Writer) (*Writer, error) {
 		return nil, err
 	}
 	header := &Header{Version: &version}
+	switch opts.Compression {
+	case Compression_None:
+	case Compression_Flate:
+		if opts.BlockSize <= 0 {
+			opts.BlockSize = DefaultBlockSize
+		}
+		header.Version = &compressedVersion
+		header.Compression = opts.Compression
+		header.BlockSize = uint32(opts.BlockSize)
+	default:
+		return nil, ErrUnknownCompression
+	}
 	if err := w.writeHeader(header); err != nil {
 		return nil, err
 	}
+	if header.Compression != Compression_None {
+		block, err := newBlockWriter(to, opts.BlockSize)
+		if err != nil {
+			return nil, err
+		}
+		w.block, w.to = block, block
+	}
 	return w, nil
 }
 
+// Flush writes out any buffered data. It does nothing for uncompressed files.
+func (w *Writer) Flush() error {
+	if w.block == nil {
+		return nil
+	}
+	return w.block.flush()
+}
+
 // Marshal writes a new object to the packfile, preceding it with a
 // type entry if needed.
 func (w *Writer) Marshal(msg proto.Message) error {
 	entry, added := w.Types.AddMessage(msg)
-	if added {
+	switch {
+	case w.block != nil && w.block.empty():
+		// Start each block with all the types registered so far, so that the
+		// block can be decoded without the blocks that precede it.
+		for _, t := range w.Types.entries[1:] {
+			if err := w.writeType(*t); err != nil {
+				return err
+			}
+		}
+	case added:
 		if err := w.writeType(entry); err != nil {
 			return err
 		}
 	}
-	return w.writeSection(entry.Index, "", msg)
+	if err := w.writeSection(entry.Index, "", msg); err != nil {
+		return err
+	}
+	if w.block == nil {
+		return nil
+	}
+	return w.block.endSection()
 }
 
 func (w *Writer) writeType(t Type) error {
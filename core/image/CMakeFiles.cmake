@@ -19,12 +19,24 @@
 
 set(files
     astc.go
+    astc_decode.go
+    astc_test.go
     atc.go
+    bptc.go
+    bptc_decode.go
+    bptc_test.go
     convert.go
+    depth.go
+    depth_test.go
     doc.go
+    eac.go
     etc1.go
     etc2.go
+    exr.go
+    exr_test.go
     format.go
+    hdr.go
+    hdr_test.go
     id.go
     image.go
     image.pb.go
@@ -34,6 +46,8 @@ set(files
     resizer.go
     rgba_f32.go
     rgba_f32_test.go
+    rgtc.go
+    rgtc_test.go
     s3.go
     s3_dxt1_rgb.go
     s3_dxt1_rgba.go
@@ -156,9 +156,9 @@ func init() {
 		NewASTC_SRGB8_ALPHA8_12x12(""),
 	}
 	for _, f := range fmts {
+		astc := f.GetAstc()
 		RegisterConverter(f, RGBA_U8_NORM, func(src []byte, width, height int) ([]byte, error) {
-			// TODO: Implement decompressor.
-			return make([]byte, width*height*4), nil
+			return decodeASTC(src, width, height, astc)
 		})
 	}
 }
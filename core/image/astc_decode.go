@@ -0,0 +1,690 @@
+// Copyright (C) 2017 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package image
+
+import (
+	"bytes"
+
+	"github.com/google/gapid/core/data/endian"
+	"github.com/google/gapid/core/math/sint"
+	"github.com/google/gapid/core/os/device"
+)
+
+// This file implements an ASTC decoder conforming to the LDR profile of the
+// Khronos Data Format Specification. Blocks that are illegal, or that use HDR
+// endpoint modes or HDR void-extents, decode to the error colour (magenta).
+
+// decodeASTC decodes the ASTC encoded image data to RGBA_U8_NORM.
+func decodeASTC(src []byte, width, height int, f *FmtASTC) ([]byte, error) {
+	dst := make([]byte, width*height*4)
+	bw, bh := int(f.BlockWidth), int(f.BlockHeight)
+	texels := make([]byte, bw*bh*4)
+	r := endian.Reader(bytes.NewReader(src), device.LittleEndian)
+	for y := 0; y < height; y += bh {
+		for x := 0; x < width; x += bw {
+			blk := astcBlock{lo: r.Uint64(), hi: r.Uint64()}
+			if !blk.decode(bw, bh, f.Srgb, texels) {
+				for i := 0; i < len(texels); i += 4 {
+					copy(texels[i:], astcErrorColor[:])
+				}
+			}
+			for dy := 0; dy < bh && y+dy < height; dy++ {
+				for dx := 0; dx < bw && x+dx < width; dx++ {
+					copy(dst[4*((y+dy)*width+x+dx):], texels[4*(dy*bw+dx):4*(dy*bw+dx+1)])
+				}
+			}
+		}
+	}
+	return dst, r.Error()
+}
+
+var astcErrorColor = [4]byte{0xff, 0x00, 0xff, 0xff}
+
+// astcQuant describes one of the integer sequence encoding ranges.
+// Each value is encoded as a trit or quint (if either is set) followed by
+// bits low bits.
+type astcQuant struct {
+	trit, quint bool
+	bits        uint
+}
+
+// astcQuants lists all the ranges in increasing order of precision.
+// Weights use the ranges [0..11], colour endpoints use [4..20].
+var astcQuants = []astcQuant{
+	{bits: 1},              // 0..1
+	{trit: true, bits: 0},  // 0..2
+	{bits: 2},              // 0..3
+	{quint: true, bits: 0}, // 0..4
+	{trit: true, bits: 1},  // 0..5
+	{bits: 3},              // 0..7
+	{quint: true, bits: 1}, // 0..9
+	{trit: true, bits: 2},  // 0..11
+	{bits: 4},              // 0..15
+	{quint: true, bits: 2}, // 0..19
+	{trit: true, bits: 3},  // 0..23
+	{bits: 5},              // 0..31
+	{quint: true, bits: 3}, // 0..39
+	{trit: true, bits: 4},  // 0..47
+	{bits: 6},              // 0..63
+	{quint: true, bits: 4}, // 0..79
+	{trit: true, bits: 5},  // 0..95
+	{bits: 7},              // 0..127
+	{quint: true, bits: 5}, // 0..159
+	{trit: true, bits: 6},  // 0..191
+	{bits: 8},              // 0..255
+}
+
+const astcMinColorQuant = 4 // 0..5
+
+// iseBits returns the number of bits used to encode count values.
+func (q astcQuant) iseBits(count int) int {
+	bits := count * int(q.bits)
+	switch {
+	case q.trit:
+		bits += (8*count + 4) / 5
+	case q.quint:
+		bits += (7*count + 2) / 3
+	}
+	return bits
+}
+
+var (
+	astcTrits  [256][5]uint32
+	astcQuints [128][3]uint32
+)
+
+func init() {
+	bit := func(v uint32, i uint) uint32 { return (v >> i) & 1 }
+	for t := uint32(0); t < 256; t++ {
+		var c, t0, t1, t2, t3, t4 uint32
+		if (t>>2)&7 == 7 {
+			c = (t>>5)<<2 | t&3
+			t4, t3 = 2, 2
+		} else {
+			c = t & 0x1f
+			if (t>>5)&3 == 3 {
+				t4, t3 = 2, bit(t, 7)
+			} else {
+				t4, t3 = bit(t, 7), (t>>5)&3
+			}
+		}
+		switch {
+		case c&3 == 3:
+			t2, t1, t0 = 2, bit(c, 4), bit(c, 3)<<1|(bit(c, 2)&^bit(c, 3))
+		case (c>>2)&3 == 3:
+			t2, t1, t0 = 2, 2, c&3
+		default:
+			t2, t1, t0 = bit(c, 4), (c>>2)&3, bit(c, 1)<<1|(bit(c, 0)&^bit(c, 1))
+		}
+		astcTrits[t] = [5]uint32{t0, t1, t2, t3, t4}
+	}
+	for q := uint32(0); q < 128; q++ {
+		var q0, q1, q2 uint32
+		if (q>>1)&3 == 3 && (q>>5)&3 == 0 {
+			q2 = bit(q, 0)<<2 | (bit(q, 4)&^bit(q, 0))<<1 | (bit(q, 3) &^ bit(q, 0))
+			q1, q0 = 4, 4
+		} else {
+			var c uint32
+			if (q>>1)&3 == 3 {
+				q2 = 4
+				c = (q>>3)&3<<3 | (^(q>>5)&3)<<1 | bit(q, 0)
+			} else {
+				q2 = (q >> 5) & 3
+				c = q & 0x1f
+			}
+			if c&7 == 5 {
+				q1, q0 = 4, (c>>3)&3
+			} else {
+				q1, q0 = (c>>3)&3, c&7
+			}
+		}
+		astcQuints[q] = [3]uint32{q0, q1, q2}
+	}
+}
+
+// bitReplicate expands the from-bit value v to to bits by repeating it.
+func bitReplicate(v uint32, from, to uint) uint32 {
+	if from == 0 {
+		return 0
+	}
+	res, n := uint32(0), uint(0)
+	for n < to {
+		res, n = res<<from|v, n+from
+	}
+	return res >> (n - to)
+}
+
+// unquantizeColor maps an integer sequence encoded colour value to [0..255].
+func (q astcQuant) unquantizeColor(v uint32) int {
+	if !q.trit && !q.quint {
+		return int(bitReplicate(v, q.bits, 8))
+	}
+	m, d := v&(1<<q.bits-1), v>>q.bits
+	a := uint32(0)
+	if m&1 != 0 {
+		a = 0x1ff
+	}
+	bit := func(i uint) uint32 { return (m >> i) & 1 }
+	b, c := bit(1), bit(2)
+	var B, C uint32
+	switch {
+	case q.trit && q.bits == 1:
+		B, C = 0, 204
+	case q.trit && q.bits == 2: // b000b0bb0
+		B, C = b<<8|b<<4|b<<2|b<<1, 93
+	case q.trit && q.bits == 3: // cb000cbcb
+		B, C = c<<8|b<<7|c<<3|b<<2|c<<1|b, 44
+	case q.trit && q.bits == 4: // dcb000dcb
+		B, C = bit(3)<<8|c<<7|b<<6|bit(3)<<2|c<<1|b, 22
+	case q.trit && q.bits == 5: // edcb000ed
+		B, C = bit(4)<<8|bit(3)<<7|c<<6|b<<5|bit(4)<<1|bit(3), 11
+	case q.trit && q.bits == 6: // fedcb000f
+		B, C = bit(5)<<8|bit(4)<<7|bit(3)<<6|c<<5|b<<4|bit(5), 5
+	case q.quint && q.bits == 1:
+		B, C = 0, 113
+	case q.quint && q.bits == 2: // b0000bb00
+		B, C = b<<8|b<<3|b<<2, 54
+	case q.quint && q.bits == 3: // cb0000cbc
+		B, C = c<<8|b<<7|c<<2|b<<1|c, 26
+	case q.quint && q.bits == 4: // dcb0000dc
+		B, C = bit(3)<<8|c<<7|b<<6|bit(3)<<1|c, 13
+	case q.quint && q.bits == 5: // edcb0000e
+		B, C = bit(4)<<8|bit(3)<<7|c<<6|b<<5|bit(4), 6
+	}
+	t := (d*C + B) ^ a
+	return int(a&0x80 | t>>2)
+}
+
+// unquantizeWeight maps an integer sequence encoded weight to [0..64].
+func (q astcQuant) unquantizeWeight(v uint32) int {
+	var res uint32
+	switch {
+	case !q.trit && !q.quint:
+		res = bitReplicate(v, q.bits, 6)
+	case q.bits == 0 && q.trit:
+		return [...]int{0, 32, 64}[v]
+	case q.bits == 0 && q.quint:
+		return [...]int{0, 16, 32, 48, 64}[v]
+	default:
+		m, d := v&(1<<q.bits-1), v>>q.bits
+		a := uint32(0)
+		if m&1 != 0 {
+			a = 0x7f
+		}
+		b, c := (m>>1)&1, (m>>2)&1
+		var B, C uint32
+		switch {
+		case q.trit && q.bits == 1:
+			B, C = 0, 50
+		case q.trit && q.bits == 2: // b000b0b
+			B, C = b<<6|b<<2|b, 23
+		case q.trit && q.bits == 3: // cb000cb
+			B, C = c<<6|b<<5|c<<1|b, 11
+		case q.quint && q.bits == 1:
+			B, C = 0, 28
+		case q.quint && q.bits == 2: // b0000b0
+			B, C = b<<6|b<<1, 13
+		}
+		t := (d*C + B) ^ a
+		res = a&0x20 | t>>2
+	}
+	if res > 32 {
+		res++
+	}
+	return int(res)
+}
+
+// astcBlock is a single 128-bit ASTC block.
+type astcBlock struct {
+	lo, hi uint64
+}
+
+// bits returns count (at most 32) bits of the block starting at offset.
+func (b astcBlock) bits(offset, count uint) uint32 {
+	var v uint64
+	switch {
+	case offset >= 64:
+		v = b.hi >> (offset - 64)
+	case offset+count <= 64:
+		v = b.lo >> offset
+	default:
+		v = b.lo>>offset | b.hi<<(64-offset)
+	}
+	return uint32(v & (1<<count - 1))
+}
+
+// reversed returns the block with the bit order reversed.
+func (b astcBlock) reversed() astcBlock {
+	rev := func(v uint64) uint64 {
+		r := uint64(0)
+		for i := 0; i < 64; i++ {
+			r, v = r<<1|v&1, v>>1
+		}
+		return r
+	}
+	return astcBlock{lo: rev(b.hi), hi: rev(b.lo)}
+}
+
+// ise decodes count integer sequence encoded values of range q starting at
+// bit offset. Bits past the end of the sequence are treated as zero.
+func (b astcBlock) ise(offset uint, count int, q astcQuant) []uint32 {
+	pos, end := offset, offset+uint(q.iseBits(count))
+	read := func(n uint) uint32 {
+		if pos >= end {
+			pos += n
+			return 0
+		}
+		v := b.bits(pos, n)
+		if pos+n > end {
+			v &= 1<<(end-pos) - 1
+		}
+		pos += n
+		return v
+	}
+	res := make([]uint32, count)
+	switch {
+	case q.trit:
+		for i := 0; i < count; i += 5 {
+			m, t, shift := [5]uint32{}, uint32(0), uint(0)
+			for j, n := range [5]uint{2, 2, 1, 2, 1} {
+				m[j] = read(q.bits)
+				t |= read(n) << shift
+				shift += n
+			}
+			for j := 0; j < 5 && i+j < count; j++ {
+				res[i+j] = astcTrits[t][j]<<q.bits | m[j]
+			}
+		}
+	case q.quint:
+		for i := 0; i < count; i += 3 {
+			m, v, shift := [3]uint32{}, uint32(0), uint(0)
+			for j, n := range [3]uint{3, 2, 2} {
+				m[j] = read(q.bits)
+				v |= read(n) << shift
+				shift += n
+			}
+			for j := 0; j < 3 && i+j < count; j++ {
+				res[i+j] = astcQuints[v][j]<<q.bits | m[j]
+			}
+		}
+	default:
+		for i := range res {
+			res[i] = read(q.bits)
+		}
+	}
+	return res
+}
+
+// astcBlockMode is the decoded form of the 11-bit block mode field.
+type astcBlockMode struct {
+	gridW, gridH int
+	dualPlane    bool
+	weightQuant  int
+}
+
+func decodeASTCBlockMode(mode uint32) (astcBlockMode, bool) {
+	m := astcBlockMode{}
+	h, d := (mode>>9)&1, (mode>>10)&1
+	a, b := int((mode>>5)&3), int((mode>>7)&3)
+	var r uint32
+	if mode&3 != 0 {
+		r = (mode>>4)&1 | (mode&3)<<1
+		switch (mode >> 2) & 3 {
+		case 0:
+			m.gridW, m.gridH = b+4, a+2
+		case 1:
+			m.gridW, m.gridH = b+8, a+2
+		case 2:
+			m.gridW, m.gridH = a+2, b+8
+		case 3:
+			if mode&0x100 == 0 {
+				m.gridW, m.gridH = a+2, (b&1)+6
+			} else {
+				m.gridW, m.gridH = (b&1)+2, a+2
+			}
+		}
+	} else {
+		r = (mode>>4)&1 | ((mode>>2)&3)<<1
+		switch (mode >> 7) & 3 {
+		case 0:
+			m.gridW, m.gridH = 12, a+2
+		case 1:
+			m.gridW, m.gridH = a+2, 12
+		case 2:
+			m.gridW, m.gridH = a+6, int((mode>>9)&3)+6
+			h, d = 0, 0
+		case 3:
+			switch a {
+			case 0:
+				m.gridW, m.gridH = 6, 10
+			case 1:
+				m.gridW, m.gridH = 10, 6
+			default:
+				return m, false
+			}
+		}
+	}
+	if r < 2 {
+		return m, false
+	}
+	m.dualPlane = d != 0
+	m.weightQuant = int(r-2) + 6*int(h)
+	return m, true
+}
+
+// decode decodes the block of bw x bh texels into out as RGBA_U8_NORM,
+// returning false if the block is illegal or unsupported.
+func (b astcBlock) decode(bw, bh int, srgb bool, out []byte) bool {
+	mode := b.bits(0, 11)
+	if mode&0x1ff == 0x1fc {
+		// Void-extent block, the whole block is a single colour.
+		if mode&0x200 != 0 {
+			return false // HDR
+		}
+		c := [4]byte{
+			byte(b.bits(64, 16) >> 8),
+			byte(b.bits(80, 16) >> 8),
+			byte(b.bits(96, 16) >> 8),
+			byte(b.bits(112, 16) >> 8),
+		}
+		for i := 0; i < len(out); i += 4 {
+			copy(out[i:], c[:])
+		}
+		return true
+	}
+
+	bm, ok := decodeASTCBlockMode(mode)
+	if !ok || bm.gridW > bw || bm.gridH > bh {
+		return false
+	}
+	planes := 1
+	if bm.dualPlane {
+		planes = 2
+	}
+	partitions := int(b.bits(11, 2)) + 1
+	weightQuant := astcQuants[bm.weightQuant]
+	weightCount := bm.gridW * bm.gridH * planes
+	weightBits := weightQuant.iseBits(weightCount)
+	if weightCount > 64 || weightBits < 24 || weightBits > 96 || (bm.dualPlane && partitions == 4) {
+		return false
+	}
+
+	// Colour endpoint modes.
+	cems := [4]uint32{}
+	belowWeights := 128 - weightBits
+	colorStart := 17
+	if partitions == 1 {
+		cems[0] = b.bits(13, 4)
+	} else {
+		colorStart = 29
+		cem := b.bits(23, 6)
+		if cem&3 == 0 {
+			for i := 0; i < partitions; i++ {
+				cems[i] = cem >> 2
+			}
+		} else {
+			extra := 3*partitions - 4
+			belowWeights -= extra
+			cem |= b.bits(uint(belowWeights), uint(extra)) << 6
+			base := cem&3 - 1
+			for i := uint(0); i < uint(partitions); i++ {
+				class := base + (cem>>(2+i))&1
+				cems[i] = class<<2 | (cem>>(2+uint(partitions)+2*i))&3
+			}
+		}
+	}
+	ccs := -1
+	if bm.dualPlane {
+		belowWeights -= 2
+		ccs = int(b.bits(uint(belowWeights), 2))
+	}
+
+	// Colour endpoints.
+	colorCount := 0
+	for i := 0; i < partitions; i++ {
+		colorCount += int(cems[i]>>2+1) * 2
+	}
+	if colorCount > 18 {
+		return false
+	}
+	colorQuant := -1
+	for q := len(astcQuants) - 1; q >= 0; q-- {
+		if astcQuants[q].iseBits(colorCount) <= belowWeights-colorStart {
+			colorQuant = q
+			break
+		}
+	}
+	if colorQuant < astcMinColorQuant {
+		return false
+	}
+	colors := b.ise(uint(colorStart), colorCount, astcQuants[colorQuant])
+	values := make([]int, colorCount)
+	for i, v := range colors {
+		values[i] = astcQuants[colorQuant].unquantizeColor(v)
+	}
+	endpoints := [4][2][4]int{}
+	for i := 0; i < partitions; i++ {
+		n := int(cems[i]>>2+1) * 2
+		if endpoints[i][0], endpoints[i][1], ok = decodeASTCEndpoints(cems[i], values[:n]); !ok {
+			return false
+		}
+		values = values[n:]
+	}
+
+	// Weights, stored bit-reversed from the top of the block.
+	encoded := b.reversed().ise(0, weightCount, weightQuant)
+	weights := make([]int, weightCount)
+	for i, v := range encoded {
+		weights[i] = weightQuant.unquantizeWeight(v)
+	}
+
+	seed := int(b.bits(13, 10))
+	small := bw*bh < 31
+	ds := (1024 + bw/2) / (bw - 1)
+	dt := (1024 + bh/2) / (bh - 1)
+	for t := 0; t < bh; t++ {
+		for s := 0; s < bw; s++ {
+			// Bilinearly infill the weights from the weight grid.
+			gs := (ds*s*(bm.gridW-1) + 32) >> 6
+			gt := (dt*t*(bm.gridH-1) + 32) >> 6
+			js, fs := gs>>4, gs&15
+			jt, ft := gt>>4, gt&15
+			w11 := (fs*ft + 8) >> 4
+			w10 := ft - w11
+			w01 := fs - w11
+			w00 := 16 - fs - ft + w11
+			texelWeight := [2]int{}
+			for p := 0; p < planes; p++ {
+				grid := func(x, y int) int {
+					if x >= bm.gridW || y >= bm.gridH {
+						return 0
+					}
+					return weights[(y*bm.gridW+x)*planes+p]
+				}
+				texelWeight[p] = (grid(js, jt)*w00 + grid(js+1, jt)*w01 +
+					grid(js, jt+1)*w10 + grid(js+1, jt+1)*w11 + 8) >> 4
+			}
+
+			partition := 0
+			if partitions > 1 {
+				partition = astcSelectPartition(seed, s, t, 0, partitions, small)
+			}
+			e := endpoints[partition]
+			for c := 0; c < 4; c++ {
+				w := texelWeight[0]
+				if c == ccs {
+					w = texelWeight[1]
+				}
+				c0, c1 := e[0][c]<<8|e[0][c], e[1][c]<<8|e[1][c]
+				if srgb {
+					c0, c1 = e[0][c]<<8|0x80, e[1][c]<<8|0x80
+				}
+				out[4*(t*bw+s)+c] = byte(((c0*(64-w) + c1*w + 32) >> 6) >> 8)
+			}
+		}
+	}
+	return true
+}
+
+// decodeASTCEndpoints returns the two RGBA endpoints encoded with the LDR
+// colour endpoint mode cem. HDR modes are not supported.
+func decodeASTCEndpoints(cem uint32, v []int) (e0, e1 [4]int, ok bool) {
+	rgba := func(r, g, b, a int) [4]int {
+		return [4]int{sint.Clamp(r, 0, 255), sint.Clamp(g, 0, 255), sint.Clamp(b, 0, 255), sint.Clamp(a, 0, 255)}
+	}
+	blueContract := func(r, g, b, a int) [4]int {
+		return rgba((r+b)>>1, (g+b)>>1, b, a)
+	}
+	bitTransferSigned := func(a, b int) (int, int) {
+		b = b>>1 | a&0x80
+		a = (a >> 1) & 0x3f
+		if a&0x20 != 0 {
+			a -= 0x40
+		}
+		return a, b
+	}
+	switch cem {
+	case 0: // Luminance, direct.
+		return rgba(v[0], v[0], v[0], 255), rgba(v[1], v[1], v[1], 255), true
+	case 1: // Luminance, base+offset.
+		l0 := v[0]>>2 | v[1]&0xc0
+		l1 := sint.Min(l0+v[1]&0x3f, 255)
+		return rgba(l0, l0, l0, 255), rgba(l1, l1, l1, 255), true
+	case 4: // Luminance+alpha, direct.
+		return rgba(v[0], v[0], v[0], v[2]), rgba(v[1], v[1], v[1], v[3]), true
+	case 5: // Luminance+alpha, base+offset.
+		v[1], v[0] = bitTransferSigned(v[1], v[0])
+		v[3], v[2] = bitTransferSigned(v[3], v[2])
+		l := v[0] + v[1]
+		return rgba(v[0], v[0], v[0], v[2]), rgba(l, l, l, v[2]+v[3]), true
+	case 6: // RGB, base+scale.
+		return rgba(v[0]*v[3]>>8, v[1]*v[3]>>8, v[2]*v[3]>>8, 255), rgba(v[0], v[1], v[2], 255), true
+	case 8: // RGB, direct.
+		if v[1]+v[3]+v[5] >= v[0]+v[2]+v[4] {
+			return rgba(v[0], v[2], v[4], 255), rgba(v[1], v[3], v[5], 255), true
+		}
+		return blueContract(v[1], v[3], v[5], 255), blueContract(v[0], v[2], v[4], 255), true
+	case 9: // RGB, base+offset.
+		v[1], v[0] = bitTransferSigned(v[1], v[0])
+		v[3], v[2] = bitTransferSigned(v[3], v[2])
+		v[5], v[4] = bitTransferSigned(v[5], v[4])
+		if v[1]+v[3]+v[5] >= 0 {
+			return rgba(v[0], v[2], v[4], 255), rgba(v[0]+v[1], v[2]+v[3], v[4]+v[5], 255), true
+		}
+		return blueContract(v[0]+v[1], v[2]+v[3], v[4]+v[5], 255), blueContract(v[0], v[2], v[4], 255), true
+	case 10: // RGB, base+scale plus two alpha.
+		return rgba(v[0]*v[3]>>8, v[1]*v[3]>>8, v[2]*v[3]>>8, v[4]), rgba(v[0], v[1], v[2], v[5]), true
+	case 12: // RGBA, direct.
+		if v[1]+v[3]+v[5] >= v[0]+v[2]+v[4] {
+			return rgba(v[0], v[2], v[4], v[6]), rgba(v[1], v[3], v[5], v[7]), true
+		}
+		return blueContract(v[1], v[3], v[5], v[7]), blueContract(v[0], v[2], v[4], v[6]), true
+	case 13: // RGBA, base+offset.
+		v[1], v[0] = bitTransferSigned(v[1], v[0])
+		v[3], v[2] = bitTransferSigned(v[3], v[2])
+		v[5], v[4] = bitTransferSigned(v[5], v[4])
+		v[7], v[6] = bitTransferSigned(v[7], v[6])
+		if v[1]+v[3]+v[5] >= 0 {
+			return rgba(v[0], v[2], v[4], v[6]), rgba(v[0]+v[1], v[2]+v[3], v[4]+v[5], v[6]+v[7]), true
+		}
+		return blueContract(v[0]+v[1], v[2]+v[3], v[4]+v[5], v[6]+v[7]), blueContract(v[0], v[2], v[4], v[6]), true
+	}
+	return e0, e1, false
+}
+
+// astcSelectPartition returns the partition of the texel at x, y, z using
+// the partition hash function of the specification.
+func astcSelectPartition(seed, x, y, z, partitions int, small bool) int {
+	if small {
+		x, y, z = x<<1, y<<1, z<<1
+	}
+	seed += (partitions - 1) * 1024
+
+	rnum := uint32(seed)
+	rnum ^= rnum >> 15
+	rnum -= rnum << 17
+	rnum += rnum << 7
+	rnum += rnum << 4
+	rnum ^= rnum >> 5
+	rnum += rnum << 16
+	rnum ^= rnum >> 7
+	rnum ^= rnum >> 3
+	rnum ^= rnum << 6
+	rnum ^= rnum >> 17
+
+	seeds := [12]uint32{}
+	for i := uint(0); i < 8; i++ {
+		seeds[i] = (rnum >> (4 * i)) & 15
+	}
+	seeds[8] = (rnum >> 18) & 15
+	seeds[9] = (rnum >> 22) & 15
+	seeds[10] = (rnum >> 26) & 15
+	seeds[11] = (rnum>>30 | rnum<<2) & 15
+	for i := range seeds {
+		seeds[i] *= seeds[i]
+	}
+
+	var sh1, sh2 uint
+	if seed&1 != 0 {
+		sh1, sh2 = 5, 5
+		if seed&2 != 0 {
+			sh1 = 4
+		}
+		if partitions == 3 {
+			sh2 = 6
+		}
+	} else {
+		sh1, sh2 = 5, 5
+		if partitions == 3 {
+			sh1 = 6
+		}
+		if seed&2 != 0 {
+			sh2 = 4
+		}
+	}
+	sh3 := sh2
+	if seed&0x10 != 0 {
+		sh3 = sh1
+	}
+	for i := 0; i < 8; i += 2 {
+		seeds[i] >>= sh1
+		seeds[i+1] >>= sh2
+	}
+	for i := 8; i < 12; i++ {
+		seeds[i] >>= sh3
+	}
+
+	ux, uy, uz := uint32(x), uint32(y), uint32(z)
+	a := (seeds[0]*ux + seeds[1]*uy + seeds[10]*uz + rnum>>14) & 0x3f
+	b := (seeds[2]*ux + seeds[3]*uy + seeds[11]*uz + rnum>>10) & 0x3f
+	c := (seeds[4]*ux + seeds[5]*uy + seeds[8]*uz + rnum>>6) & 0x3f
+	d := (seeds[6]*ux + seeds[7]*uy + seeds[9]*uz + rnum>>2) & 0x3f
+	if partitions < 4 {
+		d = 0
+	}
+	if partitions < 3 {
+		c = 0
+	}
+	switch {
+	case a >= b && a >= c && a >= d:
+		return 0
+	case b >= c && b >= d:
+		return 1
+	case c >= d:
+		return 2
+	default:
+		return 3
+	}
+}
@@ -0,0 +1,122 @@
+// Copyright (C) 2017 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package image_test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/google/gapid/core/image"
+)
+
+// astcBlock is a helper for building 128-bit ASTC blocks.
+type astcBlock [16]byte
+
+// set writes the count low bits of v at bit offset.
+func (b *astcBlock) set(offset, count uint, v uint64) *astcBlock {
+	for i := uint(0); i < count; i++ {
+		bit := offset + i
+		b[bit/8] &^= 1 << (bit % 8)
+		b[bit/8] |= byte((v>>i)&1) << (bit % 8)
+	}
+	return b
+}
+
+// setReversed writes the count low bits of v at bit offset, counting from
+// the top of the block down, as used for weights.
+func (b *astcBlock) setReversed(offset, count uint, v uint64) *astcBlock {
+	for i := uint(0); i < count; i++ {
+		b.set(127-offset-i, 1, v>>i)
+	}
+	return b
+}
+
+func fillRGBA(w, h int, c ...byte) []byte {
+	out := make([]byte, 0, w*h*4)
+	for i := 0; i < w*h; i++ {
+		out = append(out, c...)
+	}
+	return out
+}
+
+func TestASTCDecode(t *testing.T) {
+	magenta := fillRGBA(4, 4, 0xff, 0x00, 0xff, 0xff)
+
+	voidExtent := &astcBlock{}
+	voidExtent.set(0, 12, 0xdfc).set(12, 52, 1<<52-1)
+	voidExtent.set(64, 16, 0x1234).set(80, 16, 0x5678).set(96, 16, 0x9abc).set(112, 16, 0xdef0)
+
+	hdrVoidExtent := &astcBlock{}
+	hdrVoidExtent.set(0, 12, 0xffc).set(12, 52, 1<<52-1)
+
+	// 4x4 weight grid of 2-bit weights, single partition, RGB direct endpoints
+	// from black to white.
+	gradient := &astcBlock{}
+	gradient.set(0, 11, 0x42).set(13, 4, 8)
+	for i, v := range []uint64{0x00, 0xff, 0x00, 0xff, 0x00, 0xff} {
+		gradient.set(17+8*uint(i), 8, v)
+	}
+	for i := uint(0); i < 16; i++ {
+		gradient.setReversed(2*i, 2, uint64(i%4))
+	}
+	gradientRGBA := []byte{}
+	for i := 0; i < 16; i++ {
+		v := []byte{0x00, 0x54, 0xab, 0xff}[i%4]
+		gradientRGBA = append(gradientRGBA, v, v, v, 0xff)
+	}
+
+	// As gradient, but with a 5x4 weight grid which doesn't fit the block.
+	largeGrid := &astcBlock{}
+	copy(largeGrid[:], gradient[:])
+	largeGrid.set(0, 11, 0xc2)
+
+	// 4x4 weight grid of 0..2 trit weights all set to 2, with RGB direct
+	// endpoints that are swapped and blue-contracted.
+	trits := &astcBlock{}
+	trits.set(0, 11, 0x51).set(13, 4, 8)
+	for i, v := range []uint64{0xff, 0x00, 0xff, 0x00, 0xff, 0x00} {
+		trits.set(17+8*uint(i), 8, v)
+	}
+	for i := uint(0); i < 16; i += 5 {
+		// All trits 2: T = 0b01111110.
+		trits.setReversed(i/5*8, 8, 0x7e)
+	}
+
+	for _, test := range []struct {
+		name     string
+		format   *image.Format
+		w, h     uint32
+		data     []byte
+		expected []byte
+	}{
+		{"void-extent", image.NewASTC_RGBA_4x4(""), 4, 4, voidExtent[:], fillRGBA(4, 4, 0x12, 0x56, 0x9a, 0xde)},
+		{"void-extent clipped", image.NewASTC_RGBA_5x5(""), 3, 2, voidExtent[:], fillRGBA(3, 2, 0x12, 0x56, 0x9a, 0xde)},
+		{"hdr void-extent", image.NewASTC_RGBA_4x4(""), 4, 4, hdrVoidExtent[:], magenta},
+		{"reserved block mode", image.NewASTC_RGBA_4x4(""), 4, 4, make([]byte, 16), magenta},
+		{"gradient", image.NewASTC_RGBA_4x4(""), 4, 4, gradient[:], gradientRGBA},
+		{"weight grid too large", image.NewASTC_RGBA_4x4(""), 4, 4, largeGrid[:], magenta},
+		{"trits", image.NewASTC_RGBA_4x4(""), 4, 4, trits[:], fillRGBA(4, 4, 0xff, 0xff, 0xff, 0xff)},
+	} {
+		in := image.Image2D{Data: test.data, Width: test.w, Height: test.h, Format: test.format}
+		out, err := in.Convert(image.RGBA_U8_NORM)
+		if err != nil {
+			t.Errorf("%v: Convert returned error: %v", test.name, err)
+			continue
+		}
+		if !bytes.Equal(out.Data, test.expected) {
+			t.Errorf("%v: decoded to %v, expected %v", test.name, out.Data, test.expected)
+		}
+	}
+}
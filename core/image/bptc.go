@@ -0,0 +1,86 @@
+// Copyright (C) 2017 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package image
+
+import (
+	"github.com/google/gapid/core/math/sint"
+	"github.com/google/gapid/core/stream"
+)
+
+var (
+	BPTC_BC6H_RGB_UFLOAT        = NewBPTC_BC6H_RGB_UFLOAT("BPTC_BC6H_RGB_UFLOAT")
+	BPTC_BC6H_RGB_SFLOAT        = NewBPTC_BC6H_RGB_SFLOAT("BPTC_BC6H_RGB_SFLOAT")
+	BPTC_BC7_RGBA_U8_NORM       = NewBPTC_BC7_RGBA_U8_NORM("BPTC_BC7_RGBA_U8_NORM")
+	BPTC_BC7_SRGB_ALPHA_U8_NORM = NewBPTC_BC7_SRGB_ALPHA_U8_NORM("BPTC_BC7_SRGB_ALPHA_U8_NORM")
+)
+
+// NewBPTC_BC6H_RGB_UFLOAT returns a format representing the
+// COMPRESSED_RGB_BPTC_UNSIGNED_FLOAT (BC6H unsigned) block texture compression
+// format.
+func NewBPTC_BC6H_RGB_UFLOAT(name string) *Format {
+	return &Format{name, &Format_BptcBc6H{&FmtBPTC_BC6H{Signed: false}}}
+}
+
+// NewBPTC_BC6H_RGB_SFLOAT returns a format representing the
+// COMPRESSED_RGB_BPTC_SIGNED_FLOAT (BC6H signed) block texture compression
+// format.
+func NewBPTC_BC6H_RGB_SFLOAT(name string) *Format {
+	return &Format{name, &Format_BptcBc6H{&FmtBPTC_BC6H{Signed: true}}}
+}
+
+// NewBPTC_BC7_RGBA_U8_NORM returns a format representing the
+// COMPRESSED_RGBA_BPTC_UNORM (BC7) block texture compression format.
+func NewBPTC_BC7_RGBA_U8_NORM(name string) *Format {
+	return &Format{name, &Format_BptcBc7{&FmtBPTC_BC7{Srgb: false}}}
+}
+
+// NewBPTC_BC7_SRGB_ALPHA_U8_NORM returns a format representing the
+// COMPRESSED_SRGB_ALPHA_BPTC_UNORM (BC7 sRGB) block texture compression format.
+func NewBPTC_BC7_SRGB_ALPHA_U8_NORM(name string) *Format {
+	return &Format{name, &Format_BptcBc7{&FmtBPTC_BC7{Srgb: true}}}
+}
+
+func (f *FmtBPTC_BC6H) key() interface{} { return *f }
+func (*FmtBPTC_BC6H) size(w, h int) int {
+	return (sint.Max(sint.AlignUp(w, 4), 4) * sint.Max(sint.AlignUp(h, 4), 4))
+}
+func (*FmtBPTC_BC6H) check(d []byte, w, h int) error {
+	return checkSize(d, sint.Max(sint.AlignUp(w, 4), 4), sint.Max(sint.AlignUp(h, 4), 4), 8)
+}
+func (*FmtBPTC_BC6H) channels() []stream.Channel {
+	return []stream.Channel{stream.Channel_Red, stream.Channel_Green, stream.Channel_Blue}
+}
+
+func (f *FmtBPTC_BC7) key() interface{} { return *f }
+func (*FmtBPTC_BC7) size(w, h int) int {
+	return (sint.Max(sint.AlignUp(w, 4), 4) * sint.Max(sint.AlignUp(h, 4), 4))
+}
+func (*FmtBPTC_BC7) check(d []byte, w, h int) error {
+	return checkSize(d, sint.Max(sint.AlignUp(w, 4), 4), sint.Max(sint.AlignUp(h, 4), 4), 8)
+}
+func (*FmtBPTC_BC7) channels() []stream.Channel {
+	return []stream.Channel{stream.Channel_Red, stream.Channel_Green, stream.Channel_Blue, stream.Channel_Alpha}
+}
+
+func init() {
+	registerF32Decoder(BPTC_BC6H_RGB_UFLOAT, func(src []byte, width, height int) ([]byte, error) {
+		return decodeBC6H(src, width, height, false)
+	})
+	registerF32Decoder(BPTC_BC6H_RGB_SFLOAT, func(src []byte, width, height int) ([]byte, error) {
+		return decodeBC6H(src, width, height, true)
+	})
+	RegisterConverter(BPTC_BC7_RGBA_U8_NORM, RGBA_U8_NORM, decodeBC7)
+	RegisterConverter(BPTC_BC7_SRGB_ALPHA_U8_NORM, RGBA_U8_NORM, decodeBC7)
+}
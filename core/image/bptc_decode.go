@@ -0,0 +1,557 @@
+// Copyright (C) 2017 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package image
+
+import (
+	"bytes"
+
+	"github.com/google/gapid/core/data/endian"
+	"github.com/google/gapid/core/math/f16"
+	"github.com/google/gapid/core/os/device"
+)
+
+// This file implements the BC6H and BC7 decoders as described by the
+// ARB_texture_compression_bptc extension. Blocks using reserved modes decode
+// to transparent black (BC7) or black (BC6H), as required by the extension.
+
+// decodeBC7 decodes the BC7 encoded image data to RGBA_U8_NORM.
+func decodeBC7(src []byte, width, height int) ([]byte, error) {
+	dst := make([]byte, width*height*4)
+	texels := make([]byte, 16*4)
+	r := endian.Reader(bytes.NewReader(src), device.LittleEndian)
+	for y := 0; y < height; y += 4 {
+		for x := 0; x < width; x += 4 {
+			blk := bptcBlock{lo: r.Uint64(), hi: r.Uint64()}
+			blk.decodeBC7(texels)
+			for dy := 0; dy < 4 && y+dy < height; dy++ {
+				for dx := 0; dx < 4 && x+dx < width; dx++ {
+					copy(dst[4*((y+dy)*width+x+dx):], texels[4*(dy*4+dx):4*(dy*4+dx+1)])
+				}
+			}
+		}
+	}
+	return dst, r.Error()
+}
+
+// decodeBC6H decodes the BC6H encoded image data to RGBA_F32.
+func decodeBC6H(src []byte, width, height int, signed bool) ([]byte, error) {
+	texels := make([]rgbaF32, width*height)
+	block := [16][3]float32{}
+	r := endian.Reader(bytes.NewReader(src), device.LittleEndian)
+	for y := 0; y < height; y += 4 {
+		for x := 0; x < width; x += 4 {
+			blk := bptcBlock{lo: r.Uint64(), hi: r.Uint64()}
+			blk.decodeBC6H(signed, &block)
+			for dy := 0; dy < 4 && y+dy < height; dy++ {
+				for dx := 0; dx < 4 && x+dx < width; dx++ {
+					c := block[dy*4+dx]
+					texels[(y+dy)*width+x+dx] = rgbaF32{c[0], c[1], c[2], 1}
+				}
+			}
+		}
+	}
+
+	out := make([]byte, width*height*4*4)
+	w := endian.Writer(bytes.NewBuffer(out[:0]), device.LittleEndian)
+	for _, t := range texels {
+		w.Float32(t.r)
+		w.Float32(t.g)
+		w.Float32(t.b)
+		w.Float32(t.a)
+	}
+	return out, r.Error()
+}
+
+// bptcBlock is a single 128-bit BC6H or BC7 block, read from the least
+// significant bit upwards.
+type bptcBlock struct {
+	lo, hi uint64
+	pos    uint
+}
+
+// read returns the next count bits of the block.
+func (b *bptcBlock) read(count uint) int {
+	var v uint64
+	if b.pos < 64 {
+		v = b.lo>>b.pos | b.hi<<(64-b.pos)
+	} else {
+		v = b.hi >> (b.pos - 64)
+	}
+	b.pos += count
+	return int(v & (1<<count - 1))
+}
+
+// index reads the index of texel i, which is one bit shorter if the texel
+// is the anchor of its subset.
+func (b *bptcBlock) index(i int, bits uint, anchors ...int) int {
+	for _, a := range anchors {
+		if i == a {
+			return b.read(bits - 1)
+		}
+	}
+	return b.read(bits)
+}
+
+// bptcInterpolate interpolates between e0 and e1 using the 6-bit weight w.
+func bptcInterpolate(e0, e1, w int) int {
+	return ((64-w)*e0 + w*e1 + 32) >> 6
+}
+
+var bptcWeights = [][]int{
+	2: {0, 21, 43, 64},
+	3: {0, 9, 18, 27, 37, 46, 55, 64},
+	4: {0, 4, 9, 13, 17, 21, 26, 30, 34, 38, 43, 47, 51, 55, 60, 64},
+}
+
+// bptcPartitions2 holds the two subset partitions, one bit per texel.
+var bptcPartitions2 = [64]uint16{
+	0xcccc, 0x8888, 0xeeee, 0xecc8, 0xc880, 0xfeec, 0xfec8, 0xec80,
+	0xc800, 0xffec, 0xfe80, 0xe800, 0xffe8, 0xff00, 0xfff0, 0xf000,
+	0xf710, 0x008e, 0x7100, 0x08ce, 0x008c, 0x7310, 0x3100, 0x8cce,
+	0x088c, 0x3110, 0x6666, 0x366c, 0x17e8, 0x0ff0, 0x718e, 0x399c,
+	0xaaaa, 0xf0f0, 0x5a5a, 0x33cc, 0x3c3c, 0x55aa, 0x9696, 0xa55a,
+	0x73ce, 0x13c8, 0x324c, 0x3bdc, 0x6996, 0xc33c, 0x9966, 0x0660,
+	0x0272, 0x04e4, 0x4e40, 0x2720, 0xc936, 0x936c, 0x39c6, 0x639c,
+	0x9336, 0x9cc6, 0x817e, 0xe718, 0xccf0, 0x0fcc, 0x7744, 0xee22,
+}
+
+// bptcPartitions3 holds the three subset partitions, two bits per texel.
+var bptcPartitions3 = [64]uint32{
+	0xaa685050, 0x6a5a5040, 0x5a5a4200, 0x5450a0a8, 0xa5a50000, 0xa0a05050, 0x5555a0a0, 0x5a5a5050,
+	0xaa550000, 0xaa555500, 0xaaaa5500, 0x90909090, 0x94949494, 0xa4a4a4a4, 0xa9a59450, 0x2a0a4250,
+	0xa5945040, 0x0a425054, 0xa5a5a500, 0x55a0a0a0, 0xa8a85454, 0x6a6a4040, 0xa4a45000, 0x1a1a0500,
+	0x0050a4a4, 0xaaa59090, 0x14696914, 0x69691400, 0xa08585a0, 0xaa821414, 0x50a4a450, 0x6a5a0200,
+	0xa9a58000, 0x5090a0a8, 0xa8a09050, 0x24242424, 0x00aa5500, 0x24924924, 0x24499224, 0x50a50a50,
+	0x500aa550, 0xaaaa4444, 0x66660000, 0xa5a0a5a0, 0x50a050a0, 0x69286928, 0x44aaaa44, 0x66666600,
+	0xaa444444, 0x54a854a8, 0x95809580, 0x96969600, 0xa85454a8, 0x80959580, 0xaa141414, 0x96960000,
+	0xaaaa1414, 0xa05050a0, 0xa0a5a5a0, 0x96000000, 0x40804080, 0xa9a8a9a8, 0xaaaaaa44, 0x2a4a5254,
+}
+
+// bptcAnchors2 holds the anchor texel of the second subset of the two subset
+// partitions.
+var bptcAnchors2 = [64]int{
+	15, 15, 15, 15, 15, 15, 15, 15, 15, 15, 15, 15, 15, 15, 15, 15,
+	15, 2, 8, 2, 2, 8, 8, 15, 2, 8, 2, 2, 8, 8, 2, 2,
+	15, 15, 6, 8, 2, 8, 15, 15, 2, 8, 2, 2, 2, 15, 15, 6,
+	6, 2, 6, 8, 15, 15, 2, 2, 15, 15, 15, 15, 15, 2, 2, 15,
+}
+
+// bptcAnchors3 holds the anchor texels of the second and third subsets of the
+// three subset partitions.
+var bptcAnchors3 = [2][64]int{
+	{
+		3, 3, 15, 15, 8, 3, 15, 15, 8, 8, 6, 6, 6, 5, 3, 3,
+		3, 3, 8, 15, 3, 3, 6, 10, 5, 8, 8, 6, 8, 5, 15, 15,
+		8, 15, 3, 5, 6, 10, 8, 15, 15, 3, 15, 5, 15, 15, 15, 15,
+		3, 15, 5, 5, 5, 8, 5, 10, 5, 10, 8, 13, 15, 12, 3, 3,
+	}, {
+		15, 8, 8, 3, 15, 15, 3, 8, 15, 15, 15, 15, 15, 15, 15, 8,
+		15, 8, 15, 3, 15, 8, 15, 8, 3, 15, 6, 10, 15, 15, 10, 8,
+		15, 3, 15, 10, 10, 8, 9, 10, 6, 15, 8, 15, 3, 6, 6, 8,
+		15, 3, 15, 15, 15, 15, 15, 15, 15, 15, 15, 15, 3, 15, 15, 8,
+	},
+}
+
+// bc7Mode describes the layout of one of the eight BC7 block modes.
+type bc7Mode struct {
+	subsets       int
+	partitionBits uint
+	rotationBits  uint
+	selectionBits uint
+	colorBits     uint
+	alphaBits     uint
+	endpointPBits bool // One p-bit per endpoint.
+	sharedPBits   bool // One p-bit per subset.
+	indexBits     uint
+	secondaryBits uint
+}
+
+var bc7Modes = [8]bc7Mode{
+	{subsets: 3, partitionBits: 4, colorBits: 4, endpointPBits: true, indexBits: 3},
+	{subsets: 2, partitionBits: 6, colorBits: 6, sharedPBits: true, indexBits: 3},
+	{subsets: 3, partitionBits: 6, colorBits: 5, indexBits: 2},
+	{subsets: 2, partitionBits: 6, colorBits: 7, endpointPBits: true, indexBits: 2},
+	{subsets: 1, rotationBits: 2, selectionBits: 1, colorBits: 5, alphaBits: 6, indexBits: 2, secondaryBits: 3},
+	{subsets: 1, rotationBits: 2, colorBits: 7, alphaBits: 8, indexBits: 2, secondaryBits: 2},
+	{subsets: 1, colorBits: 7, alphaBits: 7, endpointPBits: true, indexBits: 4},
+	{subsets: 2, partitionBits: 6, colorBits: 5, alphaBits: 5, endpointPBits: true, indexBits: 2},
+}
+
+// decodeBC7 decodes the block to 16 RGBA_U8_NORM texels.
+func (b bptcBlock) decodeBC7(out []byte) {
+	mode := 0
+	for mode < len(bc7Modes) && b.read(1) == 0 {
+		mode++
+	}
+	if mode == len(bc7Modes) {
+		for i := range out {
+			out[i] = 0
+		}
+		return
+	}
+	m := bc7Modes[mode]
+
+	partition := b.read(m.partitionBits)
+	rotation := b.read(m.rotationBits)
+	selection := b.read(m.selectionBits)
+
+	// Endpoints are stored channel by channel, then the p-bits.
+	endpoints := [6][4]int{}
+	count := m.subsets * 2
+	for c := 0; c < 3; c++ {
+		for e := 0; e < count; e++ {
+			endpoints[e][c] = b.read(m.colorBits)
+		}
+	}
+	for e := 0; e < count; e++ {
+		endpoints[e][3] = b.read(m.alphaBits)
+	}
+	colorBits, alphaBits := m.colorBits, m.alphaBits
+	pbits := [6]int{}
+	switch {
+	case m.endpointPBits:
+		for e := 0; e < count; e++ {
+			pbits[e] = b.read(1)
+		}
+	case m.sharedPBits:
+		for s := 0; s < m.subsets; s++ {
+			pbits[s*2] = b.read(1)
+			pbits[s*2+1] = pbits[s*2]
+		}
+	}
+	if m.endpointPBits || m.sharedPBits {
+		colorBits++
+		if alphaBits > 0 {
+			alphaBits++
+		}
+	}
+	expand := func(v int, bits uint) int {
+		v <<= 8 - bits
+		return v | v>>bits
+	}
+	for e := 0; e < count; e++ {
+		for c := 0; c < 4; c++ {
+			v, bits := endpoints[e][c], colorBits
+			if c == 3 {
+				if m.alphaBits == 0 {
+					endpoints[e][c] = 255
+					continue
+				}
+				bits = alphaBits
+			}
+			if m.endpointPBits || m.sharedPBits {
+				v = v<<1 | pbits[e]
+			}
+			endpoints[e][c] = expand(v, bits)
+		}
+	}
+
+	subsets, anchors := [16]int{}, []int{0}
+	switch m.subsets {
+	case 2:
+		for i := range subsets {
+			subsets[i] = int(bptcPartitions2[partition]>>uint(i)) & 1
+		}
+		anchors = append(anchors, bptcAnchors2[partition])
+	case 3:
+		for i := range subsets {
+			subsets[i] = int(bptcPartitions3[partition]>>uint(i*2)) & 3
+		}
+		anchors = append(anchors, bptcAnchors3[0][partition], bptcAnchors3[1][partition])
+	}
+
+	indices, secondary := [16]int{}, [16]int{}
+	for i := range indices {
+		indices[i] = b.index(i, m.indexBits, anchors...)
+	}
+	if m.secondaryBits > 0 {
+		for i := range secondary {
+			secondary[i] = b.index(i, m.secondaryBits, 0)
+		}
+	}
+
+	for i := 0; i < 16; i++ {
+		e0, e1 := endpoints[subsets[i]*2], endpoints[subsets[i]*2+1]
+		cw := bptcWeights[m.indexBits][indices[i]]
+		aw := cw
+		if m.secondaryBits > 0 {
+			aw = bptcWeights[m.secondaryBits][secondary[i]]
+			if selection == 1 {
+				cw, aw = aw, cw
+			}
+		}
+		t := [4]int{
+			bptcInterpolate(e0[0], e1[0], cw),
+			bptcInterpolate(e0[1], e1[1], cw),
+			bptcInterpolate(e0[2], e1[2], cw),
+			bptcInterpolate(e0[3], e1[3], aw),
+		}
+		if rotation > 0 {
+			t[rotation-1], t[3] = t[3], t[rotation-1]
+		}
+		for c := 0; c < 4; c++ {
+			out[i*4+c] = byte(t[c])
+		}
+	}
+}
+
+// Indices of the BC6H endpoint components, and of the partition field, used
+// by bc6hField.
+const (
+	bc6hR0 = iota
+	bc6hG0
+	bc6hB0
+	bc6hR1
+	bc6hG1
+	bc6hB1
+	bc6hR2
+	bc6hG2
+	bc6hB2
+	bc6hR3
+	bc6hG3
+	bc6hB3
+	bc6hD
+)
+
+// bc6hField is a run of count bits in a BC6H block, stored in bits
+// [lsb, lsb+count) of the value v.
+type bc6hField struct {
+	v, lsb, count uint
+}
+
+// bc6hMode describes the layout of one of the fourteen BC6H block modes.
+type bc6hMode struct {
+	regions      int
+	transformed  bool
+	endpointBits uint
+	deltaBits    [3]uint
+	fields       []bc6hField
+}
+
+// bc6hReversed returns the single bit fields for bits [lsb, lsb+count) of v
+// that are stored most significant bit first.
+func bc6hReversed(v, lsb, count uint) []bc6hField {
+	out := make([]bc6hField, count)
+	for i := range out {
+		out[i] = bc6hField{v, lsb + count - 1 - uint(i), 1}
+	}
+	return out
+}
+
+func bc6hFields(groups ...[]bc6hField) []bc6hField {
+	out := []bc6hField{}
+	for _, g := range groups {
+		out = append(out, g...)
+	}
+	return out
+}
+
+// bc6hModes maps the 5-bit mode value to the mode layout. Values with the low
+// two bits clear or equal to one only use two mode bits.
+var bc6hModes = map[int]bc6hMode{
+	0x00: {2, true, 10, [3]uint{5, 5, 5}, []bc6hField{
+		{bc6hG2, 4, 1}, {bc6hB2, 4, 1}, {bc6hB3, 4, 1}, {bc6hR0, 0, 10}, {bc6hG0, 0, 10}, {bc6hB0, 0, 10},
+		{bc6hR1, 0, 5}, {bc6hG3, 4, 1}, {bc6hG2, 0, 4}, {bc6hG1, 0, 5}, {bc6hB3, 0, 1}, {bc6hG3, 0, 4},
+		{bc6hB1, 0, 5}, {bc6hB3, 1, 1}, {bc6hB2, 0, 4}, {bc6hR2, 0, 5}, {bc6hB3, 2, 1}, {bc6hR3, 0, 5},
+		{bc6hB3, 3, 1}, {bc6hD, 0, 5},
+	}},
+	0x01: {2, true, 7, [3]uint{6, 6, 6}, []bc6hField{
+		{bc6hG2, 5, 1}, {bc6hG3, 4, 1}, {bc6hG3, 5, 1}, {bc6hR0, 0, 7}, {bc6hB3, 0, 1}, {bc6hB3, 1, 1},
+		{bc6hB2, 4, 1}, {bc6hG0, 0, 7}, {bc6hB2, 5, 1}, {bc6hB3, 2, 1}, {bc6hG2, 4, 1}, {bc6hB0, 0, 7},
+		{bc6hB3, 3, 1}, {bc6hB3, 5, 1}, {bc6hB3, 4, 1}, {bc6hR1, 0, 6}, {bc6hG2, 0, 4}, {bc6hG1, 0, 6},
+		{bc6hG3, 0, 4}, {bc6hB1, 0, 6}, {bc6hB2, 0, 4}, {bc6hR2, 0, 6}, {bc6hR3, 0, 6}, {bc6hD, 0, 5},
+	}},
+	0x02: {2, true, 11, [3]uint{5, 4, 4}, []bc6hField{
+		{bc6hR0, 0, 10}, {bc6hG0, 0, 10}, {bc6hB0, 0, 10}, {bc6hR1, 0, 5}, {bc6hR0, 10, 1}, {bc6hG2, 0, 4},
+		{bc6hG1, 0, 4}, {bc6hG0, 10, 1}, {bc6hB3, 0, 1}, {bc6hG3, 0, 4}, {bc6hB1, 0, 4}, {bc6hB0, 10, 1},
+		{bc6hB3, 1, 1}, {bc6hB2, 0, 4}, {bc6hR2, 0, 5}, {bc6hB3, 2, 1}, {bc6hR3, 0, 5}, {bc6hB3, 3, 1},
+		{bc6hD, 0, 5},
+	}},
+	0x06: {2, true, 11, [3]uint{4, 5, 4}, []bc6hField{
+		{bc6hR0, 0, 10}, {bc6hG0, 0, 10}, {bc6hB0, 0, 10}, {bc6hR1, 0, 4}, {bc6hR0, 10, 1}, {bc6hG3, 4, 1},
+		{bc6hG2, 0, 4}, {bc6hG1, 0, 5}, {bc6hG0, 10, 1}, {bc6hG3, 0, 4}, {bc6hB1, 0, 4}, {bc6hB0, 10, 1},
+		{bc6hB3, 1, 1}, {bc6hB2, 0, 4}, {bc6hR2, 0, 4}, {bc6hB3, 0, 1}, {bc6hB3, 2, 1}, {bc6hR3, 0, 4},
+		{bc6hG2, 4, 1}, {bc6hB3, 3, 1}, {bc6hD, 0, 5},
+	}},
+	0x0a: {2, true, 11, [3]uint{4, 4, 5}, []bc6hField{
+		{bc6hR0, 0, 10}, {bc6hG0, 0, 10}, {bc6hB0, 0, 10}, {bc6hR1, 0, 4}, {bc6hR0, 10, 1}, {bc6hB2, 4, 1},
+		{bc6hG2, 0, 4}, {bc6hG1, 0, 4}, {bc6hG0, 10, 1}, {bc6hB3, 0, 1}, {bc6hG3, 0, 4}, {bc6hB1, 0, 5},
+		{bc6hB0, 10, 1}, {bc6hB2, 0, 4}, {bc6hR2, 0, 4}, {bc6hB3, 1, 1}, {bc6hB3, 2, 1}, {bc6hR3, 0, 4},
+		{bc6hB3, 4, 1}, {bc6hB3, 3, 1}, {bc6hD, 0, 5},
+	}},
+	0x0e: {2, true, 9, [3]uint{5, 5, 5}, []bc6hField{
+		{bc6hR0, 0, 9}, {bc6hB2, 4, 1}, {bc6hG0, 0, 9}, {bc6hG2, 4, 1}, {bc6hB0, 0, 9}, {bc6hB3, 4, 1},
+		{bc6hR1, 0, 5}, {bc6hG3, 4, 1}, {bc6hG2, 0, 4}, {bc6hG1, 0, 5}, {bc6hB3, 0, 1}, {bc6hG3, 0, 4},
+		{bc6hB1, 0, 5}, {bc6hB3, 1, 1}, {bc6hB2, 0, 4}, {bc6hR2, 0, 5}, {bc6hB3, 2, 1}, {bc6hR3, 0, 5},
+		{bc6hB3, 3, 1}, {bc6hD, 0, 5},
+	}},
+	0x12: {2, true, 8, [3]uint{6, 5, 5}, []bc6hField{
+		{bc6hR0, 0, 8}, {bc6hG3, 4, 1}, {bc6hB2, 4, 1}, {bc6hG0, 0, 8}, {bc6hB3, 2, 1}, {bc6hG2, 4, 1},
+		{bc6hB0, 0, 8}, {bc6hB3, 3, 1}, {bc6hB3, 4, 1}, {bc6hR1, 0, 6}, {bc6hG2, 0, 4}, {bc6hG1, 0, 5},
+		{bc6hB3, 0, 1}, {bc6hG3, 0, 4}, {bc6hB1, 0, 5}, {bc6hB3, 1, 1}, {bc6hB2, 0, 4}, {bc6hR2, 0, 6},
+		{bc6hR3, 0, 6}, {bc6hD, 0, 5},
+	}},
+	0x16: {2, true, 8, [3]uint{5, 6, 5}, []bc6hField{
+		{bc6hR0, 0, 8}, {bc6hB3, 0, 1}, {bc6hB2, 4, 1}, {bc6hG0, 0, 8}, {bc6hG2, 5, 1}, {bc6hG2, 4, 1},
+		{bc6hB0, 0, 8}, {bc6hG3, 5, 1}, {bc6hB3, 4, 1}, {bc6hR1, 0, 5}, {bc6hG3, 4, 1}, {bc6hG2, 0, 4},
+		{bc6hG1, 0, 6}, {bc6hG3, 0, 4}, {bc6hB1, 0, 5}, {bc6hB3, 1, 1}, {bc6hB2, 0, 4}, {bc6hR2, 0, 5},
+		{bc6hB3, 2, 1}, {bc6hR3, 0, 5}, {bc6hB3, 3, 1}, {bc6hD, 0, 5},
+	}},
+	0x1a: {2, true, 8, [3]uint{5, 5, 6}, []bc6hField{
+		{bc6hR0, 0, 8}, {bc6hB3, 1, 1}, {bc6hB2, 4, 1}, {bc6hG0, 0, 8}, {bc6hB2, 5, 1}, {bc6hG2, 4, 1},
+		{bc6hB0, 0, 8}, {bc6hB3, 5, 1}, {bc6hB3, 4, 1}, {bc6hR1, 0, 5}, {bc6hG3, 4, 1}, {bc6hG2, 0, 4},
+		{bc6hG1, 0, 5}, {bc6hB3, 0, 1}, {bc6hG3, 0, 4}, {bc6hB1, 0, 6}, {bc6hB2, 0, 4}, {bc6hR2, 0, 5},
+		{bc6hB3, 2, 1}, {bc6hR3, 0, 5}, {bc6hB3, 3, 1}, {bc6hD, 0, 5},
+	}},
+	0x1e: {2, false, 6, [3]uint{6, 6, 6}, []bc6hField{
+		{bc6hR0, 0, 6}, {bc6hG3, 4, 1}, {bc6hB3, 0, 1}, {bc6hB3, 1, 1}, {bc6hB2, 4, 1}, {bc6hG0, 0, 6},
+		{bc6hG2, 5, 1}, {bc6hB2, 5, 1}, {bc6hB3, 2, 1}, {bc6hG2, 4, 1}, {bc6hB0, 0, 6}, {bc6hG3, 5, 1},
+		{bc6hB3, 3, 1}, {bc6hB3, 5, 1}, {bc6hB3, 4, 1}, {bc6hR1, 0, 6}, {bc6hG2, 0, 4}, {bc6hG1, 0, 6},
+		{bc6hG3, 0, 4}, {bc6hB1, 0, 6}, {bc6hB2, 0, 4}, {bc6hR2, 0, 6}, {bc6hR3, 0, 6}, {bc6hD, 0, 5},
+	}},
+	0x03: {1, false, 10, [3]uint{10, 10, 10}, []bc6hField{
+		{bc6hR0, 0, 10}, {bc6hG0, 0, 10}, {bc6hB0, 0, 10}, {bc6hR1, 0, 10}, {bc6hG1, 0, 10}, {bc6hB1, 0, 10},
+	}},
+	0x07: {1, true, 11, [3]uint{9, 9, 9}, []bc6hField{
+		{bc6hR0, 0, 10}, {bc6hG0, 0, 10}, {bc6hB0, 0, 10}, {bc6hR1, 0, 9}, {bc6hR0, 10, 1}, {bc6hG1, 0, 9},
+		{bc6hG0, 10, 1}, {bc6hB1, 0, 9}, {bc6hB0, 10, 1},
+	}},
+	0x0b: {1, true, 12, [3]uint{8, 8, 8}, bc6hFields(
+		[]bc6hField{{bc6hR0, 0, 10}, {bc6hG0, 0, 10}, {bc6hB0, 0, 10}, {bc6hR1, 0, 8}},
+		bc6hReversed(bc6hR0, 10, 2),
+		[]bc6hField{{bc6hG1, 0, 8}},
+		bc6hReversed(bc6hG0, 10, 2),
+		[]bc6hField{{bc6hB1, 0, 8}},
+		bc6hReversed(bc6hB0, 10, 2),
+	)},
+	0x0f: {1, true, 16, [3]uint{4, 4, 4}, bc6hFields(
+		[]bc6hField{{bc6hR0, 0, 10}, {bc6hG0, 0, 10}, {bc6hB0, 0, 10}, {bc6hR1, 0, 4}},
+		bc6hReversed(bc6hR0, 10, 6),
+		[]bc6hField{{bc6hG1, 0, 4}},
+		bc6hReversed(bc6hG0, 10, 6),
+		[]bc6hField{{bc6hB1, 0, 4}},
+		bc6hReversed(bc6hB0, 10, 6),
+	)},
+}
+
+func signExtend(v int, bits uint) int {
+	shift := 32 - bits
+	return int(int32(v<<shift) >> shift)
+}
+
+// bc6hUnquantize expands the endpoint component v of the given precision to
+// 16 bits.
+func bc6hUnquantize(v int, bits uint, signed bool) int {
+	if !signed {
+		switch {
+		case bits >= 15:
+			return v
+		case v == 0:
+			return 0
+		case v == 1<<bits-1:
+			return 0xffff
+		default:
+			return ((v << 16) + 0x8000) >> bits
+		}
+	}
+	if bits >= 16 {
+		return v
+	}
+	neg := v < 0
+	if neg {
+		v = -v
+	}
+	switch {
+	case v == 0:
+	case v >= 1<<(bits-1)-1:
+		v = 0x7fff
+	default:
+		v = ((v << 15) + 0x4000) >> (bits - 1)
+	}
+	if neg {
+		return -v
+	}
+	return v
+}
+
+// bc6hHalf scales the interpolated value v to a half float.
+func bc6hHalf(v int, signed bool) f16.Number {
+	switch {
+	case !signed:
+		return f16.Number((v * 31) >> 6)
+	case v < 0:
+		return f16.Number(0x8000 | ((-v * 31) >> 5))
+	default:
+		return f16.Number((v * 31) >> 5)
+	}
+}
+
+// decodeBC6H decodes the block to 16 RGB texels.
+func (b bptcBlock) decodeBC6H(signed bool, out *[16][3]float32) {
+	modeBits := b.read(2)
+	if modeBits > 1 {
+		modeBits |= b.read(3) << 2
+	}
+	m, ok := bc6hModes[modeBits]
+	if !ok {
+		*out = [16][3]float32{}
+		return
+	}
+
+	values := [bc6hD + 1]int{}
+	for _, f := range m.fields {
+		values[f.v] |= b.read(f.count) << f.lsb
+	}
+
+	endpoints := [4][3]int{}
+	for e := range endpoints[:m.regions*2] {
+		for c := 0; c < 3; c++ {
+			v := values[e*3+c]
+			switch {
+			case e == 0:
+				if signed {
+					v = signExtend(v, m.endpointBits)
+				}
+			case m.transformed:
+				v = signExtend(v, m.deltaBits[c])
+				v = (values[c] + v) & (1<<m.endpointBits - 1)
+				if signed {
+					v = signExtend(v, m.endpointBits)
+				}
+			case signed:
+				v = signExtend(v, m.deltaBits[c])
+			}
+			endpoints[e][c] = bc6hUnquantize(v, m.endpointBits, signed)
+		}
+	}
+
+	indexBits, subsets, anchors := uint(4), [16]int{}, []int{0}
+	if m.regions == 2 {
+		partition := values[bc6hD]
+		indexBits = 3
+		for i := range subsets {
+			subsets[i] = int(bptcPartitions2[partition]>>uint(i)) & 1
+		}
+		anchors = append(anchors, bptcAnchors2[partition])
+	}
+
+	for i := 0; i < 16; i++ {
+		w := bptcWeights[indexBits][b.index(i, indexBits, anchors...)]
+		e0, e1 := endpoints[subsets[i]*2], endpoints[subsets[i]*2+1]
+		for c := 0; c < 3; c++ {
+			out[i][c] = bc6hHalf(bptcInterpolate(e0[c], e1[c], w), signed).Float32()
+		}
+	}
+}
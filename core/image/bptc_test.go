@@ -0,0 +1,158 @@
+// Copyright (C) 2017 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package image_test
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math"
+	"testing"
+
+	"github.com/google/gapid/core/image"
+)
+
+// bptcWriter is a helper for building 128-bit BPTC blocks, field by field.
+type bptcWriter struct {
+	astcBlock
+	pos uint
+}
+
+func (w *bptcWriter) put(count uint, values ...uint64) *bptcWriter {
+	for _, v := range values {
+		w.set(w.pos, count, v)
+		w.pos += count
+	}
+	return w
+}
+
+// indices writes 16 indices of the given size. The anchor indices, including
+// the first, are one bit shorter.
+func (w *bptcWriter) indices(bits uint, index func(i int) uint64, anchors ...int) *bptcWriter {
+	for i := 0; i < 16; i++ {
+		count := bits
+		for _, a := range append(anchors, 0) {
+			if i == a {
+				count--
+			}
+		}
+		w.put(count, index(i))
+	}
+	return w
+}
+
+func TestBC7Decode(t *testing.T) {
+	weights := []byte{0, 4, 9, 13, 17, 21, 26, 30, 34, 38, 43, 47, 51, 55, 60, 64}
+
+	// Mode 6: RGB from black to white, alpha from 254 to 255, using the
+	// p-bits for the low bits and the 4-bit index i for texel i.
+	mode6 := &bptcWriter{}
+	mode6.put(7, 0x40).put(7, 0, 127, 0, 127, 0, 127, 127, 127).put(1, 0, 1)
+	mode6.indices(4, func(i int) uint64 { return uint64(i) })
+	mode6RGBA := []byte{}
+	for _, w := range weights {
+		v := byte((int(w)*255 + 32) >> 6)
+		a := byte((254*(64-int(w)) + 255*int(w) + 32) >> 6)
+		mode6RGBA = append(mode6RGBA, v, v, v, a)
+	}
+
+	// Mode 4: opaque white with zero alpha, rotated so that red and alpha are
+	// swapped.
+	mode4 := &bptcWriter{}
+	mode4.put(5, 0x10).put(2, 1).put(1, 0).put(5, 31, 31, 31, 31, 31, 31).put(6, 0, 0)
+	mode4.indices(2, func(int) uint64 { return 0 }).indices(3, func(int) uint64 { return 0 })
+
+	// Mode 1: two subsets split into the top and bottom halves by partition
+	// 13, with red and blue endpoints. The shared p-bits are set, so the zero
+	// channels expand to 2.
+	mode1 := &bptcWriter{}
+	mode1.put(2, 0x2).put(6, 13)
+	mode1.put(6, 63, 63, 0, 0).put(6, 0, 0, 0, 0).put(6, 0, 0, 63, 63).put(1, 1, 1)
+	mode1.indices(3, func(int) uint64 { return 0 }, 15)
+	mode1RGBA := append(fillRGBA(4, 2, 0xff, 0x02, 0x02, 0xff), fillRGBA(4, 2, 0x02, 0x02, 0xff, 0xff)...)
+
+	for _, test := range []struct {
+		name     string
+		w, h     uint32
+		data     []byte
+		expected []byte
+	}{
+		{"mode 6", 4, 4, mode6.astcBlock[:], mode6RGBA},
+		{"mode 4 rotated", 4, 4, mode4.astcBlock[:], fillRGBA(4, 4, 0x00, 0xff, 0xff, 0xff)},
+		{"mode 1 partitioned", 4, 4, mode1.astcBlock[:], mode1RGBA},
+		{"mode 1 clipped", 2, 3, mode1.astcBlock[:], append(fillRGBA(2, 2, 0xff, 0x02, 0x02, 0xff), fillRGBA(2, 1, 0x02, 0x02, 0xff, 0xff)...)},
+		{"reserved mode", 4, 4, make([]byte, 16), make([]byte, 64)},
+	} {
+		in := image.Image2D{Data: test.data, Width: test.w, Height: test.h, Format: image.BPTC_BC7_RGBA_U8_NORM}
+		out, err := in.Convert(image.RGBA_U8_NORM)
+		if err != nil {
+			t.Errorf("%v: Convert returned error: %v", test.name, err)
+			continue
+		}
+		if !bytes.Equal(out.Data, test.expected) {
+			t.Errorf("%v: decoded to %v, expected %v", test.name, out.Data, test.expected)
+		}
+	}
+}
+
+func TestBC6HDecode(t *testing.T) {
+	// Mode 11 (untransformed 10-bit endpoints) from 0 to the largest value,
+	// using the 4-bit index i for texel i.
+	mode11 := &bptcWriter{}
+	mode11.put(5, 0x03).put(10, 0, 0, 0, 1023, 1023, 1023)
+	mode11.indices(4, func(i int) uint64 { return uint64(i) })
+
+	// Mode 14 (16-bit endpoint with 4-bit deltas) with a red of 0.5. The
+	// high bits of the endpoint are stored in reverse order.
+	red := uint64(29597)
+	reversed := uint64(0)
+	for i := uint(0); i < 6; i++ {
+		reversed |= ((red >> (10 + i)) & 1) << (5 - i)
+	}
+	mode14 := &bptcWriter{}
+	mode14.put(5, 0x0f).put(10, red&0x3ff, 0, 0).put(4, 0).put(6, reversed).put(4, 0).put(6, 0).put(4, 0).put(6, 0)
+
+	// Signed mode 11 from -511 (the smallest value) to 511.
+	signed := &bptcWriter{}
+	signed.put(5, 0x03).put(10, 0x201, 0x201, 0x201, 511, 511, 511)
+
+	for _, test := range []struct {
+		name     string
+		format   *image.Format
+		data     []byte
+		expected map[int]float32 // texel to red
+	}{
+		{"mode 11", image.BPTC_BC6H_RGB_UFLOAT, mode11.astcBlock[:], map[int]float32{0: 0, 15: 65504}},
+		{"mode 14", image.BPTC_BC6H_RGB_UFLOAT, mode14.astcBlock[:], map[int]float32{0: 0.5, 15: 0.5}},
+		{"signed", image.BPTC_BC6H_RGB_SFLOAT, signed.astcBlock[:], map[int]float32{0: -65504}},
+		{"reserved mode", image.BPTC_BC6H_RGB_UFLOAT, []byte{0x13, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0}, map[int]float32{0: 0}},
+	} {
+		in := image.Image2D{Data: test.data, Width: 4, Height: 4, Format: test.format}
+		out, err := in.Convert(image.RGBA_F32)
+		if err != nil {
+			t.Errorf("%v: Convert returned error: %v", test.name, err)
+			continue
+		}
+		for texel, expected := range test.expected {
+			got := math.Float32frombits(binary.LittleEndian.Uint32(out.Data[texel*16:]))
+			if got != expected {
+				t.Errorf("%v: texel %d red decoded to %v, expected %v", test.name, texel, got, expected)
+			}
+			alpha := math.Float32frombits(binary.LittleEndian.Uint32(out.Data[texel*16+12:]))
+			if alpha != 1 {
+				t.Errorf("%v: texel %d alpha decoded to %v, expected 1", test.name, texel, alpha)
+			}
+		}
+	}
+}
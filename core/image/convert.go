@@ -50,6 +50,13 @@ type converter interface {
 	convert(data []byte, width, height int, dstFmt *Format) ([]byte, error)
 }
 
+// encoder is the interface implemented by container formats that can encode
+// images of any format.
+type encoder interface {
+	// encode encodes the image formed from data, width and height in srcFmt.
+	encode(data []byte, width, height int, srcFmt *Format) ([]byte, error)
+}
+
 // Convert uses the registered Converters to convert the image formed from
 // data, width and height from srcFmt to dstFmt.
 // If no direct converter has been registered to convert from srcFmt to dstFmt,
@@ -77,14 +84,19 @@ func Convert(data []byte, width, height int, srcFmt, dstFmt *Format) ([]byte, er
 		}
 	}
 
+	// Check if the destination format can encode from any format.
+	if e, ok := protoutil.OneOf(dstFmt.Format).(encoder); ok {
+		return e.encode(data, width, height, srcFmt)
+	}
+
 	// No direct conversion found. Try going via RGBA_U8_NORM.
 	rgbaU8Key := RGBA_U8_NORM.Key()
 	if convA, found := registeredConverters[srcDstFmt{srcKey, rgbaU8Key}]; found {
-		if convB, found := registeredConverters[srcDstFmt{rgbaU8Key, dstKey}]; found {
-			if data, err := convA(data, width, height); err != nil {
-				return convB(data, width, height)
-			}
+		data, err := convA(data, width, height)
+		if err != nil {
+			return nil, err
 		}
+		return Convert(data, width, height, RGBA_U8_NORM, dstFmt)
 	}
 
 	return nil, fmt.Errorf("No converter registered that can convert from format '%s' to '%s'\n",
@@ -0,0 +1,61 @@
+// Copyright (C) 2017 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package image
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/google/gapid/core/data/endian"
+	"github.com/google/gapid/core/os/device"
+	"github.com/google/gapid/core/stream"
+)
+
+// VisualizeDepth returns the depth channel of the image in format f as a
+// grayscale RGBA_U8_NORM image. Depth values in the range [near, far] are
+// linearly mapped to [0, 1], values outside of this range are clamped.
+func VisualizeDepth(data []byte, width, height int, f *Format, near, far float32) ([]byte, error) {
+	if near == far {
+		return nil, fmt.Errorf("Depth range [%v, %v] is empty", near, far)
+	}
+	depth := newUncompressed(&stream.Format{
+		Components: []*stream.Component{{
+			DataType: &stream.F32,
+			Sampling: stream.Linear,
+			Channel:  stream.Channel_Depth,
+		}},
+	})
+	data, err := Convert(data, width, height, f, depth)
+	if err != nil {
+		return nil, err
+	}
+	r := endian.Reader(bytes.NewReader(data), device.LittleEndian)
+	out := make([]byte, width*height*4)
+	for i := 0; i < width*height; i++ {
+		v := (r.Float32() - near) / (far - near)
+		switch {
+		case v < 0:
+			v = 0
+		case v > 1:
+			v = 1
+		}
+		c := byte(v*255 + 0.5)
+		out[i*4+0], out[i*4+1], out[i*4+2], out[i*4+3] = c, c, c, 255
+	}
+	if err := r.Error(); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
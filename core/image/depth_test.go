@@ -0,0 +1,42 @@
+// Copyright (C) 2017 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package image_test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/google/gapid/core/data/endian"
+	"github.com/google/gapid/core/image"
+	"github.com/google/gapid/core/os/device"
+)
+
+func TestVisualizeDepth(t *testing.T) {
+	src := &bytes.Buffer{}
+	w := endian.Writer(src, device.LittleEndian)
+	for _, d := range []float32{0, 0.25, 0.5, 0.75, 1} {
+		w.Uint16(uint16(d * 0xffff))
+	}
+	got, err := image.VisualizeDepth(src.Bytes(), 5, 1, image.D_U16_NORM, 0.25, 0.75)
+	if err != nil {
+		t.Fatalf("VisualizeDepth failed with: %v", err)
+	}
+	expected := []byte{
+		0, 0, 0, 255 /**/, 0, 0, 0, 255 /**/, 127, 127, 127, 255 /**/, 255, 255, 255, 255 /**/, 255, 255, 255, 255,
+	}
+	if !bytes.Equal(got, expected) {
+		t.Errorf("VisualizeDepth gave %v, expected %v", got, expected)
+	}
+}
@@ -0,0 +1,145 @@
+// Copyright (C) 2017 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package image
+
+import (
+	"bytes"
+
+	"github.com/google/gapid/core/data/endian"
+	"github.com/google/gapid/core/math/sint"
+	"github.com/google/gapid/core/os/device"
+	"github.com/google/gapid/core/stream"
+)
+
+var (
+	ETC2_R11_EAC         = NewETC2_R11_EAC("ETC2_R11_EAC")
+	ETC2_SIGNED_R11_EAC  = NewETC2_SIGNED_R11_EAC("ETC2_SIGNED_R11_EAC")
+	ETC2_RG11_EAC        = NewETC2_RG11_EAC("ETC2_RG11_EAC")
+	ETC2_SIGNED_RG11_EAC = NewETC2_SIGNED_RG11_EAC("ETC2_SIGNED_RG11_EAC")
+)
+
+// NewETC2_R11_EAC returns a format representing the COMPRESSED_R11_EAC block
+// texture compression format.
+func NewETC2_R11_EAC(name string) *Format {
+	return &Format{name, &Format_Etc2R11Eac{&FmtETC2_R11_EAC{Signed: false}}}
+}
+
+// NewETC2_SIGNED_R11_EAC returns a format representing the
+// COMPRESSED_SIGNED_R11_EAC block texture compression format.
+func NewETC2_SIGNED_R11_EAC(name string) *Format {
+	return &Format{name, &Format_Etc2R11Eac{&FmtETC2_R11_EAC{Signed: true}}}
+}
+
+// NewETC2_RG11_EAC returns a format representing the COMPRESSED_RG11_EAC block
+// texture compression format.
+func NewETC2_RG11_EAC(name string) *Format {
+	return &Format{name, &Format_Etc2Rg11Eac{&FmtETC2_RG11_EAC{Signed: false}}}
+}
+
+// NewETC2_SIGNED_RG11_EAC returns a format representing the
+// COMPRESSED_SIGNED_RG11_EAC block texture compression format.
+func NewETC2_SIGNED_RG11_EAC(name string) *Format {
+	return &Format{name, &Format_Etc2Rg11Eac{&FmtETC2_RG11_EAC{Signed: true}}}
+}
+
+func (f *FmtETC2_R11_EAC) key() interface{} {
+	return *f
+}
+func (*FmtETC2_R11_EAC) size(w, h int) int {
+	return (sint.Max(sint.AlignUp(w, 4), 4) * sint.Max(sint.AlignUp(h, 4), 4)) / 2
+}
+func (*FmtETC2_R11_EAC) check(d []byte, w, h int) error {
+	return checkSize(d, sint.Max(sint.AlignUp(w, 4), 4), sint.Max(sint.AlignUp(h, 4), 4), 4)
+}
+func (*FmtETC2_R11_EAC) channels() []stream.Channel {
+	return []stream.Channel{stream.Channel_Red}
+}
+
+func (f *FmtETC2_RG11_EAC) key() interface{} {
+	return *f
+}
+func (*FmtETC2_RG11_EAC) size(w, h int) int {
+	return (sint.Max(sint.AlignUp(w, 4), 4) * sint.Max(sint.AlignUp(h, 4), 4))
+}
+func (*FmtETC2_RG11_EAC) check(d []byte, w, h int) error {
+	return checkSize(d, sint.Max(sint.AlignUp(w, 4), 4), sint.Max(sint.AlignUp(h, 4), 4), 8)
+}
+func (*FmtETC2_RG11_EAC) channels() []stream.Channel {
+	return []stream.Channel{stream.Channel_Red, stream.Channel_Green}
+}
+
+func init() {
+	RegisterConverter(ETC2_R11_EAC, RGBA_U8_NORM, func(src []byte, width, height int) ([]byte, error) {
+		v, err := decodeEAC(src, width, height, 1, false)
+		return redGreenToRGBA_U8(v, 1, 2047), err
+	})
+	RegisterConverter(ETC2_RG11_EAC, RGBA_U8_NORM, func(src []byte, width, height int) ([]byte, error) {
+		v, err := decodeEAC(src, width, height, 2, false)
+		return redGreenToRGBA_U8(v, 2, 2047), err
+	})
+	registerF32Decoder(ETC2_SIGNED_R11_EAC, func(src []byte, width, height int) ([]byte, error) {
+		v, err := decodeEAC(src, width, height, 1, true)
+		return redGreenToRGBA_F32(v, 1, 1023), err
+	})
+	registerF32Decoder(ETC2_SIGNED_RG11_EAC, func(src []byte, width, height int) ([]byte, error) {
+		v, err := decodeEAC(src, width, height, 2, true)
+		return redGreenToRGBA_F32(v, 2, 1023), err
+	})
+}
+
+// decodeEAC decodes the one or two channel 11-bit EAC image to a slice of
+// width*height*channels values. Unsigned values are in the range [0, 2047],
+// signed values in the range [-1023, 1023].
+func decodeEAC(src []byte, width, height, channels int, signed bool) ([]int, error) {
+	dst := make([]int, width*height*channels)
+
+	blockWidth := sint.Max((width+3)/4, 1)
+	blockHeight := sint.Max((height+3)/4, 1)
+
+	r := endian.Reader(bytes.NewReader(src), device.BigEndian)
+	for by := 0; by < blockHeight; by++ {
+		for bx := 0; bx < blockWidth; bx++ {
+			for c := 0; c < channels; c++ {
+				// ┏━━━━━━━━━━━━━━━━━━━━━━━┳━━━━━━━━━━━┳━━━━━━━━━━━┓
+				// ┃         Base          ┃Multiplier ┃Table Index┃
+				// ┣━━┯━━┯━━┯━━┯━━┯━━┯━━┯━━╋━━┯━━┯━━┯━━╋━━┯━━┯━━┯━━┫
+				// ┃₆₃│₆₂│₆₁│₆₀│₅₉│₅₈│₅₇│₅₆┃₅₅│₅₄│₅₃│₅₂┃₅₁│₅₀│₄₉│₄₈┃
+				// ┖──┴──┴──┴──┴──┴──┴──┴──┸──┴──┴──┴──┸──┴──┴──┴──┚
+				v64 := r.Uint64()
+				base, min, max := int(v64>>56)*8+4, 0, 2047
+				if signed {
+					base, min, max = sint.Max(int(int8(v64>>56)), -127)*8, -1023, 1023
+				}
+				mul := int((v64>>52)&15) * 8
+				if mul == 0 {
+					mul = 1
+				}
+				modTbl := eacModifierTable[(v64>>48)&15]
+
+				// Texels are stored column-major, the first texel in the
+				// most significant bits.
+				for i := uint(0); i < 16; i++ {
+					x, y := bx*4+int(i/4), by*4+int(i%4)
+					if x < width && y < height {
+						mod := modTbl[(v64>>(45-i*3))&7]
+						dst[(y*width+x)*channels+c] = sint.Clamp(base+mod*mul, min, max)
+					}
+				}
+			}
+		}
+	}
+
+	return dst, r.Error()
+}
@@ -76,6 +76,27 @@ func init() {
 	})
 }
 
+// eacModifierTable holds the EAC modifier tables, used by the alpha channel
+// of ETC2_RGBA8_EAC and the R11 and RG11 formats.
+var eacModifierTable = [16][8]int{
+	{-3, -6, -9, -15, 2, 5, 8, 14},
+	{-3, -7, -10, -13, 2, 6, 9, 12},
+	{-2, -5, -8, -13, 1, 4, 7, 12},
+	{-2, -4, -6, -13, 1, 3, 5, 12},
+	{-3, -6, -8, -12, 2, 5, 7, 11},
+	{-3, -7, -9, -11, 2, 6, 8, 10},
+	{-4, -7, -8, -11, 3, 6, 7, 10},
+	{-3, -5, -8, -11, 2, 4, 7, 10},
+	{-2, -6, -8, -10, 1, 5, 7, 9},
+	{-2, -5, -8, -10, 1, 4, 7, 9},
+	{-2, -4, -8, -10, 1, 3, 7, 9},
+	{-2, -5, -7, -10, 1, 4, 6, 9},
+	{-3, -4, -7, -10, 2, 3, 6, 9},
+	{-1, -2, -3, -10, 0, 1, 2, 9},
+	{-4, -6, -8, -9, 3, 5, 7, 8},
+	{-3, -5, -7, -9, 2, 4, 6, 8},
+}
+
 func decodeETC(src []byte, width, height int, hasAlpha bool) ([]byte, error) {
 	dst := make([]byte, width*height*4)
 
@@ -105,24 +126,6 @@ func decodeETC(src []byte, width, height int, hasAlpha bool) ([]byte, error) {
 		{0, 0, 0, 0, 0, 0, 0, 0, 1, 1, 1, 1, 1, 1, 1, 1},
 		{0, 0, 1, 1, 0, 0, 1, 1, 0, 0, 1, 1, 0, 0, 1, 1},
 	}
-	alphaModTbl := [16][8]int{
-		{-3, -6, -9, -15, 2, 5, 8, 14},
-		{-3, -7, -10, -13, 2, 6, 9, 12},
-		{-2, -5, -8, -13, 1, 4, 7, 12},
-		{-2, -4, -6, -13, 1, 3, 5, 12},
-		{-3, -6, -8, -12, 2, 5, 7, 11},
-		{-3, -7, -9, -11, 2, 6, 8, 10},
-		{-4, -7, -8, -11, 3, 6, 7, 10},
-		{-3, -5, -8, -11, 2, 4, 7, 10},
-		{-2, -6, -8, -10, 1, 5, 7, 9},
-		{-2, -5, -8, -10, 1, 4, 7, 9},
-		{-2, -4, -8, -10, 1, 3, 7, 9},
-		{-2, -5, -7, -10, 1, 4, 6, 9},
-		{-3, -4, -7, -10, 2, 3, 6, 9},
-		{-1, -2, -3, -10, 0, 1, 2, 9},
-		{-4, -6, -8, -9, 3, 5, 7, 8},
-		{-3, -5, -7, -9, 2, 4, 6, 8},
-	}
 	alpha := [16]byte{
 		0xff, 0xff, 0xff, 0xff,
 		0xff, 0xff, 0xff, 0xff,
@@ -142,7 +145,7 @@ func decodeETC(src []byte, width, height int, hasAlpha bool) ([]byte, error) {
 				// ┖──┴──┴──┴──┴──┴──┴──┴──┸──┴──┴──┴──┸──┴──┴──┴──┚
 				base := int(v64 >> 56)
 				mul := int((v64 >> 52) & 15)
-				modTbl := alphaModTbl[(v64>>48)&15]
+				modTbl := eacModifierTable[(v64>>48)&15]
 				for i := uint8(0); i < 16; i++ {
 					mod := modTbl[(v64>>(i*3))&7]
 					alpha[15-i] = sint.Byte(base + mod*mul)
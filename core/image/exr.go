@@ -0,0 +1,439 @@
+// Copyright (C) 2017 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package image
+
+import (
+	"bytes"
+	"compress/zlib"
+	"fmt"
+	"io/ioutil"
+	"sort"
+
+	"github.com/google/gapid/core/data/endian"
+	"github.com/google/gapid/core/data/protoutil"
+	"github.com/google/gapid/core/math/f16"
+	"github.com/google/gapid/core/os/device"
+	"github.com/google/gapid/core/stream"
+)
+
+var (
+	EXR     = NewEXR("exr", false)
+	EXR_ZIP = NewEXR("exr-zip", true)
+)
+
+// NewEXR returns a format representing a single-part, scanline OpenEXR file.
+// If zip is true then the scanlines are ZIP compressed in blocks of 16,
+// otherwise they are stored uncompressed.
+func NewEXR(name string, zip bool) *Format {
+	return &Format{name, &Format_Exr{&FmtEXR{Zip: zip}}}
+}
+
+func (f *FmtEXR) key() interface{}             { return *f }
+func (*FmtEXR) size(w, h int) int              { return -1 }
+func (*FmtEXR) check(d []byte, w, h int) error { return nil }
+func (*FmtEXR) channels() []stream.Channel {
+	return nil
+}
+
+func init() {
+	RegisterConverter(EXR, RGBA_F32, decodeEXR)
+	RegisterConverter(EXR_ZIP, RGBA_F32, decodeEXR)
+}
+
+const (
+	exrMagic   = 20000630
+	exrVersion = 2
+
+	exrPixelUint  = 0
+	exrPixelHalf  = 1
+	exrPixelFloat = 2
+
+	exrCompressionNone = 0
+	exrCompressionZips = 2
+	exrCompressionZip  = 3
+)
+
+// exrChannelNames maps the stream channels to the conventional OpenEXR channel
+// names. Channels not listed use their stream name.
+var exrChannelNames = map[stream.Channel]string{
+	stream.Channel_Red:       "R",
+	stream.Channel_Green:     "G",
+	stream.Channel_Blue:      "B",
+	stream.Channel_Alpha:     "A",
+	stream.Channel_Depth:     "Z",
+	stream.Channel_Luminance: "Y",
+	stream.Channel_Gray:      "Y",
+}
+
+type exrChannel struct {
+	name  string
+	ty    int32
+	index int // index of the channel in the source pixel.
+}
+
+// encode writes the image as 32-bit float channels, preserving all the
+// channels of uncompressed source formats.
+func (f *FmtEXR) encode(data []byte, width, height int, srcFmt *Format) ([]byte, error) {
+	values, channels, err := floatChannels(data, width, height, srcFmt)
+	if err != nil {
+		return nil, err
+	}
+
+	exrChannels := make([]exrChannel, len(channels))
+	names := map[string]bool{}
+	for i, c := range channels {
+		name, ok := exrChannelNames[c]
+		if !ok {
+			name = fmt.Sprint(c)
+		}
+		if names[name] {
+			return nil, fmt.Errorf("Format %v has multiple channels named '%v'", srcFmt, name)
+		}
+		names[name] = true
+		exrChannels[i] = exrChannel{name, exrPixelFloat, i}
+	}
+	// Channels are stored in alphabetical order.
+	sort.Slice(exrChannels, func(i, j int) bool { return exrChannels[i].name < exrChannels[j].name })
+
+	compression, linesPerBlock := uint8(exrCompressionNone), 1
+	if f.Zip {
+		compression, linesPerBlock = exrCompressionZip, 16
+	}
+
+	buf := &bytes.Buffer{}
+	w := endian.Writer(buf, device.LittleEndian)
+	w.Uint32(exrMagic)
+	w.Uint32(exrVersion)
+
+	attribute := func(name, ty string, size int) {
+		w.String(name)
+		w.String(ty)
+		w.Int32(int32(size))
+	}
+	attribute("channels", "chlist", exrChannelListSize(exrChannels))
+	for _, c := range exrChannels {
+		w.String(c.name)
+		w.Int32(c.ty)
+		w.Data([]byte{0, 0, 0, 0}) // pLinear and reserved
+		w.Int32(1)                 // xSampling
+		w.Int32(1)                 // ySampling
+	}
+	w.Uint8(0)
+	attribute("compression", "compression", 1)
+	w.Uint8(compression)
+	for _, window := range []string{"dataWindow", "displayWindow"} {
+		attribute(window, "box2i", 16)
+		w.Int32(0)
+		w.Int32(0)
+		w.Int32(int32(width - 1))
+		w.Int32(int32(height - 1))
+	}
+	attribute("lineOrder", "lineOrder", 1)
+	w.Uint8(0) // INCREASING_Y
+	attribute("pixelAspectRatio", "float", 4)
+	w.Float32(1)
+	attribute("screenWindowCenter", "v2f", 8)
+	w.Float32(0)
+	w.Float32(0)
+	attribute("screenWindowWidth", "float", 4)
+	w.Float32(1)
+	w.Uint8(0) // End of header
+
+	// Build each of the chunks, then write the offset table followed by the
+	// chunks themselves.
+	chunks := [][]byte{}
+	for y := 0; y < height; y += linesPerBlock {
+		block := &bytes.Buffer{}
+		bw := endian.Writer(block, device.LittleEndian)
+		for line := y; line < height && line < y+linesPerBlock; line++ {
+			for _, c := range exrChannels {
+				for x := 0; x < width; x++ {
+					bw.Float32(values[(line*width+x)*len(channels)+c.index])
+				}
+			}
+		}
+		raw := block.Bytes()
+		if f.Zip {
+			raw, err = exrZip(raw)
+			if err != nil {
+				return nil, err
+			}
+		}
+		chunk := &bytes.Buffer{}
+		cw := endian.Writer(chunk, device.LittleEndian)
+		cw.Int32(int32(y))
+		cw.Int32(int32(len(raw)))
+		cw.Data(raw)
+		chunks = append(chunks, chunk.Bytes())
+	}
+	offset := uint64(buf.Len() + len(chunks)*8)
+	for _, chunk := range chunks {
+		w.Uint64(offset)
+		offset += uint64(len(chunk))
+	}
+	for _, chunk := range chunks {
+		w.Data(chunk)
+	}
+	if err := w.Error(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// exrChannelListSize returns the size in bytes of the chlist attribute value.
+func exrChannelListSize(channels []exrChannel) int {
+	size := 1 // List terminator
+	for _, c := range channels {
+		size += len(c.name) + 1 + 16
+	}
+	return size
+}
+
+// exrZip returns the block data transformed with the OpenEXR ZIP predictor
+// and compressed with zlib. If compression does not reduce the size of the
+// data then the data is returned uncompressed, as required by the format.
+func exrZip(data []byte) ([]byte, error) {
+	// Split the even and odd bytes into two halves.
+	t := make([]byte, len(data))
+	half := (len(data) + 1) / 2
+	for i := range data {
+		if i%2 == 0 {
+			t[i/2] = data[i]
+		} else {
+			t[half+i/2] = data[i]
+		}
+	}
+	// Store the deltas between neighbouring bytes.
+	for i := len(t) - 1; i > 0; i-- {
+		t[i] = byte(int(t[i]) - int(t[i-1]) + 128)
+	}
+	out := &bytes.Buffer{}
+	z := zlib.NewWriter(out)
+	if _, err := z.Write(t); err != nil {
+		return nil, err
+	}
+	if err := z.Close(); err != nil {
+		return nil, err
+	}
+	if out.Len() >= len(data) {
+		return data, nil
+	}
+	return out.Bytes(), nil
+}
+
+// exrUnzip reverses exrZip, returning size bytes of block data.
+func exrUnzip(data []byte, size int) ([]byte, error) {
+	if len(data) == size {
+		return data, nil // Stored uncompressed.
+	}
+	z, err := zlib.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	t, err := ioutil.ReadAll(z)
+	if err != nil {
+		return nil, err
+	}
+	if len(t) != size {
+		return nil, fmt.Errorf("EXR block decompressed to %d bytes, expected %d", len(t), size)
+	}
+	for i := 1; i < len(t); i++ {
+		t[i] = byte(int(t[i-1]) + int(t[i]) - 128)
+	}
+	out := make([]byte, size)
+	half := (size + 1) / 2
+	for i := range out {
+		if i%2 == 0 {
+			out[i] = t[i/2]
+		} else {
+			out[i] = t[half+i/2]
+		}
+	}
+	return out, nil
+}
+
+// decodeEXR decodes a single-part, scanline OpenEXR file to RGBA_F32.
+// The R, G, B and A channels are used if present. A Y channel is used for all
+// of the color channels and a Z channel is used for red if there is no R
+// channel.
+func decodeEXR(src []byte, width, height int) ([]byte, error) {
+	r := endian.Reader(bytes.NewReader(src), device.LittleEndian)
+	if magic := r.Uint32(); magic != exrMagic {
+		return nil, fmt.Errorf("Not an OpenEXR file")
+	}
+	if version := r.Uint32(); version&0xff != exrVersion || version&^0xff != 0 {
+		return nil, fmt.Errorf("Unsupported OpenEXR version or flags: 0x%x", version)
+	}
+
+	channels := []exrChannel{}
+	compression := -1
+	var xMin, yMin, xMax, yMax int32
+	for r.Error() == nil {
+		name := r.String()
+		if name == "" {
+			break
+		}
+		ty, size := r.String(), int(r.Int32())
+		switch {
+		case name == "channels" && ty == "chlist":
+			for r.Error() == nil {
+				c := exrChannel{name: r.String()}
+				if c.name == "" {
+					break
+				}
+				c.ty = r.Int32()
+				r.Data(make([]byte, 4))
+				if xs, ys := r.Int32(), r.Int32(); xs != 1 || ys != 1 {
+					return nil, fmt.Errorf("Subsampled OpenEXR channels are not supported")
+				}
+				channels = append(channels, c)
+			}
+		case name == "compression" && ty == "compression":
+			compression = int(r.Uint8())
+		case name == "dataWindow" && ty == "box2i":
+			xMin, yMin, xMax, yMax = r.Int32(), r.Int32(), r.Int32(), r.Int32()
+		default:
+			r.Data(make([]byte, size))
+		}
+	}
+	if err := r.Error(); err != nil {
+		return nil, err
+	}
+
+	if w, h := int(xMax-xMin+1), int(yMax-yMin+1); w != width || h != height {
+		return nil, fmt.Errorf("OpenEXR size was not as expected. Got: %vx%v, expected: %vx%v", w, h, width, height)
+	}
+
+	linesPerBlock := 1
+	switch compression {
+	case exrCompressionNone, exrCompressionZips:
+	case exrCompressionZip:
+		linesPerBlock = 16
+	default:
+		return nil, fmt.Errorf("Unsupported OpenEXR compression %d", compression)
+	}
+
+	// Work out where each channel goes in the RGBA output.
+	targets := map[string][]int{"R": {0}, "G": {1}, "B": {2}, "A": {3}, "Y": {0, 1, 2}}
+	hasRed := false
+	for _, c := range channels {
+		hasRed = hasRed || c.name == "R"
+	}
+	if !hasRed {
+		targets["Z"] = []int{0}
+	}
+
+	pixelSize := 0
+	for _, c := range channels {
+		switch c.ty {
+		case exrPixelHalf:
+			pixelSize += 2
+		case exrPixelUint, exrPixelFloat:
+			pixelSize += 4
+		default:
+			return nil, fmt.Errorf("Unsupported OpenEXR pixel type %d", c.ty)
+		}
+	}
+
+	out := make([]float32, width*height*4)
+	for i := 3; i < len(out); i += 4 {
+		out[i] = 1
+	}
+
+	chunks := (height + linesPerBlock - 1) / linesPerBlock
+	for i := 0; i < chunks; i++ {
+		r.Uint64() // Chunks are read in order, the offsets are not needed.
+	}
+	for i := 0; i < chunks; i++ {
+		y := int(r.Int32() - yMin)
+		data := make([]byte, r.Int32())
+		r.Data(data)
+		if err := r.Error(); err != nil {
+			return nil, err
+		}
+		if y < 0 || y >= height {
+			return nil, fmt.Errorf("OpenEXR chunk has invalid y coordinate %d", y+int(yMin))
+		}
+		lines := linesPerBlock
+		if y+lines > height {
+			lines = height - y
+		}
+		if compression != exrCompressionNone {
+			var err error
+			if data, err = exrUnzip(data, lines*width*pixelSize); err != nil {
+				return nil, err
+			}
+		}
+		br := endian.Reader(bytes.NewReader(data), device.LittleEndian)
+		for line := y; line < y+lines; line++ {
+			for _, c := range channels {
+				for x := 0; x < width; x++ {
+					var v float32
+					switch c.ty {
+					case exrPixelHalf:
+						v = f16.Number(br.Uint16()).Float32()
+					case exrPixelUint:
+						v = float32(br.Uint32())
+					case exrPixelFloat:
+						v = br.Float32()
+					}
+					for _, t := range targets[c.name] {
+						out[(line*width+x)*4+t] = v
+					}
+				}
+			}
+		}
+		if err := br.Error(); err != nil {
+			return nil, err
+		}
+	}
+
+	buf := &bytes.Buffer{}
+	w := endian.Writer(buf, device.LittleEndian)
+	for _, v := range out {
+		w.Float32(v)
+	}
+	return buf.Bytes(), nil
+}
+
+// floatChannels returns the image converted to 32-bit float values, along
+// with the channels of each pixel. Uncompressed formats keep all their
+// channels, other formats are converted to RGBA.
+func floatChannels(data []byte, width, height int, srcFmt *Format) ([]float32, []stream.Channel, error) {
+	dstFmt := RGBA_F32
+	channels := RGBA_F32.Channels()
+	if u, ok := protoutil.OneOf(srcFmt.Format).(*FmtUncompressed); ok {
+		channels = u.channels()
+		f := &stream.Format{}
+		for _, c := range channels {
+			f.Components = append(f.Components, &stream.Component{
+				DataType: &stream.F32,
+				Sampling: stream.Linear,
+				Channel:  c,
+			})
+		}
+		dstFmt = newUncompressed(f)
+	}
+	data, err := Convert(data, width, height, srcFmt, dstFmt)
+	if err != nil {
+		return nil, nil, err
+	}
+	r := endian.Reader(bytes.NewReader(data), device.LittleEndian)
+	out := make([]float32, width*height*len(channels))
+	for i := range out {
+		out[i] = r.Float32()
+	}
+	return out, channels, r.Error()
+}
@@ -0,0 +1,83 @@
+// Copyright (C) 2017 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package image_test
+
+import (
+	"bytes"
+	"math"
+	"testing"
+
+	"github.com/google/gapid/core/data/endian"
+	"github.com/google/gapid/core/image"
+	"github.com/google/gapid/core/os/device"
+)
+
+func float32s(values []float32) []byte {
+	buf := &bytes.Buffer{}
+	w := endian.Writer(buf, device.LittleEndian)
+	for _, v := range values {
+		w.Float32(v)
+	}
+	return buf.Bytes()
+}
+
+func TestEXRRoundTrip(t *testing.T) {
+	const width, height = 23, 19
+	src := make([]float32, width*height*4)
+	for i := range src {
+		src[i] = float32(i%97)*0.125 - 3
+	}
+	for _, f := range []*image.Format{image.EXR, image.EXR_ZIP} {
+		exr, err := image.Convert(float32s(src), width, height, image.RGBA_F32, f)
+		if err != nil {
+			t.Errorf("Encoding %v failed with: %v", f.Name, err)
+			continue
+		}
+		got, err := image.Convert(exr, width, height, f, image.RGBA_F32)
+		if err != nil {
+			t.Errorf("Decoding %v failed with: %v", f.Name, err)
+			continue
+		}
+		if !bytes.Equal(got, float32s(src)) {
+			t.Errorf("Round trip through %v was not lossless", f.Name)
+		}
+	}
+}
+
+func TestEXRDepth(t *testing.T) {
+	const width, height = 4, 2
+	src := &bytes.Buffer{}
+	w := endian.Writer(src, device.LittleEndian)
+	expected := []float32{}
+	for i := 0; i < width*height; i++ {
+		w.Uint16(uint16(i * 0x2000))
+		d := float32(i*0x2000) / 0xffff
+		expected = append(expected, d, 0, 0, 1)
+	}
+	exr, err := image.Convert(src.Bytes(), width, height, image.D_U16_NORM, image.EXR_ZIP)
+	if err != nil {
+		t.Fatalf("Encoding depth failed with: %v", err)
+	}
+	got, err := image.Convert(exr, width, height, image.EXR_ZIP, image.RGBA_F32)
+	if err != nil {
+		t.Fatalf("Decoding depth failed with: %v", err)
+	}
+	r := endian.Reader(bytes.NewReader(got), device.LittleEndian)
+	for i, e := range expected {
+		if v := r.Float32(); math.Abs(float64(v-e)) > 1e-6 {
+			t.Errorf("Depth round trip at %d gave %v, expected %v", i, v, e)
+		}
+	}
+}
@@ -54,10 +54,18 @@ var _ = []format{
 	&FmtATC_RGB_AMD{},
 	&FmtATC_RGBA_EXPLICIT_ALPHA_AMD{},
 	&FmtATC_RGBA_INTERPOLATED_ALPHA_AMD{},
+	&FmtBPTC_BC6H{},
+	&FmtBPTC_BC7{},
 	&FmtETC1_RGB8{},
 	&FmtETC2_RGB8{},
 	&FmtETC2_RGBA8_EAC{},
+	&FmtETC2_R11_EAC{},
+	&FmtETC2_RG11_EAC{},
+	&FmtEXR{},
 	&FmtPNG{},
+	&FmtRadianceHDR{},
+	&FmtRGTC1_BC4{},
+	&FmtRGTC2_BC5{},
 	&FmtS3_DXT1_RGB{},
 	&FmtS3_DXT1_RGBA{},
 	&FmtS3_DXT3_RGBA{},
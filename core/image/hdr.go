@@ -0,0 +1,264 @@
+// Copyright (C) 2017 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package image
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"math"
+
+	"github.com/google/gapid/core/data/endian"
+	"github.com/google/gapid/core/os/device"
+	"github.com/google/gapid/core/stream"
+)
+
+// HDR is a Radiance RGBE (.hdr) image.
+var HDR = NewRadianceHDR("hdr")
+
+// NewRadianceHDR returns a format representing a Radiance RGBE image.
+func NewRadianceHDR(name string) *Format {
+	return &Format{name, &Format_Hdr{&FmtRadianceHDR{}}}
+}
+
+func (f *FmtRadianceHDR) key() interface{}             { return *f }
+func (*FmtRadianceHDR) size(w, h int) int              { return -1 }
+func (*FmtRadianceHDR) check(d []byte, w, h int) error { return nil }
+func (*FmtRadianceHDR) channels() []stream.Channel {
+	return []stream.Channel{stream.Channel_Red, stream.Channel_Green, stream.Channel_Blue}
+}
+
+func init() {
+	RegisterConverter(HDR, RGBA_F32, decodeHDR)
+}
+
+// encode writes the image as run-length encoded RGBE scanlines. Alpha is
+// discarded and negative values are clamped to zero.
+func (*FmtRadianceHDR) encode(data []byte, width, height int, srcFmt *Format) ([]byte, error) {
+	data, err := Convert(data, width, height, srcFmt, RGBA_F32)
+	if err != nil {
+		return nil, err
+	}
+	r := endian.Reader(bytes.NewReader(data), device.LittleEndian)
+
+	buf := &bytes.Buffer{}
+	fmt.Fprintf(buf, "#?RADIANCE\nFORMAT=32-bit_rle_rgbe\n\n-Y %d +X %d\n", height, width)
+
+	rle := width >= 8 && width <= 0x7fff
+	line := make([][]byte, 4)
+	for i := range line {
+		line[i] = make([]byte, width)
+	}
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			rgbe := floatToRGBE(r.Float32(), r.Float32(), r.Float32())
+			r.Float32() // alpha
+			for i, v := range rgbe {
+				line[i][x] = v
+			}
+		}
+		if !rle {
+			for x := 0; x < width; x++ {
+				buf.Write([]byte{line[0][x], line[1][x], line[2][x], line[3][x]})
+			}
+			continue
+		}
+		buf.Write([]byte{2, 2, byte(width >> 8), byte(width)})
+		for _, component := range line {
+			hdrWriteRuns(buf, component)
+		}
+	}
+	if err := r.Error(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// hdrWriteRuns writes the component values using the Radiance run-length
+// encoding. Runs are written as 128+count followed by the value, literals as
+// count followed by the values.
+func hdrWriteRuns(buf *bytes.Buffer, data []byte) {
+	const minRun = 3
+	for i := 0; i < len(data); {
+		// Find the start of the next run long enough to encode.
+		start, run := i, 1
+		for ; start < len(data); start += run {
+			run = 1
+			for start+run < len(data) && run < 127 && data[start+run] == data[start] {
+				run++
+			}
+			if run >= minRun {
+				break
+			}
+		}
+		// Write the literals before the run.
+		for i < start {
+			n := start - i
+			if n > 128 {
+				n = 128
+			}
+			buf.WriteByte(byte(n))
+			buf.Write(data[i : i+n])
+			i += n
+		}
+		if start < len(data) {
+			buf.WriteByte(byte(128 + run))
+			buf.WriteByte(data[start])
+			i = start + run
+		}
+	}
+}
+
+// floatToRGBE returns the shared-exponent encoding of the color.
+func floatToRGBE(r, g, b float32) [4]byte {
+	clamp := func(v float32) float64 {
+		if v > 0 && !math.IsNaN(float64(v)) {
+			return float64(v)
+		}
+		return 0
+	}
+	fr, fg, fb := clamp(r), clamp(g), clamp(b)
+	max := math.Max(fr, math.Max(fg, fb))
+	if max < 1e-32 {
+		return [4]byte{}
+	}
+	if math.IsInf(max, 1) {
+		max = math.MaxFloat32
+	}
+	frac, exp := math.Frexp(max)
+	scale := frac * 256 / max
+	return [4]byte{
+		byte(math.Min(fr*scale, 255)),
+		byte(math.Min(fg*scale, 255)),
+		byte(math.Min(fb*scale, 255)),
+		byte(exp + 128),
+	}
+}
+
+// decodeHDR decodes a Radiance RGBE image to RGBA_F32. Both flat and
+// run-length encoded scanlines are supported. Only the standard -Y h +X w
+// orientation is supported.
+func decodeHDR(src []byte, width, height int) ([]byte, error) {
+	in := bufio.NewReader(bytes.NewReader(src))
+	magic, err := in.ReadString('\n')
+	if err != nil || (magic != "#?RADIANCE\n" && magic != "#?RGBE\n") {
+		return nil, fmt.Errorf("Not a Radiance HDR file")
+	}
+	for {
+		line, err := in.ReadString('\n')
+		if err != nil {
+			return nil, err
+		}
+		if line == "\n" {
+			break
+		}
+		if line == "FORMAT=32-bit_rle_xyze\n" {
+			return nil, fmt.Errorf("Radiance XYZE images are not supported")
+		}
+	}
+	var w, h int
+	if _, err := fmt.Fscanf(in, "-Y %d +X %d\n", &h, &w); err != nil {
+		return nil, fmt.Errorf("Unsupported Radiance HDR resolution: %v", err)
+	}
+	if w != width || h != height {
+		return nil, fmt.Errorf("Radiance HDR size was not as expected. Got: %vx%v, expected: %vx%v", w, h, width, height)
+	}
+
+	buf := &bytes.Buffer{}
+	out := endian.Writer(buf, device.LittleEndian)
+	line := make([][]byte, 4)
+	for i := range line {
+		line[i] = make([]byte, width)
+	}
+	for y := 0; y < height; y++ {
+		header := make([]byte, 4)
+		if _, err := io.ReadFull(in, header); err != nil {
+			return nil, err
+		}
+		rle := width >= 8 && width <= 0x7fff && header[0] == 2 && header[1] == 2 && header[2]&0x80 == 0
+		if rle && int(header[2])<<8|int(header[3]) != width {
+			return nil, fmt.Errorf("Radiance HDR scanline width mismatch")
+		}
+		if rle {
+			for _, component := range line {
+				if err := hdrReadRuns(in, component); err != nil {
+					return nil, err
+				}
+			}
+		} else {
+			for x := 0; x < width; x++ {
+				pixel := header
+				if x > 0 {
+					pixel = make([]byte, 4)
+					if _, err := io.ReadFull(in, pixel); err != nil {
+						return nil, err
+					}
+				}
+				for i, v := range pixel {
+					line[i][x] = v
+				}
+			}
+		}
+		for x := 0; x < width; x++ {
+			scale := float32(0)
+			if e := line[3][x]; e != 0 {
+				scale = float32(math.Ldexp(1, int(e)-136))
+			}
+			out.Float32(float32(line[0][x]) * scale)
+			out.Float32(float32(line[1][x]) * scale)
+			out.Float32(float32(line[2][x]) * scale)
+			out.Float32(1)
+		}
+	}
+	if err := out.Error(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// hdrReadRuns reads a single run-length encoded component of a scanline.
+func hdrReadRuns(in *bufio.Reader, data []byte) error {
+	for i := 0; i < len(data); {
+		n, err := in.ReadByte()
+		if err != nil {
+			return err
+		}
+		if n > 128 {
+			count := int(n - 128)
+			if i+count > len(data) {
+				return fmt.Errorf("Radiance HDR run overflows scanline")
+			}
+			v, err := in.ReadByte()
+			if err != nil {
+				return err
+			}
+			for ; count > 0; count-- {
+				data[i] = v
+				i++
+			}
+		} else {
+			count := int(n)
+			if count == 0 || i+count > len(data) {
+				return fmt.Errorf("Radiance HDR run overflows scanline")
+			}
+			if _, err := io.ReadFull(in, data[i:i+count]); err != nil {
+				return err
+			}
+			i += count
+		}
+	}
+	return nil
+}
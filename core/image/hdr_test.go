@@ -0,0 +1,54 @@
+// Copyright (C) 2017 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package image_test
+
+import (
+	"bytes"
+	"math"
+	"testing"
+
+	"github.com/google/gapid/core/data/endian"
+	"github.com/google/gapid/core/image"
+	"github.com/google/gapid/core/os/device"
+)
+
+func TestRadianceHDRRoundTrip(t *testing.T) {
+	for _, size := range []struct{ width, height int }{{3, 2}, {40, 3}} {
+		src := make([]float32, size.width*size.height*4)
+		for i := range src {
+			if i%4 == 3 {
+				src[i] = 1
+			} else {
+				src[i] = float32(i/12) * 0.75
+			}
+		}
+		hdr, err := image.Convert(float32s(src), size.width, size.height, image.RGBA_F32, image.HDR)
+		if err != nil {
+			t.Fatalf("Encoding HDR failed with: %v", err)
+		}
+		got, err := image.Convert(hdr, size.width, size.height, image.HDR, image.RGBA_F32)
+		if err != nil {
+			t.Fatalf("Decoding HDR failed with: %v", err)
+		}
+		r := endian.Reader(bytes.NewReader(got), device.LittleEndian)
+		for i, expected := range src {
+			// RGBE has 8 bits of mantissa shared between the components.
+			if v := r.Float32(); math.Abs(float64(v-expected)) > float64(expected)/128+1e-6 {
+				t.Errorf("HDR round trip of %dx%d at %d gave %v, expected %v",
+					size.width, size.height, i, v, expected)
+			}
+		}
+	}
+}
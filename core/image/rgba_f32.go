@@ -120,3 +120,17 @@ func resizeRGBA_F32(data []byte, srcW, srcH, dstW, dstH int) ([]byte, error) {
 	}
 	return out, nil
 }
+
+// registerF32Decoder registers decode as the converter from the compressed
+// format f to RGBA_F32, along with a converter to RGBA_U8_NORM that goes via
+// RGBA_F32.
+func registerF32Decoder(f *Format, decode Converter) {
+	RegisterConverter(f, RGBA_F32, decode)
+	RegisterConverter(f, RGBA_U8_NORM, func(src []byte, width, height int) ([]byte, error) {
+		data, err := decode(src, width, height)
+		if err != nil {
+			return nil, err
+		}
+		return Convert(data, width, height, RGBA_F32, RGBA_U8_NORM)
+	})
+}
@@ -0,0 +1,170 @@
+// Copyright (C) 2017 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package image
+
+import (
+	"bytes"
+
+	"github.com/google/gapid/core/data/endian"
+	"github.com/google/gapid/core/math/sint"
+	"github.com/google/gapid/core/os/device"
+	"github.com/google/gapid/core/stream"
+)
+
+var (
+	RGTC1_BC4_R_U8_NORM  = NewRGTC1_BC4_R_U8_NORM("RGTC1_BC4_R_U8_NORM")
+	RGTC1_BC4_R_S8_NORM  = NewRGTC1_BC4_R_S8_NORM("RGTC1_BC4_R_S8_NORM")
+	RGTC2_BC5_RG_U8_NORM = NewRGTC2_BC5_RG_U8_NORM("RGTC2_BC5_RG_U8_NORM")
+	RGTC2_BC5_RG_S8_NORM = NewRGTC2_BC5_RG_S8_NORM("RGTC2_BC5_RG_S8_NORM")
+)
+
+// NewRGTC1_BC4_R_U8_NORM returns a format representing the COMPRESSED_RED_RGTC1
+// (BC4 unsigned) block texture compression format.
+func NewRGTC1_BC4_R_U8_NORM(name string) *Format {
+	return &Format{name, &Format_Rgtc1Bc4{&FmtRGTC1_BC4{Signed: false}}}
+}
+
+// NewRGTC1_BC4_R_S8_NORM returns a format representing the
+// COMPRESSED_SIGNED_RED_RGTC1 (BC4 signed) block texture compression format.
+func NewRGTC1_BC4_R_S8_NORM(name string) *Format {
+	return &Format{name, &Format_Rgtc1Bc4{&FmtRGTC1_BC4{Signed: true}}}
+}
+
+// NewRGTC2_BC5_RG_U8_NORM returns a format representing the COMPRESSED_RG_RGTC2
+// (BC5 unsigned) block texture compression format.
+func NewRGTC2_BC5_RG_U8_NORM(name string) *Format {
+	return &Format{name, &Format_Rgtc2Bc5{&FmtRGTC2_BC5{Signed: false}}}
+}
+
+// NewRGTC2_BC5_RG_S8_NORM returns a format representing the
+// COMPRESSED_SIGNED_RG_RGTC2 (BC5 signed) block texture compression format.
+func NewRGTC2_BC5_RG_S8_NORM(name string) *Format {
+	return &Format{name, &Format_Rgtc2Bc5{&FmtRGTC2_BC5{Signed: true}}}
+}
+
+func (f *FmtRGTC1_BC4) key() interface{} { return *f }
+func (*FmtRGTC1_BC4) size(w, h int) int {
+	return (sint.Max(sint.AlignUp(w, 4), 4) * sint.Max(sint.AlignUp(h, 4), 4)) / 2
+}
+func (*FmtRGTC1_BC4) check(d []byte, w, h int) error {
+	return checkSize(d, sint.Max(sint.AlignUp(w, 4), 4), sint.Max(sint.AlignUp(h, 4), 4), 4)
+}
+func (*FmtRGTC1_BC4) channels() []stream.Channel {
+	return []stream.Channel{stream.Channel_Red}
+}
+
+func (f *FmtRGTC2_BC5) key() interface{} { return *f }
+func (*FmtRGTC2_BC5) size(w, h int) int {
+	return (sint.Max(sint.AlignUp(w, 4), 4) * sint.Max(sint.AlignUp(h, 4), 4))
+}
+func (*FmtRGTC2_BC5) check(d []byte, w, h int) error {
+	return checkSize(d, sint.Max(sint.AlignUp(w, 4), 4), sint.Max(sint.AlignUp(h, 4), 4), 8)
+}
+func (*FmtRGTC2_BC5) channels() []stream.Channel {
+	return []stream.Channel{stream.Channel_Red, stream.Channel_Green}
+}
+
+func init() {
+	RegisterConverter(RGTC1_BC4_R_U8_NORM, RGBA_U8_NORM, func(src []byte, width, height int) ([]byte, error) {
+		v, err := decodeRGTC(src, width, height, 1, false)
+		return redGreenToRGBA_U8(v, 1, 255), err
+	})
+	RegisterConverter(RGTC2_BC5_RG_U8_NORM, RGBA_U8_NORM, func(src []byte, width, height int) ([]byte, error) {
+		v, err := decodeRGTC(src, width, height, 2, false)
+		return redGreenToRGBA_U8(v, 2, 255), err
+	})
+	registerF32Decoder(RGTC1_BC4_R_S8_NORM, func(src []byte, width, height int) ([]byte, error) {
+		v, err := decodeRGTC(src, width, height, 1, true)
+		return redGreenToRGBA_F32(v, 1, 127), err
+	})
+	registerF32Decoder(RGTC2_BC5_RG_S8_NORM, func(src []byte, width, height int) ([]byte, error) {
+		v, err := decodeRGTC(src, width, height, 2, true)
+		return redGreenToRGBA_F32(v, 2, 127), err
+	})
+}
+
+// decodeRGTC decodes the one or two channel RGTC image to a slice of
+// width*height*channels values. Unsigned values are in the range [0, 255],
+// signed values in the range [-127, 127].
+func decodeRGTC(src []byte, width, height, channels int, signed bool) ([]int, error) {
+	dst := make([]int, width*height*channels)
+	r := endian.Reader(bytes.NewReader(src), device.LittleEndian)
+	for y := 0; y < height; y += 4 {
+		for x := 0; x < width; x += 4 {
+			for c := 0; c < channels; c++ {
+				// Each channel is encoded the same way as the DXT5 alpha
+				// channel: two 8-bit endpoints followed by sixteen 3-bit codes.
+				v0, v1, codes := int(r.Uint8()), int(r.Uint8()), uint64(r.Uint16())|(uint64(r.Uint32())<<16)
+				min, max := 0, 255
+				if signed {
+					v0, v1 = sint.Max(int(int8(v0)), -127), sint.Max(int(int8(v1)), -127)
+					min, max = -127, 127
+				}
+
+				palette := [8]int{v0, v1}
+				if v0 > v1 {
+					for i := 2; i < 8; i++ {
+						palette[i] = (v0*(8-i) + v1*(i-1)) / 7
+					}
+				} else {
+					for i := 2; i < 6; i++ {
+						palette[i] = (v0*(6-i) + v1*(i-1)) / 5
+					}
+					palette[6], palette[7] = min, max
+				}
+
+				for i := 0; i < 16; i++ {
+					px, py := x+i%4, y+i/4
+					if px < width && py < height {
+						dst[(py*width+px)*channels+c] = palette[codes&7]
+					}
+					codes >>= 3
+				}
+			}
+		}
+	}
+	return dst, r.Error()
+}
+
+// redGreenToRGBA_U8 expands the one or two channel values in the range
+// [0, max] to RGBA_U8_NORM. Missing channels are filled with 0, alpha with 1.
+func redGreenToRGBA_U8(values []int, channels, max int) []byte {
+	out := make([]byte, len(values)/channels*4)
+	for i, o := 0, 0; i < len(values); i, o = i+channels, o+4 {
+		for c := 0; c < channels; c++ {
+			out[o+c] = byte((values[i+c]*255 + max/2) / max)
+		}
+		out[o+3] = 0xff
+	}
+	return out
+}
+
+// redGreenToRGBA_F32 expands the one or two channel values in the range
+// [-max, max] to RGBA_F32. Missing channels are filled with 0, alpha with 1.
+func redGreenToRGBA_F32(values []int, channels, max int) []byte {
+	out := make([]byte, len(values)/channels*4*4)
+	w := endian.Writer(bytes.NewBuffer(out[:0]), device.LittleEndian)
+	for i := 0; i < len(values); i += channels {
+		for c := 0; c < 3; c++ {
+			if c < channels {
+				w.Float32(float32(values[i+c]) / float32(max))
+			} else {
+				w.Float32(0)
+			}
+		}
+		w.Float32(1)
+	}
+	return out
+}
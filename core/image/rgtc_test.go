@@ -0,0 +1,107 @@
+// Copyright (C) 2017 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package image_test
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math"
+	"testing"
+
+	"github.com/google/gapid/core/image"
+)
+
+// eacBlock returns a big-endian EAC block with all texels using the same
+// modifier index.
+func eacBlock(base, mul, table, index uint64) []byte {
+	v := base<<56 | mul<<52 | table<<48
+	for i := uint(0); i < 16; i++ {
+		v |= index << (i * 3)
+	}
+	out := make([]byte, 8)
+	binary.BigEndian.PutUint64(out, v)
+	return out
+}
+
+func TestRedGreenDecode(t *testing.T) {
+	// Endpoints 200 and 100, texel 0 using code 0 and texel 1 using code 2,
+	// the first interpolated value.
+	bc4 := []byte{200, 100, 2 << 3, 0, 0, 0, 0, 0}
+	bc4RGBA := fillRGBA(4, 4, 200, 0, 0, 255)
+	copy(bc4RGBA[4:], []byte{(200*6 + 100) / 7})
+
+	// Same as bc4 for red, green with 6-value interpolation using code 7
+	// (always 255).
+	bc5 := append(append([]byte{}, bc4...), 10, 20, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff)
+	bc5RGBA := fillRGBA(4, 4, 200, 255, 0, 255)
+	copy(bc5RGBA[4:], []byte{(200*6 + 100) / 7})
+
+	for _, test := range []struct {
+		name     string
+		format   *image.Format
+		w, h     uint32
+		data     []byte
+		expected []byte
+	}{
+		{"bc4", image.RGTC1_BC4_R_U8_NORM, 4, 4, bc4, bc4RGBA},
+		{"bc4 clipped", image.RGTC1_BC4_R_U8_NORM, 1, 1, bc4, []byte{200, 0, 0, 255}},
+		{"bc5", image.RGTC2_BC5_RG_U8_NORM, 4, 4, bc5, bc5RGBA},
+		// base 128, multiplier 2, table 0, modifier index 4 (+2):
+		// 128*8 + 4 + 2*2*8 = 1060 of 2047.
+		{"r11", image.ETC2_R11_EAC, 4, 4, eacBlock(128, 2, 0, 4), fillRGBA(4, 4, 132, 0, 0, 255)},
+		{"rg11", image.ETC2_RG11_EAC, 4, 4, append(eacBlock(128, 2, 0, 4), eacBlock(255, 15, 0, 7)...), fillRGBA(4, 4, 132, 255, 0, 255)},
+	} {
+		in := image.Image2D{Data: test.data, Width: test.w, Height: test.h, Format: test.format}
+		out, err := in.Convert(image.RGBA_U8_NORM)
+		if err != nil {
+			t.Errorf("%v: Convert returned error: %v", test.name, err)
+			continue
+		}
+		if !bytes.Equal(out.Data, test.expected) {
+			t.Errorf("%v: decoded to %v, expected %v", test.name, out.Data, test.expected)
+		}
+	}
+}
+
+func TestSignedRedGreenDecode(t *testing.T) {
+	for _, test := range []struct {
+		name     string
+		format   *image.Format
+		data     []byte
+		expected [4]float32
+	}{
+		// -128 is treated as -127. Code 7 with v0 <= v1 is always the maximum.
+		{"bc4", image.RGTC1_BC4_R_S8_NORM, []byte{0x80, 0x7f, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff}, [4]float32{1, 0, 0, 1}},
+		{"bc5", image.RGTC2_BC5_RG_S8_NORM, []byte{0x80, 0x7f, 0, 0, 0, 0, 0, 0, 0x80, 0x7f, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff}, [4]float32{-1, 1, 0, 1}},
+		// base -128 (as -127), multiplier 15, table 0, modifier index 3 (-15):
+		// clamped to -1023.
+		{"r11", image.ETC2_SIGNED_R11_EAC, eacBlock(0x80, 15, 0, 3), [4]float32{-1, 0, 0, 1}},
+		// base 0, multiplier 0 (1/8), table 0, modifier index 4 (+2).
+		{"rg11", image.ETC2_SIGNED_RG11_EAC, append(eacBlock(0, 0, 0, 4), eacBlock(0, 0, 0, 4)...), [4]float32{2.0 / 1023, 2.0 / 1023, 0, 1}},
+	} {
+		in := image.Image2D{Data: test.data, Width: 4, Height: 4, Format: test.format}
+		out, err := in.Convert(image.RGBA_F32)
+		if err != nil {
+			t.Errorf("%v: Convert returned error: %v", test.name, err)
+			continue
+		}
+		for c, expected := range test.expected {
+			got := math.Float32frombits(binary.LittleEndian.Uint32(out.Data[c*4:]))
+			if got != expected {
+				t.Errorf("%v: channel %d decoded to %v, expected %v", test.name, c, got, expected)
+			}
+		}
+	}
+}
@@ -22,6 +22,8 @@ set(files
     adb_data_test.go
     adb_test.go
     bind.go
+    client.go
+    client_test.go
     commands.go
     commands_test.go
     device.go
@@ -38,7 +40,9 @@ set(files
     logcat_test.go
     screen.go
     screen_test.go
+    wire.go
+    wire_target.go
 )
 set(dirs
-    
+    fake
 )
@@ -84,6 +84,9 @@ func (b *binding) prepareADBCommand(cmd shell.Cmd, useShell bool) (shell.Process
 type deviceTarget struct{ b *binding }
 
 func (t deviceTarget) Start(cmd shell.Cmd) (shell.Process, error) {
+	if t.b.client != nil {
+		return t.b.startWire(cmd, false)
+	}
 	return t.b.prepareADBCommand(cmd, false)
 }
 
@@ -94,6 +97,9 @@ func (t deviceTarget) String() string {
 type shellTarget struct{ b *binding }
 
 func (t shellTarget) Start(cmd shell.Cmd) (shell.Process, error) {
+	if t.b.client != nil {
+		return t.b.startWire(cmd, true)
+	}
 	return t.b.prepareADBCommand(cmd, true)
 }
 
@@ -76,6 +76,7 @@ production_device        invalid
 
 func init() {
 	adb.ADB = file.Abs("/adb")
+	adb.DefaultClient = nil
 
 	shell.LocalTarget = stub.OneOf(
 		devices,
@@ -15,6 +15,8 @@
 package adb
 
 import (
+	"sync"
+
 	"github.com/google/gapid/core/log"
 	"github.com/google/gapid/core/os/android"
 	"github.com/google/gapid/core/os/device/bind"
@@ -52,6 +54,11 @@ func (l DeviceList) FindBySerial(serial string) Device {
 // binding represents an attached Android device.
 type binding struct {
 	bind.Simple
+	// client is used to talk to the adb server. If nil, the adb executable
+	// is used instead.
+	client       *Client
+	featuresLock sync.Mutex
+	features     map[string]bool // nil until successfully queried
 }
 
 // verify that binding implements Device
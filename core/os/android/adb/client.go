@@ -0,0 +1,546 @@
+// Copyright (C) 2017 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package adb
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/gapid/core/event/task"
+	"github.com/google/gapid/core/fault"
+	"github.com/google/gapid/core/fault/cause"
+	"github.com/google/gapid/core/log"
+	"github.com/google/gapid/core/os/device/bind"
+	"github.com/google/gapid/core/os/shell"
+)
+
+const (
+	// ErrServerUnavailable is returned when the adb server cannot be reached.
+	ErrServerUnavailable = fault.Const("Could not connect to the adb server")
+	// ErrShellExitCode is returned when a shell command exits with a non-zero
+	// exit code.
+	ErrShellExitCode = fault.Const("Shell command returned a non-zero exit code")
+
+	featureShellV2 = "shell_v2"
+
+	defaultServerAddr = "localhost:5037"
+	dialTimeout       = time.Second * 5
+	remoteFileMode    = 0100644 // S_IFREG | 0644
+)
+
+// DefaultClient is the client used to communicate with the adb server for the
+// devices returned by Devices and Monitor. If DefaultClient is nil then the adb
+// executable is run for each operation instead.
+var DefaultClient = &Client{}
+
+// Client talks to the adb server directly using the adb wire protocol, instead
+// of executing the adb binary.
+type Client struct {
+	// Addr is the address of the adb server.
+	// If empty, the address is taken from the ADB_SERVER_SOCKET or
+	// ANDROID_ADB_SERVER_PORT environment variables, falling back to
+	// localhost:5037.
+	Addr string
+}
+
+func (c *Client) addr() string {
+	if c.Addr != "" {
+		return c.Addr
+	}
+	if s := os.Getenv("ADB_SERVER_SOCKET"); strings.HasPrefix(s, "tcp:") {
+		s = strings.TrimPrefix(s, "tcp:")
+		if !strings.Contains(s, ":") {
+			s = "localhost:" + s
+		}
+		return s
+	}
+	if port := os.Getenv("ANDROID_ADB_SERVER_PORT"); port != "" {
+		return "localhost:" + port
+	}
+	return defaultServerAddr
+}
+
+// dial opens a new connection to the adb server. If the server is not running
+// and an adb executable can be found, the server is started.
+func (c *Client) dial(ctx log.Context) (*conn, error) {
+	addr := c.addr()
+	sock, err := net.DialTimeout("tcp", addr, dialTimeout)
+	if err != nil && c.Addr == "" {
+		// The server may just not be running yet. Ask adb to start it.
+		if exe, e := adb(); e == nil {
+			if e := shell.Command(exe.System(), "start-server").Run(ctx); e == nil {
+				sock, err = net.DialTimeout("tcp", addr, dialTimeout)
+			}
+		}
+	}
+	if err != nil {
+		return nil, cause.Explain(ctx, ErrServerUnavailable, err.Error()).With("Address", addr)
+	}
+	return newConn(ctx, sock), nil
+}
+
+// query sends the host service request and returns the length-prefixed reply.
+func (c *Client) query(ctx log.Context, service string) (string, error) {
+	s, err := c.dial(ctx)
+	if err != nil {
+		return "", err
+	}
+	defer s.Close()
+	if err := s.request(service); err != nil {
+		return "", cause.Explain(ctx, err, service)
+	}
+	return s.readHexString()
+}
+
+// command sends the host service request that replies with a second status
+// once the request has been performed.
+func (c *Client) command(ctx log.Context, service string) error {
+	s, err := c.dial(ctx)
+	if err != nil {
+		return err
+	}
+	defer s.Close()
+	if err := s.request(service); err != nil {
+		return cause.Explain(ctx, err, service)
+	}
+	if err := s.status(); err != nil {
+		return cause.Explain(ctx, err, service)
+	}
+	return nil
+}
+
+// open connects to the service on the device with the specified serial.
+// The returned connection must be closed after use.
+func (c *Client) open(ctx log.Context, serial, service string) (*conn, error) {
+	s, err := c.dial(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.request("host:transport:" + serial); err != nil {
+		s.Close()
+		return nil, cause.Explain(ctx, err, "Selecting transport").With("Serial", serial)
+	}
+	if err := s.request(service); err != nil {
+		s.Close()
+		return nil, cause.Explain(ctx, err, service).With("Serial", serial)
+	}
+	return s, nil
+}
+
+// Version returns the internal version number of the adb server.
+func (c *Client) Version(ctx log.Context) (int, error) {
+	res, err := c.query(ctx, "host:version")
+	if err != nil {
+		return 0, err
+	}
+	v, err := strconv.ParseInt(res, 16, 32)
+	if err != nil {
+		return 0, cause.Explain(ctx, ErrBadResponse, res)
+	}
+	return int(v), nil
+}
+
+// Devices returns the list of devices attached to the adb server.
+// Unlike the package level Devices function, the returned devices are not
+// added to the device registry.
+func (c *Client) Devices(ctx log.Context) (DeviceList, error) {
+	parsed, err := c.deviceStates(ctx)
+	if err != nil {
+		return nil, err
+	}
+	out := make(DeviceList, 0, len(parsed))
+	for serial, status := range parsed {
+		d, err := newDevice(ctx, c, serial, status)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, d)
+	}
+	return out, nil
+}
+
+// deviceStates returns the connection status of each device attached to the
+// adb server, keyed by serial.
+func (c *Client) deviceStates(ctx log.Context) (map[string]bind.Status, error) {
+	res, err := c.query(ctx, "host:devices-l")
+	if err != nil {
+		return nil, err
+	}
+	return parseDeviceStates(res), nil
+}
+
+// TrackDevices calls f with the states of all the devices attached to the adb
+// server each time any of them change. TrackDevices blocks until the context
+// is stopped or the connection to the server is lost.
+func (c *Client) TrackDevices(ctx log.Context, f func(map[string]bind.Status)) error {
+	s, err := c.dial(ctx)
+	if err != nil {
+		return err
+	}
+	defer s.Close()
+	if err := s.request("host:track-devices"); err != nil {
+		return cause.Explain(ctx, err, "host:track-devices")
+	}
+	for {
+		res, err := s.readHexString()
+		if err != nil {
+			if task.Stopped(ctx) {
+				return nil
+			}
+			return err
+		}
+		f(parseDeviceStates(res))
+	}
+}
+
+// Features returns the set of features supported by both the adb server and
+// the device with the specified serial.
+func (c *Client) Features(ctx log.Context, serial string) (map[string]bool, error) {
+	res, err := c.query(ctx, "host-serial:"+serial+":features")
+	if err != nil {
+		return nil, err
+	}
+	out := map[string]bool{}
+	for _, f := range strings.Split(res, ",") {
+		if f = strings.TrimSpace(f); f != "" {
+			out[f] = true
+		}
+	}
+	return out, nil
+}
+
+// Forward forwards the local port specification to the device port
+// specification.
+func (c *Client) Forward(ctx log.Context, serial, local, device string) error {
+	return c.command(ctx, fmt.Sprintf("host-serial:%s:forward:%s;%s", serial, local, device))
+}
+
+// RemoveForward removes a port forward made by Forward.
+func (c *Client) RemoveForward(ctx log.Context, serial, local string) error {
+	return c.command(ctx, fmt.Sprintf("host-serial:%s:killforward:%s", serial, local))
+}
+
+// Root restarts adbd on the device as root, returning the message reported
+// by the device.
+func (c *Client) Root(ctx log.Context, serial string) (string, error) {
+	s, err := c.open(ctx, serial, "root:")
+	if err != nil {
+		return "", err
+	}
+	defer s.Close()
+	out, err := ioutil.ReadAll(s)
+	return strings.TrimSpace(string(out)), err
+}
+
+// Push copies the local file or directory to the remote path on the device.
+func (c *Client) Push(ctx log.Context, serial, local, remote string) error {
+	s, err := c.open(ctx, serial, "sync:")
+	if err != nil {
+		return err
+	}
+	defer s.Close()
+	defer s.writeSync(syncQuit, nil)
+
+	if mode, _, err := stat(s, remote); err == nil && mode&syncModeDir != 0 {
+		remote = path.Join(remote, filepath.Base(local))
+	}
+
+	return filepath.Walk(local, func(p string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() {
+			return err
+		}
+		rel, err := filepath.Rel(local, p)
+		if err != nil {
+			return err
+		}
+		dst := remote
+		if rel != "." {
+			dst = path.Join(remote, filepath.ToSlash(rel))
+		}
+		if err := send(s, p, dst, info); err != nil {
+			return cause.Explain(ctx, err, "Pushing file").With("Local", p).With("Remote", dst)
+		}
+		return nil
+	})
+}
+
+// Pull copies the remote file on the device to the local path.
+func (c *Client) Pull(ctx log.Context, serial, remote, local string) error {
+	s, err := c.open(ctx, serial, "sync:")
+	if err != nil {
+		return err
+	}
+	defer s.Close()
+	defer s.writeSync(syncQuit, nil)
+
+	if info, err := os.Stat(local); err == nil && info.IsDir() {
+		local = filepath.Join(local, path.Base(remote))
+	}
+	f, err := os.Create(local)
+	if err != nil {
+		return err
+	}
+	if err := recv(s, remote, f); err != nil {
+		f.Close()
+		os.Remove(local)
+		return cause.Explain(ctx, err, "Pulling file").With("Local", local).With("Remote", remote)
+	}
+	return f.Close()
+}
+
+// Shell runs the command in the shell of the device with the specified serial,
+// blocking until the command completes or the context is stopped.
+// If the device supports the shell protocol (v2) then stdout and stderr are
+// reported separately and a non-zero exit code is returned as an error.
+// Shell does not wait for stdin to reach EOF.
+func (c *Client) Shell(ctx log.Context, serial, command string, stdin io.Reader, stdout, stderr io.Writer) error {
+	features, err := c.Features(ctx, serial)
+	if err != nil {
+		return err
+	}
+	return c.shell(ctx, serial, features[featureShellV2], command, stdin, stdout, stderr)
+}
+
+func (c *Client) shell(ctx log.Context, serial string, v2 bool, command string, stdin io.Reader, stdout, stderr io.Writer) error {
+	if stdout == nil {
+		stdout = ioutil.Discard
+	}
+	if stderr == nil {
+		stderr = ioutil.Discard
+	}
+
+	if !v2 {
+		// Legacy shell: no exit codes and stderr is interleaved with stdout.
+		s, err := c.open(ctx, serial, "shell:"+command)
+		if err != nil {
+			return err
+		}
+		defer s.Close()
+		if stdin != nil {
+			// The copy is not waited for, as stdin may never reach EOF.
+			// Once the deferred Close has run, the copy fails on its next
+			// write and returns.
+			go io.Copy(s, stdin)
+		}
+		_, err = io.Copy(stdout, s)
+		if task.Stopped(ctx) {
+			return task.StopReason(ctx)
+		}
+		return err
+	}
+
+	s, err := c.open(ctx, serial, "shell,v2,raw:"+command)
+	if err != nil {
+		return err
+	}
+	defer s.Close()
+	if stdin != nil {
+		// As with the legacy shell, the copy is not waited for. It returns
+		// when stdin next returns after the connection has been closed.
+		go func() {
+			buf := make([]byte, shellMaxPacketSize)
+			for {
+				n, err := stdin.Read(buf)
+				select {
+				case <-s.done:
+					return // The shell has completed.
+				default:
+				}
+				if n > 0 {
+					if s.writeShell(shellStdin, buf[:n]) != nil {
+						return
+					}
+				}
+				if err != nil {
+					s.writeShell(shellCloseStdin, nil)
+					return
+				}
+			}
+		}()
+	}
+	for {
+		id, data, err := s.readShell()
+		if err != nil {
+			if task.Stopped(ctx) {
+				return task.StopReason(ctx)
+			}
+			return cause.Explain(ctx, err, "Shell connection closed before exit")
+		}
+		switch id {
+		case shellStdout:
+			if _, err := stdout.Write(data); err != nil {
+				return err
+			}
+		case shellStderr:
+			if _, err := stderr.Write(data); err != nil {
+				return err
+			}
+		case shellExit:
+			if len(data) > 0 && data[0] != 0 {
+				return cause.Explain(ctx, ErrShellExitCode, command).With("Code", int(data[0]))
+			}
+			return nil
+		}
+	}
+}
+
+const syncModeDir = 0040000 // S_IFDIR
+
+// stat returns the mode and size of the remote file.
+func stat(s *conn, remote string) (mode, size uint32, err error) {
+	if err := s.writeSync(syncStat, []byte(remote)); err != nil {
+		return 0, 0, err
+	}
+	id, mode, err := s.readSync()
+	if err != nil {
+		return 0, 0, err
+	}
+	if id != syncStat {
+		return 0, 0, fmt.Errorf("%v: %q", ErrBadResponse, id)
+	}
+	var rest [8]byte
+	if _, err := io.ReadFull(s, rest[:]); err != nil {
+		return 0, 0, err
+	}
+	size = binary.LittleEndian.Uint32(rest[:4])
+	if mode == 0 {
+		return 0, 0, os.ErrNotExist
+	}
+	return mode, size, nil
+}
+
+// send writes the local file to the remote path using the sync protocol.
+func send(s *conn, local, remote string, info os.FileInfo) error {
+	f, err := os.Open(local)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	mode := uint32(remoteFileMode)
+	if info.Mode()&0111 != 0 {
+		mode |= 0111
+	}
+	if err := s.writeSync(syncSend, []byte(fmt.Sprintf("%s,%d", remote, mode))); err != nil {
+		return err
+	}
+	buf := make([]byte, maxSyncData)
+	for {
+		n, err := f.Read(buf)
+		if n > 0 {
+			if err := s.writeSync(syncData, buf[:n]); err != nil {
+				return err
+			}
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+	}
+	if err := s.writeSyncValue(syncDone, uint32(info.ModTime().Unix())); err != nil {
+		return err
+	}
+	id, n, err := s.readSync()
+	switch {
+	case err != nil:
+		return err
+	case id == syncOkay:
+		return nil
+	case id == syncFail:
+		return s.readSyncFail(n)
+	default:
+		return fmt.Errorf("%v: %q", ErrBadResponse, id)
+	}
+}
+
+// recv reads the remote file using the sync protocol, writing it to w.
+func recv(s *conn, remote string, w io.Writer) error {
+	if err := s.writeSync(syncRecv, []byte(remote)); err != nil {
+		return err
+	}
+	for {
+		id, n, err := s.readSync()
+		if err != nil {
+			return err
+		}
+		switch id {
+		case syncData:
+			if _, err := io.CopyN(w, s, int64(n)); err != nil {
+				return err
+			}
+		case syncDone:
+			return nil
+		case syncFail:
+			return s.readSyncFail(n)
+		default:
+			return fmt.Errorf("%v: %q", ErrBadResponse, id)
+		}
+	}
+}
+
+// parseDeviceStates parses the response to the host:devices, host:devices-l
+// and host:track-devices requests.
+func parseDeviceStates(out string) map[string]bind.Status {
+	devices := map[string]bind.Status{}
+	for _, line := range strings.Split(out, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 2 {
+			continue
+		}
+		serial, state := fields[0], []string{}
+		for _, f := range fields[1:] {
+			// Skip the long-form 'key:value' details.
+			if i := strings.IndexRune(f, ':'); i > 0 && isDeviceDetail(f[:i]) {
+				continue
+			}
+			state = append(state, f)
+		}
+		devices[serial] = deviceStatus(strings.Join(state, " "))
+	}
+	return devices
+}
+
+func isDeviceDetail(key string) bool {
+	switch key {
+	case "usb", "product", "model", "device", "transport_id", "features":
+		return true
+	}
+	return false
+}
+
+// deviceStatus converts an adb connection state string into a bind.Status.
+func deviceStatus(state string) bind.Status {
+	switch {
+	case state == "device":
+		return bind.Status_Online
+	case state == "offline":
+		return bind.Status_Offline
+	case state == "unauthorized", strings.HasPrefix(state, "no permissions"):
+		return bind.Status_Unauthorized
+	default:
+		return bind.Status_Unknown
+	}
+}
@@ -0,0 +1,283 @@
+// Copyright (C) 2017 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package adb_test
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/google/gapid/core/assert"
+	"github.com/google/gapid/core/context/jot"
+	"github.com/google/gapid/core/event/task"
+	"github.com/google/gapid/core/log"
+	"github.com/google/gapid/core/os/android/adb"
+	"github.com/google/gapid/core/os/android/adb/fake"
+	"github.com/google/gapid/core/os/device"
+	"github.com/google/gapid/core/os/device/bind"
+)
+
+func newFakeDevice(serial string) *fake.Device {
+	return &fake.Device{
+		Serial: serial,
+		Props: map[string]string{
+			"ro.build.product":         "hammerhead",
+			"ro.build.version.release": "6.0.1",
+			"ro.build.description":     "hammerhead-user 6.0.1 MMB29Q 2480792 release-keys",
+			"ro.product.cpu.abi":       "armeabi-v7a",
+		},
+		Shell: func(cmd string, stdin io.Reader, stdout, stderr io.Writer) int {
+			switch cmd {
+			case "echo hello":
+				fmt.Fprintln(stdout, "hello")
+				return 0
+			case "cat":
+				io.Copy(stdout, stdin)
+				return 0
+			case "fail":
+				fmt.Fprintln(stderr, "oh no")
+				return 3
+			}
+			fmt.Fprintf(stderr, "/system/bin/sh: %v: not found\n", cmd)
+			return 127
+		},
+	}
+}
+
+func startFakeServer(ctx log.Context, devices ...*fake.Device) (*fake.Server, *adb.Client) {
+	s, err := fake.New(devices...)
+	if err != nil {
+		jot.Fatal(ctx, err, "Couldn't start fake adb server")
+	}
+	return s, &adb.Client{Addr: s.Addr()}
+}
+
+func mustFind(ctx log.Context, c *adb.Client, serial string) adb.Device {
+	devices, err := c.Devices(ctx)
+	if err != nil {
+		jot.Fatal(ctx, err, "Couldn't get devices")
+	}
+	d := devices.FindBySerial(serial)
+	if d == nil {
+		jot.Fatalf(ctx, nil, "Couldn't find device '%v'", serial)
+	}
+	return d
+}
+
+func TestClientDevices(t_ *testing.T) {
+	ctx := log.Testing(t_)
+	s, c := startFakeServer(ctx, newFakeDevice("wire_device"), &fake.Device{Serial: "offline_device", State: "offline"})
+	defer s.Close()
+
+	version, err := c.Version(ctx)
+	assert.For(ctx, "Version").ThatError(err).Succeeded()
+	assert.For(ctx, "Version").ThatInteger(version).Equals(0x29)
+
+	devices, err := c.Devices(ctx)
+	assert.For(ctx, "Devices").ThatError(err).Succeeded()
+	assert.For(ctx, "Devices").ThatSlice(devices).IsLength(2)
+	assert.For(ctx, "Offline status").That(devices.FindBySerial("offline_device").Status()).Equals(bind.Status_Offline)
+
+	d := devices.FindBySerial("wire_device")
+	assert.For(ctx, "Online status").That(d.Status()).Equals(bind.Status_Online)
+	assert.For(ctx, "Instance").That(d.Instance()).DeepEquals(&device.Instance{
+		Serial: "wire_device",
+		Configuration: &device.Configuration{
+			OS: &device.OS{
+				Kind:  device.Android,
+				Name:  "Marshmallow",
+				Build: "hammerhead-user 6.0.1 MMB29Q 2480792 release-keys",
+				Major: 6,
+				Minor: 0,
+				Point: 1,
+			},
+			Hardware: &device.Hardware{
+				Name: "hammerhead",
+			},
+			ABIs: []*device.ABI{device.AndroidARMv7a},
+		},
+	})
+}
+
+func TestClientTrackDevices(t_ *testing.T) {
+	ctx := log.Testing(t_)
+	s, c := startFakeServer(ctx, newFakeDevice("first_device"))
+	defer s.Close()
+
+	ctx, cancel := task.WithCancel(ctx)
+	updates := make(chan map[string]bind.Status, 8)
+	done := make(chan error, 1)
+	go func() { done <- c.TrackDevices(ctx, func(d map[string]bind.Status) { updates <- d }) }()
+
+	next := func() map[string]bind.Status {
+		select {
+		case u := <-updates:
+			return u
+		case <-time.After(5 * time.Second):
+			jot.Fatal(ctx, nil, "Timeout waiting for device update")
+			return nil
+		}
+	}
+
+	assert.For(ctx, "Initial").That(next()).DeepEquals(map[string]bind.Status{
+		"first_device": bind.Status_Online,
+	})
+	s.AddDevice(&fake.Device{Serial: "second_device", State: "unauthorized"})
+	assert.For(ctx, "Added").That(next()).DeepEquals(map[string]bind.Status{
+		"first_device":  bind.Status_Online,
+		"second_device": bind.Status_Unauthorized,
+	})
+	s.RemoveDevice("first_device")
+	assert.For(ctx, "Removed").That(next()).DeepEquals(map[string]bind.Status{
+		"second_device": bind.Status_Unauthorized,
+	})
+
+	cancel()
+	assert.For(ctx, "Cancelled").ThatError(<-done).Succeeded()
+}
+
+func TestClientShell(t_ *testing.T) {
+	ctx := log.Testing(t_)
+	legacy := newFakeDevice("legacy_device")
+	legacy.Features = []string{}
+	s, c := startFakeServer(ctx, newFakeDevice("wire_device"), legacy)
+	defer s.Close()
+	d := mustFind(ctx, c, "wire_device")
+
+	out, err := d.Shell("echo", "hello").Call(ctx)
+	assert.For(ctx, "Shell output").ThatError(err).Succeeded()
+	assert.For(ctx, "Shell output").ThatString(out).Equals("hello")
+
+	out, err = d.Shell("cat").Read(bytes.NewBufferString("piped input")).Call(ctx)
+	assert.For(ctx, "Shell stdin").ThatError(err).Succeeded()
+	assert.For(ctx, "Shell stdin").ThatString(out).Equals("piped input")
+
+	stdout, stderr := &bytes.Buffer{}, &bytes.Buffer{}
+	err = d.Shell("fail").Capture(stdout, stderr).Run(ctx)
+	assert.For(ctx, "Shell exit code").ThatError(err).HasCause(adb.ErrShellExitCode)
+	assert.For(ctx, "Shell stdout").ThatString(stdout.String()).Equals("")
+	assert.For(ctx, "Shell stderr").ThatString(stderr.String()).Equals("oh no\n")
+
+	out, err = d.Command("shell", "echo", "hello").Call(ctx)
+	assert.For(ctx, "adb shell").ThatError(err).Succeeded()
+	assert.For(ctx, "adb shell").ThatString(out).Equals("hello")
+
+	l := mustFind(ctx, c, "legacy_device")
+	out, err = l.Shell("fail").Call(ctx)
+	assert.For(ctx, "Legacy shell").ThatError(err).Succeeded()
+	assert.For(ctx, "Legacy shell").ThatString(out).Equals("oh no")
+
+	// Commands complete without waiting for stdin to reach EOF.
+	stdin, w := io.Pipe()
+	defer w.Close()
+	for name, dev := range map[string]adb.Device{"Shell": d, "Legacy shell": l} {
+		out, err = dev.Shell("echo", "hello").Read(stdin).Call(ctx)
+		assert.For(ctx, "%s open stdin", name).ThatError(err).Succeeded()
+		assert.For(ctx, "%s open stdin", name).ThatString(out).Equals("hello")
+	}
+}
+
+func TestClientPushPull(t_ *testing.T) {
+	ctx := log.Testing(t_)
+	fd := newFakeDevice("wire_device")
+	s, c := startFakeServer(ctx, fd)
+	defer s.Close()
+	d := mustFind(ctx, c, "wire_device")
+
+	tmp, err := ioutil.TempDir("", "adb_test")
+	if err != nil {
+		jot.Fatal(ctx, err, "Couldn't create temporary directory")
+	}
+	defer os.RemoveAll(tmp)
+
+	// Large enough to be split over multiple sync DATA packets.
+	data := bytes.Repeat([]byte("0123456789abcdef"), 5000)
+	local := filepath.Join(tmp, "local_file")
+	ioutil.WriteFile(local, data, 0666)
+
+	err = d.Push(ctx, local, "/sdcard/remote_file")
+	assert.For(ctx, "Push").ThatError(err).Succeeded()
+	got, _ := fd.File("/sdcard/remote_file")
+	assert.For(ctx, "Pushed data").ThatSlice(got).Equals(data)
+
+	pulled := filepath.Join(tmp, "pulled_file")
+	err = d.Pull(ctx, "/sdcard/remote_file", pulled)
+	assert.For(ctx, "Pull").ThatError(err).Succeeded()
+	got, _ = ioutil.ReadFile(pulled)
+	assert.For(ctx, "Pulled data").ThatSlice(got).Equals(data)
+
+	err = d.Pull(ctx, "/sdcard/missing_file", pulled)
+	assert.For(ctx, "Pull missing").ThatError(err).Failed()
+}
+
+func TestClientForward(t_ *testing.T) {
+	ctx := log.Testing(t_)
+	s, c := startFakeServer(ctx, newFakeDevice("wire_device"))
+	defer s.Close()
+	d := mustFind(ctx, c, "wire_device")
+
+	err := d.Forward(ctx, adb.TCPPort(1234), adb.NamedAbstractSocket("gapii"))
+	assert.For(ctx, "Forward").ThatError(err).Succeeded()
+	assert.For(ctx, "Forwards").That(s.Forwards()).DeepEquals(map[string]string{
+		"tcp:1234": "localabstract:gapii",
+	})
+
+	err = d.RemoveForward(ctx, adb.TCPPort(1234))
+	assert.For(ctx, "RemoveForward").ThatError(err).Succeeded()
+	assert.For(ctx, "Forwards").That(s.Forwards()).DeepEquals(map[string]string{})
+
+	err = d.RemoveForward(ctx, adb.TCPPort(1234))
+	assert.For(ctx, "RemoveForward missing").ThatError(err).Failed()
+}
+
+func TestClientRoot(t_ *testing.T) {
+	ctx := log.Testing(t_)
+	production := newFakeDevice("production_device")
+	production.Production = true
+	s, c := startFakeServer(ctx, newFakeDevice("debug_device"), production)
+	defer s.Close()
+
+	err := mustFind(ctx, c, "debug_device").Root(ctx)
+	assert.For(ctx, "Root").ThatError(err).Succeeded()
+
+	err = mustFind(ctx, c, "production_device").Root(ctx)
+	assert.For(ctx, "Root production").ThatError(err).Equals(adb.ErrDeviceNotRooted)
+}
+
+func TestClientInstallAPK(t_ *testing.T) {
+	ctx := log.Testing(t_)
+	fd := newFakeDevice("wire_device")
+	s, c := startFakeServer(ctx, fd)
+	defer s.Close()
+	d := mustFind(ctx, c, "wire_device")
+
+	tmp, err := ioutil.TempDir("", "adb_test")
+	if err != nil {
+		jot.Fatal(ctx, err, "Couldn't create temporary directory")
+	}
+	defer os.RemoveAll(tmp)
+	apk := filepath.Join(tmp, "app.apk")
+	ioutil.WriteFile(apk, []byte("not really an apk"), 0666)
+
+	err = d.InstallAPK(ctx, apk, true, true)
+	assert.For(ctx, "InstallAPK").ThatError(err).Succeeded()
+	_, found := fd.File("/data/local/tmp/app.apk")
+	assert.For(ctx, "Staged APK removed").That(found).Equals(false)
+}
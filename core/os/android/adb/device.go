@@ -20,6 +20,7 @@ import (
 	"sync"
 	"time"
 
+	"github.com/google/gapid/core/context/jot"
 	"github.com/google/gapid/core/event/task"
 	"github.com/google/gapid/core/fault"
 	"github.com/google/gapid/core/fault/cause"
@@ -67,6 +68,9 @@ func RegisterDeviceInfoProvider(f DeviceInfoProvider) {
 
 // Monitor updates the registry with devices that are added and removed at the
 // specified interval. Monitor returns once the context is cancelled.
+// If DefaultClient is not nil then device changes are tracked as they are
+// reported by the adb server, and interval is used as the retry delay if the
+// connection to the server is lost.
 func Monitor(ctx log.Context, r *bind.Registry, interval time.Duration) error {
 	unlisten := registry.Listen(bind.NewDeviceListener(r.AddDevice, r.RemoveDevice))
 	defer unlisten()
@@ -79,6 +83,16 @@ func Monitor(ctx log.Context, r *bind.Registry, interval time.Duration) error {
 		if err := scanDevices(ctx); err != nil {
 			return cause.Explain(ctx, err, "Couldn't scan devices")
 		}
+		if c := DefaultClient; c != nil {
+			err := c.TrackDevices(ctx, func(parsed map[string]bind.Status) {
+				if err := updateDevices(ctx, c, parsed); err != nil {
+					jot.Fail(ctx, err, "Couldn't update devices")
+				}
+			})
+			if err != nil {
+				jot.Fail(ctx, err, "Lost connection to the adb server")
+			}
+		}
 		select {
 		case <-task.ShouldStop(ctx):
 			return nil
@@ -100,8 +114,9 @@ func Devices(ctx log.Context) (DeviceList, error) {
 	return out, nil
 }
 
-func newDevice(ctx log.Context, serial string, status bind.Status) (*binding, error) {
+func newDevice(ctx log.Context, client *Client, serial string, status bind.Status) (*binding, error) {
 	d := &binding{
+		client: client,
 		Simple: bind.Simple{
 			To: &device.Instance{
 				Serial:        serial,
@@ -162,6 +177,14 @@ func newDevice(ctx log.Context, serial string, status bind.Status) (*binding, er
 
 // scanDevices returns the list of attached Android devices.
 func scanDevices(ctx log.Context) error {
+	if c := DefaultClient; c != nil {
+		parsed, err := c.deviceStates(ctx)
+		if err != nil {
+			return err
+		}
+		return updateDevices(ctx, c, parsed)
+	}
+
 	exe, err := adb()
 	if err != nil {
 		return cause.Explain(ctx, err, "")
@@ -174,14 +197,20 @@ func scanDevices(ctx log.Context) error {
 	if err != nil {
 		return err
 	}
+	return updateDevices(ctx, nil, parsed)
+}
 
+// updateDevices updates the device cache and registry with the parsed device
+// list, using client to communicate with any new devices.
+func updateDevices(ctx log.Context, client *Client, parsed map[string]bind.Status) error {
 	cacheMutex.Lock()
 	defer cacheMutex.Unlock()
 
 	for serial, status := range parsed {
 		device, ok := cache[serial]
 		if !ok {
-			device, err = newDevice(ctx, serial, status)
+			var err error
+			device, err = newDevice(ctx, client, serial, status)
 			if err != nil {
 				return err
 			}
@@ -0,0 +1,114 @@
+// Copyright (C) 2017 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fake
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+)
+
+// Shell protocol (v2) packet identifiers.
+const (
+	shellStdin      = 0
+	shellStdout     = 1
+	shellStderr     = 2
+	shellExit       = 3
+	shellCloseStdin = 4
+)
+
+// conn is the server side of a connection from an adb client.
+type conn struct {
+	net.Conn
+}
+
+func (c conn) okay() error {
+	_, err := io.WriteString(c, "OKAY")
+	return err
+}
+
+func (c conn) fail(msg string) error {
+	if _, err := io.WriteString(c, "FAIL"); err != nil {
+		return err
+	}
+	return c.writeHexString(msg)
+}
+
+func (c conn) writeHexString(s string) error {
+	_, err := fmt.Fprintf(c, "%04x%s", len(s), s)
+	return err
+}
+
+func (c conn) readHexString() (string, error) {
+	var size [4]byte
+	if _, err := io.ReadFull(c, size[:]); err != nil {
+		return "", err
+	}
+	n, err := strconv.ParseUint(string(size[:]), 16, 16)
+	if err != nil {
+		return "", err
+	}
+	buf := make([]byte, n)
+	if _, err := io.ReadFull(c, buf); err != nil {
+		return "", err
+	}
+	return string(buf), nil
+}
+
+func (c conn) writeSync(id string, value uint32) error {
+	var buf [8]byte
+	copy(buf[:], id)
+	binary.LittleEndian.PutUint32(buf[4:], value)
+	_, err := c.Write(buf[:])
+	return err
+}
+
+func (c conn) writeUint32(value uint32) error {
+	var buf [4]byte
+	binary.LittleEndian.PutUint32(buf[:], value)
+	_, err := c.Write(buf[:])
+	return err
+}
+
+func (c conn) readSync() (string, uint32, error) {
+	var buf [8]byte
+	if _, err := io.ReadFull(c, buf[:]); err != nil {
+		return "", 0, err
+	}
+	return string(buf[:4]), binary.LittleEndian.Uint32(buf[4:]), nil
+}
+
+func (c conn) writeShell(id byte, data []byte) error {
+	buf := make([]byte, 5, 5+len(data))
+	buf[0] = id
+	binary.LittleEndian.PutUint32(buf[1:], uint32(len(data)))
+	buf = append(buf, data...)
+	_, err := c.Write(buf)
+	return err
+}
+
+func (c conn) readShell() (byte, []byte, error) {
+	var header [5]byte
+	if _, err := io.ReadFull(c, header[:]); err != nil {
+		return 0, nil, err
+	}
+	data := make([]byte, binary.LittleEndian.Uint32(header[1:]))
+	if _, err := io.ReadFull(c, data); err != nil {
+		return 0, nil, err
+	}
+	return header[0], data, nil
+}
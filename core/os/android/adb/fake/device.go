@@ -0,0 +1,143 @@
+// Copyright (C) 2017 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fake
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+)
+
+// ShellHandler is the function used to respond to shell commands sent to a
+// Device. It returns the exit code of the command.
+type ShellHandler func(cmd string, stdin io.Reader, stdout, stderr io.Writer) int
+
+// Device is a fake device attached to the fake adb server.
+type Device struct {
+	// Serial is the device serial.
+	Serial string
+	// State is the adb connection state of the device, for example "device",
+	// "offline" or "unauthorized". Defaults to "device" if empty.
+	State string
+	// Features is the list of adb features supported by the device.
+	// If nil, the device supports "shell_v2".
+	Features []string
+	// Props is the map of system properties returned by getprop.
+	Props map[string]string
+	// Files is the device file system used by the sync service.
+	Files map[string][]byte
+	// Shell is called for shell commands not handled by the fake device.
+	Shell ShellHandler
+	// Production makes the device refuse to restart adbd as root.
+	Production bool
+	// Rooted is true if adbd is running as root.
+	Rooted bool
+
+	mutex sync.Mutex
+}
+
+func (d *Device) state() string {
+	if d.State == "" {
+		return "device"
+	}
+	return d.State
+}
+
+func (d *Device) features() []string {
+	if d.Features == nil {
+		return []string{"shell_v2"}
+	}
+	return d.Features
+}
+
+// File returns the content of the file at path on the device.
+func (d *Device) File(path string) ([]byte, bool) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	data, ok := d.Files[path]
+	return data, ok
+}
+
+func (d *Device) putFile(path string, data []byte) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	if d.Files == nil {
+		d.Files = map[string][]byte{}
+	}
+	d.Files[path] = data
+}
+
+func (d *Device) removeFile(path string) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	delete(d.Files, path)
+}
+
+// stat returns the mode and size of the file or directory at path.
+func (d *Device) stat(path string) (mode, size uint32) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	if data, ok := d.Files[path]; ok {
+		return 0100644, uint32(len(data))
+	}
+	dir := strings.TrimSuffix(path, "/") + "/"
+	for p := range d.Files {
+		if strings.HasPrefix(p, dir) {
+			return 0040755, 0
+		}
+	}
+	return 0, 0
+}
+
+// root restarts the fake adbd as root, returning the message adbd reports.
+func (d *Device) root() string {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	switch {
+	case d.Production:
+		return "adbd cannot run as root in production builds\n"
+	case d.Rooted:
+		return "adbd is already running as root\n"
+	default:
+		d.Rooted = true
+		return "restarting adbd as root\n"
+	}
+}
+
+// shell runs the shell command, returning its exit code.
+func (d *Device) shell(cmd string, stdin io.Reader, stdout, stderr io.Writer) int {
+	args := strings.Fields(cmd)
+	switch {
+	case len(args) == 2 && args[0] == "getprop":
+		fmt.Fprintln(stdout, d.Props[args[1]])
+		return 0
+	case len(args) == 3 && args[0] == "rm" && args[1] == "-f":
+		d.removeFile(args[2])
+		return 0
+	case len(args) >= 3 && args[0] == "pm" && args[1] == "install":
+		if _, ok := d.File(args[len(args)-1]); !ok {
+			fmt.Fprintln(stdout, "Failure [INSTALL_FAILED_INVALID_URI]")
+			return 1
+		}
+		fmt.Fprintln(stdout, "Success")
+		return 0
+	}
+	if d.Shell != nil {
+		return d.Shell(cmd, stdin, stdout, stderr)
+	}
+	fmt.Fprintf(stderr, "/system/bin/sh: %v: not found\n", args[0])
+	return 127
+}
@@ -0,0 +1,360 @@
+// Copyright (C) 2017 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fake
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"sync"
+)
+
+// serverVersion is the adb server protocol version reported by host:version.
+const serverVersion = 0x29
+
+// Server is a fake adb server listening on a local TCP port.
+type Server struct {
+	listener net.Listener
+	mutex    sync.Mutex
+	devices  []*Device
+	forwards map[string]string
+	changed  chan struct{} // closed and replaced when the device list changes
+}
+
+// New starts a new fake adb server with the given attached devices.
+// The server must be closed with Close after use.
+func New(devices ...*Device) (*Server, error) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, err
+	}
+	s := &Server{
+		listener: l,
+		devices:  devices,
+		forwards: map[string]string{},
+		changed:  make(chan struct{}),
+	}
+	go s.serve()
+	return s, nil
+}
+
+// Addr returns the address the server is listening on.
+func (s *Server) Addr() string {
+	return s.listener.Addr().String()
+}
+
+// Close stops the server.
+func (s *Server) Close() error {
+	return s.listener.Close()
+}
+
+// AddDevice attaches the device to the server.
+func (s *Server) AddDevice(d *Device) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.devices = append(s.devices, d)
+	s.notify()
+}
+
+// RemoveDevice detaches the device with the specified serial from the server.
+func (s *Server) RemoveDevice(serial string) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	for i, d := range s.devices {
+		if d.Serial == serial {
+			s.devices = append(s.devices[:i], s.devices[i+1:]...)
+			break
+		}
+	}
+	s.notify()
+}
+
+// Forwards returns the active port forwards as a map of local to device port
+// specifications.
+func (s *Server) Forwards() map[string]string {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	out := make(map[string]string, len(s.forwards))
+	for k, v := range s.forwards {
+		out[k] = v
+	}
+	return out
+}
+
+// notify wakes all track-devices listeners. s.mutex must be held.
+func (s *Server) notify() {
+	close(s.changed)
+	s.changed = make(chan struct{})
+}
+
+func (s *Server) device(serial string) *Device {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	for _, d := range s.devices {
+		if d.Serial == serial {
+			return d
+		}
+	}
+	return nil
+}
+
+// deviceList returns the device list in the host:devices format, along with
+// the channel that is closed when the list next changes.
+func (s *Server) deviceList(long bool) (string, chan struct{}) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	buf := &bytes.Buffer{}
+	for i, d := range s.devices {
+		if long {
+			fmt.Fprintf(buf, "%-22s %s product:fake model:fake device:fake transport_id:%d\n", d.Serial, d.state(), i+1)
+		} else {
+			fmt.Fprintf(buf, "%s\t%s\n", d.Serial, d.state())
+		}
+	}
+	return buf.String(), s.changed
+}
+
+func (s *Server) serve() {
+	for {
+		c, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+		go func() {
+			defer c.Close()
+			s.handle(conn{c})
+		}()
+	}
+}
+
+func (s *Server) handle(c conn) {
+	var transport *Device
+	for {
+		req, err := c.readHexString()
+		if err != nil {
+			return
+		}
+		if transport != nil {
+			s.handleTransport(c, transport, req)
+			return
+		}
+		switch {
+		case req == "host:version":
+			c.okay()
+			c.writeHexString(fmt.Sprintf("%04x", serverVersion))
+			return
+		case req == "host:devices", req == "host:devices-l":
+			list, _ := s.deviceList(req == "host:devices-l")
+			c.okay()
+			c.writeHexString(list)
+			return
+		case req == "host:track-devices":
+			c.okay()
+			for {
+				list, changed := s.deviceList(false)
+				if c.writeHexString(list) != nil {
+					return
+				}
+				<-changed
+			}
+		case strings.HasPrefix(req, "host:transport:"):
+			serial := strings.TrimPrefix(req, "host:transport:")
+			if transport = s.device(serial); transport == nil {
+				c.fail(fmt.Sprintf("device '%s' not found", serial))
+				return
+			}
+			c.okay()
+		case strings.HasPrefix(req, "host-serial:"):
+			s.handleHostSerial(c, strings.TrimPrefix(req, "host-serial:"))
+			return
+		default:
+			c.fail("unknown host service")
+			return
+		}
+	}
+}
+
+func (s *Server) handleHostSerial(c conn, req string) {
+	var d *Device
+	s.mutex.Lock()
+	for _, e := range s.devices {
+		if strings.HasPrefix(req, e.Serial+":") {
+			d = e
+		}
+	}
+	s.mutex.Unlock()
+	if d == nil {
+		c.fail("device not found")
+		return
+	}
+	req = strings.TrimPrefix(req, d.Serial+":")
+	switch {
+	case req == "features":
+		c.okay()
+		c.writeHexString(strings.Join(d.features(), ","))
+	case strings.HasPrefix(req, "forward:"):
+		parts := strings.SplitN(strings.TrimPrefix(req, "forward:"), ";", 2)
+		if len(parts) != 2 {
+			c.fail("malformed forward spec")
+			return
+		}
+		s.mutex.Lock()
+		s.forwards[parts[0]] = parts[1]
+		s.mutex.Unlock()
+		c.okay()
+		c.okay()
+	case strings.HasPrefix(req, "killforward:"):
+		local := strings.TrimPrefix(req, "killforward:")
+		s.mutex.Lock()
+		_, found := s.forwards[local]
+		delete(s.forwards, local)
+		s.mutex.Unlock()
+		if !found {
+			c.fail(fmt.Sprintf("listener '%s' not found", local))
+			return
+		}
+		c.okay()
+		c.okay()
+	default:
+		c.fail("unknown host service")
+	}
+}
+
+func (s *Server) handleTransport(c conn, d *Device, req string) {
+	switch {
+	case strings.HasPrefix(req, "shell,v2,raw:"), strings.HasPrefix(req, "shell,v2:"):
+		c.okay()
+		serveShellV2(c, d, req[strings.IndexRune(req, ':')+1:])
+	case strings.HasPrefix(req, "shell:"):
+		c.okay()
+		d.shell(strings.TrimPrefix(req, "shell:"), &bytes.Buffer{}, c, c)
+	case req == "sync:":
+		c.okay()
+		serveSync(c, d)
+	case req == "root:":
+		c.okay()
+		io.WriteString(c, d.root())
+	default:
+		c.fail("unknown device service")
+	}
+}
+
+func serveShellV2(c conn, d *Device, cmd string) {
+	stdin, w := io.Pipe()
+	go func() {
+		for {
+			id, data, err := c.readShell()
+			switch {
+			case err != nil, id == shellCloseStdin:
+				w.Close()
+				return
+			case id == shellStdin:
+				w.Write(data)
+			}
+		}
+	}()
+	stdout := shellWriter{c, shellStdout}
+	stderr := shellWriter{c, shellStderr}
+	code := d.shell(cmd, stdin, stdout, stderr)
+	c.writeShell(shellExit, []byte{byte(code)})
+}
+
+func serveSync(c conn, d *Device) {
+	for {
+		id, n, err := c.readSync()
+		if err != nil {
+			return
+		}
+		switch id {
+		case "QUIT":
+			return
+		case "STAT":
+			path := make([]byte, n)
+			if _, err := io.ReadFull(c, path); err != nil {
+				return
+			}
+			mode, size := d.stat(string(path))
+			c.writeSync("STAT", mode)
+			c.writeUint32(size)
+			c.writeUint32(0)
+		case "SEND":
+			spec := make([]byte, n)
+			if _, err := io.ReadFull(c, spec); err != nil {
+				return
+			}
+			path := string(spec)
+			if i := strings.LastIndex(path, ","); i >= 0 {
+				path = path[:i]
+			}
+			data := &bytes.Buffer{}
+			for {
+				id, n, err := c.readSync()
+				if err != nil {
+					return
+				}
+				if id == "DONE" {
+					break
+				}
+				if id != "DATA" {
+					return
+				}
+				if _, err := io.CopyN(data, c, int64(n)); err != nil {
+					return
+				}
+			}
+			d.putFile(path, data.Bytes())
+			c.writeSync("OKAY", 0)
+		case "RECV":
+			path := make([]byte, n)
+			if _, err := io.ReadFull(c, path); err != nil {
+				return
+			}
+			data, ok := d.File(string(path))
+			if !ok {
+				msg := "No such file or directory"
+				c.writeSync("FAIL", uint32(len(msg)))
+				io.WriteString(c, msg)
+				continue
+			}
+			for len(data) > 0 {
+				chunk := data
+				if len(chunk) > 64*1024 {
+					chunk = chunk[:64*1024]
+				}
+				c.writeSync("DATA", uint32(len(chunk)))
+				c.Write(chunk)
+				data = data[len(chunk):]
+			}
+			c.writeSync("DONE", 0)
+		default:
+			return
+		}
+	}
+}
+
+// shellWriter writes to the connection as shell protocol packets.
+type shellWriter struct {
+	c  conn
+	id byte
+}
+
+func (w shellWriter) Write(p []byte) (int, error) {
+	if err := w.c.writeShell(w.id, p); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
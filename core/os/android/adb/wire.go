@@ -0,0 +1,222 @@
+// Copyright (C) 2017 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package adb
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"sync"
+
+	"github.com/google/gapid/core/event/task"
+	"github.com/google/gapid/core/fault"
+	"github.com/google/gapid/core/log"
+)
+
+// The adb server protocol is documented in the adb sources:
+// https://android.googlesource.com/platform/system/core/+/master/adb/OVERVIEW.TXT
+// https://android.googlesource.com/platform/system/core/+/master/adb/SERVICES.TXT
+// https://android.googlesource.com/platform/system/core/+/master/adb/SYNC.TXT
+
+const (
+	// ErrServerFailed is returned when the adb server responds to a request with FAIL.
+	ErrServerFailed = fault.Const("adb server returned FAIL")
+	// ErrBadResponse is returned when the adb server responds with something unexpected.
+	ErrBadResponse = fault.Const("Unexpected response from adb server")
+
+	statusOkay = "OKAY"
+	statusFail = "FAIL"
+
+	// maxSyncData is the largest payload permitted in a single sync DATA packet.
+	maxSyncData = 64 * 1024
+)
+
+// Sync protocol packet identifiers.
+const (
+	syncSend = "SEND"
+	syncRecv = "RECV"
+	syncStat = "STAT"
+	syncData = "DATA"
+	syncDone = "DONE"
+	syncOkay = "OKAY"
+	syncFail = "FAIL"
+	syncQuit = "QUIT"
+)
+
+// Shell protocol (v2) packet identifiers.
+const (
+	shellStdin         = 0
+	shellStdout        = 1
+	shellStderr        = 2
+	shellExit          = 3
+	shellCloseStdin    = 4
+	shellWindowSize    = 5
+	shellHeaderSize    = 5
+	shellMaxPacketSize = 32 * 1024
+)
+
+// serverError is the message returned by the adb server along with a FAIL
+// status.
+type serverError string
+
+func (e serverError) Error() string { return fmt.Sprintf("%v: %v", ErrServerFailed, string(e)) }
+
+// conn is a single connection to the adb server.
+type conn struct {
+	net.Conn
+	once sync.Once
+	done chan struct{}
+}
+
+// newConn wraps the net.Conn, closing it if the context is stopped before the
+// returned conn is closed.
+func newConn(ctx log.Context, c net.Conn) *conn {
+	out := &conn{Conn: c, done: make(chan struct{})}
+	go func() {
+		select {
+		case <-task.ShouldStop(ctx):
+			c.Close()
+		case <-out.done:
+		}
+	}()
+	return out
+}
+
+// Close closes the connection to the server.
+func (c *conn) Close() error {
+	err := error(nil)
+	c.once.Do(func() {
+		close(c.done)
+		err = c.Conn.Close()
+	})
+	return err
+}
+
+// request sends the service request to the server and waits for the status.
+func (c *conn) request(service string) error {
+	if err := c.writeHexString(service); err != nil {
+		return err
+	}
+	return c.status()
+}
+
+// status reads an OKAY or FAIL status from the server. If the status is FAIL
+// then the failure message is returned as a serverError.
+func (c *conn) status() error {
+	var status [4]byte
+	if _, err := io.ReadFull(c, status[:]); err != nil {
+		return err
+	}
+	switch string(status[:]) {
+	case statusOkay:
+		return nil
+	case statusFail:
+		msg, err := c.readHexString()
+		if err != nil {
+			return err
+		}
+		return serverError(msg)
+	default:
+		return fmt.Errorf("%v: %q", ErrBadResponse, string(status[:]))
+	}
+}
+
+// writeHexString writes the string prefixed with its length as four hex digits.
+func (c *conn) writeHexString(s string) error {
+	_, err := fmt.Fprintf(c, "%04x%s", len(s), s)
+	return err
+}
+
+// readHexString reads a string prefixed with its length as four hex digits.
+func (c *conn) readHexString() (string, error) {
+	var size [4]byte
+	if _, err := io.ReadFull(c, size[:]); err != nil {
+		return "", err
+	}
+	n, err := strconv.ParseUint(string(size[:]), 16, 16)
+	if err != nil {
+		return "", fmt.Errorf("%v: length %q", ErrBadResponse, string(size[:]))
+	}
+	buf := make([]byte, n)
+	if _, err := io.ReadFull(c, buf); err != nil {
+		return "", err
+	}
+	return string(buf), nil
+}
+
+// writeSync writes a sync protocol packet with the given identifier and data.
+func (c *conn) writeSync(id string, data []byte) error {
+	buf := make([]byte, 8, 8+len(data))
+	copy(buf, id)
+	binary.LittleEndian.PutUint32(buf[4:], uint32(len(data)))
+	buf = append(buf, data...)
+	_, err := c.Write(buf)
+	return err
+}
+
+// writeSyncValue writes a sync protocol packet with the given identifier and
+// a 32 bit value in place of the length.
+func (c *conn) writeSyncValue(id string, value uint32) error {
+	var buf [8]byte
+	copy(buf[:], id)
+	binary.LittleEndian.PutUint32(buf[4:], value)
+	_, err := c.Write(buf[:])
+	return err
+}
+
+// readSync reads a sync protocol packet header returning the identifier and
+// the 32 bit value following it.
+func (c *conn) readSync() (string, uint32, error) {
+	var buf [8]byte
+	if _, err := io.ReadFull(c, buf[:]); err != nil {
+		return "", 0, err
+	}
+	return string(buf[:4]), binary.LittleEndian.Uint32(buf[4:]), nil
+}
+
+// readSyncFail reads the message following a sync FAIL packet of length n.
+func (c *conn) readSyncFail(n uint32) error {
+	msg := make([]byte, n)
+	if _, err := io.ReadFull(c, msg); err != nil {
+		return err
+	}
+	return serverError(msg)
+}
+
+// writeShell writes a shell protocol packet with the given identifier and
+// data.
+func (c *conn) writeShell(id byte, data []byte) error {
+	buf := make([]byte, shellHeaderSize, shellHeaderSize+len(data))
+	buf[0] = id
+	binary.LittleEndian.PutUint32(buf[1:], uint32(len(data)))
+	buf = append(buf, data...)
+	_, err := c.Write(buf)
+	return err
+}
+
+// readShell reads a shell protocol packet returning its identifier and data.
+func (c *conn) readShell() (byte, []byte, error) {
+	var header [shellHeaderSize]byte
+	if _, err := io.ReadFull(c, header[:]); err != nil {
+		return 0, nil, err
+	}
+	data := make([]byte, binary.LittleEndian.Uint32(header[1:]))
+	if _, err := io.ReadFull(c, data); err != nil {
+		return 0, nil, err
+	}
+	return header[0], data, nil
+}
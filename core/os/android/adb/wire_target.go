@@ -0,0 +1,186 @@
+// Copyright (C) 2017 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package adb
+
+import (
+	"bytes"
+	"fmt"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/google/gapid/core/event/task"
+	"github.com/google/gapid/core/fault"
+	"github.com/google/gapid/core/fault/cause"
+	"github.com/google/gapid/core/log"
+	"github.com/google/gapid/core/os/shell"
+)
+
+const (
+	// ErrUnsupportedCommand is returned when an adb command has no wire
+	// protocol equivalent.
+	ErrUnsupportedCommand = fault.Const("adb command is not supported by the wire protocol client")
+	// ErrInstallFailed is returned when the package manager fails to install
+	// an APK.
+	ErrInstallFailed = fault.Const("Failed to install APK")
+
+	installStagingDir = "/data/local/tmp"
+)
+
+// startWire starts the adb command cmd using the wire protocol client.
+// If useShell is true then the command is run in the device shell, otherwise
+// it is treated as an adb command-line command.
+func (b *binding) startWire(cmd shell.Cmd, useShell bool) (shell.Process, error) {
+	if useShell {
+		return b.wireShell(cmd, append([]string{cmd.Name}, cmd.Args...)), nil
+	}
+	args := cmd.Args
+	switch cmd.Name {
+	case "shell":
+		return b.wireShell(cmd, args), nil
+	case "logcat":
+		return b.wireShell(cmd, append([]string{"logcat"}, args...)), nil
+	case "root":
+		return newWireProcess(func(ctx log.Context) error {
+			out, err := b.client.Root(ctx, b.To.Serial)
+			if cmd.Stdout != nil {
+				cmd.Stdout.Write([]byte(out))
+			}
+			return err
+		}), nil
+	case "install":
+		if len(args) > 0 {
+			return newWireProcess(func(ctx log.Context) error {
+				return b.wireInstall(ctx, cmd, args[:len(args)-1], args[len(args)-1])
+			}), nil
+		}
+	case "push":
+		if len(args) == 2 {
+			return newWireProcess(func(ctx log.Context) error {
+				return b.client.Push(ctx, b.To.Serial, args[0], args[1])
+			}), nil
+		}
+	case "pull":
+		if len(args) == 2 {
+			return newWireProcess(func(ctx log.Context) error {
+				return b.client.Pull(ctx, b.To.Serial, args[0], args[1])
+			}), nil
+		}
+	case "forward":
+		switch {
+		case len(args) == 2 && args[0] == "--remove":
+			return newWireProcess(func(ctx log.Context) error {
+				return b.client.RemoveForward(ctx, b.To.Serial, args[1])
+			}), nil
+		case len(args) == 2:
+			return newWireProcess(func(ctx log.Context) error {
+				return b.client.Forward(ctx, b.To.Serial, args[0], args[1])
+			}), nil
+		}
+	}
+	return nil, fmt.Errorf("%v: %v", ErrUnsupportedCommand, cmd.Name)
+}
+
+// wireShell returns a process that runs args in the device shell.
+func (b *binding) wireShell(cmd shell.Cmd, args []string) shell.Process {
+	// adb joins the shell arguments with spaces without any quoting, which
+	// callers depend on.
+	command := strings.Join(args, " ")
+	return newWireProcess(func(ctx log.Context) error {
+		return b.client.shell(ctx, b.To.Serial, b.hasFeature(ctx, featureShellV2),
+			command, cmd.Stdin, cmd.Stdout, cmd.Stderr)
+	})
+}
+
+// wireInstall pushes the APK to a staging directory on the device and installs
+// it with the package manager.
+func (b *binding) wireInstall(ctx log.Context, cmd shell.Cmd, flags []string, apk string) error {
+	remote := path.Join(installStagingDir, filepath.Base(apk))
+	if err := b.client.Push(ctx, b.To.Serial, apk, remote); err != nil {
+		return err
+	}
+	defer b.client.shell(ctx, b.To.Serial, false, "rm -f "+remote, nil, nil, nil)
+
+	args := append(append([]string{"pm", "install"}, flags...), remote)
+	out := &bytes.Buffer{}
+	err := b.client.shell(ctx, b.To.Serial, b.hasFeature(ctx, featureShellV2),
+		strings.Join(args, " "), nil, out, out)
+	if cmd.Stdout != nil {
+		cmd.Stdout.Write(out.Bytes())
+	}
+	if err != nil {
+		return err
+	}
+	if !strings.Contains(out.String(), "Success") {
+		return cause.Explain(ctx, ErrInstallFailed, strings.TrimSpace(out.String())).With("APK", apk)
+	}
+	return nil
+}
+
+// hasFeature returns true if both the adb server and the device support the
+// named feature. The features are only cached once they have been
+// successfully queried, so a failed lookup is retried on the next call.
+func (b *binding) hasFeature(ctx log.Context, name string) bool {
+	b.featuresLock.Lock()
+	defer b.featuresLock.Unlock()
+	if b.features == nil {
+		features, err := b.client.Features(ctx, b.To.Serial)
+		if err != nil {
+			return false
+		}
+		b.features = features
+	}
+	return b.features[name]
+}
+
+// wireProcess is an implementation of shell.Process for commands run using
+// the wire protocol client. The command is run when Wait is called.
+type wireProcess struct {
+	run    func(ctx log.Context) error
+	mutex  sync.Mutex
+	cancel task.CancelFunc
+	killed bool
+}
+
+func newWireProcess(run func(ctx log.Context) error) *wireProcess {
+	return &wireProcess{run: run}
+}
+
+func (p *wireProcess) Wait(ctx log.Context) error {
+	ctx, cancel := task.WithCancel(ctx)
+	defer cancel()
+
+	p.mutex.Lock()
+	killed := p.killed
+	p.cancel = cancel
+	p.mutex.Unlock()
+
+	if killed {
+		cancel()
+		return task.StopReason(ctx)
+	}
+	return p.run(ctx)
+}
+
+func (p *wireProcess) Kill() error {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	p.killed = true
+	if p.cancel != nil {
+		p.cancel()
+	}
+	return nil
+}
@@ -24,7 +24,17 @@ set(files
     apk.proto
     debugifier.go
     doc.go
+    jks.go
+    key.go
+    key_test.go
+    pkcs12.go
+    rc2.go
+    resources.go
+    resources_test.go
+    sign.go
+    sign_test.go
+    zip.go
 )
 set(dirs
-    
+    testdata
 )
@@ -16,6 +16,7 @@ package apk
 
 import (
 	"archive/zip"
+	"io/ioutil"
 	"path/filepath"
 
 	"github.com/google/gapid/core/fault/cause"
@@ -44,8 +45,19 @@ func Analyze(ctx log.Context, apkData []byte) (*Information, error) {
 	if err != nil {
 		return nil, cause.Explain(ctx, err, "Finding launch activity")
 	}
+
+	name, icon := m.Package, ""
+	if res, err := GetResources(ctx, files); err != nil {
+		ctx.Warning().V("error", err).Log("Couldn't read APK resources")
+	} else {
+		if label, err := res.ResolveString(ctx, m.Application.Label, ResourceConfig{}); err == nil && label != "" {
+			name = label
+		}
+		icon, _ = res.ResolveString(ctx, m.Application.Icon, ResourceConfig{Density: DensityXXXHigh})
+	}
+
 	return &Information{
-		Name:        m.Package, // TODO
+		Name:        name,
 		VersionCode: int32(m.VersionCode),
 		VersionName: m.VersionName,
 		Package:     m.Package,
@@ -54,9 +66,46 @@ func Analyze(ctx log.Context, apkData []byte) (*Information, error) {
 		Engine:      engine(files),
 		ABI:         GatherABIs(files),
 		Debuggable:  m.Application.Debuggable,
+		Icon:        icon,
 	}, nil
 }
 
+// Icon returns the path and contents of the APK's launcher icon that best
+// matches the screen density.
+func Icon(ctx log.Context, files []*zip.File, density uint16) (string, []byte, error) {
+	m, err := GetManifest(ctx, files)
+	if err != nil {
+		return "", nil, err
+	}
+	if m.Application.Icon == "" {
+		return "", nil, cause.Wrap(ctx, ErrResourceNotFound).With("resource", "icon")
+	}
+	res, err := GetResources(ctx, files)
+	if err != nil {
+		return "", nil, err
+	}
+	path, err := res.ResolveString(ctx, m.Application.Icon, ResourceConfig{Density: density})
+	if err != nil {
+		return "", nil, err
+	}
+	for _, file := range files {
+		if file.Name != path {
+			continue
+		}
+		f, err := file.Open()
+		if err != nil {
+			return "", nil, cause.Explain(ctx, err, "Couldn't open icon").With("path", path)
+		}
+		defer f.Close()
+		data, err := ioutil.ReadAll(f)
+		if err != nil {
+			return "", nil, cause.Explain(ctx, err, "Couldn't read icon").With("path", path)
+		}
+		return path, data, nil
+	}
+	return "", nil, cause.Wrap(ctx, ErrResourceNotFound).With("path", path)
+}
+
 func engine(files []*zip.File) string {
 	for _, file := range files {
 		_, name := filepath.Split(file.Name)
@@ -16,13 +16,9 @@ package apk
 
 import (
 	"archive/zip"
-	"io"
-	"io/ioutil"
-	"os"
-	"os/exec"
+	"bytes"
 	"os/user"
 	"path/filepath"
-	"regexp"
 	"strings"
 
 	"github.com/google/gapid/core/log"
@@ -30,47 +26,48 @@ import (
 )
 
 // ApkDebugifier makes an APK debuggable. The fields in the struct
-// are used to configure the various paths and passwords required,
+// are used to configure the signing key and passwords required,
 // as well as providing a log context.
-// Intended use is ApkDebugifier{Ctx: ..., JarSignCmd: "jarsigner", ..., KeyStorePath: "..."}.Run(...).
+// The key is loaded from the PEM encoded KeyPath and CertPath files if they are
+// set, otherwise from the JKS or PKCS#12 key store at KeyStorePath.
+// Intended use is ApkDebugifier{Ctx: ..., KeyStorePath: "...", ...}.Run(...).
 type ApkDebugifier struct {
 	Ctx          log.Context // log context
-	JarSignCmd   string      // path to the jarsigner binary
-	ZipAlignCmd  string      // path to the zipalign binary
 	KeyPass      string      // key passphrase
 	KeyAlias     string      // key alias for signing
 	StorePass    string      // keystore passphrase
 	KeyStorePath string      // path to keystore (e.g. /path/to/debug.keystore)
+	KeyPath      string      // path to a PEM private key, used instead of the keystore
+	CertPath     string      // path to the PEM certificate for KeyPath
 }
 
 // Run takes the path (src) to an APK, sets the debuggable flag in its manifest,
 // re-signs and aligns it, and saves it to a different path (dst).
 func (a ApkDebugifier) Run(src string, dst string) error {
-	tempFile, err := ioutil.TempFile("", "debuggable.apk")
+	key, err := a.loadKey()
 	if err != nil {
 		return err
 	}
-	defer os.Remove(tempFile.Name())
 
-	a.Ctx.Printf("Making apk %s debuggable and saving to %s", src, tempFile.Name())
-	err = a.makeApkDebuggableAndRemoveSignatureFiles(src, tempFile)
-	if err != nil {
-		return err
-	}
+	a.Ctx.Printf("Making apk %s debuggable and saving to %s", src, dst)
+	return rewrite(a.Ctx, key, src, dst, func(name string, data []byte) ([]byte, error) {
+		if name != mainfestPath {
+			return nil, nil
+		}
+		a.Ctx.Debug().Logf("Modifying manifest file")
+		out := &bytes.Buffer{}
+		if err := binaryxml.SetDebuggableFlag(bytes.NewReader(data), out); err != nil {
+			return nil, err
+		}
+		return out.Bytes(), nil
+	})
+}
 
-	a.Ctx.Printf("Signing apk %s", tempFile.Name())
-	err = a.jarSign(tempFile.Name())
-	if err != nil {
-		return err
+func (a ApkDebugifier) loadKey() (*Key, error) {
+	if a.KeyPath != "" || a.CertPath != "" {
+		return LoadPEM(a.Ctx, expandHomeDir(a.KeyPath), expandHomeDir(a.CertPath))
 	}
-
-	a.Ctx.Printf("Zipaligning %s to %s", tempFile.Name(), dst)
-	err = a.zipAlign(tempFile.Name(), dst)
-	if err != nil {
-		return err
-	}
-
-	return nil
+	return LoadKeyStore(a.Ctx, expandHomeDir(a.KeyStorePath), a.StorePass, a.KeyAlias, a.KeyPass)
 }
 
 func expandHomeDir(p string) string {
@@ -84,88 +81,6 @@ func expandHomeDir(p string) string {
 	return filepath.Join(user.HomeDir, strings.TrimLeft(p, "~"))
 }
 
-func (a ApkDebugifier) execCommand(name string, args ...string) error {
-	cmd := exec.Command(name, args...)
-	a.Ctx.Printf("Executing %v", cmd.Args)
-	out, err := cmd.CombinedOutput()
-	logger := a.Ctx.Debug()
-	if err != nil {
-		logger = a.Ctx.Error()
-	}
-	logger.Writer().Write(out)
-	return err
-}
-
-func (a ApkDebugifier) jarSign(apk string) error {
-	return a.execCommand(
-		expandHomeDir(a.JarSignCmd),
-		"-sigalg", "SHA1withRSA",
-		"-digestalg", "SHA1",
-		"-keystore", expandHomeDir(a.KeyStorePath),
-		"-storepass", a.StorePass,
-		"-keypass", a.KeyPass,
-		apk,
-		a.KeyAlias,
-	)
-}
-
-func (a ApkDebugifier) zipAlign(src string, dst string) error {
-	return a.execCommand(expandHomeDir(a.ZipAlignCmd), "-v", "-f", "4", src, dst)
-}
-
-func (a ApkDebugifier) makeApkDebuggableAndRemoveSignatureFiles(src string, outFile *os.File) error {
-	inZip, err := zip.OpenReader(src)
-	if err != nil {
-		return err
-	}
-	defer inZip.Close()
-
-	defer outFile.Close()
-	w := zip.NewWriter(outFile)
-	defer w.Close()
-
-	jarSignatureFilePattern := regexp.MustCompile(`META-INF/[^/]*(DSA|RSA|SF)`)
-
-	for _, zf := range inZip.File {
-		if jarSignatureFilePattern.MatchString(zf.Name) {
-			a.Ctx.Debug().Logf("Skipping file %s", zf.Name)
-			continue
-		}
-
-		fr, err := zf.Open()
-		if err != nil {
-			return err
-		}
-		defer fr.Close()
-
-		fw, err := w.CreateHeader(&zip.FileHeader{
-			Name:         zf.Name,
-			Method:       zf.Method,
-			ModifiedDate: zf.ModifiedDate,
-			ModifiedTime: zf.ModifiedTime,
-		})
-		if err != nil {
-			return err
-		}
-
-		if zf.Name == "AndroidManifest.xml" {
-			a.Ctx.Debug().Logf("Modifying manifest file")
-			err := binaryxml.SetDebuggableFlag(fr, fw)
-			if err != nil {
-				return err
-			}
-		} else {
-			_, err = io.Copy(fw, fr)
-		}
-
-		if err != nil {
-			return err
-		}
-	}
-
-	return nil
-}
-
 func IsApkDebuggable(ctx log.Context, apk string) (bool, error) {
 	inZip, err := zip.OpenReader(apk)
 	if err != nil {
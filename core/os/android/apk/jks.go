@@ -0,0 +1,216 @@
+// Copyright (C) 2017 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package apk
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"crypto/subtle"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"strings"
+	"unicode/utf16"
+)
+
+// The JKS key store format is the proprietary format used by the Java keytool
+// before Java 9, and is what the Android SDK uses for ~/.android/debug.keystore.
+
+var (
+	jksMagic = []byte{0xfe, 0xed, 0xfe, 0xed}
+
+	// oidJKSKeyProtector identifies the Sun proprietary key protection algorithm.
+	oidJKSKeyProtector = asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 42, 2, 17, 1, 1}
+)
+
+const (
+	jksPrivateKeyTag  = 1
+	jksTrustedCertTag = 2
+
+	// jksIntegritySalt is mixed into the SHA-1 integrity check of the key store.
+	jksIntegritySalt = "Mighty Aphrodite"
+)
+
+// loadJKS returns the key with the given alias from the JKS key store.
+func loadJKS(data []byte, storePass, alias, keyPass string) (*Key, error) {
+	if len(data) < sha1.Size {
+		return nil, ErrUnsupportedKeyStore
+	}
+	body, digest := data[:len(data)-sha1.Size], data[len(data)-sha1.Size:]
+	h := sha1.New()
+	h.Write(jksPassword(storePass))
+	h.Write([]byte(jksIntegritySalt))
+	h.Write(body)
+	if subtle.ConstantTimeCompare(h.Sum(nil), digest) != 1 {
+		return nil, ErrIncorrectPassword
+	}
+
+	r := bytes.NewReader(body)
+	var header struct {
+		Magic   uint32
+		Version uint32
+		Count   uint32
+	}
+	if err := binary.Read(r, binary.BigEndian, &header); err != nil {
+		return nil, err
+	}
+	if header.Version != 1 && header.Version != 2 {
+		return nil, ErrUnsupportedKeyStore
+	}
+	readCert := func() (*x509.Certificate, error) {
+		if header.Version == 2 {
+			if ty, err := readJKSString(r); err != nil {
+				return nil, err
+			} else if ty != "X.509" {
+				return nil, fmt.Errorf("Unsupported certificate type %s", ty)
+			}
+		}
+		der, err := readJKSBytes(r)
+		if err != nil {
+			return nil, err
+		}
+		return x509.ParseCertificate(der)
+	}
+
+	for i := uint32(0); i < header.Count; i++ {
+		var tag uint32
+		if err := binary.Read(r, binary.BigEndian, &tag); err != nil {
+			return nil, err
+		}
+		name, err := readJKSString(r)
+		if err != nil {
+			return nil, err
+		}
+		var timestamp int64
+		if err := binary.Read(r, binary.BigEndian, &timestamp); err != nil {
+			return nil, err
+		}
+		switch tag {
+		case jksPrivateKeyTag:
+			protected, err := readJKSBytes(r)
+			if err != nil {
+				return nil, err
+			}
+			var chainLength uint32
+			if err := binary.Read(r, binary.BigEndian, &chainLength); err != nil {
+				return nil, err
+			}
+			chain := make([]*x509.Certificate, chainLength)
+			for j := range chain {
+				if chain[j], err = readCert(); err != nil {
+					return nil, err
+				}
+			}
+			if !strings.EqualFold(name, alias) {
+				continue
+			}
+			if len(chain) == 0 {
+				return nil, ErrKeyNotFound
+			}
+			priv, err := jksRecoverKey(protected, keyPass)
+			if err != nil {
+				return nil, err
+			}
+			return newKey(priv, chain[0])
+		case jksTrustedCertTag:
+			if _, err := readCert(); err != nil {
+				return nil, err
+			}
+		default:
+			return nil, ErrUnsupportedKeyStore
+		}
+	}
+	return nil, ErrKeyNotFound
+}
+
+// jksRecoverKey decrypts the private key protected with the Sun key protector
+// algorithm. The key is XOR'd with a stream of chained SHA-1 digests of the
+// password, and is followed by a SHA-1 digest of the password and plain key.
+func jksRecoverKey(protected []byte, password string) (interface{}, error) {
+	info := struct {
+		Algorithm     pkix.AlgorithmIdentifier
+		EncryptedData []byte
+	}{}
+	if _, err := asn1.Unmarshal(protected, &info); err != nil {
+		return nil, err
+	}
+	if !info.Algorithm.Algorithm.Equal(oidJKSKeyProtector) {
+		return nil, fmt.Errorf("Unsupported key protection algorithm %v", info.Algorithm.Algorithm)
+	}
+	encrypted := info.EncryptedData
+	if len(encrypted) < 2*sha1.Size {
+		return nil, ErrIncorrectPassword
+	}
+	pw := jksPassword(password)
+	salt := encrypted[:sha1.Size]
+	check := encrypted[len(encrypted)-sha1.Size:]
+	encrypted = encrypted[sha1.Size : len(encrypted)-sha1.Size]
+
+	plain := make([]byte, len(encrypted))
+	digest := salt
+	for i := 0; i < len(plain); i += sha1.Size {
+		h := sha1.New()
+		h.Write(pw)
+		h.Write(digest)
+		digest = h.Sum(nil)
+		for j := 0; j < sha1.Size && i+j < len(plain); j++ {
+			plain[i+j] = encrypted[i+j] ^ digest[j]
+		}
+	}
+
+	h := sha1.New()
+	h.Write(pw)
+	h.Write(plain)
+	if subtle.ConstantTimeCompare(h.Sum(nil), check) != 1 {
+		return nil, ErrIncorrectPassword
+	}
+	return x509.ParsePKCS8PrivateKey(plain)
+}
+
+// jksPassword returns the password as big-endian UTF-16, without a terminator.
+func jksPassword(s string) []byte {
+	u := utf16.Encode([]rune(s))
+	out := make([]byte, 0, 2*len(u))
+	for _, c := range u {
+		out = append(out, byte(c>>8), byte(c))
+	}
+	return out
+}
+
+func readJKSBytes(r io.Reader) ([]byte, error) {
+	var size uint32
+	if err := binary.Read(r, binary.BigEndian, &size); err != nil {
+		return nil, err
+	}
+	out := make([]byte, size)
+	_, err := io.ReadFull(r, out)
+	return out, err
+}
+
+// readJKSString reads a string written with Java's DataOutput.writeUTF.
+// Aliases are plain ASCII in practice, so the modified UTF-8 encoding is
+// treated as regular UTF-8.
+func readJKSString(r io.Reader) (string, error) {
+	var size uint16
+	if err := binary.Read(r, binary.BigEndian, &size); err != nil {
+		return "", err
+	}
+	out := make([]byte, size)
+	_, err := io.ReadFull(r, out)
+	return string(out), err
+}
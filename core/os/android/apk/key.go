@@ -0,0 +1,188 @@
+// Copyright (C) 2017 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package apk
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"io/ioutil"
+	"strings"
+
+	"github.com/google/gapid/core/fault"
+	"github.com/google/gapid/core/fault/cause"
+	"github.com/google/gapid/core/log"
+)
+
+const (
+	ErrKeyNotFound         = fault.Const("Key not found in key store")
+	ErrIncorrectPassword   = fault.Const("Incorrect key store or key password")
+	ErrUnsupportedKeyStore = fault.Const("Unsupported key store format")
+	ErrUnsupportedKey      = fault.Const("Unsupported signing key type")
+	ErrKeyMismatch         = fault.Const("Certificate does not match the private key")
+	ErrCertificateNotFound = fault.Const("Certificate not found")
+)
+
+// Key is a private key and its certificate, used to sign APKs.
+type Key struct {
+	PrivateKey  crypto.Signer     // RSA or ECDSA private key
+	Certificate *x509.Certificate // certificate holding the public key
+}
+
+// LoadKeyStore loads the key with the given alias from the JKS or PKCS#12 key
+// store at path. storePass is the password of the key store, and keyPass the
+// password of the key itself. PKCS#12 key stores use storePass for both.
+func LoadKeyStore(ctx log.Context, path, storePass, alias, keyPass string) (*Key, error) {
+	ctx = ctx.S("keystore", path).S("alias", alias)
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, cause.Explain(ctx, err, "Couldn't read key store")
+	}
+
+	var key *Key
+	if bytes.HasPrefix(data, jksMagic) {
+		key, err = loadJKS(data, storePass, alias, keyPass)
+	} else {
+		key, err = loadPKCS12(data, storePass, alias)
+	}
+	switch err {
+	case nil:
+	case ErrKeyNotFound, ErrIncorrectPassword, ErrUnsupportedKeyStore, ErrUnsupportedKey, ErrKeyMismatch:
+		return nil, cause.Wrap(ctx, err)
+	default:
+		return nil, cause.Explain(ctx, err, "Couldn't load key store")
+	}
+	return key, nil
+}
+
+// LoadPEM loads the key from the PEM encoded private key file at keyPath and
+// the PEM encoded certificate file at certPath. The private key may be in
+// PKCS#1, PKCS#8 or SEC 1 form and must not be encrypted.
+func LoadPEM(ctx log.Context, keyPath, certPath string) (*Key, error) {
+	ctx = ctx.S("key", keyPath).S("certificate", certPath)
+	keyData, err := ioutil.ReadFile(keyPath)
+	if err != nil {
+		return nil, cause.Explain(ctx, err, "Couldn't read private key")
+	}
+	certData, err := ioutil.ReadFile(certPath)
+	if err != nil {
+		return nil, cause.Explain(ctx, err, "Couldn't read certificate")
+	}
+
+	var priv interface{}
+keys:
+	for block, rest := pem.Decode(keyData); block != nil; block, rest = pem.Decode(rest) {
+		switch block.Type {
+		case "RSA PRIVATE KEY":
+			priv, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+		case "EC PRIVATE KEY":
+			priv, err = x509.ParseECPrivateKey(block.Bytes)
+		case "PRIVATE KEY":
+			priv, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+		default:
+			continue
+		}
+		if err != nil {
+			return nil, cause.Explain(ctx, err, "Couldn't parse private key")
+		}
+		break keys
+	}
+	if priv == nil {
+		return nil, cause.Wrap(ctx, ErrKeyNotFound)
+	}
+
+	var cert *x509.Certificate
+	for block, rest := pem.Decode(certData); block != nil; block, rest = pem.Decode(rest) {
+		if block.Type != "CERTIFICATE" {
+			continue
+		}
+		if cert, err = x509.ParseCertificate(block.Bytes); err != nil {
+			return nil, cause.Explain(ctx, err, "Couldn't parse certificate")
+		}
+		break
+	}
+	if cert == nil {
+		return nil, cause.Wrap(ctx, ErrCertificateNotFound)
+	}
+
+	key, err := newKey(priv, cert)
+	if err != nil {
+		return nil, cause.Wrap(ctx, err)
+	}
+	return key, nil
+}
+
+// newKey returns a Key for the private key and certificate, checking that the
+// key type is supported for signing and that the two belong together.
+func newKey(priv interface{}, cert *x509.Certificate) (*Key, error) {
+	switch priv := priv.(type) {
+	case *rsa.PrivateKey:
+		if pub, ok := cert.PublicKey.(*rsa.PublicKey); !ok || pub.N.Cmp(priv.N) != 0 || pub.E != priv.E {
+			return nil, ErrKeyMismatch
+		}
+		return &Key{PrivateKey: priv, Certificate: cert}, nil
+	case *ecdsa.PrivateKey:
+		if pub, ok := cert.PublicKey.(*ecdsa.PublicKey); !ok || pub.X.Cmp(priv.X) != 0 || pub.Y.Cmp(priv.Y) != 0 {
+			return nil, ErrKeyMismatch
+		}
+		return &Key{PrivateKey: priv, Certificate: cert}, nil
+	default:
+		return nil, ErrUnsupportedKey
+	}
+}
+
+// loadPKCS12 returns the key with the given alias from the PKCS#12 key store.
+// If alias is empty and the key store holds a single key, then that is used.
+func loadPKCS12(data []byte, password, alias string) (*Key, error) {
+	entries, err := decodePKCS12(data, password)
+	if err != nil {
+		return nil, err
+	}
+	var priv *pkcs12Entry
+	keys := 0
+	for i := range entries {
+		e := &entries[i]
+		if e.key == nil {
+			continue
+		}
+		keys++
+		// keytool stores aliases in lower case.
+		if alias != "" && strings.EqualFold(e.name, alias) {
+			priv = e
+		}
+	}
+	if priv == nil && alias == "" && keys == 1 {
+		for i := range entries {
+			if entries[i].key != nil {
+				priv = &entries[i]
+			}
+		}
+	}
+	if priv == nil {
+		return nil, ErrKeyNotFound
+	}
+	for _, e := range entries {
+		if e.cert == nil {
+			continue
+		}
+		if (len(priv.keyID) > 0 && bytes.Equal(e.keyID, priv.keyID)) || keys == 1 {
+			return newKey(priv.key, e.cert)
+		}
+	}
+	return nil, ErrKeyNotFound
+}
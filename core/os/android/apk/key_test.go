@@ -0,0 +1,215 @@
+// Copyright (C) 2017 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package apk
+
+import (
+	"bytes"
+	"crypto/rsa"
+	"crypto/sha1"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/pem"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/gapid/core/assert"
+	"github.com/google/gapid/core/context/jot"
+	"github.com/google/gapid/core/log"
+)
+
+const (
+	testKeyAlias = "androiddebugkey"
+	testPassword = "android"
+	testKeyPath  = "testdata/debug.key.pem"
+	testCertPath = "testdata/debug.cert.pem"
+)
+
+func TestRC2(t_ *testing.T) {
+	ctx := log.Testing(t_)
+	// Test vectors from RFC 2268, section 5.
+	for _, test := range []struct {
+		key       string
+		bits      int
+		plain     string
+		encrypted string
+	}{
+		{"0000000000000000", 63, "0000000000000000", "ebb773f993278eff"},
+		{"ffffffffffffffff", 64, "ffffffffffffffff", "278b27e42e2f0d49"},
+		{"3000000000000000", 64, "1000000000000001", "30649edf9be7d2c2"},
+		{"88", 64, "0000000000000000", "61a8a244adacccf0"},
+		{"88bca90e90875a", 64, "0000000000000000", "6ccf4308974c267f"},
+		{"88bca90e90875a7f0f79c384627bafb2", 64, "0000000000000000", "1a807d272bbe5db1"},
+		{"88bca90e90875a7f0f79c384627bafb2", 128, "0000000000000000", "2269552ab0f85ca6"},
+	} {
+		key, _ := hex.DecodeString(test.key)
+		plain, _ := hex.DecodeString(test.plain)
+		c := newRC2(key, test.bits)
+		got := make([]byte, 8)
+		c.Encrypt(got, plain)
+		assert.For(ctx, "Encrypt %v", test.key).ThatString(hex.EncodeToString(got)).Equals(test.encrypted)
+		c.Decrypt(got, got)
+		assert.For(ctx, "Decrypt %v", test.key).ThatString(hex.EncodeToString(got)).Equals(test.plain)
+	}
+}
+
+func TestPBKDF2(t_ *testing.T) {
+	ctx := log.Testing(t_)
+	// Test vectors from RFC 6070.
+	got := pbkdf2(sha1.New, []byte("password"), []byte("salt"), 2, 20)
+	assert.For(ctx, "pbkdf2").ThatString(hex.EncodeToString(got)).Equals("ea6c014dc72d6f8ccd1ed92ace1d41f0d8de8957")
+	got = pbkdf2(sha1.New, []byte("passwordPASSWORDpassword"), []byte("saltSALTsaltSALTsaltSALTsaltSALTsalt"), 4096, 25)
+	assert.For(ctx, "pbkdf2").ThatString(hex.EncodeToString(got)).Equals("3d2eec4fe41c849b80c8d83662c0e44a8b291a964cf2f07038")
+}
+
+// testKey returns the key and certificate in the PEM test files.
+func testKey(ctx log.Context) (*rsa.PrivateKey, *x509.Certificate) {
+	key, err := LoadPEM(ctx, testKeyPath, testCertPath)
+	if err != nil {
+		jot.Fatal(ctx, err, "Couldn't load test key")
+	}
+	return key.PrivateKey.(*rsa.PrivateKey), key.Certificate
+}
+
+func TestLoadPEM(t_ *testing.T) {
+	ctx := log.Testing(t_)
+	key, err := LoadPEM(ctx, testKeyPath, testCertPath)
+	assert.For(ctx, "LoadPEM").ThatError(err).Succeeded()
+	assert.For(ctx, "Subject").ThatString(key.Certificate.Subject.CommonName).Equals("Android Debug")
+
+	_, err = LoadPEM(ctx, testCertPath, testCertPath)
+	assert.For(ctx, "LoadPEM no key").ThatError(err).HasCause(ErrKeyNotFound)
+
+	_, err = LoadPEM(ctx, testKeyPath, testKeyPath)
+	assert.For(ctx, "LoadPEM no certificate").ThatError(err).HasCause(ErrCertificateNotFound)
+
+	// The key and certificate may share a single file, in any order.
+	tmp, err := ioutil.TempDir("", "apk_test")
+	if err != nil {
+		jot.Fatal(ctx, err, "Couldn't create temporary directory")
+	}
+	defer os.RemoveAll(tmp)
+	keyData, _ := ioutil.ReadFile(testKeyPath)
+	certData, _ := ioutil.ReadFile(testCertPath)
+	path := filepath.Join(tmp, "debug.pem")
+	ioutil.WriteFile(path, append(append([]byte{}, certData...), keyData...), 0666)
+	key, err = LoadPEM(ctx, path, path)
+	assert.For(ctx, "LoadPEM combined").ThatError(err).Succeeded()
+	assert.For(ctx, "Combined subject").ThatString(key.Certificate.Subject.CommonName).Equals("Android Debug")
+}
+
+func TestLoadPKCS12(t_ *testing.T) {
+	ctx := log.Testing(t_)
+	priv, cert := testKey(ctx)
+	for _, path := range []string{
+		"testdata/debug.p12",        // PBES2 with AES-256 and HMAC-SHA256
+		"testdata/debug_legacy.p12", // RC2 and 3DES
+		"testdata/debug_jdk.p12",    // The JDK keytool defaults: 10000 iterations of PBES2 with AES-256
+	} {
+		ctx := ctx.S("path", path)
+		key, err := LoadKeyStore(ctx, path, testPassword, testKeyAlias, testPassword)
+		assert.For(ctx, "LoadKeyStore").ThatError(err).Succeeded()
+		assert.For(ctx, "Private key").That(key.PrivateKey).DeepEquals(priv)
+		assert.For(ctx, "Certificate").ThatSlice(key.Certificate.Raw).Equals(cert.Raw)
+
+		_, err = LoadKeyStore(ctx, path, "wrong", testKeyAlias, testPassword)
+		assert.For(ctx, "Wrong password").ThatError(err).HasCause(ErrIncorrectPassword)
+
+		_, err = LoadKeyStore(ctx, path, testPassword, "missing", testPassword)
+		assert.For(ctx, "Wrong alias").ThatError(err).HasCause(ErrKeyNotFound)
+	}
+}
+
+// encodeJKS returns a JKS key store holding the PKCS#8 encoded private key.
+func encodeJKS(storePass, alias, keyPass string, pkcs8 []byte, cert *x509.Certificate) []byte {
+	pw := jksPassword(keyPass)
+	salt := bytes.Repeat([]byte{0x42}, sha1.Size)
+	encrypted := append([]byte{}, salt...)
+	digest := salt
+	for i := 0; i < len(pkcs8); i += sha1.Size {
+		h := sha1.New()
+		h.Write(pw)
+		h.Write(digest)
+		digest = h.Sum(nil)
+		for j := 0; j < sha1.Size && i+j < len(pkcs8); j++ {
+			encrypted = append(encrypted, pkcs8[i+j]^digest[j])
+		}
+	}
+	h := sha1.New()
+	h.Write(pw)
+	h.Write(pkcs8)
+	encrypted = h.Sum(encrypted)
+	protected, _ := asn1.Marshal(struct {
+		Algorithm     pkix.AlgorithmIdentifier
+		EncryptedData []byte
+	}{pkix.AlgorithmIdentifier{Algorithm: oidJKSKeyProtector, Parameters: asn1Null}, encrypted})
+
+	buf := &bytes.Buffer{}
+	write := func(v interface{}) { binary.Write(buf, binary.BigEndian, v) }
+	buf.Write(jksMagic)
+	write(uint32(2))
+	write(uint32(1))
+	write(uint32(jksPrivateKeyTag))
+	write(uint16(len(alias)))
+	buf.WriteString(alias)
+	write(int64(0))
+	write(uint32(len(protected)))
+	buf.Write(protected)
+	write(uint32(1))
+	write(uint16(len("X.509")))
+	buf.WriteString("X.509")
+	write(uint32(len(cert.Raw)))
+	buf.Write(cert.Raw)
+
+	h = sha1.New()
+	h.Write(jksPassword(storePass))
+	h.Write([]byte(jksIntegritySalt))
+	h.Write(buf.Bytes())
+	buf.Write(h.Sum(nil))
+	return buf.Bytes()
+}
+
+func TestLoadJKS(t_ *testing.T) {
+	ctx := log.Testing(t_)
+	priv, cert := testKey(ctx)
+	data, _ := ioutil.ReadFile(testKeyPath)
+	block, _ := pem.Decode(data)
+
+	tmp, err := ioutil.TempDir("", "apk_test")
+	if err != nil {
+		jot.Fatal(ctx, err, "Couldn't create temporary directory")
+	}
+	defer os.RemoveAll(tmp)
+	path := filepath.Join(tmp, "debug.keystore")
+	ioutil.WriteFile(path, encodeJKS("storepass", testKeyAlias, "keypass", block.Bytes, cert), 0666)
+
+	key, err := LoadKeyStore(ctx, path, "storepass", "AndroidDebugKey", "keypass")
+	assert.For(ctx, "LoadKeyStore").ThatError(err).Succeeded()
+	assert.For(ctx, "Private key").That(key.PrivateKey).DeepEquals(priv)
+	assert.For(ctx, "Certificate").ThatSlice(key.Certificate.Raw).Equals(cert.Raw)
+
+	_, err = LoadKeyStore(ctx, path, "wrong", testKeyAlias, "keypass")
+	assert.For(ctx, "Wrong store password").ThatError(err).HasCause(ErrIncorrectPassword)
+
+	_, err = LoadKeyStore(ctx, path, "storepass", testKeyAlias, "wrong")
+	assert.For(ctx, "Wrong key password").ThatError(err).HasCause(ErrIncorrectPassword)
+
+	_, err = LoadKeyStore(ctx, path, "storepass", "missing", "keypass")
+	assert.For(ctx, "Wrong alias").ThatError(err).HasCause(ErrKeyNotFound)
+}
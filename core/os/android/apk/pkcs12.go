@@ -0,0 +1,462 @@
+// Copyright (C) 2017 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package apk
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/des"
+	"crypto/hmac"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"fmt"
+	"hash"
+	"math/big"
+	"unicode/utf16"
+)
+
+// PKCS#12 is described in RFC 7292, PBES2 and PBKDF2 in RFC 8018.
+
+var (
+	oidData                  = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 7, 1}
+	oidEncryptedData         = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 7, 6}
+	oidKeyBag                = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 12, 10, 1, 1}
+	oidShroudedKeyBag        = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 12, 10, 1, 2}
+	oidCertBag               = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 12, 10, 1, 3}
+	oidX509Certificate       = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 22, 1}
+	oidFriendlyName          = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 20}
+	oidLocalKeyID            = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 21}
+	oidPBEWithSHAAnd3DES     = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 12, 1, 3}
+	oidPBEWithSHAAnd40BitRC2 = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 12, 1, 6}
+	oidPBES2                 = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 5, 13}
+	oidPBKDF2                = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 5, 12}
+	oidHMACWithSHA1          = asn1.ObjectIdentifier{1, 2, 840, 113549, 2, 7}
+	oidHMACWithSHA256        = asn1.ObjectIdentifier{1, 2, 840, 113549, 2, 9}
+	oidAES128CBC             = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 1, 2}
+	oidAES192CBC             = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 1, 22}
+	oidAES256CBC             = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 1, 42}
+	oidDESEDE3CBC            = asn1.ObjectIdentifier{1, 2, 840, 113549, 3, 7}
+	oidSHA1                  = asn1.ObjectIdentifier{1, 3, 14, 3, 2, 26}
+	oidSHA256                = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 2, 1}
+)
+
+const asn1TagBMPString = 30
+
+type pfxPDU struct {
+	Version  int
+	AuthSafe contentInfo
+	MacData  macData `asn1:"optional"`
+}
+
+type contentInfo struct {
+	ContentType asn1.ObjectIdentifier
+	Content     asn1.RawValue `asn1:"tag:0,explicit,optional"`
+}
+
+type encryptedData struct {
+	Version              int
+	EncryptedContentInfo encryptedContentInfo
+}
+
+type encryptedContentInfo struct {
+	ContentType                asn1.ObjectIdentifier
+	ContentEncryptionAlgorithm pkix.AlgorithmIdentifier
+	EncryptedContent           []byte `asn1:"tag:0,optional"`
+}
+
+type macData struct {
+	Mac        digestInfo
+	MacSalt    []byte
+	Iterations int `asn1:"optional,default:1"`
+}
+
+type digestInfo struct {
+	Algorithm pkix.AlgorithmIdentifier
+	Digest    []byte
+}
+
+type safeBag struct {
+	ID         asn1.ObjectIdentifier
+	Value      asn1.RawValue     `asn1:"tag:0,explicit"`
+	Attributes []pkcs12Attribute `asn1:"set,optional"`
+}
+
+type pkcs12Attribute struct {
+	ID    asn1.ObjectIdentifier
+	Value asn1.RawValue `asn1:"set"`
+}
+
+type certBag struct {
+	ID   asn1.ObjectIdentifier
+	Data []byte `asn1:"tag:0,explicit"`
+}
+
+type encryptedPrivateKeyInfo struct {
+	Algorithm     pkix.AlgorithmIdentifier
+	EncryptedData []byte
+}
+
+type pbeParams struct {
+	Salt       []byte
+	Iterations int
+}
+
+type pbes2Params struct {
+	KeyDerivationFunc pkix.AlgorithmIdentifier
+	EncryptionScheme  pkix.AlgorithmIdentifier
+}
+
+type pbkdf2Params struct {
+	Salt       []byte
+	Iterations int
+	KeyLength  int                      `asn1:"optional"`
+	PRF        pkix.AlgorithmIdentifier `asn1:"optional"`
+}
+
+// pkcs12Entry is a single key or certificate found in a PKCS#12 key store.
+type pkcs12Entry struct {
+	name  string
+	keyID []byte
+	key   interface{}
+	cert  *x509.Certificate
+}
+
+// decodePKCS12 decodes all the private keys and certificates held in the
+// PKCS#12 key store data, decrypting them with password.
+func decodePKCS12(data []byte, password string) ([]pkcs12Entry, error) {
+	pfx := pfxPDU{}
+	if rest, err := asn1.Unmarshal(data, &pfx); err != nil {
+		return nil, err
+	} else if len(rest) != 0 {
+		return nil, fmt.Errorf("Trailing data after PKCS#12 key store")
+	}
+	if pfx.Version != 3 {
+		return nil, fmt.Errorf("Unsupported PKCS#12 version %d", pfx.Version)
+	}
+	if !pfx.AuthSafe.ContentType.Equal(oidData) {
+		return nil, fmt.Errorf("Unsupported PKCS#12 content type %v", pfx.AuthSafe.ContentType)
+	}
+	authSafe := []byte{}
+	if _, err := asn1.Unmarshal(pfx.AuthSafe.Content.Bytes, &authSafe); err != nil {
+		return nil, err
+	}
+
+	bmpPassword := bmpString(password)
+	if len(pfx.MacData.Mac.Algorithm.Algorithm) > 0 {
+		if err := verifyPKCS12MAC(&pfx.MacData, authSafe, bmpPassword); err != nil {
+			return nil, err
+		}
+	}
+
+	contents := []contentInfo{}
+	if _, err := asn1.Unmarshal(authSafe, &contents); err != nil {
+		return nil, err
+	}
+
+	entries := []pkcs12Entry{}
+	for _, ci := range contents {
+		var bags []byte
+		switch {
+		case ci.ContentType.Equal(oidData):
+			if _, err := asn1.Unmarshal(ci.Content.Bytes, &bags); err != nil {
+				return nil, err
+			}
+		case ci.ContentType.Equal(oidEncryptedData):
+			ed := encryptedData{}
+			if _, err := asn1.Unmarshal(ci.Content.Bytes, &ed); err != nil {
+				return nil, err
+			}
+			var err error
+			info := ed.EncryptedContentInfo
+			if bags, err = pbeDecrypt(info.ContentEncryptionAlgorithm, info.EncryptedContent, password); err != nil {
+				return nil, err
+			}
+		default:
+			return nil, fmt.Errorf("Unsupported PKCS#12 content type %v", ci.ContentType)
+		}
+
+		safeBags := []safeBag{}
+		if _, err := asn1.Unmarshal(bags, &safeBags); err != nil {
+			return nil, err
+		}
+		for _, bag := range safeBags {
+			entry := pkcs12Entry{}
+			for _, attr := range bag.Attributes {
+				switch {
+				case attr.ID.Equal(oidFriendlyName):
+					name := asn1.RawValue{}
+					if _, err := asn1.Unmarshal(attr.Value.Bytes, &name); err == nil && name.Tag == asn1TagBMPString {
+						entry.name = decodeBMPString(name.Bytes)
+					}
+				case attr.ID.Equal(oidLocalKeyID):
+					asn1.Unmarshal(attr.Value.Bytes, &entry.keyID)
+				}
+			}
+			switch {
+			case bag.ID.Equal(oidCertBag):
+				cb := certBag{}
+				if _, err := asn1.Unmarshal(bag.Value.Bytes, &cb); err != nil {
+					return nil, err
+				}
+				if !cb.ID.Equal(oidX509Certificate) {
+					continue
+				}
+				cert, err := x509.ParseCertificate(cb.Data)
+				if err != nil {
+					return nil, err
+				}
+				entry.cert = cert
+			case bag.ID.Equal(oidKeyBag):
+				key, err := x509.ParsePKCS8PrivateKey(bag.Value.Bytes)
+				if err != nil {
+					return nil, err
+				}
+				entry.key = key
+			case bag.ID.Equal(oidShroudedKeyBag):
+				epki := encryptedPrivateKeyInfo{}
+				if _, err := asn1.Unmarshal(bag.Value.Bytes, &epki); err != nil {
+					return nil, err
+				}
+				decrypted, err := pbeDecrypt(epki.Algorithm, epki.EncryptedData, password)
+				if err != nil {
+					return nil, err
+				}
+				key, err := x509.ParsePKCS8PrivateKey(decrypted)
+				if err != nil {
+					return nil, err
+				}
+				entry.key = key
+			default:
+				continue
+			}
+			entries = append(entries, entry)
+		}
+	}
+	return entries, nil
+}
+
+func verifyPKCS12MAC(mac *macData, content, password []byte) error {
+	var h func() hash.Hash
+	var size int
+	switch alg := mac.Mac.Algorithm.Algorithm; {
+	case alg.Equal(oidSHA1):
+		h, size = sha1.New, sha1.Size
+	case alg.Equal(oidSHA256):
+		h, size = sha256.New, sha256.Size
+	default:
+		return fmt.Errorf("Unsupported PKCS#12 MAC algorithm %v", alg)
+	}
+	key := pkcs12KDF(h, password, mac.MacSalt, 3, mac.Iterations, size)
+	m := hmac.New(h, key)
+	m.Write(content)
+	if !hmac.Equal(m.Sum(nil), mac.Mac.Digest) {
+		return ErrIncorrectPassword
+	}
+	return nil
+}
+
+// pbeDecrypt decrypts the data using the password based encryption algorithm.
+func pbeDecrypt(alg pkix.AlgorithmIdentifier, data []byte, password string) ([]byte, error) {
+	var block cipher.Block
+	var iv []byte
+	switch {
+	case alg.Algorithm.Equal(oidPBEWithSHAAnd3DES), alg.Algorithm.Equal(oidPBEWithSHAAnd40BitRC2):
+		params := pbeParams{}
+		if _, err := asn1.Unmarshal(alg.Parameters.FullBytes, &params); err != nil {
+			return nil, err
+		}
+		pw := bmpString(password)
+		if alg.Algorithm.Equal(oidPBEWithSHAAnd3DES) {
+			key := pkcs12KDF(sha1.New, pw, params.Salt, 1, params.Iterations, 24)
+			var err error
+			if block, err = des.NewTripleDESCipher(key); err != nil {
+				return nil, err
+			}
+		} else {
+			key := pkcs12KDF(sha1.New, pw, params.Salt, 1, params.Iterations, 5)
+			block = newRC2(key, 40)
+		}
+		iv = pkcs12KDF(sha1.New, pw, params.Salt, 2, params.Iterations, 8)
+
+	case alg.Algorithm.Equal(oidPBES2):
+		params := pbes2Params{}
+		if _, err := asn1.Unmarshal(alg.Parameters.FullBytes, &params); err != nil {
+			return nil, err
+		}
+		if !params.KeyDerivationFunc.Algorithm.Equal(oidPBKDF2) {
+			return nil, fmt.Errorf("Unsupported key derivation function %v", params.KeyDerivationFunc.Algorithm)
+		}
+		kdf := pbkdf2Params{}
+		if _, err := asn1.Unmarshal(params.KeyDerivationFunc.Parameters.FullBytes, &kdf); err != nil {
+			return nil, err
+		}
+		prf := sha1.New
+		switch {
+		case len(kdf.PRF.Algorithm) == 0, kdf.PRF.Algorithm.Equal(oidHMACWithSHA1):
+		case kdf.PRF.Algorithm.Equal(oidHMACWithSHA256):
+			prf = sha256.New
+		default:
+			return nil, fmt.Errorf("Unsupported PBKDF2 PRF %v", kdf.PRF.Algorithm)
+		}
+		scheme := params.EncryptionScheme
+		if _, err := asn1.Unmarshal(scheme.Parameters.FullBytes, &iv); err != nil {
+			return nil, err
+		}
+		keyLen := 0
+		switch {
+		case scheme.Algorithm.Equal(oidAES128CBC):
+			keyLen = 16
+		case scheme.Algorithm.Equal(oidAES192CBC):
+			keyLen = 24
+		case scheme.Algorithm.Equal(oidAES256CBC):
+			keyLen = 32
+		case scheme.Algorithm.Equal(oidDESEDE3CBC):
+			keyLen = 24
+		default:
+			return nil, fmt.Errorf("Unsupported encryption scheme %v", scheme.Algorithm)
+		}
+		key := pbkdf2(prf, []byte(password), kdf.Salt, kdf.Iterations, keyLen)
+		var err error
+		if scheme.Algorithm.Equal(oidDESEDE3CBC) {
+			block, err = des.NewTripleDESCipher(key)
+		} else {
+			block, err = aes.NewCipher(key)
+		}
+		if err != nil {
+			return nil, err
+		}
+
+	default:
+		return nil, fmt.Errorf("Unsupported encryption algorithm %v", alg.Algorithm)
+	}
+
+	if len(data) == 0 || len(data)%block.BlockSize() != 0 || len(iv) != block.BlockSize() {
+		return nil, ErrIncorrectPassword
+	}
+	out := make([]byte, len(data))
+	cipher.NewCBCDecrypter(block, iv).CryptBlocks(out, data)
+	// Remove the PKCS#7 padding.
+	pad := int(out[len(out)-1])
+	if pad == 0 || pad > block.BlockSize() || !bytes.Equal(out[len(out)-pad:], bytes.Repeat([]byte{byte(pad)}, pad)) {
+		return nil, ErrIncorrectPassword
+	}
+	return out[:len(out)-pad], nil
+}
+
+// pkcs12KDF is the key derivation function described in RFC 7292, Appendix B.
+func pkcs12KDF(h func() hash.Hash, password, salt []byte, id byte, iterations, size int) []byte {
+	u := h().Size()
+	v := h().BlockSize()
+
+	fill := func(in []byte) []byte {
+		if len(in) == 0 {
+			return nil
+		}
+		out := make([]byte, v*((len(in)+v-1)/v))
+		for i := range out {
+			out[i] = in[i%len(in)]
+		}
+		return out
+	}
+	d := bytes.Repeat([]byte{id}, v)
+	i := append(fill(salt), fill(password)...)
+
+	out := make([]byte, 0, size+u)
+	one := big.NewInt(1)
+	for len(out) < size {
+		a := h()
+		a.Write(d)
+		a.Write(i)
+		ai := a.Sum(nil)
+		for j := 1; j < iterations; j++ {
+			a = h()
+			a.Write(ai)
+			ai = a.Sum(nil)
+		}
+		out = append(out, ai...)
+		if len(out) >= size {
+			break
+		}
+		// I_j = (I_j + B + 1) mod 2^(8v) for each v-byte block of I.
+		b := new(big.Int).SetBytes(fill(ai)[:v])
+		b.Add(b, one)
+		for j := 0; j < len(i); j += v {
+			ij := new(big.Int).SetBytes(i[j : j+v])
+			ij.Add(ij, b)
+			bs := ij.Bytes()
+			if len(bs) > v {
+				bs = bs[len(bs)-v:]
+			}
+			blk := i[j : j+v]
+			for k := range blk {
+				blk[k] = 0
+			}
+			copy(blk[v-len(bs):], bs)
+		}
+	}
+	return out[:size]
+}
+
+// pbkdf2 is the key derivation function described in RFC 8018, section 5.2.
+func pbkdf2(h func() hash.Hash, password, salt []byte, iterations, size int) []byte {
+	prf := hmac.New(h, password)
+	out := make([]byte, 0, size+prf.Size())
+	for block := uint32(1); len(out) < size; block++ {
+		prf.Reset()
+		prf.Write(salt)
+		prf.Write([]byte{byte(block >> 24), byte(block >> 16), byte(block >> 8), byte(block)})
+		u := prf.Sum(nil)
+		t := append([]byte{}, u...)
+		for i := 1; i < iterations; i++ {
+			prf.Reset()
+			prf.Write(u)
+			u = prf.Sum(u[:0])
+			for j := range t {
+				t[j] ^= u[j]
+			}
+		}
+		out = append(out, t...)
+	}
+	return out[:size]
+}
+
+// bmpString returns the password encoded as a null terminated, big-endian
+// UTF-16 string as required by PKCS#12.
+func bmpString(s string) []byte {
+	if s == "" {
+		return nil
+	}
+	u := utf16.Encode([]rune(s))
+	out := make([]byte, 0, 2*len(u)+2)
+	for _, c := range u {
+		out = append(out, byte(c>>8), byte(c))
+	}
+	return append(out, 0, 0)
+}
+
+func decodeBMPString(b []byte) string {
+	u := make([]uint16, 0, len(b)/2)
+	for i := 0; i+1 < len(b); i += 2 {
+		u = append(u, uint16(b[i])<<8|uint16(b[i+1]))
+	}
+	for len(u) > 0 && u[len(u)-1] == 0 {
+		u = u[:len(u)-1]
+	}
+	return string(utf16.Decode(u))
+}
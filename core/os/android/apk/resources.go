@@ -0,0 +1,655 @@
+// Copyright (C) 2017 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package apk
+
+import (
+	"archive/zip"
+	"encoding/binary"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+
+	"github.com/google/gapid/core/fault"
+	"github.com/google/gapid/core/fault/cause"
+	"github.com/google/gapid/core/log"
+	"github.com/google/gapid/core/os/android/binaryxml"
+)
+
+// AOSP references:
+// https://android.googlesource.com/platform/frameworks/base/+/master/include/androidfw/ResourceTypes.h
+// https://android.googlesource.com/platform/frameworks/base/+/master/libs/androidfw/ResourceTypes.cpp
+
+const (
+	resourcesPath = "resources.arsc"
+
+	ErrMissingResources = fault.Const("Couldn't find APK's resource table.")
+	ErrResourceNotFound = fault.Const("Resource not found.")
+	ErrResourceLoop     = fault.Const("Resource references form a loop.")
+)
+
+// Screen densities, in dots per inch, used by ResourceConfig.
+const (
+	DensityDefault = 0
+	DensityLow     = 120
+	DensityMedium  = 160
+	DensityHigh    = 240
+	DensityXHigh   = 320
+	DensityXXHigh  = 480
+	DensityXXXHigh = 640
+	DensityAny     = 0xfffe
+	DensityNone    = 0xffff
+)
+
+const (
+	resStringPoolType      = 0x0001
+	resTableType           = 0x0002
+	resTablePackageType    = 0x0200
+	resTableTypeType       = 0x0201
+	resTableTypeSpecType   = 0x0202
+	resChunkHeaderSize     = 8
+	resStringPoolUTF8      = 1 << 8
+	resTableTypeSparse     = 0x01
+	resTableTypeOffset16   = 0x02
+	resTableEntryComplex   = 0x0001
+	resTableEntryCompact   = 0x0008
+	resTableNoEntry        = 0xffffffff
+	resTableNoEntry16      = 0xffff
+	resValueTypeNull       = 0x00
+	resValueTypeReference  = 0x01
+	resValueTypeString     = 0x03
+	resValueTypeFloat      = 0x04
+	resValueTypeDynamicRef = 0x07
+	resValueTypeIntDec     = 0x10
+	resValueTypeIntHex     = 0x11
+	resValueTypeIntBoolean = 0x12
+	maxReferenceDepth      = 16
+)
+
+// ResourceConfig is the device configuration a resource value applies to, or
+// that a resource is being resolved for. Zero fields are unspecified.
+// Only the most commonly used qualifiers are represented.
+type ResourceConfig struct {
+	MCC                   uint16
+	MNC                   uint16
+	Language              string
+	Region                string
+	Orientation           uint8
+	UIMode                uint8
+	Density               uint16
+	SDKVersion            uint16
+	SmallestScreenWidthDp uint16
+	ScreenWidthDp         uint16
+	ScreenHeightDp        uint16
+}
+
+// ResourceValue is the value of a resource for a single configuration.
+// Value is one of string, bool, int, uint32 (hexadecimal integers, colors,
+// dimensions and fractions), float32, binaryxml.Reference or nil.
+type ResourceValue struct {
+	Config ResourceConfig
+	Value  interface{}
+}
+
+// Resources is a decoded resources.arsc resource table.
+type Resources struct {
+	packages map[uint8]*resourcePackage
+}
+
+type resourcePackage struct {
+	name  string
+	types map[uint8]*resourceType
+}
+
+type resourceType struct {
+	name    string
+	entries map[uint16]*resourceEntry
+}
+
+type resourceEntry struct {
+	key    string
+	values []ResourceValue
+}
+
+// GetResources returns the decoded resource table of the APK.
+func GetResources(ctx log.Context, files []*zip.File) (*Resources, error) {
+	for _, file := range files {
+		if file.Name != resourcesPath {
+			continue
+		}
+		f, err := file.Open()
+		if err != nil {
+			return nil, cause.Explain(ctx, err, "Couldn't open APK's resource table")
+		}
+		defer f.Close()
+		data, err := ioutil.ReadAll(f)
+		if err != nil {
+			return nil, cause.Explain(ctx, err, "Couldn't read APK's resource table")
+		}
+		return ReadResources(ctx, data)
+	}
+	return nil, cause.Wrap(ctx, ErrMissingResources)
+}
+
+// ReadResources decodes a compiled resources.arsc resource table.
+func ReadResources(ctx log.Context, data []byte) (*Resources, error) {
+	r, err := decodeResourceTable(data)
+	if err != nil {
+		return nil, cause.Explain(ctx, err, "Decoding resource table")
+	}
+	return r, nil
+}
+
+// Name returns the fully qualified name of the resource with the given
+// identifier, in the form 'package:type/name'.
+func (r *Resources) Name(id uint32) (string, bool) {
+	t, e := r.lookup(id)
+	if e == nil {
+		return "", false
+	}
+	return fmt.Sprintf("%s:%s/%s", r.packages[uint8(id>>24)].name, t.name, e.key), true
+}
+
+// Values returns the values of the resource with the given identifier for
+// every configuration in the table.
+func (r *Resources) Values(id uint32) []ResourceValue {
+	if _, e := r.lookup(id); e != nil {
+		return e.values
+	}
+	return nil
+}
+
+// Resolve returns the value of the resource with the given identifier that
+// best matches config. References to other resources are followed.
+func (r *Resources) Resolve(ctx log.Context, id uint32, config ResourceConfig) (interface{}, error) {
+	for depth := 0; depth < maxReferenceDepth; depth++ {
+		var best *ResourceValue
+		values := r.Values(id)
+		for i := range values {
+			v := &values[i]
+			if v.Config.matches(config) && (best == nil || v.Config.isBetterThan(best.Config, config)) {
+				best = v
+			}
+		}
+		if best == nil {
+			return nil, cause.Wrap(ctx, ErrResourceNotFound).With("id", fmt.Sprintf("0x%08x", id))
+		}
+		ref, ok := best.Value.(binaryxml.Reference)
+		if !ok {
+			return best.Value, nil
+		}
+		id = uint32(ref)
+	}
+	return nil, cause.Wrap(ctx, ErrResourceLoop).With("id", fmt.Sprintf("0x%08x", id))
+}
+
+// ResolveString resolves an attribute value in the text form returned by
+// binaryxml.Decode. Values of the form '@0x...' are looked up in the table
+// and converted to a string, all other values are returned unaltered.
+func (r *Resources) ResolveString(ctx log.Context, value string, config ResourceConfig) (string, error) {
+	if !strings.HasPrefix(value, "@0x") {
+		return value, nil
+	}
+	id, err := strconv.ParseUint(value[3:], 16, 32)
+	if err != nil {
+		return value, nil
+	}
+	v, err := r.Resolve(ctx, uint32(id), config)
+	if err != nil {
+		return "", err
+	}
+	if s, ok := v.(string); ok {
+		return s, nil
+	}
+	return fmt.Sprint(v), nil
+}
+
+func (r *Resources) lookup(id uint32) (*resourceType, *resourceEntry) {
+	p, ok := r.packages[uint8(id>>24)]
+	if !ok {
+		return nil, nil
+	}
+	t, ok := p.types[uint8(id>>16)]
+	if !ok {
+		return nil, nil
+	}
+	return t, t.entries[uint16(id)]
+}
+
+// matches returns true if a resource with the configuration c can be used on
+// a device with the configuration device.
+func (c ResourceConfig) matches(device ResourceConfig) bool {
+	switch {
+	case c.MCC != 0 && c.MCC != device.MCC,
+		c.MNC != 0 && c.MNC != device.MNC,
+		c.Language != "" && c.Language != device.Language,
+		c.Region != "" && c.Region != device.Region,
+		c.Orientation != 0 && c.Orientation != device.Orientation,
+		c.UIMode != 0 && c.UIMode != device.UIMode,
+		c.SmallestScreenWidthDp > device.SmallestScreenWidthDp,
+		c.ScreenWidthDp > device.ScreenWidthDp,
+		c.ScreenHeightDp > device.ScreenHeightDp:
+		return false
+	case device.SDKVersion != 0 && c.SDKVersion > device.SDKVersion:
+		// An unspecified device SDK version matches all resources.
+		return false
+	}
+	return true
+}
+
+// isBetterThan returns true if the configuration c is a better match than o
+// for a device with the configuration device. Both configurations must match
+// the device. Qualifiers are compared in the order of precedence used by
+// Android.
+func (c ResourceConfig) isBetterThan(o ResourceConfig, device ResourceConfig) bool {
+	type qualifier struct{ c, o uint32 }
+	str := func(s string) uint32 {
+		if s != "" {
+			return 1
+		}
+		return 0
+	}
+	for _, q := range []qualifier{
+		{uint32(c.MCC), uint32(o.MCC)},
+		{uint32(c.MNC), uint32(o.MNC)},
+		{str(c.Language), str(o.Language)},
+		{str(c.Region), str(o.Region)},
+		{uint32(c.SmallestScreenWidthDp), uint32(o.SmallestScreenWidthDp)},
+		{uint32(c.ScreenWidthDp), uint32(o.ScreenWidthDp)},
+		{uint32(c.ScreenHeightDp), uint32(o.ScreenHeightDp)},
+		{uint32(c.Orientation), uint32(o.Orientation)},
+		{uint32(c.UIMode), uint32(o.UIMode)},
+	} {
+		if q.c != q.o {
+			// Matching values are either equal or, for the screen sizes, the
+			// largest not exceeding the device's is most specific.
+			return q.c > q.o
+		}
+	}
+	if c.Density != o.Density {
+		return isBetterDensity(c.Density, o.Density, device.Density)
+	}
+	return c.SDKVersion > o.SDKVersion
+}
+
+// isBetterDensity returns true if resources for density a are a better match
+// than those for density b on a device with the requested density.
+func isBetterDensity(a, b, requested uint16) bool {
+	if a == DensityAny {
+		return true
+	}
+	if b == DensityAny {
+		return false
+	}
+	if requested == DensityDefault || requested == DensityAny {
+		requested = DensityMedium
+	}
+	if a == DensityDefault {
+		a = DensityMedium
+	}
+	if b == DensityDefault {
+		b = DensityMedium
+	}
+	if a == requested || b == requested {
+		return a == requested
+	}
+	h, l := a, b
+	if l > h {
+		h, l = l, h
+	}
+	aIsHigher := a == h
+	switch {
+	case l >= requested:
+		// Both are higher than requested, prefer the lowest.
+		return !aIsHigher
+	case h <= requested:
+		// Both are lower than requested, prefer the highest.
+		return aIsHigher
+	default:
+		// Prefer scaling the higher density down unless the lower density is
+		// much closer to the request.
+		r := uint32(requested)
+		if (2*uint32(l)-r)*uint32(h) > r*r {
+			return !aIsHigher
+		}
+		return aIsHigher
+	}
+}
+
+// resReader reads little-endian values from resource table data. Reads
+// outside of the data return zero values and record an error.
+type resReader struct {
+	data []byte
+	err  error
+}
+
+func (r *resReader) bytes(offset, size int) []byte {
+	if offset < 0 || size < 0 || offset+size > len(r.data) {
+		if r.err == nil {
+			r.err = fmt.Errorf("Read of %d bytes at offset %d exceeds chunk size %d", size, offset, len(r.data))
+		}
+		return make([]byte, size)
+	}
+	return r.data[offset : offset+size]
+}
+
+func (r *resReader) u8(offset int) uint8   { return r.bytes(offset, 1)[0] }
+func (r *resReader) u16(offset int) uint16 { return binary.LittleEndian.Uint16(r.bytes(offset, 2)) }
+func (r *resReader) u32(offset int) uint32 { return binary.LittleEndian.Uint32(r.bytes(offset, 4)) }
+
+// resChunk is a single chunk of a resource table, including its header.
+type resChunk struct {
+	ty         uint16
+	headerSize int
+	data       []byte
+}
+
+// decodeResChunks splits data into the sequence of chunks it contains.
+func decodeResChunks(data []byte) ([]resChunk, error) {
+	chunks := []resChunk{}
+	for len(data) > 0 {
+		r := &resReader{data: data}
+		c := resChunk{ty: r.u16(0), headerSize: int(r.u16(2))}
+		size := int(r.u32(4))
+		if r.err != nil {
+			return nil, r.err
+		}
+		if c.headerSize < resChunkHeaderSize || size < c.headerSize || size > len(data) {
+			return nil, fmt.Errorf("Invalid chunk type 0x%x with header size %d and size %d", c.ty, c.headerSize, size)
+		}
+		c.data = data[:size]
+		chunks = append(chunks, c)
+		data = data[size:]
+	}
+	return chunks, nil
+}
+
+func decodeResourceTable(data []byte) (*Resources, error) {
+	chunks, err := decodeResChunks(data)
+	if err != nil {
+		return nil, err
+	}
+	if len(chunks) != 1 || chunks[0].ty != resTableType {
+		return nil, fmt.Errorf("Data is not a resource table")
+	}
+	table := chunks[0]
+	chunks, err = decodeResChunks(table.data[table.headerSize:])
+	if err != nil {
+		return nil, err
+	}
+
+	out := &Resources{packages: map[uint8]*resourcePackage{}}
+	var values []string
+	for _, c := range chunks {
+		switch c.ty {
+		case resStringPoolType:
+			if values, err = decodeResStringPool(c); err != nil {
+				return nil, err
+			}
+		case resTablePackageType:
+			id, p, err := decodeResPackage(c, values)
+			if err != nil {
+				return nil, err
+			}
+			out.packages[id] = p
+		}
+	}
+	return out, nil
+}
+
+func decodeResStringPool(c resChunk) ([]string, error) {
+	r := &resReader{data: c.data}
+	count := int(r.u32(8))
+	flags := r.u32(16)
+	stringsStart := int(r.u32(20))
+	out := make([]string, count)
+	for i := range out {
+		offset := stringsStart + int(r.u32(c.headerSize+4*i))
+		if flags&resStringPoolUTF8 != 0 {
+			// The UTF-16 length is followed by the UTF-8 length.
+			_, n := r.utf8Length(offset)
+			length, m := r.utf8Length(offset + n)
+			out[i] = string(r.bytes(offset+n+m, length))
+		} else {
+			length, n := r.utf16Length(offset)
+			units := make([]uint16, length)
+			for j := range units {
+				units[j] = r.u16(offset + n + 2*j)
+			}
+			out[i] = string(utf16.Decode(units))
+		}
+		if r.err != nil {
+			return nil, r.err
+		}
+	}
+	return out, nil
+}
+
+// utf8Length returns the length encoded at offset and the size of the
+// encoding in a UTF-8 string pool.
+func (r *resReader) utf8Length(offset int) (int, int) {
+	l := int(r.u8(offset))
+	if l&0x80 != 0 {
+		return (l&0x7f)<<8 | int(r.u8(offset+1)), 2
+	}
+	return l, 1
+}
+
+// utf16Length returns the length encoded at offset and the size of the
+// encoding in a UTF-16 string pool.
+func (r *resReader) utf16Length(offset int) (int, int) {
+	l := int(r.u16(offset))
+	if l&0x8000 != 0 {
+		return (l&0x7fff)<<16 | int(r.u16(offset+2)), 4
+	}
+	return l, 2
+}
+
+func decodeResPackage(c resChunk, values []string) (uint8, *resourcePackage, error) {
+	r := &resReader{data: c.data}
+	id := r.u32(8)
+	nameUnits := make([]uint16, 0, 128)
+	for i := 0; i < 128; i++ {
+		u := r.u16(12 + 2*i)
+		if u == 0 {
+			break
+		}
+		nameUnits = append(nameUnits, u)
+	}
+	typeStringsOffset := int(r.u32(268))
+	keyStringsOffset := int(r.u32(276))
+	if r.err != nil {
+		return 0, nil, r.err
+	}
+	if id > 0xff {
+		return 0, nil, fmt.Errorf("Invalid package id 0x%x", id)
+	}
+
+	var typeNames, keys []string
+	for _, s := range []struct {
+		offset int
+		out    *[]string
+	}{{typeStringsOffset, &typeNames}, {keyStringsOffset, &keys}} {
+		if s.offset < c.headerSize || s.offset > len(c.data) {
+			return 0, nil, fmt.Errorf("Invalid string pool offset %d", s.offset)
+		}
+		chunks, err := decodeResChunks(c.data[s.offset:])
+		if err != nil {
+			return 0, nil, err
+		}
+		if len(chunks) == 0 || chunks[0].ty != resStringPoolType {
+			return 0, nil, fmt.Errorf("Expected string pool at offset %d", s.offset)
+		}
+		if *s.out, err = decodeResStringPool(chunks[0]); err != nil {
+			return 0, nil, err
+		}
+	}
+
+	chunks, err := decodeResChunks(c.data[c.headerSize:])
+	if err != nil {
+		return 0, nil, err
+	}
+	p := &resourcePackage{
+		name:  string(utf16.Decode(nameUnits)),
+		types: map[uint8]*resourceType{},
+	}
+	for _, c := range chunks {
+		if c.ty != resTableTypeType {
+			continue
+		}
+		if err := p.decodeType(c, typeNames, keys, values); err != nil {
+			return 0, nil, err
+		}
+	}
+	return uint8(id), p, nil
+}
+
+func (p *resourcePackage) decodeType(c resChunk, typeNames, keys, values []string) error {
+	r := &resReader{data: c.data}
+	id := r.u8(8)
+	flags := r.u8(9)
+	count := int(r.u32(12))
+	entriesStart := int(r.u32(16))
+	config := decodeResConfig(r, 20)
+	if r.err != nil {
+		return r.err
+	}
+	if id == 0 || int(id) > len(typeNames) {
+		return fmt.Errorf("Invalid type id %d", id)
+	}
+
+	t, ok := p.types[id]
+	if !ok {
+		t = &resourceType{name: typeNames[id-1], entries: map[uint16]*resourceEntry{}}
+		p.types[id] = t
+	}
+
+	for i := 0; i < count; i++ {
+		index, offset := uint16(i), uint32(0)
+		switch {
+		case flags&resTableTypeSparse != 0:
+			index = r.u16(c.headerSize + 4*i)
+			offset = uint32(r.u16(c.headerSize+4*i+2)) * 4
+		case flags&resTableTypeOffset16 != 0:
+			o := r.u16(c.headerSize + 2*i)
+			if o == resTableNoEntry16 {
+				continue
+			}
+			offset = uint32(o) * 4
+		default:
+			if offset = r.u32(c.headerSize + 4*i); offset == resTableNoEntry {
+				continue
+			}
+		}
+
+		e := entriesStart + int(offset)
+		entryFlags := r.u16(e + 2)
+		if r.err != nil {
+			return r.err
+		}
+		var key uint32
+		var value interface{}
+		switch {
+		case entryFlags&resTableEntryCompact != 0:
+			// Compact entries store the key index in the size field, the value
+			// type in the upper byte of the flags and the data in the key field.
+			key = uint32(r.u16(e))
+			value = decodeResValue(uint8(entryFlags>>8), r.u32(e+4), values)
+		case entryFlags&resTableEntryComplex != 0:
+			// Styles, arrays and plurals are not supported.
+			continue
+		default:
+			key = r.u32(e + 4)
+			size := int(r.u16(e))
+			value = decodeResValue(r.u8(e+size+3), r.u32(e+size+4), values)
+		}
+		if r.err != nil {
+			return r.err
+		}
+		if int(key) >= len(keys) {
+			return fmt.Errorf("Invalid key index %d", key)
+		}
+
+		entry, ok := t.entries[index]
+		if !ok {
+			entry = &resourceEntry{key: keys[key]}
+			t.entries[index] = entry
+		}
+		entry.values = append(entry.values, ResourceValue{Config: config, Value: value})
+	}
+	return nil
+}
+
+// decodeResConfig decodes the ResTable_config structure at offset.
+func decodeResConfig(r *resReader, offset int) ResourceConfig {
+	size := int(r.u32(offset))
+	data := make([]byte, 36)
+	copy(data, r.bytes(offset, size))
+	c := &resReader{data: data}
+	return ResourceConfig{
+		MCC:                   c.u16(4),
+		MNC:                   c.u16(6),
+		Language:              decodeResLocale(c.bytes(8, 2), 'a'),
+		Region:                decodeResLocale(c.bytes(10, 2), '0'),
+		Orientation:           c.u8(12),
+		Density:               c.u16(14),
+		SDKVersion:            c.u16(24),
+		UIMode:                c.u8(29),
+		SmallestScreenWidthDp: c.u16(30),
+		ScreenWidthDp:         c.u16(32),
+		ScreenHeightDp:        c.u16(34),
+	}
+}
+
+// decodeResLocale decodes a language or region code. Three letter codes are
+// packed into two bytes as 5 bit offsets from base.
+func decodeResLocale(in []byte, base byte) string {
+	switch {
+	case in[0] == 0:
+		return ""
+	case in[0]&0x80 == 0:
+		return string(in)
+	default:
+		return string([]byte{
+			base + in[1]&0x1f,
+			base + (in[1]&0xe0)>>5 + (in[0]&0x03)<<3,
+			base + (in[0]&0x7c)>>2,
+		})
+	}
+}
+
+func decodeResValue(ty uint8, data uint32, values []string) interface{} {
+	switch ty {
+	case resValueTypeNull:
+		return nil
+	case resValueTypeReference, resValueTypeDynamicRef:
+		return binaryxml.Reference(data)
+	case resValueTypeString:
+		if int(data) < len(values) {
+			return values[data]
+		}
+		return nil
+	case resValueTypeFloat:
+		return math.Float32frombits(data)
+	case resValueTypeIntDec:
+		return int(int32(data))
+	case resValueTypeIntBoolean:
+		return data != 0
+	default:
+		return data
+	}
+}
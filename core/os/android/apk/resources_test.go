@@ -0,0 +1,235 @@
+// Copyright (C) 2017 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package apk
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/binary"
+	"testing"
+	"unicode/utf16"
+
+	"github.com/google/gapid/core/assert"
+	"github.com/google/gapid/core/context/jot"
+	"github.com/google/gapid/core/log"
+	"github.com/google/gapid/core/os/android/binaryxml"
+	"github.com/google/gapid/core/os/android/manifest"
+)
+
+const (
+	testIconID    = 0x7f010000
+	testLabelID   = 0x7f020000
+	testAliasID   = 0x7f020001
+	testMissingID = 0x7f020002
+	testMDPIIcon  = "res/drawable-mdpi-v4/icon.png"
+	testXXHDPIcon = "res/drawable-xxhdpi-v4/icon.png"
+)
+
+// encodeResChunk returns a resource chunk with the given header fields, which
+// follow the chunk type and sizes, and body.
+func encodeResChunk(ty uint16, header, body []byte) []byte {
+	buf := &bytes.Buffer{}
+	write := func(v interface{}) { binary.Write(buf, binary.LittleEndian, v) }
+	write(ty)
+	write(uint16(8 + len(header)))
+	write(uint32(8 + len(header) + len(body)))
+	buf.Write(header)
+	buf.Write(body)
+	return buf.Bytes()
+}
+
+func encodeResStringPool(utf8 bool, strs ...string) []byte {
+	offsets, data := &bytes.Buffer{}, &bytes.Buffer{}
+	for _, s := range strs {
+		binary.Write(offsets, binary.LittleEndian, uint32(data.Len()))
+		if utf8 {
+			data.Write([]byte{byte(len([]rune(s))), byte(len(s))})
+			data.WriteString(s)
+			data.WriteByte(0)
+		} else {
+			units := utf16.Encode([]rune(s))
+			binary.Write(data, binary.LittleEndian, uint16(len(units)))
+			binary.Write(data, binary.LittleEndian, units)
+			binary.Write(data, binary.LittleEndian, uint16(0))
+		}
+	}
+	for data.Len()%4 != 0 {
+		data.WriteByte(0)
+	}
+	flags := uint32(0)
+	if utf8 {
+		flags = resStringPoolUTF8
+	}
+	header := &bytes.Buffer{}
+	binary.Write(header, binary.LittleEndian, []uint32{
+		uint32(len(strs)), 0, flags, uint32(28 + offsets.Len()), 0,
+	})
+	return encodeResChunk(resStringPoolType, header.Bytes(), append(offsets.Bytes(), data.Bytes()...))
+}
+
+// testResEntry is an entry of a resource type chunk. A nil value is encoded
+// as a missing entry.
+type testResEntry struct {
+	key   uint32
+	ty    uint8
+	value interface{}
+}
+
+func encodeResType(id uint8, language string, density uint16, entries ...*testResEntry) []byte {
+	config := make([]byte, 64)
+	binary.LittleEndian.PutUint32(config, 64)
+	copy(config[8:], language)
+	binary.LittleEndian.PutUint16(config[14:], density)
+
+	offsets, data := &bytes.Buffer{}, &bytes.Buffer{}
+	for _, e := range entries {
+		if e == nil {
+			binary.Write(offsets, binary.LittleEndian, uint32(resTableNoEntry))
+			continue
+		}
+		binary.Write(offsets, binary.LittleEndian, uint32(data.Len()))
+		binary.Write(data, binary.LittleEndian, []uint16{8, 0})
+		binary.Write(data, binary.LittleEndian, e.key)
+		binary.Write(data, binary.LittleEndian, []uint8{8, 0, 0, e.ty})
+		binary.Write(data, binary.LittleEndian, e.value)
+	}
+	header := &bytes.Buffer{}
+	binary.Write(header, binary.LittleEndian, []uint8{id, 0, 0, 0})
+	binary.Write(header, binary.LittleEndian, []uint32{uint32(len(entries)), uint32(84 + offsets.Len())})
+	header.Write(config)
+	return encodeResChunk(resTableTypeType, header.Bytes(), append(offsets.Bytes(), data.Bytes()...))
+}
+
+// encodeTestResources returns a resource table with an icon for two densities,
+// a label in two languages and a reference to the label.
+func encodeTestResources() []byte {
+	values := encodeResStringPool(true, "Example", "Exemple", testMDPIIcon, testXXHDPIcon)
+	types := encodeResStringPool(false, "drawable", "string")
+	keys := encodeResStringPool(true, "icon", "app_name", "alias")
+	body := bytes.Join([][]byte{
+		types,
+		keys,
+		encodeResType(1, "", DensityMedium, &testResEntry{0, resValueTypeString, uint32(2)}),
+		encodeResType(1, "", DensityXXHigh, &testResEntry{0, resValueTypeString, uint32(3)}),
+		encodeResType(2, "", 0,
+			&testResEntry{1, resValueTypeString, uint32(0)},
+			&testResEntry{2, resValueTypeReference, uint32(testLabelID)}),
+		encodeResType(2, "fr", 0, &testResEntry{1, resValueTypeString, uint32(1)}, nil),
+	}, nil)
+
+	name := make([]uint16, 128)
+	copy(name, utf16.Encode([]rune("com.example")))
+	header := &bytes.Buffer{}
+	binary.Write(header, binary.LittleEndian, uint32(0x7f))
+	binary.Write(header, binary.LittleEndian, name)
+	binary.Write(header, binary.LittleEndian, []uint32{288, 2, uint32(288 + len(types)), 3, 0})
+	pkg := encodeResChunk(resTablePackageType, header.Bytes(), body)
+
+	return encodeResChunk(resTableType, []byte{1, 0, 0, 0}, append(values, pkg...))
+}
+
+func TestResources(t_ *testing.T) {
+	ctx := log.Testing(t_)
+	res, err := ReadResources(ctx, encodeTestResources())
+	assert.For(ctx, "ReadResources").ThatError(err).Succeeded()
+
+	name, ok := res.Name(testAliasID)
+	assert.For(ctx, "Name found").That(ok).Equals(true)
+	assert.For(ctx, "Name").ThatString(name).Equals("com.example:string/alias")
+	_, ok = res.Name(testMissingID)
+	assert.For(ctx, "Name missing").That(ok).Equals(false)
+
+	assert.For(ctx, "Values").That(res.Values(testLabelID)).DeepEquals([]ResourceValue{
+		{ResourceConfig{}, "Example"},
+		{ResourceConfig{Language: "fr"}, "Exemple"},
+	})
+
+	for _, test := range []struct {
+		id       uint32
+		config   ResourceConfig
+		expected interface{}
+	}{
+		{testLabelID, ResourceConfig{}, "Example"},
+		{testLabelID, ResourceConfig{Language: "fr", Region: "CA"}, "Exemple"},
+		{testLabelID, ResourceConfig{Language: "de"}, "Example"},
+		{testAliasID, ResourceConfig{Language: "fr"}, "Exemple"},
+		{testIconID, ResourceConfig{}, testMDPIIcon},
+		{testIconID, ResourceConfig{Density: DensityLow}, testMDPIIcon},
+		{testIconID, ResourceConfig{Density: DensityHigh}, testXXHDPIcon},
+		{testIconID, ResourceConfig{Density: DensityXXXHigh}, testXXHDPIcon},
+	} {
+		got, err := res.Resolve(ctx, test.id, test.config)
+		assert.For(ctx, "Resolve %x %+v", test.id, test.config).ThatError(err).Succeeded()
+		assert.For(ctx, "Resolve %x %+v", test.id, test.config).That(got).Equals(test.expected)
+	}
+
+	_, err = res.Resolve(ctx, testMissingID, ResourceConfig{})
+	assert.For(ctx, "Resolve missing").ThatError(err).HasCause(ErrResourceNotFound)
+
+	str, err := res.ResolveString(ctx, "@0x7f020001", ResourceConfig{})
+	assert.For(ctx, "ResolveString").ThatError(err).Succeeded()
+	assert.For(ctx, "ResolveString").ThatString(str).Equals("Example")
+	str, err = res.ResolveString(ctx, "Literal", ResourceConfig{})
+	assert.For(ctx, "ResolveString literal").ThatError(err).Succeeded()
+	assert.For(ctx, "ResolveString literal").ThatString(str).Equals("Literal")
+
+	_, err = ReadResources(ctx, encodeTestResources()[:100])
+	assert.For(ctx, "Truncated").ThatError(err).Failed()
+}
+
+func TestAnalyze(t_ *testing.T) {
+	ctx := log.Testing(t_)
+	m := manifest.Manifest{
+		Package: "com.example",
+		Application: manifest.Application{
+			Label: "@0x7f020001",
+			Icon:  "@0x7f010000",
+			Activities: []manifest.Activity{{
+				Name: "com.example.Main",
+				IntentFilters: []manifest.IntentFilter{{
+					Action:     manifest.Action{Name: manifest.ActionMain},
+					Categories: []manifest.Category{{Name: manifest.CategoryLauncher}},
+				}},
+			}},
+		},
+	}
+	manifestData, err := binaryxml.Encode(ctx, m.XML())
+	if err != nil {
+		jot.Fatal(ctx, err, "Couldn't encode manifest")
+	}
+	buf := &bytes.Buffer{}
+	w := zip.NewWriter(buf)
+	for name, data := range map[string][]byte{
+		"AndroidManifest.xml": manifestData,
+		"resources.arsc":      encodeTestResources(),
+		testMDPIIcon:          []byte("mdpi"),
+		testXXHDPIcon:         []byte("xxhdpi"),
+	} {
+		fw, _ := w.Create(name)
+		fw.Write(data)
+	}
+	w.Close()
+
+	info, err := Analyze(ctx, buf.Bytes())
+	assert.For(ctx, "Analyze").ThatError(err).Succeeded()
+	assert.For(ctx, "Name").ThatString(info.Name).Equals("Example")
+	assert.For(ctx, "Icon").ThatString(info.Icon).Equals(testXXHDPIcon)
+
+	files, _ := Read(ctx, buf.Bytes())
+	path, data, err := Icon(ctx, files, DensityMedium)
+	assert.For(ctx, "Icon").ThatError(err).Succeeded()
+	assert.For(ctx, "Icon path").ThatString(path).Equals(testMDPIIcon)
+	assert.For(ctx, "Icon data").ThatString(string(data)).Equals("mdpi")
+}
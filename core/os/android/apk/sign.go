@@ -0,0 +1,435 @@
+// Copyright (C) 2017 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package apk
+
+import (
+	"archive/zip"
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/rand"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/base64"
+	"encoding/binary"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"regexp"
+	"strings"
+
+	"github.com/google/gapid/core/fault/cause"
+	"github.com/google/gapid/core/log"
+	"github.com/google/gapid/core/os/android/binaryxml"
+	"github.com/google/gapid/core/os/android/manifest"
+)
+
+// signatureFilePattern matches the files holding JAR signatures, which are
+// discarded and regenerated when an APK is signed.
+var signatureFilePattern = regexp.MustCompile(`^META-INF/([^/]+\.(SF|RSA|DSA|EC)|MANIFEST\.MF)$`)
+
+const (
+	jarManifestPath = "META-INF/MANIFEST.MF"
+	jarCreatedBy    = "1.0 (Android)"
+
+	// minSDKForSHA256 is the first API level to support SHA-256 digests and
+	// ECDSA signatures in JAR signed APKs.
+	minSDKForSHA256 = 18
+)
+
+var (
+	oidSignedData      = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 7, 2}
+	oidRSAEncryption   = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 1, 1}
+	oidECDSAWithSHA1   = asn1.ObjectIdentifier{1, 2, 840, 10045, 4, 1}
+	oidECDSAWithSHA256 = asn1.ObjectIdentifier{1, 2, 840, 10045, 4, 3, 2}
+
+	asn1Null = asn1.RawValue{Tag: asn1.TagNull}
+)
+
+// jarDigest is a digest algorithm used by JAR signing.
+type jarDigest struct {
+	name string // name used in the manifest attributes
+	hash crypto.Hash
+	oid  asn1.ObjectIdentifier
+}
+
+var (
+	jarDigestSHA1   = jarDigest{"SHA1", crypto.SHA1, oidSHA1}
+	jarDigestSHA256 = jarDigest{"SHA-256", crypto.SHA256, oidSHA256}
+)
+
+func (d jarDigest) sum(data []byte) []byte {
+	h := d.hash.New()
+	h.Write(data)
+	return h.Sum(nil)
+}
+
+func (d jarDigest) encoded(data []byte) string {
+	return base64.StdEncoding.EncodeToString(d.sum(data))
+}
+
+// Sign reads the APK at src, and writes it to dst signed with key using both
+// the v1 (JAR) and v2 APK signature schemes, and with all uncompressed files
+// aligned. Any existing signatures are discarded.
+func Sign(ctx log.Context, key *Key, src, dst string) error {
+	return rewrite(ctx, key, src, dst, nil)
+}
+
+// rewrite reads the APK at src, optionally modifying the contents of its files
+// with modify, and writes it to dst aligned and signed with key.
+// modify is passed the name and uncompressed data of each file, and returns
+// the new data, or nil if the file is unchanged.
+func rewrite(ctx log.Context, key *Key, src, dst string, modify func(name string, data []byte) ([]byte, error)) error {
+	ctx = ctx.S("src", src).S("dst", dst)
+	apkData, err := ioutil.ReadFile(src)
+	if err != nil {
+		return cause.Explain(ctx, err, "Couldn't read APK")
+	}
+	files, err := Read(ctx, apkData)
+	if err != nil {
+		return err
+	}
+
+	type entry struct {
+		file     *zip.File
+		data     []byte
+		modified bool
+	}
+	entries := []*entry{}
+	minSDK := 1
+	for _, f := range files {
+		if signatureFilePattern.MatchString(f.Name) {
+			ctx.Debug().Logf("Skipping file %s", f.Name)
+			continue
+		}
+		e := &entry{file: f}
+		if !strings.HasSuffix(f.Name, "/") {
+			if e.data, err = readZipFile(f); err != nil {
+				return cause.Explain(ctx, err, "Couldn't read APK file").With("name", f.Name)
+			}
+			if modify != nil {
+				data, err := modify(f.Name, e.data)
+				if err != nil {
+					return err
+				}
+				if data != nil {
+					e.data, e.modified = data, true
+				}
+			}
+		}
+		if f.Name == mainfestPath {
+			xml, err := binaryxml.Decode(ctx, e.data)
+			if err != nil {
+				return err
+			}
+			m, err := manifest.Parse(ctx, xml)
+			if err != nil {
+				return err
+			}
+			minSDK = m.SDK.MinSDKVersion
+		}
+		entries = append(entries, e)
+	}
+
+	digest := jarDigestSHA1
+	if minSDK >= minSDKForSHA256 {
+		digest = jarDigestSHA256
+	}
+	if _, isEC := key.PrivateKey.(*ecdsa.PrivateKey); isEC && minSDK < minSDKForSHA256 {
+		return cause.Wrap(ctx, ErrUnsupportedKey).With("minSdkVersion", minSDK)
+	}
+
+	// jarsigner and apksigner put the signature files first.
+	z := &zipWriter{}
+	names := []string{}
+	contents := map[string][]byte{}
+	for _, e := range entries {
+		if e.data != nil {
+			names = append(names, e.file.Name)
+			contents[e.file.Name] = e.data
+		}
+	}
+	if err := signV1(key, digest, names, contents, z); err != nil {
+		return cause.Explain(ctx, err, "Couldn't create JAR signature")
+	}
+	for _, e := range entries {
+		if e.modified {
+			err = z.add(e.file.Name, e.file.Method, e.data)
+		} else {
+			err = z.copy(e.file, bytes.NewReader(apkData))
+		}
+		if err != nil {
+			return cause.Explain(ctx, err, "Couldn't write APK file").With("name", e.file.Name)
+		}
+	}
+
+	unsigned, centralDirectory := z.close()
+	signed, err := signV2(key, unsigned, centralDirectory)
+	if err != nil {
+		return cause.Explain(ctx, err, "Couldn't create APK signature scheme v2 signature")
+	}
+	if err := ioutil.WriteFile(dst, signed, 0666); err != nil {
+		return cause.Explain(ctx, err, "Couldn't write APK")
+	}
+	return nil
+}
+
+func readZipFile(f *zip.File) ([]byte, error) {
+	r, err := f.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	return ioutil.ReadAll(r)
+}
+
+// signV1 adds the JAR signature files for the named files to z.
+// See https://docs.oracle.com/javase/8/docs/technotes/guides/jar/jar.html#Signed_JAR_File
+func signV1(key *Key, digest jarDigest, names []string, contents map[string][]byte, z *zipWriter) error {
+	mf := &bytes.Buffer{}
+	writeJarAttribute(mf, "Manifest-Version", "1.0")
+	writeJarAttribute(mf, "Created-By", jarCreatedBy)
+	mf.WriteString("\r\n")
+
+	sections := &bytes.Buffer{}
+	for _, name := range names {
+		section := &bytes.Buffer{}
+		writeJarAttribute(section, "Name", name)
+		writeJarAttribute(section, digest.name+"-Digest", digest.encoded(contents[name]))
+		section.WriteString("\r\n")
+		mf.Write(section.Bytes())
+
+		writeJarAttribute(sections, "Name", name)
+		writeJarAttribute(sections, digest.name+"-Digest", digest.encoded(section.Bytes()))
+		sections.WriteString("\r\n")
+	}
+
+	sf := &bytes.Buffer{}
+	writeJarAttribute(sf, "Signature-Version", "1.0")
+	writeJarAttribute(sf, "Created-By", jarCreatedBy)
+	writeJarAttribute(sf, digest.name+"-Digest-Manifest", digest.encoded(mf.Bytes()))
+	// Tells verifiers that support the v2 scheme that the APK must have a v2
+	// signature, preventing the v2 signature being stripped.
+	writeJarAttribute(sf, "X-Android-APK-Signed", "2")
+	sf.WriteString("\r\n")
+	sf.Write(sections.Bytes())
+
+	block, ext, err := pkcs7Sign(key, digest, sf.Bytes())
+	if err != nil {
+		return err
+	}
+
+	if err := z.add(jarManifestPath, zip.Deflate, mf.Bytes()); err != nil {
+		return err
+	}
+	if err := z.add("META-INF/CERT.SF", zip.Deflate, sf.Bytes()); err != nil {
+		return err
+	}
+	return z.add("META-INF/CERT."+ext, zip.Deflate, block)
+}
+
+// writeJarAttribute writes the manifest attribute, wrapping lines longer
+// than 72 bytes.
+func writeJarAttribute(buf *bytes.Buffer, name, value string) {
+	const maxLine = 72
+	line := name + ": " + value
+	for first := true; first || len(line) > 0; first = false {
+		n := maxLine
+		if !first {
+			// Continuation lines start with a single space.
+			buf.WriteByte(' ')
+			n--
+		}
+		if n > len(line) {
+			n = len(line)
+		}
+		buf.WriteString(line[:n])
+		buf.WriteString("\r\n")
+		line = line[n:]
+	}
+}
+
+type pkcs7SignedData struct {
+	Version          int
+	DigestAlgorithms []pkix.AlgorithmIdentifier `asn1:"set"`
+	ContentInfo      struct{ ContentType asn1.ObjectIdentifier }
+	Certificates     asn1.RawValue
+	SignerInfos      []pkcs7SignerInfo `asn1:"set"`
+}
+
+type pkcs7SignerInfo struct {
+	Version                   int
+	IssuerAndSerialNumber     pkcs7IssuerAndSerial
+	DigestAlgorithm           pkix.AlgorithmIdentifier
+	DigestEncryptionAlgorithm pkix.AlgorithmIdentifier
+	EncryptedDigest           []byte
+}
+
+type pkcs7IssuerAndSerial struct {
+	Issuer       asn1.RawValue
+	SerialNumber *big.Int
+}
+
+// pkcs7Sign returns a detached PKCS#7 signature of data, and the file
+// extension used for the signature block.
+func pkcs7Sign(key *Key, digest jarDigest, data []byte) ([]byte, string, error) {
+	signature, err := key.PrivateKey.Sign(rand.Reader, digest.sum(data), digest.hash)
+	if err != nil {
+		return nil, "", err
+	}
+
+	ext := "RSA"
+	sigAlg := pkix.AlgorithmIdentifier{Algorithm: oidRSAEncryption, Parameters: asn1Null}
+	if _, isEC := key.PrivateKey.(*ecdsa.PrivateKey); isEC {
+		ext = "EC"
+		sigAlg = pkix.AlgorithmIdentifier{Algorithm: oidECDSAWithSHA1}
+		if digest.hash == crypto.SHA256 {
+			sigAlg.Algorithm = oidECDSAWithSHA256
+		}
+	}
+
+	digestAlg := pkix.AlgorithmIdentifier{Algorithm: digest.oid, Parameters: asn1Null}
+	sd := pkcs7SignedData{
+		Version:          1,
+		DigestAlgorithms: []pkix.AlgorithmIdentifier{digestAlg},
+		Certificates: asn1.RawValue{
+			Class:      asn1.ClassContextSpecific,
+			Tag:        0,
+			IsCompound: true,
+			Bytes:      key.Certificate.Raw,
+		},
+		SignerInfos: []pkcs7SignerInfo{{
+			Version: 1,
+			IssuerAndSerialNumber: pkcs7IssuerAndSerial{
+				Issuer:       asn1.RawValue{FullBytes: key.Certificate.RawIssuer},
+				SerialNumber: key.Certificate.SerialNumber,
+			},
+			DigestAlgorithm:           digestAlg,
+			DigestEncryptionAlgorithm: sigAlg,
+			EncryptedDigest:           signature,
+		}},
+	}
+	sd.ContentInfo.ContentType = oidData
+	content, err := asn1.Marshal(sd)
+	if err != nil {
+		return nil, "", err
+	}
+	out, err := asn1.Marshal(contentInfo{
+		ContentType: oidSignedData,
+		Content:     asn1.RawValue{Class: asn1.ClassContextSpecific, Tag: 0, IsCompound: true, Bytes: content},
+	})
+	if err != nil {
+		return nil, "", err
+	}
+	return out, ext, nil
+}
+
+// APK Signature Scheme v2.
+// See https://source.android.com/security/apksigning/v2
+const (
+	apkSigningBlockMagic        = "APK Sig Block 42"
+	apkSignatureSchemeV2BlockID = 0x7109871a
+	apkSignatureRSAPKCS1SHA256  = 0x0103
+	apkSignatureECDSASHA256     = 0x0201
+	apkChunkSize                = 1 << 20
+)
+
+// signV2 returns the zip archive apk, with the APK Signing Block holding the
+// v2 signature inserted before the central directory.
+func signV2(key *Key, apk []byte, centralDirectory int) ([]byte, error) {
+	algorithm := uint32(apkSignatureRSAPKCS1SHA256)
+	if _, isEC := key.PrivateKey.(*ecdsa.PrivateKey); isEC {
+		algorithm = apkSignatureECDSASHA256
+	}
+
+	end := len(apk) - zipEndSize
+	if binary.LittleEndian.Uint32(apk[end:]) != zipEndSignature {
+		return nil, fmt.Errorf("End of central directory not found")
+	}
+	digest := apkContentDigest(apk[:centralDirectory], apk[centralDirectory:end], apk[end:])
+
+	signedData := concat(
+		lengthPrefixed(lengthPrefixed(concat(uint32LE(algorithm), lengthPrefixed(digest)))),
+		lengthPrefixed(lengthPrefixed(key.Certificate.Raw)),
+		lengthPrefixed(nil), // additional attributes
+	)
+	hash := crypto.SHA256.New()
+	hash.Write(signedData)
+	signature, err := key.PrivateKey.Sign(rand.Reader, hash.Sum(nil), crypto.SHA256)
+	if err != nil {
+		return nil, err
+	}
+	signer := concat(
+		lengthPrefixed(signedData),
+		lengthPrefixed(lengthPrefixed(concat(uint32LE(algorithm), lengthPrefixed(signature)))),
+		lengthPrefixed(key.Certificate.RawSubjectPublicKeyInfo),
+	)
+	value := lengthPrefixed(lengthPrefixed(signer))
+
+	pair := concat(uint64LE(uint64(4+len(value))), uint32LE(apkSignatureSchemeV2BlockID), value)
+	size := uint64LE(uint64(len(pair) + 8 + len(apkSigningBlockMagic)))
+	block := concat(size, pair, size, []byte(apkSigningBlockMagic))
+
+	eocd := append([]byte{}, apk[end:]...)
+	binary.LittleEndian.PutUint32(eocd[16:], uint32(centralDirectory+len(block)))
+	return concat(apk[:centralDirectory], block, apk[centralDirectory:end], eocd), nil
+}
+
+// apkContentDigest returns the v2 scheme digest of the zip entries, central
+// directory and end of central directory sections of the APK.
+func apkContentDigest(sections ...[]byte) []byte {
+	chunks := [][]byte{}
+	for _, s := range sections {
+		for len(s) > 0 {
+			n := len(s)
+			if n > apkChunkSize {
+				n = apkChunkSize
+			}
+			chunks = append(chunks, s[:n])
+			s = s[n:]
+		}
+	}
+	top := crypto.SHA256.New()
+	top.Write([]byte{0x5a})
+	top.Write(uint32LE(uint32(len(chunks))))
+	for _, c := range chunks {
+		h := crypto.SHA256.New()
+		h.Write([]byte{0xa5})
+		h.Write(uint32LE(uint32(len(c))))
+		h.Write(c)
+		top.Write(h.Sum(nil))
+	}
+	return top.Sum(nil)
+}
+
+func concat(parts ...[]byte) []byte {
+	return bytes.Join(parts, nil)
+}
+
+func lengthPrefixed(data []byte) []byte {
+	return concat(uint32LE(uint32(len(data))), data)
+}
+
+func uint32LE(v uint32) []byte {
+	out := make([]byte, 4)
+	binary.LittleEndian.PutUint32(out, v)
+	return out
+}
+
+func uint64LE(v uint64) []byte {
+	out := make([]byte, 8)
+	binary.LittleEndian.PutUint64(out, v)
+	return out
+}
@@ -0,0 +1,332 @@
+// Copyright (C) 2017 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package apk
+
+import (
+	"archive/zip"
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/binary"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/gapid/core/assert"
+	"github.com/google/gapid/core/context/jot"
+	"github.com/google/gapid/core/log"
+)
+
+const longFileName = "res/drawable-xxxhdpi-v4/a_resource_with_a_name_long_enough_to_be_wrapped_in_the_manifest.png"
+
+// writeTestAPK writes an unsigned APK using the given binary manifest to path.
+func writeTestAPK(ctx log.Context, path, manifest string) {
+	manifestData, err := ioutil.ReadFile(manifest)
+	if err != nil {
+		jot.Fatal(ctx, err, "Couldn't read manifest")
+	}
+	buf := &bytes.Buffer{}
+	w := zip.NewWriter(buf)
+	for _, f := range []struct {
+		name   string
+		method uint16
+		data   []byte
+	}{
+		{"META-INF/MANIFEST.MF", zip.Deflate, []byte("Manifest-Version: 1.0\r\n\r\n")},
+		{"META-INF/OLD.RSA", zip.Deflate, []byte("stale signature")},
+		{"AndroidManifest.xml", zip.Deflate, manifestData},
+		{"classes.dex", zip.Deflate, bytes.Repeat([]byte("dex\n035\x00"), 1000)},
+		{"resources.arsc", zip.Store, bytes.Repeat([]byte{1, 2, 3}, 333)},
+		{"assets/", zip.Store, nil},
+		{"assets/odd.bin", zip.Store, []byte{1, 2, 3, 4, 5}},
+		{longFileName, zip.Store, []byte("not really a png")},
+		{"lib/armeabi-v7a/libfoo.so", zip.Store, bytes.Repeat([]byte{0x7f}, 5000)},
+	} {
+		fw, err := w.CreateHeader(&zip.FileHeader{Name: f.name, Method: f.method})
+		if err != nil {
+			jot.Fatal(ctx, err, "Couldn't create zip entry")
+		}
+		fw.Write(f.data)
+	}
+	w.Close()
+	ioutil.WriteFile(path, buf.Bytes(), 0666)
+}
+
+// readJarAttributes parses the manifest or signature file sections into a map
+// of section name to attributes. The main section has the name "".
+func readJarAttributes(data []byte) (map[string]map[string]string, map[string][]byte) {
+	attributes := map[string]map[string]string{}
+	raw := map[string][]byte{}
+	for _, section := range strings.SplitAfter(string(data), "\r\n\r\n") {
+		if section == "" {
+			continue
+		}
+		attrs := map[string]string{}
+		lines := strings.Replace(section, "\r\n ", "", -1)
+		for _, line := range strings.Split(strings.TrimSpace(lines), "\r\n") {
+			parts := strings.SplitN(line, ": ", 2)
+			attrs[parts[0]] = parts[1]
+		}
+		attributes[attrs["Name"]] = attrs
+		raw[attrs["Name"]] = []byte(section)
+	}
+	return attributes, raw
+}
+
+// verifyV1 checks the JAR signature of the APK.
+func verifyV1(ctx log.Context, apk []byte, digest jarDigest) {
+	files, err := Read(ctx, apk)
+	assert.For(ctx, "Read").ThatError(err).Succeeded()
+	contents := map[string][]byte{}
+	for _, f := range files {
+		contents[f.Name], _ = readZipFile(f)
+	}
+	mf, sf := contents["META-INF/MANIFEST.MF"], contents["META-INF/CERT.SF"]
+	assert.For(ctx, "Old signature removed").That(contents["META-INF/OLD.RSA"]).IsNil()
+
+	manifest, sections := readJarAttributes(mf)
+	for _, f := range files {
+		if strings.HasPrefix(f.Name, "META-INF/") || strings.HasSuffix(f.Name, "/") {
+			continue
+		}
+		assert.For(ctx, "Digest of %v", f.Name).ThatString(manifest[f.Name][digest.name+"-Digest"]).
+			Equals(digest.encoded(contents[f.Name]))
+	}
+
+	signature, _ := readJarAttributes(sf)
+	assert.For(ctx, "APK signed").ThatString(signature[""]["X-Android-APK-Signed"]).Equals("2")
+	assert.For(ctx, "Manifest digest").ThatString(signature[""][digest.name+"-Digest-Manifest"]).
+		Equals(digest.encoded(mf))
+	for name, section := range sections {
+		if name != "" {
+			assert.For(ctx, "Section digest of %v", name).ThatString(signature[name][digest.name+"-Digest"]).
+				Equals(digest.encoded(section))
+		}
+	}
+
+	var block []byte
+	for name, data := range contents {
+		if strings.HasPrefix(name, "META-INF/CERT.") && name != "META-INF/CERT.SF" {
+			block = data
+		}
+	}
+	ci := contentInfo{}
+	_, err = asn1.Unmarshal(block, &ci)
+	assert.For(ctx, "PKCS#7").ThatError(err).Succeeded()
+	sd := pkcs7SignedData{}
+	_, err = asn1.Unmarshal(ci.Content.Bytes, &sd)
+	assert.For(ctx, "SignedData").ThatError(err).Succeeded()
+	cert, err := x509.ParseCertificate(sd.Certificates.Bytes)
+	assert.For(ctx, "Certificate").ThatError(err).Succeeded()
+	algorithm := x509.SHA1WithRSA
+	switch {
+	case digest.hash == jarDigestSHA256.hash && cert.PublicKeyAlgorithm == x509.ECDSA:
+		algorithm = x509.ECDSAWithSHA256
+	case digest.hash == jarDigestSHA256.hash:
+		algorithm = x509.SHA256WithRSA
+	}
+	err = cert.CheckSignature(algorithm, sf, sd.SignerInfos[0].EncryptedDigest)
+	assert.For(ctx, "JAR signature").ThatError(err).Succeeded()
+}
+
+// verifyV2 checks the APK Signature Scheme v2 signature of the APK.
+func verifyV2(ctx log.Context, apk []byte) {
+	end := len(apk) - zipEndSize
+	centralDirectory := int(binary.LittleEndian.Uint32(apk[end+16:]))
+	assert.For(ctx, "Magic").ThatString(string(apk[centralDirectory-16 : centralDirectory])).Equals(apkSigningBlockMagic)
+	size := int(binary.LittleEndian.Uint64(apk[centralDirectory-24:]))
+	blockStart := centralDirectory - size - 8
+
+	pairs := apk[blockStart+8 : centralDirectory-24]
+	var value []byte
+	for len(pairs) > 0 {
+		n := int(binary.LittleEndian.Uint64(pairs))
+		if binary.LittleEndian.Uint32(pairs[8:]) == apkSignatureSchemeV2BlockID {
+			value = pairs[12 : 8+n]
+		}
+		pairs = pairs[8+n:]
+	}
+	assert.For(ctx, "v2 block").That(value).IsNotNil()
+
+	next := func(b *[]byte) []byte {
+		n := binary.LittleEndian.Uint32(*b)
+		out := (*b)[4 : 4+n]
+		*b = (*b)[4+n:]
+		return out
+	}
+	signers := next(&value)
+	signer := next(&signers)
+	signedData := next(&signer)
+	signatures := next(&signer)
+	signature := next(&signatures)
+	algorithm := binary.LittleEndian.Uint32(signature)
+	signature = signature[4:]
+	signature = next(&signature)
+
+	data := signedData
+	digests := next(&data)
+	digest := next(&digests)
+	digest = digest[4:]
+	digest = next(&digest)
+	certs := next(&data)
+	cert, err := x509.ParseCertificate(next(&certs))
+	assert.For(ctx, "Certificate").ThatError(err).Succeeded()
+
+	sigAlg := x509.SHA256WithRSA
+	if algorithm == apkSignatureECDSASHA256 {
+		sigAlg = x509.ECDSAWithSHA256
+	}
+	err = cert.CheckSignature(sigAlg, signedData, signature)
+	assert.For(ctx, "v2 signature").ThatError(err).Succeeded()
+
+	eocd := append([]byte{}, apk[end:]...)
+	binary.LittleEndian.PutUint32(eocd[16:], uint32(blockStart))
+	expected := apkContentDigest(apk[:blockStart], apk[centralDirectory:end], eocd)
+	assert.For(ctx, "v2 digest").ThatSlice(digest).Equals(expected)
+}
+
+// verifyAlignment checks that all uncompressed entries are aligned.
+func verifyAlignment(ctx log.Context, apk []byte) {
+	files, _ := Read(ctx, apk)
+	for _, f := range files {
+		if f.Method != zip.Store {
+			continue
+		}
+		offset, _ := f.DataOffset()
+		align := int64(4)
+		if strings.HasSuffix(f.Name, ".so") {
+			align = 4096
+		}
+		assert.For(ctx, "Alignment of %v", f.Name).ThatInteger(int(offset % align)).Equals(0)
+	}
+}
+
+func TestDebugify(t_ *testing.T) {
+	ctx := log.Testing(t_)
+	tmp, err := ioutil.TempDir("", "apk_test")
+	if err != nil {
+		jot.Fatal(ctx, err, "Couldn't create temporary directory")
+	}
+	defer os.RemoveAll(tmp)
+	src, dst := filepath.Join(tmp, "src.apk"), filepath.Join(tmp, "dst.apk")
+	writeTestAPK(ctx, src, "testdata/manifest_sdk23.binxml")
+
+	debuggable, err := IsApkDebuggable(ctx, src)
+	assert.For(ctx, "Source debuggable").ThatError(err).Succeeded()
+	assert.For(ctx, "Source debuggable").That(debuggable).Equals(false)
+
+	err = ApkDebugifier{
+		Ctx:          ctx,
+		KeyPass:      testPassword,
+		KeyAlias:     testKeyAlias,
+		StorePass:    testPassword,
+		KeyStorePath: "testdata/debug.p12",
+	}.Run(src, dst)
+	assert.For(ctx, "Run").ThatError(err).Succeeded()
+
+	debuggable, err = IsApkDebuggable(ctx, dst)
+	assert.For(ctx, "Debuggable").ThatError(err).Succeeded()
+	assert.For(ctx, "Debuggable").That(debuggable).Equals(true)
+
+	apk, _ := ioutil.ReadFile(dst)
+	verifyAlignment(ctx, apk)
+	verifyV1(ctx, apk, jarDigestSHA256)
+	verifyV2(ctx, apk)
+}
+
+func TestSignSHA1(t_ *testing.T) {
+	ctx := log.Testing(t_)
+	tmp, err := ioutil.TempDir("", "apk_test")
+	if err != nil {
+		jot.Fatal(ctx, err, "Couldn't create temporary directory")
+	}
+	defer os.RemoveAll(tmp)
+	src, dst := filepath.Join(tmp, "src.apk"), filepath.Join(tmp, "dst.apk")
+	writeTestAPK(ctx, src, "testdata/manifest_sdk15.binxml")
+
+	key, err := LoadPEM(ctx, testKeyPath, testCertPath)
+	assert.For(ctx, "LoadPEM").ThatError(err).Succeeded()
+	err = Sign(ctx, key, src, dst)
+	assert.For(ctx, "Sign").ThatError(err).Succeeded()
+
+	apk, _ := ioutil.ReadFile(dst)
+	verifyAlignment(ctx, apk)
+	verifyV1(ctx, apk, jarDigestSHA1)
+	verifyV2(ctx, apk)
+
+	// Signing the signed APK again replaces the signatures.
+	resigned := filepath.Join(tmp, "resigned.apk")
+	err = Sign(ctx, key, dst, resigned)
+	assert.For(ctx, "Resign").ThatError(err).Succeeded()
+	apk, _ = ioutil.ReadFile(resigned)
+	verifyAlignment(ctx, apk)
+	verifyV1(ctx, apk, jarDigestSHA1)
+	verifyV2(ctx, apk)
+}
+
+func TestSignECDSA(t_ *testing.T) {
+	ctx := log.Testing(t_)
+	tmp, err := ioutil.TempDir("", "apk_test")
+	if err != nil {
+		jot.Fatal(ctx, err, "Couldn't create temporary directory")
+	}
+	defer os.RemoveAll(tmp)
+
+	priv, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "Android Debug"},
+		NotBefore:    time.Now(),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &priv.PublicKey, priv)
+	assert.For(ctx, "CreateCertificate").ThatError(err).Succeeded()
+	cert, _ := x509.ParseCertificate(der)
+	key := &Key{PrivateKey: priv, Certificate: cert}
+
+	src, dst := filepath.Join(tmp, "src.apk"), filepath.Join(tmp, "dst.apk")
+	writeTestAPK(ctx, src, "testdata/manifest_sdk23.binxml")
+	err = Sign(ctx, key, src, dst)
+	assert.For(ctx, "Sign").ThatError(err).Succeeded()
+
+	apk, _ := ioutil.ReadFile(dst)
+	verifyV1(ctx, apk, jarDigestSHA256)
+	verifyV2(ctx, apk)
+
+	// ECDSA JAR signatures are not supported before API 18.
+	writeTestAPK(ctx, src, "testdata/manifest_sdk15.binxml")
+	err = Sign(ctx, key, src, dst)
+	assert.For(ctx, "Sign old SDK").ThatError(err).HasCause(ErrUnsupportedKey)
+}
+
+func TestJarAttributeWrapping(t_ *testing.T) {
+	ctx := log.Testing(t_)
+	buf := &bytes.Buffer{}
+	writeJarAttribute(buf, "Name", longFileName)
+	lines := strings.Split(strings.TrimSuffix(buf.String(), "\r\n"), "\r\n")
+	assert.For(ctx, "Lines").ThatSlice(lines).IsLength(2)
+	assert.For(ctx, "First line").ThatInteger(len(lines[0])).Equals(72)
+	assert.For(ctx, "Continuation").ThatString(lines[1][:1]).Equals(" ")
+	unwrapped := strings.Replace(buf.String(), "\r\n ", "", -1)
+	assert.For(ctx, "Unwrapped").ThatString(unwrapped).Equals("Name: " + longFileName + "\r\n")
+}
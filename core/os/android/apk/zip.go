@@ -0,0 +1,226 @@
+// Copyright (C) 2017 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package apk
+
+import (
+	"archive/zip"
+	"bytes"
+	"compress/flate"
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"strings"
+)
+
+const (
+	zipLocalHeaderSignature   = 0x04034b50
+	zipCentralHeaderSignature = 0x02014b50
+	zipEndSignature           = 0x06054b50
+	zipLocalHeaderSize        = 30
+	zipEndSize                = 22
+
+	// zipAlignmentExtraID is the extra field used by zipalign and apksigner to
+	// pad local file headers so the entry data is aligned.
+	zipAlignmentExtraID   = 0xd935
+	zipAlignmentExtraSize = 6
+
+	// zipDataDescriptorFlag indicates the sizes and CRC follow the entry data.
+	zipDataDescriptorFlag = 0x8
+
+	// zipAlignment is the alignment of uncompressed entries, so they can be
+	// mmap'd directly from the APK.
+	zipAlignment = 4
+	// zipLibraryAlignment is the alignment of uncompressed native libraries,
+	// so they can be loaded directly from the APK.
+	zipLibraryAlignment = 4096
+)
+
+// zipDOSDate is the modification date used for the files added to the APK
+// (2008-01-01 00:00), which keeps the output deterministic.
+const (
+	zipDOSDate = (2008-1980)<<9 | 1<<5 | 1
+	zipDOSTime = 0
+)
+
+// zipEntry is a single file written by zipWriter.
+type zipEntry struct {
+	name             string
+	flags            uint16
+	method           uint16
+	modTime          uint16
+	modDate          uint16
+	crc32            uint32
+	compressedSize   uint32
+	uncompressedSize uint32
+	creatorVersion   uint16
+	externalAttrs    uint32
+	offset           uint32
+}
+
+// zipWriter writes a zip archive with the data of every uncompressed entry
+// aligned, equivalent to 'zipalign -p 4'.
+type zipWriter struct {
+	buf     bytes.Buffer
+	entries []zipEntry
+}
+
+// copy copies the zip file entry to the archive without recompressing it.
+func (z *zipWriter) copy(f *zip.File, src io.ReaderAt) error {
+	offset, err := f.DataOffset()
+	if err != nil {
+		return err
+	}
+	if f.CompressedSize64 > 0xffffffff || f.UncompressedSize64 > 0xffffffff {
+		return fmt.Errorf("Zip64 entry %s is not supported", f.Name)
+	}
+	e := zipEntry{
+		name:             f.Name,
+		flags:            f.Flags &^ zipDataDescriptorFlag,
+		method:           f.Method,
+		modTime:          f.ModifiedTime,
+		modDate:          f.ModifiedDate,
+		crc32:            f.CRC32,
+		compressedSize:   uint32(f.CompressedSize64),
+		uncompressedSize: uint32(f.UncompressedSize64),
+		creatorVersion:   f.CreatorVersion,
+		externalAttrs:    f.ExternalAttrs,
+	}
+	return z.write(e, io.NewSectionReader(src, offset, int64(f.CompressedSize64)))
+}
+
+// add compresses data with the given method and adds it to the archive.
+func (z *zipWriter) add(name string, method uint16, data []byte) error {
+	e := zipEntry{
+		name:             name,
+		method:           method,
+		modTime:          zipDOSTime,
+		modDate:          zipDOSDate,
+		crc32:            crc32.ChecksumIEEE(data),
+		uncompressedSize: uint32(len(data)),
+		creatorVersion:   20,
+	}
+	switch method {
+	case zip.Store:
+	case zip.Deflate:
+		compressed := &bytes.Buffer{}
+		w, err := flate.NewWriter(compressed, flate.BestCompression)
+		if err != nil {
+			return err
+		}
+		w.Write(data)
+		if err := w.Close(); err != nil {
+			return err
+		}
+		data = compressed.Bytes()
+	default:
+		return zip.ErrAlgorithm
+	}
+	e.compressedSize = uint32(len(data))
+	return z.write(e, bytes.NewReader(data))
+}
+
+func (z *zipWriter) write(e zipEntry, data io.Reader) error {
+	e.offset = uint32(z.buf.Len())
+
+	var extra []byte
+	if e.method == zip.Store {
+		align := zipAlignment
+		if strings.HasSuffix(e.name, ".so") {
+			align = zipLibraryAlignment
+		}
+		dataOffset := z.buf.Len() + zipLocalHeaderSize + len(e.name) + zipAlignmentExtraSize
+		padding := (align - dataOffset%align) % align
+		extra = make([]byte, zipAlignmentExtraSize+padding)
+		binary.LittleEndian.PutUint16(extra[0:], zipAlignmentExtraID)
+		binary.LittleEndian.PutUint16(extra[2:], uint16(len(extra)-4))
+		binary.LittleEndian.PutUint16(extra[4:], uint16(align))
+	}
+
+	w := &z.buf
+	binary.Write(w, binary.LittleEndian, struct {
+		Signature        uint32
+		Version          uint16
+		Flags            uint16
+		Method           uint16
+		ModTime          uint16
+		ModDate          uint16
+		CRC32            uint32
+		CompressedSize   uint32
+		UncompressedSize uint32
+		NameLength       uint16
+		ExtraLength      uint16
+	}{
+		zipLocalHeaderSignature, 20, e.flags, e.method, e.modTime, e.modDate,
+		e.crc32, e.compressedSize, e.uncompressedSize,
+		uint16(len(e.name)), uint16(len(extra)),
+	})
+	w.WriteString(e.name)
+	w.Write(extra)
+	if n, err := io.Copy(w, data); err != nil {
+		return err
+	} else if n != int64(e.compressedSize) {
+		return io.ErrUnexpectedEOF
+	}
+	z.entries = append(z.entries, e)
+	return nil
+}
+
+// close writes the central directory and end of central directory record,
+// returning the complete archive and the offset of the central directory.
+func (z *zipWriter) close() ([]byte, int) {
+	w := &z.buf
+	centralDirectory := w.Len()
+	for _, e := range z.entries {
+		binary.Write(w, binary.LittleEndian, struct {
+			Signature        uint32
+			CreatorVersion   uint16
+			Version          uint16
+			Flags            uint16
+			Method           uint16
+			ModTime          uint16
+			ModDate          uint16
+			CRC32            uint32
+			CompressedSize   uint32
+			UncompressedSize uint32
+			NameLength       uint16
+			ExtraLength      uint16
+			CommentLength    uint16
+			DiskNumber       uint16
+			InternalAttrs    uint16
+			ExternalAttrs    uint32
+			Offset           uint32
+		}{
+			zipCentralHeaderSignature, e.creatorVersion, 20, e.flags, e.method,
+			e.modTime, e.modDate, e.crc32, e.compressedSize, e.uncompressedSize,
+			uint16(len(e.name)), 0, 0, 0, 0, e.externalAttrs, e.offset,
+		})
+		w.WriteString(e.name)
+	}
+	binary.Write(w, binary.LittleEndian, struct {
+		Signature       uint32
+		DiskNumber      uint16
+		DirectoryDisk   uint16
+		DiskEntries     uint16
+		Entries         uint16
+		DirectorySize   uint32
+		DirectoryOffset uint32
+		CommentLength   uint16
+	}{
+		zipEndSignature, 0, 0, uint16(len(z.entries)), uint16(len(z.entries)),
+		uint32(w.Len() - centralDirectory), uint32(centralDirectory), 0,
+	})
+	return w.Bytes(), centralDirectory
+}
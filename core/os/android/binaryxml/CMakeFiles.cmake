@@ -18,11 +18,16 @@
 # build and the file will be recreated, check in the new version.
 
 set(files
+    android_attributes.go
     debuggable.go
     debuggable_test.go
     decode.go
     decode_test.go
     doc.go
+    document.go
+    document_test.go
+    encode.go
+    manifest_edit.go
     string_pool.go
     value.go
     xml_attribute.go
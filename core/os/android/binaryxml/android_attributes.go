@@ -0,0 +1,103 @@
+// Copyright (C) 2017 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package binaryxml
+
+// AndroidNamespace is the namespace URI of the android framework attributes.
+const AndroidNamespace = "http://schemas.android.com/apk/res/android"
+
+// androidAttribute describes an android framework attribute.
+type androidAttribute struct {
+	id uint32 // resource id of the attribute (android.R.attr)
+	// isString is true if the attribute only holds strings or references,
+	// so text values must not be interpreted as numbers or booleans.
+	isString bool
+}
+
+// androidAttributes are the framework attributes that can be used in
+// manifests, keyed by name.
+// See https://android.googlesource.com/platform/frameworks/base/+/master/core/res/res/values/public.xml
+var androidAttributes = map[string]androidAttribute{
+	"theme":                 {0x01010000, false},
+	"label":                 {0x01010001, true},
+	"icon":                  {0x01010002, false},
+	"name":                  {0x01010003, true},
+	"permission":            {0x01010006, true},
+	"readPermission":        {0x01010007, true},
+	"writePermission":       {0x01010008, true},
+	"protectionLevel":       {0x01010009, false},
+	"permissionGroup":       {0x0101000a, true},
+	"sharedUserId":          {0x0101000b, true},
+	"hasCode":               {0x0101000c, false},
+	"persistent":            {0x0101000d, false},
+	"enabled":               {0x0101000e, false},
+	"debuggable":            {0x0101000f, false},
+	"exported":              {0x01010010, false},
+	"process":               {0x01010011, true},
+	"taskAffinity":          {0x01010012, true},
+	"multiprocess":          {0x01010013, false},
+	"stateNotNeeded":        {0x01010016, false},
+	"excludeFromRecents":    {0x01010017, false},
+	"authorities":           {0x01010018, true},
+	"syncable":              {0x01010019, false},
+	"grantUriPermissions":   {0x0101001b, false},
+	"priority":              {0x0101001c, false},
+	"launchMode":            {0x0101001d, false},
+	"screenOrientation":     {0x0101001e, false},
+	"configChanges":         {0x0101001f, false},
+	"description":           {0x01010020, true},
+	"value":                 {0x01010024, false},
+	"resource":              {0x01010025, false},
+	"mimeType":              {0x01010026, true},
+	"scheme":                {0x01010027, true},
+	"host":                  {0x01010028, true},
+	"port":                  {0x01010029, true},
+	"path":                  {0x0101002a, true},
+	"pathPrefix":            {0x0101002b, true},
+	"pathPattern":           {0x0101002c, true},
+	"windowBackground":      {0x01010054, false},
+	"windowNoTitle":         {0x01010056, false},
+	"targetActivity":        {0x01010202, true},
+	"alwaysRetainTaskState": {0x01010203, false},
+	"minSdkVersion":         {0x0101020c, false},
+	"versionCode":           {0x0101021b, false},
+	"versionName":           {0x0101021c, true},
+	"windowSoftInputMode":   {0x0101022b, false},
+	"noHistory":             {0x0101022d, false},
+	"targetSdkVersion":      {0x01010270, false},
+	"maxSdkVersion":         {0x01010271, false},
+	"allowBackup":           {0x01010280, false},
+	"glEsVersion":           {0x01010281, false},
+	"smallScreens":          {0x01010284, false},
+	"normalScreens":         {0x01010285, false},
+	"largeScreens":          {0x01010286, false},
+	"required":              {0x0101028e, false},
+	"installLocation":       {0x010102b7, false},
+	"logo":                  {0x010102be, false},
+	"xlargeScreens":         {0x010102bf, false},
+	"hardwareAccelerated":   {0x010102d3, false},
+	"largeHeap":             {0x0101035a, false},
+	"parentActivityName":    {0x010103a7, true},
+	"isolatedProcess":       {0x010103a9, false},
+	"supportsRtl":           {0x010103af, false},
+	"requiredAccountType":   {0x010103d6, true},
+	"sspPrefix":             {0x010103e4, true},
+	"persistableMode":       {0x0101042d, false},
+	"documentLaunchMode":    {0x01010445, false},
+	"autoRemoveFromRecents": {0x01010447, false},
+	"extractNativeLibs":     {0x010104ea, false},
+	"usesCleartextTraffic":  {0x010104ec, false},
+	"autoVerify":            {0x010104ee, false},
+	"resizeableActivity":    {0x010104f6, false},
+}
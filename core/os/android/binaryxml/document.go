@@ -0,0 +1,275 @@
+// Copyright (C) 2017 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package binaryxml
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+
+	"github.com/google/gapid/core/fault/cause"
+	"github.com/google/gapid/core/log"
+)
+
+// Reference is an attribute value that refers to a resource by identifier.
+type Reference uint32
+
+// Document is a decoded binary XML document that can be inspected, modified
+// and encoded back to binary XML.
+type Document struct {
+	tree *xmlTree
+}
+
+// Attribute is a single attribute of an Element.
+// Value is one of string, bool, int, uint32 (hexadecimal integer), float32,
+// Reference or nil.
+type Attribute struct {
+	Namespace string
+	Name      string
+	Value     interface{}
+}
+
+// Element is an element of a Document.
+type Element struct {
+	doc   *Document
+	start *xmlStartElement
+}
+
+// DecodeDocument decodes the binary XML data to a Document.
+func DecodeDocument(ctx log.Context, data []byte) (*Document, error) {
+	tree, err := decodeXmlTree(bytes.NewReader(data))
+	if err != nil {
+		return nil, cause.Explain(ctx, err, "Decoding binary XML")
+	}
+	return &Document{tree}, nil
+}
+
+// Encode returns the document encoded as binary XML.
+func (d *Document) Encode() []byte {
+	return d.tree.encode()
+}
+
+// String returns the document as text XML.
+func (d *Document) String() string {
+	return d.tree.toXmlString()
+}
+
+// Root returns the root element of the document, or nil if the document has
+// no elements.
+func (d *Document) Root() *Element {
+	for _, c := range d.tree.chunks {
+		if se, ok := c.(*xmlStartElement); ok {
+			return &Element{d, se}
+		}
+	}
+	return nil
+}
+
+// Name returns the name of the element.
+func (e *Element) Name() string {
+	return e.start.name.get()
+}
+
+// span returns the indices of the element's start and end chunks, or -1 if
+// the element is no longer part of the document.
+func (e *Element) span() (int, int) {
+	start, depth := -1, 0
+	for i, c := range e.doc.tree.chunks {
+		if start < 0 {
+			if c == chunk(e.start) {
+				start = i
+			}
+			continue
+		}
+		switch c.(type) {
+		case *xmlStartElement:
+			depth++
+		case *xmlEndElement:
+			if depth == 0 {
+				return start, i
+			}
+			depth--
+		}
+	}
+	return -1, -1
+}
+
+// Children returns the direct child elements of the element.
+func (e *Element) Children() []*Element {
+	start, end := e.span()
+	if start < 0 {
+		return nil
+	}
+	out := []*Element{}
+	depth := 0
+	for _, c := range e.doc.tree.chunks[start+1 : end] {
+		switch c := c.(type) {
+		case *xmlStartElement:
+			if depth == 0 {
+				out = append(out, &Element{e.doc, c})
+			}
+			depth++
+		case *xmlEndElement:
+			depth--
+		}
+	}
+	return out
+}
+
+// Find returns all the descendants of the element that match the '/'
+// separated path of element names, relative to the element.
+// For example manifest.Find("application/activity").
+func (e *Element) Find(path string) []*Element {
+	elements := []*Element{e}
+	for _, name := range strings.Split(path, "/") {
+		matches := []*Element{}
+		for _, el := range elements {
+			for _, c := range el.Children() {
+				if c.Name() == name {
+					matches = append(matches, c)
+				}
+			}
+		}
+		elements = matches
+	}
+	return elements
+}
+
+// Attributes returns all the attributes of the element.
+func (e *Element) Attributes() []Attribute {
+	out := make([]Attribute, len(e.start.attributes))
+	for i, a := range e.start.attributes {
+		out[i] = Attribute{Namespace: a.namespaceURI(), Name: a.name.get(), Value: a.value()}
+	}
+	return out
+}
+
+// Attribute returns the value of the attribute with the given namespace URI
+// and name, and whether the attribute was found.
+func (e *Element) Attribute(namespace, name string) (interface{}, bool) {
+	if a := e.findAttribute(namespace, name); a != nil {
+		return a.value(), true
+	}
+	return nil, false
+}
+
+// SetAttribute sets the value of the attribute with the given namespace URI
+// and name, adding the attribute if it does not already exist.
+// Attributes in the AndroidNamespace must be known framework attributes, as
+// they are identified by resource id.
+func (e *Element) SetAttribute(namespace, name string, value interface{}) error {
+	tree := e.doc.tree
+	attr := xmlAttribute{
+		namespace: invalidStringPoolRef,
+		rawValue:  invalidStringPoolRef,
+	}
+	if namespace == AndroidNamespace {
+		info, ok := androidAttributes[name]
+		if !ok {
+			return fmt.Errorf("Unknown android attribute '%s'", name)
+		}
+		attr.name = tree.ensureAttributeNameMapsToResource(info.id, name)
+	} else {
+		attr.name = tree.stringRef(name)
+	}
+	if namespace != "" {
+		attr.namespace = tree.stringRef(namespace)
+	}
+
+	switch v := value.(type) {
+	case string:
+		attr.rawValue = tree.stringRef(v)
+		attr.typedValue = valStringID(attr.rawValue)
+	case bool:
+		attr.typedValue = valIntBoolean(v)
+	case int:
+		attr.typedValue = valIntDec(v)
+	case uint32:
+		attr.typedValue = valIntHex(v)
+	case float32:
+		attr.typedValue = valFloat(v)
+	case Reference:
+		attr.typedValue = valReference(v)
+	default:
+		return fmt.Errorf("Unsupported attribute value type %T", value)
+	}
+
+	if existing := e.findAttribute(namespace, name); existing != nil {
+		*existing = attr
+	} else {
+		e.start.addAttribute(&attr)
+	}
+	return nil
+}
+
+// RemoveAttribute removes the attribute with the given namespace URI and
+// name, returning true if the attribute was found.
+func (e *Element) RemoveAttribute(namespace, name string) bool {
+	for i, a := range e.start.attributes {
+		if a.namespaceURI() == namespace && a.name.get() == name {
+			e.start.attributes = append(e.start.attributes[:i], e.start.attributes[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// AddElement adds a new element with the given name as the last child of the
+// element, returning the new element.
+func (e *Element) AddElement(name string) *Element {
+	tree := e.doc.tree
+	_, end := e.span()
+	if end < 0 {
+		return nil
+	}
+	line := tree.chunks[end].(*xmlEndElement).lineNumber
+	start := &xmlStartElement{
+		lineNumber: line,
+		comment:    invalidStringPoolRef,
+		namespace:  invalidStringPoolRef,
+		name:       tree.stringRef(name),
+	}
+	start.setRoot(tree)
+	stop := &xmlEndElement{
+		lineNumber: line,
+		comment:    invalidStringPoolRef,
+		namespace:  invalidStringPoolRef,
+		name:       start.name,
+	}
+	stop.setRoot(tree)
+	chunks := append([]chunk{}, tree.chunks[:end]...)
+	chunks = append(chunks, start, stop)
+	tree.chunks = append(chunks, tree.chunks[end:]...)
+	return &Element{e.doc, start}
+}
+
+// Remove removes the element and all its descendants from the document.
+func (e *Element) Remove() {
+	start, end := e.span()
+	if start < 0 {
+		return
+	}
+	tree := e.doc.tree
+	tree.chunks = append(tree.chunks[:start], tree.chunks[end+1:]...)
+}
+
+func (e *Element) findAttribute(namespace, name string) *xmlAttribute {
+	for i, a := range e.start.attributes {
+		if a.namespaceURI() == namespace && a.name.get() == name {
+			return &e.start.attributes[i]
+		}
+	}
+	return nil
+}
@@ -0,0 +1,146 @@
+// Copyright (C) 2017 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package binaryxml
+
+import (
+	"io/ioutil"
+	"testing"
+
+	"github.com/google/gapid/core/assert"
+	"github.com/google/gapid/core/log"
+)
+
+var testManifests = []string{
+	"testdata/manifest1.binxml",
+	"testdata/manifest2.binxml",
+	"testdata/manifest3.binxml",
+	"testdata/manifest4.binxml",
+	"testdata/manifest5.binxml",
+	"testdata/manifest6.binxml",
+}
+
+func TestTextEncoding(t *testing.T) {
+	ctx := log.Testing(t)
+	for _, fn := range testManifests {
+		ctx := ctx.S("file", fn)
+		data, err := ioutil.ReadFile(fn)
+		assert.For(ctx, "ReadFile").ThatError(err).Succeeded()
+		text, err := Decode(ctx, data)
+		assert.For(ctx, "Decode").ThatError(err).Succeeded()
+
+		encoded, err := Encode(ctx, text)
+		assert.For(ctx, "Encode").ThatError(err).Succeeded()
+		got, err := Decode(ctx, encoded)
+		assert.For(ctx, "Decode encoded").ThatError(err).Succeeded()
+		assert.For(ctx, "Round trip").ThatString(got).Equals(text)
+	}
+}
+
+// reload encodes and decodes the document, checking the binary form is valid.
+func reload(ctx log.Context, d *Document) *Document {
+	out, err := DecodeDocument(ctx, d.Encode())
+	assert.For(ctx, "DecodeDocument").ThatError(err).Succeeded()
+	return out
+}
+
+func TestDocumentEditing(t *testing.T) {
+	ctx := log.Testing(t)
+	data, err := Encode(ctx, `<manifest xmlns:android="http://schemas.android.com/apk/res/android" package="com.example">
+  <application android:label="Example">
+    <activity android:name=".Main"/>
+    <activity android:name=".Other"/>
+  </application>
+</manifest>`)
+	assert.For(ctx, "Encode").ThatError(err).Succeeded()
+	doc, err := DecodeDocument(ctx, data)
+	assert.For(ctx, "DecodeDocument").ThatError(err).Succeeded()
+
+	app := doc.Root().Find("application")
+	assert.For(ctx, "Find application").ThatSlice(app).IsLength(1)
+	assert.For(ctx, "Find activities").ThatSlice(doc.Root().Find("application/activity")).IsLength(2)
+
+	err = app[0].SetAttribute(AndroidNamespace, "hasCode", false)
+	assert.For(ctx, "SetAttribute").ThatError(err).Succeeded()
+	err = app[0].SetAttribute(AndroidNamespace, "label", Reference(0x7f010000))
+	assert.For(ctx, "SetAttribute existing").ThatError(err).Succeeded()
+	err = app[0].SetAttribute(AndroidNamespace, "notAnAttribute", true)
+	assert.For(ctx, "SetAttribute unknown").ThatError(err).Failed()
+	err = app[0].SetAttribute("", "custom", 42)
+	assert.For(ctx, "SetAttribute custom").ThatError(err).Succeeded()
+
+	doc = reload(ctx, doc)
+	app = doc.Root().Find("application")
+	assert.For(ctx, "Attributes").That(app[0].Attributes()).DeepEquals([]Attribute{
+		{AndroidNamespace, "label", Reference(0x7f010000)},
+		{AndroidNamespace, "hasCode", false},
+		{"", "custom", 42},
+	})
+
+	assert.For(ctx, "RemoveAttribute").That(app[0].RemoveAttribute(AndroidNamespace, "hasCode")).Equals(true)
+	assert.For(ctx, "RemoveAttribute missing").That(app[0].RemoveAttribute(AndroidNamespace, "hasCode")).Equals(false)
+	_, found := app[0].Attribute(AndroidNamespace, "hasCode")
+	assert.For(ctx, "Attribute removed").That(found).Equals(false)
+
+	app[0].Find("activity")[0].Remove()
+	service := app[0].AddElement("service")
+	err = service.SetAttribute(AndroidNamespace, "name", ".Service")
+	assert.For(ctx, "SetAttribute service").ThatError(err).Succeeded()
+
+	doc = reload(ctx, doc)
+	children := doc.Root().Find("application")[0].Children()
+	assert.For(ctx, "Children").ThatSlice(children).IsLength(2)
+	assert.For(ctx, "Child 0").ThatString(children[0].Name()).Equals("activity")
+	assert.For(ctx, "Child 1").ThatString(children[1].Name()).Equals("service")
+	name, _ := children[1].Attribute(AndroidNamespace, "name")
+	assert.For(ctx, "Service name").That(name).Equals(".Service")
+}
+
+func TestManifestEditing(t *testing.T) {
+	ctx := log.Testing(t)
+	for _, fn := range []string{
+		"testdata/manifest1.binxml",
+		"testdata/manifest4.binxml",
+		"testdata/manifest6.binxml",
+	} {
+		ctx := ctx.S("file", fn)
+		data, err := ioutil.ReadFile(fn)
+		assert.For(ctx, "ReadFile").ThatError(err).Succeeded()
+		doc, err := DecodeDocument(ctx, data)
+		assert.For(ctx, "DecodeDocument").ThatError(err).Succeeded()
+
+		assert.For(ctx, "SetDebuggable").ThatError(SetDebuggable(doc, true)).Succeeded()
+		assert.For(ctx, "SetExtractNativeLibs").ThatError(SetExtractNativeLibs(doc, false)).Succeeded()
+		for i := 0; i < 2; i++ {
+			assert.For(ctx, "AddPermission").ThatError(AddPermission(doc, "android.permission.INTERNET")).Succeeded()
+			assert.For(ctx, "AddUsesLibrary").ThatError(AddUsesLibrary(doc, "libOpenCL.so", false)).Succeeded()
+		}
+
+		doc = reload(ctx, doc)
+		app := doc.Root().Find("application")[0]
+		debuggable, _ := app.Attribute(AndroidNamespace, "debuggable")
+		assert.For(ctx, "debuggable").That(debuggable).Equals(true)
+		extract, _ := app.Attribute(AndroidNamespace, "extractNativeLibs")
+		assert.For(ctx, "extractNativeLibs").That(extract).Equals(false)
+		assert.For(ctx, "uses-permission").That(findByName(doc.Root().Find("uses-permission"), "android.permission.INTERNET")).IsNotNil()
+		libs := app.Find("uses-library")
+		assert.For(ctx, "uses-library").ThatSlice(libs).IsLength(1)
+		required, _ := libs[0].Attribute(AndroidNamespace, "required")
+		assert.For(ctx, "required").That(required).Equals(false)
+	}
+
+	doc, err := encodeDocument(`<manifest package="com.example"/>`)
+	assert.For(ctx, "encodeDocument").ThatError(err).Succeeded()
+	assert.For(ctx, "No application").ThatError(SetDebuggable(doc, true)).Failed()
+}
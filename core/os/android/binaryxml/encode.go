@@ -0,0 +1,186 @@
+// Copyright (C) 2017 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package binaryxml
+
+import (
+	"encoding/xml"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/google/gapid/core/fault/cause"
+	"github.com/google/gapid/core/log"
+)
+
+// Encode compiles a text XML document, such as one returned by Decode, to
+// binary Android XML.
+//
+// Attribute values are stored as references if they have the form '@0x...'.
+// Otherwise values of android attributes are stored as booleans, decimal or
+// hexadecimal integers if they parse as such, unless the attribute only holds
+// strings. All other values are stored as strings.
+func Encode(ctx log.Context, text string) ([]byte, error) {
+	doc, err := encodeDocument(text)
+	if err != nil {
+		return nil, cause.Explain(ctx, err, "Encoding binary XML")
+	}
+	return doc.Encode(), nil
+}
+
+func newXmlTree() *xmlTree {
+	tree := &xmlTree{
+		strings:     &stringPool{},
+		resourceMap: &xmlResourceMap{},
+	}
+	tree.strings.setRoot(tree)
+	tree.resourceMap.setRoot(tree)
+	return tree
+}
+
+func encodeDocument(text string) (*Document, error) {
+	tree := newXmlTree()
+	doc := &Document{tree}
+	d := xml.NewDecoder(strings.NewReader(text))
+
+	type open struct {
+		start      *xmlStartElement
+		namespaces []*xmlStartNamespace
+	}
+	stack := []open{}
+
+	line, offset := 1, 0
+	for {
+		// Line numbers are those of the start of each token.
+		next := int(d.InputOffset())
+		line += strings.Count(text[offset:next], "\n")
+		offset = next
+
+		tok, err := d.Token()
+		switch err {
+		case nil:
+		case io.EOF:
+			if len(stack) > 0 {
+				return nil, io.ErrUnexpectedEOF
+			}
+			return doc, nil
+		default:
+			return nil, err
+		}
+
+		switch t := tok.(type) {
+		case xml.StartElement:
+			o := open{}
+			attrs := []xml.Attr{}
+			for _, a := range t.Attr {
+				if a.Name.Space != "xmlns" {
+					attrs = append(attrs, a)
+					continue
+				}
+				ns := &xmlStartNamespace{
+					lineNumber:      uint32(line),
+					comment:         invalidStringPoolRef,
+					namespacePrefix: tree.stringRef(a.Name.Local),
+					namespaceURI:    tree.stringRef(a.Value),
+				}
+				ns.setRoot(tree)
+				tree.chunks = append(tree.chunks, ns)
+				o.namespaces = append(o.namespaces, ns)
+			}
+
+			o.start = &xmlStartElement{
+				lineNumber: uint32(line),
+				comment:    invalidStringPoolRef,
+				namespace:  invalidStringPoolRef,
+				name:       tree.stringRef(t.Name.Local),
+			}
+			if t.Name.Space != "" {
+				o.start.namespace = tree.stringRef(t.Name.Space)
+			}
+			o.start.setRoot(tree)
+			tree.chunks = append(tree.chunks, o.start)
+
+			e := &Element{doc, o.start}
+			for _, a := range attrs {
+				value := parseAttributeValue(a.Name.Space, a.Name.Local, a.Value)
+				if err := e.SetAttribute(a.Name.Space, a.Name.Local, value); err != nil {
+					return nil, err
+				}
+			}
+			stack = append(stack, o)
+
+		case xml.EndElement:
+			o := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			end := &xmlEndElement{
+				lineNumber: uint32(line),
+				comment:    invalidStringPoolRef,
+				namespace:  o.start.namespace,
+				name:       o.start.name,
+			}
+			end.setRoot(tree)
+			tree.chunks = append(tree.chunks, end)
+			for i := len(o.namespaces) - 1; i >= 0; i-- {
+				ns := o.namespaces[i]
+				endNS := &xmlEndNamespace{
+					lineNumber:      uint32(line),
+					comment:         invalidStringPoolRef,
+					namespacePrefix: ns.namespacePrefix,
+					namespaceURI:    ns.namespaceURI,
+				}
+				endNS.setRoot(tree)
+				tree.chunks = append(tree.chunks, endNS)
+			}
+
+		case xml.CharData:
+			if s := strings.TrimSpace(string(t)); s != "" {
+				cdata := &xmlCData{
+					lineNumber: uint32(line),
+					comment:    invalidStringPoolRef,
+					data:       tree.stringRef(s),
+					typedValue: valNull(0),
+				}
+				cdata.setRoot(tree)
+				tree.chunks = append(tree.chunks, cdata)
+			}
+		}
+	}
+}
+
+// parseAttributeValue returns the typed value of the text attribute value.
+func parseAttributeValue(namespace, name, value string) interface{} {
+	if strings.HasPrefix(value, "@0x") {
+		if v, err := strconv.ParseUint(value[3:], 16, 32); err == nil {
+			return Reference(v)
+		}
+	}
+	if namespace != AndroidNamespace || androidAttributes[name].isString {
+		return value
+	}
+	switch {
+	case value == "true":
+		return true
+	case value == "false":
+		return false
+	case strings.HasPrefix(value, "0x"):
+		if v, err := strconv.ParseUint(value[2:], 16, 32); err == nil {
+			return uint32(v)
+		}
+	default:
+		if v, err := strconv.ParseInt(value, 10, 32); err == nil {
+			return int(v)
+		}
+	}
+	return value
+}
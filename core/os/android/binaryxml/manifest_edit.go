@@ -0,0 +1,101 @@
+// Copyright (C) 2017 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package binaryxml
+
+import "fmt"
+
+// manifestRoot returns the <manifest/> element of the document.
+func manifestRoot(d *Document) (*Element, error) {
+	root := d.Root()
+	if root == nil || root.Name() != "manifest" {
+		return nil, fmt.Errorf("Document has no manifest element")
+	}
+	return root, nil
+}
+
+// manifestApplication returns the <application/> element of the manifest.
+func manifestApplication(d *Document) (*Element, error) {
+	root, err := manifestRoot(d)
+	if err != nil {
+		return nil, err
+	}
+	apps := root.Find("application")
+	if len(apps) == 0 {
+		return nil, fmt.Errorf("Manifest has no application element")
+	}
+	return apps[0], nil
+}
+
+// findByName returns the first element of elements with the given
+// android:name attribute, or nil if there is none.
+func findByName(elements []*Element, name string) *Element {
+	for _, e := range elements {
+		if v, ok := e.Attribute(AndroidNamespace, "name"); ok && v == name {
+			return e
+		}
+	}
+	return nil
+}
+
+// SetDebuggable sets the android:debuggable attribute of the manifest's
+// <application/> element.
+func SetDebuggable(d *Document, debuggable bool) error {
+	app, err := manifestApplication(d)
+	if err != nil {
+		return err
+	}
+	return app.SetAttribute(AndroidNamespace, "debuggable", debuggable)
+}
+
+// SetExtractNativeLibs sets the android:extractNativeLibs attribute of the
+// manifest's <application/> element.
+func SetExtractNativeLibs(d *Document, extract bool) error {
+	app, err := manifestApplication(d)
+	if err != nil {
+		return err
+	}
+	return app.SetAttribute(AndroidNamespace, "extractNativeLibs", extract)
+}
+
+// AddPermission adds a <uses-permission/> element for permission to the
+// manifest, if it is not already requested.
+func AddPermission(d *Document, permission string) error {
+	root, err := manifestRoot(d)
+	if err != nil {
+		return err
+	}
+	if findByName(root.Find("uses-permission"), permission) != nil {
+		return nil
+	}
+	return root.AddElement("uses-permission").SetAttribute(AndroidNamespace, "name", permission)
+}
+
+// AddUsesLibrary adds a <uses-library/> element for library to the
+// manifest's <application/> element, or updates the android:required
+// attribute of an existing one.
+func AddUsesLibrary(d *Document, library string, required bool) error {
+	app, err := manifestApplication(d)
+	if err != nil {
+		return err
+	}
+	lib := findByName(app.Find("uses-library"), library)
+	if lib == nil {
+		lib = app.AddElement("uses-library")
+		if err := lib.SetAttribute(AndroidNamespace, "name", library); err != nil {
+			return err
+		}
+	}
+	return lib.SetAttribute(AndroidNamespace, "required", required)
+}
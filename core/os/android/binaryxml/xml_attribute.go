@@ -16,6 +16,7 @@ package binaryxml
 
 import (
 	"bytes"
+	"encoding/xml"
 	"strings"
 
 	"github.com/google/gapid/core/data/pod"
@@ -64,7 +65,7 @@ func (a xmlAttribute) xml(ctx *xmlContext) string {
 	b.WriteRune('=')
 	b.WriteRune('"')
 	if a.rawValue.isValid() {
-		b.WriteString(a.rawValue.get())
+		xml.EscapeText(&b, []byte(a.rawValue.get()))
 	} else {
 		b.WriteString(a.typedValue.String())
 	}
@@ -72,6 +73,38 @@ func (a xmlAttribute) xml(ctx *xmlContext) string {
 	return b.String()
 }
 
+// namespaceURI returns the namespace URI of the attribute, or an empty string
+// if the attribute has no namespace.
+func (a xmlAttribute) namespaceURI() string {
+	if a.namespace.isValid() {
+		return a.namespace.get()
+	}
+	return ""
+}
+
+// value returns the attribute's value as a Go value.
+func (a xmlAttribute) value() interface{} {
+	if a.rawValue.isValid() {
+		return a.rawValue.get()
+	}
+	switch v := a.typedValue.(type) {
+	case valIntDec:
+		return int(v)
+	case valIntHex:
+		return uint32(v)
+	case valReference:
+		return Reference(v)
+	case valStringID:
+		return stringPoolRef(v).get()
+	case valFloat:
+		return float32(v)
+	case valIntBoolean:
+		return bool(v)
+	default:
+		return nil
+	}
+}
+
 const xmlAttributeSize = 20
 
 func (a *xmlAttribute) decode(r pod.Reader, root *xmlTree) error {
@@ -122,6 +122,19 @@ func (c *xmlTree) decodeString(r pod.Reader) stringPoolRef {
 	}
 }
 
+// stringRef returns a reference to the string in the pool that is not
+// associated with a resource id, adding the string to the end of the pool if
+// there is no such string.
+func (xml *xmlTree) stringRef(str string) stringPoolRef {
+	mapped := len(xml.resourceMap.ids)
+	for i, ptr := range xml.strings.ptrs {
+		if ptr >= mapped && xml.strings.strings[ptr] == str {
+			return stringPoolRef{xml.strings, uint32(i)}
+		}
+	}
+	return xml.strings.insertStringAtIndex(str, len(xml.strings.strings))
+}
+
 // ensureAttributeMapsToResource finds a name mapping to the given resource id.
 // If such a name does not exist, it is added to the string pool after the last
 // string associated with a resource id, shifting all the strings after it. The
@@ -15,7 +15,9 @@
 package manifest
 
 import (
+	"bytes"
 	"encoding/xml"
+	"fmt"
 
 	"github.com/google/gapid/core/fault"
 	"github.com/google/gapid/core/fault/cause"
@@ -37,6 +39,8 @@ const (
 	CategoryLauncher = "android.intent.category.LAUNCHER"
 
 	ErrNoActivityFound = fault.Const("No suitable activity found")
+
+	androidNamespace = "http://schemas.android.com/apk/res/android"
 )
 
 // Manifest represents an APK's AndroidManifest.xml file.
@@ -45,6 +49,7 @@ type Manifest struct {
 	VersionCode int          `xml:"versionCode,attr"`
 	VersionName string       `xml:"versionName,attr"`
 	Application Application  `xml:"application"`
+	SDK         UsesSDK      `xml:"uses-sdk"`
 	Features    []Feature    `xml:"uses-feature"`
 	Permissions []Permission `xml:"uses-permission"`
 }
@@ -58,10 +63,112 @@ func Parse(ctx log.Context, s string) (Manifest, error) {
 	return m, nil
 }
 
+// XML returns the manifest as an AndroidManifest.xml text document, which can
+// be compiled to binary XML with binaryxml.Encode.
+// The version and SDK attributes are omitted if they are unset.
+func (m Manifest) XML() string {
+	b := &bytes.Buffer{}
+	attr := func(name, value string) {
+		fmt.Fprintf(b, " %s=\"", name)
+		xml.EscapeText(b, []byte(value))
+		b.WriteString("\"")
+	}
+	named := func(indent, element, name string) {
+		fmt.Fprintf(b, "%s<%s", indent, element)
+		attr("android:name", name)
+		b.WriteString("/>\n")
+	}
+
+	b.WriteString("<manifest")
+	attr("xmlns:android", androidNamespace)
+	attr("package", m.Package)
+	if m.VersionCode != 0 {
+		attr("android:versionCode", fmt.Sprint(m.VersionCode))
+	}
+	if m.VersionName != "" {
+		attr("android:versionName", m.VersionName)
+	}
+	b.WriteString(">\n")
+
+	b.WriteString("  <application")
+	if m.Application.Label != "" {
+		attr("android:label", m.Application.Label)
+	}
+	if m.Application.Icon != "" {
+		attr("android:icon", m.Application.Icon)
+	}
+	attr("android:debuggable", fmt.Sprint(m.Application.Debuggable))
+	b.WriteString(">\n")
+	for _, a := range m.Application.Activities {
+		b.WriteString("    <activity")
+		attr("android:name", a.Name)
+		b.WriteString(">\n")
+		for _, f := range a.IntentFilters {
+			b.WriteString("      <intent-filter>\n")
+			named("        ", "action", f.Action.Name)
+			for _, c := range f.Categories {
+				named("        ", "category", c.Name)
+			}
+			b.WriteString("      </intent-filter>\n")
+		}
+		b.WriteString("    </activity>\n")
+	}
+	for _, d := range m.Application.MetaData {
+		b.WriteString("    <meta-data")
+		attr("android:name", d.Name)
+		if d.Value != "" {
+			attr("android:value", d.Value)
+		}
+		if d.Resource != "" {
+			attr("android:resource", d.Resource)
+		}
+		b.WriteString("/>\n")
+	}
+	b.WriteString("  </application>\n")
+
+	if m.SDK != (UsesSDK{}) {
+		b.WriteString("  <uses-sdk")
+		if m.SDK.MinSDKVersion != 0 {
+			attr("android:minSdkVersion", fmt.Sprint(m.SDK.MinSDKVersion))
+		}
+		if m.SDK.TargetSDKVersion != 0 {
+			attr("android:targetSdkVersion", fmt.Sprint(m.SDK.TargetSDKVersion))
+		}
+		b.WriteString("/>\n")
+	}
+	for _, f := range m.Features {
+		b.WriteString("  <uses-feature")
+		if f.Name != "" {
+			attr("android:name", f.Name)
+		}
+		if f.GlEsVersion != "" {
+			attr("android:glEsVersion", f.GlEsVersion)
+		}
+		attr("android:required", fmt.Sprint(f.Required))
+		b.WriteString("/>\n")
+	}
+	for _, p := range m.Permissions {
+		named("  ", "uses-permission", p.Name)
+	}
+	b.WriteString("</manifest>\n")
+	return b.String()
+}
+
 // Application represents an application declared in an APK.
 type Application struct {
+	Label      string     `xml:"label,attr"`
+	Icon       string     `xml:"icon,attr"`
 	Activities []Activity `xml:"activity"`
 	Debuggable bool       `xml:"debuggable,attr"`
+	MetaData   []MetaData `xml:"meta-data"`
+}
+
+// MetaData represents a name-value pair declared in an Application.
+// Either Value or Resource is set.
+type MetaData struct {
+	Name     string `xml:"name,attr"`
+	Value    string `xml:"value,attr"`
+	Resource string `xml:"resource,attr"`
 }
 
 // Activity represents an activity declared in an Application.
@@ -86,6 +193,12 @@ type Category struct {
 	Name string `xml:"name,attr"`
 }
 
+// UsesSDK represents the range of API levels an APK is compatible with.
+type UsesSDK struct {
+	MinSDKVersion    int `xml:"minSdkVersion,attr"`
+	TargetSDKVersion int `xml:"targetSdkVersion,attr"`
+}
+
 // Feature represents a feature used by an APK.
 type Feature struct {
 	Name        string `xml:"name,attr"`
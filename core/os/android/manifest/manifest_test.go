@@ -19,6 +19,7 @@ import (
 
 	"github.com/google/gapid/core/assert"
 	"github.com/google/gapid/core/log"
+	"github.com/google/gapid/core/os/android/binaryxml"
 	"github.com/google/gapid/core/os/android/manifest"
 )
 
@@ -66,6 +67,8 @@ func TestParseManifest(_t *testing.T) {
 		VersionCode: 11,
 		VersionName: "1.0",
 		Application: manifest.Application{
+			Label: "@string/app_name",
+			Icon:  "@drawable/ic_launcher",
 			Activities: []manifest.Activity{
 				{
 					Name: "BobsGame",
@@ -87,6 +90,10 @@ func TestParseManifest(_t *testing.T) {
 				},
 			},
 		},
+		SDK: manifest.UsesSDK{
+			MinSDKVersion:    15,
+			TargetSDKVersion: 21,
+		},
 		Features: []manifest.Feature{
 			{
 				GlEsVersion: "0x00020000",
@@ -107,3 +114,69 @@ func TestParseManifest(_t *testing.T) {
 	}
 	assert.With(ctx).That(got).DeepEquals(expected)
 }
+
+func TestManifestXML(_t *testing.T) {
+	ctx := log.Testing(_t)
+	m := manifest.Manifest{
+		Package:     "com.example.test",
+		VersionCode: 3,
+		VersionName: "1.0 <beta>",
+		Application: manifest.Application{
+			Label:      "@0x7f050000",
+			Icon:       "@0x7f020000",
+			Debuggable: true,
+			Activities: []manifest.Activity{
+				{
+					Name: "com.example.test.Main",
+					IntentFilters: []manifest.IntentFilter{
+						{
+							Action:     manifest.Action{Name: manifest.ActionMain},
+							Categories: []manifest.Category{{Name: manifest.CategoryLauncher}},
+						},
+					},
+				},
+			},
+			MetaData: []manifest.MetaData{
+				{Name: "com.example.VALUE", Value: "value"},
+				{Name: "com.example.RESOURCE", Resource: "@0x7f050001"},
+			},
+		},
+		SDK: manifest.UsesSDK{MinSDKVersion: 21, TargetSDKVersion: 25},
+		Features: []manifest.Feature{
+			{GlEsVersion: "0x30000", Required: true},
+			{Name: "android.hardware.vulkan.level"},
+		},
+		Permissions: []manifest.Permission{{Name: "android.permission.INTERNET"}},
+	}
+
+	text := m.XML()
+	got, err := manifest.Parse(ctx, text)
+	assert.With(ctx).ThatError(err).Succeeded()
+	assert.For(ctx, "Parse text").That(got).DeepEquals(m)
+
+	data, err := binaryxml.Encode(ctx, text)
+	assert.With(ctx).ThatError(err).Succeeded()
+	decoded, err := binaryxml.Decode(ctx, data)
+	assert.With(ctx).ThatError(err).Succeeded()
+	got, err = manifest.Parse(ctx, decoded)
+	assert.With(ctx).ThatError(err).Succeeded()
+	assert.For(ctx, "Parse binary").That(got).DeepEquals(m)
+}
+
+func TestManifestXMLUnset(_t *testing.T) {
+	ctx := log.Testing(_t)
+	m := manifest.Manifest{
+		Package: "com.example.test",
+		SDK:     manifest.UsesSDK{TargetSDKVersion: 25},
+	}
+	text := m.XML()
+	assert.For(ctx, "versionCode").ThatString(text).DoesNotContain("versionCode")
+	assert.For(ctx, "minSdkVersion").ThatString(text).DoesNotContain("minSdkVersion")
+	assert.For(ctx, "targetSdkVersion").ThatString(text).Contains(`android:targetSdkVersion="25"`)
+	got, err := manifest.Parse(ctx, text)
+	assert.With(ctx).ThatError(err).Succeeded()
+	assert.For(ctx, "Parse text").That(got).DeepEquals(m)
+
+	text = manifest.Manifest{Package: "com.example.test"}.XML()
+	assert.For(ctx, "uses-sdk").ThatString(text).DoesNotContain("uses-sdk")
+}
@@ -20,6 +20,7 @@ import (
 	"regexp"
 
 	"strconv"
+	"time"
 
 	"github.com/google/gapid/core/app/auth"
 	"github.com/google/gapid/core/log"
@@ -83,8 +84,16 @@ func StartWithEnv(ctx log.Context, name string, env *shell.Env, args ...string)
 	}
 }
 
+// Connect opens a connection to the process listening on port of the local
+// machine, sending the authToken.
 func Connect(port int, authToken auth.Token) (net.Conn, error) {
-	conn, err := net.Dial("tcp", fmt.Sprintf("localhost:%d", port))
+	return ConnectTo(fmt.Sprintf("localhost:%d", port), authToken)
+}
+
+// ConnectTo opens a connection to the process listening on the TCP address
+// (host:port), sending the authToken.
+func ConnectTo(address string, authToken auth.Token) (net.Conn, error) {
+	conn, err := net.Dial("tcp", address)
 	if err != nil {
 		return nil, err
 	}
@@ -93,3 +102,23 @@ func Connect(port int, authToken auth.Token) (net.Conn, error) {
 	}
 	return conn, err
 }
+
+// ConnectTimeout opens a connection to the process listening on the TCP
+// address (host:port), sending the authToken. Connecting fails if it takes
+// longer than timeout, and reads and writes on the returned connection fail
+// once timeout has elapsed since the call.
+func ConnectTimeout(address string, authToken auth.Token, timeout time.Duration) (net.Conn, error) {
+	conn, err := net.DialTimeout("tcp", address, timeout)
+	if err != nil {
+		return nil, err
+	}
+	if err := conn.SetDeadline(time.Now().Add(timeout)); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	if err := auth.Write(conn, authToken); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return conn, nil
+}
@@ -28,8 +28,15 @@ import com.google.common.util.concurrent.ListenableFuture;
 import com.google.gapid.proto.image.Image.Info2D;
 import com.google.gapid.proto.service.Service.Value;
 import com.google.gapid.proto.service.gfxapi.GfxAPI.Cubemap;
+import com.google.gapid.proto.service.gfxapi.GfxAPI.CubemapArray;
+import com.google.gapid.proto.service.gfxapi.GfxAPI.CubemapArrayLevel;
 import com.google.gapid.proto.service.gfxapi.GfxAPI.CubemapLevel;
+import com.google.gapid.proto.service.gfxapi.GfxAPI.Texture1D;
 import com.google.gapid.proto.service.gfxapi.GfxAPI.Texture2D;
+import com.google.gapid.proto.service.gfxapi.GfxAPI.Texture2DArray;
+import com.google.gapid.proto.service.gfxapi.GfxAPI.Texture2DArrayLevel;
+import com.google.gapid.proto.service.gfxapi.GfxAPI.Texture3D;
+import com.google.gapid.proto.service.gfxapi.GfxAPI.Texture3DLevel;
 import com.google.gapid.proto.service.path.Path;
 import com.google.gapid.server.Client;
 
@@ -56,8 +63,13 @@ public class FetchedImage implements MultiLevelImage {
   public static ListenableFuture<FetchedImage> load(Client client, Path.ResourceData imagePath) {
     return Futures.transformAsync(client.get(resourceInfo(imagePath)), value -> {
       switch (value.getValCase()) {
+        case TEXTURE_1D: return load(client, imagePath, getFormat(value.getTexture1D()));
         case TEXTURE_2D: return load(client, imagePath, getFormat(value.getTexture2D()));
+        case TEXTURE_3D: return load(client, imagePath, getFormat(value.getTexture3D()));
+        case TEXTURE_2D_ARRAY:
+          return load(client, imagePath, getFormat(value.getTexture2DArray()));
         case CUBEMAP: return load(client, imagePath, getFormat(value.getCubemap()));
+        case CUBEMAP_ARRAY: return load(client, imagePath, getFormat(value.getCubemapArray()));
         default:
           throw new UnsupportedOperationException("Unexpected resource type: " + value);
       }
@@ -68,8 +80,12 @@ public class FetchedImage implements MultiLevelImage {
       Client client, Path.ResourceData imagePath, Images.Format format) {
     return Futures.transform(client.get(imageData(imagePath, format.format)), value -> {
       switch (value.getValCase()) {
+        case TEXTURE_1D: return new FetchedImage(client, format, value.getTexture1D());
         case TEXTURE_2D: return new FetchedImage(client, format, value.getTexture2D());
+        case TEXTURE_3D: return new FetchedImage(client, format, value.getTexture3D());
+        case TEXTURE_2D_ARRAY: return new FetchedImage(client, format, value.getTexture2DArray());
         case CUBEMAP: return new FetchedImage(client, format, value.getCubemap());
+        case CUBEMAP_ARRAY: return new FetchedImage(client, format, value.getCubemapArray());
         default:
           throw new UnsupportedOperationException("Unexpected resource type: " + value);
       }
@@ -80,15 +96,34 @@ public class FetchedImage implements MultiLevelImage {
     return Images.Format.from(imageInfo.getFormat());
   }
 
+  private static Images.Format getFormat(Texture1D texture) {
+    return (texture.getLevelsCount() == 0) ? Images.Format.Color8 : getFormat(texture.getLevels(0));
+  }
+
   private static Images.Format getFormat(Texture2D texture) {
     return (texture.getLevelsCount() == 0) ? Images.Format.Color8 : getFormat(texture.getLevels(0));
   }
 
+  private static Images.Format getFormat(Texture3D texture) {
+    return (texture.getLevelsCount() == 0 || texture.getLevels(0).getSlicesCount() == 0) ?
+        Images.Format.Color8 : getFormat(texture.getLevels(0).getSlices(0));
+  }
+
+  private static Images.Format getFormat(Texture2DArray texture) {
+    return (texture.getLevelsCount() == 0 || texture.getLevels(0).getLayersCount() == 0) ?
+        Images.Format.Color8 : getFormat(texture.getLevels(0).getLayers(0));
+  }
+
   private static Images.Format getFormat(Cubemap cubemap) {
     return (cubemap.getLevelsCount() == 0) ?
         Images.Format.Color8 : getFormat(cubemap.getLevels(0).getNegativeZ());
   }
 
+  private static Images.Format getFormat(CubemapArray cubemap) {
+    return (cubemap.getLevelsCount() == 0 || cubemap.getLevels(0).getLayersCount() == 0) ?
+        Images.Format.Color8 : getFormat(cubemap.getLevels(0).getLayers(0).getNegativeZ());
+  }
+
   public FetchedImage(Client client, Images.Format format, Info2D imageInfo) {
     levels = new Level[] { new SingleFacedLevel(client, format, imageInfo) };
   }
@@ -101,6 +136,37 @@ public class FetchedImage implements MultiLevelImage {
     }
   }
 
+  public FetchedImage(Client client, Images.Format format, Texture1D texture) {
+    List<Info2D> infos = texture.getLevelsList();
+    levels = new Level[infos.size()];
+    for (int i = 0; i < infos.size(); i++) {
+      levels[i] = new SingleFacedLevel(client, format, infos.get(i));
+    }
+  }
+
+  /**
+   * Shows the middle depth slice of each level of the 3D texture.
+   */
+  public FetchedImage(Client client, Images.Format format, Texture3D texture) {
+    List<Texture3DLevel> infos = texture.getLevelsList();
+    levels = new Level[infos.size()];
+    for (int i = 0; i < infos.size(); i++) {
+      List<Info2D> slices = infos.get(i).getSlicesList();
+      levels[i] = new SingleFacedLevel(client, format, slices.get(slices.size() / 2));
+    }
+  }
+
+  /**
+   * Shows the first layer of each level of the array texture.
+   */
+  public FetchedImage(Client client, Images.Format format, Texture2DArray texture) {
+    List<Texture2DArrayLevel> infos = texture.getLevelsList();
+    levels = new Level[infos.size()];
+    for (int i = 0; i < infos.size(); i++) {
+      levels[i] = new SingleFacedLevel(client, format, infos.get(i).getLayers(0));
+    }
+  }
+
   public FetchedImage(Client client, Images.Format format, Cubemap cubemap) {
     List<CubemapLevel> infos = cubemap.getLevelsList();
     levels = new Level[infos.size()];
@@ -109,6 +175,17 @@ public class FetchedImage implements MultiLevelImage {
     }
   }
 
+  /**
+   * Shows the first cube of each level of the cube-map array.
+   */
+  public FetchedImage(Client client, Images.Format format, CubemapArray cubemap) {
+    List<CubemapArrayLevel> infos = cubemap.getLevelsList();
+    levels = new Level[infos.size()];
+    for (int i = 0; i < infos.size(); i++) {
+      levels[i] = new SixFacedLevel(client, format, infos.get(i).getLayers(0));
+    }
+  }
+
   @Override
   public int getLevelCount() {
     return levels.length;
@@ -122,7 +122,14 @@ public class Images {
     switch (format.getFormatCase()) {
       case UNCOMPRESSED:
         return getChannelCount(format.getUncompressed().getFormat(), interestedChannels);
+      case ETC2_R11_EAC:
+      case RGTC1_BC4:
+        return 1;
+      case ETC2_RG11_EAC:
+      case RGTC2_BC5:
+        return 2;
       case ATC_RGB_AMD:
+      case BPTC_BC6H:
       case ETC1_RGB8:
       case ETC2_RGB8:
       case S3_DXT1_RGB:
@@ -130,6 +137,7 @@ public class Images {
       case ASTC:
       case ATC_RGBA_EXPLICIT_ALPHA_AMD:
       case ATC_RGBA_INTERPOLATED_ALPHA_AMD:
+      case BPTC_BC7:
       case ETC2_RGBA8_EAC:
       case PNG:
       case S3_DXT1_RGBA:
@@ -156,8 +164,11 @@ public class Images {
     switch (format.getFormatCase()) {
       case UNCOMPRESSED:
         return are8BitsEnough(format.getUncompressed().getFormat(), interestedChannels);
+      case BPTC_BC6H:
+        // BC6H holds HDR values.
+        return false;
       default:
-        // All Compressed formats can fully be represented as 8 bits (at this time).
+        // All other compressed formats can fully be represented as 8 bits.
         return true;
     }
   }
@@ -263,6 +263,8 @@ public class TextureView extends Composite
       case Texture2DResource: return "2D";
       case Texture3DResource: return "3D";
       case CubemapResource: return "Cubemap";
+      case Texture2DArrayResource: return "2D Array";
+      case CubemapArrayResource: return "Cubemap Array";
       default: return null;
     }
   }
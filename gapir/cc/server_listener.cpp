@@ -39,9 +39,12 @@ const char kAuthTokenHeader[] = { 'A', 'U', 'T', 'H' };
 
 namespace gapir {
 
-ServerListener::ServerListener(std::unique_ptr<core::Connection> conn, uint64_t maxMemorySize) :
+ServerListener::ServerListener(std::unique_ptr<core::Connection> conn, uint64_t maxMemorySize,
+                               DeviceInstanceProvider deviceInstance) :
         mConn(std::move(conn)),
-        mMaxMemorySize(maxMemorySize) {
+        mMaxMemorySize(maxMemorySize),
+        mDeviceInstanceProvider(std::move(deviceInstance)),
+        mHasDeviceInstance(false) {
 }
 
 std::unique_ptr<ServerConnection> ServerListener::acceptConnection(int idleTimeoutMs, const char* authToken) {
@@ -95,6 +98,19 @@ std::unique_ptr<ServerConnection> ServerListener::acceptConnection(int idleTimeo
                 client->sendString("PONG");
                 break;
             }
+            case DEVICE_INFO: {
+                // Describing the device is slow, so it is only done once asked.
+                if (!mHasDeviceInstance && mDeviceInstanceProvider) {
+                    mDeviceInstance = mDeviceInstanceProvider();
+                    mHasDeviceInstance = true;
+                }
+                uint32_t size = static_cast<uint32_t>(mDeviceInstance.size());
+                if (!client->send(size) ||
+                    client->send(mDeviceInstance.data(), size) != size) {
+                    GAPID_WARNING("Failed to send device info");
+                }
+                break;
+            }
             default: {
                 GAPID_WARNING("Unknown connection type %d ignored", connectionType);
             }
@@ -17,7 +17,9 @@
 #ifndef GAPIR_GAZER_LISTENER_H
 #define GAPIR_GAZER_LISTENER_H
 
+#include <functional>
 #include <memory>
+#include <string>
 
 namespace core {
 
@@ -33,10 +35,17 @@ class ServerConnection;
 // Class for listening to incoming connections from the server.
 class ServerListener {
 public:
+    // DeviceInstanceProvider returns the serialized device.Instance proto
+    // describing this device.
+    typedef std::function<std::string()> DeviceInstanceProvider;
+
     // Construct a ServerListener using the specified connection.
     // maxMemorySize is the maximum memory size that can be reported as
-    // supported by this device.
-    explicit ServerListener(std::unique_ptr<core::Connection> conn, uint64_t maxMemorySize);
+    // supported by this device. deviceInstance is called on the first
+    // DEVICE_INFO request, and may be null if the device cannot describe
+    // itself.
+    explicit ServerListener(std::unique_ptr<core::Connection> conn, uint64_t maxMemorySize,
+                            DeviceInstanceProvider deviceInstance = nullptr);
 
     // Accept a new incoming connection on the underlying socket and create a ServerConnection over
     // the newly created socket object. idleTimeoutMs is the timeout in milliseconds to wait for
@@ -48,6 +57,7 @@ public:
         REPLAY_REQUEST   = 0,
         SHUTDOWN_REQUEST = 1,
         PING             = 2,
+        DEVICE_INFO      = 3,
     };
 
 private:
@@ -55,6 +65,11 @@ private:
     std::unique_ptr<core::Connection> mConn;
     // The maximum memory size that can be reported as supported by this device.
     uint64_t mMaxMemorySize;
+    // Provides the serialized device.Instance proto describing this device.
+    DeviceInstanceProvider mDeviceInstanceProvider;
+    // The cached result of mDeviceInstanceProvider.
+    std::string mDeviceInstance;
+    bool mHasDeviceInstance;
 };
 
 }  // namespace gapir
@@ -20,6 +20,8 @@
 set(files
     client.go
     doc.go
+    remote.go
+    remote_test.go
     session.go
 )
 set(dirs
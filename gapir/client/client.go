@@ -21,6 +21,7 @@ import (
 	"github.com/google/gapid/core/app"
 	"github.com/google/gapid/core/fault/cause"
 	"github.com/google/gapid/core/log"
+	"github.com/google/gapid/core/os/android/adb"
 	"github.com/google/gapid/core/os/device"
 	"github.com/google/gapid/core/os/device/bind"
 	"github.com/google/gapid/core/os/file"
@@ -59,14 +60,39 @@ func New(ctx log.Context) *Client {
 	return c
 }
 
+// Connector is the interface implemented by replay devices that open their own
+// connections instead of connecting to a GAPIR instance, such as the fake
+// replay devices used by tests.
+type Connector interface {
+	bind.Device
+	// Connect opens a connection that speaks the GAPIR replay protocol.
+	Connect(ctx log.Context, abi *device.ABI) (io.ReadWriteCloser, error)
+}
+
 type deviceArch struct {
-	d bind.Device
-	a device.Architecture
+	d        bind.Device
+	a        device.Architecture
+	instance int
 }
 
 // Connect opens a connection to the replay device.
 func (c *Client) Connect(ctx log.Context, d bind.Device, abi *device.ABI) (io.ReadWriteCloser, error) {
-	s, isNew, err := c.getOrCreateSession(ctx, d, abi)
+	return c.ConnectInstance(ctx, d, abi, 0)
+}
+
+// ConnectInstance opens a connection to the instance'th GAPIR instance on the
+// replay device. Each instance on the host device runs in its own process,
+// allowing replays to run in parallel. Android and remote devices only support
+// a single instance. Connectors are connected to directly, without a session.
+func (c *Client) ConnectInstance(ctx log.Context, d bind.Device, abi *device.ABI, instance int) (io.ReadWriteCloser, error) {
+	if d, ok := d.(Connector); ok {
+		if instance != 0 {
+			return nil, cause.Explain(ctx, nil, "Connectors only support a single instance").With("instance", instance)
+		}
+		return d.Connect(ctx, abi)
+	}
+
+	s, isNew, err := c.getOrCreateSession(ctx, d, abi, instance)
 	if err != nil {
 		return nil, err
 	}
@@ -80,7 +106,7 @@ func (c *Client) Connect(ctx log.Context, d bind.Device, abi *device.ABI) (io.Re
 	return s.connect(ctx)
 }
 
-func (c *Client) getOrCreateSession(ctx log.Context, d bind.Device, abi *device.ABI) (*session, bool, error) {
+func (c *Client) getOrCreateSession(ctx log.Context, d bind.Device, abi *device.ABI, instance int) (*session, bool, error) {
 	c.mutex.Lock()
 	defer c.mutex.Unlock()
 
@@ -88,7 +114,14 @@ func (c *Client) getOrCreateSession(ctx log.Context, d bind.Device, abi *device.
 		return nil, false, cause.Explain(ctx, nil, "Client has been shutdown")
 	}
 
-	key := deviceArch{d, abi.Architecture}
+	if _, isADB := d.(adb.Device); isADB && instance != 0 {
+		return nil, false, cause.Explain(ctx, nil, "Android devices only support a single GAPIR instance").With("instance", instance)
+	}
+	if _, isRemote := d.(*RemoteDevice); isRemote && instance != 0 {
+		return nil, false, cause.Explain(ctx, nil, "Remote devices only support a single GAPIR instance").With("instance", instance)
+	}
+
+	key := deviceArch{d, abi.Architecture, instance}
 	s, existing := c.sessions[key]
 	if existing {
 		return s, false, nil
@@ -0,0 +1,164 @@
+// Copyright (C) 2017 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package client
+
+import (
+	"sync"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/google/gapid/core/app/auth"
+	"github.com/google/gapid/core/context/jot"
+	"github.com/google/gapid/core/data/endian"
+	"github.com/google/gapid/core/event/task"
+	"github.com/google/gapid/core/fault"
+	"github.com/google/gapid/core/fault/cause"
+	"github.com/google/gapid/core/log"
+	"github.com/google/gapid/core/os/device"
+	"github.com/google/gapid/core/os/device/bind"
+	"github.com/google/gapid/core/os/process"
+	"github.com/google/gapid/gapis/replay/protocol"
+)
+
+// ErrNoDeviceInfo is returned when a remote GAPIR instance is unable to
+// describe its device.
+const ErrNoDeviceInfo = fault.Const("Remote GAPIR did not provide device information")
+
+// RemoteDevice is a replay device with a GAPIR instance that was started
+// independently of GAPIS and is reachable over TCP.
+type RemoteDevice struct {
+	bind.Simple
+	address string
+	token   auth.Token
+	mutex   sync.Mutex // guards status
+	status  bind.Status
+}
+
+// Address returns the TCP address (host:port) of the remote GAPIR instance.
+func (d *RemoteDevice) Address() string { return d.address }
+
+// Status implements the bind.Device interface. The status is changed by
+// MonitorRemote while the device is in use by other goroutines.
+func (d *RemoteDevice) Status() bind.Status {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	return d.status
+}
+
+func (d *RemoteDevice) setStatus(status bind.Status) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	d.status = status
+}
+
+// NewRemoteDevice connects to the GAPIR instance listening on the TCP address
+// (host:port) and returns a device described by the instance. authToken is the
+// token the instance was started with.
+func NewRemoteDevice(ctx log.Context, address string, authToken auth.Token) (*RemoteDevice, error) {
+	return newRemoteDevice(ctx, address, authToken, pingInterval)
+}
+
+// newRemoteDevice is NewRemoteDevice, failing if the instance takes longer
+// than timeout to respond.
+func newRemoteDevice(ctx log.Context, address string, authToken auth.Token, timeout time.Duration) (*RemoteDevice, error) {
+	ctx = ctx.S("address", address)
+	instance, err := queryRemote(address, authToken, timeout)
+	if err != nil {
+		return nil, cause.Explain(ctx, err, "Querying remote GAPIR device")
+	}
+	// The serial distinguishes the remote device from a local device with the
+	// same description, such as a GAPIR instance on the host.
+	instance.Serial = address
+	instance.GenID()
+	return &RemoteDevice{
+		Simple:  bind.Simple{To: instance},
+		address: address,
+		token:   authToken,
+		status:  bind.Status_Online,
+	}, nil
+}
+
+// queryRemote requests the device information from the GAPIR instance
+// listening on address, failing if the instance takes longer than timeout to
+// respond.
+func queryRemote(address string, authToken auth.Token, timeout time.Duration) (*device.Instance, error) {
+	connection, err := process.ConnectTimeout(address, authToken, timeout)
+	if err != nil {
+		return nil, err
+	}
+	defer connection.Close()
+	w := endian.Writer(connection, device.LittleEndian) // TODO: Endianness
+	r := endian.Reader(connection, device.LittleEndian) // TODO: Endianness
+	if w.Uint8(uint8(protocol.ConnectionType_DeviceInfo)); w.Error() != nil {
+		return nil, w.Error()
+	}
+	size := r.Uint32()
+	if err := r.Error(); err != nil {
+		return nil, err
+	}
+	if size == 0 {
+		return nil, ErrNoDeviceInfo
+	}
+	buf := make([]byte, size)
+	if r.Data(buf); r.Error() != nil {
+		return nil, r.Error()
+	}
+	instance := &device.Instance{}
+	if err := proto.Unmarshal(buf, instance); err != nil {
+		return nil, err
+	}
+	if instance.Configuration == nil {
+		instance.Configuration = &device.Configuration{}
+	}
+	return instance, nil
+}
+
+// MonitorRemote keeps the registry r updated with the GAPIR instance listening
+// on the TCP address (host:port), until the context is stopped.
+// The instance is pinged every interval. The device is added to r while the
+// instance responds within interval, and removed from r while it does not.
+func MonitorRemote(ctx log.Context, r *bind.Registry, address string, authToken auth.Token, interval time.Duration) {
+	ctx = ctx.S("address", address)
+	var d *RemoteDevice
+	for {
+		if d == nil {
+			remote, err := newRemoteDevice(ctx, address, authToken, interval)
+			if err != nil {
+				jot.Warning(ctx).Cause(err).Print("Remote GAPIR unavailable")
+			} else {
+				d = remote
+				r.AddDevice(ctx, d)
+			}
+		} else if _, err := ping(address, authToken, interval); err != nil {
+			if d.Status() == bind.Status_Online {
+				jot.Warning(ctx).Cause(err).Print("Lost connection to remote GAPIR")
+				d.setStatus(bind.Status_Offline)
+				r.RemoveDevice(ctx, d)
+			}
+		} else if d.Status() != bind.Status_Online {
+			ctx.Info().Log("Reconnected to remote GAPIR")
+			d.setStatus(bind.Status_Online)
+			r.AddDevice(ctx, d)
+		}
+		select {
+		case <-task.ShouldStop(ctx):
+			if d != nil {
+				r.RemoveDevice(ctx, d)
+			}
+			return
+		case <-time.After(interval):
+		}
+	}
+}
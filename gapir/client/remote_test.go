@@ -0,0 +1,224 @@
+// Copyright (C) 2017 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package client
+
+import (
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/google/gapid/core/app/auth"
+	"github.com/google/gapid/core/assert"
+	"github.com/google/gapid/core/data/endian"
+	"github.com/google/gapid/core/event/task"
+	"github.com/google/gapid/core/log"
+	"github.com/google/gapid/core/os/device"
+	"github.com/google/gapid/core/os/device/bind"
+	"github.com/google/gapid/gapis/replay/protocol"
+)
+
+const testToken = auth.Token("secret")
+
+// fakeRemote is a TCP listener that answers the device information and ping
+// requests of GAPIR.
+type fakeRemote struct {
+	listener net.Listener
+	info     []byte // The encoded device instance, or nil.
+
+	mutex   sync.Mutex
+	offline bool // If true, connections are closed without a response.
+}
+
+func newFakeRemote(instance *device.Instance) *fakeRemote {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		panic(err)
+	}
+	r := &fakeRemote{listener: l}
+	if instance != nil {
+		if r.info, err = proto.Marshal(instance); err != nil {
+			panic(err)
+		}
+	}
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go r.serve(conn)
+		}
+	}()
+	return r
+}
+
+func (r *fakeRemote) address() string { return r.listener.Addr().String() }
+func (r *fakeRemote) close()          { r.listener.Close() }
+
+func (r *fakeRemote) setOffline(offline bool) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.offline = offline
+}
+
+func (r *fakeRemote) serve(conn net.Conn) {
+	defer conn.Close()
+	r.mutex.Lock()
+	offline := r.offline
+	r.mutex.Unlock()
+	if offline {
+		return
+	}
+	d := endian.Reader(conn, device.LittleEndian)
+	e := endian.Writer(conn, device.LittleEndian)
+	header := make([]byte, 4)
+	if d.Data(header); string(header) != "AUTH" || d.String() != string(testToken) {
+		return
+	}
+	switch protocol.ConnectionType(d.Uint8()) {
+	case protocol.ConnectionType_DeviceInfo:
+		e.Uint32(uint32(len(r.info)))
+		e.Data(r.info)
+	case protocol.ConnectionType_Ping:
+		e.String("PONG")
+	}
+}
+
+func newRemoteInstance() *device.Instance {
+	return &device.Instance{
+		Name: "remote",
+		Configuration: &device.Configuration{
+			ABIs: []*device.ABI{{Name: "remote", MemoryLayout: device.Little32}},
+		},
+	}
+}
+
+func TestQueryRemote(t *testing.T) {
+	ctx := log.Testing(t)
+	remote := newFakeRemote(newRemoteInstance())
+	defer remote.close()
+
+	instance, err := queryRemote(remote.address(), testToken, time.Second)
+	if assert.For(ctx, "query").ThatError(err).Succeeded() {
+		assert.For(ctx, "instance").That(proto.Equal(instance, newRemoteInstance())).Equals(true)
+	}
+
+	_, err = queryRemote(remote.address(), auth.Token("wrong"), time.Second)
+	assert.For(ctx, "wrong token").ThatError(err).Failed()
+
+	empty := newFakeRemote(nil)
+	defer empty.close()
+	_, err = queryRemote(empty.address(), testToken, time.Second)
+	assert.For(ctx, "no device info").ThatError(err).Equals(ErrNoDeviceInfo)
+}
+
+func TestRemoteTimeout(t *testing.T) {
+	ctx := log.Testing(t)
+	// The listener accepts connections but never responds.
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if !assert.With(ctx).ThatError(err).Succeeded() {
+		return
+	}
+	defer l.Close()
+	go func() {
+		conns := []net.Conn{}
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				break
+			}
+			conns = append(conns, conn)
+		}
+		for _, conn := range conns {
+			conn.Close()
+		}
+	}()
+
+	start := time.Now()
+	_, err = queryRemote(l.Addr().String(), testToken, 50*time.Millisecond)
+	assert.For(ctx, "query").ThatError(err).Failed()
+	_, err = ping(l.Addr().String(), testToken, 50*time.Millisecond)
+	assert.For(ctx, "ping").ThatError(err).Failed()
+	assert.For(ctx, "timed out").That(time.Since(start) < 5*time.Second).Equals(true)
+}
+
+func TestNewRemoteDevice(t *testing.T) {
+	ctx := log.Testing(t)
+	remote := newFakeRemote(newRemoteInstance())
+	defer remote.close()
+
+	d, err := NewRemoteDevice(ctx, remote.address(), testToken)
+	if !assert.For(ctx, "new").ThatError(err).Succeeded() {
+		return
+	}
+	assert.For(ctx, "address").That(d.Address()).Equals(remote.address())
+	assert.For(ctx, "serial").That(d.Instance().Serial).Equals(remote.address())
+	assert.For(ctx, "id").That(d.Instance().Id).IsNotNil()
+	assert.For(ctx, "status").That(d.Status()).Equals(bind.Status_Online)
+
+	remote.close()
+	_, err = NewRemoteDevice(ctx, remote.address(), testToken)
+	assert.For(ctx, "closed").ThatError(err).Failed()
+}
+
+func TestMonitorRemote(t *testing.T) {
+	ctx := log.Testing(t)
+	remote := newFakeRemote(newRemoteInstance())
+	defer remote.close()
+
+	r := bind.NewRegistry()
+	added, removed := make(chan bind.Device, 4), make(chan bind.Device, 4)
+	r.Listen(bind.NewDeviceListener(
+		func(ctx log.Context, d bind.Device) { added <- d },
+		func(ctx log.Context, d bind.Device) { removed <- d },
+	))
+	wait := func(name string, c <-chan bind.Device) bind.Device {
+		select {
+		case d := <-c:
+			return d
+		case <-time.After(5 * time.Second):
+			assert.For(ctx, name).Error("Timed out waiting for the registry")
+			return nil
+		}
+	}
+
+	ctx, cancel := task.WithCancel(ctx)
+	done := make(chan struct{})
+	go func() {
+		MonitorRemote(ctx, r, remote.address(), testToken, 10*time.Millisecond)
+		close(done)
+	}()
+
+	d := wait("added", added)
+	if !assert.For(ctx, "added").That(d).IsNotNil() {
+		cancel()
+		return
+	}
+	assert.For(ctx, "online").That(d.Status()).Equals(bind.Status_Online)
+
+	remote.setOffline(true)
+	assert.For(ctx, "removed").That(wait("removed", removed)).Equals(d)
+	assert.For(ctx, "offline").That(d.Status()).Equals(bind.Status_Offline)
+
+	remote.setOffline(false)
+	assert.For(ctx, "re-added").That(wait("re-added", added)).Equals(d)
+	assert.For(ctx, "reconnected").That(d.Status()).Equals(bind.Status_Online)
+
+	cancel()
+	<-done
+	assert.For(ctx, "stopped").ThatSlice(r.Devices()).IsEmpty()
+}
@@ -49,9 +49,13 @@ var (
 
 const sessionTimeout = time.Second * 10
 
+// pingInterval is the interval between the keep-alive pings of a session, and
+// the time a ping is given to complete.
+const pingInterval = sessionTimeout / 2
+
 type session struct {
 	device   bind.Device
-	port     int
+	address  string
 	auth     auth.Token
 	closeCBs []func()
 	inited   chan struct{}
@@ -65,7 +69,9 @@ func (s *session) init(ctx log.Context, d bind.Device, abi *device.ABI) error {
 	defer close(s.inited)
 
 	var err error
-	if device.Host(ctx).SameAs(d.Instance()) {
+	if d, ok := d.(*RemoteDevice); ok {
+		err = s.newRemote(ctx, d)
+	} else if device.Host(ctx).SameAs(d.Instance()) {
 		err = s.newHost(ctx, d)
 	} else if d, ok := d.(adb.Device); ok {
 		err = s.newADB(ctx, d, abi)
@@ -77,7 +83,7 @@ func (s *session) init(ctx log.Context, d bind.Device, abi *device.ABI) error {
 		return err
 	}
 
-	go s.heartbeat(ctx, sessionTimeout/2)
+	go s.heartbeat(ctx, pingInterval)
 	return nil
 }
 
@@ -109,7 +115,7 @@ func (s *session) newHost(ctx log.Context, d bind.Device, gapirArgs ...string) e
 		return nil
 	}
 
-	s.port = port
+	s.address = localAddress(port)
 	s.auth = authToken
 	return nil
 }
@@ -135,7 +141,7 @@ func (s *session) newADB(ctx log.Context, d adb.Device, abi *device.ABI) error {
 	if err != nil {
 		return cause.Explain(ctx, err, "Finding free port")
 	}
-	s.port = int(localPort)
+	s.address = localAddress(int(localPort))
 	socket, ok := socketNames[abi.Architecture]
 	ctx = ctx.S("socket", socket)
 	if !ok {
@@ -163,9 +169,25 @@ func (s *session) newADB(ctx log.Context, d adb.Device, abi *device.ABI) error {
 	return cause.Explain(ctx, nil, "Timeout waiting for connection")
 }
 
+// newRemote connects to the already running GAPIR instance of the remote
+// device d.
+func (s *session) newRemote(ctx log.Context, d *RemoteDevice) error {
+	ctx = ctx.S("address", d.address)
+	s.address = d.address
+	s.auth = d.token
+	if _, err := s.ping(ctx); err != nil {
+		return cause.Explain(ctx, err, "Connecting to remote GAPIR")
+	}
+	return nil
+}
+
+func localAddress(port int) string {
+	return fmt.Sprintf("localhost:%d", port)
+}
+
 func (s *session) connect(ctx log.Context) (io.ReadWriteCloser, error) {
 	<-s.inited
-	return process.Connect(s.port, s.auth)
+	return process.ConnectTo(s.address, s.auth)
 }
 
 func (s *session) onClose(f func()) {
@@ -180,7 +202,13 @@ func (s *session) close() {
 }
 
 func (s *session) ping(ctx log.Context) (time.Duration, error) {
-	connection, err := process.Connect(s.port, s.auth)
+	return ping(s.address, s.auth, pingInterval)
+}
+
+// ping sends a ping to the GAPIR instance listening on address, returning the
+// round trip time. The ping fails if it takes longer than timeout.
+func ping(address string, authToken auth.Token, timeout time.Duration) (time.Duration, error) {
+	connection, err := process.ConnectTimeout(address, authToken, timeout)
 	if err != nil {
 		return 0, err
 	}
@@ -15,10 +15,12 @@
 package transform
 
 import (
+	"github.com/google/gapid/core/event/task"
 	"github.com/google/gapid/core/log"
 	"github.com/google/gapid/gapis/atom"
 	"github.com/google/gapid/gapis/config"
 	"github.com/google/gapid/gapis/gfxapi"
+	"github.com/google/gapid/gapis/status"
 )
 
 // Transforms is a list of Transformer objects.
@@ -26,7 +28,9 @@ type Transforms []Transformer
 
 // Transform sequentially transforms the atoms by each of the transformers in
 // the list, before writing the final output to the output atom Writer.
-func (l Transforms) Transform(ctx log.Context, atoms atom.List, out Writer) {
+// Transform returns an error if ctx is cancelled before all the atoms have been
+// transformed.
+func (l Transforms) Transform(ctx log.Context, atoms atom.List, out Writer) error {
 	chain := out
 	for i := len(l) - 1; i >= 0; i-- {
 		s := out.State()
@@ -35,13 +39,20 @@ func (l Transforms) Transform(ctx log.Context, atoms atom.List, out Writer) {
 		}
 		chain = TransformWriter{s, l[i], chain}
 	}
+	count := uint64(len(atoms.Atoms))
 	for i, a := range atoms.Atoms {
+		if err := task.StopReason(ctx); err != nil {
+			return err
+		}
+		status.UpdateProgress(ctx, uint64(i), count)
 		chain.MutateAndWrite(ctx, atom.ID(i), a)
 	}
+	status.UpdateProgress(ctx, count, count)
 	for p, ok := chain.(TransformWriter); ok; p, ok = chain.(TransformWriter) {
 		chain = p.O
 		p.T.Flush(ctx, chain)
 	}
+	return nil
 }
 
 // Add is a convenience function for appending the list of Transformers t to the
@@ -46,6 +46,9 @@ import (
 var (
 	capturesLock sync.RWMutex
 	captures     = []id.ID{}
+	// bookmarks maps the identifiers of the imported captures to the
+	// identifiers of their BookmarkList. Guarded by capturesLock.
+	bookmarks = map[id.ID]id.ID{}
 )
 
 // FileTag is the trace file header tag.
@@ -131,19 +134,23 @@ func ResolveFromPath(ctx log.Context, p *path.Capture) (*Capture, error) {
 // Import reads capture data from an io.Reader, imports into the given
 // database and returns the new capture identifier.
 func Import(ctx log.Context, name string, in io.ReadSeeker) (*path.Capture, error) {
-	list, err := ReadAny(ctx, in)
+	list, bookmarks, err := readAny(ctx, in)
 	if err != nil {
 		return nil, err
 	}
 	if len(list.Atoms) == 0 {
 		return nil, nil
 	}
-	return ImportAtomList(ctx, name, list)
+	return importAtomList(ctx, name, list, bookmarks)
 }
 
 // ImportAtomList builds a new capture containing a, stores it into d and
 // returns the new capture path.
 func ImportAtomList(ctx log.Context, name string, a *atom.List) (*path.Capture, error) {
+	return importAtomList(ctx, name, a, nil)
+}
+
+func importAtomList(ctx log.Context, name string, a *atom.List, bookmarks []*Bookmark) (*path.Capture, error) {
 	a, observed, err := process(ctx, a)
 	if err != nil {
 		return nil, err
@@ -183,7 +190,70 @@ func ImportAtomList(ctx log.Context, name string, a *atom.List) (*path.Capture,
 		Observed: observed,
 	}
 
-	captureID, err := database.Store(ctx, capture)
+	p, err := store(ctx, capture)
+	if err != nil {
+		return nil, err
+	}
+	if len(bookmarks) > 0 {
+		if err := setBookmarks(ctx, p, bookmarks, uint64(len(a.Atoms))); err != nil {
+			return nil, err
+		}
+	}
+	return p, nil
+}
+
+// Bookmarks returns the bookmarks of the capture at p.
+func Bookmarks(ctx log.Context, p *path.Capture) ([]*Bookmark, error) {
+	if _, err := ResolveFromPath(ctx, p); err != nil {
+		return nil, err
+	}
+	capturesLock.RLock()
+	listID, ok := bookmarks[p.Id.ID()]
+	capturesLock.RUnlock()
+	if !ok {
+		return []*Bookmark{}, nil
+	}
+	obj, err := database.Resolve(ctx, listID)
+	if err != nil {
+		return nil, err
+	}
+	return obj.(*BookmarkList).Bookmarks, nil
+}
+
+// SetBookmarks replaces the bookmarks of the capture at p with list.
+// Bookmarks of commands that are not in the capture are dropped. The capture
+// itself is unchanged, so p continues to refer to it.
+func SetBookmarks(ctx log.Context, p *path.Capture, list []*Bookmark) error {
+	c, err := ResolveFromPath(ctx, p)
+	if err != nil {
+		return err
+	}
+	atoms, err := c.Atoms(ctx)
+	if err != nil {
+		return err
+	}
+	return setBookmarks(ctx, p, list, uint64(len(atoms.Atoms)))
+}
+
+// setBookmarks replaces the bookmarks of the capture at p, which holds count
+// commands, with list.
+func setBookmarks(ctx log.Context, p *path.Capture, list []*Bookmark, count uint64) error {
+	listID, err := database.Store(ctx, &BookmarkList{
+		Capture:   NewID(p.Id.ID()),
+		Bookmarks: validBookmarks(ctx, list, count),
+	})
+	if err != nil {
+		return err
+	}
+	capturesLock.Lock()
+	bookmarks[p.Id.ID()] = listID
+	capturesLock.Unlock()
+	return nil
+}
+
+// store adds the capture c to the database and the list of imported captures.
+func store(ctx log.Context, c *Capture) (*path.Capture, error) {
+	captureID, err := database.Store(ctx, c)
 	if err != nil {
 		return nil, err
 	}
@@ -195,42 +265,76 @@ func ImportAtomList(ctx log.Context, name string, a *atom.List) (*path.Capture,
 	return &path.Capture{Id: path.NewID(captureID)}, nil
 }
 
+// validBookmarks returns the bookmarks of list that refer to one of the count
+// commands of a capture.
+func validBookmarks(ctx log.Context, list []*Bookmark, count uint64) []*Bookmark {
+	out := make([]*Bookmark, 0, len(list))
+	for _, b := range list {
+		if b.Command >= count {
+			ctx.Warning().V("command", b.Command).V("label", b.Label).Log("Dropping bookmark of missing command")
+			continue
+		}
+		out = append(out, b)
+	}
+	return out
+}
+
 // ReadAny attempts to auto detect the capture stream type and read it.
 func ReadAny(ctx log.Context, in io.ReadSeeker) (*atom.List, error) {
-	atoms, err := ReadPack(ctx, in)
+	list, _, err := readAny(ctx, in)
+	return list, err
+}
+
+// readAny is like ReadAny, but also returns the bookmarks held by pack
+// streams.
+func readAny(ctx log.Context, in io.ReadSeeker) (*atom.List, []*Bookmark, error) {
+	atoms, bookmarks, err := readPack(ctx, in)
 	switch err {
 	case nil:
-		return atoms, err
+		return atoms, bookmarks, err
 	case pack.ErrIncorrectMagic:
 		in.Seek(0, io.SeekStart)
-		return ReadLegacy(ctx, in)
+		atoms, err := ReadLegacy(ctx, in)
+		return atoms, nil, err
 	default:
-		return nil, err
+		return nil, nil, err
 	}
 }
 
 // ReadPack converts the contents of a proto capture stream to an atom list.
 func ReadPack(ctx log.Context, in io.Reader) (*atom.List, error) {
+	list, _, err := readPack(ctx, in)
+	return list, err
+}
+
+// readPack is like ReadPack, but also returns the bookmarks held by the
+// stream.
+func readPack(ctx log.Context, in io.Reader) (*atom.List, []*Bookmark, error) {
 	reader, err := pack.NewReader(in)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 	list := atom.NewList()
+	bookmarks := []*Bookmark{}
 	converter := atom.FromConverter(func(a atom.Atom) {
 		list.Atoms = append(list.Atoms, a)
 	})
 	for {
-		atom, err := reader.Unmarshal()
+		msg, err := reader.Unmarshal()
 		if errors.Cause(err) == io.EOF {
 			break
 		}
 		if err != nil {
-			return nil, cause.Explain(ctx, err, "Failed to unmarshal")
+			return nil, nil, cause.Explain(ctx, err, "Failed to unmarshal")
 		}
-		converter(ctx, atom)
+		if b, ok := msg.(*Bookmark); ok {
+			bookmarks = append(bookmarks, b)
+			continue
+		}
+		converter(ctx, msg)
 	}
 	// must invoke the converter with nil to flush the last atom
-	return list, converter(ctx, nil)
+	return list, bookmarks, converter(ctx, nil)
 }
 
 // ReadLegacy converts the contents of a legacy capture stream to an atom list.
@@ -274,10 +378,10 @@ func ReadLegacy(ctx log.Context, in io.Reader) (*atom.List, error) {
 
 type atomWriter func(ctx log.Context, a atom.Atom) error
 
-func packWriter(w io.Writer) (atomWriter, error) {
-	writer, err := pack.NewWriter(w)
+func packWriter(w io.Writer, opts pack.Options) (atomWriter, *pack.Writer, error) {
+	writer, err := pack.NewWriterWithOptions(w, opts)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 	out := func(ctx log.Context, a atom_pb.Atom) error { return writer.Marshal(a) }
 	return func(ctx log.Context, a atom.Atom) error {
@@ -286,7 +390,7 @@ func packWriter(w io.Writer) (atomWriter, error) {
 			return atom.ErrNotConvertible
 		}
 		return c.Convert(ctx, out)
-	}, nil
+	}, writer, nil
 }
 
 func legacyWriter(w io.Writer) atomWriter {
@@ -357,13 +461,17 @@ func export(ctx log.Context, p *path.Capture, to atomWriter) error {
 	return nil
 }
 
-// WritePack writes the supplied atoms directly to the writer in the pack file format.
-func WritePack(ctx log.Context, atoms *atom.List, w io.Writer) error {
-	writer, err := packWriter(w)
+// WritePack writes the supplied atoms directly to the writer in the pack file
+// format, encoded with opts.
+func WritePack(ctx log.Context, atoms *atom.List, w io.Writer, opts pack.Options) error {
+	writer, pw, err := packWriter(w, opts)
 	if err != nil {
 		return err
 	}
-	return writeAll(ctx, atoms, writer)
+	if err := writeAll(ctx, atoms, writer); err != nil {
+		return err
+	}
+	return pw.Flush()
 }
 
 // WriteLegacy writes the supplied atoms directly to the writer in the legacy .gfxtrace format.
@@ -378,15 +486,27 @@ func Export(ctx log.Context, p *path.Capture, w io.Writer) error {
 	return export(ctx, p, legacyWriter(w))
 }
 
-// ExportPack encodes the given capture and associated resources
+// ExportPack encodes the given capture, associated resources and bookmarks
 // and writes it to the supplied io.Writer in the pack file format,
 // producing output suitable for use with Import or opening in the trace editor.
 func ExportPack(ctx log.Context, p *path.Capture, w io.Writer) error {
-	writer, err := packWriter(w)
+	writer, pw, err := packWriter(w, pack.Options{})
+	if err != nil {
+		return err
+	}
+	if err := export(ctx, p, writer); err != nil {
+		return err
+	}
+	list, err := Bookmarks(ctx, p)
 	if err != nil {
 		return err
 	}
-	return export(ctx, p, writer)
+	for _, b := range list {
+		if err := pw.Marshal(b); err != nil {
+			return err
+		}
+	}
+	return pw.Flush()
 }
 
 // ExportLegacy encodes the given capture and associated resources
@@ -15,6 +15,9 @@
 package client
 
 import (
+	"io"
+	"time"
+
 	"github.com/google/gapid/core/log"
 	"github.com/google/gapid/framework/binary/schema"
 	"github.com/google/gapid/gapis/gfxapi"
@@ -232,6 +235,7 @@ func (c *client) GetFramebufferAttachment(
 	dev *path.Device,
 	cmd *path.Command,
 	att gfxapi.FramebufferAttachment,
+	layer *gfxapi.FramebufferLayer,
 	rs *service.RenderSettings) (*path.ImageInfo, error) {
 
 	res, err := c.client.GetFramebufferAttachment(ctx.Unwrap(), &service.GetFramebufferAttachmentRequest{
@@ -239,6 +243,7 @@ func (c *client) GetFramebufferAttachment(
 		After:      cmd,
 		Attachment: att,
 		Settings:   rs,
+		Layer:      layer,
 	})
 	if err != nil {
 		return nil, err
@@ -248,3 +253,52 @@ func (c *client) GetFramebufferAttachment(
 	}
 	return res.GetImage(), nil
 }
+
+func (c *client) FindCommands(ctx log.Context, search *path.CommandSearch, max int, f func(*path.Command) error) error {
+	stream, err := c.client.FindCommands(ctx.Unwrap(), &service.FindCommandsRequest{
+		Search:     search,
+		MaxResults: uint32(max),
+	})
+	if err != nil {
+		return err
+	}
+	for {
+		res, err := stream.Recv()
+		switch {
+		case err == io.EOF:
+			return nil
+		case err != nil:
+			return err
+		}
+		if err := res.GetError(); err != nil {
+			return err.Get()
+		}
+		if err := f(res.GetCommand()); err != nil {
+			return err
+		}
+	}
+}
+
+func (c *client) Status(ctx log.Context, interval time.Duration, f func(*service.Tasks) error) error {
+	stream, err := c.client.Status(ctx.Unwrap(), &service.StatusRequest{
+		UpdateIntervalMs: uint32(interval / time.Millisecond),
+	})
+	if err != nil {
+		return err
+	}
+	for {
+		res, err := stream.Recv()
+		switch {
+		case err == io.EOF:
+			return nil
+		case err != nil:
+			return err
+		}
+		if err := res.GetError(); err != nil {
+			return err.Get()
+		}
+		if err := f(res.GetTasks()); err != nil {
+			return err
+		}
+	}
+}
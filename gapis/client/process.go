@@ -83,7 +83,8 @@ func Connect(ctx log.Context, cfg Config) (Client, *schema.Message, error) {
 
 	conn, err := grpcutil.Dial(ctx, target,
 		grpc.WithInsecure(),
-		grpc.WithUnaryInterceptor(auth.ClientInterceptor(cfg.Token)))
+		grpc.WithUnaryInterceptor(auth.ClientInterceptor(cfg.Token)),
+		grpc.WithStreamInterceptor(auth.StreamClientInterceptor(cfg.Token)))
 	if err != nil {
 		return nil, nil, cause.Explain(ctx, err, "Dialing GAPIS")
 	}
@@ -23,6 +23,7 @@ import (
 	"github.com/google/gapid/core/event/task"
 	"github.com/google/gapid/core/log"
 	"github.com/google/gapid/gapis/config"
+	"github.com/google/gapid/gapis/status"
 )
 
 // NewInMemory builds a new in memory database.
@@ -109,6 +110,7 @@ func (d *memory) resolve(ctx log.Context, id id.ID) (interface{}, error) {
 
 		// Build a cancellable context for the resolve.
 		resolveCtx, cancel := task.WithCancel(d.resolveCtx)
+		resolveCtx = status.Start(resolveCtx, "Resolve %T", resolvable)
 
 		rs = &resolveState{
 			ctx:      resolveCtx,
@@ -120,6 +122,7 @@ func (d *memory) resolve(ctx log.Context, id id.ID) (interface{}, error) {
 
 		// Build the resolvable on a separate go-routine.
 		go func() {
+			defer status.Finish(rs.ctx)
 			val, err := resolvable.Resolve(rs.ctx)
 			if err == nil {
 				// Resolved without error. Store the resulting values.
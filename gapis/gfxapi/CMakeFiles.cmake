@@ -21,12 +21,19 @@ set(files
     api.go
     context.go
     doc.go
+    draw_call_state.go
+    framebuffer.go
+    framebuffer_test.go
     gfxapi.pb.go
     gfxapi.proto
+    lint.go
     mesh.go
+    mesh_export.go
+    mesh_export_test.go
     resource.go
     snippet.go
     state.go
+    stats.go
     texture.go
     texture_test.go
 )
@@ -0,0 +1,26 @@
+// Copyright (C) 2017 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gfxapi
+
+import "github.com/google/gapid/core/log"
+
+// DrawCallStateProvider is the interface implemented by types that describe
+// the state used by draw calls.
+type DrawCallStateProvider interface {
+	// DrawCallState returns the state used by the command o, given the state s
+	// after o and the identifiers of the resources of s.
+	// If nil, nil is returned then o does not use any draw call state.
+	DrawCallState(ctx log.Context, o interface{}, s *State, resources ResourceMap) (*DrawCallState, error)
+}
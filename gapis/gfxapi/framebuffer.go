@@ -0,0 +1,70 @@
+// Copyright (C) 2017 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gfxapi
+
+import "github.com/google/gapid/core/image"
+
+// MaxColorAttachments is the number of color attachments that can be
+// addressed by a FramebufferAttachment.
+const MaxColorAttachments = int(FramebufferAttachment_Color7-FramebufferAttachment_Color0) + 1
+
+// FramebufferAttachments is the list of all the framebuffer attachments, in
+// enumerator order.
+var FramebufferAttachments = []FramebufferAttachment{
+	FramebufferAttachment_Depth,
+	FramebufferAttachment_Stencil,
+	FramebufferAttachment_Color0,
+	FramebufferAttachment_Color1,
+	FramebufferAttachment_Color2,
+	FramebufferAttachment_Color3,
+	FramebufferAttachment_Color4,
+	FramebufferAttachment_Color5,
+	FramebufferAttachment_Color6,
+	FramebufferAttachment_Color7,
+}
+
+// ColorAttachment returns the FramebufferAttachment for the color attachment
+// with the index i.
+func ColorAttachment(i int) FramebufferAttachment {
+	return FramebufferAttachment_Color0 + FramebufferAttachment(i)
+}
+
+// IsColor returns true if a is a color attachment.
+func (a FramebufferAttachment) IsColor() bool {
+	return a >= FramebufferAttachment_Color0 && a <= FramebufferAttachment_Color7
+}
+
+// ColorIndex returns the index of the color attachment a, or -1 if a is not a
+// color attachment.
+func (a FramebufferAttachment) ColorIndex() int {
+	if !a.IsColor() {
+		return -1
+	}
+	return int(a - FramebufferAttachment_Color0)
+}
+
+// FramebufferLayersProvider is the interface implemented by APIs that can
+// address the individual layers and mip levels of the images bound to
+// framebuffer attachments.
+type FramebufferLayersProvider interface {
+	// GetFramebufferAttachmentLayers returns the number of layers and mip
+	// levels of the image bound to the specified framebuffer attachment.
+	GetFramebufferAttachmentLayers(state *State, attachment FramebufferAttachment) (layers, levels uint32, err error)
+
+	// GetFramebufferAttachmentLayerInfo returns the width, height and format of
+	// a single layer and level of the image bound to the specified framebuffer
+	// attachment.
+	GetFramebufferAttachmentLayerInfo(state *State, attachment FramebufferAttachment, layer *FramebufferLayer) (width, height uint32, format *image.Format, err error)
+}
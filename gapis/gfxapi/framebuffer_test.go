@@ -0,0 +1,41 @@
+// Copyright (C) 2017 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gfxapi_test
+
+import (
+	"testing"
+
+	"github.com/google/gapid/core/assert"
+	"github.com/google/gapid/core/log"
+	"github.com/google/gapid/gapis/gfxapi"
+)
+
+func TestColorAttachments(t *testing.T) {
+	ctx := log.Testing(t)
+	assert.With(ctx).That(gfxapi.MaxColorAttachments).Equals(8)
+	assert.With(ctx).That(len(gfxapi.FramebufferAttachments)).Equals(len(gfxapi.FramebufferAttachment_name))
+	for i := 0; i < gfxapi.MaxColorAttachments; i++ {
+		att := gfxapi.ColorAttachment(i)
+		assert.With(ctx).That(att.IsColor()).Equals(true)
+		assert.With(ctx).That(att.ColorIndex()).Equals(i)
+	}
+	for _, att := range []gfxapi.FramebufferAttachment{
+		gfxapi.FramebufferAttachment_Depth,
+		gfxapi.FramebufferAttachment_Stencil,
+	} {
+		assert.With(ctx).That(att.IsColor()).Equals(false)
+		assert.With(ctx).That(att.ColorIndex()).Equals(-1)
+	}
+}
@@ -30,6 +30,10 @@ set(files
     dependency_graph.go
     draw_call.go
     draw_call_mesh.go
+    draw_call_state.go
+    draw_call_state_test.go
+    draw_mode.go
+    draw_mode_test.go
     enum.go
     externs.go
     extras.go
@@ -42,10 +46,14 @@ set(files
     helpers.go
     image.go
     issue_whitelist.go
+    lint.go
+    lint_test.go
     links.go
     markers.go
     markers_test.go
     metadata.go
+    pixel_history.go
+    pixel_history_test.go
     mutate.go
     read_framebuffer.go
     replay.go
@@ -53,8 +61,11 @@ set(files
     resolvables.proto
     resources.go
     resources_test.go
+    shader_trace.go
     snippets_embed.go
     state.go
+    stats.go
+    stats_test.go
     string.go
     stub_program.go
     stub_program_test.go
@@ -203,16 +203,20 @@ func (k vertexAttribGroupKey) Parent() stateKey { return nil }
 
 type renderbufferDataKey struct {
 	renderbuffer *Renderbuffer
+	id           RenderbufferId
 }
 
 func (k renderbufferDataKey) Parent() stateKey { return nil }
 
 type renderbufferSubDataKey struct {
 	renderbuffer *Renderbuffer
+	id           RenderbufferId
 	region       Rect
 }
 
-func (k renderbufferSubDataKey) Parent() stateKey { return renderbufferDataKey{k.renderbuffer} }
+func (k renderbufferSubDataKey) Parent() stateKey {
+	return renderbufferDataKey{k.renderbuffer, k.id}
+}
 
 type textureDataKey struct {
 	texture *Texture
@@ -252,10 +256,10 @@ func (g *DependencyGraph) getBehaviour(ctx log.Context, s *gfxapi.State, id atom
 			depthId := RenderbufferId(fb.DepthAttachment.ObjectName)
 			stencilId := RenderbufferId(fb.StencilAttachment.ObjectName)
 			if !c.Info.PreserveBuffersOnSwap {
-				b.write(g, renderbufferDataKey{c.Instances.Renderbuffers[colorId]})
+				b.write(g, renderbufferDataKey{c.Instances.Renderbuffers[colorId], colorId})
 			}
-			b.write(g, renderbufferDataKey{c.Instances.Renderbuffers[depthId]})
-			b.write(g, renderbufferDataKey{c.Instances.Renderbuffers[stencilId]})
+			b.write(g, renderbufferDataKey{c.Instances.Renderbuffers[depthId], depthId})
+			b.write(g, renderbufferDataKey{c.Instances.Renderbuffers[stencilId], stencilId})
 		} else if a.AtomFlags().IsDrawCall() {
 			b.read(g, uniformGroupKey{c, c.BoundProgram})
 			b.read(g, vertexAttribGroupKey{c, c.BoundVertexArray})
@@ -355,14 +359,15 @@ func (g *DependencyGraph) getTextureData(ctx log.Context, a atom.Atom, s *gfxapi
 
 func getAttachmentData(g *DependencyGraph, c *Context, att FramebufferAttachment) (key stateKey) {
 	if att.ObjectType == GLenum_GL_RENDERBUFFER {
-		rb := c.Instances.Renderbuffers[RenderbufferId(att.ObjectName)]
+		id := RenderbufferId(att.ObjectName)
+		rb := c.Instances.Renderbuffers[id]
 		if rb != nil && rb.InternalFormat != GLenum_GL_NONE {
 			scissor := c.FragmentOperations.Scissor
 			fullBox := Rect{Width: rb.Width, Height: rb.Height}
 			if scissor.Test == GLboolean_GL_TRUE && scissor.Box != fullBox {
-				key = renderbufferSubDataKey{rb, scissor.Box}
+				key = renderbufferSubDataKey{rb, id, scissor.Box}
 			} else {
-				key = renderbufferDataKey{rb}
+				key = renderbufferDataKey{rb, id}
 			}
 		}
 	}
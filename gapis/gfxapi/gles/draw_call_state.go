@@ -0,0 +1,183 @@
+// Copyright (C) 2017 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gles
+
+import (
+	"sort"
+
+	"github.com/google/gapid/core/log"
+	"github.com/google/gapid/gapis/atom"
+	"github.com/google/gapid/gapis/gfxapi"
+	"github.com/google/gapid/gapis/memory"
+	"github.com/google/gapid/gapis/messages"
+	"github.com/google/gapid/gapis/service"
+	"github.com/google/gapid/gapis/service/path"
+)
+
+// DrawCallState implements the gfxapi.DrawCallStateProvider interface.
+func (api) DrawCallState(ctx log.Context, o interface{}, s *gfxapi.State, resources gfxapi.ResourceMap) (*gfxapi.DrawCallState, error) {
+	dc, ok := o.(drawCall)
+	if !ok {
+		return nil, nil
+	}
+	c := GetContext(s)
+	if c == nil {
+		return nil, &service.ErrDataUnavailable{Reason: messages.ErrStateUnavailable()}
+	}
+	program, ok := c.Instances.Programs[c.BoundProgram]
+	if !ok {
+		return nil, &service.ErrDataUnavailable{Reason: messages.ErrNoProgramBound()}
+	}
+
+	out := &gfxapi.DrawCallState{
+		Program:  resourceID(resources, program),
+		Uniforms: program.uniforms(ctx, s),
+	}
+
+	shaderTypes := make([]int, 0, len(program.Shaders))
+	for ty := range program.Shaders {
+		shaderTypes = append(shaderTypes, int(ty))
+	}
+	sort.Ints(shaderTypes)
+	for _, ty := range shaderTypes {
+		if shader := c.Instances.Shaders[program.Shaders[GLenum(ty)]]; shader != nil {
+			if id := resourceID(resources, shader); id != nil {
+				out.Shaders = append(out.Shaders, id)
+			}
+		}
+	}
+
+	for _, u := range program.ActiveUniforms {
+		if !isSampler(u.Type) {
+			continue
+		}
+		target, _ := subGetTextureTargetFromSamplerType(ctx, dc, nil, s, GetState(s), nil, u.Type)
+		for i := 0; i < int(u.ArraySize); i++ {
+			uniform := program.Uniforms[u.Location+UniformLocation(i)]
+			units := AsU32ˢ(uniform.Value, s).Read(ctx, dc, s, nil)
+			if len(units) == 0 {
+				units = []uint32{0} // The uniform was not set, so use default value.
+			}
+			for _, unit := range units {
+				out.Textures = append(out.Textures, textureBinding(ctx, dc, s, c, u.Name, unit, target, resources))
+			}
+		}
+	}
+
+	if va, ok := c.Instances.VertexArrays[c.BoundVertexArray]; ok {
+		for _, attr := range program.ActiveAttributes {
+			if vaa := va.VertexAttributeArrays[attr.Location]; vaa != nil {
+				out.Attributes = append(out.Attributes, vertexAttributeBinding(va, vaa, attr))
+			}
+		}
+	}
+	sort.Slice(out.Textures, func(i, j int) bool {
+		return out.Textures[i].Unit < out.Textures[j].Unit
+	})
+	sort.Slice(out.Attributes, func(i, j int) bool {
+		return out.Attributes[i].Location < out.Attributes[j].Location
+	})
+
+	return out, nil
+}
+
+// textureBinding returns the texture and sampling parameters used by the
+// sampler uniform name for the texture unit index unit.
+func textureBinding(ctx log.Context, a atom.Atom, s *gfxapi.State, c *Context, name string, unit uint32, target GLenum, resources gfxapi.ResourceMap) *gfxapi.TextureBinding {
+	out := &gfxapi.TextureBinding{Unit: unit, Name: name}
+	glUnit := GLenum(unit) + GLenum_GL_TEXTURE0
+	tex, err := subGetBoundTextureForUnit(ctx, a, nil, s, GetState(s), nil, c, glUnit, target)
+	if tex == nil || err != nil {
+		return out
+	}
+	out.Texture = resourceID(resources, tex)
+	out.Sampler = textureSamplerState(tex)
+	if tu := c.TextureUnits[glUnit]; tu != nil && tu.SamplerBinding != 0 {
+		if sampler, ok := c.Instances.Samplers[tu.SamplerBinding]; ok {
+			out.Sampler = samplerObjectState(sampler)
+		}
+	}
+	return out
+}
+
+// vertexAttributeBinding returns the vertex stream that feeds the active
+// attribute attr.
+func vertexAttributeBinding(va *VertexArray, vaa *VertexAttributeArray, attr ActiveAttribute) *gfxapi.VertexAttributeBinding {
+	out := &gfxapi.VertexAttributeBinding{
+		Location:   uint32(attr.Location),
+		Name:       attr.Name,
+		Enabled:    vaa.Enabled == GLboolean_GL_TRUE,
+		Type:       vaa.Type.String(),
+		Components: uint32(vaa.Size),
+		Normalized: vaa.Normalized == GLboolean_GL_TRUE,
+	}
+	if vbb := va.VertexBufferBindings[vaa.Binding]; vbb != nil {
+		out.Buffer = uint64(vbb.Buffer)
+		out.Offset = uint64(vbb.Offset) + uint64(vaa.RelativeOffset)
+		out.Stride = uint32(vbb.Stride)
+		out.Divisor = uint32(vbb.Divisor)
+		if vbb.Buffer == 0 {
+			// Client memory.
+			out.Offset = memory.Pointer(vaa.Pointer).Address
+		}
+	}
+	return out
+}
+
+// resourceID returns the path to the identifier of the resource r, or nil if
+// r is not a tracked resource.
+func resourceID(resources gfxapi.ResourceMap, r gfxapi.Resource) *path.ID {
+	if id, ok := resources[r]; ok {
+		return path.NewID(id)
+	}
+	return nil
+}
+
+// textureSamplerState returns the sampling parameters held by the texture.
+func textureSamplerState(t *Texture) *gfxapi.SamplerState {
+	out := &gfxapi.SamplerState{
+		MinFilter:     t.MinFilter.String(),
+		MagFilter:     t.MagFilter.String(),
+		WrapU:         t.WrapS.String(),
+		WrapV:         t.WrapT.String(),
+		WrapW:         t.WrapR.String(),
+		MinLod:        float32(t.MinLod),
+		MaxLod:        float32(t.MaxLod),
+		MaxAnisotropy: float32(t.MaxAnisotropy),
+	}
+	if t.CompareMode != GLenum_GL_NONE {
+		out.CompareFunc = t.CompareFunc.String()
+	}
+	return out
+}
+
+// samplerObjectState returns the sampling parameters held by the sampler
+// object, which override the parameters of the texture.
+func samplerObjectState(s *Sampler) *gfxapi.SamplerState {
+	out := &gfxapi.SamplerState{
+		MinFilter:     s.MinFilter.String(),
+		MagFilter:     s.MagFilter.String(),
+		WrapU:         s.WrapS.String(),
+		WrapV:         s.WrapT.String(),
+		WrapW:         s.WrapR.String(),
+		MinLod:        float32(s.MinLod),
+		MaxLod:        float32(s.MaxLod),
+		MaxAnisotropy: float32(s.MaxAnisotropy),
+	}
+	if s.CompareMode != GLenum_GL_NONE {
+		out.CompareFunc = s.CompareFunc.String()
+	}
+	return out
+}
@@ -0,0 +1,230 @@
+// Copyright (C) 2017 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gles
+
+import (
+	"testing"
+
+	"github.com/google/gapid/core/assert"
+	"github.com/google/gapid/core/data/id"
+	"github.com/google/gapid/core/data/pod"
+	"github.com/google/gapid/core/log"
+	"github.com/google/gapid/gapis/atom"
+	"github.com/google/gapid/gapis/database"
+	"github.com/google/gapid/gapis/gfxapi"
+	"github.com/google/gapid/gapis/memory"
+	"github.com/google/gapid/gapis/messages"
+	"github.com/google/gapid/gapis/service"
+	"github.com/google/gapid/gapis/service/path"
+)
+
+func TestDrawCallState(t *testing.T) {
+	ctx := log.Testing(t)
+	ctx = database.Put(ctx, database.NewInMemory(ctx))
+	ctx, out, atoms := newTestState(ctx)
+	s := out.S
+
+	const (
+		vs, fs, prog               = ShaderId(1), ShaderId(2), ProgramId(3)
+		tint, tex, shadow          = UniformLocation(0), UniformLocation(1), UniformLocation(2)
+		position, uv               = AttributeLocation(0), AttributeLocation(1)
+		colorTex, shadowTex        = TextureId(10), TextureId(11)
+		sampler                    = SamplerId(5)
+		vertexBuffer               = BufferId(20)
+		clientVertices      uint64 = 0x1000
+	)
+	tintValue := []GLfloat{0.5, 0.25, 1, 1}
+	tintData := atom.Must(atom.AllocData(ctx, s, tintValue))
+
+	atoms = append(atoms, BuildProgram(ctx, s, vs, fs, prog, "vertex", "fragment")...)
+	atoms = append(atoms,
+		atom.WithExtras(NewGlLinkProgram(prog), &ProgramInfo{
+			LinkStatus: GLboolean_GL_TRUE,
+			ActiveAttributes: AttributeIndexːActiveAttributeᵐ{
+				0: {Name: "uv", Type: GLenum_GL_FLOAT_VEC2, ArraySize: 1, Location: uv},
+				1: {Name: "position", Type: GLenum_GL_FLOAT_VEC3, ArraySize: 1, Location: position},
+			},
+			ActiveUniforms: UniformIndexːActiveUniformᵐ{
+				0: {Name: "tint", Type: GLenum_GL_FLOAT_VEC4, ArraySize: 1, Location: tint},
+				1: {Name: "tex", Type: GLenum_GL_SAMPLER_2D, ArraySize: 1, Location: tex},
+				2: {Name: "shadow", Type: GLenum_GL_SAMPLER_2D, ArraySize: 2, Location: shadow},
+			},
+		}),
+	)
+	preUse := len(atoms)
+	atoms = append(atoms,
+		NewGlUseProgram(prog),
+		NewGlUniform4fv(tint, 1, tintData.Ptr()).AddRead(tintData.Data()),
+		NewGlUniform1i(tex, 1),
+		NewGlUniform1i(shadow, 2),
+		NewGlUniform1i(shadow+1, 3),
+
+		// Unit 1 uses the sampling parameters of the texture.
+		NewGlActiveTexture(GLenum_GL_TEXTURE1),
+		NewGlBindTexture(GLenum_GL_TEXTURE_2D, colorTex),
+		NewGlTexParameteri(GLenum_GL_TEXTURE_2D, GLenum_GL_TEXTURE_MIN_FILTER, GLint(GLenum_GL_NEAREST)),
+		NewGlTexParameteri(GLenum_GL_TEXTURE_2D, GLenum_GL_TEXTURE_WRAP_S, GLint(GLenum_GL_CLAMP_TO_EDGE)),
+		// Unit 2 has a sampler object bound, see below. Unit 3 has no texture.
+		NewGlActiveTexture(GLenum_GL_TEXTURE2),
+		NewGlBindTexture(GLenum_GL_TEXTURE_2D, shadowTex),
+
+		// Client memory.
+		NewGlEnableVertexAttribArray(position),
+		NewGlVertexAttribPointer(position, 3, GLenum_GL_FLOAT, GLboolean_GL_FALSE, 12,
+			memory.Pointer{Pool: memory.ApplicationPool, Address: clientVertices}),
+		// Buffer, disabled.
+		NewGlBindBuffer(GLenum_GL_ARRAY_BUFFER, vertexBuffer),
+		NewGlVertexAttribPointer(uv, 2, GLenum_GL_UNSIGNED_BYTE, GLboolean_GL_TRUE, 8,
+			memory.Pointer{Pool: memory.ApplicationPool, Address: 4}),
+	)
+	draw := NewGlDrawArrays(GLenum_GL_TRIANGLES, 0, 3)
+
+	var beforeUse *gfxapi.DrawCallState
+	var beforeUseErr error
+	for i, a := range atoms {
+		if i == preUse {
+			beforeUse, beforeUseErr = api{}.DrawCallState(ctx, draw, s, gfxapi.ResourceMap{})
+		}
+		out.MutateAndWrite(ctx, atom.ID(i), a)
+	}
+	assert.For(ctx, "no program").That(beforeUse).IsNil()
+	assert.For(ctx, "no program").ThatError(beforeUseErr).DeepEquals(
+		&service.ErrDataUnavailable{Reason: messages.ErrNoProgramBound()})
+
+	// Sampler objects require OpenGL ES 3.0, so the sampler is bound directly.
+	c := GetContext(s)
+	c.Instances.Samplers[sampler] = &Sampler{
+		MinFilter:     GLenum_GL_LINEAR,
+		MagFilter:     GLenum_GL_NEAREST,
+		WrapS:         GLenum_GL_MIRRORED_REPEAT,
+		WrapT:         GLenum_GL_CLAMP_TO_EDGE,
+		WrapR:         GLenum_GL_REPEAT,
+		MinLod:        0,
+		MaxLod:        4,
+		CompareMode:   GLenum_GL_COMPARE_REF_TO_TEXTURE,
+		CompareFunc:   GLenum_GL_GREATER,
+		MaxAnisotropy: 8,
+	}
+	c.TextureUnits[GLenum_GL_TEXTURE2].SamplerBinding = sampler
+
+	program := c.Instances.Programs[prog]
+	resources := gfxapi.ResourceMap{
+		program:                         id.ID{1},
+		c.Instances.Shaders[vs]:         id.ID{2},
+		c.Instances.Shaders[fs]:         id.ID{3},
+		c.Instances.Textures[colorTex]:  id.ID{4},
+		c.Instances.Textures[shadowTex]: id.ID{5},
+	}
+
+	got, err := api{}.DrawCallState(ctx, draw, s, resources)
+	if !assert.For(ctx, "err").ThatError(err).Succeeded() {
+		return
+	}
+	assert.For(ctx, "program").That(got.Program).DeepEquals(path.NewID(id.ID{1}))
+	// Ordered by shader type, GL_FRAGMENT_SHADER < GL_VERTEX_SHADER.
+	assert.For(ctx, "shaders").ThatSlice(got.Shaders).DeepEquals([]*path.ID{
+		path.NewID(id.ID{3}), path.NewID(id.ID{2}),
+	})
+
+	uniforms := map[string]*gfxapi.Uniform{}
+	for _, u := range got.Uniforms {
+		uniforms[u.Name] = u
+	}
+	assert.For(ctx, "uniforms").That(len(uniforms)).Equals(3)
+	assert.For(ctx, "tint").That(uniforms["tint"]).DeepEquals(&gfxapi.Uniform{
+		UniformLocation: uint32(tint),
+		Name:            "tint",
+		Format:          gfxapi.UniformFormat_Vec4,
+		Type:            gfxapi.UniformType_Float,
+		Value:           pod.NewValue([]float32{0.5, 0.25, 1, 1}),
+	})
+
+	assert.For(ctx, "textures").ThatSlice(got.Textures).DeepEquals([]*gfxapi.TextureBinding{
+		{
+			Unit:    1,
+			Name:    "tex",
+			Texture: path.NewID(id.ID{4}),
+			Sampler: &gfxapi.SamplerState{
+				MinFilter:     GLenum_GL_NEAREST.String(),
+				MagFilter:     GLenum_GL_LINEAR.String(),
+				WrapU:         GLenum_GL_CLAMP_TO_EDGE.String(),
+				WrapV:         GLenum_GL_REPEAT.String(),
+				WrapW:         GLenum_GL_REPEAT.String(),
+				MinLod:        -1000,
+				MaxLod:        1000,
+				MaxAnisotropy: 1,
+			},
+		}, {
+			Unit:    2,
+			Name:    "shadow",
+			Texture: path.NewID(id.ID{5}),
+			Sampler: &gfxapi.SamplerState{
+				MinFilter:     GLenum_GL_LINEAR.String(),
+				MagFilter:     GLenum_GL_NEAREST.String(),
+				WrapU:         GLenum_GL_MIRRORED_REPEAT.String(),
+				WrapV:         GLenum_GL_CLAMP_TO_EDGE.String(),
+				WrapW:         GLenum_GL_REPEAT.String(),
+				CompareFunc:   GLenum_GL_GREATER.String(),
+				MinLod:        0,
+				MaxLod:        4,
+				MaxAnisotropy: 8,
+			},
+		}, {
+			Unit: 3,
+			Name: "shadow",
+		},
+	})
+
+	assert.For(ctx, "attributes").ThatSlice(got.Attributes).DeepEquals([]*gfxapi.VertexAttributeBinding{
+		{
+			Location:   uint32(position),
+			Name:       "position",
+			Enabled:    true,
+			Type:       GLenum_GL_FLOAT.String(),
+			Components: 3,
+			Offset:     clientVertices,
+			Stride:     12,
+		}, {
+			Location:   uint32(uv),
+			Name:       "uv",
+			Type:       GLenum_GL_UNSIGNED_BYTE.String(),
+			Components: 2,
+			Normalized: true,
+			Buffer:     uint64(vertexBuffer),
+			Offset:     4,
+			Stride:     8,
+		},
+	})
+}
+
+func TestDrawCallStateErrors(t *testing.T) {
+	ctx := log.Testing(t)
+	ctx = database.Put(ctx, database.NewInMemory(ctx))
+	ctx, out, _ := newTestState(ctx)
+
+	for _, test := range []struct {
+		name     string
+		o        interface{}
+		expected error
+	}{
+		{"not a draw call", NewGlClear(GLbitfield_GL_COLOR_BUFFER_BIT), nil},
+		{"no context", NewGlDrawArrays(GLenum_GL_TRIANGLES, 0, 3),
+			&service.ErrDataUnavailable{Reason: messages.ErrStateUnavailable()}},
+	} {
+		got, err := api{}.DrawCallState(ctx, test.o, out.S, gfxapi.ResourceMap{})
+		assert.For(ctx, test.name).That(got).IsNil()
+		assert.For(ctx, test.name).ThatError(err).DeepEquals(test.expected)
+	}
+}
@@ -0,0 +1,212 @@
+// Copyright (C) 2017 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gles
+
+import (
+	"github.com/google/gapid/core/log"
+	"github.com/google/gapid/gapis/atom"
+	"github.com/google/gapid/gapis/atom/transform"
+	"github.com/google/gapid/gapis/memory"
+	"github.com/google/gapid/gapis/messages"
+	"github.com/google/gapid/gapis/service"
+)
+
+// overdrawPalette is the heat-map used to display the per-pixel fragment
+// counts. The last color is used for all counts greater than or equal to its
+// index.
+var overdrawPalette = []Color{
+	{Red: 0.0, Green: 0.0, Blue: 0.0, Alpha: 1.0},
+	{Red: 0.0, Green: 0.0, Blue: 0.5, Alpha: 1.0},
+	{Red: 0.0, Green: 0.0, Blue: 1.0, Alpha: 1.0},
+	{Red: 0.0, Green: 1.0, Blue: 1.0, Alpha: 1.0},
+	{Red: 0.0, Green: 1.0, Blue: 0.0, Alpha: 1.0},
+	{Red: 1.0, Green: 1.0, Blue: 0.0, Alpha: 1.0},
+	{Red: 1.0, Green: 0.5, Blue: 0.0, Alpha: 1.0},
+	{Red: 1.0, Green: 0.0, Blue: 0.0, Alpha: 1.0},
+	{Red: 1.0, Green: 1.0, Blue: 1.0, Alpha: 1.0},
+}
+
+// overdraw returns an atom transform that counts the fragments of every draw
+// call up to and including the atom id in the stencil buffer, and then
+// replaces the color buffer with a heat-map of the counts after id.
+// If depthTested is true then only the fragments that pass the depth test are
+// counted, otherwise all rasterized fragments are counted.
+// The stencil buffer of each framebuffer is cleared on its first draw call of
+// each frame, and the stencil state of the application is ignored.
+// If the framebuffer bound for id has no stencil attachment then an error is
+// sent to each of res, and the framebuffer is not read.
+func overdraw(ctx log.Context, id atom.ID, depthTested bool, res []chan<- imgRes) transform.Transformer {
+	ctx = ctx.Enter("Overdraw")
+	depthFailOp := GLenum_GL_INCR
+	if depthTested {
+		depthFailOp = GLenum_GL_KEEP
+	}
+	cleared := map[FramebufferId]bool{}
+	return transform.Transform("Overdraw", func(ctx log.Context, i atom.ID, a atom.Atom, out transform.Writer) {
+		if i > id {
+			out.MutateAndWrite(ctx, i, a)
+			return
+		}
+		if a.AtomFlags().IsEndOfFrame() {
+			cleared = map[FramebufferId]bool{}
+		}
+
+		c := GetContext(out.State())
+		if i == id && (c == nil || !hasStencilAttachment(c)) {
+			for _, r := range res {
+				r <- imgRes{err: &service.ErrDataUnavailable{Reason: messages.ErrNoStencilAttachment()}}
+			}
+			// Written without the id, so the framebuffer read is skipped.
+			out.MutateAndWrite(ctx, atom.NoID, a)
+			return
+		}
+
+		t := newTweaker(ctx, out)
+		if _, ok := a.(drawCall); ok && c != nil {
+			if fb := c.BoundDrawFramebuffer; !cleared[fb] {
+				reset := newTweaker(ctx, out)
+				reset.glDisable(GLenum_GL_SCISSOR_TEST)
+				reset.glStencilMask(0xFFFFFFFF)
+				reset.glClearStencil(0)
+				out.MutateAndWrite(ctx, atom.NoID, NewGlClear(GLbitfield_GL_STENCIL_BUFFER_BIT))
+				reset.revert()
+				cleared[fb] = true
+			}
+			t.glEnable(GLenum_GL_STENCIL_TEST)
+			t.glStencilFunc(GLenum_GL_ALWAYS, 0, 0xFFFFFFFF)
+			t.glStencilOp(GLenum_GL_KEEP, depthFailOp, GLenum_GL_INCR)
+			t.glStencilMask(0xFFFFFFFF)
+		}
+
+		if i != id {
+			out.MutateAndWrite(ctx, i, a)
+			t.revert()
+			return
+		}
+
+		out.MutateAndWrite(ctx, atom.NoID, a)
+		t.revert()
+		drawOverdrawHeatmap(ctx, i, out)
+	})
+}
+
+// drawOverdrawHeatmap replaces the color buffer with the overdrawPalette
+// color for the value held in the stencil buffer. The last draw is written
+// with the atom id i.
+func drawOverdrawHeatmap(ctx log.Context, i atom.ID, out transform.Writer) {
+	t := newTweaker(ctx, out)
+	t.glDisable(GLenum_GL_CULL_FACE)
+	t.glDisable(GLenum_GL_DEPTH_TEST)
+	t.glDisable(GLenum_GL_SCISSOR_TEST)
+	t.glEnable(GLenum_GL_STENCIL_TEST)
+	t.glStencilOp(GLenum_GL_KEEP, GLenum_GL_KEEP, GLenum_GL_KEEP)
+	// The quad outputs white, so the blend color is written as-is.
+	t.glEnable(GLenum_GL_BLEND)
+	t.glBlendFunc(GLenum_GL_CONSTANT_COLOR, GLenum_GL_ZERO)
+	bindFullscreenQuad(ctx, t, out)
+
+	last := len(overdrawPalette) - 1
+	for count, color := range overdrawPalette {
+		id, f := atom.NoID, GLenum_GL_EQUAL
+		if count == last {
+			// Passes when count <= stencil.
+			id, f = i, GLenum_GL_LEQUAL
+		}
+		t.glStencilFunc(f, GLint(count), 0xFFFFFFFF)
+		t.glBlendColor(color.Red, color.Green, color.Blue, color.Alpha)
+		out.MutateAndWrite(ctx, id, NewGlDrawArrays(GLenum_GL_TRIANGLE_STRIP, 0, 4))
+	}
+
+	t.revert()
+}
+
+// highlight returns an atom transform that dims the color buffer before the
+// draw call id is rendered, so that only the fragments of id are displayed at
+// full intensity.
+func highlight(ctx log.Context, id atom.ID) transform.Transformer {
+	ctx = ctx.Enter("Highlight")
+	return transform.Transform("Highlight", func(ctx log.Context, i atom.ID, a atom.Atom, out transform.Writer) {
+		if i == id {
+			if dc, ok := a.(drawCall); ok {
+				t := newTweaker(ctx, out)
+				t.glDisable(GLenum_GL_CULL_FACE)
+				t.glDisable(GLenum_GL_DEPTH_TEST)
+				t.glDisable(GLenum_GL_SCISSOR_TEST)
+				t.glDisable(GLenum_GL_STENCIL_TEST)
+				t.glEnable(GLenum_GL_BLEND)
+				t.glBlendColor(0.25, 0.25, 0.25, 1.0)
+				t.glBlendFunc(GLenum_GL_ZERO, GLenum_GL_CONSTANT_COLOR)
+				bindFullscreenQuad(ctx, t, out)
+				out.MutateAndWrite(ctx, atom.NoID, NewGlDrawArrays(GLenum_GL_TRIANGLE_STRIP, 0, 4))
+				t.revert()
+
+				out.MutateAndWrite(ctx, i, dc)
+				return
+			}
+		}
+
+		out.MutateAndWrite(ctx, i, a)
+	})
+}
+
+// hasStencilAttachment returns true if the bound draw framebuffer of c has a
+// stencil attachment.
+func hasStencilAttachment(c *Context) bool {
+	fb, ok := c.Instances.Framebuffers[c.BoundDrawFramebuffer]
+	return ok && fb.StencilAttachment.ObjectType != GLenum_GL_NONE
+}
+
+// bindFullscreenQuad binds a program and vertex buffer that render white over
+// the entire viewport with a GL_TRIANGLE_STRIP of 4 vertices. The bindings are
+// reverted by t.
+func bindFullscreenQuad(ctx log.Context, t *tweaker, out transform.Writer) {
+	const (
+		aScreenCoordsLocation AttributeLocation = 0
+
+		vertexShaderSource string = `
+					precision highp float;
+					attribute vec2 aScreenCoords;
+
+					void main() {
+						gl_Position = vec4(aScreenCoords.xy, 0., 1.);
+					}`
+		fragmentShaderSource string = `
+					precision highp float;
+
+					void main() {
+						gl_FragColor = vec4(1.0, 1.0, 1.0, 1.0);
+					}`
+	)
+
+	// 2D vertices positions for a full screen 2D triangle strip.
+	positions := []float32{-1., -1., 1., -1., -1., 1., 1., 1.}
+
+	t.makeVertexArray(aScreenCoordsLocation)
+
+	programID := t.makeProgram(vertexShaderSource, fragmentShaderSource)
+
+	out.MutateAndWrite(ctx, atom.NoID, NewGlBindAttribLocation(programID, aScreenCoordsLocation, "aScreenCoords"))
+	out.MutateAndWrite(ctx, atom.NoID, NewGlLinkProgram(programID))
+	t.glUseProgram(programID)
+
+	bufferID := t.glGenBuffer()
+	t.GlBindBuffer_ArrayBuffer(bufferID)
+
+	tmp := t.AllocData(positions)
+	out.MutateAndWrite(ctx, atom.NoID, NewGlBufferData(GLenum_GL_ARRAY_BUFFER, GLsizeiptr(4*len(positions)), tmp.Ptr(), GLenum_GL_STATIC_DRAW).
+		AddRead(tmp.Data()))
+
+	out.MutateAndWrite(ctx, atom.NoID, NewGlVertexAttribPointer(aScreenCoordsLocation, 2, GLenum_GL_FLOAT, GLboolean(0), 0, memory.Nullptr))
+}
@@ -0,0 +1,226 @@
+// Copyright (C) 2017 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gles
+
+import (
+	"testing"
+
+	"github.com/google/gapid/core/assert"
+	"github.com/google/gapid/core/log"
+	"github.com/google/gapid/gapis/atom"
+	"github.com/google/gapid/gapis/atom/test"
+	"github.com/google/gapid/gapis/atom/transform"
+	"github.com/google/gapid/gapis/capture"
+	"github.com/google/gapid/gapis/database"
+	"github.com/google/gapid/gapis/memory"
+	"github.com/google/gapid/gapis/messages"
+	"github.com/google/gapid/gapis/service"
+)
+
+// newTestState returns the context holding an empty capture, a writer with
+// the state of the capture and the atoms that create and bind a context with a
+// 64x64 backbuffer.
+func newTestState(ctx log.Context) (log.Context, *test.MockAtomWriter, []atom.Atom) {
+	p, err := capture.ImportAtomList(ctx, "test", atom.NewList())
+	if err != nil {
+		panic(err)
+	}
+	ctx = capture.Put(ctx, p)
+	ctxHandle := memory.Pointer{Pool: memory.ApplicationPool, Address: 1}
+	prologue := []atom.Atom{
+		NewEglCreateContext(memory.Nullptr, memory.Nullptr, memory.Nullptr, memory.Nullptr, ctxHandle),
+		atom.WithExtras(
+			NewEglMakeCurrent(memory.Nullptr, memory.Nullptr, memory.Nullptr, ctxHandle, 0),
+			NewStaticContextState(), NewDynamicContextState(64, 64, false)),
+	}
+	return ctx, &test.MockAtomWriter{S: capture.NewState(ctx)}, prologue
+}
+
+// runTransform passes the atoms through t, with their index as the atom id.
+func runTransform(ctx log.Context, t transform.Transformer, atoms []atom.Atom, out transform.Writer) {
+	for i, a := range atoms {
+		t.Transform(ctx, atom.ID(i), a, out)
+	}
+	t.Flush(ctx, out)
+}
+
+// isStencilClear returns true if a is a glClear of only the stencil buffer.
+func isStencilClear(a atom.Atom) bool {
+	c, ok := a.(*GlClear)
+	return ok && c.Mask == GLbitfield_GL_STENCIL_BUFFER_BIT
+}
+
+// isQuad returns true if a is the draw of a fullscreen quad.
+func isQuad(a atom.Atom) bool {
+	d, ok := a.(*GlDrawArrays)
+	return ok && d.DrawMode == GLenum_GL_TRIANGLE_STRIP && d.FirstIndex == 0 && d.IndicesCount == 4
+}
+
+func TestOverdraw(t *testing.T) {
+	ctx := log.Testing(t)
+	ctx = database.Put(ctx, database.NewInMemory(ctx))
+
+	for _, test := range []struct {
+		name        string
+		depthTested bool
+		depthFailOp GLenum
+	}{
+		{"overdraw", false, GLenum_GL_INCR},
+		{"depth complexity", true, GLenum_GL_KEEP},
+	} {
+		ctx, out, atoms := newTestState(ctx.S("test", test.name))
+		first := atom.ID(len(atoms))
+		draw := NewGlDrawArrays(GLenum_GL_TRIANGLES, 0, 3)
+		atoms = append(atoms,
+			NewGlDrawArrays(GLenum_GL_TRIANGLES, 0, 3), // first: previous frame
+			NewEglSwapBuffers(memory.Nullptr, memory.Nullptr, EGLBoolean(1)),
+			NewGlDrawArrays(GLenum_GL_TRIANGLES, 0, 3), // first + 2: cleared again
+			NewGlDrawArrays(GLenum_GL_TRIANGLES, 0, 3), // first + 3: not cleared
+			draw, // first + 4: requested
+			NewGlDrawArrays(GLenum_GL_TRIANGLES, 0, 3), // first + 5: after
+		)
+		id := first + 4
+
+		res := make(chan imgRes, 1)
+		runTransform(ctx, overdraw(ctx, id, test.depthTested, []chan<- imgRes{res}), atoms, out)
+
+		// Each of the draw calls up to id counts its fragments in the stencil
+		// buffer, which is cleared on the first draw call of each frame.
+		clears, counted := 0, 0
+		for _, a := range out.Atoms {
+			if isStencilClear(a) {
+				clears++
+			}
+			if op, ok := a.(*GlStencilOp); ok && op.Zpass == GLenum_GL_INCR {
+				assert.For(ctx, "depth fail op").That(op.Zfail).Equals(test.depthFailOp)
+				counted++
+			}
+		}
+		assert.For(ctx, "stencil clears").That(clears).Equals(2)
+		assert.For(ctx, "counted draws").That(counted).Equals(4)
+
+		// The requested draw is followed by the heat-map, the last draw of
+		// which takes the atom id so that the framebuffer is read after it.
+		idAtoms := map[atom.ID][]atom.Atom{}
+		requested, quads := -1, 0
+		for i, a := range out.IdAtoms {
+			idAtoms[a.Id] = append(idAtoms[a.Id], a.Atom)
+			if a.Atom == draw {
+				assert.For(ctx, "requested draw id").That(a.Id).Equals(atom.NoID)
+				requested = i
+			}
+			if requested >= 0 && isQuad(a.Atom) {
+				quads++
+			}
+		}
+		assert.For(ctx, "requested draw written").That(requested >= 0).Equals(true)
+		assert.For(ctx, "heat-map draws").That(quads).Equals(len(overdrawPalette))
+		if got := idAtoms[id]; assert.For(ctx, "atoms with id").ThatSlice(got).IsLength(1) {
+			assert.For(ctx, "last heat-map draw").That(isQuad(got[0])).Equals(true)
+		}
+		// Atoms after the request are passed through.
+		assert.For(ctx, "after").ThatSlice(idAtoms[id+1]).Equals([]atom.Atom{atoms[id+1]})
+		assert.For(ctx, "stencil test reverted").That(
+			GetContext(out.S).FragmentOperations.Stencil.Test).Equals(GLboolean_GL_FALSE)
+
+		select {
+		case r := <-res:
+			t.Errorf("Unexpected result sent: %+v", r)
+		default:
+		}
+	}
+}
+
+func TestOverdrawNoStencil(t *testing.T) {
+	ctx := log.Testing(t)
+	ctx = database.Put(ctx, database.NewInMemory(ctx))
+
+	ctx, out, atoms := newTestState(ctx)
+	for i, a := range atoms {
+		out.MutateAndWrite(ctx, atom.ID(i), a)
+	}
+	GetContext(out.S).Instances.Framebuffers[0].StencilAttachment = FramebufferAttachment{}
+
+	draw := NewGlDrawArrays(GLenum_GL_TRIANGLES, 0, 3)
+	resA, resB := make(chan imgRes, 1), make(chan imgRes, 1)
+	w := &test.MockAtomWriter{S: out.S}
+	runTransform(ctx, overdraw(ctx, 0, false, []chan<- imgRes{resA, resB}), []atom.Atom{draw}, w)
+
+	expected := &service.ErrDataUnavailable{Reason: messages.ErrNoStencilAttachment()}
+	for _, res := range []chan imgRes{resA, resB} {
+		select {
+		case r := <-res:
+			assert.For(ctx, "err").ThatError(r.err).DeepEquals(expected)
+			assert.For(ctx, "img").That(r.img).IsNil()
+		default:
+			t.Errorf("No result sent for the request")
+		}
+	}
+	// The draw is written without its id, so the framebuffer isn't read.
+	assert.For(ctx, "atoms").ThatSlice(w.IdAtoms).Equals(test.AtomAtomIDList{{draw, atom.NoID}})
+}
+
+func TestHighlight(t *testing.T) {
+	ctx := log.Testing(t)
+	ctx = database.Put(ctx, database.NewInMemory(ctx))
+
+	ctx, out, atoms := newTestState(ctx)
+	first := atom.ID(len(atoms))
+	draw := NewGlDrawArrays(GLenum_GL_TRIANGLES, 0, 3)
+	atoms = append(atoms,
+		NewGlDrawArrays(GLenum_GL_TRIANGLES, 0, 3),
+		draw,
+		NewGlDrawArrays(GLenum_GL_TRIANGLES, 0, 3),
+	)
+	id := first + 1
+	runTransform(ctx, highlight(ctx, id), atoms, out)
+
+	// All the atoms are written with their ids, the requested draw call is
+	// preceded by the dimming quad.
+	quad, dim := -1, false
+	for i, a := range out.IdAtoms {
+		switch {
+		case a.Id != atom.NoID:
+			assert.For(ctx, "atom %v", a.Id).That(a.Atom).Equals(atoms[a.Id])
+		case isQuad(a.Atom):
+			quad = i
+		}
+		if c, ok := a.Atom.(*GlBlendColor); ok && c.Red == 0.25 && c.Green == 0.25 && c.Blue == 0.25 {
+			dim = true
+		}
+	}
+	assert.For(ctx, "dim blend color").That(dim).Equals(true)
+	if assert.For(ctx, "quad drawn").That(quad >= 0).Equals(true) {
+		rest := out.IdAtoms[quad+1:]
+		// The quad's bindings are reverted before the requested draw.
+		for _, a := range rest {
+			if a.Id != atom.NoID {
+				assert.For(ctx, "draw after quad").That(a.Atom).Equals(draw)
+				break
+			}
+		}
+	}
+	ids := []atom.ID{}
+	for _, a := range out.IdAtoms {
+		if a.Id != atom.NoID {
+			ids = append(ids, a.Id)
+		}
+	}
+	expected := []atom.ID{}
+	for i := range atoms {
+		expected = append(expected, atom.ID(i))
+	}
+	assert.For(ctx, "ids").ThatSlice(ids).Equals(expected)
+}
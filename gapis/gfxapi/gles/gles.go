@@ -44,6 +44,24 @@ func (api) GetFramebufferAttachmentInfo(state *gfxapi.State, attachment gfxapi.F
 	return w, h, f, err
 }
 
+// GetFramebufferAttachmentLayers returns the number of layers and mip levels
+// of the image bound to the specified framebuffer attachment.
+func (api) GetFramebufferAttachmentLayers(state *gfxapi.State, attachment gfxapi.FramebufferAttachment) (layers, levels uint32, err error) {
+	return GetState(state).getFramebufferAttachmentLayers(attachment)
+}
+
+// GetFramebufferAttachmentLayerInfo returns the width, height and format of a
+// single layer and level of the image bound to the specified framebuffer
+// attachment.
+func (api) GetFramebufferAttachmentLayerInfo(state *gfxapi.State, attachment gfxapi.FramebufferAttachment, layer *gfxapi.FramebufferLayer) (width, height uint32, format *image.Format, err error) {
+	w, h, ifmt, err := GetState(state).getFramebufferAttachmentLayerInfo(attachment, layer)
+	if err != nil {
+		return 0, 0, nil, err
+	}
+	f, err := ifmt.asImage()
+	return w, h, f, err
+}
+
 // Context returns the active context for the given state.
 func (api) Context(s *gfxapi.State) gfxapi.Context {
 	if c := GetContext(s); c != nil {
@@ -141,6 +141,10 @@ func (f imgfmt) asImage() (*image.Format, error) {
 			return image.NewUncompressed("GL_RED", fmts.R_F16), nil
 		case GLenum_GL_FLOAT:
 			return image.NewUncompressed("GL_RED", fmts.R_F32), nil
+		case GLenum_GL_COMPRESSED_R11_EAC:
+			return image.NewETC2_R11_EAC("GL_COMPRESSED_R11_EAC"), nil
+		case GLenum_GL_COMPRESSED_SIGNED_R11_EAC:
+			return image.NewETC2_SIGNED_R11_EAC("GL_COMPRESSED_SIGNED_R11_EAC"), nil
 		}
 	case GLenum_GL_RED_INTEGER:
 		switch ty {
@@ -170,6 +174,10 @@ func (f imgfmt) asImage() (*image.Format, error) {
 			return image.NewUncompressed("GL_RG", fmts.RG_F16), nil
 		case GLenum_GL_FLOAT:
 			return image.NewUncompressed("GL_RG", fmts.RG_F32), nil
+		case GLenum_GL_COMPRESSED_RG11_EAC:
+			return image.NewETC2_RG11_EAC("GL_COMPRESSED_RG11_EAC"), nil
+		case GLenum_GL_COMPRESSED_SIGNED_RG11_EAC:
+			return image.NewETC2_SIGNED_RG11_EAC("GL_COMPRESSED_SIGNED_RG11_EAC"), nil
 		}
 	case GLenum_GL_RG_INTEGER:
 		switch ty {
@@ -298,6 +306,30 @@ func (f imgfmt) asImage() (*image.Format, error) {
 		return image.NewS3_DXT3_RGBA("GL_COMPRESSED_RGBA_S3TC_DXT3_EXT"), nil
 	case GLenum_GL_COMPRESSED_RGBA_S3TC_DXT5_EXT:
 		return image.NewS3_DXT5_RGBA("GL_COMPRESSED_RGBA_S3TC_DXT5_EXT"), nil
+	case GLenum_GL_COMPRESSED_R11_EAC:
+		return image.NewETC2_R11_EAC("GL_COMPRESSED_R11_EAC"), nil
+	case GLenum_GL_COMPRESSED_SIGNED_R11_EAC:
+		return image.NewETC2_SIGNED_R11_EAC("GL_COMPRESSED_SIGNED_R11_EAC"), nil
+	case GLenum_GL_COMPRESSED_RG11_EAC:
+		return image.NewETC2_RG11_EAC("GL_COMPRESSED_RG11_EAC"), nil
+	case GLenum_GL_COMPRESSED_SIGNED_RG11_EAC:
+		return image.NewETC2_SIGNED_RG11_EAC("GL_COMPRESSED_SIGNED_RG11_EAC"), nil
+	case GLenum_GL_COMPRESSED_RED_RGTC1:
+		return image.NewRGTC1_BC4_R_U8_NORM("GL_COMPRESSED_RED_RGTC1"), nil
+	case GLenum_GL_COMPRESSED_SIGNED_RED_RGTC1:
+		return image.NewRGTC1_BC4_R_S8_NORM("GL_COMPRESSED_SIGNED_RED_RGTC1"), nil
+	case GLenum_GL_COMPRESSED_RG_RGTC2:
+		return image.NewRGTC2_BC5_RG_U8_NORM("GL_COMPRESSED_RG_RGTC2"), nil
+	case GLenum_GL_COMPRESSED_SIGNED_RG_RGTC2:
+		return image.NewRGTC2_BC5_RG_S8_NORM("GL_COMPRESSED_SIGNED_RG_RGTC2"), nil
+	case GLenum_GL_COMPRESSED_RGB_BPTC_UNSIGNED_FLOAT:
+		return image.NewBPTC_BC6H_RGB_UFLOAT("GL_COMPRESSED_RGB_BPTC_UNSIGNED_FLOAT"), nil
+	case GLenum_GL_COMPRESSED_RGB_BPTC_SIGNED_FLOAT:
+		return image.NewBPTC_BC6H_RGB_SFLOAT("GL_COMPRESSED_RGB_BPTC_SIGNED_FLOAT"), nil
+	case GLenum_GL_COMPRESSED_RGBA_BPTC_UNORM:
+		return image.NewBPTC_BC7_RGBA_U8_NORM("GL_COMPRESSED_RGBA_BPTC_UNORM"), nil
+	case GLenum_GL_COMPRESSED_SRGB_ALPHA_BPTC_UNORM:
+		return image.NewBPTC_BC7_SRGB_ALPHA_U8_NORM("GL_COMPRESSED_SRGB_ALPHA_BPTC_UNORM"), nil
 	}
 
 	return nil, fmt.Errorf("Unsupported input format-type pair: (%s, %s)", f.base, ty)
@@ -0,0 +1,237 @@
+// Copyright (C) 2017 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gles
+
+import (
+	"sort"
+
+	"github.com/google/gapid/core/fault/severity"
+	"github.com/google/gapid/core/log"
+	"github.com/google/gapid/gapis/atom"
+	"github.com/google/gapid/gapis/gfxapi"
+	"github.com/google/gapid/gapis/memory"
+	"github.com/google/gapid/gapis/messages"
+	"github.com/google/gapid/gapis/stringtable"
+)
+
+// Interface compliance test
+var _ = gfxapi.Linter(api{})
+
+// minMipmapLintSize is the smallest texture dimension for which sampling a
+// texture without mipmaps is reported.
+const minMipmapLintSize = 256
+
+// lint implements the gfxapi.Lint interface, reporting the GLES performance
+// anti-patterns found in a capture.
+type lint struct {
+	frame    uint64                   // The index of the current frame.
+	reported map[interface{}]struct{} // Issues that are only reported once.
+}
+
+type unusedAttributeKey struct {
+	program *Program
+	array   *VertexArray
+	loc     AttributeLocation
+}
+
+type unmipmappedTextureKey struct {
+	texture *Texture
+}
+
+// NewLint implements the gfxapi.Linter interface.
+func (api) NewLint(ctx log.Context) gfxapi.Lint {
+	return &lint{reported: map[interface{}]struct{}{}}
+}
+
+// Check implements the gfxapi.Lint interface.
+func (l *lint) Check(ctx log.Context, o interface{}, s *gfxapi.State) []gfxapi.LintIssue {
+	a, ok := o.(atom.Atom)
+	if !ok {
+		return nil
+	}
+	if a.AtomFlags().IsEndOfFrame() {
+		defer func() { l.frame++ }()
+	}
+	c := GetContext(s)
+	if c == nil {
+		return nil
+	}
+
+	out := []gfxapi.LintIssue{}
+	warn := func(m *stringtable.Msg) {
+		out = append(out, gfxapi.LintIssue{Severity: severity.Warning, Message: m})
+	}
+
+	switch a := a.(type) {
+	case *GlEnable:
+		enabled, err := subGetCapability(ctx, a, nil, s, GetState(s), nil, a.Capability, 0)
+		if err == nil && enabled == GLboolean_GL_TRUE {
+			warn(messages.WarnRedundantStateChange())
+		}
+
+	case *GlDisable:
+		enabled, err := subGetCapability(ctx, a, nil, s, GetState(s), nil, a.Capability, 0)
+		if err == nil && enabled == GLboolean_GL_FALSE {
+			warn(messages.WarnRedundantStateChange())
+		}
+
+	case *GlUseProgram:
+		if c.BoundProgram == a.Program {
+			warn(messages.WarnRedundantStateChange())
+		}
+
+	case *GlBindBuffer:
+		switch a.Target {
+		case GLenum_GL_ARRAY_BUFFER:
+			if c.BoundBuffers.ArrayBuffer == a.Buffer {
+				warn(messages.WarnRedundantStateChange())
+			}
+		case GLenum_GL_ELEMENT_ARRAY_BUFFER:
+			if va := c.Instances.VertexArrays[c.BoundVertexArray]; va != nil && va.ElementArrayBuffer == a.Buffer {
+				warn(messages.WarnRedundantStateChange())
+			}
+		}
+
+	case *GlBindTexture:
+		if a.Texture == 0 {
+			break
+		}
+		tex, err := subGetBoundTextureForUnit(ctx, a, nil, s, GetState(s), nil, c, c.ActiveTextureUnit, a.Target)
+		if err == nil && tex != nil && tex == c.Instances.Textures[a.Texture] {
+			warn(messages.WarnRedundantStateChange())
+		}
+
+	case *GlCompileShader:
+		if l.frame > 0 {
+			warn(messages.WarnShaderCompiledAfterFirstFrame(uint32(a.Shader), l.frame))
+		}
+
+	case *GlBufferData:
+		if a.Data.Address == 0 {
+			break
+		}
+		b, err := subGetBoundBufferOrError(ctx, a, nil, s, GetState(s), nil, a.Target)
+		if err != nil || b == nil || b.Size != a.Size {
+			break
+		}
+		a.Extras().Observations().ApplyReads(s.Memory[memory.ApplicationPool])
+		data := U8ᵖ(a.Data).Slice(0, uint64(a.Size), s)
+		if data.ResourceID(ctx, s) == b.Data.ResourceID(ctx, s) {
+			warn(messages.WarnRedundantBufferUpload())
+		}
+
+	case *GlBufferSubData:
+		b, err := subGetBoundBufferOrError(ctx, a, nil, s, GetState(s), nil, a.Target)
+		if err != nil || b == nil || a.Offset < 0 || a.Size <= 0 ||
+			GLsizeiptr(a.Offset)+a.Size > b.Size {
+			break
+		}
+		a.Extras().Observations().ApplyReads(s.Memory[memory.ApplicationPool])
+		start, end := uint64(a.Offset), uint64(a.Offset)+uint64(a.Size)
+		data := U8ᵖ(a.Data).Slice(0, uint64(a.Size), s)
+		if data.ResourceID(ctx, s) == b.Data.Slice(start, end, s).ResourceID(ctx, s) {
+			warn(messages.WarnRedundantBufferUpload())
+		}
+	}
+
+	if a.AtomFlags().IsDrawCall() {
+		l.checkDrawCall(ctx, a, s, c, warn)
+	}
+	return out
+}
+
+// checkDrawCall reports the enabled vertex attribute arrays that are not used
+// by the bound program, and the large textures sampled without mipmaps.
+// Each issue is only reported for the first draw call it is found in.
+func (l *lint) checkDrawCall(ctx log.Context, a atom.Atom, s *gfxapi.State, c *Context, warn func(*stringtable.Msg)) {
+	program, ok := c.Instances.Programs[c.BoundProgram]
+	if !ok || program == nil {
+		return
+	}
+
+	if va, ok := c.Instances.VertexArrays[c.BoundVertexArray]; ok && va != nil {
+		used := map[AttributeLocation]bool{}
+		for _, attr := range program.ActiveAttributes {
+			used[attr.Location] = true
+		}
+		locations := []int{}
+		for loc, vaa := range va.VertexAttributeArrays {
+			if vaa != nil && vaa.Enabled == GLboolean_GL_TRUE && !used[loc] {
+				locations = append(locations, int(loc))
+			}
+		}
+		sort.Ints(locations)
+		for _, loc := range locations {
+			if l.once(unusedAttributeKey{program, va, AttributeLocation(loc)}) {
+				warn(messages.WarnUnusedVertexAttribute(uint32(loc), uint32(c.BoundProgram)))
+			}
+		}
+	}
+
+	for _, u := range program.ActiveUniforms {
+		if !isSampler(u.Type) {
+			continue
+		}
+		target, err := subGetTextureTargetFromSamplerType(ctx, a, nil, s, GetState(s), nil, u.Type)
+		if err != nil || target != GLenum_GL_TEXTURE_2D {
+			continue
+		}
+		for i := 0; i < int(u.ArraySize); i++ {
+			uniform := program.Uniforms[u.Location+UniformLocation(i)]
+			units := AsU32ˢ(uniform.Value, s).Read(ctx, a, s, nil)
+			if len(units) == 0 {
+				units = []uint32{0} // The uniform was not set, so use default value.
+			}
+			for _, unit := range units {
+				l.checkTextureMipmaps(ctx, a, s, c, GLenum(unit)+GLenum_GL_TEXTURE0, target, warn)
+			}
+		}
+	}
+}
+
+// checkTextureMipmaps reports the 2D texture bound to unit if it is large, has
+// no mipmaps and is sampled with a minification filter that does not use
+// mipmaps.
+func (l *lint) checkTextureMipmaps(ctx log.Context, a atom.Atom, s *gfxapi.State, c *Context, unit, target GLenum, warn func(*stringtable.Msg)) {
+	tex, err := subGetBoundTextureForUnit(ctx, a, nil, s, GetState(s), nil, c, unit, target)
+	if err != nil || tex == nil || len(tex.Texture2D) != 1 {
+		return
+	}
+	img, ok := tex.Texture2D[0]
+	if !ok || (img.Width < minMipmapLintSize && img.Height < minMipmapLintSize) {
+		return
+	}
+	filter := tex.MinFilter
+	if tu := c.TextureUnits[unit]; tu != nil && tu.SamplerBinding != 0 {
+		if sampler, ok := c.Instances.Samplers[tu.SamplerBinding]; ok {
+			filter = sampler.MinFilter
+		}
+	}
+	if filter != GLenum_GL_LINEAR && filter != GLenum_GL_NEAREST {
+		return
+	}
+	if l.once(unmipmappedTextureKey{tex}) {
+		warn(messages.WarnTextureNotMipmapped(uint32(tex.ID), int64(img.Width), int64(img.Height), filter.String()))
+	}
+}
+
+// once returns true the first time it is called with key.
+func (l *lint) once(key interface{}) bool {
+	if _, ok := l.reported[key]; ok {
+		return false
+	}
+	l.reported[key] = struct{}{}
+	return true
+}
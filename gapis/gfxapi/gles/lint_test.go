@@ -0,0 +1,192 @@
+// Copyright (C) 2017 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gles
+
+import (
+	"testing"
+
+	"github.com/google/gapid/core/assert"
+	"github.com/google/gapid/core/fault/severity"
+	"github.com/google/gapid/core/log"
+	"github.com/google/gapid/gapis/atom"
+	"github.com/google/gapid/gapis/database"
+	"github.com/google/gapid/gapis/gfxapi"
+	"github.com/google/gapid/gapis/memory"
+	"github.com/google/gapid/gapis/messages"
+	"github.com/google/gapid/gapis/stringtable"
+)
+
+// lintProgram returns the atoms that build and link a program with a single
+// vertex attribute at location 0 and a 2D sampler uniform at location 0.
+func lintProgram(ctx log.Context, s *gfxapi.State, prog ProgramId) []atom.Atom {
+	return append(BuildProgram(ctx, s, 1, 2, prog, "vertex", "fragment"),
+		atom.WithExtras(NewGlLinkProgram(prog), &ProgramInfo{
+			LinkStatus: GLboolean_GL_TRUE,
+			ActiveAttributes: AttributeIndexːActiveAttributeᵐ{
+				0: {Name: "position", Type: GLenum_GL_FLOAT_VEC4, ArraySize: 1, Location: 0},
+			},
+			ActiveUniforms: UniformIndexːActiveUniformᵐ{
+				0: {Name: "tex", Type: GLenum_GL_SAMPLER_2D, ArraySize: 1, Location: 0},
+			},
+		}),
+	)
+}
+
+func TestLint(t *testing.T) {
+	ctx := log.Testing(t)
+	ctx = database.Put(ctx, database.NewInMemory(ctx))
+	const prog = ProgramId(3)
+	redundant := messages.WarnRedundantStateChange()
+
+	for _, test := range []struct {
+		name  string
+		atoms func(s *gfxapi.State) ([]atom.Atom, map[atom.Atom][]*stringtable.Msg)
+	}{
+		{"redundant capability", func(s *gfxapi.State) ([]atom.Atom, map[atom.Atom][]*stringtable.Msg) {
+			enable, disable := NewGlEnable(GLenum_GL_BLEND), NewGlDisable(GLenum_GL_BLEND)
+			return []atom.Atom{
+				NewGlEnable(GLenum_GL_BLEND), // Disabled by default.
+				enable,
+				NewGlDisable(GLenum_GL_BLEND),
+				disable,
+			}, map[atom.Atom][]*stringtable.Msg{
+				enable:  {redundant},
+				disable: {redundant},
+			}
+		}},
+		{"redundant program", func(s *gfxapi.State) ([]atom.Atom, map[atom.Atom][]*stringtable.Msg) {
+			use := NewGlUseProgram(prog)
+			return append(lintProgram(ctx, s, prog),
+				NewGlUseProgram(prog),
+				use,
+				NewGlUseProgram(0),
+			), map[atom.Atom][]*stringtable.Msg{
+				use: {redundant},
+			}
+		}},
+		{"redundant buffer binding", func(s *gfxapi.State) ([]atom.Atom, map[atom.Atom][]*stringtable.Msg) {
+			array := NewGlBindBuffer(GLenum_GL_ARRAY_BUFFER, 1)
+			elements := NewGlBindBuffer(GLenum_GL_ELEMENT_ARRAY_BUFFER, 2)
+			return []atom.Atom{
+				NewGlBindBuffer(GLenum_GL_ARRAY_BUFFER, 1),
+				array,
+				NewGlBindBuffer(GLenum_GL_ELEMENT_ARRAY_BUFFER, 2),
+				elements,
+				NewGlBindBuffer(GLenum_GL_ARRAY_BUFFER, 2),
+			}, map[atom.Atom][]*stringtable.Msg{
+				array:    {redundant},
+				elements: {redundant},
+			}
+		}},
+		{"redundant texture binding", func(s *gfxapi.State) ([]atom.Atom, map[atom.Atom][]*stringtable.Msg) {
+			bind := NewGlBindTexture(GLenum_GL_TEXTURE_2D, 1)
+			return []atom.Atom{
+				NewGlBindTexture(GLenum_GL_TEXTURE_2D, 1),
+				bind,
+				NewGlActiveTexture(GLenum_GL_TEXTURE1),
+				NewGlBindTexture(GLenum_GL_TEXTURE_2D, 1), // Different unit.
+				NewGlBindTexture(GLenum_GL_TEXTURE_2D, 0),
+				NewGlBindTexture(GLenum_GL_TEXTURE_2D, 0), // Unbinding is not reported.
+			}, map[atom.Atom][]*stringtable.Msg{
+				bind: {redundant},
+			}
+		}},
+		{"shader compiled after first frame", func(s *gfxapi.State) ([]atom.Atom, map[atom.Atom][]*stringtable.Msg) {
+			compile := NewGlCompileShader(1)
+			return []atom.Atom{
+				NewGlCreateShader(GLenum_GL_VERTEX_SHADER, 1),
+				NewGlCompileShader(1),
+				NewEglSwapBuffers(memory.Nullptr, memory.Nullptr, EGLBoolean(1)),
+				compile,
+			}, map[atom.Atom][]*stringtable.Msg{
+				compile: {messages.WarnShaderCompiledAfterFirstFrame(1, 1)},
+			}
+		}},
+		{"redundant buffer upload", func(s *gfxapi.State) ([]atom.Atom, map[atom.Atom][]*stringtable.Msg) {
+			data := atom.Must(atom.AllocData(ctx, s, []uint8{1, 2, 3, 4}))
+			other := atom.Must(atom.AllocData(ctx, s, []uint8{1, 2, 5, 6}))
+			upload := NewGlBufferData(GLenum_GL_ARRAY_BUFFER, 4, data.Ptr(), GLenum_GL_STATIC_DRAW).
+				AddRead(data.Data())
+			subUpload := NewGlBufferSubData(GLenum_GL_ARRAY_BUFFER, 0, 4, data.Ptr()).
+				AddRead(data.Data())
+			return []atom.Atom{
+				NewGlBindBuffer(GLenum_GL_ARRAY_BUFFER, 1),
+				NewGlBufferData(GLenum_GL_ARRAY_BUFFER, 4, data.Ptr(), GLenum_GL_STATIC_DRAW).
+					AddRead(data.Data()),
+				upload,
+				subUpload,
+				NewGlBufferSubData(GLenum_GL_ARRAY_BUFFER, 0, 4, other.Ptr()).
+					AddRead(other.Data()),
+				NewGlBufferData(GLenum_GL_ARRAY_BUFFER, 4, memory.Nullptr, GLenum_GL_STATIC_DRAW),
+			}, map[atom.Atom][]*stringtable.Msg{
+				upload:    {messages.WarnRedundantBufferUpload()},
+				subUpload: {messages.WarnRedundantBufferUpload()},
+			}
+		}},
+		{"unused vertex attribute", func(s *gfxapi.State) ([]atom.Atom, map[atom.Atom][]*stringtable.Msg) {
+			draw := NewGlDrawArrays(GLenum_GL_TRIANGLES, 0, 3)
+			return append(lintProgram(ctx, s, prog),
+				NewGlUseProgram(prog),
+				NewGlEnableVertexAttribArray(0),
+				NewGlEnableVertexAttribArray(3),
+				draw,
+				NewGlDrawArrays(GLenum_GL_TRIANGLES, 0, 3), // Reported once.
+			), map[atom.Atom][]*stringtable.Msg{
+				draw: {messages.WarnUnusedVertexAttribute(3, uint32(prog))},
+			}
+		}},
+		{"texture not mipmapped", func(s *gfxapi.State) ([]atom.Atom, map[atom.Atom][]*stringtable.Msg) {
+			small := NewGlDrawArrays(GLenum_GL_TRIANGLES, 0, 3)
+			mipmapped := NewGlDrawArrays(GLenum_GL_TRIANGLES, 0, 3)
+			draw := NewGlDrawArrays(GLenum_GL_TRIANGLES, 0, 3)
+			image := func(size GLsizei) atom.Atom {
+				return NewGlTexImage2D(GLenum_GL_TEXTURE_2D, 0, GLint(GLenum_GL_RGBA), size, size, 0,
+					GLenum_GL_RGBA, GLenum_GL_UNSIGNED_BYTE, memory.Nullptr)
+			}
+			return append(lintProgram(ctx, s, prog),
+				NewGlUseProgram(prog),
+				NewGlUniform1i(0, 0),
+				NewGlBindTexture(GLenum_GL_TEXTURE_2D, 1),
+				NewGlTexParameteri(GLenum_GL_TEXTURE_2D, GLenum_GL_TEXTURE_MIN_FILTER, GLint(GLenum_GL_LINEAR)),
+				image(64),
+				small,
+				image(512),
+				NewGlTexParameteri(GLenum_GL_TEXTURE_2D, GLenum_GL_TEXTURE_MIN_FILTER, GLint(GLenum_GL_LINEAR_MIPMAP_LINEAR)),
+				mipmapped,
+				NewGlTexParameteri(GLenum_GL_TEXTURE_2D, GLenum_GL_TEXTURE_MIN_FILTER, GLint(GLenum_GL_LINEAR)),
+				draw,
+				NewGlDrawArrays(GLenum_GL_TRIANGLES, 0, 3), // Reported once.
+			), map[atom.Atom][]*stringtable.Msg{
+				draw: {messages.WarnTextureNotMipmapped(1, 512, 512, GLenum_GL_LINEAR.String())},
+			}
+		}},
+	} {
+		ctx, out, prologue := newTestState(ctx.S("test", test.name))
+		atoms, expected := test.atoms(out.S)
+		atoms = append(prologue, atoms...)
+
+		l := api{}.NewLint(ctx)
+		got := map[atom.Atom][]*stringtable.Msg{}
+		for i, a := range atoms {
+			// The lint is checked against the state before the atom is mutated.
+			for _, issue := range l.Check(ctx, a, out.S) {
+				assert.For(ctx, "severity").That(issue.Severity).Equals(severity.Warning)
+				got[a] = append(got[a], issue.Message)
+			}
+			out.MutateAndWrite(ctx, atom.ID(i), a)
+		}
+		assert.For(ctx, "issues").That(got).DeepEquals(expected)
+	}
+}
@@ -0,0 +1,502 @@
+// Copyright (C) 2017 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gles
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/google/gapid/core/data/endian"
+	"github.com/google/gapid/core/event/task"
+	"github.com/google/gapid/core/image"
+	"github.com/google/gapid/core/log"
+	"github.com/google/gapid/core/os/device"
+	"github.com/google/gapid/core/stream/fmts"
+	"github.com/google/gapid/gapis/atom"
+	"github.com/google/gapid/gapis/atom/transform"
+	"github.com/google/gapid/gapis/capture"
+	"github.com/google/gapid/gapis/gfxapi"
+	"github.com/google/gapid/gapis/memory"
+	"github.com/google/gapid/gapis/messages"
+	"github.com/google/gapid/gapis/replay"
+	"github.com/google/gapid/gapis/service"
+)
+
+var depthF32 = image.NewUncompressed("D_F32", fmts.D_F32)
+
+// pixelHistoryConfig is a replay.Config used by the framebuffer requests of a
+// pixel history query that read the fragment shader output of its draw calls.
+// All the draw calls are isolated in a single replay, so the framebuffer read
+// before each of the draw calls is compared to the read after it. Each query
+// uses a new *pixelHistoryConfig, so its requests are batched together.
+type pixelHistoryConfig struct {
+	isolate map[atom.ID]bool // The draw calls to isolate.
+}
+
+// pixelHistoryTarget identifies the object backing a framebuffer attachment.
+type pixelHistoryTarget struct {
+	objectType GLenum
+	name       GLuint
+	eglImage   GLeglImageOES
+}
+
+// pixelHistoryCommand holds the information about a draw call or clear that
+// may have modified the pixel.
+type pixelHistoryCommand struct {
+	id          atom.ID
+	isDraw      bool
+	attachments map[gfxapi.FramebufferAttachment]pixelHistoryAttachment
+	slot        gfxapi.FramebufferAttachment // The attachment holding the target.
+	depthTest   bool
+	stencilTest bool
+}
+
+// pixelHistoryAttachment describes an attachment of the framebuffer bound for
+// a draw call or clear.
+type pixelHistoryAttachment struct {
+	target        pixelHistoryTarget
+	width, height uint32
+	blend         *service.BlendState // nil for depth attachments.
+}
+
+// pixelRes holds the value of a single pixel read from a framebuffer.
+type pixelRes struct {
+	value []float32
+	err   error
+}
+
+// isolateFragments returns an atom transform that disables the depth and
+// stencil tests and blending for the draw calls with the specified
+// identifiers, so that the fragment shader output is written directly to the
+// framebuffer.
+func isolateFragments(ctx log.Context, ids map[atom.ID]bool) transform.Transformer {
+	ctx = ctx.Enter("IsolateFragments")
+	return transform.Transform("IsolateFragments", func(ctx log.Context, i atom.ID, a atom.Atom, out transform.Writer) {
+		if !ids[i] {
+			out.MutateAndWrite(ctx, i, a)
+			return
+		}
+		t := newTweaker(ctx, out)
+		t.glDisable(GLenum_GL_DEPTH_TEST)
+		t.glDisable(GLenum_GL_STENCIL_TEST)
+		t.glDisable(GLenum_GL_BLEND)
+		out.MutateAndWrite(ctx, i, a)
+		t.revert()
+	})
+}
+
+func (a api) QueryPixelHistory(
+	ctx log.Context,
+	intent replay.Intent,
+	mgr *replay.Manager,
+	after atom.ID,
+	x, y uint32,
+	attachment gfxapi.FramebufferAttachment) (*service.PixelHistory, error) {
+
+	if attachment == gfxapi.FramebufferAttachment_Stencil {
+		return nil, fmt.Errorf("Stencil buffer attachments are not currently supported")
+	}
+
+	ctx = capture.Put(ctx, intent.Capture)
+	c, err := capture.Resolve(ctx)
+	if err != nil {
+		return nil, err
+	}
+	list, err := c.Atoms(ctx)
+	if err != nil {
+		return nil, err
+	}
+	atoms := list.Atoms
+	if int(after) >= len(atoms) {
+		return nil, fmt.Errorf("Command %d is out of range", after)
+	}
+
+	dependencyGraph, err := GetDependencyGraph(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	commands, err := pixelHistoryCommands(ctx, dependencyGraph, c.NewState(), atoms, after, attachment)
+	if err != nil {
+		return nil, err
+	}
+
+	read := func(cfg replay.Config, after atom.ID, width, height uint32, slot gfxapi.FramebufferAttachment) <-chan pixelRes {
+		return a.readPixel(ctx, intent, mgr, cfg, after, width, height, slot, x, y)
+	}
+	return pixelHistory(ctx, commands, read)
+}
+
+// pixelReader is the function used to request the value of the pixel of the
+// attachment slot after the atom after, replayed with the config cfg.
+type pixelReader func(cfg replay.Config, after atom.ID, width, height uint32, slot gfxapi.FramebufferAttachment) <-chan pixelRes
+
+// pixelHistory reads the pixel before and after each of the commands, and the
+// isolated fragment shader output of each of the draw calls, returning the
+// commands that modified or covered the pixel.
+func pixelHistory(ctx log.Context, commands []pixelHistoryCommand, read pixelReader) (*service.PixelHistory, error) {
+	isolated := &pixelHistoryConfig{isolate: map[atom.ID]bool{}}
+	for _, cmd := range commands {
+		if cmd.isDraw {
+			isolated.isolate[cmd.id] = true
+		}
+	}
+
+	// Issue all the framebuffer reads up front so that the requests using the
+	// same config are batched into the same replay.
+	type reads struct {
+		pre, post, preDepth, postDepth, preSource, source <-chan pixelRes
+	}
+	all := make([]reads, len(commands))
+	for i, cmd := range commands {
+		r, att := &all[i], cmd.attachments[cmd.slot]
+		if cmd.id > 0 {
+			r.pre = read(drawConfig{}, cmd.id-1, att.width, att.height, cmd.slot)
+		}
+		r.post = read(drawConfig{}, cmd.id, att.width, att.height, cmd.slot)
+		depth := gfxapi.FramebufferAttachment_Depth
+		if cmd.slot != depth && cmd.attachments[depth].target.objectType != GLenum_GL_NONE {
+			if cmd.id > 0 {
+				r.preDepth = read(drawConfig{}, cmd.id-1, att.width, att.height, depth)
+			}
+			r.postDepth = read(drawConfig{}, cmd.id, att.width, att.height, depth)
+		}
+		if cmd.isDraw {
+			// The earlier draw calls are isolated too, so the output is
+			// compared to the pixel read from the same replay.
+			if cmd.id > 0 {
+				r.preSource = read(isolated, cmd.id-1, att.width, att.height, cmd.slot)
+			}
+			r.source = read(isolated, cmd.id, att.width, att.height, cmd.slot)
+		}
+	}
+
+	out := &service.PixelHistory{}
+	for i, cmd := range commands {
+		r := all[i]
+		pre, err := pixelValue(ctx, r.pre, r.preDepth)
+		if err != nil {
+			return nil, err
+		}
+		post, err := pixelValue(ctx, r.post, r.postDepth)
+		if err != nil {
+			return nil, err
+		}
+		preSource, err := pixelValue(ctx, r.preSource, nil)
+		if err != nil {
+			return nil, err
+		}
+		source, err := pixelValue(ctx, r.source, nil)
+		if err != nil {
+			return nil, err
+		}
+
+		changed := !pixelValuesEqual(pre, post)
+		covered := source != nil && !pixelColorsEqual(preSource, source)
+		if !changed && !covered {
+			continue // The command did not touch the pixel.
+		}
+
+		m := &service.PixelModification{
+			Command:     uint64(cmd.id),
+			Pre:         pre,
+			Post:        post,
+			Source:      source,
+			DepthTest:   service.TestResult_TestDisabled,
+			StencilTest: service.TestResult_TestDisabled,
+		}
+		if cmd.isDraw {
+			m.DepthTest = testResult(cmd.depthTest, changed, cmd.stencilTest)
+			m.StencilTest = testResult(cmd.stencilTest, changed, cmd.depthTest)
+			m.Blend = cmd.attachments[cmd.slot].blend
+		}
+		out.Modifications = append(out.Modifications, m)
+	}
+	return out, nil
+}
+
+// testResult returns the result of a depth or stencil test of a draw call,
+// given whether the test was enabled, whether the pixel was changed and
+// whether the other test was enabled.
+func testResult(enabled, changed, otherEnabled bool) service.TestResult {
+	switch {
+	case !enabled:
+		return service.TestResult_TestDisabled
+	case changed:
+		return service.TestResult_TestPassed
+	case !otherEnabled:
+		return service.TestResult_TestFailed
+	default:
+		// Either of the tests could have discarded the fragment.
+		return service.TestResult_TestUnknown
+	}
+}
+
+var allAttachments = []gfxapi.FramebufferAttachment{
+	gfxapi.FramebufferAttachment_Color0,
+	gfxapi.FramebufferAttachment_Color1,
+	gfxapi.FramebufferAttachment_Color2,
+	gfxapi.FramebufferAttachment_Color3,
+	gfxapi.FramebufferAttachment_Color4,
+	gfxapi.FramebufferAttachment_Color5,
+	gfxapi.FramebufferAttachment_Color6,
+	gfxapi.FramebufferAttachment_Color7,
+	gfxapi.FramebufferAttachment_Depth,
+}
+
+// pixelHistoryCommands returns the draw calls and clears in the frame
+// containing after that the dependency graph reports as writing to the object
+// backing the attachment.
+func pixelHistoryCommands(
+	ctx log.Context,
+	g *DependencyGraph,
+	s *gfxapi.State,
+	atoms []atom.Atom,
+	after atom.ID,
+	attachment gfxapi.FramebufferAttachment) ([]pixelHistoryCommand, error) {
+
+	frameStart := atom.ID(0)
+	for i := after; i > 0; i-- {
+		if atoms[i-1].AtomFlags().IsEndOfFrame() {
+			frameStart = i
+			break
+		}
+	}
+
+	commands := []pixelHistoryCommand{}
+	for i, a := range atoms[:after+1] {
+		id := atom.ID(i)
+		_, isClear := a.(*GlClear)
+		isDraw := a.AtomFlags().IsDrawCall()
+		if c := GetContext(s); c != nil && id >= frameStart && (isDraw || isClear) {
+			commands = append(commands, newPixelHistoryCommand(s, c, id, isDraw))
+		}
+		if err := a.Mutate(ctx, s, nil /* builder */); err != nil {
+			ctx.Warning().Logf("Atom %v %v: %v", id, a, err)
+		}
+	}
+
+	c := GetContext(s)
+	if c == nil {
+		return nil, &service.ErrDataUnavailable{Reason: messages.ErrFramebufferUnavailable()}
+	}
+	target := newPixelHistoryCommand(s, c, after, false).attachments[attachment].target
+	if target == (pixelHistoryTarget{}) {
+		return nil, &service.ErrDataUnavailable{Reason: messages.ErrFramebufferUnavailable()}
+	}
+
+	out := []pixelHistoryCommand{}
+	for _, cmd := range commands {
+		b := g.behaviours[cmd.id]
+		if b.Aborted {
+			continue
+		}
+		writes := false
+		for _, addr := range append(append([]StateAddress{}, b.Write...), b.Modify...) {
+			if t, ok := stateKeyTarget(g.addressMap.key[addr]); ok && t == target {
+				writes = true
+				break
+			}
+		}
+		if !writes {
+			continue
+		}
+		// The target may be held by a different attachment point of the
+		// framebuffer bound for this command.
+		for _, att := range allAttachments {
+			if cmd.attachments[att].target == target {
+				cmd.slot = att
+				out = append(out, cmd)
+				break
+			}
+		}
+	}
+	return out, nil
+}
+
+func newPixelHistoryCommand(s *gfxapi.State, c *Context, id atom.ID, isDraw bool) pixelHistoryCommand {
+	cmd := pixelHistoryCommand{
+		id:          id,
+		isDraw:      isDraw,
+		attachments: map[gfxapi.FramebufferAttachment]pixelHistoryAttachment{},
+		depthTest:   c.FragmentOperations.Depth.Test == GLboolean_GL_TRUE,
+		stencilTest: c.FragmentOperations.Stencil.Test == GLboolean_GL_TRUE,
+	}
+	fb := c.Instances.Framebuffers[c.BoundDrawFramebuffer]
+	if fb == nil {
+		return cmd
+	}
+	for _, att := range allAttachments {
+		var a FramebufferAttachment
+		if att == gfxapi.FramebufferAttachment_Depth {
+			a = fb.DepthAttachment
+		} else {
+			a = fb.ColorAttachments[GLint(att-gfxapi.FramebufferAttachment_Color0)]
+		}
+		if a.ObjectType == GLenum_GL_NONE {
+			continue
+		}
+		info := pixelHistoryAttachment{target: attachmentTarget(c, a)}
+		info.width, info.height, _, _ = GetState(s).getFramebufferAttachmentInfo(att)
+		if blend, ok := c.FragmentOperations.Blend[DrawBufferIndex(att-gfxapi.FramebufferAttachment_Color0)]; ok && att != gfxapi.FramebufferAttachment_Depth {
+			color := c.FragmentOperations.BlendColor
+			info.blend = &service.BlendState{
+				Enabled:       blend.Enabled == GLboolean_GL_TRUE,
+				SrcRgb:        blend.SrcRgb.String(),
+				DstRgb:        blend.DstRgb.String(),
+				SrcAlpha:      blend.SrcAlpha.String(),
+				DstAlpha:      blend.DstAlpha.String(),
+				EquationRgb:   blend.EquationRgb.String(),
+				EquationAlpha: blend.EquationAlpha.String(),
+				Constant: &service.PixelValue{
+					Red:   float32(color.Red),
+					Green: float32(color.Green),
+					Blue:  float32(color.Blue),
+					Alpha: float32(color.Alpha),
+				},
+			}
+		}
+		cmd.attachments[att] = info
+	}
+	return cmd
+}
+
+// attachmentTarget returns the object backing the framebuffer attachment.
+func attachmentTarget(c *Context, att FramebufferAttachment) pixelHistoryTarget {
+	if att.ObjectType == GLenum_GL_TEXTURE {
+		if tex := c.Instances.Textures[TextureId(att.ObjectName)]; tex != nil && tex.EGLImage != GLeglImageOES(memory.Nullptr) {
+			return pixelHistoryTarget{eglImage: tex.EGLImage}
+		}
+	}
+	return pixelHistoryTarget{objectType: att.ObjectType, name: att.ObjectName}
+}
+
+// stateKeyTarget returns the object held by the dependency graph state key.
+func stateKeyTarget(k stateKey) (pixelHistoryTarget, bool) {
+	switch k := k.(type) {
+	case renderbufferDataKey:
+		return pixelHistoryTarget{objectType: GLenum_GL_RENDERBUFFER, name: GLuint(k.id)}, true
+	case renderbufferSubDataKey:
+		return pixelHistoryTarget{objectType: GLenum_GL_RENDERBUFFER, name: GLuint(k.id)}, true
+	case textureDataKey:
+		return pixelHistoryTarget{objectType: GLenum_GL_TEXTURE, name: GLuint(k.id)}, true
+	case eglImageDataKey:
+		return pixelHistoryTarget{eglImage: k.address}, true
+	}
+	return pixelHistoryTarget{}, false
+}
+
+// readPixel requests the framebuffer attachment after the specified atom and
+// returns a chan that receives the value of the pixel (x, y). Depth
+// attachments return a single value, color attachments return RGBA.
+func (a api) readPixel(
+	ctx log.Context,
+	intent replay.Intent,
+	mgr *replay.Manager,
+	cfg replay.Config,
+	after atom.ID,
+	width, height uint32,
+	attachment gfxapi.FramebufferAttachment,
+	x, y uint32) <-chan pixelRes {
+
+	res := make(chan pixelRes, 1)
+	go func() {
+		out := make(chan imgRes, 1)
+		r := framebufferRequest{after: after, width: width, height: height, attachment: attachment, out: out}
+		if err := mgr.Replay(ctx, intent, cfg, r, a); err != nil {
+			res <- pixelRes{err: err}
+			return
+		}
+		select {
+		case i := <-out:
+			if i.err != nil {
+				res <- pixelRes{err: i.err}
+				return
+			}
+			res <- samplePixel(i.img, attachment, x, y)
+		case <-task.ShouldStop(ctx):
+			res <- pixelRes{err: task.StopReason(ctx)}
+		}
+	}()
+	return res
+}
+
+// samplePixel returns the value of the pixel (x, y) of img, where y is
+// measured from the top of the image.
+func samplePixel(img *image.Image2D, attachment gfxapi.FramebufferAttachment, x, y uint32) pixelRes {
+	if x >= img.Width || y >= img.Height {
+		return pixelRes{err: fmt.Errorf("Pixel (%d, %d) is outside the %dx%d framebuffer", x, y, img.Width, img.Height)}
+	}
+	to, channels := image.RGBA_F32, 4
+	if attachment == gfxapi.FramebufferAttachment_Depth {
+		to, channels = depthF32, 1
+	}
+	converted, err := img.Convert(to)
+	if err != nil {
+		return pixelRes{err: err}
+	}
+	// Framebuffer images are stored bottom row first.
+	offset := (int(img.Height-1-y)*int(img.Width) + int(x)) * channels * 4
+	r := endian.Reader(bytes.NewReader(converted.Data[offset:]), device.LittleEndian)
+	value := make([]float32, channels)
+	for i := range value {
+		value[i] = r.Float32()
+	}
+	return pixelRes{value: value, err: r.Error()}
+}
+
+// pixelValue waits for the color and depth reads and returns the combined
+// pixel value. If color is nil then nil is returned.
+func pixelValue(ctx log.Context, color, depth <-chan pixelRes) (*service.PixelValue, error) {
+	if color == nil {
+		return nil, nil
+	}
+	c := <-color
+	if c.err != nil {
+		return nil, c.err
+	}
+	out := &service.PixelValue{}
+	if len(c.value) == 1 {
+		out.Depth, out.HasDepth = c.value[0], true
+	} else {
+		out.Red, out.Green, out.Blue, out.Alpha = c.value[0], c.value[1], c.value[2], c.value[3]
+	}
+	if depth != nil {
+		d := <-depth
+		if d.err != nil {
+			ctx.Warning().Logf("Failed to read depth for pixel history: %v", d.err)
+		} else {
+			out.Depth, out.HasDepth = d.value[0], true
+		}
+	}
+	return out, nil
+}
+
+func pixelValuesEqual(a, b *service.PixelValue) bool {
+	return pixelColorsEqual(a, b) && a.HasDepth == b.HasDepth && a.Depth == b.Depth
+}
+
+// pixelColorsEqual compares the color of the two pixel values and, if both
+// hold one, their depth. The values read from depth attachments only hold a
+// depth.
+func pixelColorsEqual(a, b *service.PixelValue) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	if a.HasDepth && b.HasDepth && a.Depth != b.Depth {
+		return false
+	}
+	return a.Red == b.Red && a.Green == b.Green && a.Blue == b.Blue && a.Alpha == b.Alpha
+}
@@ -0,0 +1,401 @@
+// Copyright (C) 2017 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gles
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/google/gapid/core/assert"
+	"github.com/google/gapid/core/log"
+	"github.com/google/gapid/gapis/atom"
+	"github.com/google/gapid/gapis/capture"
+	"github.com/google/gapid/gapis/database"
+	"github.com/google/gapid/gapis/gfxapi"
+	"github.com/google/gapid/gapis/memory"
+	"github.com/google/gapid/gapis/messages"
+	"github.com/google/gapid/gapis/replay"
+	"github.com/google/gapid/gapis/service"
+)
+
+func TestPixelHistoryCommands(t *testing.T) {
+	ctx := log.Testing(t)
+	ctx = database.Put(ctx, database.NewInMemory(ctx))
+
+	ctxHandle := memory.Pointer{Pool: memory.ApplicationPool, Address: 1}
+	atoms := []atom.Atom{
+		NewEglCreateContext(memory.Nullptr, memory.Nullptr, memory.Nullptr, memory.Nullptr, ctxHandle),
+		atom.WithExtras(
+			NewEglMakeCurrent(memory.Nullptr, memory.Nullptr, memory.Nullptr, ctxHandle, 0),
+			NewStaticContextState(), NewDynamicContextState(64, 64, false)),
+		// Previous frame.
+		NewGlClear(GLbitfield_GL_COLOR_BUFFER_BIT),
+		NewGlDrawArrays(GLenum_GL_TRIANGLES, 0, 0),
+		NewEglSwapBuffers(memory.Nullptr, memory.Nullptr, EGLBoolean(1)),
+		// Frame containing the requested command.
+		NewGlClear(GLbitfield_GL_COLOR_BUFFER_BIT | GLbitfield_GL_DEPTH_BUFFER_BIT), // 5
+		NewGlClear(GLbitfield_GL_DEPTH_BUFFER_BIT),                                  // 6
+		NewGlDrawArrays(GLenum_GL_TRIANGLES, 0, 0),                                  // 7
+		NewGlEnable(GLenum_GL_DEPTH_TEST),                                           // 8
+		NewGlDrawArrays(GLenum_GL_TRIANGLES, 0, 0),                                  // 9
+		NewGlClear(GLbitfield_GL_STENCIL_BUFFER_BIT),                                // 10
+	}
+	p, err := capture.ImportAtomList(ctx, "test", atom.NewList(atoms...))
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	ctx = capture.Put(ctx, p)
+	c, err := capture.Resolve(ctx)
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	g, err := GetDependencyGraph(ctx)
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+
+	type candidate struct {
+		id        atom.ID
+		isDraw    bool
+		slot      gfxapi.FramebufferAttachment
+		depthTest bool
+	}
+	color, depth := gfxapi.FramebufferAttachment_Color0, gfxapi.FramebufferAttachment_Depth
+	for _, test := range []struct {
+		name       string
+		after      atom.ID
+		attachment gfxapi.FramebufferAttachment
+		expected   []candidate
+	}{
+		{"color", 10, color, []candidate{
+			{5, false, color, false},
+			{7, true, color, false},
+			{9, true, color, true},
+		}},
+		{"depth", 10, depth, []candidate{
+			{5, false, depth, false},
+			{6, false, depth, false},
+			{7, true, depth, false},
+			{9, true, depth, true},
+		}},
+		{"after draw", 7, color, []candidate{
+			{5, false, color, false},
+			{7, true, color, false},
+		}},
+		{"first frame", 3, color, []candidate{
+			{2, false, color, false},
+			{3, true, color, false},
+		}},
+	} {
+		ctx := ctx.S("test", test.name)
+		commands, err := pixelHistoryCommands(ctx, g, c.NewState(), atoms, test.after, test.attachment)
+		if !assert.For(ctx, "err").ThatError(err).Succeeded() {
+			continue
+		}
+		got := []candidate{}
+		for _, cmd := range commands {
+			got = append(got, candidate{cmd.id, cmd.isDraw, cmd.slot, cmd.depthTest})
+		}
+		assert.For(ctx, "candidates").ThatSlice(got).Equals(test.expected)
+		for _, cmd := range commands {
+			att := cmd.attachments[cmd.slot]
+			assert.For(ctx, "width").That(att.width).Equals(uint32(64))
+			assert.For(ctx, "height").That(att.height).Equals(uint32(64))
+		}
+	}
+
+	_, err = pixelHistoryCommands(ctx, g, c.NewState(), atoms, 10, gfxapi.FramebufferAttachment_Color1)
+	assert.For(ctx, "missing attachment").ThatError(err).DeepEquals(
+		&service.ErrDataUnavailable{Reason: messages.ErrFramebufferUnavailable()})
+}
+
+// pixelRead identifies a single read issued by pixelHistory.
+type pixelRead struct {
+	cfg   replay.Config
+	after atom.ID
+	slot  gfxapi.FramebufferAttachment
+}
+
+// isolatedReads is the config used in pixelRead for the reads made with the
+// *pixelHistoryConfig of the query.
+type isolatedReads struct{}
+
+// fakePixelReader records the reads it was asked for and returns the values
+// from a table, failing reads that are not in the table.
+type fakePixelReader struct {
+	values   map[pixelRead][]float32
+	reads    map[pixelRead]bool
+	isolated map[*pixelHistoryConfig]bool // The configs of the isolated reads.
+}
+
+func (f *fakePixelReader) read(cfg replay.Config, after atom.ID, width, height uint32, slot gfxapi.FramebufferAttachment) <-chan pixelRes {
+	if c, ok := cfg.(*pixelHistoryConfig); ok {
+		f.isolated[c] = true
+		cfg = isolatedReads{}
+	}
+	r := pixelRead{cfg, after, slot}
+	f.reads[r] = true
+	out := make(chan pixelRes, 1)
+	if v, ok := f.values[r]; ok {
+		out <- pixelRes{value: v}
+	} else {
+		out <- pixelRes{err: fmt.Errorf("Unexpected read %+v", r)}
+	}
+	return out
+}
+
+func TestPixelHistory(t *testing.T) {
+	ctx := log.Testing(t)
+
+	color, depth := gfxapi.FramebufferAttachment_Color0, gfxapi.FramebufferAttachment_Depth
+	blend := &service.BlendState{Enabled: true, SrcRgb: "GL_ONE", DstRgb: "GL_ONE"}
+	attachments := map[gfxapi.FramebufferAttachment]pixelHistoryAttachment{
+		color: {
+			target: pixelHistoryTarget{objectType: GLenum_GL_RENDERBUFFER, name: 1},
+			width:  4, height: 4,
+			blend: blend,
+		},
+		depth: {
+			target: pixelHistoryTarget{objectType: GLenum_GL_RENDERBUFFER, name: 2},
+			width:  4, height: 4,
+		},
+	}
+	draw := func(id atom.ID, depthTest, stencilTest bool) pixelHistoryCommand {
+		return pixelHistoryCommand{id: id, isDraw: true, attachments: attachments, slot: color, depthTest: depthTest, stencilTest: stencilTest}
+	}
+	normal, isolated := drawConfig{}, isolatedReads{}
+	black, red, green := []float32{0, 0, 0, 1}, []float32{1, 0, 0, 1}, []float32{0, 1, 0, 1}
+	near, far := []float32{0.25}, []float32{1}
+
+	commands := []pixelHistoryCommand{
+		// Clear to red. The first command so there is no pre-read.
+		{id: 0, attachments: attachments, slot: color},
+		// Clear that doesn't change the pixel.
+		{id: 1, attachments: attachments, slot: color},
+		// Draw passing the depth test, changing the pixel to green.
+		draw(2, true, false),
+		// Draw failing the depth test, isolated output differs from the pixel.
+		draw(3, true, false),
+		// Draw that doesn't cover the pixel.
+		draw(4, true, false),
+		// Draw with both tests enabled, failing either of the tests.
+		draw(5, true, true),
+		// Draw that only changes the depth.
+		draw(6, false, true),
+	}
+	f := &fakePixelReader{
+		reads:    map[pixelRead]bool{},
+		isolated: map[*pixelHistoryConfig]bool{},
+		values: map[pixelRead][]float32{
+			{normal, 0, color}: red, {normal, 0, depth}: far,
+			{normal, 1, color}: red, {normal, 1, depth}: far, {isolated, 1, color}: red,
+			{normal, 2, color}: green, {normal, 2, depth}: near, {isolated, 2, color}: green,
+			{normal, 3, color}: green, {normal, 3, depth}: near, {isolated, 3, color}: red,
+			{normal, 4, color}: green, {normal, 4, depth}: near, {isolated, 4, color}: red,
+			{normal, 5, color}: green, {normal, 5, depth}: near, {isolated, 5, color}: black,
+			{normal, 6, color}: green, {normal, 6, depth}: far, {isolated, 6, color}: green,
+		},
+	}
+
+	res, err := pixelHistory(ctx, commands, f.read)
+	if !assert.For(ctx, "err").ThatError(err).Succeeded() {
+		return
+	}
+
+	expectedReads := map[pixelRead]bool{}
+	for _, cmd := range commands {
+		if cmd.id > 0 {
+			expectedReads[pixelRead{normal, cmd.id - 1, color}] = true
+			expectedReads[pixelRead{normal, cmd.id - 1, depth}] = true
+		}
+		expectedReads[pixelRead{normal, cmd.id, color}] = true
+		expectedReads[pixelRead{normal, cmd.id, depth}] = true
+		if cmd.isDraw {
+			expectedReads[pixelRead{isolated, cmd.id - 1, color}] = true
+			expectedReads[pixelRead{isolated, cmd.id, color}] = true
+		}
+	}
+	assert.For(ctx, "reads").That(f.reads).DeepEquals(expectedReads)
+
+	// All the draw calls are isolated in the same replay.
+	if assert.For(ctx, "isolated configs").That(len(f.isolated)).Equals(1) {
+		for cfg := range f.isolated {
+			assert.For(ctx, "isolated draws").That(cfg.isolate).DeepEquals(
+				map[atom.ID]bool{2: true, 3: true, 4: true, 5: true, 6: true})
+		}
+	}
+
+	value := func(c, d []float32) *service.PixelValue {
+		return &service.PixelValue{Red: c[0], Green: c[1], Blue: c[2], Alpha: c[3], Depth: d[0], HasDepth: true}
+	}
+	source := func(c []float32) *service.PixelValue {
+		return &service.PixelValue{Red: c[0], Green: c[1], Blue: c[2], Alpha: c[3]}
+	}
+	disabled, passed := service.TestResult_TestDisabled, service.TestResult_TestPassed
+	failed, unknown := service.TestResult_TestFailed, service.TestResult_TestUnknown
+	expected := &service.PixelHistory{Modifications: []*service.PixelModification{
+		{
+			Command:     0,
+			Post:        value(red, far),
+			DepthTest:   disabled,
+			StencilTest: disabled,
+		}, {
+			Command:     2,
+			Pre:         value(red, far),
+			Post:        value(green, near),
+			Source:      source(green),
+			DepthTest:   passed,
+			StencilTest: disabled,
+			Blend:       blend,
+		}, {
+			Command:     3,
+			Pre:         value(green, near),
+			Post:        value(green, near),
+			Source:      source(red),
+			DepthTest:   failed,
+			StencilTest: disabled,
+			Blend:       blend,
+		}, {
+			Command:     5,
+			Pre:         value(green, near),
+			Post:        value(green, near),
+			Source:      source(black),
+			DepthTest:   unknown,
+			StencilTest: unknown,
+			Blend:       blend,
+		}, {
+			Command:     6,
+			Pre:         value(green, near),
+			Post:        value(green, far),
+			Source:      source(green),
+			DepthTest:   disabled,
+			StencilTest: passed,
+			Blend:       blend,
+		},
+	}}
+	assert.For(ctx, "history").That(res).DeepEquals(expected)
+}
+
+func TestPixelHistoryReadError(t *testing.T) {
+	ctx := log.Testing(t)
+
+	attachments := map[gfxapi.FramebufferAttachment]pixelHistoryAttachment{
+		gfxapi.FramebufferAttachment_Color0: {
+			target: pixelHistoryTarget{objectType: GLenum_GL_RENDERBUFFER, name: 1},
+			width:  4, height: 4,
+		},
+	}
+	commands := []pixelHistoryCommand{
+		{id: 3, isDraw: true, attachments: attachments, slot: gfxapi.FramebufferAttachment_Color0},
+	}
+	// Only the pre and post reads succeed, the isolated read fails.
+	f := &fakePixelReader{
+		reads:    map[pixelRead]bool{},
+		isolated: map[*pixelHistoryConfig]bool{},
+		values: map[pixelRead][]float32{
+			{drawConfig{}, 2, gfxapi.FramebufferAttachment_Color0}: {0, 0, 0, 1},
+			{drawConfig{}, 3, gfxapi.FramebufferAttachment_Color0}: {0, 0, 0, 1},
+		},
+	}
+	_, err := pixelHistory(ctx, commands, f.read)
+	assert.For(ctx, "err").ThatError(err).Failed()
+}
+
+func TestPixelHistoryTestResult(t *testing.T) {
+	ctx := log.Testing(t)
+	for _, test := range []struct {
+		enabled, changed, otherEnabled bool
+		expected                       service.TestResult
+	}{
+		{false, false, false, service.TestResult_TestDisabled},
+		{false, true, true, service.TestResult_TestDisabled},
+		{true, true, false, service.TestResult_TestPassed},
+		{true, true, true, service.TestResult_TestPassed},
+		{true, false, false, service.TestResult_TestFailed},
+		{true, false, true, service.TestResult_TestUnknown},
+	} {
+		got := testResult(test.enabled, test.changed, test.otherEnabled)
+		assert.For(ctx, "testResult(%v, %v, %v)", test.enabled, test.changed, test.otherEnabled).
+			That(got).Equals(test.expected)
+	}
+}
+
+func TestPixelHistoryDepth(t *testing.T) {
+	ctx := log.Testing(t)
+
+	depth := gfxapi.FramebufferAttachment_Depth
+	attachments := map[gfxapi.FramebufferAttachment]pixelHistoryAttachment{
+		depth: {
+			target: pixelHistoryTarget{objectType: GLenum_GL_RENDERBUFFER, name: 2},
+			width:  4, height: 4,
+		},
+	}
+	commands := []pixelHistoryCommand{
+		// Draw failing the depth test, whose isolated output is nearer.
+		{id: 3, isDraw: true, attachments: attachments, slot: depth, depthTest: true},
+		// Draw that doesn't cover the pixel.
+		{id: 5, isDraw: true, attachments: attachments, slot: depth, depthTest: true},
+	}
+	normal, isolated := drawConfig{}, isolatedReads{}
+	near, far := []float32{0.25}, []float32{1}
+	f := &fakePixelReader{
+		reads:    map[pixelRead]bool{},
+		isolated: map[*pixelHistoryConfig]bool{},
+		values: map[pixelRead][]float32{
+			{normal, 2, depth}: near, {normal, 3, depth}: near, {isolated, 2, depth}: far, {isolated, 3, depth}: near,
+			{normal, 4, depth}: near, {normal, 5, depth}: near, {isolated, 4, depth}: far, {isolated, 5, depth}: far,
+		},
+	}
+	res, err := pixelHistory(ctx, commands, f.read)
+	if !assert.For(ctx, "err").ThatError(err).Succeeded() {
+		return
+	}
+	value := func(d []float32) *service.PixelValue {
+		return &service.PixelValue{Depth: d[0], HasDepth: true}
+	}
+	assert.For(ctx, "history").That(res).DeepEquals(&service.PixelHistory{
+		Modifications: []*service.PixelModification{{
+			Command:     3,
+			Pre:         value(near),
+			Post:        value(near),
+			Source:      value(near),
+			DepthTest:   service.TestResult_TestFailed,
+			StencilTest: service.TestResult_TestDisabled,
+		}},
+	})
+}
+
+func TestPixelColorsEqual(t *testing.T) {
+	ctx := log.Testing(t)
+	red := &service.PixelValue{Red: 1, Alpha: 1}
+	redNear := &service.PixelValue{Red: 1, Alpha: 1, Depth: 0.25, HasDepth: true}
+	redFar := &service.PixelValue{Red: 1, Alpha: 1, Depth: 1, HasDepth: true}
+	green := &service.PixelValue{Green: 1, Alpha: 1}
+	for _, test := range []struct {
+		name     string
+		a, b     *service.PixelValue
+		expected bool
+	}{
+		{"nil", nil, nil, true},
+		{"one nil", red, nil, false},
+		{"same color", red, red, true},
+		{"different color", red, green, false},
+		{"one depth", red, redNear, true},
+		{"same depth", redNear, redNear, true},
+		{"different depth", redNear, redFar, false},
+	} {
+		assert.For(ctx, test.name).That(pixelColorsEqual(test.a, test.b)).Equals(test.expected)
+	}
+}
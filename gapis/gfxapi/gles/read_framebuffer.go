@@ -52,26 +52,34 @@ func (t *readFramebuffer) Transform(ctx log.Context, id atom.ID, a atom.Atom, ou
 
 func (t *readFramebuffer) Flush(ctx log.Context, out transform.Writer) {}
 
-func (t *readFramebuffer) Depth(id atom.ID, res chan<- imgRes) {
+func (t *readFramebuffer) Depth(id atom.ID, layer *gfxapi.FramebufferLayer, res chan<- imgRes) {
 	t.injections[id] = append(t.injections[id], func(ctx log.Context, out transform.Writer) {
 		s := out.State()
-		width, height, format, err := GetState(s).getFramebufferAttachmentInfo(gfxapi.FramebufferAttachment_Depth)
+		attachment := gfxapi.FramebufferAttachment_Depth
+		width, height, format, err := GetState(s).getFramebufferAttachmentLayerInfo(attachment, layer)
 		if err != nil {
 			res <- imgRes{err: &service.ErrDataUnavailable{Reason: messages.ErrFramebufferUnavailable()}}
 			return
 		}
 
+		t := newTweaker(ctx, out)
+		if layer != nil {
+			bindFramebufferLayer(ctx, t, out, attachment, GLenum_GL_DEPTH_ATTACHMENT, layer)
+		}
+
 		postColorData(ctx, s, int32(width), int32(height), format, out, func(i imgRes) { res <- i })
+
+		t.revert()
 	})
 }
 
-func (t *readFramebuffer) Color(id atom.ID, width, height, bufferIdx uint32, res chan<- imgRes) {
+func (t *readFramebuffer) Color(id atom.ID, width, height, bufferIdx uint32, layer *gfxapi.FramebufferLayer, res chan<- imgRes) {
 	t.injections[id] = append(t.injections[id], func(ctx log.Context, out transform.Writer) {
 		s := out.State()
 		c := GetContext(s)
 
-		attachment := gfxapi.FramebufferAttachment_Color0 + gfxapi.FramebufferAttachment(bufferIdx)
-		w, h, fmt, err := GetState(s).getFramebufferAttachmentInfo(attachment)
+		attachment := gfxapi.ColorAttachment(int(bufferIdx))
+		w, h, fmt, err := GetState(s).getFramebufferAttachmentLayerInfo(attachment, layer)
 		if err != nil {
 			res <- imgRes{err: &service.ErrDataUnavailable{Reason: messages.ErrFramebufferUnavailable()}}
 			return
@@ -91,7 +99,10 @@ func (t *readFramebuffer) Color(id atom.ID, width, height, bufferIdx uint32, res
 		// TODO: These glReadBuffer calls need to be changed for on-device
 		//       replay. Note that glReadBuffer was only introduced in
 		//       OpenGL ES 3.0, and that GL_FRONT is not a legal enum value.
-		if c.BoundDrawFramebuffer == 0 {
+		if layer != nil {
+			bindFramebufferLayer(ctx, t, out, attachment, GLenum_GL_COLOR_ATTACHMENT0, layer)
+			t.glReadBuffer(GLenum_GL_COLOR_ATTACHMENT0)
+		} else if c.BoundDrawFramebuffer == 0 {
 			out.MutateAndWrite(ctx, atom.NoID, replay.Custom(func(ctx log.Context, s *gfxapi.State, b *builder.Builder) error {
 				// TODO: We assume here that the default framebuffer is
 				//       single-buffered. Once we support double-buffering we
@@ -126,6 +137,37 @@ func (t *readFramebuffer) Color(id atom.ID, width, height, bufferIdx uint32, res
 	})
 }
 
+// bindFramebufferLayer binds a new read framebuffer with the layer and level of
+// the image bound to att attached to attachment. The layer and level must have
+// been validated by getFramebufferAttachmentLayerInfo. The bindings are
+// reverted by t.
+func bindFramebufferLayer(ctx log.Context, t *tweaker, out transform.Writer, att gfxapi.FramebufferAttachment, attachment GLenum, layer *gfxapi.FramebufferLayer) {
+	c, a, _ := GetState(out.State()).getFramebufferAttachment(att)
+
+	framebufferID := t.glGenFramebuffer()
+	t.glBindFramebuffer_Read(framebufferID)
+
+	if a.ObjectType == GLenum_GL_RENDERBUFFER {
+		out.MutateAndWrite(ctx, atom.NoID, NewGlFramebufferRenderbuffer(
+			GLenum_GL_READ_FRAMEBUFFER, attachment, GLenum_GL_RENDERBUFFER, RenderbufferId(a.ObjectName)))
+		return
+	}
+
+	id, level := TextureId(a.ObjectName), GLint(layer.Level)
+	switch c.Instances.Textures[id].Kind {
+	case GLenum_GL_TEXTURE_2D:
+		out.MutateAndWrite(ctx, atom.NoID, NewGlFramebufferTexture2D(
+			GLenum_GL_READ_FRAMEBUFFER, attachment, GLenum_GL_TEXTURE_2D, id, level))
+	case GLenum_GL_TEXTURE_CUBE_MAP:
+		face := GLenum_GL_TEXTURE_CUBE_MAP_POSITIVE_X + GLenum(layer.Layer)
+		out.MutateAndWrite(ctx, atom.NoID, NewGlFramebufferTexture2D(
+			GLenum_GL_READ_FRAMEBUFFER, attachment, face, id, level))
+	default:
+		out.MutateAndWrite(ctx, atom.NoID, NewGlFramebufferTextureLayer(
+			GLenum_GL_READ_FRAMEBUFFER, attachment, id, level, GLint(layer.Layer)))
+	}
+}
+
 func postColorData(ctx log.Context,
 	s *gfxapi.State,
 	width, height int32,
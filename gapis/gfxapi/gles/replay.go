@@ -36,6 +36,8 @@ var (
 	// Interface compliance tests
 	_ = replay.QueryIssues(api{})
 	_ = replay.QueryFramebufferAttachment(api{})
+	_ = replay.QueryPixelHistory(api{})
+	_ = replay.QueryShaderTrace(api{})
 	_ = replay.Support(api{})
 )
 
@@ -47,6 +49,8 @@ type issuesConfig struct{}
 type drawConfig struct {
 	wireframeMode      replay.WireframeMode
 	wireframeOverlayID atom.ID // used when wireframeMode == WireframeMode_Overlay
+	drawMode           replay.DrawMode
+	drawModeID         atom.ID // used when drawMode != DrawMode_Normal
 }
 
 // uniqueConfig returns a replay.Config that is guaranteed to be unique.
@@ -66,6 +70,7 @@ type framebufferRequest struct {
 	after            atom.ID
 	width, height    uint32
 	attachment       gfxapi.FramebufferAttachment
+	layer            *gfxapi.FramebufferLayer
 	out              chan imgRes
 	wireframeOverlay bool
 }
@@ -118,6 +123,10 @@ func (a api) Replay(
 	readFramebuffer := newReadFramebuffer(ctx)
 
 	optimize := true
+	isolated := false
+	// The outputs of the requests using an alternate draw mode. These are all
+	// for the atom drawConfig.drawModeID.
+	drawModeRequests := []chan<- imgRes{}
 
 	for _, req := range requests {
 		switch req := req.(type) {
@@ -136,28 +145,54 @@ func (a api) Replay(
 
 			switch req.attachment {
 			case gfxapi.FramebufferAttachment_Depth:
-				readFramebuffer.Depth(req.after, req.out)
+				readFramebuffer.Depth(req.after, req.layer, req.out)
 			case gfxapi.FramebufferAttachment_Stencil:
 				return fmt.Errorf("Stencil buffer attachments are not currently supported")
 			default:
-				idx := uint32(req.attachment - gfxapi.FramebufferAttachment_Color0)
-				readFramebuffer.Color(req.after, req.width, req.height, idx, req.out)
+				idx := uint32(req.attachment.ColorIndex())
+				readFramebuffer.Color(req.after, req.width, req.height, idx, req.layer, req.out)
 			}
 
-			cfg := cfg.(drawConfig)
-			switch cfg.wireframeMode {
-			case replay.WireframeMode_All:
-				// TODO: Add only once
-				transforms.Add(wireframe(ctx))
-			case replay.WireframeMode_Overlay:
-				transforms.Add(wireframeOverlay(ctx, req.after))
+			switch cfg := cfg.(type) {
+			case drawConfig:
+				switch cfg.wireframeMode {
+				case replay.WireframeMode_All:
+					// TODO: Add only once
+					transforms.Add(wireframe(ctx))
+				case replay.WireframeMode_Overlay:
+					transforms.Add(wireframeOverlay(ctx, req.after))
+				}
+				if cfg.drawMode != replay.DrawMode_Normal {
+					drawModeRequests = append(drawModeRequests, req.out)
+				}
+			case *pixelHistoryConfig:
+				if !isolated {
+					transforms.Add(isolateFragments(ctx, cfg.isolate))
+					isolated = true
+				}
 			}
+
+		case shaderTraceRequest:
+			deadCodeElimination.Request(req.after)
+			transforms.Add(newShaderTrace(ctx, req))
+		}
+	}
+
+	if cfg, ok := cfg.(drawConfig); ok && len(drawModeRequests) > 0 {
+		switch cfg.drawMode {
+		case replay.DrawMode_Overdraw:
+			transforms.Add(overdraw(ctx, cfg.drawModeID, false, drawModeRequests))
+		case replay.DrawMode_DepthComplexity:
+			transforms.Add(overdraw(ctx, cfg.drawModeID, true, drawModeRequests))
+		case replay.DrawMode_Highlight:
+			transforms.Add(highlight(ctx, cfg.drawModeID))
 		}
 	}
 
 	if optimize && !config.DisableDeadCodeElimination {
 		atoms = atom.NewList() // DeadAtomRemoval generates atoms.
-		transforms.Add(deadCodeElimination)
+		// The atoms are generated for the transforms added for the requests.
+		transforms = append(transform.Transforms{deadCodeElimination}, transforms...)
 	}
 
 	if issues != nil {
@@ -203,8 +238,7 @@ func (a api) Replay(
 		transforms = newTransforms
 	}
 
-	transforms.Transform(ctx, *atoms, out)
-	return nil
+	return transforms.Transform(ctx, *atoms, out)
 }
 
 func (a api) QueryIssues(
@@ -228,14 +262,21 @@ func (a api) QueryFramebufferAttachment(
 	after atom.ID,
 	width, height uint32,
 	attachment gfxapi.FramebufferAttachment,
-	wireframeMode replay.WireframeMode) (*image.Image2D, error) {
+	layer *gfxapi.FramebufferLayer,
+	wireframeMode replay.WireframeMode,
+	drawMode replay.DrawMode) (*image.Image2D, error) {
 
-	c := drawConfig{wireframeMode: wireframeMode}
+	c := drawConfig{wireframeMode: wireframeMode, drawMode: drawMode}
 	if wireframeMode == replay.WireframeMode_Overlay {
 		c.wireframeOverlayID = after
 	}
+	if drawMode != replay.DrawMode_Normal {
+		// Fragment counts are accumulated per request, so these requests
+		// cannot be batched with requests for other atoms.
+		c.drawModeID = after
+	}
 	out := make(chan imgRes, 1)
-	r := framebufferRequest{after: after, width: width, height: height, attachment: attachment, out: out}
+	r := framebufferRequest{after: after, width: width, height: height, attachment: attachment, layer: layer, out: out}
 	if err := mgr.Replay(ctx, intent, c, r, a); err != nil {
 		return nil, err
 	}
@@ -49,6 +49,12 @@ func (t *Texture) ResourceType() gfxapi.ResourceType {
 		return gfxapi.ResourceType_Texture2DResource
 	case GLenum_GL_TEXTURE_CUBE_MAP:
 		return gfxapi.ResourceType_CubemapResource
+	case GLenum_GL_TEXTURE_3D:
+		return gfxapi.ResourceType_Texture3DResource
+	case GLenum_GL_TEXTURE_2D_ARRAY:
+		return gfxapi.ResourceType_Texture2DArrayResource
+	case GLenum_GL_TEXTURE_CUBE_MAP_ARRAY:
+		return gfxapi.ResourceType_CubemapArrayResource
 	default:
 		return gfxapi.ResourceType_UnknownResource
 	}
@@ -61,12 +67,7 @@ func (t *Texture) ResourceData(ctx log.Context, s *gfxapi.State, resources gfxap
 	case GLenum_GL_TEXTURE_2D:
 		levels := make([]*image.Info2D, len(t.Texture2D))
 		for i, level := range t.Texture2D {
-			levels[i] = &image.Info2D{
-				Format: newImgfmt(level.TexelFormat, level.TexelType).asImageOrPanic(),
-				Width:  uint32(level.Width),
-				Height: uint32(level.Height),
-				Data:   image.NewID(level.Data.ResourceID(ctx, s)),
-			}
+			levels[i] = level.imageInfo(ctx, s)
 		}
 		return &gfxapi.Texture2D{Levels: levels}, nil
 
@@ -75,35 +76,85 @@ func (t *Texture) ResourceData(ctx log.Context, s *gfxapi.State, resources gfxap
 		for i, level := range t.Cubemap {
 			levels[i] = &gfxapi.CubemapLevel{}
 			for j, face := range level.Faces {
-				img := &image.Info2D{
-					Format: newImgfmt(face.TexelFormat, face.TexelType).asImageOrPanic(),
-					Width:  uint32(face.Width),
-					Height: uint32(face.Height),
-					Data:   image.NewID(face.Data.ResourceID(ctx, s)),
-				}
-				switch j {
-				case GLenum_GL_TEXTURE_CUBE_MAP_NEGATIVE_X:
-					levels[i].NegativeX = img
-				case GLenum_GL_TEXTURE_CUBE_MAP_POSITIVE_X:
-					levels[i].PositiveX = img
-				case GLenum_GL_TEXTURE_CUBE_MAP_NEGATIVE_Y:
-					levels[i].NegativeY = img
-				case GLenum_GL_TEXTURE_CUBE_MAP_POSITIVE_Y:
-					levels[i].PositiveY = img
-				case GLenum_GL_TEXTURE_CUBE_MAP_NEGATIVE_Z:
-					levels[i].NegativeZ = img
-				case GLenum_GL_TEXTURE_CUBE_MAP_POSITIVE_Z:
-					levels[i].PositiveZ = img
-				}
+				setCubemapFace(levels[i], j, face.imageInfo(ctx, s))
 			}
 		}
 		return &gfxapi.Cubemap{Levels: levels}, nil
 
+	case GLenum_GL_TEXTURE_3D:
+		levels := make([]*gfxapi.Texture3DLevel, len(t.Layered))
+		for i, level := range t.Layered {
+			levels[i] = &gfxapi.Texture3DLevel{Slices: layeredImages(ctx, s, level)}
+		}
+		return &gfxapi.Texture3D{Levels: levels}, nil
+
+	case GLenum_GL_TEXTURE_2D_ARRAY:
+		levels := make([]*gfxapi.Texture2DArrayLevel, len(t.Layered))
+		for i, level := range t.Layered {
+			levels[i] = &gfxapi.Texture2DArrayLevel{Layers: layeredImages(ctx, s, level)}
+		}
+		return &gfxapi.Texture2DArray{Levels: levels}, nil
+
+	case GLenum_GL_TEXTURE_CUBE_MAP_ARRAY:
+		levels := make([]*gfxapi.CubemapArrayLevel, len(t.Layered))
+		for i, level := range t.Layered {
+			// Each layer of a cube-map array is stored as six consecutive
+			// layer-faces, in the order +X, -X, +Y, -Y, +Z, -Z.
+			images := layeredImages(ctx, s, level)
+			layers := make([]*gfxapi.CubemapLevel, len(images)/6)
+			for j := range layers {
+				layers[j] = &gfxapi.CubemapLevel{}
+				for k, img := range images[j*6 : j*6+6] {
+					setCubemapFace(layers[j], GLenum_GL_TEXTURE_CUBE_MAP_POSITIVE_X+GLenum(k), img)
+				}
+			}
+			levels[i] = &gfxapi.CubemapArrayLevel{Layers: layers}
+		}
+		return &gfxapi.CubemapArray{Levels: levels}, nil
+
 	default:
 		return nil, &service.ErrDataUnavailable{Reason: messages.ErrNoTextureData(t.ResourceName())}
 	}
 }
 
+// imageInfo returns the image.Info2D describing the image level or layer.
+func (i Image) imageInfo(ctx log.Context, s *gfxapi.State) *image.Info2D {
+	return &image.Info2D{
+		Format: newImgfmt(i.TexelFormat, i.TexelType).asImageOrPanic(),
+		Width:  uint32(i.Width),
+		Height: uint32(i.Height),
+		Data:   image.NewID(i.Data.ResourceID(ctx, s)),
+	}
+}
+
+// layeredImages returns the image.Info2D for each layer of level, ordered by
+// layer index.
+func layeredImages(ctx log.Context, s *gfxapi.State, level LayeredLevel) []*image.Info2D {
+	out := make([]*image.Info2D, len(level.Layers))
+	for i, layer := range level.Layers {
+		out[i] = layer.imageInfo(ctx, s)
+	}
+	return out
+}
+
+// setCubemapFace assigns img to the face of the cube-map level l.
+func setCubemapFace(l *gfxapi.CubemapLevel, face GLenum, img *image.Info2D) {
+	switch face {
+	case GLenum_GL_TEXTURE_CUBE_MAP_NEGATIVE_X:
+		l.NegativeX = img
+	case GLenum_GL_TEXTURE_CUBE_MAP_POSITIVE_X:
+		l.PositiveX = img
+	case GLenum_GL_TEXTURE_CUBE_MAP_NEGATIVE_Y:
+		l.NegativeY = img
+	case GLenum_GL_TEXTURE_CUBE_MAP_POSITIVE_Y:
+		l.PositiveY = img
+	case GLenum_GL_TEXTURE_CUBE_MAP_NEGATIVE_Z:
+		l.NegativeZ = img
+	case GLenum_GL_TEXTURE_CUBE_MAP_POSITIVE_Z:
+		l.PositiveZ = img
+	}
+}
+
 func (t *Texture) SetResourceData(ctx log.Context, at *path.Command,
 	data interface{}, resources gfxapi.ResourceMap, edits gfxapi.ReplaceCallback) error {
 	return fmt.Errorf("SetResourceData is not supported for Texture")
@@ -251,6 +302,11 @@ func (p *Program) ResourceData(ctx log.Context, s *gfxapi.State, resources gfxap
 		})
 	}
 
+	return &gfxapi.Program{Shaders: shaders, Uniforms: p.uniforms(ctx, s)}, nil
+}
+
+// uniforms returns the active uniforms of the program with their current values.
+func (p *Program) uniforms(ctx log.Context, s *gfxapi.State) []*gfxapi.Uniform {
 	uniforms := []*gfxapi.Uniform{}
 	for _, activeUniform := range p.ActiveUniforms {
 		uniform := p.Uniforms[activeUniform.Location]
@@ -393,7 +449,7 @@ func (p *Program) ResourceData(ctx log.Context, s *gfxapi.State, resources gfxap
 		})
 	}
 
-	return &gfxapi.Program{Shaders: shaders, Uniforms: uniforms}, nil
+	return uniforms
 }
 
 func uniformValue(ctx log.Context, s *gfxapi.State, kind gfxapi.UniformType, data U8ˢ) interface{} {
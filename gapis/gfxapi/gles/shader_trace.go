@@ -0,0 +1,446 @@
+// Copyright (C) 2017 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gles
+
+import (
+	"fmt"
+
+	"github.com/google/gapid/core/data/pod"
+	"github.com/google/gapid/core/event/task"
+	"github.com/google/gapid/core/log"
+	"github.com/google/gapid/gapis/atom"
+	"github.com/google/gapid/gapis/atom/transform"
+	"github.com/google/gapid/gapis/gfxapi"
+	"github.com/google/gapid/gapis/memory"
+	"github.com/google/gapid/gapis/replay"
+	"github.com/google/gapid/gapis/replay/builder"
+	"github.com/google/gapid/gapis/replay/value"
+	"github.com/google/gapid/gapis/service"
+	"github.com/google/gapid/gapis/shadertools"
+)
+
+// shaderTraceMaxSteps is the maximum number of values captured for a single
+// fragment. Each step requires the draw call to be replayed once.
+const shaderTraceMaxSteps = 256
+
+// shaderTraceFirstSteps is the number of steps captured by the first replay of
+// a shader trace. Each following replay doubles the number of steps captured,
+// until the trace is complete or shaderTraceMaxSteps is reached.
+const shaderTraceFirstSteps = 16
+
+// shaderTraceRequest requests the values written by the fragment shader of
+// the draw call after for the pixel (x, y), where y is measured from the top
+// of the framebuffer. The values of the count steps starting at step first
+// are captured.
+type shaderTraceRequest struct {
+	after        atom.ID
+	x, y         uint32
+	first, count uint32
+	out          chan shaderTraceRes
+}
+
+// shaderTraceRes holds the result of a shader trace request.
+type shaderTraceRes struct {
+	values [][4]uint32
+	info   *shadertools.DebugInfo
+	err    error
+}
+
+func (a api) QueryShaderTrace(
+	ctx log.Context,
+	intent replay.Intent,
+	mgr *replay.Manager,
+	after atom.ID,
+	x, y uint32,
+	variables []string) (*service.ShaderTrace, error) {
+
+	values := [][4]uint32{}
+	for count := shaderTraceFirstSteps; ; count *= 2 {
+		if count > shaderTraceMaxSteps {
+			count = shaderTraceMaxSteps
+		}
+		out := make(chan shaderTraceRes, 1)
+		r := shaderTraceRequest{
+			after: after,
+			x:     x,
+			y:     y,
+			first: uint32(len(values) + 1),
+			count: uint32(count - len(values)),
+			out:   out,
+		}
+		if err := mgr.Replay(ctx, intent, uniqueConfig(), r, a); err != nil {
+			return nil, err
+		}
+		var res shaderTraceRes
+		select {
+		case res = <-out:
+		case <-task.ShouldStop(ctx):
+			return nil, task.StopReason(ctx)
+		}
+		if res.err != nil {
+			return nil, res.err
+		}
+		values = append(values, res.values...)
+		steps, complete := res.info.Trace(values)
+		if complete || count == shaderTraceMaxSteps {
+			return shaderTraceSteps(steps, variables), nil
+		}
+	}
+}
+
+// shaderTrace is an atom transform that replaces the fragment shader of the
+// draw call req.after with a debuggable version, and then replays the draw
+// call once for each of the requested steps for the requested pixel.
+//
+// The debuggable shader only writes a single value, selected by a uniform, to
+// a GL_RGBA32UI render target. The values are read back and posted to req.out
+// along with the DebugInfo used to map them to the variables of the shader.
+//
+// The shaders are translated to desktop GLSL, so the replay device must be a
+// desktop device. If the fragment is discarded, or not rasterized, the trace
+// is empty. The values written by functions called from a block are not
+// distinguished from the values written by the block itself.
+type shaderTrace struct {
+	req  shaderTraceRequest
+	seen bool
+}
+
+func newShaderTrace(ctx log.Context, req shaderTraceRequest) *shaderTrace {
+	return &shaderTrace{req: req}
+}
+
+func (t *shaderTrace) Transform(ctx log.Context, i atom.ID, a atom.Atom, out transform.Writer) {
+	out.MutateAndWrite(ctx, i, a)
+	if i != t.req.after {
+		return
+	}
+	t.seen = true
+	dc, ok := a.(drawCall)
+	if !ok {
+		t.req.out <- shaderTraceRes{err: fmt.Errorf("Command %v is not a draw call", i)}
+		return
+	}
+	if err := traceDrawCall(ctx, dc, t.req, out); err != nil {
+		t.req.out <- shaderTraceRes{err: err}
+	}
+}
+
+func (t *shaderTrace) Flush(ctx log.Context, out transform.Writer) {
+	if !t.seen {
+		t.req.out <- shaderTraceRes{err: fmt.Errorf("Command %v was not replayed", t.req.after)}
+	}
+}
+
+// traceDrawCall replays the draw call dc with a debuggable fragment shader
+// and posts the captured values to req.out. If reading any of the values
+// fails then only the first error is posted.
+func traceDrawCall(ctx log.Context, dc drawCall, req shaderTraceRequest, out transform.Writer) error {
+	s := out.State()
+	c := GetContext(s)
+	if c == nil {
+		return fmt.Errorf("No context bound")
+	}
+	prog, ok := c.Instances.Programs[c.BoundProgram]
+	if !ok {
+		return fmt.Errorf("No program bound")
+	}
+	vs := c.Instances.Shaders[prog.Shaders[GLenum_GL_VERTEX_SHADER]]
+	fs := c.Instances.Shaders[prog.Shaders[GLenum_GL_FRAGMENT_SHADER]]
+	if vs == nil || fs == nil {
+		return fmt.Errorf("The bound program has no vertex or fragment shader")
+	}
+
+	vsRes := shadertools.ConvertGlsl(vs.Source, &shadertools.Option{IsVertexShader: true})
+	if !vsRes.Ok {
+		return fmt.Errorf("Failed to translate the vertex shader: %v", vsRes.Message)
+	}
+	fsRes := shadertools.ConvertGlsl(fs.Source, &shadertools.Option{IsFragmentShader: true, MakeDebuggable: true})
+	if !fsRes.Ok {
+		return fmt.Errorf("Failed to make the fragment shader debuggable: %v", fsRes.Message)
+	}
+	fsSource, err := shadertools.SelectDebugStepWithUniform(fsRes.SourceCode)
+	if err != nil {
+		return err
+	}
+	info := shadertools.NewDebugInfo(fsRes.Info)
+
+	t := newTweaker(ctx, out)
+	defer t.revert()
+
+	// The pixel is read from the framebuffer bound for the draw call.
+	t.glBindFramebuffer_Read(c.BoundDrawFramebuffer)
+	width, height, _, err := GetState(s).getFramebufferAttachmentInfo(gfxapi.FramebufferAttachment_Color0)
+	if err != nil {
+		return err
+	}
+	if req.x >= width || req.y >= height {
+		return fmt.Errorf("Pixel (%d, %d) is outside the %dx%d framebuffer", req.x, req.y, width, height)
+	}
+	x, y := GLint(req.x), GLint(height-1-req.y) // GL has a bottom-left origin.
+
+	stepLocation := bindDebugProgram(ctx, t, prog, vsRes.SourceCode, fsSource, out)
+
+	framebufferID := t.glGenFramebuffer()
+	t.glBindFramebuffer_Draw(framebufferID)
+	t.glBindFramebuffer_Read(framebufferID)
+	renderbufferID := t.glGenRenderbuffer()
+	t.glBindRenderbuffer(renderbufferID)
+	mutateAndWriteEach(ctx, out,
+		NewGlRenderbufferStorage(GLenum_GL_RENDERBUFFER, GLenum_GL_RGBA32UI, GLsizei(width), GLsizei(height)),
+		NewGlFramebufferRenderbuffer(GLenum_GL_DRAW_FRAMEBUFFER, GLenum_GL_COLOR_ATTACHMENT0, GLenum_GL_RENDERBUFFER, renderbufferID),
+	)
+
+	t.glDisable(GLenum_GL_BLEND)
+	t.glDisable(GLenum_GL_DEPTH_TEST)
+	t.glDisable(GLenum_GL_STENCIL_TEST)
+	t.glEnable(GLenum_GL_SCISSOR_TEST)
+	t.glScissor(x, y, 1, 1)
+	t.setPixelStorage(PixelStorageState{PackAlignment: 1, UnpackAlignment: 1}, 0, 0)
+
+	// The clear value is never a valid block label, so it terminates the trace.
+	clearValue := t.AllocData([]uint32{0xFFFFFFFF, 0xFFFFFFFF, 0xFFFFFFFF, 0xFFFFFFFF})
+	const pixelSize = 16
+	tmp := atom.Must(atom.Alloc(ctx, s, pixelSize))
+	defer tmp.Free()
+
+	values := make([][4]uint32, req.count)
+	failed := false
+	for i := range values {
+		i := i
+		step := req.first + uint32(i)
+		out.MutateAndWrite(ctx, atom.NoID, NewGlUniform1ui(stepLocation, GLuint(step)))
+		out.MutateAndWrite(ctx, atom.NoID, NewGlClearBufferuiv(GLenum_GL_COLOR, 0, clearValue.Ptr()).
+			AddRead(clearValue.Data()))
+		out.MutateAndWrite(ctx, atom.NoID, dc)
+		out.MutateAndWrite(ctx, atom.NoID, replay.Custom(func(ctx log.Context, s *gfxapi.State, b *builder.Builder) error {
+			b.ReserveMemory(tmp.Range())
+			NewGlReadPixels(x, y, 1, 1, GLenum_GL_RGBA_INTEGER, GLenum_GL_UNSIGNED_INT, tmp.Ptr()).
+				Call(ctx, s, b)
+
+			b.Post(value.ObservedPointer(tmp.Address()), pixelSize, func(r pod.Reader, err error) error {
+				if failed {
+					return err
+				}
+				if err == nil {
+					for j := range values[i] {
+						values[i][j] = r.Uint32()
+					}
+					err = r.Error()
+				}
+				switch {
+				case err != nil:
+					failed = true
+					req.out <- shaderTraceRes{err: fmt.Errorf("Could not read shader trace value: %v", err)}
+				case i == len(values)-1:
+					req.out <- shaderTraceRes{values: values, info: info}
+				}
+				return err
+			})
+			return nil
+		}))
+	}
+	return nil
+}
+
+// bindDebugProgram creates, links and binds a program built from the given
+// shader sources, using the attribute locations and uniform values of prog.
+// It returns the location of the shadertools.DebugStepUniformName uniform.
+func bindDebugProgram(ctx log.Context, t *tweaker, prog *Program, vsSource, fsSource string, out transform.Writer) UniformLocation {
+	s := out.State()
+	programID := t.glCreateProgram()
+	for _, shader := range []struct {
+		ty     GLenum
+		source string
+	}{
+		{GLenum_GL_VERTEX_SHADER, vsSource},
+		{GLenum_GL_FRAGMENT_SHADER, fsSource},
+	} {
+		shaderID := t.glCreateShader(shader.ty)
+		src := t.AllocData(shader.source)
+		srcLen := t.AllocData(GLint(len(shader.source)))
+		ptrToSrc := t.AllocData(src.Ptr())
+		a := NewGlShaderSource(shaderID, 1, ptrToSrc.Ptr(), srcLen.Ptr()).
+			AddRead(ptrToSrc.Data()).
+			AddRead(srcLen.Data()).
+			AddRead(src.Data())
+		// The sources are already translated for the replay device, so they
+		// are written with a custom atom to bypass the compatibility transform.
+		out.MutateAndWrite(ctx, atom.NoID, replay.Custom(func(ctx log.Context, s *gfxapi.State, b *builder.Builder) error {
+			return a.Mutate(ctx, s, b)
+		}))
+		out.MutateAndWrite(ctx, atom.NoID, NewGlCompileShader(shaderID))
+		out.MutateAndWrite(ctx, atom.NoID, NewGlAttachShader(programID, shaderID))
+	}
+
+	// Link with the introspection of prog, so that the attributes are bound
+	// to the same locations and the uniforms can be set.
+	info := &ProgramInfo{
+		LinkStatus:       GLboolean_GL_TRUE,
+		ActiveAttributes: AttributeIndexːActiveAttributeᵐ{},
+		ActiveUniforms:   UniformIndexːActiveUniformᵐ{},
+	}
+	stepLocation := UniformLocation(0)
+	for k, v := range prog.ActiveAttributes {
+		info.ActiveAttributes[k] = v
+	}
+	for k, v := range prog.ActiveUniforms {
+		info.ActiveUniforms[k] = v
+		if end := v.Location + UniformLocation(v.ArraySize); end > stepLocation {
+			stepLocation = end
+		}
+	}
+	info.ActiveUniforms[UniformIndex(len(info.ActiveUniforms))] = ActiveUniform{
+		Name:      shadertools.DebugStepUniformName,
+		Type:      GLenum_GL_UNSIGNED_INT,
+		ArraySize: 1,
+		Location:  stepLocation,
+	}
+	out.MutateAndWrite(ctx, atom.NoID, atom.WithExtras(NewGlLinkProgram(programID), info))
+	t.glUseProgram(programID)
+
+	for _, u := range info.ActiveUniforms {
+		if u.Location < 0 {
+			continue
+		}
+		// Map the captured location to the location in the new program.
+		out.MutateAndWrite(ctx, atom.NoID, NewGlGetUniformLocation(programID, u.Name, u.Location))
+		if u.Name == shadertools.DebugStepUniformName {
+			continue
+		}
+		data := prog.Uniforms[u.Location].Value.Read(ctx, nil, s, nil)
+		size := 4 * uniformComponents(u.Type)
+		count := GLsizei(len(data) / size)
+		if count == 0 {
+			continue // The uniform was not set, so use the default value.
+		}
+		if count > GLsizei(u.ArraySize) {
+			count = GLsizei(u.ArraySize)
+		}
+		tmp := t.AllocData(data)
+		if set := setUniform(u.Location, u.Type, count, tmp.Ptr()); set != nil {
+			set.Extras().GetOrAppendObservations().AddRead(tmp.Data())
+			out.MutateAndWrite(ctx, atom.NoID, set)
+		} else {
+			ctx.Warning().Logf("Unsupported uniform type %v of %s", u.Type, u.Name)
+		}
+	}
+	return stepLocation
+}
+
+// uniformComponents returns the number of 32-bit components of a uniform of
+// type ty.
+func uniformComponents(ty GLenum) int {
+	switch ty {
+	case GLenum_GL_FLOAT_VEC2, GLenum_GL_INT_VEC2, GLenum_GL_UNSIGNED_INT_VEC2, GLenum_GL_BOOL_VEC2:
+		return 2
+	case GLenum_GL_FLOAT_VEC3, GLenum_GL_INT_VEC3, GLenum_GL_UNSIGNED_INT_VEC3, GLenum_GL_BOOL_VEC3:
+		return 3
+	case GLenum_GL_FLOAT_VEC4, GLenum_GL_INT_VEC4, GLenum_GL_UNSIGNED_INT_VEC4, GLenum_GL_BOOL_VEC4,
+		GLenum_GL_FLOAT_MAT2:
+		return 4
+	case GLenum_GL_FLOAT_MAT2x3, GLenum_GL_FLOAT_MAT3x2:
+		return 6
+	case GLenum_GL_FLOAT_MAT2x4, GLenum_GL_FLOAT_MAT4x2:
+		return 8
+	case GLenum_GL_FLOAT_MAT3:
+		return 9
+	case GLenum_GL_FLOAT_MAT3x4, GLenum_GL_FLOAT_MAT4x3:
+		return 12
+	case GLenum_GL_FLOAT_MAT4:
+		return 16
+	default:
+		return 1
+	}
+}
+
+// setUniform returns the glUniform atom that sets count elements of the
+// uniform of type ty at location to the values at data, or nil if the type is
+// not supported.
+func setUniform(location UniformLocation, ty GLenum, count GLsizei, data memory.Pointer) atom.Atom {
+	var a atom.Atom
+	switch ty {
+	case GLenum_GL_FLOAT:
+		a = NewGlUniform1fv(location, count, data)
+	case GLenum_GL_FLOAT_VEC2:
+		a = NewGlUniform2fv(location, count, data)
+	case GLenum_GL_FLOAT_VEC3:
+		a = NewGlUniform3fv(location, count, data)
+	case GLenum_GL_FLOAT_VEC4:
+		a = NewGlUniform4fv(location, count, data)
+	case GLenum_GL_INT, GLenum_GL_BOOL:
+		a = NewGlUniform1iv(location, count, data)
+	case GLenum_GL_INT_VEC2, GLenum_GL_BOOL_VEC2:
+		a = NewGlUniform2iv(location, count, data)
+	case GLenum_GL_INT_VEC3, GLenum_GL_BOOL_VEC3:
+		a = NewGlUniform3iv(location, count, data)
+	case GLenum_GL_INT_VEC4, GLenum_GL_BOOL_VEC4:
+		a = NewGlUniform4iv(location, count, data)
+	case GLenum_GL_UNSIGNED_INT:
+		a = NewGlUniform1uiv(location, count, data)
+	case GLenum_GL_UNSIGNED_INT_VEC2:
+		a = NewGlUniform2uiv(location, count, data)
+	case GLenum_GL_UNSIGNED_INT_VEC3:
+		a = NewGlUniform3uiv(location, count, data)
+	case GLenum_GL_UNSIGNED_INT_VEC4:
+		a = NewGlUniform4uiv(location, count, data)
+	case GLenum_GL_FLOAT_MAT2:
+		a = NewGlUniformMatrix2fv(location, count, GLboolean_GL_FALSE, data)
+	case GLenum_GL_FLOAT_MAT3:
+		a = NewGlUniformMatrix3fv(location, count, GLboolean_GL_FALSE, data)
+	case GLenum_GL_FLOAT_MAT4:
+		a = NewGlUniformMatrix4fv(location, count, GLboolean_GL_FALSE, data)
+	case GLenum_GL_FLOAT_MAT2x3:
+		a = NewGlUniformMatrix2x3fv(location, count, GLboolean_GL_FALSE, data)
+	case GLenum_GL_FLOAT_MAT2x4:
+		a = NewGlUniformMatrix2x4fv(location, count, GLboolean_GL_FALSE, data)
+	case GLenum_GL_FLOAT_MAT3x2:
+		a = NewGlUniformMatrix3x2fv(location, count, GLboolean_GL_FALSE, data)
+	case GLenum_GL_FLOAT_MAT3x4:
+		a = NewGlUniformMatrix3x4fv(location, count, GLboolean_GL_FALSE, data)
+	case GLenum_GL_FLOAT_MAT4x2:
+		a = NewGlUniformMatrix4x2fv(location, count, GLboolean_GL_FALSE, data)
+	case GLenum_GL_FLOAT_MAT4x3:
+		a = NewGlUniformMatrix4x3fv(location, count, GLboolean_GL_FALSE, data)
+	default:
+		if !isSampler(ty) {
+			return nil
+		}
+		a = NewGlUniform1iv(location, count, data)
+	}
+	return a
+}
+
+// shaderTraceSteps converts the debug steps to a service.ShaderTrace, keeping
+// only the steps writing to one of variables. If variables is empty then all
+// the steps are kept.
+func shaderTraceSteps(steps []shadertools.DebugStep, variables []string) *service.ShaderTrace {
+	filter := map[string]bool{}
+	for _, v := range variables {
+		filter[v] = true
+	}
+	out := &service.ShaderTrace{}
+	for _, step := range steps {
+		if len(filter) > 0 && !filter[step.Variable] {
+			continue
+		}
+		out.Steps = append(out.Steps, &service.ShaderTraceStep{
+			Variable: step.Variable,
+			Line:     step.Line,
+			Block:    step.Block,
+			Value:    step.Value[:],
+		})
+	}
+	return out
+}
@@ -27,57 +27,76 @@ func (s *State) getContext() *Context {
 // TODO: When gfx api macros produce functions instead of inlining, move this logic
 // to the gles.api file.
 func (s *State) getFramebufferAttachmentInfo(att gfxapi.FramebufferAttachment) (width, height uint32, ifmt imgfmt, err error) {
+	return s.getFramebufferAttachmentLayerInfo(att, nil)
+}
+
+// getFramebufferAttachment returns the current context and the attachment att
+// of the bound read framebuffer.
+func (s *State) getFramebufferAttachment(att gfxapi.FramebufferAttachment) (*Context, FramebufferAttachment, error) {
 	c := s.getContext()
 	if c == nil {
-		return 0, 0, imgfmt{}, fmt.Errorf("No context bound")
+		return nil, FramebufferAttachment{}, fmt.Errorf("No context bound")
 	}
 	if !c.Info.Initialized {
-		return 0, 0, imgfmt{}, fmt.Errorf("Context not initialized")
+		return nil, FramebufferAttachment{}, fmt.Errorf("Context not initialized")
 	}
 
 	framebuffer, ok := c.Instances.Framebuffers[c.BoundReadFramebuffer]
 	if !ok {
-		return 0, 0, imgfmt{}, fmt.Errorf("No GL_FRAMEBUFFER bound")
+		return nil, FramebufferAttachment{}, fmt.Errorf("No GL_FRAMEBUFFER bound")
 	}
 
 	var a FramebufferAttachment
-	switch att {
-	case gfxapi.FramebufferAttachment_Color0:
-		a = framebuffer.ColorAttachments[0]
-	case gfxapi.FramebufferAttachment_Color1:
-		a = framebuffer.ColorAttachments[1]
-	case gfxapi.FramebufferAttachment_Color2:
-		a = framebuffer.ColorAttachments[2]
-	case gfxapi.FramebufferAttachment_Color3:
-		a = framebuffer.ColorAttachments[3]
-	case gfxapi.FramebufferAttachment_Depth:
+	switch {
+	case att.IsColor():
+		a = framebuffer.ColorAttachments[GLint(att.ColorIndex())]
+	case att == gfxapi.FramebufferAttachment_Depth:
 		a = framebuffer.DepthAttachment
-	case gfxapi.FramebufferAttachment_Stencil:
+	case att == gfxapi.FramebufferAttachment_Stencil:
 		a = framebuffer.StencilAttachment
 	default:
-		return 0, 0, imgfmt{}, fmt.Errorf("Framebuffer attachment %v unsupported by gles", att)
+		return nil, FramebufferAttachment{}, fmt.Errorf("Framebuffer attachment %v unsupported by gles", att)
 	}
 
 	if a.ObjectType == GLenum_GL_NONE {
-		return 0, 0, imgfmt{}, fmt.Errorf("%s is not bound", att)
+		return nil, FramebufferAttachment{}, fmt.Errorf("%s is not bound", att)
+	}
+	return c, a, nil
+}
+
+// getFramebufferAttachmentLayerInfo returns the width, height and format of
+// the layer and level of the image bound to the attachment att. If layer is
+// nil then the layer and level bound to the framebuffer are used.
+func (s *State) getFramebufferAttachmentLayerInfo(att gfxapi.FramebufferAttachment, layer *gfxapi.FramebufferLayer) (width, height uint32, ifmt imgfmt, err error) {
+	c, a, err := s.getFramebufferAttachment(att)
+	if err != nil {
+		return 0, 0, imgfmt{}, err
 	}
 
 	switch a.ObjectType {
 	case GLenum_GL_TEXTURE:
 		id := TextureId(a.ObjectName)
-		t := c.Instances.Textures[id]
-		switch t.Kind {
-		case GLenum_GL_TEXTURE_2D:
-			l := t.Texture2D[a.TextureLevel]
+		t, ok := c.Instances.Textures[id]
+		if !ok {
+			return 0, 0, imgfmt{}, fmt.Errorf("Texture %v not found", id)
+		}
+		level, face, index := a.TextureLevel, a.TextureCubeMapFace, a.TextureLayer
+		if layer != nil {
+			level, index = GLint(layer.Level), GLint(layer.Layer)
+			face = GLenum_GL_TEXTURE_CUBE_MAP_POSITIVE_X + GLenum(layer.Layer)
+		}
+		l, err := t.layerImage(level, face, index)
+		if err != nil {
+			return 0, 0, imgfmt{}, err
+		}
+		if t.Kind == GLenum_GL_TEXTURE_2D {
 			return uint32(l.Width), uint32(l.Height), newImgfmt(t.TexelFormat, t.TexelType), nil
-		case GLenum_GL_TEXTURE_CUBE_MAP:
-			l := t.Cubemap[a.TextureLevel]
-			f := l.Faces[a.TextureCubeMapFace]
-			return uint32(f.Width), uint32(f.Height), newImgfmt(f.TexelFormat, f.TexelType), nil
-		default:
-			return 0, 0, imgfmt{}, fmt.Errorf("Unknown texture kind %v", t.Kind)
 		}
+		return uint32(l.Width), uint32(l.Height), newImgfmt(l.TexelFormat, l.TexelType), nil
 	case GLenum_GL_RENDERBUFFER:
+		if layer != nil && (layer.Layer != 0 || layer.Level != 0) {
+			return 0, 0, imgfmt{}, fmt.Errorf("Renderbuffers only have a single layer and level")
+		}
 		id := RenderbufferId(a.ObjectName)
 		r, ok := c.Instances.Renderbuffers[id]
 		if !ok {
@@ -90,3 +109,57 @@ func (s *State) getFramebufferAttachmentInfo(att gfxapi.FramebufferAttachment) (
 		return 0, 0, imgfmt{}, fmt.Errorf("Unknown framebuffer attachment type %T", a.ObjectType)
 	}
 }
+
+// getFramebufferAttachmentLayers returns the number of layers and levels of
+// the image bound to the attachment att.
+func (s *State) getFramebufferAttachmentLayers(att gfxapi.FramebufferAttachment) (layers, levels uint32, err error) {
+	c, a, err := s.getFramebufferAttachment(att)
+	if err != nil {
+		return 0, 0, err
+	}
+	if a.ObjectType != GLenum_GL_TEXTURE {
+		return 1, 1, nil
+	}
+	t, ok := c.Instances.Textures[TextureId(a.ObjectName)]
+	if !ok {
+		return 0, 0, fmt.Errorf("Texture %v not found", a.ObjectName)
+	}
+	switch t.Kind {
+	case GLenum_GL_TEXTURE_2D:
+		return 1, uint32(len(t.Texture2D)), nil
+	case GLenum_GL_TEXTURE_CUBE_MAP:
+		return 6, uint32(len(t.Cubemap)), nil
+	case GLenum_GL_TEXTURE_3D, GLenum_GL_TEXTURE_2D_ARRAY, GLenum_GL_TEXTURE_CUBE_MAP_ARRAY:
+		// The number of depth slices of 3D textures shrinks with the level.
+		return uint32(len(t.Layered[a.TextureLevel].Layers)), uint32(len(t.Layered)), nil
+	default:
+		return 0, 0, fmt.Errorf("Unknown texture kind %v", t.Kind)
+	}
+}
+
+// layerImage returns the image of the texture at the given level and, for
+// cube-maps, face or, for layered textures, layer.
+func (t *Texture) layerImage(level GLint, face GLenum, layer GLint) (Image, error) {
+	var (
+		img Image
+		ok  bool
+	)
+	switch t.Kind {
+	case GLenum_GL_TEXTURE_2D:
+		img, ok = t.Texture2D[level]
+	case GLenum_GL_TEXTURE_CUBE_MAP:
+		if l, found := t.Cubemap[level]; found {
+			img, ok = l.Faces[face]
+		}
+	case GLenum_GL_TEXTURE_3D, GLenum_GL_TEXTURE_2D_ARRAY, GLenum_GL_TEXTURE_CUBE_MAP_ARRAY:
+		if l, found := t.Layered[level]; found {
+			img, ok = l.Layers[layer]
+		}
+	default:
+		return Image{}, fmt.Errorf("Unknown texture kind %v", t.Kind)
+	}
+	if !ok {
+		return Image{}, fmt.Errorf("Texture %v has no image at level %v, face %v, layer %v", t.ID, level, face, layer)
+	}
+	return img, nil
+}
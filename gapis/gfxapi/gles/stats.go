@@ -0,0 +1,205 @@
+// Copyright (C) 2017 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gles
+
+import (
+	"reflect"
+	"strings"
+
+	"github.com/google/gapid/core/log"
+	"github.com/google/gapid/gapis/atom"
+	"github.com/google/gapid/gapis/gfxapi"
+)
+
+// Interface compliance test
+var _ = gfxapi.StatsProvider(api{})
+
+// The categories of state changed by commands.
+const (
+	capabilityState  = "capability"
+	blendState       = "blend"
+	depthState       = "depth"
+	stencilState     = "stencil"
+	rasterState      = "rasterization"
+	viewportState    = "viewport"
+	programState     = "program"
+	uniformState     = "uniform"
+	bufferState      = "buffer"
+	textureState     = "texture"
+	samplerState     = "sampler"
+	framebufferState = "framebuffer"
+	vertexArrayState = "vertex array"
+)
+
+// CommandStats implements the gfxapi.StatsProvider interface.
+func (api) CommandStats(ctx log.Context, o interface{}, s *gfxapi.State) (gfxapi.CommandStats, error) {
+	out := gfxapi.CommandStats{}
+	a, ok := o.(atom.Atom)
+	if !ok {
+		return out, nil
+	}
+	c := GetContext(s)
+	if c == nil {
+		return out, nil
+	}
+
+	if a.AtomFlags().IsDrawCall() {
+		out.Vertices, out.Primitives = drawCallCounts(a)
+		return out, nil
+	}
+
+	out.StateChange = stateChangeCategory(a)
+
+	pixelUnpackBuffer := c.BoundBuffers.PixelUnpackBuffer != 0
+	var err error
+	switch a := a.(type) {
+	case *GlCompileShader:
+		out.ShaderCompile = true
+
+	case *GlBufferData:
+		if a.Data.Address != 0 {
+			out.BufferUploadBytes = uint64(a.Size)
+		}
+	case *GlBufferSubData:
+		out.BufferUploadBytes = uint64(a.Size)
+
+	case *GlTexImage2D:
+		if a.Data.Address != 0 || pixelUnpackBuffer {
+			out.TextureUploadBytes, err = textureBytes(ctx, a, s, a.Width, a.Height, 1, a.Format, a.Type)
+		}
+	case *GlTexSubImage2D:
+		if a.Data.Address != 0 || pixelUnpackBuffer {
+			out.TextureUploadBytes, err = textureBytes(ctx, a, s, a.Width, a.Height, 1, a.Format, a.Type)
+		}
+	case *GlTexImage3D:
+		if a.Data.Address != 0 || pixelUnpackBuffer {
+			out.TextureUploadBytes, err = textureBytes(ctx, a, s, a.Width, a.Height, a.Depth, a.Format, a.Type)
+		}
+	case *GlTexSubImage3D:
+		if a.Data.Address != 0 || pixelUnpackBuffer {
+			out.TextureUploadBytes, err = textureBytes(ctx, a, s, a.Width, a.Height, a.Depth, a.Format, a.Type)
+		}
+	case *GlCompressedTexImage2D:
+		out.TextureUploadBytes = uint64(a.ImageSize)
+	case *GlCompressedTexSubImage2D:
+		out.TextureUploadBytes = uint64(a.ImageSize)
+	case *GlCompressedTexImage3D:
+		out.TextureUploadBytes = uint64(a.ImageSize)
+	case *GlCompressedTexSubImage3D:
+		out.TextureUploadBytes = uint64(a.ImageSize)
+	}
+	return out, err
+}
+
+// stateChangeCategory returns the category of the state changed by a, or ""
+// if a does not change any of the categorized state.
+func stateChangeCategory(a atom.Atom) string {
+	switch a.(type) {
+	case *GlEnable, *GlDisable:
+		return capabilityState
+	case *GlBlendFunc, *GlBlendFuncSeparate, *GlBlendEquation,
+		*GlBlendEquationSeparate, *GlBlendColor, *GlColorMask:
+		return blendState
+	case *GlDepthFunc, *GlDepthMask, *GlDepthRangef:
+		return depthState
+	case *GlStencilFunc, *GlStencilFuncSeparate, *GlStencilOp,
+		*GlStencilOpSeparate, *GlStencilMask, *GlStencilMaskSeparate:
+		return stencilState
+	case *GlCullFace, *GlFrontFace, *GlLineWidth, *GlPolygonOffset:
+		return rasterState
+	case *GlViewport, *GlScissor:
+		return viewportState
+	case *GlUseProgram:
+		return programState
+	case *GlBindBuffer, *GlBindBufferBase, *GlBindBufferRange:
+		return bufferState
+	case *GlActiveTexture, *GlBindTexture, *GlTexParameteri, *GlTexParameterf:
+		return textureState
+	case *GlBindSampler, *GlSamplerParameteri:
+		return samplerState
+	case *GlBindFramebuffer, *GlBindRenderbuffer:
+		return framebufferState
+	case *GlBindVertexArray, *GlBindVertexArrayOES, *GlVertexAttribPointer,
+		*GlEnableVertexAttribArray, *GlDisableVertexAttribArray:
+		return vertexArrayState
+	}
+	// There are many variants of the uniform commands.
+	if name := reflect.TypeOf(a).Elem().Name(); strings.HasPrefix(name, "GlUniform") ||
+		strings.HasPrefix(name, "GlProgramUniform") {
+		return uniformState
+	}
+	return ""
+}
+
+// drawCallCounts returns the number of vertices and primitives drawn by the
+// draw call a, including all its instances. Instanced draws of no instances
+// and draws without an indices_count parameter, such as indirect draws,
+// return 0, 0.
+func drawCallCounts(a atom.Atom) (vertices, primitives uint64) {
+	v := reflect.ValueOf(a).Elem()
+	count, mode := v.FieldByName("IndicesCount"), v.FieldByName("DrawMode")
+	if !count.IsValid() || !mode.IsValid() || count.Int() < 0 {
+		return 0, 0
+	}
+	vertices = uint64(count.Int())
+	primitives = primitiveCount(GLenum(mode.Uint()), vertices)
+	if instances := v.FieldByName("InstanceCount"); instances.IsValid() {
+		if instances.Int() <= 0 {
+			return 0, 0 // No instances are drawn.
+		}
+		vertices *= uint64(instances.Int())
+		primitives *= uint64(instances.Int())
+	}
+	return vertices, primitives
+}
+
+// primitiveCount returns the number of primitives formed by vertices vertices
+// with the draw mode mode.
+func primitiveCount(mode GLenum, vertices uint64) uint64 {
+	switch mode {
+	case GLenum_GL_POINTS:
+		return vertices
+	case GLenum_GL_LINES:
+		return vertices / 2
+	case GLenum_GL_LINE_STRIP:
+		if vertices >= 2 {
+			return vertices - 1
+		}
+	case GLenum_GL_LINE_LOOP:
+		if vertices >= 2 {
+			return vertices
+		}
+	case GLenum_GL_TRIANGLES:
+		return vertices / 3
+	case GLenum_GL_TRIANGLE_STRIP, GLenum_GL_TRIANGLE_FAN:
+		if vertices >= 3 {
+			return vertices - 2
+		}
+	}
+	return 0
+}
+
+// textureBytes returns the number of bytes of an uncompressed texture upload
+// of the given size, format and type.
+func textureBytes(ctx log.Context, a atom.Atom, s *gfxapi.State, width, height, depth GLsizei, format, ty GLenum) (uint64, error) {
+	if width <= 0 || height <= 0 || depth <= 0 {
+		return 0, nil
+	}
+	size, err := subImageSize(ctx, a, nil, s, GetState(s), nil, uint32(width), uint32(height), format, ty)
+	if err != nil {
+		return 0, err
+	}
+	return uint64(size) * uint64(depth), nil
+}
@@ -0,0 +1,145 @@
+// Copyright (C) 2017 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gles
+
+import (
+	"testing"
+
+	"github.com/google/gapid/core/assert"
+	"github.com/google/gapid/core/log"
+	"github.com/google/gapid/gapis/atom"
+	"github.com/google/gapid/gapis/database"
+	"github.com/google/gapid/gapis/gfxapi"
+	"github.com/google/gapid/gapis/memory"
+)
+
+func TestPrimitiveCount(t *testing.T) {
+	ctx := log.Testing(t)
+	for _, test := range []struct {
+		mode       GLenum
+		vertices   uint64
+		primitives uint64
+	}{
+		{GLenum_GL_POINTS, 5, 5},
+		{GLenum_GL_LINES, 5, 2},
+		{GLenum_GL_LINE_STRIP, 5, 4},
+		{GLenum_GL_LINE_STRIP, 1, 0},
+		{GLenum_GL_LINE_LOOP, 5, 5},
+		{GLenum_GL_LINE_LOOP, 1, 0},
+		{GLenum_GL_TRIANGLES, 7, 2},
+		{GLenum_GL_TRIANGLE_STRIP, 6, 4},
+		{GLenum_GL_TRIANGLE_STRIP, 2, 0},
+		{GLenum_GL_TRIANGLE_FAN, 6, 4},
+		{GLenum_GL_TRIANGLE_FAN, 2, 0},
+		{GLenum_GL_RGBA, 6, 0},
+	} {
+		ctx := ctx.V("mode", test.mode).V("vertices", test.vertices)
+		assert.For(ctx, "primitives").That(primitiveCount(test.mode, test.vertices)).Equals(test.primitives)
+	}
+}
+
+func TestStateChangeCategory(t *testing.T) {
+	ctx := log.Testing(t)
+	for _, test := range []struct {
+		atom     atom.Atom
+		category string
+	}{
+		{NewGlEnable(GLenum_GL_BLEND), capabilityState},
+		{NewGlBlendFunc(GLenum_GL_ONE, GLenum_GL_ZERO), blendState},
+		{NewGlDepthFunc(GLenum_GL_LESS), depthState},
+		{NewGlStencilMask(0xff), stencilState},
+		{NewGlCullFace(GLenum_GL_BACK), rasterState},
+		{NewGlViewport(0, 0, 64, 64), viewportState},
+		{NewGlUseProgram(1), programState},
+		{NewGlUniform1i(0, 0), uniformState},
+		{NewGlUniform4fv(0, 1, memory.Nullptr), uniformState},
+		{NewGlProgramUniform1i(1, 0, 0), uniformState},
+		{NewGlBindBuffer(GLenum_GL_ARRAY_BUFFER, 1), bufferState},
+		{NewGlBindTexture(GLenum_GL_TEXTURE_2D, 1), textureState},
+		{NewGlBindSampler(0, 1), samplerState},
+		{NewGlBindFramebuffer(GLenum_GL_FRAMEBUFFER, 1), framebufferState},
+		{NewGlEnableVertexAttribArray(0), vertexArrayState},
+		{NewGlDrawArrays(GLenum_GL_TRIANGLES, 0, 3), ""},
+		{NewGlFlush(), ""},
+	} {
+		ctx := ctx.V("atom", test.atom)
+		assert.For(ctx, "category").That(stateChangeCategory(test.atom)).Equals(test.category)
+	}
+}
+
+func TestDrawCallCounts(t *testing.T) {
+	ctx := log.Testing(t)
+	for _, test := range []struct {
+		name       string
+		atom       atom.Atom
+		vertices   uint64
+		primitives uint64
+	}{
+		{"arrays", NewGlDrawArrays(GLenum_GL_TRIANGLES, 0, 6), 6, 2},
+		{"elements", NewGlDrawElements(GLenum_GL_LINES, 4, GLenum_GL_UNSIGNED_SHORT, memory.Nullptr), 4, 2},
+		{"instanced", NewGlDrawArraysInstanced(GLenum_GL_TRIANGLES, 0, 6, 3), 18, 6},
+		{"no instances", NewGlDrawArraysInstanced(GLenum_GL_TRIANGLES, 0, 6, 0), 0, 0},
+		{"negative count", NewGlDrawArrays(GLenum_GL_TRIANGLES, 0, -3), 0, 0},
+		{"indirect", NewGlDrawArraysIndirect(GLenum_GL_TRIANGLES, memory.Nullptr), 0, 0},
+	} {
+		vertices, primitives := drawCallCounts(test.atom)
+		assert.For(ctx, "%s vertices", test.name).That(vertices).Equals(test.vertices)
+		assert.For(ctx, "%s primitives", test.name).That(primitives).Equals(test.primitives)
+	}
+}
+
+func TestCommandStats(t *testing.T) {
+	ctx := log.Testing(t)
+	ctx = database.Put(ctx, database.NewInMemory(ctx))
+	ctx, out, prologue := newTestState(ctx)
+	for i, a := range prologue {
+		out.MutateAndWrite(ctx, atom.ID(i), a)
+	}
+	data := atom.Must(atom.AllocData(ctx, out.S, []uint8{1, 2, 3, 4}))
+
+	for _, test := range []struct {
+		name     string
+		atom     atom.Atom
+		expected gfxapi.CommandStats
+	}{
+		{"draw",
+			NewGlDrawArraysInstanced(GLenum_GL_TRIANGLE_STRIP, 0, 4, 2),
+			gfxapi.CommandStats{Vertices: 8, Primitives: 4}},
+		{"state change",
+			NewGlBindBuffer(GLenum_GL_ARRAY_BUFFER, 1),
+			gfxapi.CommandStats{StateChange: bufferState}},
+		{"buffer upload",
+			NewGlBufferData(GLenum_GL_ARRAY_BUFFER, 4, data.Ptr(), GLenum_GL_STATIC_DRAW).AddRead(data.Data()),
+			gfxapi.CommandStats{BufferUploadBytes: 4}},
+		{"buffer allocation",
+			NewGlBufferData(GLenum_GL_ARRAY_BUFFER, 4, memory.Nullptr, GLenum_GL_STATIC_DRAW),
+			gfxapi.CommandStats{}},
+		{"texture upload",
+			NewGlTexImage2D(GLenum_GL_TEXTURE_2D, 0, GLint(GLenum_GL_RGBA), 1, 1, 0,
+				GLenum_GL_RGBA, GLenum_GL_UNSIGNED_BYTE, data.Ptr()).AddRead(data.Data()),
+			gfxapi.CommandStats{TextureUploadBytes: 4}},
+		{"compressed texture upload",
+			NewGlCompressedTexImage2D(GLenum_GL_TEXTURE_2D, 0, GLenum_GL_ETC1_RGB8_OES, 4, 4, 0, 8, memory.Nullptr),
+			gfxapi.CommandStats{TextureUploadBytes: 8}},
+		{"shader compile",
+			NewGlCompileShader(1),
+			gfxapi.CommandStats{ShaderCompile: true}},
+	} {
+		got, err := api{}.CommandStats(ctx, test.atom, out.S)
+		if assert.For(ctx, "%s err", test.name).ThatError(err).Succeeded() {
+			assert.For(ctx, test.name).That(got).Equals(test.expected)
+		}
+	}
+}
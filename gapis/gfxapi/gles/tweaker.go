@@ -106,6 +106,55 @@ func (t *tweaker) glDepthFunc(v GLenum) {
 	}
 }
 
+func (t *tweaker) glStencilFunc(f GLenum, ref GLint, mask GLuint) {
+	o := t.c.FragmentOperations.Stencil
+	if o.Func != f || o.Ref != ref || o.ValueMask != mask {
+		t.doAndUndo(
+			NewGlStencilFuncSeparate(GLenum_GL_FRONT, f, ref, mask),
+			NewGlStencilFuncSeparate(GLenum_GL_FRONT, o.Func, o.Ref, o.ValueMask))
+	}
+	if o.BackFunc != f || o.BackRef != ref || o.BackValueMask != mask {
+		t.doAndUndo(
+			NewGlStencilFuncSeparate(GLenum_GL_BACK, f, ref, mask),
+			NewGlStencilFuncSeparate(GLenum_GL_BACK, o.BackFunc, o.BackRef, o.BackValueMask))
+	}
+}
+
+func (t *tweaker) glStencilOp(fail, zfail, zpass GLenum) {
+	o := t.c.FragmentOperations.Stencil
+	if o.Fail != fail || o.PassDepthFail != zfail || o.PassDepthPass != zpass {
+		t.doAndUndo(
+			NewGlStencilOpSeparate(GLenum_GL_FRONT, fail, zfail, zpass),
+			NewGlStencilOpSeparate(GLenum_GL_FRONT, o.Fail, o.PassDepthFail, o.PassDepthPass))
+	}
+	if o.BackFail != fail || o.BackPassDepthFail != zfail || o.BackPassDepthPass != zpass {
+		t.doAndUndo(
+			NewGlStencilOpSeparate(GLenum_GL_BACK, fail, zfail, zpass),
+			NewGlStencilOpSeparate(GLenum_GL_BACK, o.BackFail, o.BackPassDepthFail, o.BackPassDepthPass))
+	}
+}
+
+func (t *tweaker) glStencilMask(mask GLuint) {
+	if o := t.c.Framebuffer.StencilWritemask; o != mask {
+		t.doAndUndo(
+			NewGlStencilMaskSeparate(GLenum_GL_FRONT, mask),
+			NewGlStencilMaskSeparate(GLenum_GL_FRONT, o))
+	}
+	if o := t.c.Framebuffer.StencilBackWritemask; o != mask {
+		t.doAndUndo(
+			NewGlStencilMaskSeparate(GLenum_GL_BACK, mask),
+			NewGlStencilMaskSeparate(GLenum_GL_BACK, o))
+	}
+}
+
+func (t *tweaker) glClearStencil(v GLint) {
+	if o := t.c.Framebuffer.StencilClearValue; o != v {
+		t.doAndUndo(
+			NewGlClearStencil(v),
+			NewGlClearStencil(o))
+	}
+}
+
 func (t *tweaker) glBlendColor(r, g, b, a GLfloat) {
 	n := Color{Red: r, Green: g, Blue: b, Alpha: a}
 	if o := t.c.FragmentOperations.BlendColor; o != n {
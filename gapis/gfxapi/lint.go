@@ -0,0 +1,43 @@
+// Copyright (C) 2017 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gfxapi
+
+import (
+	"github.com/google/gapid/core/fault/severity"
+	"github.com/google/gapid/core/log"
+	"github.com/google/gapid/gapis/stringtable"
+)
+
+// Linter is the optional interface implemented by APIs that can statically
+// check the commands of a capture for performance problems and poor practices.
+type Linter interface {
+	// NewLint returns a new Lint used to check the commands of a single
+	// capture.
+	NewLint(ctx log.Context) Lint
+}
+
+// Lint checks a stream of commands, holding any information it needs to
+// track between commands.
+type Lint interface {
+	// Check is called with each command o of the API in capture order, and the
+	// state s before o is mutated. It returns the issues found with o.
+	Check(ctx log.Context, o interface{}, s *State) []LintIssue
+}
+
+// LintIssue is a single issue found by a Lint.
+type LintIssue struct {
+	Severity severity.Level   // The severity of the issue.
+	Message  *stringtable.Msg // The description of the issue.
+}
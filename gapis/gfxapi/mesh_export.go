@@ -0,0 +1,399 @@
+// Copyright (C) 2017 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gfxapi
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+
+	"github.com/google/gapid/core/data/endian"
+	"github.com/google/gapid/core/fault/cause"
+	"github.com/google/gapid/core/log"
+	"github.com/google/gapid/core/os/device"
+	"github.com/google/gapid/core/stream"
+	"github.com/google/gapid/core/stream/fmts"
+	"github.com/google/gapid/gapis/service/path"
+	"github.com/google/gapid/gapis/vertex"
+)
+
+// Export encodes the mesh to the file format f.
+// Only the position, normal and texture coordinate streams are exported.
+func (m *Mesh) Export(ctx log.Context, f path.MeshFormat) ([]byte, error) {
+	switch f {
+	case path.MeshFormat_OBJ:
+		return m.exportOBJ(ctx)
+	case path.MeshFormat_GLTF:
+		return m.exportGLTF(ctx)
+	default:
+		return nil, fmt.Errorf("Unsupported mesh format %v", f)
+	}
+}
+
+// exportStream is a vertex stream converted to 32-bit floats, ready for export.
+type exportStream struct {
+	semantic   vertex.Semantic
+	components int
+	data       []float32
+}
+
+// exportStreams returns the position, normal and texture coordinate streams of
+// the mesh converted to 32-bit floats. The position stream is always first.
+func (m *Mesh) exportStreams(ctx log.Context) ([]exportStream, error) {
+	out := []exportStream{}
+	hasPosition := false
+	for _, s := range m.VertexBuffer.Streams {
+		var f *stream.Format
+		switch s.Semantic.Type {
+		case vertex.Semantic_Position, vertex.Semantic_Normal:
+			f = fmts.XYZ_F32
+		case vertex.Semantic_Texcoord:
+			f = fmts.XY_F32
+		default:
+			continue
+		}
+		data, err := stream.Convert(f, s.Format, s.Data)
+		if err != nil {
+			return nil, cause.Explain(ctx, err, "Couldn't convert vertex stream").With("name", s.Name)
+		}
+		es := exportStream{
+			semantic:   *s.Semantic,
+			components: len(f.Components),
+			data:       bytesToFloat32s(data),
+		}
+		if s.Semantic.Type == vertex.Semantic_Position {
+			if hasPosition {
+				continue
+			}
+			hasPosition = true
+			out = append([]exportStream{es}, out...)
+		} else {
+			out = append(out, es)
+		}
+	}
+	if !hasPosition {
+		return nil, fmt.Errorf("Mesh has no position stream")
+	}
+	return out, nil
+}
+
+// exportOBJ encodes the mesh as a Wavefront OBJ file.
+func (m *Mesh) exportOBJ(ctx log.Context) ([]byte, error) {
+	streams, err := m.exportStreams(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var texcoords, normals *exportStream
+	for i := range streams {
+		s := &streams[i]
+		switch {
+		case s.semantic.Type == vertex.Semantic_Texcoord && texcoords == nil:
+			texcoords = s
+		case s.semantic.Type == vertex.Semantic_Normal && normals == nil:
+			normals = s
+		}
+	}
+
+	buf := &bytes.Buffer{}
+	fmt.Fprintln(buf, "# Exported by GAPID")
+	positions := streams[0].data
+	for i := 0; i+2 < len(positions); i += 3 {
+		fmt.Fprintf(buf, "v %v %v %v\n", positions[i], positions[i+1], positions[i+2])
+	}
+	if texcoords != nil {
+		for i := 0; i+1 < len(texcoords.data); i += 2 {
+			fmt.Fprintf(buf, "vt %v %v\n", texcoords.data[i], texcoords.data[i+1])
+		}
+	}
+	if normals != nil {
+		for i := 0; i+2 < len(normals.data); i += 3 {
+			fmt.Fprintf(buf, "vn %v %v %v\n", normals.data[i], normals.data[i+1], normals.data[i+2])
+		}
+	}
+
+	// OBJ indices are 1-based, with the texture coordinate and normal indices
+	// following the position index.
+	ref := func(i uint32) string {
+		i++
+		switch {
+		case texcoords != nil && normals != nil:
+			return fmt.Sprintf("%d/%d/%d", i, i, i)
+		case texcoords != nil:
+			return fmt.Sprintf("%d/%d", i, i)
+		case normals != nil:
+			return fmt.Sprintf("%d//%d", i, i)
+		default:
+			return fmt.Sprint(i)
+		}
+	}
+	indices := m.IndexBuffer.Indices
+	switch m.DrawPrimitive {
+	case DrawPrimitive_Points:
+		for _, i := range indices {
+			fmt.Fprintf(buf, "p %v\n", ref(i))
+		}
+	case DrawPrimitive_Lines:
+		for i := 0; i+1 < len(indices); i += 2 {
+			fmt.Fprintf(buf, "l %v %v\n", ref(indices[i]), ref(indices[i+1]))
+		}
+	case DrawPrimitive_LineStrip, DrawPrimitive_LineLoop:
+		if len(indices) > 0 {
+			fmt.Fprint(buf, "l")
+			for _, i := range indices {
+				fmt.Fprintf(buf, " %v", ref(i))
+			}
+			if m.DrawPrimitive == DrawPrimitive_LineLoop {
+				fmt.Fprintf(buf, " %v", ref(indices[0]))
+			}
+			fmt.Fprintln(buf)
+		}
+	default:
+		for t, n := 0, m.TriangleCount(); t < n; t++ {
+			a, b, c := m.Triangle(t)
+			fmt.Fprintf(buf, "f %v %v %v\n", ref(a), ref(b), ref(c))
+		}
+	}
+	return buf.Bytes(), nil
+}
+
+// glTF constants, as defined by the glTF 2.0 specification.
+const (
+	gltfMagic          = 0x46546C67 // "glTF"
+	gltfVersion        = 2
+	gltfChunkJSON      = 0x4E4F534A // "JSON"
+	gltfChunkBIN       = 0x004E4942 // "BIN\0"
+	gltfFloat          = 5126
+	gltfUnsignedInt    = 5125
+	gltfArrayBuffer    = 34962
+	gltfElementBuffer  = 34963
+	gltfModeTriangles  = 4
+	gltfGeneratorLabel = "GAPID"
+)
+
+var gltfModes = map[DrawPrimitive]int{
+	DrawPrimitive_Points:        0,
+	DrawPrimitive_Lines:         1,
+	DrawPrimitive_LineLoop:      2,
+	DrawPrimitive_LineStrip:     3,
+	DrawPrimitive_Triangles:     gltfModeTriangles,
+	DrawPrimitive_TriangleStrip: 5,
+	DrawPrimitive_TriangleFan:   6,
+}
+
+type gltfBufferView struct {
+	Buffer     int `json:"buffer"`
+	ByteOffset int `json:"byteOffset"`
+	ByteLength int `json:"byteLength"`
+	Target     int `json:"target"`
+}
+
+type gltfAccessor struct {
+	BufferView    int       `json:"bufferView"`
+	ComponentType int       `json:"componentType"`
+	Count         int       `json:"count"`
+	Type          string    `json:"type"`
+	Min           []float32 `json:"min,omitempty"`
+	Max           []float32 `json:"max,omitempty"`
+}
+
+type gltfPrimitive struct {
+	Attributes map[string]int `json:"attributes"`
+	Indices    int            `json:"indices"`
+	Mode       int            `json:"mode"`
+}
+
+type gltfAsset struct {
+	Version   string `json:"version"`
+	Generator string `json:"generator"`
+}
+
+type gltfScene struct {
+	Nodes []int `json:"nodes"`
+}
+
+type gltfNode struct {
+	Mesh int `json:"mesh"`
+}
+
+type gltfMesh struct {
+	Primitives []gltfPrimitive `json:"primitives"`
+}
+
+type gltfBuffer struct {
+	ByteLength int `json:"byteLength"`
+}
+
+type gltfDocument struct {
+	Asset       gltfAsset        `json:"asset"`
+	Scene       int              `json:"scene"`
+	Scenes      []gltfScene      `json:"scenes"`
+	Nodes       []gltfNode       `json:"nodes"`
+	Meshes      []gltfMesh       `json:"meshes"`
+	Buffers     []gltfBuffer     `json:"buffers"`
+	BufferViews []gltfBufferView `json:"bufferViews"`
+	Accessors   []gltfAccessor   `json:"accessors"`
+}
+
+// exportGLTF encodes the mesh as a binary glTF 2.0 file.
+func (m *Mesh) exportGLTF(ctx log.Context) ([]byte, error) {
+	streams, err := m.exportStreams(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	doc := gltfDocument{
+		Asset:   gltfAsset{Version: "2.0", Generator: gltfGeneratorLabel},
+		Scenes:  []gltfScene{{Nodes: []int{0}}},
+		Nodes:   []gltfNode{{Mesh: 0}},
+		Meshes:  []gltfMesh{{}},
+		Buffers: []gltfBuffer{{}},
+	}
+
+	bin := &bytes.Buffer{}
+	w := endian.Writer(bin, device.LittleEndian)
+	addView := func(target int) int {
+		doc.BufferViews = append(doc.BufferViews, gltfBufferView{
+			ByteOffset: bin.Len(),
+			Target:     target,
+		})
+		return len(doc.BufferViews) - 1
+	}
+	endView := func(view int) {
+		v := &doc.BufferViews[view]
+		v.ByteLength = bin.Len() - v.ByteOffset
+	}
+
+	primitive := gltfPrimitive{Attributes: map[string]int{}, Mode: gltfModeTriangles}
+	if mode, ok := gltfModes[m.DrawPrimitive]; ok {
+		primitive.Mode = mode
+	}
+
+	vertexCount := len(streams[0].data) / streams[0].components
+	if vertexCount == 0 {
+		// The position accessor bounds can't be computed without vertices.
+		return nil, fmt.Errorf("Mesh has no vertices")
+	}
+	for _, s := range streams {
+		var name string
+		switch s.semantic.Type {
+		case vertex.Semantic_Position:
+			name = "POSITION"
+		case vertex.Semantic_Normal:
+			name = "NORMAL"
+		case vertex.Semantic_Texcoord:
+			name = fmt.Sprintf("TEXCOORD_%d", s.semantic.Index)
+		}
+		if _, ok := primitive.Attributes[name]; ok {
+			continue
+		}
+		if len(s.data)/s.components != vertexCount {
+			continue // glTF requires all attributes to have the same count.
+		}
+		view := addView(gltfArrayBuffer)
+		for i, f := range s.data {
+			if s.semantic.Type == vertex.Semantic_Texcoord && i%s.components == 1 {
+				// glTF texture coordinates have their origin at the top-left.
+				f = 1 - f
+			}
+			w.Float32(f)
+		}
+		endView(view)
+		accessor := gltfAccessor{
+			BufferView:    view,
+			ComponentType: gltfFloat,
+			Count:         vertexCount,
+			Type:          fmt.Sprintf("VEC%d", s.components),
+		}
+		if s.semantic.Type == vertex.Semantic_Position {
+			// The position accessor must declare its bounds.
+			accessor.Min, accessor.Max = bounds(s.data, s.components)
+		}
+		doc.Accessors = append(doc.Accessors, accessor)
+		primitive.Attributes[name] = len(doc.Accessors) - 1
+	}
+
+	view := addView(gltfElementBuffer)
+	for _, i := range m.IndexBuffer.Indices {
+		w.Uint32(i)
+	}
+	endView(view)
+	doc.Accessors = append(doc.Accessors, gltfAccessor{
+		BufferView:    view,
+		ComponentType: gltfUnsignedInt,
+		Count:         len(m.IndexBuffer.Indices),
+		Type:          "SCALAR",
+	})
+	primitive.Indices = len(doc.Accessors) - 1
+	doc.Meshes[0].Primitives = []gltfPrimitive{primitive}
+	doc.Buffers[0].ByteLength = bin.Len()
+
+	js, err := json.Marshal(doc)
+	if err != nil {
+		return nil, err
+	}
+
+	// Both chunks must be 4-byte aligned, padded with spaces and zeros
+	// respectively.
+	for len(js)%4 != 0 {
+		js = append(js, ' ')
+	}
+	for bin.Len()%4 != 0 {
+		bin.WriteByte(0)
+	}
+
+	out := &bytes.Buffer{}
+	o := endian.Writer(out, device.LittleEndian)
+	o.Uint32(gltfMagic)
+	o.Uint32(gltfVersion)
+	o.Uint32(uint32(12 + 8 + len(js) + 8 + bin.Len()))
+	o.Uint32(uint32(len(js)))
+	o.Uint32(gltfChunkJSON)
+	o.Data(js)
+	o.Uint32(uint32(bin.Len()))
+	o.Uint32(gltfChunkBIN)
+	o.Data(bin.Bytes())
+	if err := o.Error(); err != nil {
+		return nil, err
+	}
+	return out.Bytes(), nil
+}
+
+// bounds returns the per-component minimum and maximum of the vectors in data.
+func bounds(data []float32, components int) (min, max []float32) {
+	min, max = make([]float32, components), make([]float32, components)
+	for c := range min {
+		min[c], max[c] = math.MaxFloat32, -math.MaxFloat32
+	}
+	for i, f := range data {
+		c := i % components
+		if f < min[c] {
+			min[c] = f
+		}
+		if f > max[c] {
+			max[c] = f
+		}
+	}
+	return min, max
+}
+
+func bytesToFloat32s(data []byte) []float32 {
+	r := endian.Reader(bytes.NewReader(data), device.LittleEndian)
+	out := make([]float32, len(data)/4)
+	for i := range out {
+		out[i] = r.Float32()
+	}
+	return out
+}
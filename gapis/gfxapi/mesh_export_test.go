@@ -0,0 +1,138 @@
+// Copyright (C) 2017 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gfxapi_test
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"math"
+	"testing"
+
+	"github.com/google/gapid/core/assert"
+	"github.com/google/gapid/core/log"
+	"github.com/google/gapid/core/stream/fmts"
+	"github.com/google/gapid/gapis/gfxapi"
+	"github.com/google/gapid/gapis/service/path"
+	"github.com/google/gapid/gapis/vertex"
+)
+
+func floats(v ...float32) []byte {
+	out := make([]byte, len(v)*4)
+	for i, f := range v {
+		binary.LittleEndian.PutUint32(out[i*4:], math.Float32bits(f))
+	}
+	return out
+}
+
+func triangleMesh() *gfxapi.Mesh {
+	return &gfxapi.Mesh{
+		DrawPrimitive: gfxapi.DrawPrimitive_Triangles,
+		VertexBuffer: &vertex.Buffer{Streams: []*vertex.Stream{
+			{
+				Name:     "position",
+				Data:     floats(0, 0, 0, 1, 0, 0, 0, 1, 0),
+				Format:   fmts.XYZ_F32,
+				Semantic: &vertex.Semantic{Type: vertex.Semantic_Position},
+			},
+			{
+				Name:     "uv",
+				Data:     floats(0, 0, 1, 0, 0, 1),
+				Format:   fmts.XY_F32,
+				Semantic: &vertex.Semantic{Type: vertex.Semantic_Texcoord},
+			},
+		}},
+		IndexBuffer: &gfxapi.IndexBuffer{Indices: []uint32{0, 1, 2}},
+	}
+}
+
+func TestMeshExportOBJ(t *testing.T) {
+	ctx := log.Testing(t)
+	data, err := triangleMesh().Export(ctx, path.MeshFormat_OBJ)
+	if !assert.With(ctx).ThatError(err).Succeeded() {
+		return
+	}
+	assert.With(ctx).ThatString(string(data)).Equals(
+		"# Exported by GAPID\n" +
+			"v 0 0 0\n" +
+			"v 1 0 0\n" +
+			"v 0 1 0\n" +
+			"vt 0 0\n" +
+			"vt 1 0\n" +
+			"vt 0 1\n" +
+			"f 1/1 2/2 3/3\n")
+}
+
+func TestMeshExportGLTF(t *testing.T) {
+	ctx := log.Testing(t)
+	data, err := triangleMesh().Export(ctx, path.MeshFormat_GLTF)
+	if !assert.With(ctx).ThatError(err).Succeeded() {
+		return
+	}
+	header := data[:20]
+	assert.With(ctx).ThatSlice(header[:4]).Equals([]byte("glTF"))
+	assert.With(ctx).That(binary.LittleEndian.Uint32(header[4:])).Equals(uint32(2))
+	assert.With(ctx).That(int(binary.LittleEndian.Uint32(header[8:]))).Equals(len(data))
+	assert.With(ctx).ThatSlice(header[16:20]).Equals([]byte("JSON"))
+
+	jsonLength := binary.LittleEndian.Uint32(header[12:])
+	doc := struct {
+		Meshes []struct {
+			Primitives []struct {
+				Attributes map[string]int
+				Mode       int
+			}
+		}
+		BufferViews []struct {
+			ByteOffset int
+			ByteLength int
+		}
+		Accessors []struct {
+			BufferView int
+			Count      int
+			Type       string
+			Min, Max   []float32
+		}
+	}{}
+	js := bytes.TrimRight(data[20:20+jsonLength], " ")
+	if !assert.With(ctx).ThatError(json.Unmarshal(js, &doc)).Succeeded() {
+		return
+	}
+	primitive := doc.Meshes[0].Primitives[0]
+	assert.With(ctx).That(primitive.Mode).Equals(4)
+	assert.With(ctx).That(doc.Accessors[primitive.Attributes["POSITION"]].Type).Equals("VEC3")
+	assert.With(ctx).That(doc.Accessors[primitive.Attributes["TEXCOORD_0"]].Type).Equals("VEC2")
+	position := doc.Accessors[primitive.Attributes["POSITION"]]
+	assert.With(ctx).That(position.Count).Equals(3)
+	assert.With(ctx).ThatSlice(position.Min).Equals([]float32{0, 0, 0})
+	assert.With(ctx).ThatSlice(position.Max).Equals([]float32{1, 1, 0})
+
+	// The texture coordinates are flipped vertically.
+	bin := data[20+jsonLength+8:]
+	view := doc.BufferViews[doc.Accessors[primitive.Attributes["TEXCOORD_0"]].BufferView]
+	assert.With(ctx).ThatSlice(bin[view.ByteOffset : view.ByteOffset+view.ByteLength]).Equals(
+		floats(0, 1, 1, 1, 0, 0))
+}
+
+func TestMeshExportGLTFEmpty(t *testing.T) {
+	ctx := log.Testing(t)
+	m := triangleMesh()
+	for _, s := range m.VertexBuffer.Streams {
+		s.Data = nil
+	}
+	m.IndexBuffer.Indices = nil
+	_, err := m.Export(ctx, path.MeshFormat_GLTF)
+	assert.With(ctx).ThatError(err).HasMessage("Mesh has no vertices")
+}
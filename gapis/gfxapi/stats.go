@@ -0,0 +1,35 @@
+// Copyright (C) 2017 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gfxapi
+
+import "github.com/google/gapid/core/log"
+
+// StatsProvider is the optional interface implemented by APIs that can
+// describe the work performed by their commands, for capture statistics.
+type StatsProvider interface {
+	// CommandStats returns the statistics of the command o, given the state s
+	// before o is mutated.
+	CommandStats(ctx log.Context, o interface{}, s *State) (CommandStats, error)
+}
+
+// CommandStats describes the work performed by a single command.
+type CommandStats struct {
+	Primitives         uint64 // The number of primitives drawn.
+	Vertices           uint64 // The number of vertices drawn.
+	StateChange        string // The category of the state changed, or "" if none.
+	TextureUploadBytes uint64 // The number of bytes uploaded to textures.
+	BufferUploadBytes  uint64 // The number of bytes uploaded to buffers.
+	ShaderCompile      bool   // True if the command compiles a shader.
+}
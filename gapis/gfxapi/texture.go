@@ -104,16 +104,148 @@ func (t *Cubemap) ConvertTo(ctx log.Context, f *image.Format) (interface{}, erro
 		Levels: make([]*CubemapLevel, len(t.Levels)),
 	}
 	for i, m := range t.Levels {
-		out.Levels[i] = &CubemapLevel{}
-		dst, src := out.Levels[i].faces(), m.faces()
-		for j := range src {
-			if obj, err := src[j].ConvertTo(ctx, f); err == nil {
-				dst[j] = obj
-			} else {
+		if obj, err := m.convertTo(ctx, f); err == nil {
+			out.Levels[i] = obj
+		} else {
+			return nil, err
+		}
+	}
+	return out, nil
+}
+
+func (l *CubemapLevel) convertTo(ctx log.Context, f *image.Format) (*CubemapLevel, error) {
+	out := &CubemapLevel{}
+	dst, src := out.faces(), l.faces()
+	for j := range src {
+		if obj, err := src[j].ConvertTo(ctx, f); err == nil {
+			dst[j] = obj
+		} else {
+			return nil, err
+		}
+	}
+	out.setFaces(dst)
+	return out, nil
+}
+
+// convertImages returns the list of images converted to the requested format.
+func convertImages(ctx log.Context, f *image.Format, images []*image.Info2D) ([]*image.Info2D, error) {
+	out := make([]*image.Info2D, len(images))
+	for i, m := range images {
+		if obj, err := m.ConvertTo(ctx, f); err == nil {
+			out[i] = obj
+		} else {
+			return nil, err
+		}
+	}
+	return out, nil
+}
+
+// Thumbnail returns the image that most closely matches the desired size.
+func (t *Texture1D) Thumbnail(ctx log.Context, w, h uint32) (*image.Info2D, error) {
+	m := imageMatcher{width: w, height: h}
+	for _, l := range t.Levels {
+		m.consider(l)
+	}
+
+	return m.best, nil
+}
+
+// ConvertTo returns this Texture1D with each mip-level converted to the requested format.
+func (t *Texture1D) ConvertTo(ctx log.Context, f *image.Format) (interface{}, error) {
+	levels, err := convertImages(ctx, f, t.Levels)
+	if err != nil {
+		return nil, err
+	}
+	return &Texture1D{Levels: levels}, nil
+}
+
+// Thumbnail returns the middle depth slice of the level that most closely
+// matches the desired size.
+func (t *Texture3D) Thumbnail(ctx log.Context, w, h uint32) (*image.Info2D, error) {
+	m := imageMatcher{width: w, height: h}
+	for _, l := range t.Levels {
+		if len(l.Slices) > 0 {
+			m.consider(l.Slices[len(l.Slices)/2])
+		}
+	}
+
+	return m.best, nil
+}
+
+// ConvertTo returns this Texture3D with each mip-level slice converted to the requested format.
+func (t *Texture3D) ConvertTo(ctx log.Context, f *image.Format) (interface{}, error) {
+	out := &Texture3D{
+		Levels: make([]*Texture3DLevel, len(t.Levels)),
+	}
+	for i, m := range t.Levels {
+		slices, err := convertImages(ctx, f, m.Slices)
+		if err != nil {
+			return nil, err
+		}
+		out.Levels[i] = &Texture3DLevel{Slices: slices}
+	}
+	return out, nil
+}
+
+// Thumbnail returns the first layer of the level that most closely matches
+// the desired size.
+func (t *Texture2DArray) Thumbnail(ctx log.Context, w, h uint32) (*image.Info2D, error) {
+	m := imageMatcher{width: w, height: h}
+	for _, l := range t.Levels {
+		if len(l.Layers) > 0 {
+			m.consider(l.Layers[0])
+		}
+	}
+
+	return m.best, nil
+}
+
+// ConvertTo returns this Texture2DArray with each mip-level layer converted to the requested format.
+func (t *Texture2DArray) ConvertTo(ctx log.Context, f *image.Format) (interface{}, error) {
+	out := &Texture2DArray{
+		Levels: make([]*Texture2DArrayLevel, len(t.Levels)),
+	}
+	for i, m := range t.Levels {
+		layers, err := convertImages(ctx, f, m.Layers)
+		if err != nil {
+			return nil, err
+		}
+		out.Levels[i] = &Texture2DArrayLevel{Layers: layers}
+	}
+	return out, nil
+}
+
+// Thumbnail returns the face of the first layer that most closely matches the
+// desired size.
+func (t *CubemapArray) Thumbnail(ctx log.Context, w, h uint32) (*image.Info2D, error) {
+	m := imageMatcher{width: w, height: h}
+	for _, l := range t.Levels {
+		if len(l.Layers) > 0 {
+			for _, face := range l.Layers[0].faces() {
+				if face != nil {
+					m.consider(face)
+				}
+			}
+		}
+	}
+
+	return m.best, nil
+}
+
+// ConvertTo returns this CubemapArray with each mip-level layer face converted to the requested format.
+func (t *CubemapArray) ConvertTo(ctx log.Context, f *image.Format) (interface{}, error) {
+	out := &CubemapArray{
+		Levels: make([]*CubemapArrayLevel, len(t.Levels)),
+	}
+	for i, m := range t.Levels {
+		out.Levels[i] = &CubemapArrayLevel{Layers: make([]*CubemapLevel, len(m.Layers))}
+		for j, layer := range m.Layers {
+			obj, err := layer.convertTo(ctx, f)
+			if err != nil {
 				return nil, err
 			}
+			out.Levels[i].Layers[j] = obj
 		}
-		out.Levels[i].setFaces(dst)
 	}
 	return out, nil
 }
@@ -18,16 +18,57 @@ import (
 	"testing"
 
 	"github.com/google/gapid/core/image"
+	"github.com/google/gapid/core/log"
 	"github.com/google/gapid/gapis/gfxapi"
 )
 
-// TestNothing exists because some tools don't like packages that don't have any tests in them.
-func TestNothing(t *testing.T) {}
-
 // interface compliance test
 var (
 	_ = []image.Thumbnailer{
+		(*gfxapi.Texture1D)(nil),
 		(*gfxapi.Texture2D)(nil),
+		(*gfxapi.Texture3D)(nil),
+		(*gfxapi.Texture2DArray)(nil),
 		(*gfxapi.Cubemap)(nil),
+		(*gfxapi.CubemapArray)(nil),
 	}
 )
+
+func TestLayeredThumbnail(t *testing.T) {
+	ctx := log.Testing(t)
+	img := func(w, h uint32) *image.Info2D { return &image.Info2D{Width: w, Height: h} }
+	front, middle, back := img(8, 8), img(8, 8), img(8, 8)
+	small := img(4, 4)
+
+	for _, test := range []struct {
+		name     string
+		texture  image.Thumbnailer
+		w, h     uint32
+		expected *image.Info2D
+	}{
+		{"3D middle slice", &gfxapi.Texture3D{Levels: []*gfxapi.Texture3DLevel{
+			{Slices: []*image.Info2D{front, middle, back}},
+			{Slices: []*image.Info2D{small}},
+		}}, 8, 8, middle},
+		{"3D closest level", &gfxapi.Texture3D{Levels: []*gfxapi.Texture3DLevel{
+			{Slices: []*image.Info2D{front, middle, back}},
+			{Slices: []*image.Info2D{small}},
+		}}, 4, 4, small},
+		{"2D array first layer", &gfxapi.Texture2DArray{Levels: []*gfxapi.Texture2DArrayLevel{
+			{Layers: []*image.Info2D{front, middle, back}},
+		}}, 8, 8, front},
+		{"cubemap array first layer", &gfxapi.CubemapArray{Levels: []*gfxapi.CubemapArrayLevel{
+			{Layers: []*gfxapi.CubemapLevel{{PositiveX: front}, {PositiveX: back}}},
+		}}, 8, 8, front},
+		{"empty", &gfxapi.Texture2DArray{}, 8, 8, nil},
+	} {
+		got, err := test.texture.Thumbnail(ctx, test.w, test.h)
+		if err != nil {
+			t.Errorf("%v: Thumbnail returned error: %v", test.name, err)
+			continue
+		}
+		if got != test.expected {
+			t.Errorf("%v: Thumbnail returned %v, expected %v", test.name, got, test.expected)
+		}
+	}
+}
@@ -22,12 +22,15 @@ set(files
     buffer_command.go
     convert.go
     custom_replay.go
+    draw_call_state.go
+    draw_call_state_test.go
     enum.go
     externs.go
     find_issues.go
     mutate.go
     read_framebuffer.go
     replay.go
+    replay_test.go
     resources.go
     snippets_embed.go
     state.go
@@ -0,0 +1,155 @@
+// Copyright (C) 2017 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package vulkan
+
+import (
+	"sort"
+
+	"github.com/google/gapid/core/log"
+	"github.com/google/gapid/gapis/gfxapi"
+	"github.com/google/gapid/gapis/service/path"
+)
+
+// DrawCallState implements the gfxapi.DrawCallStateProvider interface.
+// As commands recorded into command buffers are only executed on submission,
+// the returned state is that of the last draw executed by the command buffers
+// submitted by the vkQueueSubmit o.
+// Pipelines are not resources, so the program is left unset. Uniform values
+// are held in buffers and push constants and are not reported.
+func (api) DrawCallState(ctx log.Context, o interface{}, s *gfxapi.State, resources gfxapi.ResourceMap) (*gfxapi.DrawCallState, error) {
+	if _, ok := o.(*VkQueueSubmit); !ok {
+		return nil, nil
+	}
+	st := GetState(s)
+	pipeline := st.CurrentGraphicsPipeline
+	if pipeline == nil {
+		return nil, nil
+	}
+
+	out := &gfxapi.DrawCallState{}
+
+	stages := make([]int, 0, len(pipeline.Stages))
+	for i := range pipeline.Stages {
+		stages = append(stages, int(i))
+	}
+	sort.Ints(stages)
+	for _, i := range stages {
+		if id := resourceID(resources, pipeline.Stages[uint32(i)].Module); id != nil {
+			out.Shaders = append(out.Shaders, id)
+		}
+	}
+
+	for set, descriptors := range st.CurrentDescriptorSets {
+		if descriptors == nil {
+			continue
+		}
+		for binding, b := range descriptors.Bindings {
+			for element, info := range b.ImageBinding {
+				if info == nil {
+					continue
+				}
+				out.Textures = append(out.Textures, st.textureBinding(set, binding, element, descriptors, info, resources))
+			}
+		}
+	}
+	sort.Slice(out.Textures, func(i, j int) bool {
+		a, b := out.Textures[i], out.Textures[j]
+		if a.Set != b.Set {
+			return a.Set < b.Set
+		}
+		return a.Unit < b.Unit
+	})
+
+	bindings := pipeline.VertexInputState.BindingDescriptions
+	for _, attr := range pipeline.VertexInputState.AttributeDescriptions {
+		binding := &gfxapi.VertexAttributeBinding{
+			Location: attr.Location,
+			Type:     attr.Format.String(),
+			Offset:   uint64(attr.Offset),
+		}
+		for _, desc := range bindings {
+			if desc.Binding != attr.Binding {
+				continue
+			}
+			binding.Stride = desc.Stride
+			if desc.InputRate == VkVertexInputRate_VK_VERTEX_INPUT_RATE_INSTANCE {
+				binding.Divisor = 1
+			}
+		}
+		if vb, ok := st.CurrentVertexBuffers[attr.Binding]; ok && vb.Buffer != nil {
+			binding.Enabled = true
+			binding.Buffer = uint64(vb.Buffer.VulkanHandle)
+			binding.Offset += uint64(vb.Offset)
+		}
+		out.Attributes = append(out.Attributes, binding)
+	}
+	sort.Slice(out.Attributes, func(i, j int) bool {
+		return out.Attributes[i].Location < out.Attributes[j].Location
+	})
+
+	return out, nil
+}
+
+// textureBinding returns the image and sampling parameters of the image
+// descriptor info at the given array element of the binding in the descriptor
+// set bound to set.
+func (st *State) textureBinding(set, binding, element uint32, descriptors *DescriptorSetObject, info *VkDescriptorImageInfo, resources gfxapi.ResourceMap) *gfxapi.TextureBinding {
+	out := &gfxapi.TextureBinding{Set: set, Unit: binding}
+	if view, ok := st.ImageViews[info.ImageView]; ok && view.Image != nil {
+		out.Texture = resourceID(resources, view.Image)
+	}
+	sampler, ok := st.Samplers[info.Sampler]
+	if !ok && descriptors.Layout != nil {
+		// The sampler may be baked into the descriptor set layout.
+		if b, ok := descriptors.Layout.Bindings[binding]; ok {
+			sampler = b.ImmutableSamplers[element]
+		}
+	}
+	if sampler != nil {
+		out.Sampler = samplerState(sampler)
+	}
+	return out
+}
+
+// samplerState returns the sampling parameters held by the sampler object.
+func samplerState(s *SamplerObject) *gfxapi.SamplerState {
+	out := &gfxapi.SamplerState{
+		MinFilter:  s.MinFilter.String(),
+		MagFilter:  s.MagFilter.String(),
+		MipmapMode: s.MipMapMode.String(),
+		WrapU:      s.AddressModeU.String(),
+		WrapV:      s.AddressModeV.String(),
+		WrapW:      s.AddressModeW.String(),
+		MinLod:     s.MinLod,
+		MaxLod:     s.MaxLod,
+	}
+	out.MaxAnisotropy = 1
+	if s.AnisotropyEnable != 0 {
+		out.MaxAnisotropy = s.MaxAnisotropy
+	}
+	if s.CompareEnable != 0 {
+		out.CompareFunc = s.CompareOp.String()
+	}
+	return out
+}
+
+// resourceID returns the path to the identifier of the resource r, or nil if
+// r is not a tracked resource.
+func resourceID(resources gfxapi.ResourceMap, r gfxapi.Resource) *path.ID {
+	if id, ok := resources[r]; ok {
+		return path.NewID(id)
+	}
+	return nil
+}
@@ -0,0 +1,216 @@
+// Copyright (C) 2017 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package vulkan
+
+import (
+	"testing"
+
+	"github.com/google/gapid/core/assert"
+	"github.com/google/gapid/core/data/id"
+	"github.com/google/gapid/core/log"
+	"github.com/google/gapid/gapis/gfxapi"
+	"github.com/google/gapid/gapis/service/path"
+)
+
+func TestCurrentDescriptorSets(t *testing.T) {
+	ctx := log.Testing(t)
+	s := gfxapi.NewStateWithEmptyAllocator()
+	st := GetState(s)
+
+	a := &DescriptorSetObject{VulkanHandle: 1}
+	b := &DescriptorSetObject{VulkanHandle: 2}
+	c := &DescriptorSetObject{VulkanHandle: 3}
+
+	for _, test := range []struct {
+		name     string
+		bind     U32ːDescriptorSetObjectʳᵐ
+		expected map[uint32]*DescriptorSetObject
+	}{
+		{"first bind", U32ːDescriptorSetObjectʳᵐ{0: a, 1: b},
+			map[uint32]*DescriptorSetObject{0: a, 1: b}},
+		{"replace set 1", U32ːDescriptorSetObjectʳᵐ{1: c},
+			map[uint32]*DescriptorSetObject{0: a, 1: c}},
+		{"no sets", U32ːDescriptorSetObjectʳᵐ{},
+			map[uint32]*DescriptorSetObject{0: a, 1: c}},
+	} {
+		err := subDoCmdBindBuffers(ctx, nil, nil, s, st, nil, CmdBindBuffer{DescriptorSets: test.bind})
+		assert.For(ctx, "%s err", test.name).ThatError(err).Succeeded()
+		got := map[uint32]*DescriptorSetObject{}
+		for set, descriptors := range st.CurrentDescriptorSets {
+			got[set] = descriptors
+		}
+		assert.For(ctx, test.name).That(got).DeepEquals(test.expected)
+	}
+}
+
+func TestDrawCallState(t *testing.T) {
+	ctx := log.Testing(t)
+	s := gfxapi.NewStateWithEmptyAllocator()
+	st := GetState(s)
+
+	submit := &VkQueueSubmit{}
+	for _, test := range []struct {
+		name string
+		o    interface{}
+	}{
+		{"not a submit", &VkCmdDraw{}},
+		{"no pipeline", submit},
+	} {
+		got, err := api{}.DrawCallState(ctx, test.o, s, gfxapi.ResourceMap{})
+		assert.For(ctx, test.name).That(got).IsNil()
+		assert.For(ctx, test.name).ThatError(err).Succeeded()
+	}
+
+	vertex := &ShaderModuleObject{VulkanHandle: 1}
+	fragment := &ShaderModuleObject{VulkanHandle: 2}
+	image := &ImageObject{VulkanHandle: 3}
+	st.ImageViews[4] = &ImageViewObject{VulkanHandle: 4, Image: image}
+	st.Samplers[5] = &SamplerObject{
+		VulkanHandle:     5,
+		MinFilter:        VkFilter_VK_FILTER_LINEAR,
+		MagFilter:        VkFilter_VK_FILTER_NEAREST,
+		MipMapMode:       VkSamplerMipmapMode_VK_SAMPLER_MIPMAP_MODE_LINEAR,
+		AddressModeU:     VkSamplerAddressMode_VK_SAMPLER_ADDRESS_MODE_REPEAT,
+		AddressModeV:     VkSamplerAddressMode_VK_SAMPLER_ADDRESS_MODE_CLAMP_TO_EDGE,
+		AddressModeW:     VkSamplerAddressMode_VK_SAMPLER_ADDRESS_MODE_MIRRORED_REPEAT,
+		AnisotropyEnable: 1,
+		MaxAnisotropy:    16,
+		MinLod:           1,
+		MaxLod:           8,
+	}
+	immutable := &SamplerObject{
+		VulkanHandle:  6,
+		MinFilter:     VkFilter_VK_FILTER_NEAREST,
+		MagFilter:     VkFilter_VK_FILTER_NEAREST,
+		MipMapMode:    VkSamplerMipmapMode_VK_SAMPLER_MIPMAP_MODE_NEAREST,
+		AddressModeU:  VkSamplerAddressMode_VK_SAMPLER_ADDRESS_MODE_REPEAT,
+		AddressModeV:  VkSamplerAddressMode_VK_SAMPLER_ADDRESS_MODE_REPEAT,
+		AddressModeW:  VkSamplerAddressMode_VK_SAMPLER_ADDRESS_MODE_REPEAT,
+		MaxAnisotropy: 16, // Ignored as anisotropy is disabled.
+		CompareEnable: 1,
+		CompareOp:     VkCompareOp_VK_COMPARE_OP_LESS,
+	}
+
+	st.CurrentDescriptorSets[0] = &DescriptorSetObject{
+		VulkanHandle: 7,
+		Bindings: U32ːDescriptorBindingᵐ{
+			0: {ImageBinding: U32ːVkDescriptorImageInfoʳᵐ{
+				0: {Sampler: 5, ImageView: 4},
+			}},
+		},
+	}
+	st.CurrentDescriptorSets[2] = &DescriptorSetObject{
+		VulkanHandle: 8,
+		Bindings: U32ːDescriptorBindingᵐ{
+			1: {ImageBinding: U32ːVkDescriptorImageInfoʳᵐ{
+				0: nil,                         // Not written.
+				1: {Sampler: 0, ImageView: 99}, // Unknown view, immutable sampler.
+			}},
+		},
+		Layout: &DescriptorSetLayoutObject{
+			Bindings: U32ːDescriptorSetLayoutBindingᵐ{
+				1: {ImmutableSamplers: U32ːSamplerObjectʳᵐ{1: immutable}},
+			},
+		},
+	}
+
+	st.CurrentVertexBuffers[0] = BoundBuffer{Buffer: &BufferObject{VulkanHandle: 9}, Offset: 64}
+	st.CurrentGraphicsPipeline = &GraphicsPipelineObject{
+		Stages: U32ːStageDataᵐ{
+			1: {Module: fragment},
+			0: {Module: vertex},
+		},
+		VertexInputState: VertexData{
+			BindingDescriptions: U32ːVkVertexInputBindingDescriptionᵐ{
+				0: {Binding: 0, Stride: 20, InputRate: VkVertexInputRate_VK_VERTEX_INPUT_RATE_VERTEX},
+				1: {Binding: 1, Stride: 16, InputRate: VkVertexInputRate_VK_VERTEX_INPUT_RATE_INSTANCE},
+			},
+			AttributeDescriptions: U32ːVkVertexInputAttributeDescriptionᵐ{
+				0: {Location: 2, Binding: 1, Format: VkFormat_VK_FORMAT_R32G32B32A32_SFLOAT, Offset: 0},
+				1: {Location: 0, Binding: 0, Format: VkFormat_VK_FORMAT_R32G32B32_SFLOAT, Offset: 0},
+				2: {Location: 1, Binding: 0, Format: VkFormat_VK_FORMAT_R32G32_SFLOAT, Offset: 12},
+			},
+		},
+	}
+
+	resources := gfxapi.ResourceMap{
+		vertex:   id.ID{1},
+		fragment: id.ID{2},
+		image:    id.ID{3},
+	}
+	got, err := api{}.DrawCallState(ctx, submit, s, resources)
+	if !assert.For(ctx, "err").ThatError(err).Succeeded() {
+		return
+	}
+	assert.For(ctx, "program").That(got.Program).IsNil()
+	assert.For(ctx, "uniforms").ThatSlice(got.Uniforms).IsEmpty()
+	assert.For(ctx, "shaders").ThatSlice(got.Shaders).DeepEquals([]*path.ID{
+		path.NewID(id.ID{1}), path.NewID(id.ID{2}),
+	})
+	assert.For(ctx, "textures").ThatSlice(got.Textures).DeepEquals([]*gfxapi.TextureBinding{
+		{
+			Set:     0,
+			Unit:    0,
+			Texture: path.NewID(id.ID{3}),
+			Sampler: &gfxapi.SamplerState{
+				MinFilter:     VkFilter_VK_FILTER_LINEAR.String(),
+				MagFilter:     VkFilter_VK_FILTER_NEAREST.String(),
+				MipmapMode:    VkSamplerMipmapMode_VK_SAMPLER_MIPMAP_MODE_LINEAR.String(),
+				WrapU:         VkSamplerAddressMode_VK_SAMPLER_ADDRESS_MODE_REPEAT.String(),
+				WrapV:         VkSamplerAddressMode_VK_SAMPLER_ADDRESS_MODE_CLAMP_TO_EDGE.String(),
+				WrapW:         VkSamplerAddressMode_VK_SAMPLER_ADDRESS_MODE_MIRRORED_REPEAT.String(),
+				MinLod:        1,
+				MaxLod:        8,
+				MaxAnisotropy: 16,
+			},
+		}, {
+			Set:  2,
+			Unit: 1,
+			Sampler: &gfxapi.SamplerState{
+				MinFilter:     VkFilter_VK_FILTER_NEAREST.String(),
+				MagFilter:     VkFilter_VK_FILTER_NEAREST.String(),
+				MipmapMode:    VkSamplerMipmapMode_VK_SAMPLER_MIPMAP_MODE_NEAREST.String(),
+				WrapU:         VkSamplerAddressMode_VK_SAMPLER_ADDRESS_MODE_REPEAT.String(),
+				WrapV:         VkSamplerAddressMode_VK_SAMPLER_ADDRESS_MODE_REPEAT.String(),
+				WrapW:         VkSamplerAddressMode_VK_SAMPLER_ADDRESS_MODE_REPEAT.String(),
+				CompareFunc:   VkCompareOp_VK_COMPARE_OP_LESS.String(),
+				MaxAnisotropy: 1,
+			},
+		},
+	})
+	assert.For(ctx, "attributes").ThatSlice(got.Attributes).DeepEquals([]*gfxapi.VertexAttributeBinding{
+		{
+			Location: 0,
+			Enabled:  true,
+			Type:     VkFormat_VK_FORMAT_R32G32B32_SFLOAT.String(),
+			Buffer:   9,
+			Offset:   64,
+			Stride:   20,
+		}, {
+			Location: 1,
+			Enabled:  true,
+			Type:     VkFormat_VK_FORMAT_R32G32_SFLOAT.String(),
+			Buffer:   9,
+			Offset:   64 + 12,
+			Stride:   20,
+		}, {
+			// Binding 1 has no vertex buffer bound.
+			Location: 2,
+			Type:     VkFormat_VK_FORMAT_R32G32B32A32_SFLOAT.String(),
+			Stride:   16,
+			Divisor:  1,
+		},
+	})
+}
@@ -68,26 +68,26 @@ func (t *readFramebuffer) Transform(ctx log.Context, id atom.ID, a atom.Atom, ou
 
 func (t *readFramebuffer) Flush(ctx log.Context, out transform.Writer) {}
 
-func (t *readFramebuffer) Depth(id atom.ID, res chan<- imgRes) {
+func (t *readFramebuffer) Depth(id atom.ID, layer *gfxapi.FramebufferLayer, res chan<- imgRes) {
 	t.injections[id] = append(t.injections[id], func(ctx log.Context, out transform.Writer) {
 		s := out.State()
 		attachment := gfxapi.FramebufferAttachment_Depth
-		w, h, form, attachmentIndex, err := GetState(s).getFramebufferAttachmentInfo(attachment)
+		w, h, form, attachmentIndex, l, err := GetState(s).getFramebufferAttachmentLayerInfo(attachment, layer)
 		if err != nil {
 			res <- imgRes{err: &service.ErrDataUnavailable{Reason: messages.ErrMessage("Invalid Depth attachment")}}
 			return
 		}
 		imageViewDepth := GetState(s).LastUsedFramebuffer.ImageAttachments[attachmentIndex]
 		depthImageObject := imageViewDepth.Image
-		postImageData(ctx, s, depthImageObject, form, VkImageAspectFlagBits_VK_IMAGE_ASPECT_DEPTH_BIT, w, h, w, h, out, func(i imgRes) { res <- i })
+		postImageData(ctx, s, depthImageObject, form, VkImageAspectFlagBits_VK_IMAGE_ASPECT_DEPTH_BIT, l, w, h, w, h, out, func(i imgRes) { res <- i })
 	})
 }
 
-func (t *readFramebuffer) Color(id atom.ID, width, height, bufferIdx uint32, res chan<- imgRes) {
+func (t *readFramebuffer) Color(id atom.ID, width, height, bufferIdx uint32, layer *gfxapi.FramebufferLayer, res chan<- imgRes) {
 	t.injections[id] = append(t.injections[id], func(ctx log.Context, out transform.Writer) {
 		s := out.State()
 		attachment := gfxapi.FramebufferAttachment_Color0 + gfxapi.FramebufferAttachment(bufferIdx)
-		w, h, form, attachmentIndex, err := GetState(s).getFramebufferAttachmentInfo(attachment)
+		w, h, form, attachmentIndex, l, err := GetState(s).getFramebufferAttachmentLayerInfo(attachment, layer)
 		if err != nil {
 			res <- imgRes{err: &service.ErrDataUnavailable{Reason: messages.ErrMessage("Invalid Color attachment")}}
 			return
@@ -96,7 +96,7 @@ func (t *readFramebuffer) Color(id atom.ID, width, height, bufferIdx uint32, res
 		// TODO: Figure out a better way to select the framebuffer here.
 		imageView := GetState(s).LastUsedFramebuffer.ImageAttachments[attachmentIndex]
 		imageObject := imageView.Image
-		postImageData(ctx, s, imageObject, form, VkImageAspectFlagBits_VK_IMAGE_ASPECT_COLOR_BIT, w, h, width, height, out, func(i imgRes) { res <- i })
+		postImageData(ctx, s, imageObject, form, VkImageAspectFlagBits_VK_IMAGE_ASPECT_COLOR_BIT, l, w, h, width, height, out, func(i imgRes) { res <- i })
 	})
 }
 
@@ -123,6 +123,7 @@ func postImageData(ctx log.Context,
 	imageObject *ImageObject,
 	vkFormat VkFormat,
 	aspectMask VkImageAspectFlagBits,
+	layer gfxapi.FramebufferLayer,
 	imgWidth,
 	imgHeight,
 	reqWidth,
@@ -487,9 +488,9 @@ func postImageData(ctx log.Context,
 		Image:               imageObject.VulkanHandle,
 		SubresourceRange: VkImageSubresourceRange{
 			AspectMask:     VkImageAspectFlags(aspectMask),
-			BaseMipLevel:   0,
+			BaseMipLevel:   layer.Level,
 			LevelCount:     1,
-			BaseArrayLayer: 0,
+			BaseArrayLayer: layer.Layer,
 			LayerCount:     1,
 		},
 	}
@@ -514,9 +515,9 @@ func postImageData(ctx log.Context,
 		Image:               imageObject.VulkanHandle,
 		SubresourceRange: VkImageSubresourceRange{
 			AspectMask:     VkImageAspectFlags(aspectMask),
-			BaseMipLevel:   0,
+			BaseMipLevel:   layer.Level,
 			LevelCount:     1,
-			BaseArrayLayer: 0,
+			BaseArrayLayer: layer.Layer,
 			LayerCount:     1,
 		},
 	}
@@ -526,8 +527,8 @@ func postImageData(ctx log.Context,
 	imageBlit := VkImageBlit{
 		SrcSubresource: VkImageSubresourceLayers{
 			AspectMask:     VkImageAspectFlags(aspectMask),
-			MipLevel:       0,
-			BaseArrayLayer: 0,
+			MipLevel:       layer.Level,
+			BaseArrayLayer: layer.Layer,
 			LayerCount:     1,
 		},
 		SrcOffsets: VkOffset3Dː2ᵃ{
@@ -571,8 +572,8 @@ func postImageData(ctx log.Context,
 	imageResolve := VkImageResolve{
 		SrcSubresource: VkImageSubresourceLayers{
 			AspectMask:     VkImageAspectFlags(aspectMask),
-			MipLevel:       0,
-			BaseArrayLayer: 0,
+			MipLevel:       layer.Level,
+			BaseArrayLayer: layer.Layer,
 			LayerCount:     1,
 		},
 		SrcOffset: VkOffset3D{
@@ -29,7 +29,9 @@ import (
 	"github.com/google/gapid/gapis/config"
 	"github.com/google/gapid/gapis/gfxapi"
 	"github.com/google/gapid/gapis/memory"
+	"github.com/google/gapid/gapis/messages"
 	"github.com/google/gapid/gapis/replay"
+	"github.com/google/gapid/gapis/service"
 )
 
 var (
@@ -69,6 +71,7 @@ type framebufferRequest struct {
 	after            atom.ID
 	width, height    uint32
 	attachment       gfxapi.FramebufferAttachment
+	layer            *gfxapi.FramebufferLayer
 	out              chan imgRes
 	wireframeOverlay bool
 }
@@ -374,12 +377,12 @@ func (a api) Replay(
 			earlyTerminator.Add(req.after)
 			switch req.attachment {
 			case gfxapi.FramebufferAttachment_Depth:
-				readFramebuffer.Depth(req.after, req.out)
+				readFramebuffer.Depth(req.after, req.layer, req.out)
 			case gfxapi.FramebufferAttachment_Stencil:
 				return fmt.Errorf("Stencil attachments are not currently supported")
 			default:
-				idx := uint32(req.attachment - gfxapi.FramebufferAttachment_Color0)
-				readFramebuffer.Color(req.after, req.width, req.height, idx, req.out)
+				idx := uint32(req.attachment.ColorIndex())
+				readFramebuffer.Color(req.after, req.width, req.height, idx, req.layer, req.out)
 			}
 		}
 	}
@@ -418,17 +421,16 @@ func (a api) Replay(
 		transforms = newTransforms
 	}
 
-	return catchPanics(ctx, func() { transforms.Transform(ctx, *atoms, out) })
+	return catchPanics(ctx, func() error { return transforms.Transform(ctx, *atoms, out) })
 }
 
-func catchPanics(ctx log.Context, do func()) (err error) {
+func catchPanics(ctx log.Context, do func() error) (err error) {
 	defer func() {
 		if e := recover(); e != nil {
 			err = cause.Explainf(ctx, nil, "Panic raised: %v", e)
 		}
 	}()
-	do()
-	return nil
+	return do()
 }
 
 func (a api) QueryFramebufferAttachment(
@@ -438,11 +440,20 @@ func (a api) QueryFramebufferAttachment(
 	after atom.ID,
 	width, height uint32,
 	attachment gfxapi.FramebufferAttachment,
-	wireframeMode replay.WireframeMode) (*image.Image2D, error) {
+	layer *gfxapi.FramebufferLayer,
+	wireframeMode replay.WireframeMode,
+	drawMode replay.DrawMode) (*image.Image2D, error) {
+
+	if drawMode != replay.DrawMode_Normal {
+		// The alternate draw modes are not implemented for Vulkan.
+		return nil, &service.ErrDataUnavailable{
+			Reason: messages.ErrDrawModeNotSupported(drawMode.String(), "Vulkan"),
+		}
+	}
 
 	c := drawConfig{}
 	out := make(chan imgRes, 1)
-	r := framebufferRequest{after: after, width: width, height: height, attachment: attachment, out: out}
+	r := framebufferRequest{after: after, width: width, height: height, attachment: attachment, layer: layer, out: out}
 	if err := mgr.Replay(ctx, intent, c, r, a); err != nil {
 		return nil, err
 	}
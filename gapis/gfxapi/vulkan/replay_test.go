@@ -0,0 +1,42 @@
+// Copyright (C) 2017 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package vulkan
+
+import (
+	"testing"
+
+	"github.com/google/gapid/core/assert"
+	"github.com/google/gapid/core/log"
+	"github.com/google/gapid/gapis/gfxapi"
+	"github.com/google/gapid/gapis/messages"
+	"github.com/google/gapid/gapis/replay"
+	"github.com/google/gapid/gapis/service"
+)
+
+func TestDrawModesNotSupported(t *testing.T) {
+	ctx := log.Testing(t)
+	for _, mode := range []replay.DrawMode{
+		replay.DrawMode_Overdraw,
+		replay.DrawMode_DepthComplexity,
+		replay.DrawMode_Highlight,
+	} {
+		// The draw mode is rejected before anything is replayed.
+		_, err := api{}.QueryFramebufferAttachment(ctx, replay.Intent{}, nil, 0, 64, 64,
+			gfxapi.FramebufferAttachment_Color0, nil, replay.WireframeMode_None, mode)
+		assert.For(ctx, "%v", mode).ThatError(err).DeepEquals(&service.ErrDataUnavailable{
+			Reason: messages.ErrDrawModeNotSupported(mode.String(), "Vulkan"),
+		})
+	}
+}
@@ -50,11 +50,32 @@ func (t *ImageObject) ResourceName() string {
 
 // ResourceType returns the type of this resource.
 func (t *ImageObject) ResourceType() gfxapi.ResourceType {
-	if uint32(t.Info.Flags)&uint32(VkImageCreateFlagBits_VK_IMAGE_CREATE_CUBE_COMPATIBLE_BIT) != 0 {
+	switch t.Info.ImageType {
+	case VkImageType_VK_IMAGE_TYPE_1D:
+		if t.Info.ArrayLayers > 1 {
+			return gfxapi.ResourceType_Texture2DArrayResource
+		}
+		return gfxapi.ResourceType_Texture1DResource
+	case VkImageType_VK_IMAGE_TYPE_3D:
+		return gfxapi.ResourceType_Texture3DResource
+	}
+	if t.isCubemap() {
+		if t.Info.ArrayLayers > 6 {
+			return gfxapi.ResourceType_CubemapArrayResource
+		}
 		return gfxapi.ResourceType_CubemapResource
-	} else {
-		return gfxapi.ResourceType_Texture2DResource
 	}
+	if t.Info.ArrayLayers > 1 {
+		return gfxapi.ResourceType_Texture2DArrayResource
+	}
+	return gfxapi.ResourceType_Texture2DResource
+}
+
+// isCubemap returns true if the image has VK_IMAGE_CREATE_CUBE_COMPATIBLE_BIT
+// set and has a multiple of six layers to represent the cube faces.
+func (t *ImageObject) isCubemap() bool {
+	return uint32(t.Info.Flags)&uint32(VkImageCreateFlagBits_VK_IMAGE_CREATE_CUBE_COMPATIBLE_BIT) != 0 &&
+		t.Info.ArrayLayers >= 6 && t.Info.ArrayLayers%6 == 0
 }
 
 type unsupportedVulkanFormatError struct {
@@ -81,6 +102,30 @@ func getImageFormatFromVulkanFormat(vkfmt VkFormat) (*image.Format, error) {
 		return image.NewS3_DXT3_RGBA("VK_FORMAT_BC2_UNORM_BLOCK"), nil
 	case VkFormat_VK_FORMAT_BC3_UNORM_BLOCK:
 		return image.NewS3_DXT5_RGBA("VK_FORMAT_BC3_UNORM_BLOCK"), nil
+	case VkFormat_VK_FORMAT_BC4_UNORM_BLOCK:
+		return image.NewRGTC1_BC4_R_U8_NORM("VK_FORMAT_BC4_UNORM_BLOCK"), nil
+	case VkFormat_VK_FORMAT_BC4_SNORM_BLOCK:
+		return image.NewRGTC1_BC4_R_S8_NORM("VK_FORMAT_BC4_SNORM_BLOCK"), nil
+	case VkFormat_VK_FORMAT_BC5_UNORM_BLOCK:
+		return image.NewRGTC2_BC5_RG_U8_NORM("VK_FORMAT_BC5_UNORM_BLOCK"), nil
+	case VkFormat_VK_FORMAT_BC5_SNORM_BLOCK:
+		return image.NewRGTC2_BC5_RG_S8_NORM("VK_FORMAT_BC5_SNORM_BLOCK"), nil
+	case VkFormat_VK_FORMAT_BC6H_UFLOAT_BLOCK:
+		return image.NewBPTC_BC6H_RGB_UFLOAT("VK_FORMAT_BC6H_UFLOAT_BLOCK"), nil
+	case VkFormat_VK_FORMAT_BC6H_SFLOAT_BLOCK:
+		return image.NewBPTC_BC6H_RGB_SFLOAT("VK_FORMAT_BC6H_SFLOAT_BLOCK"), nil
+	case VkFormat_VK_FORMAT_BC7_UNORM_BLOCK:
+		return image.NewBPTC_BC7_RGBA_U8_NORM("VK_FORMAT_BC7_UNORM_BLOCK"), nil
+	case VkFormat_VK_FORMAT_BC7_SRGB_BLOCK:
+		return image.NewBPTC_BC7_SRGB_ALPHA_U8_NORM("VK_FORMAT_BC7_SRGB_BLOCK"), nil
+	case VkFormat_VK_FORMAT_EAC_R11_UNORM_BLOCK:
+		return image.NewETC2_R11_EAC("VK_FORMAT_EAC_R11_UNORM_BLOCK"), nil
+	case VkFormat_VK_FORMAT_EAC_R11_SNORM_BLOCK:
+		return image.NewETC2_SIGNED_R11_EAC("VK_FORMAT_EAC_R11_SNORM_BLOCK"), nil
+	case VkFormat_VK_FORMAT_EAC_R11G11_UNORM_BLOCK:
+		return image.NewETC2_RG11_EAC("VK_FORMAT_EAC_R11G11_UNORM_BLOCK"), nil
+	case VkFormat_VK_FORMAT_EAC_R11G11_SNORM_BLOCK:
+		return image.NewETC2_SIGNED_RG11_EAC("VK_FORMAT_EAC_R11G11_SNORM_BLOCK"), nil
 	case VkFormat_VK_FORMAT_R16G16B16A16_SFLOAT:
 		return image.NewUncompressed("VK_FORMAT_R16G16B16A16_SFLOAT", fmts.RGBA_F16), nil
 	case VkFormat_VK_FORMAT_R8_UNORM:
@@ -168,44 +213,109 @@ func (t *ImageObject) ResourceData(ctx log.Context, s *gfxapi.State, resources g
 		return nil, &service.ErrDataUnavailable{Reason: messages.ErrNoTextureData(t.ResourceName())}
 	}
 	switch t.Info.ImageType {
+	case VkImageType_VK_IMAGE_TYPE_1D:
+		if len(t.Layers) > 1 {
+			return &gfxapi.Texture2DArray{Levels: t.arrayLevels(ctx, s, format)}, nil
+		}
+		return &gfxapi.Texture1D{Levels: t.layerLevels(ctx, s, format, 0)}, nil
+
 	case VkImageType_VK_IMAGE_TYPE_2D:
-		// If this image has VK_IMAGE_CREATE_CUBE_COMPATIBLE_BIT set, it should have six layers to
-		// represent a cubemap
-		if uint32(t.Info.Flags)&uint32(VkImageCreateFlagBits_VK_IMAGE_CREATE_CUBE_COMPATIBLE_BIT) != 0 {
-			// Cubemap
-			ctx.Error().Log("ResourceData for cubemap")
-			cubeMapLevels := make([]*gfxapi.CubemapLevel, len(t.Layers[0].Levels))
+		if t.isCubemap() {
+			// Each cube is stored as six consecutive layers, in the order +X,
+			// -X, +Y, -Y, +Z, -Z.
+			cubes := make([][]*gfxapi.CubemapLevel, len(t.Layers)/6)
+			for i := range cubes {
+				cubes[i] = make([]*gfxapi.CubemapLevel, len(t.Layers[0].Levels))
+				for levelIndex := range cubes[i] {
+					cubes[i][levelIndex] = &gfxapi.CubemapLevel{}
+				}
+			}
 			for layerIndex, imageLayer := range t.Layers {
 				for levelIndex, imageLevel := range imageLayer.Levels {
-					img := &image.Info2D{
-						Format: format,
-						Width:  imageLevel.Width,
-						Height: imageLevel.Height,
-						Data:   image.NewID(imageLevel.Data.ResourceID(ctx, s)),
-					}
-					if !setCubemapFace(img, cubeMapLevels[levelIndex], layerIndex) {
+					img := levelImage(ctx, s, format, imageLevel)
+					if !setCubemapFace(img, cubes[layerIndex/6][levelIndex], layerIndex%6) {
 						return nil, &service.ErrDataUnavailable{Reason: messages.ErrNoTextureData(t.ResourceName())}
 					}
 				}
 			}
-			return &gfxapi.Cubemap{Levels: cubeMapLevels}, nil
-		} else {
-			levels := make([]*image.Info2D, len(t.Layers[0].Levels))
-			for i, level := range t.Layers[0].Levels {
-				levels[i] = &image.Info2D{
+			if len(cubes) == 1 {
+				return &gfxapi.Cubemap{Levels: cubes[0]}, nil
+			}
+			levels := make([]*gfxapi.CubemapArrayLevel, len(t.Layers[0].Levels))
+			for i := range levels {
+				levels[i] = &gfxapi.CubemapArrayLevel{Layers: make([]*gfxapi.CubemapLevel, len(cubes))}
+				for j, cube := range cubes {
+					levels[i].Layers[j] = cube[i]
+				}
+			}
+			return &gfxapi.CubemapArray{Levels: levels}, nil
+		}
+		if len(t.Layers) > 1 {
+			return &gfxapi.Texture2DArray{Levels: t.arrayLevels(ctx, s, format)}, nil
+		}
+		return &gfxapi.Texture2D{Levels: t.layerLevels(ctx, s, format, 0)}, nil
+
+	case VkImageType_VK_IMAGE_TYPE_3D:
+		levels := make([]*gfxapi.Texture3DLevel, len(t.Layers[0].Levels))
+		for i, level := range t.Layers[0].Levels {
+			// The level data holds the depth slices back to back.
+			depth := uint64(level.Depth)
+			if depth == 0 {
+				depth = 1
+			}
+			sliceSize := level.Data.Count / depth
+			slices := make([]*image.Info2D, depth)
+			for z := range slices {
+				slices[z] = &image.Info2D{
 					Format: format,
 					Width:  level.Width,
 					Height: level.Height,
-					Data:   image.NewID(level.Data.ResourceID(ctx, s)),
+					Data:   image.NewID(level.Data.Slice(uint64(z)*sliceSize, uint64(z+1)*sliceSize, s).ResourceID(ctx, s)),
 				}
 			}
-			return &gfxapi.Texture2D{Levels: levels}, nil
+			levels[i] = &gfxapi.Texture3DLevel{Slices: slices}
 		}
+		return &gfxapi.Texture3D{Levels: levels}, nil
+
 	default:
 		return nil, &service.ErrDataUnavailable{Reason: messages.ErrNoTextureData(t.ResourceName())}
 	}
 }
 
+// levelImage returns the image.Info2D describing a single image level.
+func levelImage(ctx log.Context, s *gfxapi.State, format *image.Format, level *ImageLevel) *image.Info2D {
+	return &image.Info2D{
+		Format: format,
+		Width:  level.Width,
+		Height: level.Height,
+		Data:   image.NewID(level.Data.ResourceID(ctx, s)),
+	}
+}
+
+// layerLevels returns the mip-map levels of the given layer of the image.
+func (t *ImageObject) layerLevels(ctx log.Context, s *gfxapi.State, format *image.Format, layer uint32) []*image.Info2D {
+	levels := make([]*image.Info2D, len(t.Layers[layer].Levels))
+	for i, level := range t.Layers[layer].Levels {
+		levels[i] = levelImage(ctx, s, format, level)
+	}
+	return levels
+}
+
+// arrayLevels returns the mip-map levels of the image, each holding all the
+// array layers.
+func (t *ImageObject) arrayLevels(ctx log.Context, s *gfxapi.State, format *image.Format) []*gfxapi.Texture2DArrayLevel {
+	levels := make([]*gfxapi.Texture2DArrayLevel, len(t.Layers[0].Levels))
+	for i := range levels {
+		levels[i] = &gfxapi.Texture2DArrayLevel{Layers: make([]*image.Info2D, len(t.Layers))}
+	}
+	for layerIndex, imageLayer := range t.Layers {
+		for levelIndex, imageLevel := range imageLayer.Levels {
+			levels[levelIndex].Layers[layerIndex] = levelImage(ctx, s, format, imageLevel)
+		}
+	}
+	return levels
+}
+
 func (t *ImageObject) SetResourceData(ctx log.Context, at *path.Command,
 	data interface{}, resources gfxapi.ResourceMap, edits gfxapi.ReplaceCallback) error {
 	return fmt.Errorf("SetResourceData is not supported for ImageObject")
@@ -21,57 +21,83 @@ import (
 )
 
 func (st *State) getFramebufferAttachmentInfo(attachment gfxapi.FramebufferAttachment) (w, h uint32, f VkFormat, attachmentIndex uint32, err error) {
-	if st.LastUsedFramebuffer != nil {
-		var index int
+	w, h, f, attachmentIndex, _, err = st.getFramebufferAttachmentLayerInfo(attachment, nil)
+	return w, h, f, attachmentIndex, err
+}
+
+// getFramebufferAttachmentView returns the image view bound to the specified
+// attachment of the last used framebuffer, along with its attachment index.
+func (st *State) getFramebufferAttachmentView(attachment gfxapi.FramebufferAttachment) (*ImageViewObject, uint32, error) {
+	if st.LastUsedFramebuffer == nil {
+		return nil, 0, fmt.Errorf("%s is not bound", attachment)
+	}
+
+	index := attachment.ColorIndex()
+	switch {
+	case index >= 0, attachment == gfxapi.FramebufferAttachment_Depth:
+	default:
+		return nil, 0, fmt.Errorf("Framebuffer attachment %v currently unsupported", attachment)
+	}
+
+	currentColorIndex := 0
+	for _, a := range st.LastUsedFramebuffer.ImageAttachments.KeysSorted() {
+		view := st.LastUsedFramebuffer.ImageAttachments[a]
+		i := view.Image
+		isDepth := 0 != (uint32(i.Info.Usage) & uint32(VkImageUsageFlagBits_VK_IMAGE_USAGE_DEPTH_STENCIL_ATTACHMENT_BIT))
 
 		switch attachment {
-		case gfxapi.FramebufferAttachment_Color0:
-			index = 0
-		case gfxapi.FramebufferAttachment_Color1:
-			index = 1
-		case gfxapi.FramebufferAttachment_Color2:
-			index = 2
-		case gfxapi.FramebufferAttachment_Color3:
-			index = 3
 		case gfxapi.FramebufferAttachment_Depth:
-			break
-		case gfxapi.FramebufferAttachment_Stencil:
-			fallthrough
+			// Use the first-found depth image that is not multi-sampled.
+			if isDepth && i.Info.Samples == VkSampleCountFlagBits_VK_SAMPLE_COUNT_1_BIT {
+				return view, a, nil
+			}
 		default:
-			return 0, 0, VkFormat_VK_FORMAT_UNDEFINED, 0, fmt.Errorf("Framebuffer attachment %v currently unsupported", attachment)
+			if isDepth {
+				continue
+			}
+			if currentColorIndex == index {
+				return view, a, nil
+			}
+			currentColorIndex++
 		}
+	}
+	return nil, 0, fmt.Errorf("%s is not bound", attachment)
+}
 
-		currentColorIndex := 0
-		for _, a := range st.LastUsedFramebuffer.ImageAttachments.KeysSorted() {
-			view := st.LastUsedFramebuffer.ImageAttachments[a]
-			i := view.Image
-			layer0 := i.Layers[0]
-			level0 := layer0.Levels[0]
+// getFramebufferAttachmentLayerInfo returns the dimensions and format of the
+// image layer and mip level bound to the specified attachment. If layer is nil
+// then the first layer and level of the attachment's image view is used.
+// The returned layer holds the absolute layer and level of the image.
+func (st *State) getFramebufferAttachmentLayerInfo(attachment gfxapi.FramebufferAttachment, layer *gfxapi.FramebufferLayer) (w, h uint32, f VkFormat, attachmentIndex uint32, l gfxapi.FramebufferLayer, err error) {
+	view, attachmentIndex, err := st.getFramebufferAttachmentView(attachment)
+	if err != nil {
+		return 0, 0, VkFormat_VK_FORMAT_UNDEFINED, 0, l, err
+	}
+	l = gfxapi.FramebufferLayer{
+		Layer: view.SubresourceRange.BaseArrayLayer,
+		Level: view.SubresourceRange.BaseMipLevel,
+	}
+	if layer != nil {
+		l = *layer
+	}
+	i := view.Image
+	imageLayer, ok := i.Layers[l.Layer]
+	if !ok || imageLayer == nil {
+		return 0, 0, VkFormat_VK_FORMAT_UNDEFINED, 0, l, fmt.Errorf("%s has no layer %d", attachment, l.Layer)
+	}
+	imageLevel, ok := imageLayer.Levels[l.Level]
+	if !ok || imageLevel == nil {
+		return 0, 0, VkFormat_VK_FORMAT_UNDEFINED, 0, l, fmt.Errorf("%s has no level %d", attachment, l.Level)
+	}
+	return imageLevel.Width, imageLevel.Height, i.Info.Format, attachmentIndex, l, nil
+}
 
-			switch attachment {
-			case gfxapi.FramebufferAttachment_Depth:
-				if 0 != (uint32(i.Info.Usage) & uint32(VkImageUsageFlagBits_VK_IMAGE_USAGE_DEPTH_STENCIL_ATTACHMENT_BIT)) {
-					// Use the first-found depth image that is not multi-sampled.
-					if i.Info.Samples != VkSampleCountFlagBits_VK_SAMPLE_COUNT_1_BIT {
-						continue
-					}
-					return level0.Width, level0.Height, i.Info.Format, a, nil
-				}
-			case gfxapi.FramebufferAttachment_Stencil:
-				if 0 != (uint32(i.Info.Usage) & uint32(VkImageUsageFlagBits_VK_IMAGE_USAGE_DEPTH_STENCIL_ATTACHMENT_BIT)) {
-					return level0.Width, level0.Height, i.Info.Format, a, nil
-				}
-			default:
-				if 0 != (uint32(i.Info.Usage) & uint32(VkImageUsageFlagBits_VK_IMAGE_USAGE_DEPTH_STENCIL_ATTACHMENT_BIT)) {
-					continue
-				}
-				if currentColorIndex == index {
-					return level0.Width, level0.Height, i.Info.Format, a, nil
-				} else {
-					currentColorIndex += 1
-				}
-			}
-		}
+// getFramebufferAttachmentLayers returns the number of layers and mip levels
+// of the image bound to the specified attachment.
+func (st *State) getFramebufferAttachmentLayers(attachment gfxapi.FramebufferAttachment) (layers, levels uint32, err error) {
+	view, _, err := st.getFramebufferAttachmentView(attachment)
+	if err != nil {
+		return 0, 0, err
 	}
-	return 0, 0, VkFormat_VK_FORMAT_UNDEFINED, 0, fmt.Errorf("%s is not bound", attachment)
+	return view.Image.Info.ArrayLayers, view.Image.Info.MipLevels, nil
 }
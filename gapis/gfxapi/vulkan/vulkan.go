@@ -45,6 +45,28 @@ func (api) Context(s *gfxapi.State) gfxapi.Context {
 
 func (api) GetFramebufferAttachmentInfo(state *gfxapi.State, attachment gfxapi.FramebufferAttachment) (w, h uint32, f *image.Format, err error) {
 	w, h, form, _, err := GetState(state).getFramebufferAttachmentInfo(attachment)
+	return attachmentInfo(attachment, w, h, form)
+}
+
+// GetFramebufferAttachmentLayers implements the
+// gfxapi.FramebufferLayersProvider interface.
+func (api) GetFramebufferAttachmentLayers(state *gfxapi.State, attachment gfxapi.FramebufferAttachment) (layers, levels uint32, err error) {
+	return GetState(state).getFramebufferAttachmentLayers(attachment)
+}
+
+// GetFramebufferAttachmentLayerInfo implements the
+// gfxapi.FramebufferLayersProvider interface.
+func (api) GetFramebufferAttachmentLayerInfo(state *gfxapi.State, attachment gfxapi.FramebufferAttachment, layer *gfxapi.FramebufferLayer) (w, h uint32, f *image.Format, err error) {
+	w, h, form, _, _, err := GetState(state).getFramebufferAttachmentLayerInfo(attachment, layer)
+	if err != nil {
+		return 0, 0, nil, err
+	}
+	return attachmentInfo(attachment, w, h, form)
+}
+
+// attachmentInfo converts the Vulkan format of the image bound to attachment
+// to an image format.
+func attachmentInfo(attachment gfxapi.FramebufferAttachment, w, h uint32, form VkFormat) (uint32, uint32, *image.Format, error) {
 	switch attachment {
 	case gfxapi.FramebufferAttachment_Stencil:
 		return 0, 0, nil, fmt.Errorf("Unsupported Stencil")
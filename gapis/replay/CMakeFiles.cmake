@@ -21,6 +21,8 @@ set(files
     batcher.go
     context.go
     custom.go
+    dispatch.go
+    dispatch_test.go
     events.go
     interfaces.go
     manager.go
@@ -35,4 +37,5 @@ set(dirs
     opcode
     protocol
     value
+    vm
 )
@@ -15,23 +15,27 @@
 package replay
 
 import (
+	"bufio"
 	"fmt"
+	"io"
 	"time"
 
 	"github.com/google/gapid/core/app/benchmark"
 	"github.com/google/gapid/core/data/id"
+	"github.com/google/gapid/core/event/task"
 	"github.com/google/gapid/core/fault/cause"
 	"github.com/google/gapid/core/log"
 	"github.com/google/gapid/core/os/device"
 	"github.com/google/gapid/core/os/device/bind"
-	gapir "github.com/google/gapid/gapir/client"
 	"github.com/google/gapid/gapis/atom"
 	"github.com/google/gapid/gapis/capture"
 	"github.com/google/gapid/gapis/config"
 	"github.com/google/gapid/gapis/gfxapi"
 	"github.com/google/gapid/gapis/replay/builder"
 	"github.com/google/gapid/gapis/replay/executor"
+	"github.com/google/gapid/gapis/replay/protocol"
 	"github.com/google/gapid/gapis/service/path"
+	"github.com/google/gapid/gapis/status"
 )
 
 const maxBatchDelay = 250 * time.Millisecond
@@ -47,6 +51,7 @@ type batcherContext struct {
 }
 
 type job struct {
+	ctx     log.Context
 	request Request
 	result  chan<- error
 }
@@ -54,8 +59,8 @@ type job struct {
 type batcher struct {
 	feed    chan job
 	context batcherContext
-	device  bind.Device
-	gapir   *gapir.Client
+	device  bind.Device // The requested replay device.
+	manager *Manager
 }
 
 var (
@@ -85,18 +90,58 @@ func (b *batcher) run(ctx log.Context) {
 			}
 		}
 
+		// Drop the jobs that were cancelled while the batch was forming.
+		live := jobs[:0]
+		for _, job := range jobs {
+			if err := task.StopReason(job.ctx); err != nil {
+				job.result <- err
+			} else {
+				live = append(live, job)
+			}
+		}
+		if len(live) == 0 {
+			continue
+		}
+
 		// Batch formed. Trigger the replay.
-		requests := make([]Request, len(jobs))
-		for i, job := range jobs {
+		requests := make([]Request, len(live))
+		for i, job := range live {
 			requests[i] = job.request
 		}
 
+		// Batches are replayed in parallel, limited by the number of replay
+		// targets available.
 		ctx.Info().Log("Replay batch")
-		err := b.send(ctx, requests)
+		go func(jobs []job) {
+			err := b.sendCancellable(ctx, jobs, requests)
+			for _, job := range jobs {
+				job.result <- err
+			}
+		}(live)
+	}
+}
+
+// sendCancellable calls send with a context that is cancelled once all of the
+// jobs have been cancelled.
+func (b *batcher) sendCancellable(ctx log.Context, jobs []job, requests []Request) error {
+	ctx, cancel := task.WithCancel(ctx)
+	defer cancel()
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
 		for _, job := range jobs {
-			job.result <- err
+			select {
+			case <-task.ShouldStop(job.ctx):
+			case <-done:
+				return
+			}
 		}
-	}
+		cancel()
+	}()
+
+	ctx = status.Start(ctx, "Replay batch of %d requests", len(requests))
+	defer status.Finish(ctx)
+	return b.send(ctx, requests)
 }
 
 // captureMemoryLayout returns the device memory layout of the capture from the
@@ -131,11 +176,19 @@ func (b *batcher) send(ctx log.Context, requests []Request) (err error) {
 
 	ctx = capture.Put(ctx, capPath)
 
+	// All the replay targets share the configuration of the requested device.
 	device := b.device.Instance()
 	ctx = ctx.S("device", device.Name)
 
 	ctx.Info().Logf("Replaying...")
 
+	// Wait for a replay target to become available before building the
+	// payload, so that batches waiting for a target do not hold their payloads.
+	if err := b.manager.reserveBatch(ctx, b.device); err != nil {
+		return err
+	}
+	defer b.manager.releaseBatch(b.device)
+
 	c, err := capture.ResolveFromPath(ctx, capPath)
 	if err != nil {
 		return cause.Explain(ctx, err, "Failed to load capture")
@@ -191,6 +244,13 @@ func (b *batcher) send(ctx log.Context, requests []Request) (err error) {
 	}
 	builderBuildCounter.Stop(t0)
 
+	// decoder is only handed the postbacks once.
+	decoded := false
+	decode := func(r io.Reader, err error) {
+		decoded = true
+		decoder(r, err)
+	}
+
 	defer func() {
 		caught := recover()
 		if err == nil && caught != nil {
@@ -200,7 +260,7 @@ func (b *batcher) send(ctx log.Context, requests []Request) (err error) {
 				err = fmt.Errorf("%s", caught)
 			}
 		}
-		if err != nil {
+		if err != nil && !decoded {
 			// An error was returned or thrown after the replay postbacks were requested.
 			// Inform each postback handler that they're not going to get data,
 			// to avoid chans blocking forever.
@@ -211,9 +271,74 @@ func (b *batcher) send(ctx log.Context, requests []Request) (err error) {
 		}
 	}()
 
-	connection, err := b.gapir.Connect(ctx, b.device, replayABI)
+	// The replay target is only acquired once the payload is built, so that
+	// other batches can replay on it in the meantime.
+	target, err := b.manager.acquire(ctx, b.device, map[replayTarget]bool{})
+	if err != nil {
+		return cause.Explain(ctx, err, "Failed to find a replay device")
+	}
+	defer func() { b.manager.release(target, false) }()
+
+	return b.execute(ctx, &target, intent, requests, replayABI, payload, decode)
+}
+
+// execute replays the payload on the replay target t. Each time the replay
+// fails before any postback data has been handed to decoder, t is released
+// and the payload is re-dispatched to another equivalent replay target. Once
+// all the equivalent targets have failed, execute returns the last error and
+// t is set to the zero replayTarget.
+func (b *batcher) execute(
+	ctx log.Context,
+	t *replayTarget,
+	intent Intent,
+	requests []Request,
+	abi *device.ABI,
+	payload protocol.Payload,
+	decoder builder.ResponseDecoder) error {
+
+	failed := map[replayTarget]bool{}
+	for {
+		decoded, err := b.executeOn(ctx, *t, intent, requests, abi, payload, decoder)
+		if err == nil {
+			return nil
+		}
+		if stop := task.StopReason(ctx); stop != nil {
+			return stop // Cancelled, not the target's fault.
+		}
+		if decoded {
+			return err // The postbacks cannot be replayed.
+		}
+		ctx.Warning().V("instance", t.instance).Logf("Replay on %v failed, re-dispatching: %v", t.device, err)
+		failed[*t] = true
+		b.manager.release(*t, true)
+		*t = replayTarget{}
+		next, nextErr := b.manager.acquire(ctx, b.device, failed)
+		if nextErr != nil {
+			return err
+		}
+		*t = next
+	}
+}
+
+// executeOn replays the payload once on the replay target t. The postbacks
+// are streamed to decoder as they are received, but decoder is only called
+// once the first postback data has arrived, so that a replay that fails
+// before posting anything back can be re-dispatched. executeOn returns true
+// if decoder was called.
+func (b *batcher) executeOn(
+	ctx log.Context,
+	t replayTarget,
+	intent Intent,
+	requests []Request,
+	abi *device.ABI,
+	payload protocol.Payload,
+	decoder builder.ResponseDecoder) (bool, error) {
+
+	ctx = ctx.S("target", t.device.Instance().Name).V("instance", t.instance)
+
+	connection, err := b.manager.gapir.ConnectInstance(ctx, t.device, abi, t.instance)
 	if err != nil {
-		return cause.Explain(ctx, err, "Failed to connect to device")
+		return false, cause.Explain(ctx, err, "Failed to connect to device")
 	}
 	defer connection.Close()
 
@@ -222,19 +347,30 @@ func (b *batcher) send(ctx log.Context, requests []Request) (err error) {
 	}
 
 	if Events.OnReplay != nil {
-		Events.OnReplay(b.device, intent, b.context.Config, requests)
+		Events.OnReplay(t.device, intent, b.context.Config, requests)
 	}
 
-	t0 = executorExecuteCounter.Start()
+	decoded := false
+	t0 := executorExecuteCounter.Start()
 	err = executor.Execute(
 		ctx,
 		payload,
-		decoder,
+		func(r io.Reader, err error) {
+			if r == nil {
+				return
+			}
+			postbacks := bufio.NewReader(r)
+			if _, err := postbacks.Peek(1); err != nil && err != io.EOF {
+				return // The replay failed before posting anything back.
+			}
+			decoded = true
+			decoder(postbacks, nil)
+		},
 		connection,
-		replayABI.MemoryLayout,
+		abi.MemoryLayout,
 	)
 	executorExecuteCounter.Stop(t0)
-	return err
+	return decoded, err
 }
 
 // adapter conforms to the the atom Writer interface, performing replay writes
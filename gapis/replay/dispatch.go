@@ -0,0 +1,192 @@
+// Copyright (C) 2017 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package replay
+
+import (
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/google/gapid/core/event/task"
+	"github.com/google/gapid/core/fault"
+	"github.com/google/gapid/core/log"
+	"github.com/google/gapid/core/os/device"
+	"github.com/google/gapid/core/os/device/bind"
+	gapir "github.com/google/gapid/gapir/client"
+)
+
+const (
+	// ErrNoReplayTargets is returned when there are no replay targets left to
+	// dispatch a batch to.
+	ErrNoReplayTargets = fault.Const("No replay targets available")
+
+	// failureCooldown is the duration a replay target is avoided for after a
+	// failed replay.
+	failureCooldown = 30 * time.Second
+)
+
+// replayTarget is a single GAPIR instance on a replay device.
+type replayTarget struct {
+	device   bind.Device
+	instance int
+}
+
+// targetState holds the load of a replay target.
+type targetState struct {
+	slot    chan struct{} // Holds a value while the target is replaying.
+	pending int           // Number of batches waiting for or holding the slot.
+	failed  time.Time     // Time of the last failed replay on the target.
+}
+
+// batchLimit counts the batches of a group of equivalent replay targets that
+// are being built or replayed.
+type batchLimit struct {
+	inFlight int
+	changed  chan struct{} // Closed each time inFlight decreases.
+}
+
+// reserveBatch blocks until another batch can be built for the replay targets
+// equivalent to the requested device. At most one batch is built or replayed
+// per equivalent replay target, so that the payloads of batches waiting for a
+// target are not held in memory. Each successful call to reserveBatch must be
+// paired with a call to releaseBatch.
+func (m *Manager) reserveBatch(ctx log.Context, requested bind.Device) error {
+	key := proto.CompactTextString(requested.Instance().Configuration)
+	m.mutex.Lock()
+	l, ok := m.limits[key]
+	if !ok {
+		l = &batchLimit{changed: make(chan struct{})}
+		m.limits[key] = l
+	}
+	for l.inFlight >= len(m.equivalentTargets(ctx, requested)) {
+		changed := l.changed
+		m.mutex.Unlock()
+		select {
+		case <-changed:
+		case <-task.ShouldStop(ctx):
+			return task.StopReason(ctx)
+		}
+		m.mutex.Lock()
+	}
+	l.inFlight++
+	m.mutex.Unlock()
+	return nil
+}
+
+// releaseBatch frees the batch previously reserved by reserveBatch.
+func (m *Manager) releaseBatch(requested bind.Device) {
+	key := proto.CompactTextString(requested.Instance().Configuration)
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	l := m.limits[key]
+	l.inFlight--
+	close(l.changed)
+	l.changed = make(chan struct{})
+}
+
+// acquire picks the least loaded replay target that is equivalent to the
+// requested device, blocking until the target is free to replay. Targets in
+// exclude are never picked, and targets that recently failed are only picked
+// if there is no alternative. Each successful call to acquire must be paired
+// with a call to release.
+func (m *Manager) acquire(ctx log.Context, requested bind.Device, exclude map[replayTarget]bool) (replayTarget, error) {
+	m.mutex.Lock()
+	var best replayTarget
+	var bestState *targetState
+	bestFailed := false
+	for _, t := range m.equivalentTargets(ctx, requested) {
+		if exclude[t] {
+			continue
+		}
+		s, ok := m.targets[t]
+		if !ok {
+			s = &targetState{slot: make(chan struct{}, 1)}
+			m.targets[t] = s
+		}
+		failed := time.Since(s.failed) < failureCooldown
+		switch {
+		case bestState == nil,
+			bestFailed && !failed,
+			bestFailed == failed && s.pending < bestState.pending:
+			best, bestState, bestFailed = t, s, failed
+		}
+	}
+	if bestState == nil {
+		m.mutex.Unlock()
+		return replayTarget{}, ErrNoReplayTargets
+	}
+	bestState.pending++
+	m.mutex.Unlock()
+
+	select {
+	case bestState.slot <- struct{}{}:
+		return best, nil
+	case <-task.ShouldStop(ctx):
+		m.mutex.Lock()
+		bestState.pending--
+		m.mutex.Unlock()
+		return replayTarget{}, task.StopReason(ctx)
+	}
+}
+
+// release frees the replay target t previously returned by acquire. If failed
+// is true then t is avoided by acquire for a while.
+func (m *Manager) release(t replayTarget, failed bool) {
+	if t.device == nil {
+		return
+	}
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	s := m.targets[t]
+	<-s.slot
+	s.pending--
+	if failed {
+		s.failed = time.Now()
+	}
+}
+
+// equivalentTargets returns the replay targets on all the registered devices
+// with the same configuration as the requested device. The requested device's
+// targets are listed first so that they are preferred when loads are equal.
+// m.mutex must be held when calling equivalentTargets.
+func (m *Manager) equivalentTargets(ctx log.Context, requested bind.Device) []replayTarget {
+	devices := []bind.Device{requested}
+	config := requested.Instance().Configuration
+	for _, d := range bind.GetRegistry(ctx).Devices() {
+		if d != requested && proto.Equal(d.Instance().Configuration, config) {
+			devices = append(devices, d)
+		}
+	}
+	out := []replayTarget{}
+	for _, d := range devices {
+		for i, c := 0, m.instances(ctx, d); i < c; i++ {
+			out = append(out, replayTarget{d, i})
+		}
+	}
+	return out
+}
+
+// instances returns the number of GAPIR instances that can replay on d.
+func (m *Manager) instances(ctx log.Context, d bind.Device) int {
+	if _, remote := d.(*gapir.RemoteDevice); remote {
+		return 1 // Remote GAPIR instances are not spawned by GAPIS.
+	}
+	if _, connector := d.(gapir.Connector); connector {
+		return 1 // Connectors only support a single instance.
+	}
+	if m.HostInstances > 1 && device.Host(ctx).SameAs(d.Instance()) {
+		return m.HostInstances
+	}
+	return 1
+}
@@ -0,0 +1,242 @@
+// Copyright (C) 2017 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package replay
+
+import (
+	"io"
+	"net"
+	"testing"
+
+	"github.com/google/gapid/core/assert"
+	"github.com/google/gapid/core/data/pod"
+	"github.com/google/gapid/core/event/task"
+	"github.com/google/gapid/core/log"
+	"github.com/google/gapid/core/os/device"
+	"github.com/google/gapid/core/os/device/bind"
+	"github.com/google/gapid/gapis/database"
+	"github.com/google/gapid/gapis/replay/builder"
+	"github.com/google/gapid/gapis/replay/protocol"
+	"github.com/google/gapid/gapis/replay/value"
+	"github.com/google/gapid/gapis/replay/vm"
+)
+
+var testABI = &device.ABI{Name: "test", MemoryLayout: device.Little32}
+
+func newInstance(serial string, abi *device.ABI) *device.Instance {
+	instance := &device.Instance{
+		Serial:        serial,
+		Name:          serial,
+		Configuration: &device.Configuration{ABIs: []*device.ABI{abi}},
+	}
+	instance.GenID()
+	return instance
+}
+
+func newDevice(serial string, abi *device.ABI) bind.Device {
+	return &bind.Simple{To: newInstance(serial, abi), LastStatus: bind.Status_Online}
+}
+
+// brokenDevice is a fake replay device whose connections fail as soon as the
+// replay is sent.
+type brokenDevice struct{ bind.Simple }
+
+func newBrokenDevice(serial string, abi *device.ABI) *brokenDevice {
+	return &brokenDevice{bind.Simple{To: newInstance(serial, abi), LastStatus: bind.Status_Online}}
+}
+
+func (d *brokenDevice) Connect(ctx log.Context, abi *device.ABI) (io.ReadWriteCloser, error) {
+	client, server := net.Pipe()
+	server.Close()
+	return client, nil
+}
+
+// newRegistryContext returns ctx with an in-memory database and a device
+// registry holding devices.
+func newRegistryContext(ctx log.Context, devices ...bind.Device) log.Context {
+	ctx = database.Put(ctx, database.NewInMemory(ctx))
+	r := bind.NewRegistry()
+	ctx = bind.PutRegistry(ctx, r)
+	for _, d := range devices {
+		r.AddDevice(ctx, d)
+	}
+	return ctx
+}
+
+func TestEquivalentTargets(t *testing.T) {
+	ctx := log.Testing(t)
+	other := &device.ABI{Name: "other", MemoryLayout: device.Big32}
+	a, b, c := newDevice("a", testABI), newDevice("b", testABI), newDevice("c", other)
+	ctx = newRegistryContext(ctx, c, b, a)
+
+	m := New(ctx)
+	assert.For(ctx, "a").ThatSlice(m.equivalentTargets(ctx, a)).Equals([]replayTarget{{a, 0}, {b, 0}})
+	assert.For(ctx, "b").ThatSlice(m.equivalentTargets(ctx, b)).Equals([]replayTarget{{b, 0}, {a, 0}})
+	assert.For(ctx, "c").ThatSlice(m.equivalentTargets(ctx, c)).Equals([]replayTarget{{c, 0}})
+}
+
+func TestAcquireRelease(t *testing.T) {
+	ctx := log.Testing(t)
+	a, b := newDevice("a", testABI), newDevice("b", testABI)
+	ctx = newRegistryContext(ctx, a, b)
+	none := map[replayTarget]bool{}
+
+	m := New(ctx)
+	// The requested device is preferred when the loads are equal.
+	first, err := m.acquire(ctx, a, none)
+	if assert.For(ctx, "first").ThatError(err).Succeeded() {
+		assert.For(ctx, "first").That(first).Equals(replayTarget{a, 0})
+	}
+	// The least loaded target is picked.
+	second, err := m.acquire(ctx, a, none)
+	if assert.For(ctx, "second").ThatError(err).Succeeded() {
+		assert.For(ctx, "second").That(second).Equals(replayTarget{b, 0})
+	}
+
+	// Acquiring a busy target blocks until cancelled.
+	cancelled, cancel := task.WithCancel(ctx)
+	cancel()
+	_, err = m.acquire(cancelled, a, none)
+	assert.For(ctx, "cancelled").ThatError(err).Equals(task.StopReason(cancelled))
+	assert.For(ctx, "pending").That(m.targets[first].pending).Equals(1)
+
+	// Recently failed targets are only picked if there is no alternative.
+	m.release(first, true)
+	m.release(second, false)
+	got, err := m.acquire(ctx, a, none)
+	if assert.For(ctx, "after failure").ThatError(err).Succeeded() {
+		assert.For(ctx, "after failure").That(got).Equals(second)
+	}
+	got, err = m.acquire(ctx, a, map[replayTarget]bool{second: true})
+	if assert.For(ctx, "only failed").ThatError(err).Succeeded() {
+		assert.For(ctx, "only failed").That(got).Equals(first)
+	}
+	m.release(first, false)
+	m.release(second, false)
+
+	_, err = m.acquire(ctx, a, map[replayTarget]bool{first: true, second: true})
+	assert.For(ctx, "all excluded").ThatError(err).Equals(ErrNoReplayTargets)
+	assert.For(ctx, "released").That(m.targets[first].pending).Equals(0)
+	assert.For(ctx, "released").That(m.targets[second].pending).Equals(0)
+}
+
+func TestReserveBatch(t *testing.T) {
+	ctx := log.Testing(t)
+	other := &device.ABI{Name: "other", MemoryLayout: device.Big32}
+	a, b, c := newDevice("a", testABI), newDevice("b", testABI), newDevice("c", other)
+	ctx = newRegistryContext(ctx, a, b, c)
+
+	m := New(ctx)
+	// One batch is allowed per equivalent replay target.
+	assert.For(ctx, "first").ThatError(m.reserveBatch(ctx, a)).Succeeded()
+	assert.For(ctx, "second").ThatError(m.reserveBatch(ctx, b)).Succeeded()
+	assert.For(ctx, "other").ThatError(m.reserveBatch(ctx, c)).Succeeded()
+
+	cancelled, cancel := task.WithCancel(ctx)
+	cancel()
+	err := m.reserveBatch(cancelled, a)
+	assert.For(ctx, "full").ThatError(err).Equals(task.StopReason(cancelled))
+
+	reserved := make(chan error, 1)
+	go func() { reserved <- m.reserveBatch(ctx, a) }()
+	m.releaseBatch(b)
+	assert.For(ctx, "released").ThatError(<-reserved).Succeeded()
+	m.releaseBatch(a)
+	m.releaseBatch(a)
+	m.releaseBatch(c)
+}
+
+// buildPostback returns a payload that posts back the value 42, its decoder
+// and the channel the posted data is sent to. The channel is closed if the
+// postback fails.
+func buildPostback(ctx log.Context) (protocol.Payload, builder.ResponseDecoder, <-chan []byte, error) {
+	got := make(chan []byte, 1)
+	b := builder.New(testABI.MemoryLayout)
+	ptr := b.AllocateMemory(4)
+	b.BeginAtom(0)
+	b.Push(value.U32(42))
+	b.Store(ptr)
+	b.Post(ptr, 4, func(r pod.Reader, err error) error {
+		if err != nil {
+			close(got)
+			return err
+		}
+		data := make([]byte, 4)
+		r.Data(data)
+		got <- data
+		return r.Error()
+	})
+	b.CommitAtom()
+	payload, decoder, err := b.Build(ctx)
+	return payload, decoder, got, err
+}
+
+func TestRedispatch(t *testing.T) {
+	ctx := log.Testing(t)
+	broken := newBrokenDevice("broken", testABI)
+	fake := vm.NewDevice(newInstance("fake", testABI), nil)
+	ctx = newRegistryContext(ctx, broken, fake)
+
+	payload, decoder, got, err := buildPostback(ctx)
+	if !assert.With(ctx).ThatError(err).Succeeded() {
+		return
+	}
+
+	m := New(ctx)
+	b := &batcher{device: broken, manager: m}
+	target, err := m.acquire(ctx, broken, map[replayTarget]bool{})
+	if !assert.For(ctx, "acquire").ThatError(err).Succeeded() {
+		return
+	}
+	assert.For(ctx, "first target").That(target).Equals(replayTarget{broken, 0})
+
+	err = b.execute(ctx, &target, Intent{}, nil, testABI, payload, decoder)
+	if !assert.For(ctx, "execute").ThatError(err).Succeeded() {
+		return
+	}
+	assert.For(ctx, "replay target").That(target).Equals(replayTarget{fake, 0})
+	assert.For(ctx, "postback").ThatSlice(<-got).Equals([]byte{42, 0, 0, 0})
+	assert.For(ctx, "fake replays").ThatSlice(fake.Replays()).IsLength(1)
+	assert.For(ctx, "broken failed").That(m.targets[replayTarget{broken, 0}].failed.IsZero()).Equals(false)
+	m.release(target, false)
+}
+
+func TestRedispatchAllFailed(t *testing.T) {
+	ctx := log.Testing(t)
+	broken := newBrokenDevice("broken", testABI)
+	ctx = newRegistryContext(ctx, broken)
+
+	payload, decoder, got, err := buildPostback(ctx)
+	if !assert.With(ctx).ThatError(err).Succeeded() {
+		return
+	}
+
+	m := New(ctx)
+	b := &batcher{device: broken, manager: m}
+	target, err := m.acquire(ctx, broken, map[replayTarget]bool{})
+	if !assert.For(ctx, "acquire").ThatError(err).Succeeded() {
+		return
+	}
+	err = b.execute(ctx, &target, Intent{}, nil, testABI, payload, decoder)
+	assert.For(ctx, "execute").ThatError(err).Failed()
+	assert.For(ctx, "replay target").That(target).Equals(replayTarget{})
+	assert.For(ctx, "pending").That(m.targets[replayTarget{broken, 0}].pending).Equals(0)
+
+	// The decoder is left to the caller, so no postbacks were delivered.
+	select {
+	case <-got:
+		assert.For(ctx, "postbacks").Error("Unexpected postback")
+	default:
+	}
+}
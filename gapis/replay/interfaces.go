@@ -53,6 +53,8 @@ type QueryIssues interface {
 // QueryFramebufferAttachment is the interface implemented by types that can
 // return the content of a framebuffer attachment at a particular point in a
 // capture.
+// If layer is nil then the layer and level bound to the framebuffer are
+// returned.
 type QueryFramebufferAttachment interface {
 	QueryFramebufferAttachment(
 		ctx log.Context,
@@ -61,7 +63,34 @@ type QueryFramebufferAttachment interface {
 		after atom.ID,
 		width, height uint32,
 		attachment gfxapi.FramebufferAttachment,
-		wireframeMode WireframeMode) (*image.Image2D, error)
+		layer *gfxapi.FramebufferLayer,
+		wireframeMode WireframeMode,
+		drawMode DrawMode) (*image.Image2D, error)
+}
+
+// QueryPixelHistory is the interface implemented by types that can return the
+// list of commands in a frame that modified a single pixel of a framebuffer
+// attachment, up to and including a particular point in a capture.
+type QueryPixelHistory interface {
+	QueryPixelHistory(
+		ctx log.Context,
+		intent Intent,
+		mgr *Manager,
+		after atom.ID,
+		x, y uint32,
+		attachment gfxapi.FramebufferAttachment) (*service.PixelHistory, error)
+}
+
+// QueryShaderTrace is the interface implemented by types that can return the
+// values written by the fragment shader of a draw call for a single pixel.
+type QueryShaderTrace interface {
+	QueryShaderTrace(
+		ctx log.Context,
+		intent Intent,
+		mgr *Manager,
+		after atom.ID,
+		x, y uint32,
+		variables []string) (*service.ShaderTrace, error)
 }
 
 // Issue represents a single replay issue reported by QueryIssues.
@@ -15,9 +15,12 @@
 package replay
 
 import (
+	"context"
 	"fmt"
 	"sync"
 
+	"github.com/google/gapid/core/context/keys"
+	"github.com/google/gapid/core/event/task"
 	"github.com/google/gapid/core/log"
 	"github.com/google/gapid/core/os/device/bind"
 	gapir "github.com/google/gapid/gapir/client"
@@ -25,10 +28,21 @@ import (
 
 // Manager is used discover replay devices and to send replay requests to those
 // discovered devices.
+//
+// Batches of replay requests are load balanced across the GAPIR instances of
+// all the registered devices that share the configuration of the requested
+// device. Batches that fail to connect to or replay on an instance are
+// re-dispatched to another.
 type Manager struct {
+	// HostInstances is the number of GAPIR instances that replay in parallel
+	// on the host device. It must be set before the first replay request.
+	HostInstances int
+
 	gapir    *gapir.Client
 	batchers map[batcherContext]*batcher
-	mutex    sync.Mutex // guards batchers
+	targets  map[replayTarget]*targetState
+	limits   map[string]*batchLimit // keyed by device configuration
+	mutex    sync.Mutex             // guards batchers, targets and limits
 }
 
 func (m *Manager) getBatchStream(ctx log.Context, bContext batcherContext) (chan<- job, error) {
@@ -48,10 +62,12 @@ func (m *Manager) getBatchStream(ctx log.Context, bContext batcherContext) (chan
 			feed:    make(chan job, 64),
 			context: bContext,
 			device:  device,
-			gapir:   m.gapir,
+			manager: m,
 		}
 		m.batchers[bContext] = b
-		go b.run(ctx)
+		// The batcher outlives the request that created it, so run it with a
+		// context that is not cancelled with the request.
+		go b.run(log.Wrap(keys.Clone(context.Background(), ctx.Unwrap())))
 	}
 	return b.feed, nil
 }
@@ -59,8 +75,11 @@ func (m *Manager) getBatchStream(ctx log.Context, bContext batcherContext) (chan
 // New returns a new Manager instance using the database db.
 func New(ctx log.Context) *Manager {
 	return &Manager{
-		gapir:    gapir.New(ctx),
-		batchers: make(map[batcherContext]*batcher),
+		HostInstances: 1,
+		gapir:         gapir.New(ctx),
+		batchers:      make(map[batcherContext]*batcher),
+		targets:       make(map[replayTarget]*targetState),
+		limits:        make(map[string]*batchLimit),
 	}
 }
 
@@ -79,6 +98,15 @@ func (m *Manager) Replay(ctx log.Context, intent Intent, cfg Config, req Request
 		return err
 	}
 	res := make(chan error, 1)
-	batch <- job{request: req, result: res}
-	return <-res
+	select {
+	case batch <- job{ctx: ctx, request: req, result: res}:
+	case <-task.ShouldStop(ctx):
+		return task.StopReason(ctx)
+	}
+	select {
+	case err := <-res:
+		return err
+	case <-task.ShouldStop(ctx):
+		return task.StopReason(ctx)
+	}
 }
@@ -0,0 +1,165 @@
+// Copyright (C) 2017 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package vm
+
+import (
+	"bytes"
+	"io"
+	"net"
+	"sync"
+
+	"github.com/google/gapid/core/data/endian"
+	"github.com/google/gapid/core/data/pod"
+	"github.com/google/gapid/core/fault/cause"
+	"github.com/google/gapid/core/log"
+	"github.com/google/gapid/core/os/device"
+	"github.com/google/gapid/core/os/device/bind"
+	"github.com/google/gapid/gapis/replay/protocol"
+)
+
+// Replay is the result of a single replay on a Device.
+type Replay struct {
+	Machine *Machine // The machine that interpreted the payload, or nil.
+	Err     error    // The error that stopped the replay, or nil.
+}
+
+// Device is a fake replay device that interprets replay payloads with a
+// Machine instead of a GAPIR instance.
+//
+// Device implements the gapir client's Connector interface, so a Device added
+// to the device registry can be used as the replay device of integration
+// tests.
+type Device struct {
+	bind.Simple
+	Functions Functions // The functions callable by the payloads.
+
+	mutex   sync.Mutex
+	replays []Replay
+}
+
+// NewDevice returns a new online Device described by instance, dispatching
+// the calls of the payloads to functions.
+func NewDevice(instance *device.Instance, functions Functions) *Device {
+	return &Device{
+		Simple: bind.Simple{
+			To:         instance,
+			LastStatus: bind.Status_Online,
+		},
+		Functions: functions,
+	}
+}
+
+// Replays returns the results of the replays that have finished on the
+// device, in order of completion.
+func (d *Device) Replays() []Replay {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	return append([]Replay{}, d.replays...)
+}
+
+// Connect returns a connection to a new Machine, which serves a single replay
+// using the same protocol as GAPIR.
+func (d *Device) Connect(ctx log.Context, abi *device.ABI) (io.ReadWriteCloser, error) {
+	client, server := net.Pipe()
+	go func() {
+		defer server.Close()
+		m, err := serve(ctx, server, abi.MemoryLayout, d.Functions)
+		if err != nil {
+			ctx.Warning().Logf("Replay on fake device %v failed: %v", d, err)
+		}
+		d.mutex.Lock()
+		defer d.mutex.Unlock()
+		d.replays = append(d.replays, Replay{Machine: m, Err: err})
+	}()
+	return client, nil
+}
+
+// connection is the replay side of a connection to the server.
+type connection struct {
+	r pod.Reader
+	w pod.Writer
+}
+
+// serve reads the replay request from conn, fetches and interprets its
+// payload, then posts back the data posted by the payload.
+func serve(ctx log.Context, conn io.ReadWriter, layout *device.MemoryLayout, functions Functions) (*Machine, error) {
+	c := connection{
+		r: endian.Reader(conn, layout.GetEndian()),
+		w: endian.Writer(conn, layout.GetEndian()),
+	}
+
+	ty := protocol.ConnectionType(c.r.Uint8())
+	replayID := c.r.String()
+	replaySize := c.r.Uint32()
+	if err := c.r.Error(); err != nil {
+		return nil, cause.Explain(ctx, err, "Reading replay request")
+	}
+	if ty != protocol.ConnectionType_Replay {
+		return nil, cause.Explain(ctx, nil, "Unsupported connection type").With("type", ty)
+	}
+
+	data, err := c.get(ctx, protocol.ResourceInfo{ID: replayID, Size: replaySize})
+	if err != nil {
+		return nil, err
+	}
+	payload := protocol.Payload{}
+	r := endian.Reader(bytes.NewReader(data), layout.GetEndian())
+	if r.Simple(&payload); r.Error() != nil {
+		return nil, cause.Explain(ctx, r.Error(), "Decoding replay payload")
+	}
+
+	resources := map[string]protocol.ResourceInfo{}
+	for _, info := range payload.Resources {
+		resources[info.ID] = info
+	}
+	m := New(payload, layout, functions)
+	m.Resources = func(ctx log.Context, id string) ([]byte, error) {
+		return c.get(ctx, resources[id])
+	}
+	if err := m.Run(ctx); err != nil {
+		return m, err
+	}
+	return m, c.post(ctx, m.Postbacks())
+}
+
+// get requests the data of the resource from the server.
+func (c connection) get(ctx log.Context, info protocol.ResourceInfo) ([]byte, error) {
+	c.w.Uint8(uint8(protocol.MessageType_Get))
+	c.w.Uint32(1)
+	c.w.Uint64(uint64(info.Size))
+	c.w.String(info.ID)
+	if err := c.w.Error(); err != nil {
+		return nil, cause.Explain(ctx, err, "Requesting resource").With("id", info.ID)
+	}
+	data := make([]byte, info.Size)
+	if c.r.Data(data); c.r.Error() != nil {
+		return nil, cause.Explain(ctx, c.r.Error(), "Receiving resource").With("id", info.ID)
+	}
+	return data, nil
+}
+
+// post sends data to the server as a single postback.
+func (c connection) post(ctx log.Context, data []byte) error {
+	if len(data) == 0 {
+		return nil
+	}
+	c.w.Uint8(uint8(protocol.MessageType_Post))
+	c.w.Uint32(uint32(len(data)))
+	c.w.Data(data)
+	if err := c.w.Error(); err != nil {
+		return cause.Explain(ctx, err, "Posting data")
+	}
+	return nil
+}
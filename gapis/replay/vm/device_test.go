@@ -0,0 +1,126 @@
+// Copyright (C) 2017 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package vm_test
+
+import (
+	"testing"
+
+	"github.com/google/gapid/core/assert"
+	"github.com/google/gapid/core/data/pod"
+	"github.com/google/gapid/core/log"
+	"github.com/google/gapid/core/os/device"
+	gapir "github.com/google/gapid/gapir/client"
+	"github.com/google/gapid/gapis/database"
+	"github.com/google/gapid/gapis/memory"
+	"github.com/google/gapid/gapis/replay/builder"
+	"github.com/google/gapid/gapis/replay/executor"
+	"github.com/google/gapid/gapis/replay/value"
+	"github.com/google/gapid/gapis/replay/vm"
+)
+
+func newFakeDevice(layout *device.MemoryLayout) (*vm.Device, *device.ABI) {
+	abi := &device.ABI{Name: "fake", MemoryLayout: layout}
+	instance := &device.Instance{
+		Serial:        "fake",
+		Name:          "Fake replay device",
+		Configuration: &device.Configuration{ABIs: []*device.ABI{abi}},
+	}
+	instance.GenID()
+	return vm.NewDevice(instance, vm.Functions{{ApiIndex: 1, ID: 10}: incrementFunction}), abi
+}
+
+func TestDevice(t *testing.T) {
+	ctx := log.Testing(t)
+	ctx = database.Put(ctx, database.NewInMemory(ctx))
+	layout := device.Little32
+	d, abi := newFakeDevice(layout)
+
+	resource, err := database.Store(ctx, []byte{1, 2, 3, 4})
+	if !assert.With(ctx).ThatError(err).Succeeded() {
+		return
+	}
+
+	b := builder.New(layout)
+	ptr := b.AllocateMemory(4)
+	observed := memory.Range{Base: 0x1000, Size: 4}
+	got := make(chan []byte, 2)
+	postback := func(r pod.Reader, err error) error {
+		if err != nil {
+			return err
+		}
+		data := make([]byte, 4)
+		r.Data(data)
+		got <- data
+		return r.Error()
+	}
+
+	b.BeginAtom(1)
+	b.Write(observed, resource)
+	b.Push(value.U32(41))
+	b.Call(increment)
+	b.Store(ptr)
+	b.Post(value.ObservedPointer(observed.Base), 4, postback)
+	b.Post(ptr, 4, postback)
+	b.CommitAtom()
+
+	payload, decoder, err := b.Build(ctx)
+	if !assert.With(ctx).ThatError(err).Succeeded() {
+		return
+	}
+
+	// Connect through the GAPIR client, as the replay manager does.
+	connection, err := gapir.New(ctx).Connect(ctx, d, abi)
+	if !assert.With(ctx).ThatError(err).Succeeded() {
+		return
+	}
+	err = executor.Execute(ctx, payload, decoder, connection, layout)
+	if !assert.With(ctx).ThatError(err).Succeeded() {
+		return
+	}
+	assert.With(ctx).ThatSlice(<-got).Equals([]byte{1, 2, 3, 4})
+	assert.With(ctx).ThatSlice(<-got).Equals([]byte{42, 0, 0, 0})
+
+	replays := d.Replays()
+	if assert.With(ctx).ThatSlice(replays).IsLength(1) {
+		assert.With(ctx).ThatError(replays[0].Err).Succeeded()
+	}
+}
+
+func TestDeviceReplayError(t *testing.T) {
+	ctx := log.Testing(t)
+	ctx = database.Put(ctx, database.NewInMemory(ctx))
+	layout := device.Little32
+	d, abi := newFakeDevice(layout)
+
+	b := builder.New(layout)
+	b.BeginAtom(1)
+	b.Call(builder.FunctionInfo{ApiIndex: 1, ID: 99})
+	b.CommitAtom()
+	payload, decoder, err := b.Build(ctx)
+	if !assert.With(ctx).ThatError(err).Succeeded() {
+		return
+	}
+
+	connection, err := gapir.New(ctx).Connect(ctx, d, abi)
+	if !assert.With(ctx).ThatError(err).Succeeded() {
+		return
+	}
+	executor.Execute(ctx, payload, decoder, connection, layout)
+
+	replays := d.Replays()
+	if assert.With(ctx).ThatSlice(replays).IsLength(1) {
+		assert.With(ctx).ThatError(replays[0].Err).HasCause(vm.ErrUnknownFunction)
+	}
+}
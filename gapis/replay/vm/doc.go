@@ -0,0 +1,25 @@
+// Copyright (C) 2017 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package vm implements an interpreter for the replay virtual machine in Go.
+//
+// The interpreter executes a protocol.Payload without a replay device. Calls
+// are dispatched to a table of Go functions, which typically record the call
+// instead of calling into a graphics driver. The stack and memory accesses
+// of the payload are validated as it executes, so that malformed payloads
+// can be found by tests instead of on hardware.
+//
+// A Device serves replays with the interpreter over the same protocol as
+// GAPIR, so it can stand in for a replay device in integration tests.
+package vm
@@ -0,0 +1,75 @@
+// Copyright (C) 2017 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package vm
+
+import (
+	"github.com/google/gapid/core/log"
+	"github.com/google/gapid/gapis/replay/protocol"
+)
+
+// The identifiers of the functions built into the replay virtual machine.
+// Must match the values used in gapir/cc/interpreter.h.
+const (
+	postFunctionID       = 0xff00
+	resourceFunctionID   = 0xff01
+	printStackFunctionID = 0xff80
+)
+
+// FunctionID identifies a function callable by a payload.
+type FunctionID struct {
+	ApiIndex uint8  // The index of the API the function belongs to.
+	ID       uint16 // The function identifier.
+}
+
+// Function is the Go implementation of a function called by a payload.
+// The function must pop all of its arguments from the machine's stack, and
+// push its return value if pushReturn is true.
+type Function func(ctx log.Context, m *Machine, pushReturn bool) error
+
+// Functions is a table of functions callable by a payload.
+type Functions map[FunctionID]Function
+
+// Call is the record of a single function call made by a payload.
+type Call struct {
+	FunctionID
+	Label uint32  // The last label reached before the call.
+	Args  []Value // The arguments, in parameter order.
+}
+
+// Signature describes the parameters and return type of a function.
+type Signature struct {
+	Parameters int           // The number of parameters.
+	ReturnType protocol.Type // The return type.
+}
+
+// Record returns a Function with the signature sig that appends a Call to the
+// machine's Calls. If the return value is used, then a zero value is returned.
+func Record(id FunctionID, sig Signature) Function {
+	return func(ctx log.Context, m *Machine, pushReturn bool) error {
+		call := Call{FunctionID: id, Label: m.Label, Args: make([]Value, sig.Parameters)}
+		for i := sig.Parameters - 1; i >= 0; i-- {
+			v, err := m.Stack.Pop(ctx)
+			if err != nil {
+				return err
+			}
+			call.Args[i] = v
+		}
+		m.Calls = append(m.Calls, call)
+		if pushReturn {
+			return m.Stack.Push(ctx, Value{Type: sig.ReturnType})
+		}
+		return nil
+	}
+}
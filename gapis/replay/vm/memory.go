@@ -0,0 +1,166 @@
+// Copyright (C) 2017 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package vm
+
+import (
+	"encoding/binary"
+
+	"github.com/google/gapid/core/fault/cause"
+	"github.com/google/gapid/core/log"
+	"github.com/google/gapid/core/os/device"
+)
+
+// The base addresses of the address spaces in the interpreter's absolute
+// address-space. The bases fit in 32 bits so that payloads for 32-bit
+// devices can be interpreted.
+const (
+	constantBase = 0x10000000
+	volatileBase = 0x40000000
+	absoluteBase = 0x80000000
+
+	// unobservedPointer is the pointer used by the builder for pointers that
+	// could not be remapped. It must never be dereferenced.
+	// Must match the value used in gapis/replay/builder.
+	unobservedPointer = 0xBADF00D
+)
+
+// region is a contiguous block of memory in absolute address-space.
+type region struct {
+	name     string
+	base     uint64
+	data     []byte
+	writable bool
+}
+
+// contains returns true if the size bytes at addr lie within the region.
+// The checks are ordered so that none of them can overflow.
+func (r *region) contains(addr, size uint64) bool {
+	n := uint64(len(r.data))
+	return addr >= r.base && size <= n && addr-r.base <= n-size
+}
+
+// Memory is the memory of the interpreter, holding the constant and volatile
+// memory of the payload along with any blocks allocated by functions.
+type Memory struct {
+	regions   []*region
+	next      uint64
+	byteOrder binary.ByteOrder
+}
+
+func newMemory(layout *device.MemoryLayout, constants []byte, volatileSize uint32) *Memory {
+	m := &Memory{next: absoluteBase, byteOrder: binary.LittleEndian}
+	if layout.GetEndian() == device.BigEndian {
+		m.byteOrder = binary.BigEndian
+	}
+	m.regions = []*region{
+		{name: "constant", base: constantBase, data: constants},
+		{name: "volatile", base: volatileBase, data: make([]byte, volatileSize), writable: true},
+	}
+	return m
+}
+
+// Allocate allocates a writable block of size bytes, returning its absolute
+// address. Functions can use Allocate for the memory they return pointers to.
+func (m *Memory) Allocate(size uint64) uint64 {
+	base := m.next
+	m.regions = append(m.regions, &region{name: "allocated", base: base, data: make([]byte, size), writable: true})
+	m.next += (size + 15) &^ 15
+	return base
+}
+
+// Read returns the size bytes at the absolute address addr.
+// The returned slice aliases the interpreter's memory.
+func (m *Memory) Read(ctx log.Context, addr, size uint64) ([]byte, error) {
+	r, err := m.find(ctx, addr, size)
+	if err != nil {
+		return nil, err
+	}
+	offset := addr - r.base
+	return r.data[offset : offset+size], nil
+}
+
+// Write writes data to the absolute address addr.
+func (m *Memory) Write(ctx log.Context, addr uint64, data []byte) error {
+	r, err := m.find(ctx, addr, uint64(len(data)))
+	if err != nil {
+		return err
+	}
+	if !r.writable {
+		return cause.Explain(ctx, ErrInvalidAddress, "Write to read-only memory").
+			With("address", addr).With("size", len(data)).With("memory", r.name)
+	}
+	copy(r.data[addr-r.base:], data)
+	return nil
+}
+
+func (m *Memory) find(ctx log.Context, addr, size uint64) (*region, error) {
+	switch addr {
+	case 0:
+		return nil, cause.Explain(ctx, ErrInvalidAddress, "Null pointer dereference")
+	case unobservedPointer:
+		return nil, cause.Explain(ctx, ErrInvalidAddress, "Unobserved pointer dereference")
+	}
+	for _, r := range m.regions {
+		if r.contains(addr, size) {
+			return r, nil
+		}
+	}
+	return nil, cause.Explain(ctx, ErrInvalidAddress, "Access out of bounds").
+		With("address", addr).With("size", size)
+}
+
+// readValue reads a value of size bytes from the absolute address addr.
+func (m *Memory) readValue(ctx log.Context, addr, size uint64) (uint64, error) {
+	data, err := m.Read(ctx, addr, size)
+	if err != nil {
+		return 0, err
+	}
+	return m.decode(data), nil
+}
+
+// writeValue writes the low size bytes of v to the absolute address addr.
+func (m *Memory) writeValue(ctx log.Context, addr, size, v uint64) error {
+	return m.Write(ctx, addr, m.encode(v, size))
+}
+
+func (m *Memory) decode(data []byte) uint64 {
+	switch len(data) {
+	case 0:
+		return 0
+	case 1:
+		return uint64(data[0])
+	case 2:
+		return uint64(m.byteOrder.Uint16(data))
+	case 4:
+		return uint64(m.byteOrder.Uint32(data))
+	default:
+		return m.byteOrder.Uint64(data)
+	}
+}
+
+func (m *Memory) encode(v, size uint64) []byte {
+	data := make([]byte, size)
+	switch size {
+	case 1:
+		data[0] = byte(v)
+	case 2:
+		m.byteOrder.PutUint16(data, uint16(v))
+	case 4:
+		m.byteOrder.PutUint32(data, uint32(v))
+	case 8:
+		m.byteOrder.PutUint64(data, v)
+	}
+	return data
+}
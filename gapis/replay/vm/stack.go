@@ -0,0 +1,134 @@
+// Copyright (C) 2017 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package vm
+
+import (
+	"fmt"
+
+	"github.com/google/gapid/core/fault/cause"
+	"github.com/google/gapid/core/log"
+	"github.com/google/gapid/gapis/replay/protocol"
+)
+
+// Value is a typed value held on the interpreter's stack.
+type Value struct {
+	// Type is the type of the value.
+	Type protocol.Type
+	// Bits holds the bits of the value. Constant and volatile pointers hold the
+	// offset of the pointer in their address-space.
+	Bits uint64
+}
+
+func (v Value) String() string {
+	switch v.Type {
+	case protocol.Type_ConstantPointer, protocol.Type_VolatilePointer, protocol.Type_AbsolutePointer:
+		return fmt.Sprintf("%v<0x%x>", v.Type, v.Bits)
+	default:
+		return fmt.Sprintf("%v<%d>", v.Type, v.Bits)
+	}
+}
+
+// IsPointer returns true if the value is one of the pointer types.
+func (v Value) IsPointer() bool {
+	switch v.Type {
+	case protocol.Type_AbsolutePointer, protocol.Type_ConstantPointer, protocol.Type_VolatilePointer:
+		return true
+	}
+	return false
+}
+
+// Absolute returns the address of the pointer value v in absolute
+// address-space.
+func (v Value) Absolute() uint64 {
+	switch v.Type {
+	case protocol.Type_ConstantPointer:
+		return constantBase + v.Bits
+	case protocol.Type_VolatilePointer:
+		return volatileBase + v.Bits
+	default:
+		return v.Bits
+	}
+}
+
+// Stack is the value stack of the interpreter.
+type Stack struct {
+	values   []Value
+	capacity int
+}
+
+// Len returns the number of values on the stack.
+func (s *Stack) Len() int { return len(s.values) }
+
+// Push pushes v to the top of the stack.
+func (s *Stack) Push(ctx log.Context, v Value) error {
+	if len(s.values) >= s.capacity {
+		return cause.Explain(ctx, ErrStackOverflow, "Push").With("capacity", s.capacity)
+	}
+	s.values = append(s.values, v)
+	return nil
+}
+
+// Pop removes and returns the value at the top of the stack.
+func (s *Stack) Pop(ctx log.Context) (Value, error) {
+	if len(s.values) == 0 {
+		return Value{}, cause.Explain(ctx, ErrStackUnderflow, "Pop")
+	}
+	v := s.values[len(s.values)-1]
+	s.values = s.values[:len(s.values)-1]
+	return v, nil
+}
+
+// PopType removes and returns the value at the top of the stack, returning an
+// error if the value is not of type ty.
+func (s *Stack) PopType(ctx log.Context, ty protocol.Type) (Value, error) {
+	v, err := s.Pop(ctx)
+	if err != nil {
+		return v, err
+	}
+	if v.Type != ty {
+		return v, cause.Explain(ctx, ErrTypeMismatch, "Pop").With("expected", ty).With("got", v.Type)
+	}
+	return v, nil
+}
+
+// PopPointer removes the pointer at the top of the stack, returning its
+// address in absolute address-space.
+func (s *Stack) PopPointer(ctx log.Context) (uint64, error) {
+	v, err := s.Pop(ctx)
+	if err != nil {
+		return 0, err
+	}
+	if !v.IsPointer() {
+		return 0, cause.Explain(ctx, ErrTypeMismatch, "Pop pointer").With("got", v.Type)
+	}
+	return v.Absolute(), nil
+}
+
+// Peek returns the value index values down from the top of the stack.
+func (s *Stack) Peek(ctx log.Context, index int) (Value, error) {
+	if index < 0 || index >= len(s.values) {
+		return Value{}, cause.Explain(ctx, ErrStackUnderflow, "Peek").With("index", index).With("size", len(s.values))
+	}
+	return s.values[len(s.values)-1-index], nil
+}
+
+// Discard removes count values from the top of the stack.
+func (s *Stack) Discard(ctx log.Context, count int) error {
+	if count > len(s.values) {
+		return cause.Explain(ctx, ErrStackUnderflow, "Discard").With("count", count).With("size", len(s.values))
+	}
+	s.values = s.values[:len(s.values)-count]
+	return nil
+}
@@ -0,0 +1,432 @@
+// Copyright (C) 2017 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package vm
+
+import (
+	"bytes"
+	"io"
+	"math"
+
+	"github.com/google/gapid/core/data/endian"
+	"github.com/google/gapid/core/data/id"
+	"github.com/google/gapid/core/fault"
+	"github.com/google/gapid/core/fault/cause"
+	"github.com/google/gapid/core/log"
+	"github.com/google/gapid/core/os/device"
+	"github.com/google/gapid/gapis/database"
+	"github.com/google/gapid/gapis/replay/opcode"
+	"github.com/google/gapid/gapis/replay/protocol"
+)
+
+const (
+	ErrStackOverflow   = fault.Const("Stack overflow")
+	ErrStackUnderflow  = fault.Const("Stack underflow")
+	ErrStackNotEmpty   = fault.Const("Stack not empty at end of replay")
+	ErrTypeMismatch    = fault.Const("Type mismatch")
+	ErrInvalidType     = fault.Const("Invalid type")
+	ErrInvalidAddress  = fault.Const("Invalid address")
+	ErrUnknownFunction = fault.Const("Unknown function")
+	ErrInvalidResource = fault.Const("Invalid resource")
+)
+
+// ResourceProvider returns the data of the resource with the given identifier.
+type ResourceProvider func(ctx log.Context, id string) ([]byte, error)
+
+// Machine is an interpreter of replay payloads.
+type Machine struct {
+	Stack     *Stack           // The value stack.
+	Memory    *Memory          // The constant, volatile and allocated memory.
+	Label     uint32           // The last label reached.
+	Calls     []Call           // The calls recorded by the functions.
+	Functions Functions        // The functions callable by the payload.
+	Resources ResourceProvider // The provider of resource data.
+
+	payload   protocol.Payload
+	layout    *device.MemoryLayout
+	postbacks bytes.Buffer
+}
+
+// New returns a new Machine that interprets payload built for a device with
+// the given memory layout, dispatching calls to functions.
+// Resources are resolved from the database.
+func New(payload protocol.Payload, layout *device.MemoryLayout, functions Functions) *Machine {
+	return &Machine{
+		Stack:     &Stack{capacity: int(payload.StackSize)},
+		Memory:    newMemory(layout, payload.Constants, payload.VolatileMemorySize),
+		Functions: functions,
+		Resources: resolveResource,
+		payload:   payload,
+		layout:    layout,
+	}
+}
+
+// Postbacks returns the data posted back by the payload so far.
+func (m *Machine) Postbacks() []byte {
+	return m.postbacks.Bytes()
+}
+
+// Execute interprets payload, then passes the postback data to decoder.
+// Execute has the same form as executor.Execute, and can be used in its place
+// to replay without a device.
+func Execute(
+	ctx log.Context,
+	payload protocol.Payload,
+	decoder func(r io.Reader, err error),
+	layout *device.MemoryLayout,
+	functions Functions) (*Machine, error) {
+
+	m := New(payload, layout, functions)
+	if err := m.Run(ctx); err != nil {
+		decoder(nil, err)
+		return m, err
+	}
+	decoder(bytes.NewReader(m.Postbacks()), nil)
+	return m, nil
+}
+
+// Run interprets all the opcodes of the payload. Run fails if any opcode
+// cannot be interpreted, or if values are left on the stack once all the
+// opcodes have been interpreted.
+func (m *Machine) Run(ctx log.Context) error {
+	r := endian.Reader(bytes.NewReader(m.payload.Opcodes), m.layout.GetEndian())
+	for i := 0; ; i++ {
+		op, err := opcode.Decode(r)
+		switch err {
+		case nil:
+		case io.EOF:
+			if n := m.Stack.Len(); n != 0 {
+				return cause.Explain(ctx, ErrStackNotEmpty, "Replay finished").With("size", n).With("label", m.Label)
+			}
+			return nil
+		default:
+			return cause.Explain(ctx, err, "Decoding opcode").With("index", i).With("label", m.Label)
+		}
+		if err := m.interpret(ctx, op); err != nil {
+			return cause.Explain(ctx, err, "Interpreting opcode").
+				With("index", i).With("opcode", op).With("label", m.Label)
+		}
+	}
+}
+
+func (m *Machine) interpret(ctx log.Context, op interface{}) error {
+	switch op := op.(type) {
+	case opcode.Call:
+		return m.call(ctx, op)
+	case opcode.PushI:
+		return m.pushI(ctx, op)
+	case opcode.LoadC:
+		return m.load(ctx, op.DataType, constantBase+uint64(op.Address))
+	case opcode.LoadV:
+		return m.load(ctx, op.DataType, volatileBase+uint64(op.Address))
+	case opcode.Load:
+		addr, err := m.Stack.PopPointer(ctx)
+		if err != nil {
+			return err
+		}
+		return m.load(ctx, op.DataType, addr)
+	case opcode.Pop:
+		return m.Stack.Discard(ctx, int(op.Count))
+	case opcode.StoreV:
+		return m.store(ctx, volatileBase+uint64(op.Address))
+	case opcode.Store:
+		addr, err := m.Stack.PopPointer(ctx)
+		if err != nil {
+			return err
+		}
+		return m.store(ctx, addr)
+	case opcode.Resource:
+		return m.resource(ctx, op.ID)
+	case opcode.Post:
+		return m.post(ctx)
+	case opcode.Copy:
+		return m.copy(ctx, uint64(op.Count))
+	case opcode.Clone:
+		v, err := m.Stack.Peek(ctx, int(op.Index))
+		if err != nil {
+			return err
+		}
+		return m.Stack.Push(ctx, v)
+	case opcode.Strcpy:
+		return m.strcpy(ctx, uint64(op.MaxSize))
+	case opcode.Extend:
+		return m.extend(ctx, op.Value)
+	case opcode.Add:
+		return m.add(ctx, int(op.Count))
+	case opcode.Label:
+		m.Label = op.Value
+		return nil
+	default:
+		return cause.Explain(ctx, nil, "Unknown opcode").With("opcode", op)
+	}
+}
+
+// size returns the size in bytes of a value of type ty in memory.
+func (m *Machine) size(ctx log.Context, ty protocol.Type) (uint64, error) {
+	switch ty {
+	case protocol.Type_Bool,
+		protocol.Type_Int8, protocol.Type_Int16, protocol.Type_Int32, protocol.Type_Int64,
+		protocol.Type_Uint8, protocol.Type_Uint16, protocol.Type_Uint32, protocol.Type_Uint64,
+		protocol.Type_Float, protocol.Type_Double,
+		protocol.Type_AbsolutePointer, protocol.Type_ConstantPointer, protocol.Type_VolatilePointer:
+		return uint64(ty.Size(m.layout.GetPointerSize())), nil
+	default:
+		return 0, cause.Explain(ctx, ErrInvalidType, "Size").With("type", ty)
+	}
+}
+
+// push pushes a value of type ty to the stack, truncating bits to the size of
+// the type.
+func (m *Machine) push(ctx log.Context, ty protocol.Type, bits uint64) error {
+	size, err := m.size(ctx, ty)
+	if err != nil {
+		return err
+	}
+	if size < 8 {
+		bits &= (1 << (size * 8)) - 1
+	}
+	return m.Stack.Push(ctx, Value{Type: ty, Bits: bits})
+}
+
+func (m *Machine) call(ctx log.Context, op opcode.Call) error {
+	switch op.FunctionID {
+	case postFunctionID:
+		return m.post(ctx)
+	case resourceFunctionID:
+		idx, err := m.Stack.PopType(ctx, protocol.Type_Uint32)
+		if err != nil {
+			return err
+		}
+		return m.resource(ctx, uint32(idx.Bits))
+	case printStackFunctionID:
+		for i, v := range m.Stack.values {
+			ctx.Info().Logf("(%d) %v", i, v)
+		}
+		return nil
+	}
+	f, ok := m.Functions[FunctionID{ApiIndex: op.ApiIndex, ID: op.FunctionID}]
+	if !ok {
+		return cause.Explain(ctx, ErrUnknownFunction, "Call").With("api", op.ApiIndex).With("function", op.FunctionID)
+	}
+	return f(ctx, m, op.PushReturn)
+}
+
+func (m *Machine) pushI(ctx log.Context, op opcode.PushI) error {
+	data := uint64(op.Value)
+	switch op.DataType {
+	case protocol.Type_Int32, protocol.Type_Int64:
+		// Sign extension for signed types.
+		if data&0x80000 != 0 {
+			data |= 0xfffffffffff00000
+		}
+	case protocol.Type_Float:
+		// Shift the value into the exponent for floating point types.
+		data <<= 23
+	case protocol.Type_Double:
+		data <<= 52
+	}
+	return m.push(ctx, op.DataType, data)
+}
+
+func (m *Machine) load(ctx log.Context, ty protocol.Type, addr uint64) error {
+	size, err := m.size(ctx, ty)
+	if err != nil {
+		return err
+	}
+	v, err := m.Memory.readValue(ctx, addr, size)
+	if err != nil {
+		return err
+	}
+	return m.push(ctx, ty, v)
+}
+
+// store pops the value at the top of the stack, writing it to addr.
+// Pointers are converted to absolute pointers before they are written.
+func (m *Machine) store(ctx log.Context, addr uint64) error {
+	v, err := m.Stack.Pop(ctx)
+	if err != nil {
+		return err
+	}
+	size, err := m.size(ctx, v.Type)
+	if err != nil {
+		return err
+	}
+	bits := v.Bits
+	if v.IsPointer() {
+		bits = v.Absolute()
+	}
+	return m.Memory.writeValue(ctx, addr, size, bits)
+}
+
+// resource pops the target address from the stack, and writes the data of the
+// resource with the index idx to it.
+func (m *Machine) resource(ctx log.Context, idx uint32) error {
+	addr, err := m.Stack.PopPointer(ctx)
+	if err != nil {
+		return err
+	}
+	if int(idx) >= len(m.payload.Resources) {
+		return cause.Explain(ctx, ErrInvalidResource, "Resource index out of range").
+			With("index", idx).With("count", len(m.payload.Resources))
+	}
+	info := m.payload.Resources[idx]
+	data, err := m.Resources(ctx, info.ID)
+	if err != nil {
+		return err
+	}
+	if len(data) != int(info.Size) {
+		return cause.Explain(ctx, ErrInvalidResource, "Resource size mismatch").
+			With("id", info.ID).With("expected", info.Size).With("got", len(data))
+	}
+	return m.Memory.Write(ctx, addr, data)
+}
+
+// post pops the size and source address from the stack, and appends the
+// source data to the postbacks.
+func (m *Machine) post(ctx log.Context) error {
+	count, err := m.Stack.PopType(ctx, protocol.Type_Uint32)
+	if err != nil {
+		return err
+	}
+	addr, err := m.Stack.PopPointer(ctx)
+	if err != nil {
+		return err
+	}
+	data, err := m.Memory.Read(ctx, addr, count.Bits)
+	if err != nil {
+		return err
+	}
+	m.postbacks.Write(data)
+	return nil
+}
+
+func (m *Machine) copy(ctx log.Context, count uint64) error {
+	target, err := m.Stack.PopPointer(ctx)
+	if err != nil {
+		return err
+	}
+	source, err := m.Stack.PopPointer(ctx)
+	if err != nil {
+		return err
+	}
+	data, err := m.Memory.Read(ctx, source, count)
+	if err != nil {
+		return err
+	}
+	return m.Memory.Write(ctx, target, append([]byte{}, data...))
+}
+
+func (m *Machine) strcpy(ctx log.Context, maxSize uint64) error {
+	target, err := m.Stack.PopPointer(ctx)
+	if err != nil {
+		return err
+	}
+	source, err := m.Stack.PopPointer(ctx)
+	if err != nil {
+		return err
+	}
+	// The whole target must be writable, even if the source is shorter.
+	out := make([]byte, maxSize)
+	for i := uint64(0); i+1 < maxSize; i++ {
+		c, err := m.Memory.Read(ctx, source+i, 1)
+		if err != nil {
+			return err
+		}
+		if c[0] == 0 {
+			break
+		}
+		out[i] = c[0]
+	}
+	return m.Memory.Write(ctx, target, out)
+}
+
+func (m *Machine) extend(ctx log.Context, data uint32) error {
+	v, err := m.Stack.Pop(ctx)
+	if err != nil {
+		return err
+	}
+	switch v.Type {
+	case protocol.Type_Float:
+		// Extend the mantissa for floating point types.
+		v.Bits |= uint64(data) & 0x007fffff
+	case protocol.Type_Double:
+		exponent := v.Bits & 0xfff0000000000000
+		v.Bits = ((v.Bits<<26)|uint64(data))&0x000fffffffffffff | exponent
+	default:
+		v.Bits = (v.Bits << 26) | uint64(data)
+	}
+	return m.push(ctx, v.Type, v.Bits)
+}
+
+func (m *Machine) add(ctx log.Context, count int) error {
+	if count < 2 {
+		return nil
+	}
+	top, err := m.Stack.Peek(ctx, 0)
+	if err != nil {
+		return err
+	}
+	ty := top.Type
+	switch ty {
+	case protocol.Type_VolatilePointer, protocol.Type_Bool:
+		return cause.Explain(ctx, ErrTypeMismatch, "Add").With("type", ty)
+	case protocol.Type_ConstantPointer:
+		// Pointers are summed as absolute pointers.
+		ty = protocol.Type_AbsolutePointer
+	}
+	var sum uint64
+	var fsum float64
+	for i := 0; i < count; i++ {
+		v, err := m.Stack.Pop(ctx)
+		if err != nil {
+			return err
+		}
+		if v.Type != top.Type {
+			return cause.Explain(ctx, ErrTypeMismatch, "Add").With("expected", top.Type).With("got", v.Type)
+		}
+		switch ty {
+		case protocol.Type_Float:
+			fsum += float64(math.Float32frombits(uint32(v.Bits)))
+		case protocol.Type_Double:
+			fsum += math.Float64frombits(v.Bits)
+		default:
+			sum += v.Absolute()
+		}
+	}
+	switch ty {
+	case protocol.Type_Float:
+		sum = uint64(math.Float32bits(float32(fsum)))
+	case protocol.Type_Double:
+		sum = math.Float64bits(fsum)
+	}
+	return m.push(ctx, ty, sum)
+}
+
+// resolveResource is the default ResourceProvider, which resolves resources
+// from the database.
+func resolveResource(ctx log.Context, resourceID string) ([]byte, error) {
+	rid, err := id.Parse(resourceID)
+	if err != nil {
+		return nil, cause.Explain(ctx, err, "Failed to parse resource ID").With("id", resourceID)
+	}
+	obj, err := database.Resolve(ctx, rid)
+	if err != nil {
+		return nil, cause.Explain(ctx, err, "Failed to resolve resource").With("id", resourceID)
+	}
+	data, ok := obj.([]byte)
+	if !ok {
+		return nil, cause.Explain(ctx, ErrInvalidResource, "Resource did not resolve to bytes").With("id", resourceID)
+	}
+	return data, nil
+}
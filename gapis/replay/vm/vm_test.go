@@ -0,0 +1,252 @@
+// Copyright (C) 2017 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package vm_test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/google/gapid/core/assert"
+	"github.com/google/gapid/core/data/endian"
+	"github.com/google/gapid/core/data/pod"
+	"github.com/google/gapid/core/log"
+	"github.com/google/gapid/core/os/device"
+	"github.com/google/gapid/gapis/replay/builder"
+	"github.com/google/gapid/gapis/replay/opcode"
+	"github.com/google/gapid/gapis/replay/protocol"
+	"github.com/google/gapid/gapis/replay/value"
+	"github.com/google/gapid/gapis/replay/vm"
+)
+
+var increment = builder.FunctionInfo{ApiIndex: 1, ID: 10, ReturnType: protocol.Type_Uint32, Parameters: 1}
+
+// incrementFunction returns its uint32 argument plus one.
+func incrementFunction(ctx log.Context, m *vm.Machine, pushReturn bool) error {
+	v, err := m.Stack.PopType(ctx, protocol.Type_Uint32)
+	if err != nil {
+		return err
+	}
+	if pushReturn {
+		return m.Stack.Push(ctx, vm.Value{Type: protocol.Type_Uint32, Bits: v.Bits + 1})
+	}
+	return nil
+}
+
+func TestBuilderPayload(t *testing.T) {
+	ctx := log.Testing(t)
+	for _, layout := range []*device.MemoryLayout{device.Little32, device.Big64} {
+		ctx := ctx.V("layout", layout)
+		b := builder.New(layout)
+		ptr := b.AllocateMemory(4)
+
+		b.BeginAtom(1)
+		b.Push(value.U32(41))
+		b.Call(increment)
+		b.Store(ptr)
+		got := make(chan uint32, 1)
+		b.Post(ptr, 4, func(d pod.Reader, err error) error {
+			if err != nil {
+				return err
+			}
+			got <- d.Uint32()
+			return d.Error()
+		})
+		b.CommitAtom()
+
+		b.BeginAtom(2)
+		b.Push(value.U32(7))
+		b.Call(builder.FunctionInfo{ApiIndex: 1, ID: 11, ReturnType: protocol.Type_Void, Parameters: 1})
+		b.CommitAtom()
+
+		payload, decoder, err := b.Build(ctx)
+		if !assert.With(ctx).ThatError(err).Succeeded() {
+			continue
+		}
+
+		record := vm.FunctionID{ApiIndex: 1, ID: 11}
+		m, err := vm.Execute(ctx, payload, decoder, layout, vm.Functions{
+			{ApiIndex: 1, ID: 10}: incrementFunction,
+			record:                vm.Record(record, vm.Signature{Parameters: 1, ReturnType: protocol.Type_Void}),
+		})
+		if !assert.With(ctx).ThatError(err).Succeeded() {
+			continue
+		}
+		assert.With(ctx).That(<-got).Equals(uint32(42))
+		assert.With(ctx).That(m.Calls).DeepEquals([]vm.Call{
+			{FunctionID: record, Label: 2, Args: []vm.Value{{Type: protocol.Type_Uint32, Bits: 7}}},
+		})
+	}
+}
+
+func encode(ctx log.Context, ops ...interface {
+	Encode(pod.Writer) error
+}) []byte {
+	buf := &bytes.Buffer{}
+	w := endian.Writer(buf, device.LittleEndian)
+	for _, op := range ops {
+		assert.With(ctx).ThatError(op.Encode(w)).Succeeded()
+	}
+	return buf.Bytes()
+}
+
+func TestInvalidPayloads(t *testing.T) {
+	ctx := log.Testing(t)
+	for _, test := range []struct {
+		name     string
+		payload  protocol.Payload
+		expected error
+	}{
+		{
+			"stack not empty",
+			protocol.Payload{StackSize: 8, Opcodes: encode(ctx,
+				opcode.PushI{DataType: protocol.Type_Uint32, Value: 1},
+			)},
+			vm.ErrStackNotEmpty,
+		},
+		{
+			"stack underflow",
+			protocol.Payload{StackSize: 8, Opcodes: encode(ctx,
+				opcode.PushI{DataType: protocol.Type_Uint32, Value: 1},
+				opcode.Pop{Count: 2},
+			)},
+			vm.ErrStackUnderflow,
+		},
+		{
+			"stack overflow",
+			protocol.Payload{StackSize: 1, Opcodes: encode(ctx,
+				opcode.PushI{DataType: protocol.Type_Uint32, Value: 1},
+				opcode.Clone{Index: 0},
+			)},
+			vm.ErrStackOverflow,
+		},
+		{
+			"unknown function",
+			protocol.Payload{StackSize: 8, Opcodes: encode(ctx,
+				opcode.Call{ApiIndex: 1, FunctionID: 99},
+			)},
+			vm.ErrUnknownFunction,
+		},
+		{
+			"load out of volatile memory",
+			protocol.Payload{StackSize: 8, VolatileMemorySize: 4, Opcodes: encode(ctx,
+				opcode.LoadV{DataType: protocol.Type_Uint32, Address: 2},
+			)},
+			vm.ErrInvalidAddress,
+		},
+		{
+			"store to constant memory",
+			protocol.Payload{StackSize: 8, Constants: make([]byte, 4), Opcodes: encode(ctx,
+				opcode.PushI{DataType: protocol.Type_Uint32, Value: 1},
+				opcode.PushI{DataType: protocol.Type_ConstantPointer, Value: 0},
+				opcode.Store{},
+			)},
+			vm.ErrInvalidAddress,
+		},
+		{
+			"unobserved pointer",
+			protocol.Payload{StackSize: 8, Opcodes: encode(ctx,
+				opcode.PushI{DataType: protocol.Type_AbsolutePointer, Value: 0xBADF00D >> 26},
+				opcode.Extend{Value: 0xBADF00D & 0x3ffffff},
+				opcode.Load{DataType: protocol.Type_Uint32},
+			)},
+			vm.ErrInvalidAddress,
+		},
+		{
+			"post size type mismatch",
+			protocol.Payload{StackSize: 8, VolatileMemorySize: 4, Opcodes: encode(ctx,
+				opcode.PushI{DataType: protocol.Type_VolatilePointer, Value: 0},
+				opcode.PushI{DataType: protocol.Type_Uint8, Value: 4},
+				opcode.Post{},
+			)},
+			vm.ErrTypeMismatch,
+		},
+	} {
+		ctx := ctx.S("test", test.name)
+		err := vm.New(test.payload, device.Little32, vm.Functions{}).Run(ctx)
+		assert.With(ctx).ThatError(err).HasCause(test.expected)
+	}
+}
+
+func TestWrappedPointers(t *testing.T) {
+	ctx := log.Testing(t)
+	// 0xfffffffffffffff8 is 8 bytes below 2^64, so a 16 byte access wraps
+	// around to the start of the address-space.
+	wrapped := []interface {
+		Encode(pod.Writer) error
+	}{
+		opcode.PushI{DataType: protocol.Type_AbsolutePointer, Value: 0xfff},
+		opcode.Extend{Value: 0x3ffffff},
+		opcode.Extend{Value: 0x3fffff8},
+	}
+	for _, test := range []struct {
+		name string
+		ops  []interface {
+			Encode(pod.Writer) error
+		}
+	}{
+		{"post", append(wrapped,
+			opcode.PushI{DataType: protocol.Type_Uint32, Value: 16},
+			opcode.Post{},
+		)},
+		{"copy", append(wrapped,
+			opcode.PushI{DataType: protocol.Type_VolatilePointer, Value: 0},
+			opcode.Copy{Count: 16},
+		)},
+	} {
+		ctx := ctx.S("test", test.name)
+		payload := protocol.Payload{
+			StackSize:          8,
+			VolatileMemorySize: 16,
+			Constants:          make([]byte, 16),
+			Opcodes:            encode(ctx, test.ops...),
+		}
+		err := vm.New(payload, device.Big64, vm.Functions{}).Run(ctx)
+		assert.With(ctx).ThatError(err).HasCause(vm.ErrInvalidAddress)
+	}
+}
+
+func TestArithmetic(t *testing.T) {
+	ctx := log.Testing(t)
+	payload := protocol.Payload{StackSize: 8, VolatileMemorySize: 12, Opcodes: encode(ctx,
+		// volatile[0] = int32(-3) + int32(5)
+		opcode.PushI{DataType: protocol.Type_Int32, Value: 0xffffd},
+		opcode.PushI{DataType: protocol.Type_Int32, Value: 5},
+		opcode.Add{Count: 2},
+		opcode.StoreV{Address: 0},
+		// volatile[4] = 1.5f
+		opcode.PushI{DataType: protocol.Type_Float, Value: 0x7f},
+		opcode.Extend{Value: 0x400000},
+		opcode.StoreV{Address: 4},
+		// volatile[8] = volatile pointer 4, stored as an absolute pointer.
+		opcode.PushI{DataType: protocol.Type_VolatilePointer, Value: 4},
+		opcode.StoreV{Address: 8},
+		// Post all 12 bytes.
+		opcode.PushI{DataType: protocol.Type_VolatilePointer, Value: 0},
+		opcode.PushI{DataType: protocol.Type_Uint32, Value: 12},
+		opcode.Post{},
+	)}
+	m := vm.New(payload, device.Little32, vm.Functions{})
+	if !assert.With(ctx).ThatError(m.Run(ctx)).Succeeded() {
+		return
+	}
+	r := endian.Reader(bytes.NewReader(m.Postbacks()), device.LittleEndian)
+	assert.With(ctx).That(r.Int32()).Equals(int32(2))
+	assert.With(ctx).That(r.Float32()).Equals(float32(1.5))
+	ptr := r.Uint32()
+	data, err := m.Memory.Read(ctx, uint64(ptr), 4)
+	if assert.With(ctx).ThatError(err).Succeeded() {
+		assert.With(ctx).ThatSlice(data).Equals([]byte{0, 0, 0xc0, 0x3f})
+	}
+}
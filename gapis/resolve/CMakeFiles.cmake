@@ -19,17 +19,27 @@
 
 set(files
     as.go
+    bookmarks.go
     contexts.go
+    draw_call_state.go
+    find_commands.go
+    find_commands_test.go
     follow.go
     framebuffer_attachment.go
     framebuffer_attachment_data.go
+    framebuffer_attachments.go
     framebuffer_changes.go
     get.go
     get_set_test.go
     hierarchies.go
     index_limits.go
     memory.go
+    memory_structure.go
+    memory_structure_test.go
+    pixel_history.go
+    pixel_history_test.go
     report.go
+    report_test.go
     requests_test.go
     resolvables.pb.go
     resolvables.proto
@@ -38,7 +48,10 @@ set(files
     resource_meta.go
     resources.go
     set.go
+    shader_trace.go
     state.go
+    stats.go
+    stats_test.go
     thumbnail.go
 )
 set(dirs
@@ -39,6 +39,14 @@ func As(ctx log.Context, p *path.As) (interface{}, error) {
 			return o.ConvertTo(ctx, f)
 		case *gfxapi.Cubemap:
 			return o.ConvertTo(ctx, f)
+		case *gfxapi.Texture1D:
+			return o.ConvertTo(ctx, f)
+		case *gfxapi.Texture3D:
+			return o.ConvertTo(ctx, f)
+		case *gfxapi.Texture2DArray:
+			return o.ConvertTo(ctx, f)
+		case *gfxapi.CubemapArray:
+			return o.ConvertTo(ctx, f)
 		}
 	case *path.As_VertexBufferFormat:
 		f := to.VertexBufferFormat
@@ -46,6 +54,12 @@ func As(ctx log.Context, p *path.As) (interface{}, error) {
 		case *gfxapi.Mesh:
 			return o.ConvertTo(ctx, f)
 		}
+	case *path.As_MeshFormat:
+		f := to.MeshFormat
+		switch o := o.(type) {
+		case *gfxapi.Mesh:
+			return o.Export(ctx, f)
+		}
 	}
 	return nil, &service.ErrDataUnavailable{Reason: messages.ErrUnsupportedConversion()}
 }
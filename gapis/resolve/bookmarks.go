@@ -0,0 +1,164 @@
+// Copyright (C) 2017 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package resolve
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/google/gapid/core/log"
+	"github.com/google/gapid/gapis/capture"
+	"github.com/google/gapid/gapis/messages"
+	"github.com/google/gapid/gapis/service"
+	"github.com/google/gapid/gapis/service/path"
+)
+
+// Bookmarks resolves and returns the list of bookmarks from the path p.
+func Bookmarks(ctx log.Context, p *path.Bookmarks) ([]*service.Bookmark, error) {
+	list, err := capture.Bookmarks(ctx, p.Capture)
+	if err != nil {
+		return nil, err
+	}
+	commands := p.Capture.Commands()
+	out := make([]*service.Bookmark, len(list))
+	for i, b := range list {
+		out[i] = &service.Bookmark{
+			Command:   commands.Index(b.Command),
+			Label:     b.Label,
+			Text:      b.Text,
+			Author:    b.Author,
+			Timestamp: b.Timestamp,
+		}
+	}
+	return out, nil
+}
+
+// Bookmark resolves and returns the bookmark from the path p.
+func Bookmark(ctx log.Context, p *path.Bookmark) (*service.Bookmark, error) {
+	list, err := Bookmarks(ctx, p.Bookmarks)
+	if err != nil {
+		return nil, err
+	}
+	switch count := uint64(len(list)); {
+	case count == 0:
+		return nil, &service.ErrInvalidPath{
+			Reason: messages.ErrNoBookmarks(),
+			Path:   p.Path(),
+		}
+	case p.Index >= count:
+		return nil, &service.ErrInvalidPath{
+			Reason: messages.ErrValueOutOfBounds(p.Index, "Index", uint64(0), count-1),
+			Path:   p.Path(),
+		}
+	}
+	return list[p.Index], nil
+}
+
+// changeBookmarks replaces the bookmarks of the capture of p with val,
+// returning p. The capture is unchanged.
+func changeBookmarks(ctx log.Context, p *path.Bookmarks, val interface{}) (*path.Bookmarks, error) {
+	list, ok := val.([]*service.Bookmark)
+	if !ok {
+		return nil, fmt.Errorf("Expected []*service.Bookmark, got %T", val)
+	}
+	atoms, err := Commands(ctx, p.Capture.Commands())
+	if err != nil {
+		return nil, err
+	}
+	count := uint64(len(atoms.Atoms))
+	now := time.Now().UnixNano() / int64(time.Millisecond)
+	bookmarks := make([]*capture.Bookmark, len(list))
+	for i, b := range list {
+		if b == nil || b.Command == nil {
+			return nil, fmt.Errorf("Bookmark %d has no command", i)
+		}
+		if c := b.Command.Commands; c == nil || c.Capture == nil || c.Capture.Id.ID() != p.Capture.Id.ID() {
+			return nil, &service.ErrInvalidPath{
+				Reason: messages.ErrPathWrongCapture(),
+				Path:   b.Command.Path(),
+			}
+		}
+		switch {
+		case count == 0:
+			return nil, &service.ErrInvalidPath{
+				Reason: messages.ErrNoCommands(),
+				Path:   b.Command.Path(),
+			}
+		case b.Command.Index >= count:
+			return nil, &service.ErrInvalidPath{
+				Reason: messages.ErrValueOutOfBounds(b.Command.Index, "Index", uint64(0), count-1),
+				Path:   b.Command.Path(),
+			}
+		}
+		timestamp := b.Timestamp
+		if timestamp == 0 {
+			timestamp = now
+		}
+		bookmarks[i] = &capture.Bookmark{
+			Command:   b.Command.Index,
+			Label:     b.Label,
+			Text:      b.Text,
+			Author:    b.Author,
+			Timestamp: timestamp,
+		}
+	}
+	if err := capture.SetBookmarks(ctx, p.Capture, bookmarks); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// changeBookmark replaces the bookmark at p with val, returning p. If p
+// indexes one past the last bookmark then val is appended, and if val is nil
+// then the bookmark is removed. The capture is unchanged.
+func changeBookmark(ctx log.Context, p *path.Bookmark, val interface{}) (*path.Bookmark, error) {
+	list, err := Bookmarks(ctx, p.Bookmarks)
+	if err != nil {
+		return nil, err
+	}
+	switch count := uint64(len(list)); {
+	case val == nil && count == 0:
+		return nil, &service.ErrInvalidPath{
+			Reason: messages.ErrNoBookmarks(),
+			Path:   p.Path(),
+		}
+	case val == nil && p.Index >= count:
+		return nil, &service.ErrInvalidPath{
+			Reason: messages.ErrValueOutOfBounds(p.Index, "Index", uint64(0), count-1),
+			Path:   p.Path(),
+		}
+	case p.Index > count:
+		return nil, &service.ErrInvalidPath{
+			Reason: messages.ErrValueOutOfBounds(p.Index, "Index", uint64(0), count),
+			Path:   p.Path(),
+		}
+	}
+	switch val := val.(type) {
+	case nil:
+		list = append(list[:p.Index], list[p.Index+1:]...)
+	case *service.Bookmark:
+		if p.Index == uint64(len(list)) {
+			list = append(list, val)
+		} else {
+			list[p.Index] = val
+		}
+	default:
+		return nil, fmt.Errorf("Expected *service.Bookmark, got %T", val)
+	}
+	if _, err := changeBookmarks(ctx, p.Bookmarks, list); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
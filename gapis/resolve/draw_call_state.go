@@ -0,0 +1,68 @@
+// Copyright (C) 2017 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package resolve
+
+import (
+	"github.com/google/gapid/core/log"
+	"github.com/google/gapid/gapis/capture"
+	"github.com/google/gapid/gapis/database"
+	"github.com/google/gapid/gapis/gfxapi"
+	"github.com/google/gapid/gapis/messages"
+	"github.com/google/gapid/gapis/service"
+	"github.com/google/gapid/gapis/service/path"
+)
+
+// DrawCallState resolves the program, uniform, texture and vertex attribute
+// state used by the draw call at p.
+func DrawCallState(ctx log.Context, p *path.DrawCallState) (*gfxapi.DrawCallState, error) {
+	obj, err := database.Build(ctx, &DrawCallStateResolvable{p})
+	if err != nil {
+		return nil, err
+	}
+	return obj.(*gfxapi.DrawCallState), nil
+}
+
+// Resolve implements the database.Resolver interface.
+func (r *DrawCallStateResolvable) Resolve(ctx log.Context) (interface{}, error) {
+	ctx = capture.Put(ctx, r.Path.After.Commands.Capture)
+
+	cmd, err := Command(ctx, r.Path.After)
+	if err != nil {
+		return nil, err
+	}
+	api := cmd.API()
+	if api == nil {
+		return nil, &service.ErrDataUnavailable{Reason: messages.ErrDrawCallStateNotAvailable()}
+	}
+	provider, ok := api.(gfxapi.DrawCallStateProvider)
+	if !ok {
+		return nil, &service.ErrDataUnavailable{Reason: messages.ErrDrawCallStateNotAvailable()}
+	}
+
+	state := capture.NewState(ctx)
+	IDMap := gfxapi.ResourceMap{}
+	if err := mutateWithResourceIDs(ctx, state, r.Path.After, IDMap); err != nil {
+		return nil, err
+	}
+
+	out, err := provider.DrawCallState(ctx, cmd, state, IDMap)
+	if err != nil {
+		return nil, err
+	}
+	if out == nil {
+		return nil, &service.ErrDataUnavailable{Reason: messages.ErrDrawCallStateNotAvailable()}
+	}
+	return out, nil
+}
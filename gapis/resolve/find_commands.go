@@ -0,0 +1,258 @@
+// Copyright (C) 2017 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package resolve
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/google/gapid/core/data/search"
+	"github.com/google/gapid/core/data/search/eval"
+	"github.com/google/gapid/core/event"
+	"github.com/google/gapid/core/event/task"
+	"github.com/google/gapid/core/log"
+	"github.com/google/gapid/core/math/interval"
+	"github.com/google/gapid/gapis/atom"
+	"github.com/google/gapid/gapis/capture"
+	"github.com/google/gapid/gapis/service/path"
+	"github.com/google/gapid/gapis/status"
+)
+
+// FindCommands searches the commands of a capture for those that match the
+// query held by p, calling f with the path of each matching command in order.
+//
+// The query is evaluated against a record for each command with the fields:
+//
+//	Index  - the index of the command.
+//	Name   - the name of the command, for example "glDrawArrays".
+//	API    - the name of the command's API, or "" if it has none.
+//	Params - a struct holding the command's parameters.
+//	Result - the command's return value, if it has one.
+//
+// Integer parameters are presented as int64, floating-point parameters as
+// float64 and enumerations as their string names.
+//
+// If f returns an error then the search is stopped and the error is returned.
+func FindCommands(ctx log.Context, p *path.CommandSearch, f func(*path.Command) error) error {
+	ctx = capture.Put(ctx, p.Commands.Capture)
+
+	c, err := capture.Resolve(ctx)
+	if err != nil {
+		return err
+	}
+	list, err := c.Atoms(ctx)
+	if err != nil {
+		return err
+	}
+	atoms := list.Atoms
+
+	first, end := p.First, uint64(len(atoms))
+	if p.Count > 0 && first+p.Count < end {
+		end = first + p.Count
+	}
+
+	var ranges atom.RangeList
+	if p.Context != nil {
+		context, err := Context(ctx, p.Context)
+		if err != nil {
+			return err
+		}
+		for _, r := range context.Ranges {
+			ranges = append(ranges, atom.Range{Start: r.First, End: r.First + r.Count})
+		}
+	}
+
+	query := p.Query
+	if query == nil {
+		query = &search.Query{} // Matches everything.
+	}
+
+	s := &commandSearcher{types: map[reflect.Type]*commandRecord{}}
+	for i := first; i < end; i++ {
+		if err := task.StopReason(ctx); err != nil {
+			return err
+		}
+		status.UpdateProgress(ctx, i-first, end-first)
+		if p.Context != nil && !interval.Contains(ranges, i) {
+			continue
+		}
+		r := s.record(ctx, query, atoms[i])
+		if r.pred == nil {
+			continue
+		}
+		if r.pred(ctx, r.build(i, atoms[i])) {
+			if err := f(p.Commands.Index(i)); err != nil {
+				return err
+			}
+		}
+	}
+
+	if s.compiled == 0 && s.err != nil {
+		// The query could not be applied to any of the commands searched.
+		return s.err
+	}
+	return nil
+}
+
+// commandSearcher holds the compiled query for each of the atom types seen by
+// a search.
+type commandSearcher struct {
+	types    map[reflect.Type]*commandRecord
+	compiled int   // The number of atom types the query was compiled for.
+	err      error // The last error returned when compiling the query.
+}
+
+// commandRecord describes the record searched for a single atom type.
+type commandRecord struct {
+	pred   event.Predicate // nil if the query could not be compiled.
+	params []paramField
+	build  func(index uint64, a atom.Atom) interface{}
+}
+
+// paramField maps an atom field to a field of the record's Params struct.
+type paramField struct {
+	index   []int
+	convert func(reflect.Value) reflect.Value
+}
+
+func (s *commandSearcher) record(ctx log.Context, query *search.Query, a atom.Atom) *commandRecord {
+	t := reflect.TypeOf(a)
+	if r, ok := s.types[t]; ok {
+		return r
+	}
+	r := newCommandRecord(t)
+	klass := reflect.TypeOf(r.build(0, a))
+	pred, err := eval.Compile(ctx, query, klass)
+	if err == nil {
+		r.pred = pred
+		s.compiled++
+	} else {
+		s.err = err
+	}
+	s.types[t] = r
+	return r
+}
+
+func newCommandRecord(t reflect.Type) *commandRecord {
+	r := &commandRecord{}
+	st := t
+	if st.Kind() == reflect.Ptr {
+		st = st.Elem()
+	}
+
+	params := []reflect.StructField{}
+	var result *paramField
+	var resultType reflect.Type
+	if st.Kind() == reflect.Struct {
+		for i, c := 0, st.NumField(); i < c; i++ {
+			f := st.Field(i)
+			if f.PkgPath != "" || f.Anonymous {
+				continue // Unexported or embedded.
+			}
+			ty, convert := searchableType(f.Type)
+			if convert == nil {
+				continue
+			}
+			field := paramField{f.Index, convert}
+			if f.Name == "Result" {
+				result, resultType = &field, ty
+				continue
+			}
+			r.params = append(r.params, field)
+			params = append(params, reflect.StructField{Name: f.Name, Type: ty})
+		}
+	}
+
+	fields := []reflect.StructField{
+		{Name: "Index", Type: reflect.TypeOf(int64(0))},
+		{Name: "Name", Type: reflect.TypeOf("")},
+		{Name: "API", Type: reflect.TypeOf("")},
+		{Name: "Params", Type: reflect.StructOf(params)},
+	}
+	if result != nil {
+		fields = append(fields, reflect.StructField{Name: "Result", Type: resultType})
+	}
+	recordType := reflect.StructOf(fields)
+
+	r.build = func(index uint64, a atom.Atom) interface{} {
+		v := reflect.ValueOf(a)
+		if v.Kind() == reflect.Ptr {
+			v = v.Elem()
+		}
+		rec := reflect.New(recordType).Elem()
+		rec.Field(0).SetInt(int64(index))
+		rec.Field(1).SetString(atomName(a))
+		if api := a.API(); api != nil {
+			rec.Field(2).SetString(api.Name())
+		}
+		ps := rec.Field(3)
+		for i, f := range r.params {
+			ps.Field(i).Set(f.convert(v.FieldByIndex(f.index)))
+		}
+		if result != nil {
+			rec.Field(4).Set(result.convert(v.FieldByIndex(result.index)))
+		}
+		return rec.Interface()
+	}
+	return r
+}
+
+var stringerType = reflect.TypeOf((*fmt.Stringer)(nil)).Elem()
+
+// searchableType returns the type used to present values of type t to a
+// search query, and a function to convert values to that type. If values of
+// type t cannot be searched, then searchableType returns a nil function.
+func searchableType(t reflect.Type) (reflect.Type, func(reflect.Value) reflect.Value) {
+	str := func(v reflect.Value) reflect.Value {
+		return reflect.ValueOf(v.Interface().(fmt.Stringer).String())
+	}
+	switch t.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		if t.Implements(stringerType) {
+			// Enumerations are matched by name.
+			return reflect.TypeOf(""), str
+		}
+		signed := t.Kind() <= reflect.Int64
+		return reflect.TypeOf(int64(0)), func(v reflect.Value) reflect.Value {
+			if signed {
+				return reflect.ValueOf(v.Int())
+			}
+			return reflect.ValueOf(int64(v.Uint()))
+		}
+	case reflect.Float32, reflect.Float64:
+		return reflect.TypeOf(float64(0)), func(v reflect.Value) reflect.Value {
+			return reflect.ValueOf(v.Float())
+		}
+	case reflect.Bool:
+		return reflect.TypeOf(false), func(v reflect.Value) reflect.Value {
+			return reflect.ValueOf(v.Bool())
+		}
+	case reflect.String:
+		return reflect.TypeOf(""), func(v reflect.Value) reflect.Value {
+			return reflect.ValueOf(v.String())
+		}
+	}
+	if t.Implements(stringerType) {
+		return reflect.TypeOf(""), str
+	}
+	return nil, nil
+}
+
+func atomName(a atom.Atom) string {
+	name := a.Class().Schema().Name()
+	return strings.ToLower(name[:1]) + name[1:]
+}
@@ -0,0 +1,331 @@
+// Copyright (C) 2017 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package resolve
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/google/gapid/core/assert"
+	"github.com/google/gapid/core/data/search"
+	"github.com/google/gapid/core/image"
+	"github.com/google/gapid/core/log"
+	"github.com/google/gapid/framework/binary"
+	"github.com/google/gapid/gapis/atom"
+	"github.com/google/gapid/gapis/database"
+	"github.com/google/gapid/gapis/gfxapi"
+	"github.com/google/gapid/gapis/replay/builder"
+	"github.com/google/gapid/gapis/service/path"
+)
+
+// searchAPI is an API whose context is set by the searchAtoms.
+type searchAPI struct{}
+
+func (searchAPI) Name() string  { return "search" }
+func (searchAPI) ID() gfxapi.ID { return gfxapi.ID{4, 5, 6} }
+func (searchAPI) Index() uint8  { return 14 }
+func (searchAPI) GetFramebufferAttachmentInfo(state *gfxapi.State, attachment gfxapi.FramebufferAttachment) (uint32, uint32, *image.Format, error) {
+	return 0, 0, nil, nil
+}
+func (searchAPI) Context(s *gfxapi.State) gfxapi.Context {
+	if c, ok := s.APIs[searchAPI{}].(*searchContext); ok {
+		return c
+	}
+	return nil
+}
+
+type searchContext struct {
+	binary.Generate
+	Id uint8
+}
+
+func (c *searchContext) Name() string         { return fmt.Sprintf("context %d", c.Id) }
+func (c *searchContext) ID() gfxapi.ContextID { return gfxapi.ContextID{c.Id} }
+
+type searchEnum uint32
+
+const (
+	searchEnumA searchEnum = iota
+	searchEnumB
+)
+
+func (e searchEnum) String() string { return [...]string{"A", "B"}[e] }
+
+type searchAtom struct {
+	binary.Generate
+
+	context uint8 // If not 0, the atom makes this context current.
+
+	Count   int32
+	Size    uint64
+	Scale   float32
+	Mode    searchEnum
+	Label   string
+	Enabled bool
+	Data    []byte
+	Result  searchEnum
+}
+
+func (searchAtom) API() gfxapi.API       { return searchAPI{} }
+func (searchAtom) AtomFlags() atom.Flags { return 0 }
+func (searchAtom) Extras() *atom.Extras  { return nil }
+func (a *searchAtom) Mutate(ctx log.Context, s *gfxapi.State, b *builder.Builder) error {
+	if a.context != 0 {
+		s.APIs[searchAPI{}] = &searchContext{Id: a.context}
+	}
+	return nil
+}
+
+func init() {
+	gfxapi.Register(searchAPI{})
+}
+
+func exprName(n string) *search.Expression {
+	return &search.Expression{Is: &search.Expression_Name{Name: n}}
+}
+
+func exprMember(o *search.Expression, n string) *search.Expression {
+	return &search.Expression{Is: &search.Expression_Member{Member: &search.Member{Object: o, Name: n}}}
+}
+
+func exprParam(n string) *search.Expression {
+	return exprMember(exprName("Params"), n)
+}
+
+func exprEqual(lhs, rhs *search.Expression) *search.Expression {
+	return &search.Expression{Is: &search.Expression_Equal{Equal: &search.Binary{Lhs: lhs, Rhs: rhs}}}
+}
+
+func exprGreater(lhs, rhs *search.Expression) *search.Expression {
+	return &search.Expression{Is: &search.Expression_Greater{Greater: &search.Binary{Lhs: lhs, Rhs: rhs}}}
+}
+
+func exprGreaterOrEqual(lhs, rhs *search.Expression) *search.Expression {
+	return &search.Expression{Is: &search.Expression_GreaterOrEqual{GreaterOrEqual: &search.Binary{Lhs: lhs, Rhs: rhs}}}
+}
+
+func exprString(s string) *search.Expression {
+	return &search.Expression{Is: &search.Expression_String_{String_: s}}
+}
+
+func exprSigned(v int64) *search.Expression {
+	return &search.Expression{Is: &search.Expression_Signed{Signed: v}}
+}
+
+func exprDouble(v float64) *search.Expression {
+	return &search.Expression{Is: &search.Expression_Double{Double: v}}
+}
+
+func exprBool(v bool) *search.Expression {
+	return &search.Expression{Is: &search.Expression_Boolean{Boolean: v}}
+}
+
+func TestSearchableType(t *testing.T) {
+	ctx := log.Testing(t)
+	var (
+		signedType = reflect.TypeOf(int64(0))
+		floatType  = reflect.TypeOf(float64(0))
+		stringType = reflect.TypeOf("")
+		boolType   = reflect.TypeOf(false)
+	)
+	for _, test := range []struct {
+		value    interface{}
+		ty       reflect.Type
+		expected interface{}
+	}{
+		{int8(-3), signedType, int64(-3)},
+		{int32(-70000), signedType, int64(-70000)},
+		{uint16(65535), signedType, int64(65535)},
+		{uint64(1 << 40), signedType, int64(1 << 40)},
+		{float32(0.5), floatType, float64(0.5)},
+		{float64(-2.25), floatType, float64(-2.25)},
+		{true, boolType, true},
+		{"label", stringType, "label"},
+		{searchEnumB, stringType, "B"},
+		{&searchContext{Id: 3}, nil, nil},
+		{[]byte{1, 2}, nil, nil},
+		{map[string]string{}, nil, nil},
+		{struct{}{}, nil, nil},
+	} {
+		v := reflect.ValueOf(test.value)
+		ty, convert := searchableType(v.Type())
+		ctx := ctx.V("type", v.Type())
+		assert.With(ctx).That(ty).Equals(test.ty)
+		if test.ty == nil {
+			assert.With(ctx).That(convert == nil).Equals(true)
+			continue
+		}
+		if assert.With(ctx).That(convert != nil).Equals(true) {
+			got := convert(v)
+			assert.With(ctx).That(got.Type()).Equals(test.ty)
+			assert.With(ctx).That(got.Interface()).Equals(test.expected)
+		}
+	}
+}
+
+func TestNewCommandRecord(t *testing.T) {
+	ctx := log.Testing(t)
+	fieldNames := func(t reflect.Type) []string {
+		out := []string{}
+		for i := 0; i < t.NumField(); i++ {
+			out = append(out, t.Field(i).Name)
+		}
+		return out
+	}
+
+	a := &searchAtom{
+		context: 1,
+		Count:   -4,
+		Size:    16,
+		Scale:   1.5,
+		Mode:    searchEnumB,
+		Label:   "label",
+		Enabled: true,
+		Data:    []byte{1},
+		Result:  searchEnumA,
+	}
+	rec := reflect.ValueOf(newCommandRecord(reflect.TypeOf(a)).build(7, a))
+	// Unexported and unsearchable fields are dropped, the result is moved out
+	// of the parameters.
+	assert.For(ctx, "record").ThatSlice(fieldNames(rec.Type())).Equals(
+		[]string{"Index", "Name", "API", "Params", "Result"})
+	assert.For(ctx, "params").ThatSlice(fieldNames(rec.Field(3).Type())).Equals(
+		[]string{"Count", "Size", "Scale", "Mode", "Label", "Enabled"})
+	assert.For(ctx, "record").That(rec.Interface()).DeepEquals(reflect.ValueOf(struct {
+		Index  int64
+		Name   string
+		API    string
+		Params struct {
+			Count   int64
+			Size    int64
+			Scale   float64
+			Mode    string
+			Label   string
+			Enabled bool
+		}
+		Result string
+	}{
+		Index: 7,
+		Name:  "searchAtom",
+		API:   "search",
+		Params: struct {
+			Count   int64
+			Size    int64
+			Scale   float64
+			Mode    string
+			Label   string
+			Enabled bool
+		}{-4, 16, 1.5, "B", "label", true},
+		Result: "A",
+	}).Convert(rec.Type()).Interface())
+
+	// Atoms without an API or result.
+	b := &testAtom{Str: "aaa", Sli: []bool{true}}
+	rec = reflect.ValueOf(newCommandRecord(reflect.TypeOf(b)).build(2, b))
+	assert.For(ctx, "no result").ThatSlice(fieldNames(rec.Type())).Equals(
+		[]string{"Index", "Name", "API", "Params"})
+	assert.For(ctx, "no api").That(rec.Field(2).String()).Equals("")
+	assert.For(ctx, "params").That(rec.Field(3).Interface()).DeepEquals(
+		reflect.ValueOf(struct{ Str string }{"aaa"}).Convert(rec.Field(3).Type()).Interface())
+}
+
+func TestFindCommands(t *testing.T) {
+	ctx := log.Testing(t)
+	ctx = database.Put(ctx, database.NewInMemory(ctx))
+	p := newPathTest(ctx, atom.NewList(
+		&searchAtom{context: 1, Count: 1, Mode: searchEnumA, Label: "first", Result: searchEnumA},
+		&searchAtom{Count: 5, Scale: 0.5, Mode: searchEnumB, Label: "second", Result: searchEnumB},
+		&searchAtom{context: 2, Count: 10, Mode: searchEnumB, Enabled: true, Result: searchEnumA},
+		&searchAtom{Count: -2, Mode: searchEnumA, Result: searchEnumB},
+		&testAtom{Str: "aaa"},
+	))
+
+	contexts, err := Contexts(ctx, p.Contexts())
+	if !assert.With(ctx).ThatError(err).Succeeded() ||
+		!assert.With(ctx).ThatSlice(contexts).IsLength(2) {
+		return
+	}
+	inContext := func(i int) *path.Context {
+		return &path.Context{Contexts: p.Contexts(), Id: contexts[i].Id}
+	}
+
+	query := func(e *search.Expression) *search.Query { return &search.Query{Expression: e} }
+	for _, test := range []struct {
+		name     string
+		search   *path.CommandSearch
+		expected []uint64
+	}{
+		{"everything", p.Commands().Search(nil),
+			[]uint64{0, 1, 2, 3, 4}},
+		{"name", p.Commands().Search(query(exprEqual(exprName("Name"), exprString("searchAtom")))),
+			[]uint64{0, 1, 2, 3}},
+		{"index", p.Commands().Search(query(exprGreaterOrEqual(exprName("Index"), exprSigned(3)))),
+			[]uint64{3, 4}},
+		{"api", p.Commands().Search(query(exprEqual(exprName("API"), exprString("search")))),
+			[]uint64{0, 1, 2, 3}},
+		{"integer parameter", p.Commands().Search(query(exprGreater(exprParam("Count"), exprSigned(4)))),
+			[]uint64{1, 2}},
+		{"float parameter", p.Commands().Search(query(exprGreaterOrEqual(exprParam("Scale"), exprDouble(0.5)))),
+			[]uint64{1}},
+		{"bool parameter", p.Commands().Search(query(exprEqual(exprParam("Enabled"), exprBool(true)))),
+			[]uint64{2}},
+		{"string parameter", p.Commands().Search(query(exprEqual(exprParam("Str"), exprString("aaa")))),
+			[]uint64{4}},
+		{"enum parameter", p.Commands().Search(query(exprEqual(exprParam("Mode"), exprString("B")))),
+			[]uint64{1, 2}},
+		{"result", p.Commands().Search(query(exprEqual(exprName("Result"), exprString("A")))),
+			[]uint64{0, 2}},
+		{"range", &path.CommandSearch{Commands: p.Commands(), First: 1, Count: 2},
+			[]uint64{1, 2}},
+		{"range past end", &path.CommandSearch{Commands: p.Commands(), First: 3, Count: 10},
+			[]uint64{3, 4}},
+		{"first context", &path.CommandSearch{Commands: p.Commands(), Context: inContext(0)},
+			[]uint64{0, 1}},
+		{"second context", &path.CommandSearch{Commands: p.Commands(), Context: inContext(1)},
+			[]uint64{2, 3}},
+		{"context and range", &path.CommandSearch{Commands: p.Commands(), Context: inContext(1), First: 3},
+			[]uint64{3}},
+		{"context and query", &path.CommandSearch{
+			Commands: p.Commands(),
+			Query:    query(exprEqual(exprName("Result"), exprString("A"))),
+			Context:  inContext(0),
+		}, []uint64{0}},
+	} {
+		got := []uint64{}
+		err := FindCommands(ctx, test.search, func(c *path.Command) error {
+			got = append(got, c.Index)
+			return nil
+		})
+		if assert.For(ctx, test.name).ThatError(err).Succeeded() {
+			assert.For(ctx, test.name).ThatSlice(got).Equals(test.expected)
+		}
+	}
+
+	// A query that can't be applied to any command fails.
+	err = FindCommands(ctx, p.Commands().Search(query(exprEqual(exprParam("Data"), exprString("")))),
+		func(*path.Command) error { return nil })
+	assert.For(ctx, "unsearchable").ThatError(err).Failed()
+
+	// Errors returned by the callback stop the search.
+	stop := fmt.Errorf("stop")
+	count := 0
+	err = FindCommands(ctx, p.Commands().Search(nil), func(*path.Command) error {
+		count++
+		return stop
+	})
+	assert.For(ctx, "callback error").ThatError(err).Equals(stop)
+	assert.For(ctx, "callback count").That(count).Equals(1)
+}
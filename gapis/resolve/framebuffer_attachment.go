@@ -28,11 +28,14 @@ import (
 
 // FramebufferAttachment resolves the specified framebuffer attachment at the
 // specified point in a capture.
+// If layer is not nil then the given layer and level of the attached image is
+// resolved instead of the layer and level bound to the framebuffer.
 func FramebufferAttachment(
 	ctx log.Context,
 	device *path.Device,
 	after *path.Command,
 	attachment gfxapi.FramebufferAttachment,
+	layer *gfxapi.FramebufferLayer,
 	settings *service.RenderSettings,
 ) (*path.ImageInfo, error) {
 	id, err := database.Store(ctx, &FramebufferAttachmentResolvable{
@@ -40,6 +43,7 @@ func FramebufferAttachment(
 		after,
 		attachment,
 		settings,
+		layer,
 	})
 	if err != nil {
 		return nil, err
@@ -74,6 +78,11 @@ func (r *FramebufferAttachmentResolvable) Resolve(ctx log.Context) (interface{},
 	if err != nil {
 		return nil, err
 	}
+	if r.Layer != nil {
+		if fbInfo, err = framebufferLayerInfo(ctx, r.After, r.Attachment, r.Layer); err != nil {
+			return nil, err
+		}
+	}
 	width, height := uniformScale(fbInfo.width, fbInfo.height, r.Settings.MaxWidth, r.Settings.MaxHeight)
 
 	data, err := database.Store(ctx, &FramebufferAttachmentDataResolvable{
@@ -83,6 +92,8 @@ func (r *FramebufferAttachmentResolvable) Resolve(ctx log.Context) (interface{},
 		Height:        height,
 		Attachment:    r.Attachment,
 		WireframeMode: r.Settings.WireframeMode,
+		DrawMode:      r.Settings.DrawMode,
+		Layer:         r.Layer,
 	})
 	if err != nil {
 		return nil, err
@@ -96,6 +107,25 @@ func (r *FramebufferAttachmentResolvable) Resolve(ctx log.Context) (interface{},
 	}, nil
 }
 
+// framebufferLayerInfo returns the dimensions and format of the given layer
+// and level of the image bound to the framebuffer attachment att after the
+// command after.
+func framebufferLayerInfo(ctx log.Context, after *path.Command, att gfxapi.FramebufferAttachment, layer *gfxapi.FramebufferLayer) (framebufferAttachmentInfo, error) {
+	s, api, err := framebufferState(ctx, after)
+	if err != nil {
+		return framebufferAttachmentInfo{}, err
+	}
+	layers, ok := api.(gfxapi.FramebufferLayersProvider)
+	if !ok {
+		return framebufferAttachmentInfo{}, &service.ErrDataUnavailable{Reason: messages.ErrFramebufferUnavailable()}
+	}
+	w, h, f, err := layers.GetFramebufferAttachmentLayerInfo(s, att, layer)
+	if err != nil || f == nil {
+		return framebufferAttachmentInfo{}, &service.ErrDataUnavailable{Reason: messages.ErrFramebufferUnavailable()}
+	}
+	return framebufferAttachmentInfo{after: after.Index, width: w, height: h, format: f, valid: true}, nil
+}
+
 func uniformScale(width, height, maxWidth, maxHeight uint32) (w, h uint32) {
 	w, h = width, height
 	scaleX, scaleY := float32(w)/float32(maxWidth), float32(h)/float32(maxHeight)
@@ -142,12 +172,3 @@ func (c framebufferAttachmentChanges) last() framebufferAttachmentInfo {
 	}
 	return framebufferAttachmentInfo{}
 }
-
-var allFramebufferAttachments = []gfxapi.FramebufferAttachment{
-	gfxapi.FramebufferAttachment_Depth,
-	gfxapi.FramebufferAttachment_Stencil,
-	gfxapi.FramebufferAttachment_Color0,
-	gfxapi.FramebufferAttachment_Color1,
-	gfxapi.FramebufferAttachment_Color2,
-	gfxapi.FramebufferAttachment_Color3,
-}
@@ -57,9 +57,22 @@ func (r *FramebufferAttachmentDataResolvable) Resolve(ctx log.Context) (interfac
 		return nil, &service.ErrInvalidArgument{Reason: messages.ErrInvalidEnumValue(wireframeMode, "WireframeMode")}
 	}
 
+	drawMode := replay.DrawMode_Normal
+	switch r.DrawMode {
+	case service.DrawMode_Normal:
+	case service.DrawMode_Overdraw:
+		drawMode = replay.DrawMode_Overdraw
+	case service.DrawMode_DepthComplexity:
+		drawMode = replay.DrawMode_DepthComplexity
+	case service.DrawMode_Highlight:
+		drawMode = replay.DrawMode_Highlight
+	default:
+		return nil, &service.ErrInvalidArgument{Reason: messages.ErrInvalidEnumValue(r.DrawMode, "DrawMode")}
+	}
+
 	mgr := replay.GetManager(ctx)
 
-	res, err := query.QueryFramebufferAttachment(ctx, intent, mgr, atom.ID(r.After.Index), r.Width, r.Height, r.Attachment, wireframeMode)
+	res, err := query.QueryFramebufferAttachment(ctx, intent, mgr, atom.ID(r.After.Index), r.Width, r.Height, r.Attachment, r.Layer, wireframeMode, drawMode)
 	if err != nil {
 		if _, ok := err.(*service.ErrDataUnavailable); ok {
 			return nil, err
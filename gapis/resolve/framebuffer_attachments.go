@@ -0,0 +1,79 @@
+// Copyright (C) 2017 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package resolve
+
+import (
+	"github.com/google/gapid/core/log"
+	"github.com/google/gapid/gapis/capture"
+	"github.com/google/gapid/gapis/gfxapi"
+	"github.com/google/gapid/gapis/messages"
+	"github.com/google/gapid/gapis/service"
+	"github.com/google/gapid/gapis/service/path"
+)
+
+// FramebufferAttachments resolves the list of attachments of the framebuffer
+// bound after the command p.After.
+func FramebufferAttachments(ctx log.Context, p *path.FramebufferAttachments) (*service.FramebufferAttachments, error) {
+	s, api, err := framebufferState(ctx, p.After)
+	if err != nil {
+		return nil, err
+	}
+	layers, _ := api.(gfxapi.FramebufferLayersProvider)
+
+	out := &service.FramebufferAttachments{}
+	for _, att := range gfxapi.FramebufferAttachments {
+		w, h, f, err := api.GetFramebufferAttachmentInfo(s, att)
+		if err != nil || f == nil {
+			continue
+		}
+		info := &service.FramebufferAttachmentInfo{
+			Attachment: att,
+			Width:      w,
+			Height:     h,
+			Format:     f,
+			Layers:     1,
+			Levels:     1,
+		}
+		if layers != nil {
+			if l, m, err := layers.GetFramebufferAttachmentLayers(s, att); err == nil {
+				info.Layers, info.Levels = l, m
+			}
+		}
+		out.Attachments = append(out.Attachments, info)
+	}
+	if len(out.Attachments) == 0 {
+		return nil, &service.ErrDataUnavailable{Reason: messages.ErrFramebufferUnavailable()}
+	}
+	return out, nil
+}
+
+// framebufferState returns the state after the command after, along with the
+// API of the command.
+func framebufferState(ctx log.Context, after *path.Command) (*gfxapi.State, gfxapi.API, error) {
+	ctx = capture.Put(ctx, after.Commands.Capture)
+	list, err := NCommands(ctx, after.Commands, after.Index+1)
+	if err != nil {
+		return nil, nil, err
+	}
+	api := list.Atoms[after.Index].API()
+	if api == nil {
+		return nil, nil, &service.ErrDataUnavailable{Reason: messages.ErrFramebufferUnavailable()}
+	}
+	s := capture.NewState(ctx)
+	for _, a := range list.Atoms[:after.Index+1] {
+		a.Mutate(ctx, s, nil /* no builder, just mutate */)
+	}
+	return s, api, nil
+}
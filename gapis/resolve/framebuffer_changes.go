@@ -17,12 +17,14 @@ package resolve
 import (
 	"fmt"
 
+	"github.com/google/gapid/core/event/task"
 	"github.com/google/gapid/core/log"
 	"github.com/google/gapid/gapis/atom"
 	"github.com/google/gapid/gapis/capture"
 	"github.com/google/gapid/gapis/database"
 	"github.com/google/gapid/gapis/gfxapi"
 	"github.com/google/gapid/gapis/service/path"
+	"github.com/google/gapid/gapis/status"
 )
 
 // FramebufferChanges returns the list of attachment changes over the span of
@@ -63,16 +65,19 @@ func (r *FramebufferChangesResolvable) Resolve(ctx log.Context) (interface{}, er
 	}
 
 	out := &AttachmentFramebufferChanges{
-		// TODO: Remove hardcoded upper limit
-		attachments: make([]framebufferAttachmentChanges, gfxapi.FramebufferAttachment_Color3+1),
+		attachments: make([]framebufferAttachmentChanges, len(gfxapi.FramebufferAttachments)),
 	}
 
 	s := c.NewState()
 	for i, a := range list.Atoms {
+		if err := task.StopReason(ctx); err != nil {
+			return nil, err
+		}
+		status.UpdateProgress(ctx, uint64(i), uint64(len(list.Atoms)))
 		id = atom.ID(i)
 		a.Mutate(ctx, s, nil /* no builder, just mutate */)
 		api := a.API()
-		for _, att := range allFramebufferAttachments {
+		for _, att := range gfxapi.FramebufferAttachments {
 			info := framebufferAttachmentInfo{after: uint64(i)}
 			if api != nil {
 				if w, h, f, err := api.GetFramebufferAttachmentInfo(s, att); err == nil && f != nil {
@@ -336,3 +336,90 @@ func TestSet(t *testing.T) {
 		}
 	}
 }
+
+func TestBookmarks(t *testing.T) {
+	ctx := log.Testing(t)
+	ctx = database.Put(ctx, database.NewInMemory(ctx))
+	a := atom.NewList(&testAtom{Str: "aaa"}, &testAtom{Str: "bbb"})
+	p := newPathTest(ctx, a)
+	ctx = capture.Put(ctx, p)
+
+	// There are no bookmarks to get or remove.
+	_, err := Get(ctx, p.Bookmarks().Index(0).Path())
+	assert.For(ctx, "Get missing bookmark").ThatError(err).DeepEquals(&service.ErrInvalidPath{
+		Reason: messages.ErrNoBookmarks(),
+		Path:   p.Bookmarks().Index(0).Path(),
+	})
+	_, err = Set(ctx, p.Bookmarks().Index(0).Path(), nil)
+	assert.For(ctx, "Remove missing bookmark").ThatError(err).DeepEquals(&service.ErrInvalidPath{
+		Reason: messages.ErrNoBookmarks(),
+		Path:   p.Bookmarks().Index(0).Path(),
+	})
+
+	// Add a bookmark to the capture.
+	changed, err := Set(ctx, p.Bookmarks().Index(0).Path(), &service.Bookmark{
+		Command: p.Commands().Index(1),
+		Label:   "shadow pass",
+		Text:    "broken",
+		Author:  "bob",
+	})
+	assert.For(ctx, "Set bookmark").ThatError(err).Succeeded()
+	bookmark := changed.Node().(*path.Bookmark)
+	// Bookmarks are stored separately, so the capture is unchanged.
+	assert.For(ctx, "Set bookmark path").That(bookmark).DeepEquals(p.Bookmarks().Index(0))
+
+	got, err := Get(ctx, bookmark.Path())
+	assert.For(ctx, "Get bookmark").ThatError(err).Succeeded()
+	b := got.(*service.Bookmark)
+	assert.For(ctx, "command").That(b.Command.Index).Equals(uint64(1))
+	assert.For(ctx, "label").That(b.Label).Equals("shadow pass")
+	assert.For(ctx, "text").That(b.Text).Equals("broken")
+	assert.For(ctx, "author").That(b.Author).Equals("bob")
+	assert.For(ctx, "timestamp").That(b.Timestamp).NotEquals(int64(0))
+
+	_, err = Get(ctx, p.Bookmarks().Index(1).Path())
+	assert.For(ctx, "Get out of bounds").ThatError(err).DeepEquals(&service.ErrInvalidPath{
+		Reason: messages.ErrValueOutOfBounds(uint64(1), "Index", uint64(0), uint64(0)),
+		Path:   p.Bookmarks().Index(1).Path(),
+	})
+
+	// Bookmarks must refer to commands of the capture.
+	commands := p.Commands()
+	_, err = Set(ctx, p.Bookmarks().Path(), []*service.Bookmark{{Command: commands.Index(5)}})
+	assert.For(ctx, "Set missing command").ThatError(err).DeepEquals(&service.ErrInvalidPath{
+		Reason: messages.ErrValueOutOfBounds(uint64(5), "Index", uint64(0), uint64(1)),
+		Path:   commands.Index(5).Path(),
+	})
+	other := newPathTest(ctx, atom.NewList(&testAtom{Str: "ccc"}))
+	_, err = Set(ctx, p.Bookmarks().Path(), []*service.Bookmark{{Command: other.Commands().Index(0)}})
+	assert.For(ctx, "Set other capture command").ThatError(err).DeepEquals(&service.ErrInvalidPath{
+		Reason: messages.ErrPathWrongCapture(),
+		Path:   other.Commands().Index(0).Path(),
+	})
+	empty := newPathTest(ctx, atom.NewList())
+	_, err = Set(ctx, empty.Bookmarks().Path(), []*service.Bookmark{{Command: empty.Commands().Index(0)}})
+	assert.For(ctx, "Set bookmark without commands").ThatError(err).DeepEquals(&service.ErrInvalidPath{
+		Reason: messages.ErrNoCommands(),
+		Path:   empty.Commands().Index(0).Path(),
+	})
+
+	// Bookmarks are kept by edits, unless their command is removed.
+	edited, err := Set(ctx, commands.Index(0).Path(), &testAtom{Str: "xxx"})
+	assert.For(ctx, "Edit command").ThatError(err).Succeeded()
+	got, err = Get(ctx, edited.Node().(*path.Command).Commands.Capture.Bookmarks().Path())
+	assert.For(ctx, "Get edited bookmarks").ThatError(err).Succeeded()
+	assert.For(ctx, "edited bookmarks").ThatSlice(got).IsLength(1)
+
+	edited, err = Set(ctx, commands.Path(), atom.NewList(&testAtom{Str: "aaa"}))
+	assert.For(ctx, "Edit commands").ThatError(err).Succeeded()
+	got, err = Get(ctx, edited.Node().(*path.Commands).Capture.Bookmarks().Path())
+	assert.For(ctx, "Get truncated bookmarks").ThatError(err).Succeeded()
+	assert.For(ctx, "truncated bookmarks").That(got).DeepEquals([]*service.Bookmark{})
+
+	// Remove the bookmark.
+	changed, err = Set(ctx, bookmark.Path(), nil)
+	assert.For(ctx, "Remove bookmark").ThatError(err).Succeeded()
+	got, err = Get(ctx, changed.Node().Parent().Path())
+	assert.For(ctx, "Get bookmarks").ThatError(err).Succeeded()
+	assert.For(ctx, "bookmarks").That(got).DeepEquals([]*service.Bookmark{})
+}
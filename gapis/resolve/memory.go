@@ -45,18 +45,21 @@ func Memory(ctx log.Context, p *path.Memory) (*service.MemoryInfo, error) {
 
 	r := memory.Range{Base: p.Address, Size: p.Size}
 
-	var reads, writes memory.RangeList
+	var reads, writes, touched memory.RangeList
 	pool.OnRead = func(rng memory.Range) {
 		if rng.Overlaps(r) {
 			interval.Merge(&reads, rng.Window(r).Span(), false)
 		}
+		interval.Merge(&touched, rng.Span(), false)
 	}
 	pool.OnWrite = func(rng memory.Range) {
 		if rng.Overlaps(r) {
 			interval.Merge(&writes, rng.Window(r).Span(), false)
 		}
+		interval.Merge(&touched, rng.Span(), false)
 	}
-	list.Atoms[p.After.Index].Mutate(ctx, s, nil /* no builder, just mutate */)
+	a := list.Atoms[p.After.Index]
+	a.Mutate(ctx, s, nil /* no builder, just mutate */)
 
 	slice := pool.Slice(r)
 	data := make([]byte, slice.Size())
@@ -67,9 +70,10 @@ func Memory(ctx log.Context, p *path.Memory) (*service.MemoryInfo, error) {
 	observed := slice.ValidRanges()
 
 	return &service.MemoryInfo{
-		Data:     data,
-		Reads:    service.NewMemoryRanges(reads),
-		Writes:   service.NewMemoryRanges(writes),
-		Observed: service.NewMemoryRanges(observed),
+		Data:      data,
+		Structure: memoryStructure(a, s, memory.PoolID(p.Pool), r, touched),
+		Reads:     service.NewMemoryRanges(reads),
+		Writes:    service.NewMemoryRanges(writes),
+		Observed:  service.NewMemoryRanges(observed),
 	}, nil
 }
@@ -0,0 +1,307 @@
+// Copyright (C) 2017 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package resolve
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/google/gapid/core/math/interval"
+	"github.com/google/gapid/core/math/u64"
+	"github.com/google/gapid/core/os/device"
+	"github.com/google/gapid/gapis/atom"
+	"github.com/google/gapid/gapis/gfxapi"
+	"github.com/google/gapid/gapis/memory"
+	"github.com/google/gapid/gapis/service"
+)
+
+// maxStructureElements is the largest number of array elements that are
+// broken down into their fields.
+const maxStructureElements = 256
+
+// typedPointer is implemented by the generated API pointer types.
+type typedPointer interface {
+	ElementSize(s *gfxapi.State) uint64
+}
+
+var pointerType = reflect.TypeOf(memory.Pointer{})
+
+// memoryStructure returns the typed layout of the memory range r of pool,
+// derived from the pointer parameters of the atom a. The number of elements
+// each pointer refers to is taken from the ranges of memory touched by a,
+// up to the memory referred to by the next pointer parameter. touched holds
+// the absolute ranges read or written by a's mutation, with adjacent accesses
+// kept apart.
+func memoryStructure(a atom.Atom, s *gfxapi.State, pool memory.PoolID, r memory.Range, touched memory.RangeList) *service.MemoryStructure {
+	out := &service.MemoryStructure{}
+
+	if o := a.Extras().Observations(); o != nil && pool == memory.ApplicationPool {
+		for _, l := range [][]atom.Observation{o.Reads, o.Writes} {
+			for _, obs := range l {
+				interval.Merge(&touched, obs.Range.Span(), false)
+			}
+		}
+	}
+
+	v := reflect.ValueOf(a)
+	if v.Kind() == reflect.Ptr {
+		v = v.Elem()
+	}
+	if v.Kind() != reflect.Struct {
+		return out
+	}
+
+	type param struct {
+		name string
+		el   reflect.Type
+		size uint64
+		ptr  memory.Pointer
+	}
+	params := []param{}
+	t := v.Type()
+	for i, c := 0, t.NumField(); i < c; i++ {
+		f := t.Field(i)
+		if f.PkgPath != "" || f.Anonymous || !f.Type.ConvertibleTo(pointerType) {
+			continue // Unexported, embedded or not a pointer.
+		}
+		p, ok := v.Field(i).Interface().(typedPointer)
+		if !ok {
+			continue
+		}
+		ptr := v.Field(i).Convert(pointerType).Interface().(memory.Pointer)
+		if ptr.Pool != pool || ptr.Address == 0 {
+			continue
+		}
+		el := pointeeType(f.Type)
+		size := p.ElementSize(s)
+		if el == nil || size == 0 {
+			continue // void pointer
+		}
+		params = append(params, param{lowerFirst(f.Name), el, size, ptr})
+	}
+	sort.Slice(params, func(i, j int) bool { return params[i].ptr.Address < params[j].ptr.Address })
+
+	l := structureLayout{s.MemoryLayout}
+	for i, p := range params {
+		idx := interval.IndexOf(&touched, p.ptr.Address)
+		if idx < 0 {
+			continue // Memory not used by the command.
+		}
+		end := touched[idx].End()
+		for _, next := range params[i+1:] {
+			if next.ptr.Address > p.ptr.Address {
+				end = u64.Min(end, next.ptr.Address)
+				break
+			}
+		}
+		count := (end - p.ptr.Address) / p.size
+		rng := memory.Range{Base: p.ptr.Address, Size: count * p.size}
+		if count == 0 || !rng.Overlaps(r) {
+			continue
+		}
+		out.Fields = append(out.Fields, l.array(p.name, p.el, p.size, count, int64(p.ptr.Address)-int64(r.Base)))
+	}
+	return out
+}
+
+// pointeeType returns the Go type of the elements pointed to by values of the
+// generated pointer type t, or nil if t is a void pointer.
+func pointeeType(t reflect.Type) reflect.Type {
+	slice, ok := t.MethodByName("Slice")
+	if !ok || slice.Type.NumOut() != 1 {
+		return nil
+	}
+	read, ok := slice.Type.Out(0).MethodByName("Read")
+	if !ok || read.Type.NumOut() != 1 || read.Type.Out(0).Kind() != reflect.Slice {
+		return nil
+	}
+	return read.Type.Out(0).Elem()
+}
+
+// structureLayout calculates the layout of Go types using the C alignment
+// rules of the memory layout. 64-bit floats share the alignment of 64-bit
+// integers.
+// The generated Go types are used rather than their binary schema entities as
+// the schema describes the encoding of the types, not their memory layout: it
+// encodes int and uint as 32-bit values regardless of the IntegerSize, and the
+// pointer types are plain structures with no pointee type.
+type structureLayout struct {
+	layout *device.MemoryLayout
+}
+
+// array returns the field for count elements of type t, each of size bytes,
+// starting at offset. If count is 1 then the field describes the single
+// element.
+func (l structureLayout) array(name string, t reflect.Type, size, count uint64, offset int64) *service.MemoryField {
+	if count == 1 {
+		return &service.MemoryField{
+			Name:   name,
+			Type:   typeName(t),
+			Offset: offset,
+			Size:   size,
+			Fields: l.fields(t, size, offset),
+		}
+	}
+	return &service.MemoryField{
+		Name:   name,
+		Type:   fmt.Sprintf("%s[%d]", typeName(t), count),
+		Offset: offset,
+		Size:   size * count,
+		Fields: l.elements(t, size, count, offset),
+	}
+}
+
+// elements returns a field for each of the count elements of type t, each of
+// size bytes, starting at offset. Elements of primitive types and arrays with
+// more than maxStructureElements elements are not broken down.
+func (l structureLayout) elements(t reflect.Type, size, count uint64, offset int64) []*service.MemoryField {
+	if k := t.Kind(); k != reflect.Struct && k != reflect.Array {
+		return nil
+	}
+	if count > maxStructureElements || size == 0 || l.sizeOf(t) != size {
+		return nil // Too many elements, or the layout of t is not known.
+	}
+	out := make([]*service.MemoryField, count)
+	for i := range out {
+		out[i] = l.array(fmt.Sprintf("[%d]", i), t, size, 1, offset+int64(uint64(i)*size))
+	}
+	return out
+}
+
+// fields returns the fields of the structure or array type t of size bytes
+// that starts at offset.
+func (l structureLayout) fields(t reflect.Type, size uint64, offset int64) []*service.MemoryField {
+	if size == 0 || l.sizeOf(t) != size {
+		return nil // The layout of t is not known.
+	}
+	switch t.Kind() {
+	case reflect.Array:
+		el := t.Elem()
+		return l.elements(el, l.sizeOf(el), uint64(t.Len()), offset)
+
+	case reflect.Struct:
+		out := []*service.MemoryField{}
+		fieldOffset := uint64(0)
+		for i, c := 0, t.NumField(); i < c; i++ {
+			f := t.Field(i)
+			if f.PkgPath != "" || f.Anonymous {
+				continue
+			}
+			fieldOffset = u64.AlignUp(fieldOffset, l.alignmentOf(f.Type))
+			fieldSize := l.sizeOf(f.Type)
+			out = append(out, l.array(lowerFirst(f.Name), f.Type, fieldSize, 1, offset+int64(fieldOffset)))
+			fieldOffset += fieldSize
+		}
+		return out
+	}
+	return nil
+}
+
+// alignmentOf returns the alignment in bytes of the type t, or 0 if t cannot
+// be stored in memory.
+func (l structureLayout) alignmentOf(t reflect.Type) uint64 {
+	if t.ConvertibleTo(pointerType) {
+		return uint64(l.layout.GetPointerAlignment())
+	}
+	switch t.Kind() {
+	case reflect.Bool, reflect.Int8, reflect.Uint8:
+		return 1
+	case reflect.Int16, reflect.Uint16:
+		return 2
+	case reflect.Int32, reflect.Uint32, reflect.Float32:
+		return 4
+	case reflect.Int64, reflect.Uint64, reflect.Float64:
+		return uint64(l.layout.GetU64Alignment())
+	case reflect.Int, reflect.Uint:
+		return uint64(l.layout.GetIntegerSize())
+	case reflect.String:
+		return uint64(l.layout.GetPointerAlignment())
+	case reflect.Array:
+		return l.alignmentOf(t.Elem())
+	case reflect.Struct:
+		alignment := uint64(1)
+		for i, c := 0, t.NumField(); i < c; i++ {
+			f := t.Field(i)
+			if f.PkgPath != "" || f.Anonymous {
+				continue
+			}
+			a := l.alignmentOf(f.Type)
+			if a == 0 {
+				return 0
+			}
+			if alignment < a {
+				alignment = a
+			}
+		}
+		return alignment
+	}
+	return 0
+}
+
+// sizeOf returns the size in bytes of the type t, or 0 if t cannot be stored
+// in memory.
+func (l structureLayout) sizeOf(t reflect.Type) uint64 {
+	if t.ConvertibleTo(pointerType) {
+		return uint64(l.layout.GetPointerSize())
+	}
+	switch t.Kind() {
+	case reflect.Bool, reflect.Int8, reflect.Uint8:
+		return 1
+	case reflect.Int16, reflect.Uint16:
+		return 2
+	case reflect.Int32, reflect.Uint32, reflect.Float32:
+		return 4
+	case reflect.Int64, reflect.Uint64, reflect.Float64:
+		return 8
+	case reflect.Int, reflect.Uint:
+		return uint64(l.layout.GetIntegerSize())
+	case reflect.String:
+		return uint64(l.layout.GetPointerSize())
+	case reflect.Array:
+		return uint64(t.Len()) * l.sizeOf(t.Elem())
+	case reflect.Struct:
+		alignment := l.alignmentOf(t)
+		if alignment == 0 {
+			return 0
+		}
+		size := uint64(0)
+		for i, c := 0, t.NumField(); i < c; i++ {
+			f := t.Field(i)
+			if f.PkgPath != "" || f.Anonymous {
+				continue
+			}
+			size = u64.AlignUp(size, l.alignmentOf(f.Type)) + l.sizeOf(f.Type)
+		}
+		return u64.AlignUp(size, alignment)
+	}
+	return 0
+}
+
+// typeName returns the API name of the Go type t.
+func typeName(t reflect.Type) string {
+	if t.Name() == "" && t.Kind() == reflect.Array {
+		return fmt.Sprintf("%s[%d]", typeName(t.Elem()), t.Len())
+	}
+	return strings.Replace(strings.Replace(t.Name(), "ᶜ", "", -1), "ᵖ", "*", -1)
+}
+
+func lowerFirst(s string) string {
+	if s == "" {
+		return s
+	}
+	return strings.ToLower(s[:1]) + s[1:]
+}
@@ -0,0 +1,183 @@
+// Copyright (C) 2017 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package resolve
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/google/gapid/core/assert"
+	"github.com/google/gapid/core/log"
+	"github.com/google/gapid/core/math/interval"
+	"github.com/google/gapid/core/os/device"
+	"github.com/google/gapid/framework/binary"
+	"github.com/google/gapid/gapis/atom"
+	"github.com/google/gapid/gapis/gfxapi"
+	"github.com/google/gapid/gapis/memory"
+	"github.com/google/gapid/gapis/replay/builder"
+	"github.com/google/gapid/gapis/service"
+)
+
+// x86 is a 32-bit memory layout that aligns 64-bit values to 4 bytes.
+var x86 = &device.MemoryLayout{
+	PointerAlignment: 4,
+	PointerSize:      4,
+	IntegerSize:      4,
+	SizeSize:         4,
+	U64Alignment:     4,
+	Endian:           device.LittleEndian,
+}
+
+// x64 is a 64-bit memory layout.
+var x64 = &device.MemoryLayout{
+	PointerAlignment: 8,
+	PointerSize:      8,
+	IntegerSize:      4,
+	SizeSize:         8,
+	U64Alignment:     8,
+	Endian:           device.LittleEndian,
+}
+
+type testVertex struct {
+	A uint8
+	B float64
+	C uint16
+}
+
+// testVertexᵖ mimics the generated pointer types.
+type testVertexᵖ memory.Pointer
+
+func (testVertexᵖ) ElementSize(*gfxapi.State) uint64 { return 16 }
+func (testVertexᵖ) Slice() testVertexˢ               { return testVertexˢ{} }
+
+type testVertexˢ struct{}
+
+func (testVertexˢ) Read() []testVertex { return nil }
+
+type testU16ᵖ memory.Pointer
+
+func (testU16ᵖ) ElementSize(*gfxapi.State) uint64 { return 2 }
+func (testU16ᵖ) Slice() testU16ˢ                  { return testU16ˢ{} }
+
+type testU16ˢ struct{}
+
+func (testU16ˢ) Read() []uint16 { return nil }
+
+type testVoidᵖ memory.Pointer
+
+func (testVoidᵖ) ElementSize(*gfxapi.State) uint64 { return 1 }
+
+type structureAtom struct {
+	binary.Generate
+	Vertices testVertexᵖ
+	Indices  testU16ᵖ
+	Data     testVoidᵖ
+	Other    testU16ᵖ
+	Unused   testU16ᵖ
+}
+
+func (structureAtom) API() gfxapi.API       { return nil }
+func (structureAtom) AtomFlags() atom.Flags { return 0 }
+func (structureAtom) Extras() *atom.Extras  { return nil }
+func (structureAtom) Mutate(ctx log.Context, s *gfxapi.State, b *builder.Builder) error {
+	return nil
+}
+
+func TestStructureLayout(t *testing.T) {
+	ctx := log.Testing(t)
+	vertex := reflect.TypeOf(testVertex{})
+	for _, test := range []struct {
+		name      string
+		layout    *device.MemoryLayout
+		t         reflect.Type
+		size      uint64
+		alignment uint64
+	}{
+		{"u8", device.Little32, reflect.TypeOf(uint8(0)), 1, 1},
+		{"f64 little32", device.Little32, reflect.TypeOf(float64(0)), 8, 8},
+		{"f64 x86", x86, reflect.TypeOf(float64(0)), 8, 4},
+		{"u64 x86", x86, reflect.TypeOf(uint64(0)), 8, 4},
+		{"int x64", x64, reflect.TypeOf(int(0)), 4, 4},
+		{"pointer x64", x64, reflect.TypeOf(testU16ᵖ{}), 8, 8},
+		{"struct little32", device.Little32, vertex, 24, 8},
+		{"struct x86", x86, vertex, 16, 4},
+		{"array x86", x86, reflect.ArrayOf(3, vertex), 48, 4},
+		{"unknown", x86, reflect.TypeOf(map[int]int{}), 0, 0},
+	} {
+		l := structureLayout{test.layout}
+		assert.For(ctx, "%s size", test.name).That(l.sizeOf(test.t)).Equals(test.size)
+		assert.For(ctx, "%s alignment", test.name).That(l.alignmentOf(test.t)).Equals(test.alignment)
+	}
+
+	fields := structureLayout{x86}.fields(vertex, 16, 8)
+	assert.For(ctx, "fields").That(fields).DeepEquals([]*service.MemoryField{
+		{Name: "a", Type: "uint8", Offset: 8, Size: 1},
+		{Name: "b", Type: "float64", Offset: 12, Size: 8},
+		{Name: "c", Type: "uint16", Offset: 20, Size: 2},
+	})
+	assert.For(ctx, "mismatched size").That(structureLayout{x86}.fields(vertex, 24, 0)).IsNil()
+}
+
+func TestMemoryStructure(t *testing.T) {
+	ctx := log.Testing(t)
+	s := gfxapi.NewStateWithEmptyAllocator()
+	s.MemoryLayout = x86
+
+	pool := memory.ApplicationPool
+	a := &structureAtom{
+		Vertices: testVertexᵖ{Address: 0x1000, Pool: pool},
+		Indices:  testU16ᵖ{Address: 0x1020, Pool: pool},
+		Data:     testVoidᵖ{Address: 0x1030, Pool: pool},
+		Other:    testU16ᵖ{Address: 0x1000, Pool: pool + 1},
+		Unused:   testU16ᵖ{Address: 0x2000, Pool: pool},
+	}
+
+	vertex := func(name string, offset int64) *service.MemoryField {
+		return &service.MemoryField{Name: name, Type: "testVertex", Offset: offset, Size: 16,
+			Fields: []*service.MemoryField{
+				{Name: "a", Type: "uint8", Offset: offset, Size: 1},
+				{Name: "b", Type: "float64", Offset: offset + 4, Size: 8},
+				{Name: "c", Type: "uint16", Offset: offset + 12, Size: 2},
+			},
+		}
+	}
+	expected := &service.MemoryStructure{Fields: []*service.MemoryField{
+		{Name: "vertices", Type: "testVertex[2]", Offset: -16, Size: 32,
+			Fields: []*service.MemoryField{vertex("[0]", -16), vertex("[1]", 0)},
+		},
+		{Name: "indices", Type: "uint16[8]", Offset: 16, Size: 16},
+	}}
+	r := memory.Range{Base: 0x1010, Size: 0x20}
+
+	for _, test := range []struct {
+		name    string
+		touched []memory.Range
+	}{
+		{"separate accesses", []memory.Range{{Base: 0x1000, Size: 32}, {Base: 0x1020, Size: 16}}},
+		// The vertices end where the indices start.
+		{"single access", []memory.Range{{Base: 0x1000, Size: 48}}},
+	} {
+		var touched memory.RangeList
+		for _, rng := range test.touched {
+			interval.Merge(&touched, rng.Span(), false)
+		}
+		got := memoryStructure(a, s, pool, r, touched)
+		assert.For(ctx, test.name).That(got).DeepEquals(expected)
+	}
+
+	// Fields that do not overlap the range are dropped.
+	got := memoryStructure(a, s, pool, memory.Range{Base: 0x1030, Size: 4}, memory.RangeList{{Base: 0x1000, Size: 48}})
+	assert.For(ctx, "no overlap").ThatSlice(got.Fields).IsEmpty()
+}
@@ -0,0 +1,80 @@
+// Copyright (C) 2017 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package resolve
+
+import (
+	"github.com/google/gapid/core/fault/cause"
+	"github.com/google/gapid/core/log"
+	"github.com/google/gapid/gapis/atom"
+	"github.com/google/gapid/gapis/database"
+	"github.com/google/gapid/gapis/gfxapi"
+	"github.com/google/gapid/gapis/messages"
+	"github.com/google/gapid/gapis/replay"
+	"github.com/google/gapid/gapis/service"
+	"github.com/google/gapid/gapis/service/path"
+)
+
+// PixelHistory resolves the list of commands in the frame that modified the
+// pixel described by p.
+func PixelHistory(ctx log.Context, p *path.PixelHistory) (*service.PixelHistory, error) {
+	obj, err := database.Build(ctx, &PixelHistoryResolvable{p})
+	if err != nil {
+		return nil, err
+	}
+	return obj.(*service.PixelHistory), nil
+}
+
+// Resolve implements the database.Resolver interface.
+func (r *PixelHistoryResolvable) Resolve(ctx log.Context) (interface{}, error) {
+	p := r.Path
+	if p.Device == nil {
+		return nil, &service.ErrInvalidArgument{Reason: messages.ErrMessage("A replay device is required for pixel history")}
+	}
+	attachment := gfxapi.FramebufferAttachment(p.Attachment)
+	if _, ok := gfxapi.FramebufferAttachment_name[int32(attachment)]; !ok {
+		return nil, &service.ErrInvalidArgument{Reason: messages.ErrInvalidEnumValue(p.Attachment, "FramebufferAttachment")}
+	}
+
+	intent := replay.Intent{
+		Device:  p.Device,
+		Capture: p.After.Commands.Capture,
+	}
+
+	after, err := Command(ctx, p.After)
+	if err != nil {
+		return nil, err
+	}
+
+	api := after.API()
+	if api == nil {
+		return nil, &service.ErrDataUnavailable{Reason: messages.ErrFramebufferUnavailable()}
+	}
+
+	query, ok := api.(replay.QueryPixelHistory)
+	if !ok {
+		return nil, &service.ErrDataUnavailable{Reason: messages.ErrFramebufferUnavailable()}
+	}
+
+	mgr := replay.GetManager(ctx)
+
+	res, err := query.QueryPixelHistory(ctx, intent, mgr, atom.ID(p.After.Index), p.X, p.Y, attachment)
+	if err != nil {
+		if _, ok := err.(*service.ErrDataUnavailable); ok {
+			return nil, err
+		}
+		return nil, cause.Explain(ctx, err, "Couldn't get pixel history")
+	}
+	return res, nil
+}
@@ -0,0 +1,63 @@
+// Copyright (C) 2017 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package resolve
+
+import (
+	"testing"
+
+	"github.com/google/gapid/core/assert"
+	"github.com/google/gapid/core/data/id"
+	"github.com/google/gapid/core/log"
+	"github.com/google/gapid/gapis/atom"
+	"github.com/google/gapid/gapis/database"
+	"github.com/google/gapid/gapis/gfxapi"
+	"github.com/google/gapid/gapis/messages"
+	"github.com/google/gapid/gapis/service"
+	"github.com/google/gapid/gapis/service/path"
+)
+
+func TestPixelHistoryErrors(t *testing.T) {
+	ctx := log.Testing(t)
+	ctx = database.Put(ctx, database.NewInMemory(ctx))
+	p := newPathTest(ctx, atom.NewList(
+		&testAtom{api: testAPI{}.ID()}, // API without pixel history support.
+		&testAtom{},                    // No API.
+	))
+	device := path.NewDevice(id.ID{1})
+	color := uint32(gfxapi.FramebufferAttachment_Color0)
+	unavailable := &service.ErrDataUnavailable{Reason: messages.ErrFramebufferUnavailable()}
+
+	for _, test := range []struct {
+		name     string
+		path     *path.PixelHistory
+		expected error
+	}{
+		{"no device",
+			&path.PixelHistory{After: p.Commands().Index(0), Attachment: color},
+			&service.ErrInvalidArgument{Reason: messages.ErrMessage("A replay device is required for pixel history")}},
+		{"invalid attachment",
+			&path.PixelHistory{After: p.Commands().Index(0), Attachment: 0xbad, Device: device},
+			&service.ErrInvalidArgument{Reason: messages.ErrInvalidEnumValue(uint32(0xbad), "FramebufferAttachment")}},
+		{"unsupported api",
+			&path.PixelHistory{After: p.Commands().Index(0), Attachment: color, Device: device},
+			unavailable},
+		{"no api",
+			&path.PixelHistory{After: p.Commands().Index(1), Attachment: color, Device: device},
+			unavailable},
+	} {
+		_, err := PixelHistory(ctx, test.path)
+		assert.For(ctx, test.name).ThatError(err).DeepEquals(test.expected)
+	}
+}
@@ -19,6 +19,7 @@ import (
 	"reflect"
 	"strings"
 
+	"github.com/google/gapid/core/event/task"
 	"github.com/google/gapid/core/fault/severity"
 	"github.com/google/gapid/core/log"
 	"github.com/google/gapid/gapis/atom"
@@ -29,6 +30,7 @@ import (
 	"github.com/google/gapid/gapis/replay"
 	"github.com/google/gapid/gapis/service"
 	"github.com/google/gapid/gapis/service/path"
+	"github.com/google/gapid/gapis/status"
 	"github.com/google/gapid/gapis/stringtable"
 )
 
@@ -112,14 +114,49 @@ func (r *ReportResolvable) Resolve(ctx log.Context) (interface{}, error) {
 			}
 		}
 	}
-	// Gather report items from the state mutator, and collect together all the
-	// APIs in use.
+	lints := map[gfxapi.API]gfxapi.Lint{}
+	lint := func(i int, a atom.Atom, api gfxapi.API) {
+		l, ok := lints[api]
+		if !ok {
+			if linter, ok := api.(gfxapi.Linter); ok {
+				l = linter.NewLint(ctx)
+			}
+			lints[api] = l
+		}
+		if l == nil {
+			return
+		}
+		// Errors and messages raised by the API while linting are reported by
+		// the mutation, so the state callbacks are detached from the report.
+		onError, newMessage, addTag := state.OnError, state.NewMessage, state.AddTag
+		state.OnError, state.NewMessage, state.AddTag = nil, nil, nil
+		defer func() {
+			state.OnError, state.NewMessage, state.AddTag = onError, newMessage, addTag
+		}()
+		for _, issue := range l.Check(ctx, a, state) {
+			item := service.WrapReportItem(
+				&service.ReportItem{
+					Severity: service.Severity(issue.Severity),
+					Command:  uint64(i),
+				}, issue.Message)
+			item.Tags = append(item.Tags, messages.TagPerformance(), getAtomNameTag(a))
+			builder.Add(ctx, item)
+		}
+	}
+	// Gather report items from the state mutator and the API lints, and
+	// collect together all the APIs in use.
 	apis := map[gfxapi.API]struct{}{}
 	for i, a := range atoms {
+		if err := task.StopReason(ctx); err != nil {
+			return nil, err
+		}
+		status.UpdateProgress(ctx, uint64(i), uint64(len(atoms)))
+		currentAtom = uint64(i)
 		if api := a.API(); api != nil {
 			apis[api] = struct{}{}
+			// The lint inspects the state before it is mutated by the atom.
+			lint(i, a, api)
 		}
-		currentAtom = uint64(i)
 		mutate(i, a)
 		for _, item := range items {
 			item.Tags = append(item.Tags, getAtomNameTag(a))
@@ -0,0 +1,104 @@
+// Copyright (C) 2017 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package resolve
+
+import (
+	"testing"
+
+	"github.com/google/gapid/core/assert"
+	"github.com/google/gapid/core/fault/severity"
+	"github.com/google/gapid/core/image"
+	"github.com/google/gapid/core/log"
+	"github.com/google/gapid/framework/binary"
+	"github.com/google/gapid/gapis/atom"
+	"github.com/google/gapid/gapis/database"
+	"github.com/google/gapid/gapis/gfxapi"
+	"github.com/google/gapid/gapis/messages"
+	"github.com/google/gapid/gapis/replay/builder"
+	"github.com/google/gapid/gapis/service"
+)
+
+// lintAPI is an API with a lint that raises errors and messages on the state
+// while checking each command.
+type lintAPI struct{}
+
+func (lintAPI) Name() string  { return "lint" }
+func (lintAPI) ID() gfxapi.ID { return gfxapi.ID{7, 8, 9} }
+func (lintAPI) Index() uint8  { return 13 }
+func (lintAPI) GetFramebufferAttachmentInfo(state *gfxapi.State, attachment gfxapi.FramebufferAttachment) (uint32, uint32, *image.Format, error) {
+	return 0, 0, nil, nil
+}
+func (lintAPI) Context(*gfxapi.State) gfxapi.Context { return nil }
+func (lintAPI) NewLint(ctx log.Context) gfxapi.Lint  { return noisyLint{} }
+
+type noisyLint struct{}
+
+func (noisyLint) Check(ctx log.Context, o interface{}, s *gfxapi.State) []gfxapi.LintIssue {
+	if s.OnError != nil {
+		s.OnError("lint error")
+	}
+	if s.NewMessage != nil {
+		s.NewMessage(severity.Error, messages.ErrMessage("lint message"))
+	}
+	if o.(*lintAtom).Redundant {
+		return []gfxapi.LintIssue{{Severity: severity.Warning, Message: messages.WarnRedundantStateChange()}}
+	}
+	return nil
+}
+
+type lintAtom struct {
+	binary.Generate
+	Redundant bool // If true, the lint reports the atom.
+	Fail      bool // If true, the atom raises an error when mutated.
+}
+
+func (lintAtom) API() gfxapi.API       { return lintAPI{} }
+func (lintAtom) AtomFlags() atom.Flags { return 0 }
+func (lintAtom) Extras() *atom.Extras  { return nil }
+func (a *lintAtom) Mutate(ctx log.Context, s *gfxapi.State, b *builder.Builder) error {
+	if a.Fail && s.OnError != nil {
+		s.OnError("mutate error")
+	}
+	return nil
+}
+
+func init() {
+	gfxapi.Register(lintAPI{})
+}
+
+func TestReportLint(t *testing.T) {
+	ctx := log.Testing(t)
+	ctx = database.Put(ctx, database.NewInMemory(ctx))
+	p := newPathTest(ctx, atom.NewList(
+		&lintAtom{},
+		&lintAtom{Redundant: true},
+		&lintAtom{Fail: true},
+	))
+
+	report, err := Report(ctx, p, nil)
+	if !assert.With(ctx).ThatError(err).Succeeded() {
+		return
+	}
+	// Only the lint issue and the mutation error are reported, not the errors
+	// and messages raised by the lint.
+	got := []service.ReportItem{}
+	for _, item := range report.Items {
+		got = append(got, service.ReportItem{Severity: item.Severity, Command: item.Command})
+	}
+	assert.With(ctx).ThatSlice(got).DeepEquals([]service.ReportItem{
+		{Severity: service.Severity_WarningLevel, Command: 1},
+		{Severity: service.Severity_ErrorLevel, Command: 2},
+	})
+}
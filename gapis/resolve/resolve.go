@@ -275,6 +275,10 @@ func Resolve(ctx log.Context, p path.Node) (interface{}, error) {
 		return As(ctx, p)
 	case *path.Blob:
 		return Blob(ctx, p)
+	case *path.Bookmark:
+		return Bookmark(ctx, p)
+	case *path.Bookmarks:
+		return Bookmarks(ctx, p)
 	case *path.Capture:
 		return Capture(ctx, p)
 	case *path.Command:
@@ -287,8 +291,12 @@ func Resolve(ctx log.Context, p path.Node) (interface{}, error) {
 		return Contexts(ctx, p)
 	case *path.Device:
 		return Device(ctx, p)
+	case *path.DrawCallState:
+		return DrawCallState(ctx, p)
 	case *path.Field:
 		return Field(ctx, p)
+	case *path.FramebufferAttachments:
+		return FramebufferAttachments(ctx, p)
 	case *path.Hierarchies:
 		return Hierarchies(ctx, p)
 	case *path.ImageInfo:
@@ -301,16 +309,22 @@ func Resolve(ctx log.Context, p path.Node) (interface{}, error) {
 		return Mesh(ctx, p)
 	case *path.Parameter:
 		return Parameter(ctx, p)
+	case *path.PixelHistory:
+		return PixelHistory(ctx, p)
 	case *path.Report:
 		return Report(ctx, p.Capture, p.Device)
 	case *path.ResourceData:
 		return ResourceData(ctx, p)
 	case *path.Resources:
 		return Resources(ctx, p.Capture)
+	case *path.ShaderTrace:
+		return ShaderTrace(ctx, p)
 	case *path.Slice:
 		return Slice(ctx, p)
 	case *path.State:
 		return APIState(ctx, p)
+	case *path.Stats:
+		return Stats(ctx, p)
 	case *path.Thumbnail:
 		return Thumbnail(ctx, p)
 	default:
@@ -49,29 +49,35 @@ func (r *ResourceDataResolvable) Resolve(ctx log.Context) (interface{}, error) {
 }
 
 func buildResource(ctx log.Context, state *gfxapi.State, p *path.ResourceData, IDMap gfxapi.ResourceMap) (gfxapi.Resource, error) {
-	list, err := NCommands(ctx, p.After.Commands, p.After.Index+1)
-	if err != nil {
+	if err := mutateWithResourceIDs(ctx, state, p.After, IDMap); err != nil {
 		return nil, err
 	}
+	id := p.Id.ID()
+	for r, i := range IDMap {
+		if i == id {
+			return r, nil
+		}
+	}
+	return nil, fmt.Errorf("Resource with id %v not found", p.Id.ID())
+}
+
+// mutateWithResourceIDs mutates state with the commands up to and including
+// after, adding the identifier of each created resource to IDMap.
+func mutateWithResourceIDs(ctx log.Context, state *gfxapi.State, after *path.Command, IDMap gfxapi.ResourceMap) error {
+	list, err := NCommands(ctx, after.Commands, after.Index+1)
+	if err != nil {
+		return err
+	}
 	var currentAtomIndex uint64
-	var resource gfxapi.Resource
 	var currentAtomResourceCount int
-	id := p.Id.ID()
 	state.OnResourceCreated = func(r gfxapi.Resource) {
 		currentAtomResourceCount++
-		i := genResourceID(currentAtomIndex, currentAtomResourceCount)
-		IDMap[r] = i
-		if i == id {
-			resource = r
-		}
+		IDMap[r] = genResourceID(currentAtomIndex, currentAtomResourceCount)
 	}
-	for i, a := range list.Atoms[:p.After.Index+1] {
+	for i, a := range list.Atoms[:after.Index+1] {
 		currentAtomResourceCount = 0
 		currentAtomIndex = uint64(i)
 		a.Mutate(ctx, state, nil /* no builder, just mutate */)
 	}
-	if resource != nil {
-		return resource, nil
-	}
-	return nil, fmt.Errorf("Resource with id %v not found", p.Id.ID())
+	return nil
 }
@@ -18,12 +18,14 @@ import (
 	"fmt"
 
 	"github.com/google/gapid/core/data/id"
+	"github.com/google/gapid/core/event/task"
 	"github.com/google/gapid/core/log"
 	"github.com/google/gapid/gapis/capture"
 	"github.com/google/gapid/gapis/database"
 	"github.com/google/gapid/gapis/gfxapi"
 	"github.com/google/gapid/gapis/service"
 	"github.com/google/gapid/gapis/service/path"
+	"github.com/google/gapid/gapis/status"
 )
 
 // Resources resolves all the resources used by the specified capture.
@@ -74,6 +76,10 @@ func (r *ResourcesResolvable) Resolve(ctx log.Context) (interface{}, error) {
 		}
 	}
 	for i, a := range list.Atoms {
+		if err := task.StopReason(ctx); err != nil {
+			return nil, err
+		}
+		status.UpdateProgress(ctx, uint64(i), uint64(len(list.Atoms)))
 		currentAtomResourceCount = 0
 		currentAtomIndex = uint64(i)
 		a.Mutate(ctx, state, nil /* no builder, just mutate */)
@@ -56,6 +56,12 @@ func change(ctx log.Context, p path.Node, val interface{}) (path.Node, error) {
 	case *path.Report:
 		return nil, fmt.Errorf("Reports are immutable")
 
+	case *path.Bookmarks:
+		return changeBookmarks(ctx, p, val)
+
+	case *path.Bookmark:
+		return changeBookmark(ctx, p, val)
+
 	case *path.ResourceData:
 		meta, err := ResourceMeta(ctx, p.Id, p.After)
 		if err != nil {
@@ -133,10 +139,21 @@ func change(ctx log.Context, p path.Node, val interface{}) (path.Node, error) {
 		if !ok {
 			return nil, fmt.Errorf("Expected *atom.List, got %T", val)
 		}
+		bookmarks, err := capture.Bookmarks(ctx, p.Capture)
+		if err != nil {
+			return nil, err
+		}
 		c, err := capture.ImportAtomList(ctx, old.Name+"*", atoms)
 		if err != nil {
 			return nil, err
 		}
+		if len(bookmarks) > 0 {
+			// Keep the bookmarks of the original capture that still refer to
+			// one of the commands.
+			if err := capture.SetBookmarks(ctx, c, bookmarks); err != nil {
+				return nil, err
+			}
+		}
 		return c.Commands(), nil
 
 	case *path.State:
@@ -0,0 +1,75 @@
+// Copyright (C) 2017 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package resolve
+
+import (
+	"github.com/google/gapid/core/fault/cause"
+	"github.com/google/gapid/core/log"
+	"github.com/google/gapid/gapis/atom"
+	"github.com/google/gapid/gapis/database"
+	"github.com/google/gapid/gapis/messages"
+	"github.com/google/gapid/gapis/replay"
+	"github.com/google/gapid/gapis/service"
+	"github.com/google/gapid/gapis/service/path"
+)
+
+// ShaderTrace resolves the values written by the fragment shader of the draw
+// call described by p, for the fragment covering the pixel of p.
+func ShaderTrace(ctx log.Context, p *path.ShaderTrace) (*service.ShaderTrace, error) {
+	obj, err := database.Build(ctx, &ShaderTraceResolvable{p})
+	if err != nil {
+		return nil, err
+	}
+	return obj.(*service.ShaderTrace), nil
+}
+
+// Resolve implements the database.Resolver interface.
+func (r *ShaderTraceResolvable) Resolve(ctx log.Context) (interface{}, error) {
+	p := r.Path
+	if p.Device == nil {
+		return nil, &service.ErrInvalidArgument{Reason: messages.ErrMessage("A replay device is required for shader traces")}
+	}
+
+	intent := replay.Intent{
+		Device:  p.Device,
+		Capture: p.After.Commands.Capture,
+	}
+
+	after, err := Command(ctx, p.After)
+	if err != nil {
+		return nil, err
+	}
+
+	api := after.API()
+	if api == nil {
+		return nil, &service.ErrDataUnavailable{Reason: messages.ErrMessage("Command has no API")}
+	}
+
+	query, ok := api.(replay.QueryShaderTrace)
+	if !ok {
+		return nil, &service.ErrDataUnavailable{Reason: messages.ErrMessage("Shader traces are not supported for this API")}
+	}
+
+	mgr := replay.GetManager(ctx)
+
+	res, err := query.QueryShaderTrace(ctx, intent, mgr, atom.ID(p.After.Index), p.X, p.Y, p.Variables)
+	if err != nil {
+		if _, ok := err.(*service.ErrDataUnavailable); ok {
+			return nil, err
+		}
+		return nil, cause.Explain(ctx, err, "Couldn't get shader trace")
+	}
+	return res, nil
+}
@@ -15,6 +15,7 @@
 package resolve
 
 import (
+	"github.com/google/gapid/core/event/task"
 	"github.com/google/gapid/core/log"
 	"github.com/google/gapid/framework/binary"
 	"github.com/google/gapid/gapis/atom"
@@ -24,6 +25,7 @@ import (
 	"github.com/google/gapid/gapis/messages"
 	"github.com/google/gapid/gapis/service"
 	"github.com/google/gapid/gapis/service/path"
+	"github.com/google/gapid/gapis/status"
 )
 
 // GlobalState resolves the global *gfxapi.State at a requested point in a
@@ -53,7 +55,12 @@ func (r *GlobalStateResolvable) Resolve(ctx log.Context) (interface{}, error) {
 		return nil, err
 	}
 	s := capture.NewState(ctx)
-	for _, a := range list.Atoms[:r.Path.After.Index+1] {
+	atoms := list.Atoms[:r.Path.After.Index+1]
+	for i, a := range atoms {
+		if err := task.StopReason(ctx); err != nil {
+			return nil, err
+		}
+		status.UpdateProgress(ctx, uint64(i), uint64(len(atoms)))
 		a.Mutate(ctx, s, nil)
 	}
 	return s, nil
@@ -81,7 +88,11 @@ func apiState(ctx log.Context, atoms []atom.Atom, p *path.State) (binary.Object,
 		return nil, &service.ErrDataUnavailable{Reason: messages.ErrStateUnavailable()}
 	}
 	s := capture.NewState(ctx)
-	for _, a := range atoms[:p.After.Index+1] {
+	for i, a := range atoms[:p.After.Index+1] {
+		if err := task.StopReason(ctx); err != nil {
+			return nil, err
+		}
+		status.UpdateProgress(ctx, uint64(i), p.After.Index+1)
 		a.Mutate(ctx, s, nil)
 	}
 	res, found := s.APIs[api]
@@ -0,0 +1,137 @@
+// Copyright (C) 2017 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package resolve
+
+import (
+	"github.com/google/gapid/core/event/task"
+	"github.com/google/gapid/core/fault/cause"
+	"github.com/google/gapid/core/log"
+	"github.com/google/gapid/gapis/capture"
+	"github.com/google/gapid/gapis/database"
+	"github.com/google/gapid/gapis/gfxapi"
+	"github.com/google/gapid/gapis/service"
+	"github.com/google/gapid/gapis/service/path"
+	"github.com/google/gapid/gapis/status"
+)
+
+// Stats resolves the per-frame and whole capture statistics of a capture.
+func Stats(ctx log.Context, p *path.Stats) (*service.Stats, error) {
+	obj, err := database.Build(ctx, &StatsResolvable{p.Capture})
+	if err != nil {
+		return nil, err
+	}
+	return obj.(*service.Stats), nil
+}
+
+// Resolve implements the database.Resolver interface.
+func (r *StatsResolvable) Resolve(ctx log.Context) (interface{}, error) {
+	ctx = capture.Put(ctx, r.Capture)
+
+	c, err := capture.Resolve(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	list, err := c.Atoms(ctx)
+	if err != nil {
+		return nil, err
+	}
+	atoms := list.Atoms
+
+	newFrameStats := func(first uint64) *service.FrameStats {
+		return &service.FrameStats{
+			Range:        &service.CommandRange{First: first},
+			StateChanges: map[string]uint64{},
+		}
+	}
+	out := &service.Stats{Total: newFrameStats(0)}
+	frame := newFrameStats(0)
+
+	var context gfxapi.Context
+	s := c.NewState()
+	for i, a := range atoms {
+		if err := task.StopReason(ctx); err != nil {
+			return nil, err
+		}
+		status.UpdateProgress(ctx, uint64(i), uint64(len(atoms)))
+
+		flags := a.AtomFlags()
+		if flags.IsDrawCall() {
+			frame.DrawCalls++
+		}
+
+		api := a.API()
+		if p, ok := api.(gfxapi.StatsProvider); ok {
+			stats, err := p.CommandStats(ctx, a, s)
+			if err != nil {
+				return nil, cause.Explain(ctx, err, "Gathering command statistics").With("atom", i)
+			}
+			addCommandStats(frame, stats)
+		}
+
+		a.Mutate(ctx, s, nil /* no builder, just mutate */)
+
+		if api != nil {
+			if bound := api.Context(s); bound != nil {
+				if context != nil && bound.ID() != context.ID() {
+					frame.ContextSwitches++
+				}
+				context = bound
+			}
+		}
+
+		frame.Range.Count++
+		if flags.IsEndOfFrame() {
+			out.Frames = append(out.Frames, frame)
+			frame = newFrameStats(uint64(i) + 1)
+		}
+	}
+	if frame.Range.Count > 0 {
+		out.Frames = append(out.Frames, frame)
+	}
+
+	for _, f := range out.Frames {
+		addFrameStats(out.Total, f)
+	}
+	out.Total.Range.Count = uint64(len(atoms))
+
+	return out, nil
+}
+
+func addCommandStats(f *service.FrameStats, c gfxapi.CommandStats) {
+	f.Primitives += c.Primitives
+	f.Vertices += c.Vertices
+	if c.StateChange != "" {
+		f.StateChanges[c.StateChange]++
+	}
+	f.TextureUploadBytes += c.TextureUploadBytes
+	f.BufferUploadBytes += c.BufferUploadBytes
+	if c.ShaderCompile {
+		f.ShaderCompiles++
+	}
+}
+
+func addFrameStats(total, f *service.FrameStats) {
+	total.DrawCalls += f.DrawCalls
+	total.Primitives += f.Primitives
+	total.Vertices += f.Vertices
+	for k, v := range f.StateChanges {
+		total.StateChanges[k] += v
+	}
+	total.TextureUploadBytes += f.TextureUploadBytes
+	total.BufferUploadBytes += f.BufferUploadBytes
+	total.ShaderCompiles += f.ShaderCompiles
+	total.ContextSwitches += f.ContextSwitches
+}
@@ -0,0 +1,172 @@
+// Copyright (C) 2017 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package resolve
+
+import (
+	"testing"
+
+	"github.com/google/gapid/core/assert"
+	"github.com/google/gapid/core/fault"
+	"github.com/google/gapid/core/image"
+	"github.com/google/gapid/core/log"
+	"github.com/google/gapid/framework/binary"
+	"github.com/google/gapid/gapis/atom"
+	"github.com/google/gapid/gapis/database"
+	"github.com/google/gapid/gapis/gfxapi"
+	"github.com/google/gapid/gapis/replay/builder"
+	"github.com/google/gapid/gapis/service"
+)
+
+// statsAPI is an API whose command statistics are described by the
+// statsAtoms.
+type statsAPI struct{}
+
+func (statsAPI) Name() string  { return "stats" }
+func (statsAPI) ID() gfxapi.ID { return gfxapi.ID{10, 11, 12} }
+func (statsAPI) Index() uint8  { return 12 }
+func (statsAPI) GetFramebufferAttachmentInfo(state *gfxapi.State, attachment gfxapi.FramebufferAttachment) (uint32, uint32, *image.Format, error) {
+	return 0, 0, nil, nil
+}
+func (statsAPI) Context(s *gfxapi.State) gfxapi.Context {
+	if c, ok := s.APIs[statsAPI{}].(*searchContext); ok {
+		return c
+	}
+	return nil
+}
+func (statsAPI) CommandStats(ctx log.Context, o interface{}, s *gfxapi.State) (gfxapi.CommandStats, error) {
+	a := o.(*statsAtom)
+	if a.Fail {
+		return gfxapi.CommandStats{}, errMalformedAtom
+	}
+	return gfxapi.CommandStats{
+		Vertices:          a.Vertices,
+		Primitives:        a.Vertices / 3,
+		StateChange:       a.StateChange,
+		BufferUploadBytes: a.Upload,
+		ShaderCompile:     a.Compile,
+	}, nil
+}
+
+const errMalformedAtom = fault.Const("Malformed atom")
+
+type statsAtom struct {
+	binary.Generate
+	DrawCall    bool   // If true, the atom is a draw call.
+	EndOfFrame  bool   // If true, the atom ends the frame.
+	Context     uint8  // If not 0, the atom makes this context current.
+	Vertices    uint64 // The number of vertices drawn.
+	StateChange string // The category of the state changed.
+	Upload      uint64 // The number of bytes uploaded to buffers.
+	Compile     bool   // If true, the atom compiles a shader.
+	Fail        bool   // If true, the API fails to inspect the atom.
+}
+
+func (statsAtom) API() gfxapi.API { return statsAPI{} }
+func (a *statsAtom) AtomFlags() atom.Flags {
+	var flags atom.Flags
+	if a.DrawCall {
+		flags |= atom.DrawCall
+	}
+	if a.EndOfFrame {
+		flags |= atom.EndOfFrame
+	}
+	return flags
+}
+func (statsAtom) Extras() *atom.Extras { return nil }
+func (a *statsAtom) Mutate(ctx log.Context, s *gfxapi.State, b *builder.Builder) error {
+	if a.Context != 0 {
+		s.APIs[statsAPI{}] = &searchContext{Id: a.Context}
+	}
+	return nil
+}
+
+func init() {
+	gfxapi.Register(statsAPI{})
+}
+
+func TestStats(t *testing.T) {
+	ctx := log.Testing(t)
+	ctx = database.Put(ctx, database.NewInMemory(ctx))
+	p := newPathTest(ctx, atom.NewList(
+		// Frame 0
+		&statsAtom{Context: 1, StateChange: "program", Compile: true},
+		&statsAtom{StateChange: "buffer", Upload: 16},
+		&statsAtom{DrawCall: true, Vertices: 6},
+		&statsAtom{Context: 2},
+		&statsAtom{DrawCall: true, Vertices: 3},
+		&statsAtom{EndOfFrame: true},
+		// Frame 1
+		&statsAtom{StateChange: "buffer", Upload: 4},
+		&statsAtom{Context: 2, DrawCall: true, Vertices: 9},
+		&statsAtom{Context: 1},
+		// Frame 2, unterminated.
+		&statsAtom{EndOfFrame: true},
+		&statsAtom{StateChange: "program"},
+	))
+
+	stats, err := Stats(ctx, p.Stats())
+	if !assert.With(ctx).ThatError(err).Succeeded() {
+		return
+	}
+	assert.With(ctx).That(stats).DeepEquals(&service.Stats{
+		Total: &service.FrameStats{
+			Range:             &service.CommandRange{First: 0, Count: 11},
+			DrawCalls:         3,
+			Primitives:        6,
+			Vertices:          18,
+			StateChanges:      map[string]uint64{"program": 2, "buffer": 2},
+			BufferUploadBytes: 20,
+			ShaderCompiles:    1,
+			ContextSwitches:   2,
+		},
+		Frames: []*service.FrameStats{
+			{
+				Range:             &service.CommandRange{First: 0, Count: 6},
+				DrawCalls:         2,
+				Primitives:        3,
+				Vertices:          9,
+				StateChanges:      map[string]uint64{"program": 1, "buffer": 1},
+				BufferUploadBytes: 16,
+				ShaderCompiles:    1,
+				ContextSwitches:   1,
+			},
+			{
+				Range:             &service.CommandRange{First: 6, Count: 4},
+				DrawCalls:         1,
+				Primitives:        3,
+				Vertices:          9,
+				StateChanges:      map[string]uint64{"buffer": 1},
+				BufferUploadBytes: 4,
+				ContextSwitches:   1,
+			},
+			{
+				Range:        &service.CommandRange{First: 10, Count: 1},
+				StateChanges: map[string]uint64{"program": 1},
+			},
+		},
+	})
+}
+
+func TestStatsError(t *testing.T) {
+	ctx := log.Testing(t)
+	ctx = database.Put(ctx, database.NewInMemory(ctx))
+	p := newPathTest(ctx, atom.NewList(
+		&statsAtom{Context: 1},
+		&statsAtom{DrawCall: true, Vertices: 3, Fail: true},
+		&statsAtom{EndOfFrame: true},
+	))
+	_, err := Stats(ctx, p.Stats())
+	assert.With(ctx).ThatError(err).HasCause(errMalformedAtom)
+}
@@ -20,6 +20,7 @@
 set(files
     grpc.go
     server.go
+    server_test.go
 )
 set(dirs
     
@@ -17,12 +17,14 @@ package server
 import (
 	"fmt"
 	"net"
+	"time"
 
 	"github.com/google/gapid/core/app/auth"
 	"github.com/google/gapid/core/context/keys"
 	"github.com/google/gapid/core/log"
 	"github.com/google/gapid/core/net/grpcutil"
 	"github.com/google/gapid/gapis/service"
+	"github.com/google/gapid/gapis/service/path"
 	"golang.org/x/net/context"
 	"google.golang.org/grpc"
 )
@@ -36,7 +38,9 @@ func Listen(ctx log.Context, addr string, cfg Config) error {
 		fmt.Printf("Bound on port '%d'\n", listener.Addr().(*net.TCPAddr).Port)
 		service.RegisterGapidServer(server, s)
 		return nil
-	}, grpc.UnaryInterceptor(auth.ServerInterceptor(cfg.AuthToken)))
+	},
+		grpc.UnaryInterceptor(auth.ServerInterceptor(cfg.AuthToken)),
+		grpc.StreamInterceptor(auth.StreamServerInterceptor(cfg.AuthToken)))
 }
 
 // NewGapidServer returns a GapidServer interface to a new server instace.
@@ -191,9 +195,32 @@ func (s *grpcServer) GetDevicesForReplay(ctx context.Context, req *service.GetDe
 }
 
 func (s *grpcServer) GetFramebufferAttachment(ctx context.Context, req *service.GetFramebufferAttachmentRequest) (*service.GetFramebufferAttachmentResponse, error) {
-	image, err := s.handler.GetFramebufferAttachment(s.bindCtx(log.Wrap(ctx)), req.Device, req.After, req.Attachment, req.Settings)
+	image, err := s.handler.GetFramebufferAttachment(s.bindCtx(log.Wrap(ctx)), req.Device, req.After, req.Attachment, req.Layer, req.Settings)
 	if err := service.NewError(err); err != nil {
 		return &service.GetFramebufferAttachmentResponse{Res: &service.GetFramebufferAttachmentResponse_Error{Error: err}}, nil
 	}
 	return &service.GetFramebufferAttachmentResponse{Res: &service.GetFramebufferAttachmentResponse_Image{Image: image}}, nil
 }
+
+func (s *grpcServer) FindCommands(req *service.FindCommandsRequest, stream service.Gapid_FindCommandsServer) error {
+	ctx := s.bindCtx(log.Wrap(stream.Context()))
+	err := s.handler.FindCommands(ctx, req.Search, int(req.MaxResults), func(p *path.Command) error {
+		return stream.Send(&service.FindCommandsResponse{Res: &service.FindCommandsResponse_Command{Command: p}})
+	})
+	if err := service.NewError(err); err != nil {
+		return stream.Send(&service.FindCommandsResponse{Res: &service.FindCommandsResponse_Error{Error: err}})
+	}
+	return nil
+}
+
+func (s *grpcServer) Status(req *service.StatusRequest, stream service.Gapid_StatusServer) error {
+	ctx := s.bindCtx(log.Wrap(stream.Context()))
+	interval := time.Duration(req.UpdateIntervalMs) * time.Millisecond
+	err := s.handler.Status(ctx, interval, func(tasks *service.Tasks) error {
+		return stream.Send(&service.StatusResponse{Res: &service.StatusResponse_Tasks{Tasks: tasks}})
+	})
+	if err := service.NewError(err); err != nil {
+		return stream.Send(&service.StatusResponse{Res: &service.StatusResponse_Error{Error: err}})
+	}
+	return nil
+}
@@ -23,10 +23,12 @@ import (
 	"os"
 	"path/filepath"
 	"runtime/pprof"
+	"time"
 
 	"github.com/google/gapid/core/app/auth"
 	"github.com/google/gapid/core/app/benchmark"
 	"github.com/google/gapid/core/event/task"
+	"github.com/google/gapid/core/fault"
 	"github.com/google/gapid/core/log"
 	"github.com/google/gapid/core/os/device/bind"
 	"github.com/google/gapid/framework/binary"
@@ -39,9 +41,19 @@ import (
 	"github.com/google/gapid/gapis/resolve"
 	"github.com/google/gapid/gapis/service"
 	"github.com/google/gapid/gapis/service/path"
+	"github.com/google/gapid/gapis/status"
 	"github.com/google/gapid/gapis/stringtable"
 )
 
+const (
+	// defaultStatusInterval is the interval between status updates if the
+	// client does not specify one.
+	defaultStatusInterval = 500 * time.Millisecond
+
+	// errMaxResults is used to stop a search once enough results are found.
+	errMaxResults = fault.Const("Maximum number of results reached")
+)
+
 // Config holds the server configuration settings.
 type Config struct {
 	Info           *service.ServerInfo
@@ -171,6 +183,7 @@ func (s *server) GetFramebufferAttachment(
 	device *path.Device,
 	after *path.Command,
 	attachment gfxapi.FramebufferAttachment,
+	layer *gfxapi.FramebufferLayer,
 	settings *service.RenderSettings) (*path.ImageInfo, error) {
 
 	// TODO: Path validation
@@ -180,7 +193,9 @@ func (s *server) GetFramebufferAttachment(
 	// if err := after.Validate(); err != nil {
 	// 	return nil, err
 	// }
-	return resolve.FramebufferAttachment(ctx, device, after, attachment, settings)
+	ctx = status.Start(ctx, "GetFramebufferAttachment")
+	defer status.Finish(ctx)
+	return resolve.FramebufferAttachment(ctx, device, after, attachment, layer, settings)
 }
 
 func (s *server) Get(ctx log.Context, p *path.Any) (interface{}, error) {
@@ -188,6 +203,8 @@ func (s *server) Get(ctx log.Context, p *path.Any) (interface{}, error) {
 	// if err := p.Validate(); err != nil {
 	// 	return nil, err
 	// }
+	ctx = status.Start(ctx, "Get %v", p)
+	defer status.Finish(ctx)
 	v, err := resolve.Get(ctx, p)
 	if err != nil {
 		return nil, err
@@ -236,3 +253,52 @@ func (s *server) GetProfile(ctx log.Context, name string, debug int32) ([]byte,
 	}
 	return b.Bytes(), nil
 }
+
+func (s *server) FindCommands(ctx log.Context, search *path.CommandSearch, max int, f func(*path.Command) error) error {
+	ctx = status.Start(ctx, "FindCommands %v", search)
+	defer status.Finish(ctx)
+	count := 0
+	err := resolve.FindCommands(ctx, search, func(p *path.Command) error {
+		if err := f(p); err != nil {
+			return err
+		}
+		count++
+		if max > 0 && count >= max {
+			return errMaxResults
+		}
+		return nil
+	})
+	if err == errMaxResults {
+		return nil
+	}
+	return err
+}
+
+func (s *server) Status(ctx log.Context, interval time.Duration, f func(*service.Tasks) error) error {
+	if interval <= 0 {
+		interval = defaultStatusInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		tasks := &service.Tasks{}
+		for _, t := range status.Tasks() {
+			tasks.List = append(tasks.List, &service.Task{
+				Id:        t.ID,
+				Parent:    t.Parent,
+				Name:      t.Name,
+				ElapsedMs: uint64(t.Elapsed / time.Millisecond),
+				Completed: t.Completed,
+				Total:     t.Total,
+			})
+		}
+		if err := f(tasks); err != nil {
+			return err
+		}
+		select {
+		case <-task.ShouldStop(ctx):
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
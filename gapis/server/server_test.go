@@ -0,0 +1,66 @@
+// Copyright (C) 2017 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server_test
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/google/gapid/core/assert"
+	"github.com/google/gapid/core/log"
+	"github.com/google/gapid/gapis/atom"
+	"github.com/google/gapid/gapis/atom/test"
+	"github.com/google/gapid/gapis/capture"
+	"github.com/google/gapid/gapis/database"
+	"github.com/google/gapid/gapis/server"
+	"github.com/google/gapid/gapis/service/path"
+)
+
+func TestFindCommandsMaxResults(t *testing.T) {
+	ctx := log.Testing(t)
+	ctx = database.Put(ctx, database.NewInMemory(ctx))
+	p, err := capture.ImportAtomList(ctx, "test", atom.NewList(
+		&test.AtomA{ID: 1}, &test.AtomB{ID: 2}, &test.AtomC{String: "c"},
+	))
+	if !assert.With(ctx).ThatError(err).Succeeded() {
+		return
+	}
+	s := server.New(ctx, server.Config{})
+
+	for _, test := range []struct {
+		name     string
+		max      int
+		expected []uint64
+	}{
+		{"no limit", 0, []uint64{0, 1, 2}},
+		{"limited", 2, []uint64{0, 1}},
+		{"limit reached at end", 3, []uint64{0, 1, 2}},
+		{"limit above count", 10, []uint64{0, 1, 2}},
+	} {
+		got := []uint64{}
+		err := s.FindCommands(ctx, p.Commands().Search(nil), test.max, func(c *path.Command) error {
+			got = append(got, c.Index)
+			return nil
+		})
+		if assert.For(ctx, test.name).ThatError(err).Succeeded() {
+			assert.For(ctx, test.name).ThatSlice(got).Equals(test.expected)
+		}
+	}
+
+	// Errors returned by the callback are not hidden by the limit.
+	stop := fmt.Errorf("stop")
+	err = s.FindCommands(ctx, p.Commands().Search(nil), 1, func(*path.Command) error { return stop })
+	assert.For(ctx, "callback error").ThatError(err).Equals(stop)
+}
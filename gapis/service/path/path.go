@@ -20,6 +20,7 @@ import (
 
 	"github.com/google/gapid/core/data/id"
 	"github.com/google/gapid/core/data/protoutil"
+	"github.com/google/gapid/core/data/search"
 	"github.com/google/gapid/core/image"
 	"github.com/google/gapid/gapis/service/pod"
 )
@@ -48,64 +49,86 @@ type Node interface {
 	// Validate() error
 }
 
-func (n *ArrayIndex) Path() *Any   { return &Any{&Any_ArrayIndex{n}} }
-func (n *As) Path() *Any           { return &Any{&Any_As{n}} }
-func (n *Blob) Path() *Any         { return &Any{&Any_Blob{n}} }
-func (n *Capture) Path() *Any      { return &Any{&Any_Capture{n}} }
-func (n *Command) Path() *Any      { return &Any{&Any_Command{n}} }
-func (n *Commands) Path() *Any     { return &Any{&Any_Commands{n}} }
-func (n *Context) Path() *Any      { return &Any{&Any_Context{n}} }
-func (n *Contexts) Path() *Any     { return &Any{&Any_Contexts{n}} }
-func (n *Device) Path() *Any       { return &Any{&Any_Device{n}} }
-func (n *Field) Path() *Any        { return &Any{&Any_Field{n}} }
-func (n *Hierarchies) Path() *Any  { return &Any{&Any_Hierarchies{n}} }
-func (n *Hierarchy) Path() *Any    { return &Any{&Any_Hierarchy{n}} }
-func (n *ImageInfo) Path() *Any    { return &Any{&Any_ImageInfo{n}} }
-func (n *MapIndex) Path() *Any     { return &Any{&Any_MapIndex{n}} }
-func (n *Memory) Path() *Any       { return &Any{&Any_Memory{n}} }
-func (n *Mesh) Path() *Any         { return &Any{&Any_Mesh{n}} }
-func (n *Parameter) Path() *Any    { return &Any{&Any_Parameter{n}} }
-func (n *Report) Path() *Any       { return &Any{&Any_Report{n}} }
-func (n *ResourceData) Path() *Any { return &Any{&Any_ResourceData{n}} }
-func (n *Resources) Path() *Any    { return &Any{&Any_Resources{n}} }
-func (n *Slice) Path() *Any        { return &Any{&Any_Slice{n}} }
-func (n *State) Path() *Any        { return &Any{&Any_State{n}} }
-func (n *Thumbnail) Path() *Any    { return &Any{&Any_Thumbnail{n}} }
-
-func (n ArrayIndex) Parent() Node   { return oneOfNode(n.Array) }
-func (n As) Parent() Node           { return oneOfNode(n.From) }
-func (n Blob) Parent() Node         { return nil }
-func (n Capture) Parent() Node      { return nil }
-func (n Command) Parent() Node      { return n.Commands }
-func (n Commands) Parent() Node     { return n.Capture }
-func (n Context) Parent() Node      { return n.Contexts }
-func (n Contexts) Parent() Node     { return n.Capture }
-func (n Device) Parent() Node       { return nil }
-func (n Field) Parent() Node        { return oneOfNode(n.Struct) }
-func (n Hierarchies) Parent() Node  { return n.Capture }
-func (n Hierarchy) Parent() Node    { return n.Hierarchies }
-func (n ImageInfo) Parent() Node    { return nil }
-func (n MapIndex) Parent() Node     { return oneOfNode(n.Map) }
-func (n Memory) Parent() Node       { return n.After }
-func (n Mesh) Parent() Node         { return oneOfNode(n.Object) }
-func (n Parameter) Parent() Node    { return n.Command }
-func (n Report) Parent() Node       { return n.Capture }
-func (n ResourceData) Parent() Node { return n.After }
-func (n Resources) Parent() Node    { return n.Capture }
-func (n Slice) Parent() Node        { return oneOfNode(n.Array) }
-func (n State) Parent() Node        { return n.After }
-func (n Thumbnail) Parent() Node    { return oneOfNode(n.Object) }
-
-func (n ArrayIndex) Text() string  { return fmt.Sprintf("%v[%v]", n.Parent().Text(), n.Index) }
-func (n As) Text() string          { return fmt.Sprintf("%v.as<%v>", n.Parent().Text(), protoutil.OneOf(n.To)) }
-func (n Blob) Text() string        { return fmt.Sprintf("blob<%x>", n.Id.Data) }
-func (n Capture) Text() string     { return fmt.Sprintf("capture<%x>", n.Id.Data) }
-func (n Command) Text() string     { return fmt.Sprintf("%v[%v]", n.Parent().Text(), n.Index) }
-func (n Commands) Text() string    { return fmt.Sprintf("%v.commands", n.Parent().Text()) }
-func (n Context) Text() string     { return fmt.Sprintf("%v[%x]", n.Parent().Text(), n.Id.Data) }
-func (n Contexts) Text() string    { return fmt.Sprintf("%v.contexts", n.Parent().Text()) }
-func (n Device) Text() string      { return fmt.Sprintf("device<%x>", n.Id.Data) }
-func (n Field) Text() string       { return fmt.Sprintf("%v.%v", n.Parent().Text(), n.Name) }
+func (n *ArrayIndex) Path() *Any             { return &Any{&Any_ArrayIndex{n}} }
+func (n *As) Path() *Any                     { return &Any{&Any_As{n}} }
+func (n *Blob) Path() *Any                   { return &Any{&Any_Blob{n}} }
+func (n *Bookmark) Path() *Any               { return &Any{&Any_Bookmark{n}} }
+func (n *Bookmarks) Path() *Any              { return &Any{&Any_Bookmarks{n}} }
+func (n *Capture) Path() *Any                { return &Any{&Any_Capture{n}} }
+func (n *Command) Path() *Any                { return &Any{&Any_Command{n}} }
+func (n *Commands) Path() *Any               { return &Any{&Any_Commands{n}} }
+func (n *Context) Path() *Any                { return &Any{&Any_Context{n}} }
+func (n *Contexts) Path() *Any               { return &Any{&Any_Contexts{n}} }
+func (n *Device) Path() *Any                 { return &Any{&Any_Device{n}} }
+func (n *DrawCallState) Path() *Any          { return &Any{&Any_DrawCallState{n}} }
+func (n *Field) Path() *Any                  { return &Any{&Any_Field{n}} }
+func (n *FramebufferAttachments) Path() *Any { return &Any{&Any_FramebufferAttachments{n}} }
+func (n *Hierarchies) Path() *Any            { return &Any{&Any_Hierarchies{n}} }
+func (n *Hierarchy) Path() *Any              { return &Any{&Any_Hierarchy{n}} }
+func (n *ImageInfo) Path() *Any              { return &Any{&Any_ImageInfo{n}} }
+func (n *MapIndex) Path() *Any               { return &Any{&Any_MapIndex{n}} }
+func (n *Memory) Path() *Any                 { return &Any{&Any_Memory{n}} }
+func (n *Mesh) Path() *Any                   { return &Any{&Any_Mesh{n}} }
+func (n *Parameter) Path() *Any              { return &Any{&Any_Parameter{n}} }
+func (n *PixelHistory) Path() *Any           { return &Any{&Any_PixelHistory{n}} }
+func (n *Report) Path() *Any                 { return &Any{&Any_Report{n}} }
+func (n *ResourceData) Path() *Any           { return &Any{&Any_ResourceData{n}} }
+func (n *Resources) Path() *Any              { return &Any{&Any_Resources{n}} }
+func (n *ShaderTrace) Path() *Any            { return &Any{&Any_ShaderTrace{n}} }
+func (n *Slice) Path() *Any                  { return &Any{&Any_Slice{n}} }
+func (n *State) Path() *Any                  { return &Any{&Any_State{n}} }
+func (n *Stats) Path() *Any                  { return &Any{&Any_Stats{n}} }
+func (n *Thumbnail) Path() *Any              { return &Any{&Any_Thumbnail{n}} }
+
+func (n ArrayIndex) Parent() Node             { return oneOfNode(n.Array) }
+func (n As) Parent() Node                     { return oneOfNode(n.From) }
+func (n Blob) Parent() Node                   { return nil }
+func (n Bookmark) Parent() Node               { return n.Bookmarks }
+func (n Bookmarks) Parent() Node              { return n.Capture }
+func (n Capture) Parent() Node                { return nil }
+func (n Command) Parent() Node                { return n.Commands }
+func (n Commands) Parent() Node               { return n.Capture }
+func (n Context) Parent() Node                { return n.Contexts }
+func (n Contexts) Parent() Node               { return n.Capture }
+func (n Device) Parent() Node                 { return nil }
+func (n DrawCallState) Parent() Node          { return n.After }
+func (n Field) Parent() Node                  { return oneOfNode(n.Struct) }
+func (n FramebufferAttachments) Parent() Node { return n.After }
+func (n Hierarchies) Parent() Node            { return n.Capture }
+func (n Hierarchy) Parent() Node              { return n.Hierarchies }
+func (n ImageInfo) Parent() Node              { return nil }
+func (n MapIndex) Parent() Node               { return oneOfNode(n.Map) }
+func (n Memory) Parent() Node                 { return n.After }
+func (n Mesh) Parent() Node                   { return oneOfNode(n.Object) }
+func (n Parameter) Parent() Node              { return n.Command }
+func (n PixelHistory) Parent() Node           { return n.After }
+func (n Report) Parent() Node                 { return n.Capture }
+func (n ResourceData) Parent() Node           { return n.After }
+func (n Resources) Parent() Node              { return n.Capture }
+func (n ShaderTrace) Parent() Node            { return n.After }
+func (n Slice) Parent() Node                  { return oneOfNode(n.Array) }
+func (n State) Parent() Node                  { return n.After }
+func (n Stats) Parent() Node                  { return n.Capture }
+func (n Thumbnail) Parent() Node              { return oneOfNode(n.Object) }
+
+func (n ArrayIndex) Text() string { return fmt.Sprintf("%v[%v]", n.Parent().Text(), n.Index) }
+func (n As) Text() string         { return fmt.Sprintf("%v.as<%v>", n.Parent().Text(), protoutil.OneOf(n.To)) }
+func (n Blob) Text() string       { return fmt.Sprintf("blob<%x>", n.Id.Data) }
+func (n Bookmark) Text() string   { return fmt.Sprintf("%v[%v]", n.Parent().Text(), n.Index) }
+func (n Bookmarks) Text() string  { return fmt.Sprintf("%v.bookmarks", n.Parent().Text()) }
+func (n Capture) Text() string    { return fmt.Sprintf("capture<%x>", n.Id.Data) }
+func (n Command) Text() string    { return fmt.Sprintf("%v[%v]", n.Parent().Text(), n.Index) }
+func (n Commands) Text() string   { return fmt.Sprintf("%v.commands", n.Parent().Text()) }
+func (n Context) Text() string    { return fmt.Sprintf("%v[%x]", n.Parent().Text(), n.Id.Data) }
+func (n Contexts) Text() string   { return fmt.Sprintf("%v.contexts", n.Parent().Text()) }
+func (n Device) Text() string     { return fmt.Sprintf("device<%x>", n.Id.Data) }
+func (n DrawCallState) Text() string {
+	return fmt.Sprintf("%v.draw-call-state", n.Parent().Text())
+}
+func (n Field) Text() string { return fmt.Sprintf("%v.%v", n.Parent().Text(), n.Name) }
+func (n FramebufferAttachments) Text() string {
+	return fmt.Sprintf("%v.framebuffer-attachments", n.Parent().Text())
+}
 func (n Hierarchies) Text() string { return fmt.Sprintf("%v.hierarchies", n.Parent().Text()) }
 func (n Hierarchy) Text() string   { return fmt.Sprintf("%v[%x]", n.Parent().Text(), n.Id.Data) }
 func (n ImageInfo) Text() string   { return fmt.Sprintf("image-info<%x>", n.Id.Data) }
@@ -113,13 +136,20 @@ func (n MapIndex) Text() string    { return fmt.Sprintf("%v[%x]", n.Parent().Tex
 func (n Memory) Text() string      { return fmt.Sprintf("%v.memory-after", n.Parent().Text()) }
 func (n Mesh) Text() string        { return fmt.Sprintf("%v.mesh", n.Parent().Text()) }
 func (n Parameter) Text() string   { return fmt.Sprintf("%v.%v", n.Parent().Text(), n.Name) }
-func (n Report) Text() string      { return fmt.Sprintf("%v.report", n.Parent().Text()) }
+func (n PixelHistory) Text() string {
+	return fmt.Sprintf("%v.pixel-history<%v,%v,%v>", n.Parent().Text(), n.X, n.Y, n.Attachment)
+}
+func (n Report) Text() string { return fmt.Sprintf("%v.report", n.Parent().Text()) }
 func (n ResourceData) Text() string {
 	return fmt.Sprintf("%v.resource-data<%x>", n.Parent().Text(), n.Id.Data)
 }
 func (n Resources) Text() string { return fmt.Sprintf("%v.resources", n.Parent().Text()) }
+func (n ShaderTrace) Text() string {
+	return fmt.Sprintf("%v.shader-trace<%v,%v>", n.Parent().Text(), n.X, n.Y)
+}
 func (n Slice) Text() string     { return fmt.Sprintf("%v[%v:%v]", n.Parent().Text(), n.Start, n.End) }
 func (n State) Text() string     { return fmt.Sprintf("%v.state-after", n.Parent().Text()) }
+func (n Stats) Text() string     { return fmt.Sprintf("%v.stats", n.Parent().Text()) }
 func (n Thumbnail) Text() string { return fmt.Sprintf("%v.thumbnail", n.Parent().Text()) }
 
 func (n *ArrayIndex) SetParent(p Node) {
@@ -248,6 +278,21 @@ func (n *Capture) Contexts() *Contexts {
 	return &Contexts{Capture: n}
 }
 
+// Bookmarks returns the path node to the capture's bookmarks.
+func (n *Capture) Bookmarks() *Bookmarks {
+	return &Bookmarks{Capture: n}
+}
+
+// Index returns the path to the i'th bookmark of the capture.
+func (n *Bookmarks) Index(i uint64) *Bookmark {
+	return &Bookmark{Bookmarks: n, Index: i}
+}
+
+// Stats returns the path node to the capture's statistics.
+func (n *Capture) Stats() *Stats {
+	return &Stats{Capture: n}
+}
+
 // Hierarchies returns the path node to the capture's hierarchies.
 func (n *Capture) Hierarchies() *Hierarchies {
 	return &Hierarchies{Capture: n}
@@ -258,6 +303,11 @@ func (n *Capture) Commands() *Commands {
 	return &Commands{Capture: n}
 }
 
+// Search returns a CommandSearch for the commands that match the query q.
+func (n *Commands) Search(q *search.Query) *CommandSearch {
+	return &CommandSearch{Commands: n, Query: q}
+}
+
 // Index returns the path node to a single command in the a list of commands.
 func (n *Commands) Index(i uint64) *Command {
 	return &Command{Commands: n, Index: i}
@@ -273,6 +323,17 @@ func (n *Command) MemoryAfter(pool uint32, addr, size uint64) *Memory {
 	}
 }
 
+// DrawCallState returns the path node to the state used by this draw command.
+func (n *Command) DrawCallState() *DrawCallState {
+	return &DrawCallState{After: n}
+}
+
+// FramebufferAttachments returns the path node to the list of attachments of
+// the framebuffer bound after this command.
+func (n *Command) FramebufferAttachments() *FramebufferAttachments {
+	return &FramebufferAttachments{After: n}
+}
+
 // Mesh returns the path node to the mesh of this command.
 func (n *Command) Mesh(faceted bool) *Mesh {
 	return &Mesh{
@@ -281,6 +342,36 @@ func (n *Command) Mesh(faceted bool) *Mesh {
 	}
 }
 
+// As returns the path node to the mesh exported to the file format f.
+func (n *Mesh) As(f MeshFormat) *As {
+	return &As{To: &As_MeshFormat{f}, From: &As_Mesh{n}}
+}
+
+// PixelHistory returns the path node to the history of the pixel (x, y) of
+// the framebuffer attachment, up to and including this command.
+func (n *Command) PixelHistory(x, y, attachment uint32, d *Device) *PixelHistory {
+	return &PixelHistory{
+		After:      n,
+		X:          x,
+		Y:          y,
+		Attachment: attachment,
+		Device:     d,
+	}
+}
+
+// ShaderTrace returns the path node to the values written by the fragment
+// shader of this draw command for the pixel (x, y). If variables is empty then
+// all variables are traced.
+func (n *Command) ShaderTrace(x, y uint32, variables []string, d *Device) *ShaderTrace {
+	return &ShaderTrace{
+		After:     n,
+		X:         x,
+		Y:         y,
+		Variables: variables,
+		Device:    d,
+	}
+}
+
 // StateAfter returns the path node to the state after this command.
 func (n *Command) StateAfter() *State {
 	return &State{After: n}
@@ -19,6 +19,7 @@ package service
 
 import (
 	"fmt"
+	"time"
 
 	"github.com/google/gapid/core/data/id"
 	"github.com/google/gapid/core/data/protoutil"
@@ -76,6 +77,9 @@ type Service interface {
 	// GetFramebufferAttachment returns the ImageInfo identifier describing the
 	// given framebuffer attachment and device, immediately following the atom
 	// after.
+	// If layer is not nil then the given layer and mip level of the attached
+	// image is returned instead of the layer and level bound to the
+	// framebuffer.
 	// The provided RenderSettings structure can be used to adjust maximum desired
 	// dimensions of the image, as well as applying debug visualizations.
 	GetFramebufferAttachment(
@@ -83,6 +87,7 @@ type Service interface {
 		device *path.Device,
 		after *path.Command,
 		attachment gfxapi.FramebufferAttachment,
+		layer *gfxapi.FramebufferLayer,
 		settings *RenderSettings) (*path.ImageInfo, error)
 
 	// Get resolves and returns the object, value or memory at the path p.
@@ -113,6 +118,17 @@ type Service interface {
 
 	// GetProfile returns the pprof profile with the given name.
 	GetProfile(ctx log.Context, name string, debug int32) ([]byte, error)
+
+	// Status calls f with the list of tasks in flight on the server, and then
+	// again every interval until ctx is cancelled or f returns an error.
+	// Cancelling the context of any other call stops the work performed for
+	// that call.
+	Status(ctx log.Context, interval time.Duration, f func(*Tasks) error) error
+
+	// FindCommands calls f with the path of each command that matches the
+	// search, in command order. If max is greater than 0 then at most max
+	// commands are reported.
+	FindCommands(ctx log.Context, search *path.CommandSearch, max int, f func(*path.Command) error) error
 }
 
 // NewError attempts to box and return err into an Error.
@@ -167,10 +183,22 @@ func NewValue(v interface{}) *Value {
 		return &Value{&Value_Texture_2D{v}}
 	case *gfxapi.Cubemap:
 		return &Value{&Value_Cubemap{v}}
+	case *gfxapi.Texture1D:
+		return &Value{&Value_Texture_1D{v}}
+	case *gfxapi.Texture3D:
+		return &Value{&Value_Texture_3D{v}}
+	case *gfxapi.Texture2DArray:
+		return &Value{&Value_Texture_2DArray{v}}
+	case *gfxapi.CubemapArray:
+		return &Value{&Value_CubemapArray{v}}
 	case *gfxapi.Shader:
 		return &Value{&Value_Shader{v}}
 	case *gfxapi.Program:
 		return &Value{&Value_Program{v}}
+	case *gfxapi.DrawCallState:
+		return &Value{&Value_DrawCallState{v}}
+	case *FramebufferAttachments:
+		return &Value{&Value_FramebufferAttachments{v}}
 	case *Hierarchies:
 		return &Value{&Value_Hierarchies{v}}
 	case []*Hierarchy:
@@ -179,10 +207,22 @@ func NewValue(v interface{}) *Value {
 		return &Value{&Value_ImageInfo_2D{v}}
 	case *MemoryInfo:
 		return &Value{&Value_MemoryInfo{v}}
+	case *PixelHistory:
+		return &Value{&Value_PixelHistory{v}}
 	case *Report:
 		return &Value{&Value_Report{v}}
 	case *Resources:
 		return &Value{&Value_Resources{v}}
+	case *ShaderTrace:
+		return &Value{&Value_ShaderTrace{v}}
+	case *Stats:
+		return &Value{&Value_Stats{v}}
+	case *Bookmark:
+		return &Value{&Value_Bookmark{v}}
+	case *Bookmarks:
+		return &Value{&Value_Bookmarks{v}}
+	case []*Bookmark:
+		return &Value{&Value_Bookmarks{&Bookmarks{v}}}
 	case *device.Instance:
 		return &Value{&Value_Device{v}}
 
@@ -207,6 +247,8 @@ func (v *Value) Get() interface{} {
 		}
 		return o
 
+	case *Value_Bookmarks:
+		return v.Bookmarks.List
 	case *Value_Contexts:
 		return v.Contexts.List
 	case *Value_Hierarchies:
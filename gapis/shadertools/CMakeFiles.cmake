@@ -18,6 +18,8 @@
 # build and the file will be recreated, check in the new version.
 
 set(files
+    debug.go
+    debug_test.go
     funcs.go
     funcs_integration.go
     shadertools.go
@@ -0,0 +1,228 @@
+// Copyright (C) 2017 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package shadertools
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Names of the symbols added to a shader by Option.MakeDebuggable.
+const (
+	DebugResultName  = "gapid_result"
+	DebugSamplerName = "gapid_sampler"
+	DebugCoordName   = "gapid_coor"
+	DebugStepName    = "gapid_curr_step"
+)
+
+// DebugStepUniformName is the name of the uint uniform that selects the step
+// captured by a shader rewritten by SelectDebugStepWithUniform.
+const DebugStepUniformName = "gapid_step"
+
+// SPIR-V opcodes of the debug instructions used by DebugInfo.
+const (
+	opName                = 5
+	opLine                = 8
+	opFunctionCall        = 57
+	opAccessChain         = 65
+	opInBoundsAccessChain = 66
+	opLabel               = 248
+)
+
+// Names of the functions called by the instrumented code.
+const (
+	printFunctionName = "print"
+	labelFunctionName = "label"
+)
+
+// DebugProbe is a single print of a value inserted into a shader by
+// Option.MakeDebuggable.
+type DebugProbe struct {
+	Variable string // Name of the variable written, empty if unknown.
+	Line     uint32 // Source line of the write, 0 if unknown.
+}
+
+// DebugStep is a value captured by a single step of a debuggable shader.
+type DebugStep struct {
+	DebugProbe
+	Block uint32    // The SPIR-V id of the basic block holding the print.
+	Value [4]uint32 // The printed value. Float components are bit-cast.
+}
+
+// DebugInfo maps the values printed by a debuggable shader back to the
+// variables of the shader.
+//
+// Every executed basic block of a debuggable shader first prints its own
+// label id, followed by each of the values stored in the block in order.
+type DebugInfo struct {
+	blocks map[uint32][]DebugProbe
+}
+
+// NewDebugInfo returns the DebugInfo built from the debug instructions
+// returned by ConvertGlsl.
+func NewDebugInfo(insts []Instruction) *DebugInfo {
+	names := map[uint32]string{}
+	chains := map[uint32]uint32{}
+	for _, inst := range insts {
+		switch inst.Opcode {
+		case opName:
+			if len(inst.Words) > 0 {
+				names[inst.Words[0]] = inst.Name
+			}
+		case opAccessChain, opInBoundsAccessChain:
+			if len(inst.Words) > 2 {
+				chains[inst.Id] = inst.Words[2]
+			}
+		}
+	}
+	variable := func(id uint32) string {
+		for {
+			if name, ok := names[id]; ok {
+				return name
+			}
+			base, ok := chains[id]
+			if !ok {
+				return ""
+			}
+			id = base
+		}
+	}
+
+	d := &DebugInfo{blocks: map[uint32][]DebugProbe{}}
+	block, inBlock, line := uint32(0), false, uint32(0)
+	for _, inst := range insts {
+		switch inst.Opcode {
+		case opLabel:
+			block, inBlock, line = inst.Id, true, 0
+			d.blocks[block] = d.blocks[block]
+		case opLine:
+			if len(inst.Words) > 1 {
+				line = inst.Words[1]
+			}
+		case opFunctionCall:
+			if !inBlock || len(inst.Words) < 4 {
+				continue
+			}
+			if names[inst.Words[2]] == printFunctionName {
+				probe := DebugProbe{Variable: variable(inst.Words[3]), Line: line}
+				d.blocks[block] = append(d.blocks[block], probe)
+			}
+		}
+	}
+	return d
+}
+
+// Trace returns the prints executed by a debuggable shader given the values
+// captured by consecutive steps, starting with step 1.
+// The trace ends at the first value that is not the label of a known block,
+// such as a step past the last print of the shader, in which case complete is
+// true. If the values run out before the end of the trace then complete is
+// false, and the trace holds the prints of the captured values.
+// Calls to other functions within a block are not distinguished from the
+// prints of the calling block.
+func (d *DebugInfo) Trace(values [][4]uint32) (steps []DebugStep, complete bool) {
+	steps = []DebugStep{}
+	for i := 0; i < len(values); {
+		block := values[i][0]
+		probes, ok := d.blocks[block]
+		if !ok {
+			return steps, true
+		}
+		i++
+		for _, probe := range probes {
+			if i >= len(values) {
+				return steps, false
+			}
+			steps = append(steps, DebugStep{DebugProbe: probe, Block: block, Value: values[i]})
+			i++
+		}
+	}
+	return steps, false
+}
+
+// SelectDebugStepWithUniform rewrites the GLSL source generated for a
+// debuggable fragment shader so that the step to capture is read from the
+// uniform DebugStepUniformName instead of the debug sampler, and so that
+// DebugResultName is the only output of the shader, bound to location 0.
+// The other outputs of the shader become private globals.
+func SelectDebugStepWithUniform(source string) (string, error) {
+	lines := strings.Split(source, "\n")
+	out := make([]string, 0, len(lines)+1)
+	step := fmt.Sprintf("uniform uint %s;", DebugStepUniformName)
+	hasVersion, hasStep := false, false
+	for _, line := range lines {
+		trimmed := strings.TrimSpace(line)
+		switch {
+		case strings.HasPrefix(trimmed, "#version"):
+			out = append(out, line, step)
+			hasVersion = true
+
+		case strings.Contains(trimmed, DebugSamplerName) || strings.Contains(trimmed, DebugCoordName):
+			switch {
+			case strings.HasPrefix(trimmed, DebugStepName+" ="):
+				indent := line[:len(line)-len(strings.TrimLeft(line, " \t"))]
+				out = append(out, fmt.Sprintf("%s%s = %s;", indent, DebugStepName, DebugStepUniformName))
+				hasStep = true
+			case isGlobalDeclaration(trimmed):
+				// Drop the declaration.
+			default:
+				return "", fmt.Errorf("Unexpected use of the debug inputs: %s", trimmed)
+			}
+
+		case isOutputDeclaration(trimmed):
+			fields := strings.Fields(trimmed[strings.Index(trimmed, "out ")+len("out "):])
+			if len(fields) != 2 {
+				return "", fmt.Errorf("Unsupported output declaration: %s", trimmed)
+			}
+			ty, name := fields[0], strings.TrimSuffix(fields[1], ";")
+			if name == DebugResultName {
+				out = append(out, fmt.Sprintf("layout(location = 0) out %s %s;", ty, name))
+			} else {
+				out = append(out, fmt.Sprintf("%s %s;", ty, name))
+			}
+
+		default:
+			out = append(out, line)
+		}
+	}
+	if !hasStep {
+		return "", fmt.Errorf("Debug step assignment not found")
+	}
+	if !hasVersion {
+		out = append([]string{step}, out...)
+	}
+	return strings.Join(out, "\n"), nil
+}
+
+// isGlobalDeclaration returns true if line declares a uniform or an input.
+func isGlobalDeclaration(line string) bool {
+	if strings.HasPrefix(line, "layout(") {
+		if i := strings.Index(line, ") "); i >= 0 {
+			line = line[i+2:]
+		}
+	}
+	return strings.HasSuffix(line, ";") &&
+		(strings.HasPrefix(line, "uniform ") || strings.HasPrefix(line, "in "))
+}
+
+// isOutputDeclaration returns true if line declares a single output variable.
+func isOutputDeclaration(line string) bool {
+	if strings.HasPrefix(line, "layout(") {
+		if i := strings.Index(line, ") "); i >= 0 {
+			line = line[i+2:]
+		}
+	}
+	return strings.HasPrefix(line, "out ") && strings.HasSuffix(line, ";") && !strings.Contains(line, "{")
+}
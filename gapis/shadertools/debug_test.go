@@ -0,0 +1,226 @@
+// Copyright (C) 2017 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package shadertools
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/google/gapid/core/assert"
+	"github.com/google/gapid/core/log"
+)
+
+const (
+	printID = 10
+	otherID = 11
+	colorID = 20
+	indexID = 21
+	chainID = 30
+	fieldID = 31
+)
+
+func instName(id uint32, name string) Instruction {
+	return Instruction{Opcode: opName, Words: []uint32{id}, Name: name}
+}
+
+func instLabel(id uint32) Instruction {
+	return Instruction{Id: id, Opcode: opLabel, Words: []uint32{id}}
+}
+
+func instLine(l uint32) Instruction {
+	return Instruction{Opcode: opLine, Words: []uint32{1, l, 0}}
+}
+
+func instCall(function, arg uint32) Instruction {
+	return Instruction{Id: 90, Opcode: opFunctionCall, Words: []uint32{2, 90, function, arg}}
+}
+
+func instAccessChain(id, base uint32) Instruction {
+	return Instruction{Id: id, Opcode: opAccessChain, Words: []uint32{3, id, base, 40}}
+}
+
+func TestNewDebugInfo(t *testing.T) {
+	ctx := log.Testing(t)
+	names := []Instruction{
+		instName(printID, printFunctionName),
+		instName(otherID, "helper"),
+		instName(colorID, "color"),
+		instName(indexID, "i"),
+		instAccessChain(chainID, colorID),
+		{Id: fieldID, Opcode: opInBoundsAccessChain, Words: []uint32{3, fieldID, chainID, 41}},
+	}
+	for _, test := range []struct {
+		name     string
+		insts    []Instruction
+		expected map[uint32][]DebugProbe
+	}{
+		{"empty", nil, map[uint32][]DebugProbe{}},
+		{"prints", []Instruction{
+			instLabel(100),
+			instLine(5), instCall(printID, indexID),
+			instLine(6), instCall(printID, colorID),
+		}, map[uint32][]DebugProbe{
+			100: {{"i", 5}, {"color", 6}},
+		}},
+		{"access chains", []Instruction{
+			instLabel(100),
+			instLine(7), instCall(printID, chainID),
+			instCall(printID, fieldID),
+		}, map[uint32][]DebugProbe{
+			100: {{"color", 7}, {"color", 7}},
+		}},
+		{"unknown variable", []Instruction{
+			instLabel(100), instCall(printID, 99),
+		}, map[uint32][]DebugProbe{
+			100: {{"", 0}},
+		}},
+		{"other calls", []Instruction{
+			instLabel(100), instLine(3), instCall(otherID, colorID), instCall(printID, colorID),
+		}, map[uint32][]DebugProbe{
+			100: {{"color", 3}},
+		}},
+		{"call outside block", []Instruction{
+			instCall(printID, colorID), instLabel(100),
+		}, map[uint32][]DebugProbe{
+			100: nil,
+		}},
+		{"line reset by label", []Instruction{
+			instLabel(100), instLine(5), instCall(printID, colorID),
+			instLabel(101), instCall(printID, indexID),
+			instLabel(102),
+		}, map[uint32][]DebugProbe{
+			100: {{"color", 5}},
+			101: {{"i", 0}},
+			102: nil,
+		}},
+	} {
+		insts := append(append([]Instruction{}, names...), test.insts...)
+		d := NewDebugInfo(insts)
+		assert.For(ctx, test.name).That(d.blocks).DeepEquals(test.expected)
+	}
+}
+
+func TestDebugInfoTrace(t *testing.T) {
+	ctx := log.Testing(t)
+	d := &DebugInfo{blocks: map[uint32][]DebugProbe{
+		100: {{"color", 5}, {"i", 6}},
+		101: {{"i", 8}},
+		102: nil,
+	}}
+	end := [4]uint32{0xFFFFFFFF, 0xFFFFFFFF, 0xFFFFFFFF, 0xFFFFFFFF}
+	a, b, c := [4]uint32{1, 2, 3, 4}, [4]uint32{5, 0, 0, 0}, [4]uint32{6, 0, 0, 0}
+	for _, test := range []struct {
+		name     string
+		values   [][4]uint32
+		steps    []DebugStep
+		complete bool
+	}{
+		{"no values", nil, []DebugStep{}, false},
+		{"not a label", [][4]uint32{end}, []DebugStep{}, true},
+		{"single block", [][4]uint32{{100}, a, b, end}, []DebugStep{
+			{DebugProbe{"color", 5}, 100, a},
+			{DebugProbe{"i", 6}, 100, b},
+		}, true},
+		{"ends within block", [][4]uint32{{100}, a}, []DebugStep{
+			{DebugProbe{"color", 5}, 100, a},
+		}, false},
+		{"ends after block", [][4]uint32{{100}, a, b}, []DebugStep{
+			{DebugProbe{"color", 5}, 100, a},
+			{DebugProbe{"i", 6}, 100, b},
+		}, false},
+		{"loop", [][4]uint32{{101}, a, {102}, {101}, b, {100}, c, a, end, c}, []DebugStep{
+			{DebugProbe{"i", 8}, 101, a},
+			{DebugProbe{"i", 8}, 101, b},
+			{DebugProbe{"color", 5}, 100, c},
+			{DebugProbe{"i", 6}, 100, a},
+		}, true},
+	} {
+		steps, complete := d.Trace(test.values)
+		assert.For(ctx, "%s steps", test.name).That(steps).DeepEquals(test.steps)
+		assert.For(ctx, "%s complete", test.name).That(complete).Equals(test.complete)
+	}
+}
+
+func TestSelectDebugStepWithUniform(t *testing.T) {
+	ctx := log.Testing(t)
+	source := func(lines ...string) string { return strings.Join(lines, "\n") }
+	for _, test := range []struct {
+		name     string
+		source   string
+		expected string
+		err      string
+	}{
+		{"version", source(
+			"#version 330",
+			"uniform sampler2D gapid_sampler;",
+			"layout(location = 1) in vec2 gapid_coor;",
+			"uniform vec4 tint;",
+			"layout(location = 0) out vec4 color;",
+			"out uvec4 gapid_result;",
+			"uint gapid_curr_step;",
+			"void main() {",
+			"    gapid_curr_step = uint(texture(gapid_sampler, gapid_coor).x);",
+			"    color = tint;",
+			"}",
+		), source(
+			"#version 330",
+			"uniform uint gapid_step;",
+			"uniform vec4 tint;",
+			"vec4 color;",
+			"layout(location = 0) out uvec4 gapid_result;",
+			"uint gapid_curr_step;",
+			"void main() {",
+			"    gapid_curr_step = gapid_step;",
+			"    color = tint;",
+			"}",
+		), ""},
+		{"no version", source(
+			"uniform sampler2D gapid_sampler;",
+			"out uvec4 gapid_result;",
+			"void main() {",
+			"\tgapid_curr_step = texture(gapid_sampler, vec2(0.0)).x;",
+			"}",
+		), source(
+			"uniform uint gapid_step;",
+			"layout(location = 0) out uvec4 gapid_result;",
+			"void main() {",
+			"\tgapid_curr_step = gapid_step;",
+			"}",
+		), ""},
+		{"no step", source(
+			"#version 330",
+			"out uvec4 gapid_result;",
+			"void main() {}",
+		), "", "Debug step assignment not found"},
+		{"unexpected use", source(
+			"uniform sampler2D gapid_sampler;",
+			"void main() {",
+			"    gapid_result = texture(gapid_sampler, vec2(0.0));",
+			"}",
+		), "", "Unexpected use of the debug inputs: gapid_result = texture(gapid_sampler, vec2(0.0));"},
+		{"unsupported output", source(
+			"out highp vec4 color;",
+		), "", "Unsupported output declaration: out highp vec4 color;"},
+	} {
+		got, err := SelectDebugStepWithUniform(test.source)
+		if test.err != "" {
+			assert.For(ctx, test.name).ThatError(err).HasMessage(test.err)
+			continue
+		}
+		if assert.For(ctx, test.name).ThatError(err).Succeeded() {
+			assert.For(ctx, test.name).ThatString(got).Equals(test.expected)
+		}
+	}
+}
@@ -54,12 +54,35 @@ func ConvertGlsl(source string, option *Option) CodeWithDebugInfo {
 		Message:           C.GoString(result.message),
 		SourceCode:        C.GoString(result.source_code),
 		DisassemblyString: C.GoString(result.disassembly_string),
-		// TODO: copy all instructions
+		Info:              debugInstructions(result.info),
 	}
 	C.deleteGlslCodeWithDebug(result)
 	return ret
 }
 
+// debugInstructions copies the debug instructions held by info.
+func debugInstructions(info *C.debug_instructions_t) []Instruction {
+	if info == nil || info.insts_num == 0 {
+		return nil
+	}
+	count := uint64(info.insts_num)
+	// TODO: Remove the following hack and encoding the data without using unsafe.
+	insts := (*[1 << 28]C.instruction_t)(unsafe.Pointer(info.insts))[:count:count]
+	out := make([]Instruction, count)
+	for i, inst := range insts {
+		out[i] = Instruction{
+			Id:     uint32(inst.id),
+			Opcode: uint32(inst.opcode),
+			Name:   C.GoString(inst.name),
+		}
+		if n := uint64(inst.words_num); n > 0 {
+			words := (*[1 << 28]uint32)(unsafe.Pointer(inst.words))[:n:n]
+			out[i].Words = append([]uint32{}, words...)
+		}
+	}
+	return out
+}
+
 // DisassembleSpirvBinary disassembles the given SPIR-V binary words by calling
 // SPIRV-Tools and returns the disassembly. Returns an empty string if
 // diassembling fails.
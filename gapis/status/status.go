@@ -0,0 +1,122 @@
+// Copyright (C) 2017 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package status tracks the progress of the long running tasks performed by
+// the server, so that they can be reported to clients.
+package status
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/google/gapid/core/log"
+)
+
+// Task is a unit of work that is in flight.
+type Task struct {
+	id        uint64
+	parent    uint64
+	name      string
+	started   time.Time
+	completed uint64 // Accessed atomically.
+	total     uint64 // Accessed atomically.
+}
+
+// Snapshot is the state of a Task at a point in time.
+type Snapshot struct {
+	ID        uint64        // The unique identifier of the task.
+	Parent    uint64        // The identifier of the parent task, or 0 if none.
+	Name      string        // The name of the task.
+	Elapsed   time.Duration // The time since the task was started.
+	Completed uint64        // The number of completed units of work.
+	Total     uint64        // The total number of units of work, or 0 if unknown.
+}
+
+var (
+	mutex  sync.Mutex
+	nextID uint64
+	tasks  = map[uint64]*Task{}
+)
+
+type taskKeyTy string
+
+const taskKey = taskKeyTy("status.task")
+
+// Start begins a new task with the name formed from the format string and
+// arguments, returning a context holding the task. If ctx already holds a
+// task, then the new task is a child of that task.
+// Finish must be called with the returned context once the task has finished.
+func Start(ctx log.Context, format string, args ...interface{}) log.Context {
+	t := &Task{name: fmt.Sprintf(format, args...), started: time.Now()}
+	if parent := get(ctx); parent != nil {
+		t.parent = parent.id
+	}
+	mutex.Lock()
+	nextID++
+	t.id = nextID
+	tasks[t.id] = t
+	mutex.Unlock()
+	// The task is deliberately not added with keys.WithValue, so that it is
+	// not carried over to detached contexts.
+	return log.Wrap(context.WithValue(ctx.Unwrap(), taskKey, t))
+}
+
+// Finish ends the task held by ctx.
+func Finish(ctx log.Context) {
+	if t := get(ctx); t != nil {
+		mutex.Lock()
+		delete(tasks, t.id)
+		mutex.Unlock()
+	}
+}
+
+// UpdateProgress sets the number of completed and total units of work for the
+// task held by ctx. If ctx does not hold a task then UpdateProgress does
+// nothing.
+func UpdateProgress(ctx log.Context, completed, total uint64) {
+	if t := get(ctx); t != nil {
+		atomic.StoreUint64(&t.completed, completed)
+		atomic.StoreUint64(&t.total, total)
+	}
+}
+
+// Tasks returns a snapshot of all the tasks in flight, ordered by the time
+// they were started.
+func Tasks() []Snapshot {
+	now := time.Now()
+	mutex.Lock()
+	out := make([]Snapshot, 0, len(tasks))
+	for _, t := range tasks {
+		out = append(out, Snapshot{
+			ID:        t.id,
+			Parent:    t.parent,
+			Name:      t.name,
+			Elapsed:   now.Sub(t.started),
+			Completed: atomic.LoadUint64(&t.completed),
+			Total:     atomic.LoadUint64(&t.total),
+		})
+	}
+	mutex.Unlock()
+	sort.Slice(out, func(i, j int) bool { return out[i].ID < out[j].ID })
+	return out
+}
+
+func get(ctx log.Context) *Task {
+	t, _ := ctx.Value(taskKey).(*Task)
+	return t
+}
@@ -0,0 +1,48 @@
+// Copyright (C) 2017 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package status_test
+
+import (
+	"testing"
+
+	"github.com/google/gapid/core/assert"
+	"github.com/google/gapid/core/log"
+	"github.com/google/gapid/gapis/status"
+)
+
+func TestTasks(t *testing.T) {
+	ctx := log.Testing(t)
+	assert.With(ctx).ThatInteger(len(status.Tasks())).Equals(0)
+
+	parent := status.Start(ctx, "parent %d", 1)
+	child := status.Start(parent, "child")
+	status.UpdateProgress(child, 3, 10)
+	status.UpdateProgress(ctx, 5, 5) // No task, should be ignored.
+
+	tasks := status.Tasks()
+	if assert.With(ctx).ThatInteger(len(tasks)).Equals(2) {
+		assert.With(ctx).That(tasks[0].Name).Equals("parent 1")
+		assert.With(ctx).That(tasks[0].Parent).Equals(uint64(0))
+		assert.With(ctx).That(tasks[1].Name).Equals("child")
+		assert.With(ctx).That(tasks[1].Parent).Equals(tasks[0].ID)
+		assert.With(ctx).That(tasks[1].Completed).Equals(uint64(3))
+		assert.With(ctx).That(tasks[1].Total).Equals(uint64(10))
+	}
+
+	status.Finish(child)
+	assert.With(ctx).ThatInteger(len(status.Tasks())).Equals(1)
+	status.Finish(parent)
+	assert.With(ctx).ThatInteger(len(status.Tasks())).Equals(0)
+}
@@ -266,7 +266,7 @@ func checkColorBuffer(ctx log.Context, intent replay.Intent, mgr *replay.Manager
 	}
 	ctx, _ = task.WithTimeout(ctx, replayTimeout)
 	img, err := gles.API().(replay.QueryFramebufferAttachment).QueryFramebufferAttachment(
-		ctx, intent, mgr, after, w, h, gfxapi.FramebufferAttachment_Color0, replay.WireframeMode_None)
+		ctx, intent, mgr, after, w, h, gfxapi.FramebufferAttachment_Color0, nil, replay.WireframeMode_None, replay.DrawMode_Normal)
 	if !assert.With(ctx).ThatError(err).Succeeded() {
 		return
 	}
@@ -280,7 +280,7 @@ func checkDepthBuffer(ctx log.Context, intent replay.Intent, mgr *replay.Manager
 	}
 	ctx, _ = task.WithTimeout(ctx, replayTimeout)
 	img, err := gles.API().(replay.QueryFramebufferAttachment).QueryFramebufferAttachment(
-		ctx, intent, mgr, after, w, h, gfxapi.FramebufferAttachment_Depth, replay.WireframeMode_None)
+		ctx, intent, mgr, after, w, h, gfxapi.FramebufferAttachment_Depth, nil, replay.WireframeMode_None, replay.DrawMode_Normal)
 	if !assert.With(ctx).ThatError(err).Succeeded() {
 		return
 	}
@@ -18,6 +18,7 @@ package service_test
 
 import (
 	"bytes"
+	"fmt"
 	"reflect"
 	"testing"
 
@@ -91,6 +92,14 @@ func (a adapter) GetDevicesForReplay(ctx context.Context, in *service.GetDevices
 func (a adapter) GetFramebufferAttachment(ctx context.Context, in *service.GetFramebufferAttachmentRequest, opts ...grpc.CallOption) (*service.GetFramebufferAttachmentResponse, error) {
 	return a.GapidServer.GetFramebufferAttachment(context.Background(), in)
 }
+func (a adapter) Status(ctx context.Context, in *service.StatusRequest, opts ...grpc.CallOption) (service.Gapid_StatusClient, error) {
+	// Streaming calls cannot be adapted without a transport.
+	return nil, fmt.Errorf("Status is not supported by the adapter")
+}
+func (a adapter) FindCommands(ctx context.Context, in *service.FindCommandsRequest, opts ...grpc.CallOption) (service.Gapid_FindCommandsClient, error) {
+	// Streaming calls cannot be adapted without a transport.
+	return nil, fmt.Errorf("FindCommands is not supported by the adapter")
+}
 
 func setup(t *testing.T) (log.Context, server.Server) {
 	ctx := log.Testing(t)
@@ -215,7 +224,7 @@ func TestGetFramebufferAttachment(t *testing.T) {
 	after := capture.Commands().Index(drawAtomIndex)
 	attachment := gfxapi.FramebufferAttachment_Color0
 	settings := &service.RenderSettings{}
-	got, err := server.GetFramebufferAttachment(ctx, devices[0], after, attachment, settings)
+	got, err := server.GetFramebufferAttachment(ctx, devices[0], after, attachment, nil, settings)
 	assert.With(ctx).ThatError(err).Succeeded()
 	assert.With(ctx).That(got).IsNotNil()
 }
@@ -39,7 +39,7 @@ var (
 			return t.trace.subject
 		},
 		enumSrc: func() enum {
-			return itemGetter("{{.id}}", "{{.Information.APK.package}}")(queryArray("/subjects/"))
+			return itemGetter("{{.id}}", "{{.Information.APK.name}}")(queryArray("/subjects/"))
 		},
 	}
 	targetDimension = &dimension{